@@ -130,7 +130,7 @@ func (s *Server) updateAssessmentV2(c *gin.Context) {
 		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
 	case constant.ErrForbidden:
 		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
-	case model.ErrAssessmentHasCompleted, constant.ErrInvalidArgs:
+	case model.ErrAssessmentHasCompleted, model.ErrAssessmentPendingModeration, constant.ErrInvalidArgs:
 		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
 	default:
 		s.defaultErrorHandler(c, err)
@@ -285,6 +285,7 @@ func (s *Server) addAssessment(c *gin.Context) {
 	log.Info(ctx, "add assessment jwt: fill args", log.Any("args", args), log.String("token", body.Token))
 
 	operator := s.getOperator(c)
+	// the event is the only write of the callback, it is in the outbox before the reply
 	err := model.GetLiveRoomEventBusModel().PubEndClass(ctx, operator, args)
 	switch err {
 	case nil:
@@ -335,7 +336,7 @@ func (s *Server) queryAssessments(c *gin.Context) {
 	if req.Status == v2.AssessmentStatusCompliantCompleted.String() {
 		req.Status = v2.AssessmentStatusComplete.String()
 	} else if req.Status == v2.AssessmentStatusCompliantNotCompleted.String() {
-		req.Status = fmt.Sprintf("%s,%s", v2.AssessmentStatusStarted.String(), v2.AssessmentStatusInDraft.String())
+		req.Status = fmt.Sprintf("%s,%s,%s", v2.AssessmentStatusStarted.String(), v2.AssessmentStatusInDraft.String(), v2.AssessmentStatusPendingModeration.String())
 	}
 
 	if req.PageSize <= 0 || req.PageIndex <= 0 {
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// @Summary query assessment auto complete rules
+// @Description query auto complete rules of the organization
+// @Tags assessments
+// @ID queryAssessmentAutoCompleteRules
+// @Accept json
+// @Produce json
+// @Success 200 {array} v2.AssessmentAutoCompleteRuleReply
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_auto_complete_rules [get]
+func (s *Server) queryAssessmentAutoCompleteRules(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetAssessmentAutoCompleteModel().QueryRules(ctx, op)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary add assessment auto complete rule
+// @Description online class and online study assessments are completed by the system when any rule of the organization matches
+// @Tags assessments
+// @ID addAssessmentAutoCompleteRule
+// @Accept json
+// @Produce json
+// @Param req body v2.AssessmentAutoCompleteRuleAddReq true "auto complete rule"
+// @Success 200 {object} IDResponse
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_auto_complete_rules [post]
+func (s *Server) addAssessmentAutoCompleteRule(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentAutoCompleteRuleAddReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "add auto complete rule: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	id, err := model.GetAssessmentAutoCompleteModel().AddRule(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, IDResponse{ID: id})
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary update assessment auto complete rule
+// @Description update an auto complete rule of the organization
+// @Tags assessments
+// @ID updateAssessmentAutoCompleteRule
+// @Accept json
+// @Produce json
+// @Param id path string true "rule id"
+// @Param req body v2.AssessmentAutoCompleteRuleAddReq true "auto complete rule"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_auto_complete_rules/{id} [put]
+func (s *Server) updateAssessmentAutoCompleteRule(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentAutoCompleteRuleUpdateReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "update auto complete rule: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+	req.ID = c.Param("id")
+
+	err := model.GetAssessmentAutoCompleteModel().UpdateRule(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary delete assessment auto complete rule
+// @Description delete an auto complete rule, assessments already completed by it are not affected
+// @Tags assessments
+// @ID deleteAssessmentAutoCompleteRule
+// @Accept json
+// @Produce json
+// @Param id path string true "rule id"
+// @Success 200 {string} string "OK"
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_auto_complete_rules/{id} [delete]
+func (s *Server) deleteAssessmentAutoCompleteRule(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	err := model.GetAssessmentAutoCompleteModel().DeleteRule(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary reopen assessment
+// @Description put an assessment completed by the system back to draft, it is removed from the gradebook until completed again
+// @Tags assessments
+// @ID reopenAssessment
+// @Accept json
+// @Produce json
+// @Param id path string true "assessment id"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_v2/{id}/reopen [put]
+func (s *Server) reopenAssessment(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	err := model.GetAssessmentAutoCompleteModel().Reopen(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
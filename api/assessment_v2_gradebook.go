@@ -0,0 +1,519 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+// @Summary get gradebook of class
+// @Description completed assessments of a class flattened into line items and student results
+// @Tags assessments
+// @ID getAssessmentGradebook
+// @Accept json
+// @Produce json
+// @Param class_id query string true "class id"
+// @Param complete_at_ge query integer false "complete at greater or equal, unix seconds"
+// @Param complete_at_le query integer false "complete at less or equal, unix seconds"
+// @Success 200 {object} v2.AssessmentGradebookReply
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_gradebooks [get]
+func (s *Server) getAssessmentGradebook(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentGradebookReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "get gradebook: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetAssessmentGradebookModel().GetByClass(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary export gradebook of class
+// @Description export gradebook of class as csv or xlsx, one row per student and assessment
+// @Tags assessments
+// @ID exportAssessmentGradebook
+// @Accept json
+// @Produce octet-stream
+// @Param class_id query string true "class id"
+// @Param complete_at_ge query integer false "complete at greater or equal, unix seconds"
+// @Param complete_at_le query integer false "complete at less or equal, unix seconds"
+// @Param format query string false "file format" enums(csv,xlsx) default(csv)
+// @Success 200 {file} file
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_gradebooks/export [get]
+func (s *Server) exportAssessmentGradebook(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentGradebookExportReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "export gradebook: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+	if req.Format == "" {
+		req.Format = v2.AssessmentGradebookExportFormatCsv
+	}
+	if !req.Format.Valid() {
+		log.Warn(ctx, "export gradebook: invalid format", log.Any("req", req))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetAssessmentGradebookModel().GetByClass(ctx, op, &req.AssessmentGradebookReq)
+	switch err {
+	case nil:
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+		return
+	default:
+		s.defaultErrorHandler(c, err)
+		return
+	}
+
+	fileName := fmt.Sprintf("gradebook_%s_%s.%s", req.ClassID, time.Now().UTC().Format("20060102"), req.Format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+
+	rows := result.ExportRows()
+	switch req.Format {
+	case v2.AssessmentGradebookExportFormatXlsx:
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		err = utils.WriteXlsx(c.Writer, "Gradebook", rows)
+	default:
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		err = writeGradebookCsv(c, rows)
+	}
+	if err != nil {
+		log.Error(ctx, "export gradebook: write file failed", log.Err(err), log.Any("req", req))
+	}
+}
+
+func writeGradebookCsv(c *gin.Context, rows [][]interface{}) error {
+	writer := csv.NewWriter(c.Writer)
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			switch v := cell.(type) {
+			case nil:
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// @Summary sync gradebook of class
+// @Description rebuild gradebook snapshots from completed assessments of a class
+// @Tags assessments
+// @ID syncAssessmentGradebook
+// @Accept json
+// @Produce json
+// @Param req body v2.AssessmentGradebookSyncReq true "sync gradebook args"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_gradebooks/sync [post]
+func (s *Server) syncAssessmentGradebook(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentGradebookSyncReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "sync gradebook: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	_, err := model.GetAssessmentGradebookModel().SyncClass(ctx, op, req.ClassID)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary query organization api keys
+// @Description api keys of the organization used by the OneRoster gradebook service
+// @Tags organizationAPIKey
+// @ID queryOrganizationAPIKeys
+// @Accept json
+// @Produce json
+// @Success 200 {array} entity.OrganizationAPIKeyReply
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /organizations_api_keys [get]
+func (s *Server) queryOrganizationAPIKeys(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetOrganizationAPIKeyModel().Query(ctx, op)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary add organization api key
+// @Description the key is only returned once, only its hash is stored
+// @Tags organizationAPIKey
+// @ID addOrganizationAPIKey
+// @Accept json
+// @Produce json
+// @Param req body entity.OrganizationAPIKeyAddReq true "add api key args"
+// @Success 200 {object} entity.OrganizationAPIKeyCreatedReply
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /organizations_api_keys [post]
+func (s *Server) addOrganizationAPIKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.OrganizationAPIKeyAddReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "add api key: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetOrganizationAPIKeyModel().Add(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary delete organization api key
+// @Description revoke an api key
+// @Tags organizationAPIKey
+// @ID deleteOrganizationAPIKey
+// @Accept json
+// @Produce json
+// @Param id path string true "api key id"
+// @Success 200 {string} string "OK"
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /organizations_api_keys/{id} [delete]
+func (s *Server) deleteOrganizationAPIKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	id := c.Param("id")
+
+	err := model.GetOrganizationAPIKeyModel().Delete(ctx, op, id)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// oneRoster
+
+func (s *Server) bindOneRosterQuery(c *gin.Context) (*v2.OneRosterQuery, bool) {
+	ctx := c.Request.Context()
+	query := new(v2.OneRosterQuery)
+	if err := c.ShouldBindQuery(query); err != nil {
+		log.Warn(ctx, "oneroster: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, v2.NewOneRosterErrorReply(v2.OneRosterCodeMinorInvalidData, "invalid limit or offset"))
+		return nil, false
+	}
+
+	dateLastModifiedGt, err := v2.ParseOneRosterFilter(c.Query("filter"))
+	if err != nil {
+		log.Warn(ctx, "oneroster: parse filter failed", log.Err(err), log.String("filter", c.Query("filter")))
+		c.JSON(http.StatusBadRequest, v2.NewOneRosterErrorReply(v2.OneRosterCodeMinorInvalidData, "only dateLastModified> filter is supported"))
+		return nil, false
+	}
+	query.DateLastModifiedGt = dateLastModifiedGt
+
+	return query, true
+}
+
+func (s *Server) oneRosterErrorHandler(c *gin.Context, err error) {
+	switch err {
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, v2.NewOneRosterErrorReply(v2.OneRosterCodeMinorUnknownObject, "object not found"))
+	default:
+		log.Error(c.Request.Context(), "oneroster: request failed", log.Err(err))
+		c.JSON(http.StatusInternalServerError, v2.NewOneRosterErrorReply(v2.OneRosterCodeMinorServerBusy, "server error"))
+	}
+}
+
+// @Summary OneRoster get all line items
+// @Description line items of the organization, one line item per completed assessment
+// @Tags oneRoster
+// @ID getOneRosterLineItems
+// @Produce json
+// @Param limit query integer false "limit" default(100)
+// @Param offset query integer false "offset" default(0)
+// @Param filter query string false "dateLastModified>'2022-01-01T00:00:00.000Z'"
+// @Success 200 {object} v2.OneRosterLineItemsReply
+// @Failure 400 {object} v2.OneRosterErrorReply
+// @Failure 401 {object} v2.OneRosterErrorReply
+// @Failure 500 {object} v2.OneRosterErrorReply
+// @Router /ims/oneroster/gradebook/v1p2/lineItems [get]
+func (s *Server) getOneRosterLineItems(c *gin.Context) {
+	s.queryOneRosterLineItems(c, "")
+}
+
+// @Summary OneRoster get line items for class
+// @Tags oneRoster
+// @ID getOneRosterLineItemsForClass
+// @Produce json
+// @Param class_id path string true "class id"
+// @Param limit query integer false "limit" default(100)
+// @Param offset query integer false "offset" default(0)
+// @Param filter query string false "dateLastModified>'2022-01-01T00:00:00.000Z'"
+// @Success 200 {object} v2.OneRosterLineItemsReply
+// @Failure 400 {object} v2.OneRosterErrorReply
+// @Failure 401 {object} v2.OneRosterErrorReply
+// @Failure 500 {object} v2.OneRosterErrorReply
+// @Router /ims/oneroster/gradebook/v1p2/classes/{class_id}/lineItems [get]
+func (s *Server) getOneRosterLineItemsForClass(c *gin.Context) {
+	s.queryOneRosterLineItems(c, c.Param("class_id"))
+}
+
+func (s *Server) queryOneRosterLineItems(c *gin.Context, classID string) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	query, ok := s.bindOneRosterQuery(c)
+	if !ok {
+		return
+	}
+
+	total, result, err := model.GetAssessmentGradebookModel().QueryOneRosterLineItems(ctx, op, classID, query)
+	if err != nil {
+		s.oneRosterErrorHandler(c, err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.JSON(http.StatusOK, &v2.OneRosterLineItemsReply{LineItems: result})
+}
+
+// @Summary OneRoster get line item
+// @Tags oneRoster
+// @ID getOneRosterLineItem
+// @Produce json
+// @Param id path string true "line item sourcedId"
+// @Success 200 {object} v2.OneRosterLineItemReply
+// @Failure 401 {object} v2.OneRosterErrorReply
+// @Failure 404 {object} v2.OneRosterErrorReply
+// @Failure 500 {object} v2.OneRosterErrorReply
+// @Router /ims/oneroster/gradebook/v1p2/lineItems/{id} [get]
+func (s *Server) getOneRosterLineItem(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetAssessmentGradebookModel().GetOneRosterLineItem(ctx, op, c.Param("id"))
+	if err != nil {
+		s.oneRosterErrorHandler(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, &v2.OneRosterLineItemReply{LineItem: result})
+}
+
+// @Summary OneRoster get all results
+// @Tags oneRoster
+// @ID getOneRosterResults
+// @Produce json
+// @Param limit query integer false "limit" default(100)
+// @Param offset query integer false "offset" default(0)
+// @Param filter query string false "dateLastModified>'2022-01-01T00:00:00.000Z'"
+// @Success 200 {object} v2.OneRosterResultsReply
+// @Failure 400 {object} v2.OneRosterErrorReply
+// @Failure 401 {object} v2.OneRosterErrorReply
+// @Failure 500 {object} v2.OneRosterErrorReply
+// @Router /ims/oneroster/gradebook/v1p2/results [get]
+func (s *Server) getOneRosterResults(c *gin.Context) {
+	s.queryOneRosterResults(c, &model.OneRosterResultFilter{})
+}
+
+// @Summary OneRoster get results for class
+// @Tags oneRoster
+// @ID getOneRosterResultsForClass
+// @Produce json
+// @Param class_id path string true "class id"
+// @Param limit query integer false "limit" default(100)
+// @Param offset query integer false "offset" default(0)
+// @Param filter query string false "dateLastModified>'2022-01-01T00:00:00.000Z'"
+// @Success 200 {object} v2.OneRosterResultsReply
+// @Failure 400 {object} v2.OneRosterErrorReply
+// @Failure 401 {object} v2.OneRosterErrorReply
+// @Failure 500 {object} v2.OneRosterErrorReply
+// @Router /ims/oneroster/gradebook/v1p2/classes/{class_id}/results [get]
+func (s *Server) getOneRosterResultsForClass(c *gin.Context) {
+	s.queryOneRosterResults(c, &model.OneRosterResultFilter{ClassID: c.Param("class_id")})
+}
+
+// @Summary OneRoster get results for line item
+// @Tags oneRoster
+// @ID getOneRosterResultsForLineItem
+// @Produce json
+// @Param id path string true "line item sourcedId"
+// @Param limit query integer false "limit" default(100)
+// @Param offset query integer false "offset" default(0)
+// @Param filter query string false "dateLastModified>'2022-01-01T00:00:00.000Z'"
+// @Success 200 {object} v2.OneRosterResultsReply
+// @Failure 400 {object} v2.OneRosterErrorReply
+// @Failure 401 {object} v2.OneRosterErrorReply
+// @Failure 500 {object} v2.OneRosterErrorReply
+// @Router /ims/oneroster/gradebook/v1p2/lineItems/{id}/results [get]
+func (s *Server) getOneRosterResultsForLineItem(c *gin.Context) {
+	s.queryOneRosterResults(c, &model.OneRosterResultFilter{LineItemID: c.Param("id")})
+}
+
+// @Summary OneRoster get results for student for class
+// @Tags oneRoster
+// @ID getOneRosterResultsForStudentForClass
+// @Produce json
+// @Param class_id path string true "class id"
+// @Param student_id path string true "student id"
+// @Param limit query integer false "limit" default(100)
+// @Param offset query integer false "offset" default(0)
+// @Param filter query string false "dateLastModified>'2022-01-01T00:00:00.000Z'"
+// @Success 200 {object} v2.OneRosterResultsReply
+// @Failure 400 {object} v2.OneRosterErrorReply
+// @Failure 401 {object} v2.OneRosterErrorReply
+// @Failure 500 {object} v2.OneRosterErrorReply
+// @Router /ims/oneroster/gradebook/v1p2/classes/{class_id}/students/{student_id}/results [get]
+func (s *Server) getOneRosterResultsForStudentForClass(c *gin.Context) {
+	s.queryOneRosterResults(c, &model.OneRosterResultFilter{
+		ClassID:   c.Param("class_id"),
+		StudentID: c.Param("student_id"),
+	})
+}
+
+func (s *Server) queryOneRosterResults(c *gin.Context, filter *model.OneRosterResultFilter) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	query, ok := s.bindOneRosterQuery(c)
+	if !ok {
+		return
+	}
+
+	total, result, err := model.GetAssessmentGradebookModel().QueryOneRosterResults(ctx, op, filter, query)
+	if err != nil {
+		s.oneRosterErrorHandler(c, err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.JSON(http.StatusOK, &v2.OneRosterResultsReply{Results: result})
+}
+
+// @Summary OneRoster get result
+// @Tags oneRoster
+// @ID getOneRosterResult
+// @Produce json
+// @Param id path string true "result sourcedId"
+// @Success 200 {object} v2.OneRosterResultReply
+// @Failure 401 {object} v2.OneRosterErrorReply
+// @Failure 404 {object} v2.OneRosterErrorReply
+// @Failure 500 {object} v2.OneRosterErrorReply
+// @Router /ims/oneroster/gradebook/v1p2/results/{id} [get]
+func (s *Server) getOneRosterResult(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetAssessmentGradebookModel().GetOneRosterResult(ctx, op, c.Param("id"))
+	if err != nil {
+		s.oneRosterErrorHandler(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, &v2.OneRosterResultReply{Result: result})
+}
+
+// @Summary OneRoster get all categories
+// @Description line items are categorized by assessment type
+// @Tags oneRoster
+// @ID getOneRosterCategories
+// @Produce json
+// @Success 200 {object} v2.OneRosterCategoriesReply
+// @Failure 401 {object} v2.OneRosterErrorReply
+// @Router /ims/oneroster/gradebook/v1p2/categories [get]
+func (s *Server) getOneRosterCategories(c *gin.Context) {
+	result := v2.OneRosterCategories()
+	c.Header("X-Total-Count", strconv.Itoa(len(result)))
+	c.JSON(http.StatusOK, &v2.OneRosterCategoriesReply{Categories: result})
+}
+
+// @Summary OneRoster get category
+// @Tags oneRoster
+// @ID getOneRosterCategory
+// @Produce json
+// @Param id path string true "category sourcedId"
+// @Success 200 {object} v2.OneRosterCategoryReply
+// @Failure 401 {object} v2.OneRosterErrorReply
+// @Failure 404 {object} v2.OneRosterErrorReply
+// @Router /ims/oneroster/gradebook/v1p2/categories/{id} [get]
+func (s *Server) getOneRosterCategory(c *gin.Context) {
+	id := c.Param("id")
+	for _, item := range v2.OneRosterCategories() {
+		if item.SourcedID == id {
+			c.JSON(http.StatusOK, &v2.OneRosterCategoryReply{Category: item})
+			return
+		}
+	}
+
+	s.oneRosterErrorHandler(c, constant.ErrRecordNotFound)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// @Summary item analysis of lesson material
+// @Description difficulty, discrimination, answer distribution and attempts of each H5P item of a lesson material, aggregated over the latest completed assessments in the range
+// @Tags assessments
+// @ID getAssessmentItemAnalysis
+// @Accept json
+// @Produce json
+// @Param content_id query string true "lesson material id, all versions are included"
+// @Param class_id query string false "class id"
+// @Param complete_at_ge query integer true "complete at greater or equal, unix seconds"
+// @Param complete_at_le query integer true "complete at less or equal, unix seconds"
+// @Success 200 {object} v2.AssessmentItemAnalysisReply
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_item_analysis [get]
+func (s *Server) getAssessmentItemAnalysis(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentItemAnalysisReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "item analysis: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetAssessmentItemAnalysisModel().Analyze(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
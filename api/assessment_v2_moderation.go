@@ -0,0 +1,212 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// @Summary query assessment moderation policies
+// @Description query moderation policies of the organization, one per program
+// @Tags assessments
+// @ID queryAssessmentModerationPolicies
+// @Accept json
+// @Produce json
+// @Success 200 {array} v2.AssessmentModerationPolicyReply
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_moderation_policies [get]
+func (s *Server) queryAssessmentModerationPolicies(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetAssessmentModerationModel().QueryPolicies(ctx, op)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary set assessment moderation policy
+// @Description add or replace the moderation policy of a program, completed assessments are sampled by percentage and always moderated when a student scores below the fail threshold
+// @Tags assessments
+// @ID setAssessmentModerationPolicy
+// @Accept json
+// @Produce json
+// @Param req body v2.AssessmentModerationPolicySetReq true "moderation policy"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_moderation_policies [put]
+func (s *Server) setAssessmentModerationPolicy(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentModerationPolicySetReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "set moderation policy: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	err := model.GetAssessmentModerationModel().SetPolicy(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary delete assessment moderation policy
+// @Description delete the moderation policy of a program, assessments already waiting for moderation stay in the queue
+// @Tags assessments
+// @ID deleteAssessmentModerationPolicy
+// @Accept json
+// @Produce json
+// @Param program_id path string true "program id"
+// @Success 200 {string} string "OK"
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_moderation_policies/{program_id} [delete]
+func (s *Server) deleteAssessmentModerationPolicy(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	err := model.GetAssessmentModerationModel().DeletePolicy(ctx, op, c.Param("program_id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary query assessment moderations
+// @Description queue of assessments awaiting moderation, assessments marked by the current user are excluded
+// @Tags assessments
+// @ID queryAssessmentModerations
+// @Accept json
+// @Produce json
+// @Param status query string false "moderation status" enums(Pending,Reconciled) default(Pending)
+// @Param page query int false "page number" default(1)
+// @Param page_size query integer false "page size" format(int) default(10)
+// @Success 200 {object} v2.AssessmentModerationPageReply
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_moderations [get]
+func (s *Server) queryAssessmentModerations(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentModerationQueryReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "query moderations: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	if req.PageSize <= 0 || req.PageIndex <= 0 {
+		req.PageIndex = constant.DefaultPageIndex
+		req.PageSize = constant.DefaultPageSize
+	}
+
+	result, err := model.GetAssessmentModerationModel().Page(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary get assessment moderation
+// @Description moderator view, the assessment detail with the original scores and outcome decisions next to the moderated ones
+// @Tags assessments
+// @ID getAssessmentModeration
+// @Accept json
+// @Produce json
+// @Param id path string true "moderation id"
+// @Success 200 {object} v2.AssessmentModerationDetailReply
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_moderations/{id} [get]
+func (s *Server) getAssessmentModeration(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetAssessmentModerationModel().GetByID(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary update assessment moderation
+// @Description save moderated scores and outcome decisions as draft, or reconcile them as the final result which completes the assessment
+// @Tags assessments
+// @ID updateAssessmentModeration
+// @Accept json
+// @Produce json
+// @Param id path string true "moderation id"
+// @Param req body v2.AssessmentModerationUpdateReq true "moderated result"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessments_moderations/{id} [put]
+func (s *Server) updateAssessmentModeration(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentModerationUpdateReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "update moderation: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+	req.ID = c.Param("id")
+
+	err := model.GetAssessmentModerationModel().Update(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case model.ErrAssessmentHasCompleted, constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
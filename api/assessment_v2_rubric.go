@@ -0,0 +1,265 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// @Summary query assessment rubrics
+// @Description query rubrics of the organization
+// @Tags assessments
+// @ID queryAssessmentRubrics
+// @Accept json
+// @Produce json
+// @Param name query string false "rubric name search"
+// @Param page query int false "page number" default(1)
+// @Param page_size query integer false "page size" format(int) default(10)
+// @Param order_by query string false "query order by" enums(create_at,-create_at,name,-name) default(-create_at)
+// @Success 200 {object} v2.AssessmentRubricPageReply
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessment_rubrics [get]
+func (s *Server) queryAssessmentRubrics(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentRubricQueryReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "query rubrics: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	if req.PageSize <= 0 || req.PageIndex <= 0 {
+		req.PageIndex = constant.DefaultPageIndex
+		req.PageSize = constant.DefaultPageSize
+	}
+
+	result, err := model.GetAssessmentRubricModel().Page(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary get assessment rubric
+// @Description get rubric with criteria and performance levels
+// @Tags assessments
+// @ID getAssessmentRubric
+// @Accept json
+// @Produce json
+// @Param id path string true "rubric id"
+// @Success 200 {object} v2.AssessmentRubricReply
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessment_rubrics/{id} [get]
+func (s *Server) getAssessmentRubric(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	id := c.Param("id")
+
+	result, err := model.GetAssessmentRubricModel().GetByID(ctx, op, id)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrRecordNotFound, sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary add assessment rubric
+// @Description add rubric with criteria, performance levels and optional outcome per criterion
+// @Tags assessments
+// @ID addAssessmentRubric
+// @Accept json
+// @Produce json
+// @Param req body v2.AssessmentRubricAddReq true "add rubric args"
+// @Success 200 {object} IDResponse
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessment_rubrics [post]
+func (s *Server) addAssessmentRubric(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentRubricAddReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "add rubric: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	id, err := model.GetAssessmentRubricModel().Add(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, IDResponse{ID: id})
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary update assessment rubric
+// @Description update rubric, criteria and levels can not be changed once students are scored with it
+// @Tags assessments
+// @ID updateAssessmentRubric
+// @Accept json
+// @Produce json
+// @Param id path string true "rubric id"
+// @Param req body v2.AssessmentRubricAddReq true "update rubric args"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 409 {object} ConflictResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessment_rubrics/{id} [put]
+func (s *Server) updateAssessmentRubric(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentRubricUpdateReq)
+	if err := c.ShouldBindJSON(&req.AssessmentRubricAddReq); err != nil {
+		log.Warn(ctx, "update rubric: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+	req.ID = c.Param("id")
+
+	err := model.GetAssessmentRubricModel().Update(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	case model.ErrAssessmentRubricInUse:
+		c.JSON(http.StatusConflict, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary delete assessment rubric
+// @Description delete rubric and detach it from lesson plans and schedules
+// @Tags assessments
+// @ID deleteAssessmentRubric
+// @Accept json
+// @Produce json
+// @Param id path string true "rubric id"
+// @Success 200 {string} string "OK"
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessment_rubrics/{id} [delete]
+func (s *Server) deleteAssessmentRubric(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	id := c.Param("id")
+
+	err := model.GetAssessmentRubricModel().Delete(ctx, op, id)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary attach assessment rubric
+// @Description attach rubric to a lesson plan or a schedule, replaces the rubric attached before
+// @Tags assessments
+// @ID attachAssessmentRubric
+// @Accept json
+// @Produce json
+// @Param req body v2.AssessmentRubricAttachReq true "attach rubric args"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessment_rubrics_relations [post]
+func (s *Server) attachAssessmentRubric(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(v2.AssessmentRubricAttachReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "attach rubric: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	err := model.GetAssessmentRubricModel().Attach(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary detach assessment rubric
+// @Description detach rubric from a lesson plan or a schedule
+// @Tags assessments
+// @ID detachAssessmentRubric
+// @Accept json
+// @Produce json
+// @Param relation_type query string true "relation type" enums(LessonPlan,Schedule)
+// @Param relation_id query string true "lesson plan id or schedule id"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /assessment_rubrics_relations [delete]
+func (s *Server) detachAssessmentRubric(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	relationType := v2.AssessmentRubricRelationType(c.Query("relation_type"))
+	relationID := c.Query("relation_id")
+
+	err := model.GetAssessmentRubricModel().Detach(ctx, op, relationType, relationID)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
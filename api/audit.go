@@ -0,0 +1,230 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// @Summary query audit logs
+// @Description mutating requests of the organization, latest first
+// @Tags audit
+// @ID queryAuditLogs
+// @Accept json
+// @Produce json
+// @Param actor_id query string false "user id of the operator"
+// @Param action query string false "action, the api handler name, e.g. updateSchedule"
+// @Param target_type query string false "target type, e.g. schedules"
+// @Param target_id query string false "target id"
+// @Param method query string false "http method" enums(POST,PUT,PATCH,DELETE)
+// @Param start_at query integer false "created at or after, unix seconds"
+// @Param end_at query integer false "created at or before, unix seconds"
+// @Param page query int false "page number" default(1)
+// @Param page_size query integer false "page size" format(int) default(10)
+// @Success 200 {object} entity.AuditLogPageReply
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /audit_logs [get]
+func (s *Server) queryAuditLogs(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.AuditLogQueryReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "query audit logs: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	if req.PageSize <= 0 || req.PageIndex <= 0 {
+		req.PageIndex = constant.DefaultPageIndex
+		req.PageSize = constant.DefaultPageSize
+	}
+
+	result, err := model.GetAuditModel().Query(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary export audit logs
+// @Description export audit logs as csv, latest first, rows beyond the export limit are left out
+// @Tags audit
+// @ID exportAuditLogs
+// @Accept json
+// @Produce octet-stream
+// @Param actor_id query string false "user id of the operator"
+// @Param action query string false "action, the api handler name, e.g. updateSchedule"
+// @Param target_type query string false "target type, e.g. schedules"
+// @Param target_id query string false "target id"
+// @Param method query string false "http method" enums(POST,PUT,PATCH,DELETE)
+// @Param start_at query integer false "created at or after, unix seconds"
+// @Param end_at query integer false "created at or before, unix seconds"
+// @Success 200 {file} file
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /audit_logs/export [get]
+func (s *Server) exportAuditLogs(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.AuditLogQueryReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "export audit logs: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	// headers are written with the first page, errors before it are replied as usual
+	var writer *csv.Writer
+	start := func() error {
+		fileName := fmt.Sprintf("audit_logs_%s.csv", time.Now().UTC().Format("20060102"))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		writer = csv.NewWriter(c.Writer)
+		return writer.Write(auditLogCsvHeader)
+	}
+	err := model.GetAuditModel().Export(ctx, op, req, func(logs []*entity.AuditLogView) error {
+		if writer == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		for _, item := range logs {
+			if err := writer.Write(auditLogCsvRecord(item)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+	if err == nil && writer == nil {
+		// nothing matched, reply the header only
+		if err = start(); err == nil {
+			writer.Flush()
+			err = writer.Error()
+		}
+	}
+
+	switch {
+	case err == nil:
+	case writer != nil:
+		// the file has been partly sent, nothing else can be replied
+		log.Error(ctx, "export audit logs: write file failed", log.Err(err), log.Any("req", req))
+	case err == constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case err == constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+var auditLogCsvHeader = []string{
+	"id", "create_at", "actor_id", "action", "target_type", "target_id",
+	"method", "path", "status_code", "ip", "user_agent", "duration", "before", "after",
+}
+
+func auditLogCsvRecord(item *entity.AuditLogView) []string {
+	return []string{
+		item.ID,
+		time.Unix(item.CreateAt, 0).UTC().Format(time.RFC3339),
+		item.ActorID,
+		item.Action,
+		item.TargetType,
+		escapeCsvFormula(item.TargetID),
+		item.Method,
+		escapeCsvFormula(item.Path),
+		strconv.Itoa(item.StatusCode),
+		escapeCsvFormula(item.IP),
+		escapeCsvFormula(item.UserAgent),
+		strconv.FormatInt(item.Duration, 10),
+		escapeCsvFormula(item.Before),
+		escapeCsvFormula(item.After),
+	}
+}
+
+// escapeCsvFormula values from requests must not be run as formulas by spreadsheets
+func escapeCsvFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// @Summary get audit setting
+// @Description retention of audit logs in the organization, the default retention when it is not set
+// @Tags audit
+// @ID getAuditSetting
+// @Accept json
+// @Produce json
+// @Success 200 {object} entity.AuditSettingView
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /audit_settings [get]
+func (s *Server) getAuditSetting(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetAuditModel().GetSetting(ctx, op)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary update audit setting
+// @Description logs older than the retention are purged
+// @Tags audit
+// @ID updateAuditSetting
+// @Accept json
+// @Produce json
+// @Param req body entity.AuditSettingUpdateReq true "audit setting"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /audit_settings [put]
+func (s *Server) updateAuditSetting(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.AuditSettingUpdateReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "update audit setting: bind body failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	err := model.GetAuditModel().UpdateSetting(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(AssessMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/utils/kl2cache"
+)
+
+// @Summary get cache stats
+// @Description hit and miss counters of kl2cache on the instance which handles the request, since it started
+// @Tags admin
+// @ID getCacheStats
+// @Accept json
+// @Produce json
+// @Success 200 {object} kl2cache.Stats
+// @Failure 401 {object} UnAuthorizedResponse
+// @Router /admin/cache_stats [get]
+func (s *Server) getCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, kl2cache.GetStats())
+}
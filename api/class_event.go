@@ -50,6 +50,7 @@ func (s *Server) classAddMembersEvent(c *gin.Context) {
 
 	log.Debug(ctx, "class event", log.Any("event", event), log.String("token", body.Token))
 
+	// the event is the only write of the callback, it is in the outbox before the reply
 	err := model.GetClassEventBusModel().PubAddMembers(ctx, op, event)
 	if err != nil {
 		log.Error(ctx, "class add user event error",
@@ -101,6 +102,7 @@ func (s *Server) classDeleteMembersEvent(c *gin.Context) {
 		return
 	}
 
+	// the event is the only write of the callback, it is in the outbox before the reply
 	err := model.GetClassEventBusModel().PubDeleteMembers(ctx, op, event)
 	if err != nil {
 		log.Error(ctx, "class delete user event error",
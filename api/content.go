@@ -111,6 +111,7 @@ func (s *Server) createContent(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param contentIds body contentBulkOperateRequest true "content bulk id list"
+// @Param async query bool false "submit a job and return its id"
 // @Tags content
 // @Success 200 {object} string
 // @Failure 500 {object} InternalServerErrorResponse
@@ -136,6 +137,11 @@ func (s *Server) publishContentBulk(c *gin.Context) {
 		return
 	}
 
+	if isAsyncRequest(c) {
+		s.submitJob(c, entity.JobTypePublishContentBulk, &entity.ContentBulkJobParams{IDs: ids.ID})
+		return
+	}
+
 	err = model.GetContentModel().PublishContentBulkTx(ctx, ids.ID, op)
 	switch err {
 	case nil:
@@ -334,7 +340,9 @@ func (s *Server) updateContent(c *gin.Context) {
 		return
 	}
 
-	err = model.GetContentModel().UpdateContent(ctx, dbo.MustGetDB(ctx), cid, data, op)
+	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		return model.GetContentModel().UpdateContent(ctx, tx, cid, data, op)
+	})
 	switch err {
 	case model.ErrContentDataRequestSource:
 		c.JSON(http.StatusBadRequest, L(LibraryMsgContentDataInvalid))
@@ -440,6 +448,7 @@ func (s *Server) lockContent(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param contentIds body contentBulkOperateRequest true "content bulk id list"
+// @Param async query bool false "submit a job and return its id"
 // @Tags content
 // @Success 200 {object} string
 // @Failure 500 {object} InternalServerErrorResponse
@@ -466,6 +475,11 @@ func (s *Server) deleteContentBulk(c *gin.Context) {
 		return
 	}
 
+	if isAsyncRequest(c) {
+		s.submitJob(c, entity.JobTypeDeleteContentBulk, &entity.ContentBulkJobParams{IDs: ids.ID})
+		return
+	}
+
 	err = model.GetContentModel().DeleteContentBulkTx(ctx, ids.ID, op)
 
 	lockedByErr, ok := err.(*model.ErrContentAlreadyLocked)
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// directoryRecords json rows to records, values of any type are formatted as strings
+func directoryRecords(rows []map[string]interface{}) []map[string]string {
+	records := make([]map[string]string, len(rows))
+	for i, row := range rows {
+		records[i] = make(map[string]string, len(row))
+		for column, value := range row {
+			if value != nil {
+				records[i][column] = fmt.Sprint(value)
+			}
+		}
+	}
+	return records
+}
+
+// @Summary upsert directory rows
+// @Description upsert rows of the local directory, entities are replaced by id, existing relations are kept. Status is active by default.
+// @Tags admin
+// @ID upsertDirectory
+// @Accept json
+// @Produce json
+// @Param type path string true "directory type" enums(users,organizations,schools,classes,roles,programs,subjects,memberships,membership_roles,role_permissions,class_schools,class_members,program_subjects)
+// @Param rows body []object true "rows, keyed by the columns of the type"
+// @Success 200 {object} entity.DirectoryImportResult
+// @Failure 400 {object} BadRequestResponse
+// @Failure 401 {object} UnAuthorizedResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /admin/directory/{type} [post]
+func (s *Server) upsertDirectory(c *gin.Context) {
+	ctx := c.Request.Context()
+	var rows []map[string]interface{}
+	if err := c.ShouldBindJSON(&rows); err != nil {
+		log.Warn(ctx, "upsert directory: bind body failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetDirectoryModel().Upsert(ctx, entity.DirectoryType(c.Param("type")), directoryRecords(rows))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary import directory csv
+// @Description import a csv file into the local directory, the header names the columns of the type
+// @Tags admin
+// @ID importDirectory
+// @Accept mpfd
+// @Produce json
+// @Param type path string true "directory type" enums(users,organizations,schools,classes,roles,programs,subjects,memberships,membership_roles,role_permissions,class_schools,class_members,program_subjects)
+// @Param file formData file true "csv file"
+// @Success 200 {object} entity.DirectoryImportResult
+// @Failure 400 {object} BadRequestResponse
+// @Failure 401 {object} UnAuthorizedResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /admin/directory/{type}/import [post]
+func (s *Server) importDirectory(c *gin.Context) {
+	ctx := c.Request.Context()
+	header, err := c.FormFile("file")
+	if err != nil {
+		log.Warn(ctx, "import directory: get file failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		log.Warn(ctx, "import directory: open file failed", log.Err(err), log.String("filename", header.Filename))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+	defer file.Close()
+
+	result, err := model.GetDirectoryModel().Import(ctx, entity.DirectoryType(c.Param("type")), file)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary delete directory rows
+// @Description relations are deleted by all of their columns, entities are deactivated by id
+// @Tags admin
+// @ID deleteDirectory
+// @Accept json
+// @Produce json
+// @Param type path string true "directory type" enums(users,organizations,schools,classes,roles,programs,subjects,memberships,membership_roles,role_permissions,class_schools,class_members,program_subjects)
+// @Param rows body []object true "rows, keyed by the columns of the type"
+// @Success 200 {object} entity.DirectoryDeleteResult
+// @Failure 400 {object} BadRequestResponse
+// @Failure 401 {object} UnAuthorizedResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /admin/directory/{type} [delete]
+func (s *Server) deleteDirectory(c *gin.Context) {
+	ctx := c.Request.Context()
+	var rows []map[string]interface{}
+	if err := c.ShouldBindJSON(&rows); err != nil {
+		log.Warn(ctx, "delete directory: bind body failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	directoryType := entity.DirectoryType(c.Param("type"))
+	rowsAffected, err := model.GetDirectoryModel().Delete(ctx, directoryType, directoryRecords(rows))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, entity.DirectoryDeleteResult{Type: directoryType, Rows: rowsAffected})
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// @Summary query domain events
+// @Description query events in the outbox, handlers lists the handlers which already succeeded
+// @Tags admin
+// @ID queryDomainEvents
+// @Accept json
+// @Produce json
+// @Param topic query string false "topic"
+// @Param status query string false "status" enums(Pending,Dispatched,Succeeded,Dead)
+// @Param page query int false "page number" default(1)
+// @Param page_size query integer false "page size" format(int) default(10)
+// @Success 200 {object} entity.DomainEventPageReply
+// @Failure 400 {object} BadRequestResponse
+// @Failure 401 {object} UnAuthorizedResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /admin/domain_events [get]
+func (s *Server) queryDomainEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+	req := new(entity.DomainEventQueryReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "query domain events: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	if req.PageSize <= 0 || req.PageIndex <= 0 {
+		req.PageIndex = constant.DefaultPageIndex
+		req.PageSize = constant.DefaultPageSize
+	}
+
+	result, err := model.GetDomainEventBus().Query(ctx, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary replay domain event
+// @Description dispatch a dead or pending event again, handlers which already succeeded are skipped
+// @Tags admin
+// @ID replayDomainEvent
+// @Accept json
+// @Produce json
+// @Param id path string true "event id"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 401 {object} UnAuthorizedResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /admin/domain_events/{id}/replay [post]
+func (s *Server) replayDomainEvent(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	err := model.GetDomainEventBus().Replay(ctx, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
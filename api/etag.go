@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// etagResource a resource served with an ETag, its table has the revision column increased by the writes
+type etagResource struct {
+	table string
+	// updateAt the update time of the resource, constant.ErrRecordNotFound if it doesn't exist
+	updateAt func(ctx context.Context, id string) (int64, error)
+}
+
+// current the ETag of the current revision of the resource, and the revision
+func (r etagResource) current(ctx context.Context, id string) (string, int64, error) {
+	updateAt, err := r.updateAt(ctx, id)
+	if err != nil {
+		return "", 0, err
+	}
+	revision, err := da.GetRevisionDA().GetRevision(ctx, r.table, id)
+	if err != nil {
+		return "", 0, etagNotFound(err)
+	}
+	return entity.ETag(revision, updateAt), revision, nil
+}
+
+func etagNotFound(err error) error {
+	if err == dbo.ErrRecordNotFound {
+		return constant.ErrRecordNotFound
+	}
+	return err
+}
+
+var scheduleETag = etagResource{
+	table: constant.TableNameSchedule,
+	updateAt: func(ctx context.Context, id string) (int64, error) {
+		schedule, err := model.GetScheduleModel().GetPlainByID(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		return schedule.UpdatedAt, nil
+	},
+}
+
+var contentETag = etagResource{
+	table: entity.Content{}.TableName(),
+	updateAt: func(ctx context.Context, id string) (int64, error) {
+		content, err := da.GetContentDA().GetContentByID(ctx, dbo.MustGetDB(ctx), id)
+		if err != nil {
+			return 0, etagNotFound(err)
+		}
+		return content.UpdateAt, nil
+	},
+}
+
+var outcomeETag = etagResource{
+	table: entity.OutcomeTable,
+	updateAt: func(ctx context.Context, id string) (int64, error) {
+		outcome, err := da.GetOutcomeDA().GetOutcomeByID(ctx, dbo.MustGetDB(ctx), id)
+		if err != nil {
+			return 0, etagNotFound(err)
+		}
+		if outcome.DeleteAt > 0 {
+			return 0, constant.ErrRecordNotFound
+		}
+		return outcome.UpdateAt, nil
+	},
+}
+
+var milestoneETag = etagResource{
+	table: entity.MilestoneTable,
+	updateAt: func(ctx context.Context, id string) (int64, error) {
+		milestone, err := da.GetMilestoneDA().GetByID(ctx, dbo.MustGetDB(ctx), id)
+		if err != nil {
+			return 0, etagNotFound(err)
+		}
+		if milestone.DeleteAt > 0 {
+			return 0, constant.ErrRecordNotFound
+		}
+		return milestone.UpdateAt, nil
+	},
+}
+
+var folderETag = etagResource{
+	table: entity.FolderItem{}.TableName(),
+	updateAt: func(ctx context.Context, id string) (int64, error) {
+		folder, err := da.GetFolderDA().GetFolderByID(ctx, dbo.MustGetDB(ctx), id)
+		if err != nil {
+			return 0, etagNotFound(err)
+		}
+		return folder.UpdateAt, nil
+	},
+}
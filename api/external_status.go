@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+)
+
+// @Summary get external status
+// @Description circuit breakers of ams, data service and h5p on the instance which handles the request, counters are since it started
+// @Tags admin
+// @ID getExternalStatus
+// @Accept json
+// @Produce json
+// @Success 200 {array} resilience.Status
+// @Failure 401 {object} UnAuthorizedResponse
+// @Router /admin/external_status [get]
+func (s *Server) getExternalStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, external.GetExternalStatuses())
+}
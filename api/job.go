@@ -0,0 +1,158 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// asyncQueryKey bulk apis submit a job and return its id when async=true
+const asyncQueryKey = "async"
+
+func isAsyncRequest(c *gin.Context) bool {
+	return c.Query(asyncQueryKey) == "true"
+}
+
+// submitJob reply the job id, the job is polled from the jobs api
+func (s *Server) submitJob(c *gin.Context, jobType entity.JobType, params interface{}) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	id, err := model.GetJobModel().Submit(ctx, op, jobType, params)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, IDResponse{ID: id})
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary query jobs
+// @Description jobs submitted by the operator in the organization, latest first
+// @Tags job
+// @ID queryJobs
+// @Accept json
+// @Produce json
+// @Param type query string false "job type" enums(content.publish_bulk,content.delete_bulk,outcome.import,report.export)
+// @Param status query string false "status" enums(Pending,Running,Succeeded,Failed,Cancelled)
+// @Param page query int false "page number" default(1)
+// @Param page_size query integer false "page size" format(int) default(10)
+// @Success 200 {object} entity.JobPageReply
+// @Failure 400 {object} BadRequestResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /jobs [get]
+func (s *Server) queryJobs(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.JobQueryReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "query jobs: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	if req.PageSize <= 0 || req.PageIndex <= 0 {
+		req.PageIndex = constant.DefaultPageIndex
+		req.PageSize = constant.DefaultPageSize
+	}
+
+	result, err := model.GetJobModel().Query(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary get job
+// @Description poll the status, progress and result of a job
+// @Tags job
+// @ID getJob
+// @Accept json
+// @Produce json
+// @Param id path string true "job id"
+// @Success 200 {object} entity.JobView
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /jobs/{id} [get]
+func (s *Server) getJob(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetJobModel().Get(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary cancel job
+// @Description a pending job is cancelled at once, a running job stops when it reports progress next time
+// @Tags job
+// @ID cancelJob
+// @Accept json
+// @Produce json
+// @Param id path string true "job id"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /jobs/{id}/cancel [post]
+func (s *Server) cancelJob(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	err := model.GetJobModel().Cancel(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary download job file
+// @Description download the file produced by a succeeded job
+// @Tags job
+// @ID downloadJobFile
+// @Accept json
+// @Produce octet-stream
+// @Param id path string true "job id"
+// @Success 200 {file} file
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /jobs/{id}/file [get]
+func (s *Server) downloadJobFile(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	file, err := model.GetJobModel().GetFile(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", file.FileName))
+		c.Data(http.StatusOK, file.ContentType, file.Data)
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
@@ -1,8 +1,14 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
@@ -13,6 +19,9 @@ import (
 	"github.com/KL-Engineering/kidsloop-cms-service/config"
 	"github.com/KL-Engineering/kidsloop-cms-service/constant"
 	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
 	"github.com/KL-Engineering/kidsloop-cms-service/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
@@ -72,8 +81,55 @@ func (Server) mustDataService(c *gin.Context) {
 	}
 }
 
+// mustAdmin operation apis, not bound to any organization
+func (Server) mustAdmin(c *gin.Context) {
+	token := c.GetHeader("Authorization")
+	if token == "" {
+		log.Info(c.Request.Context(), "mustAdmin", log.String("session", "no authorization"))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, L(GeneralUnAuthorized))
+		return
+	}
+
+	prefix := "Bearer "
+	if strings.HasPrefix(token, prefix) {
+		token = token[len(prefix):]
+	}
+
+	key := config.Get().Admin.AuthorizedKey
+	if key == "" || subtle.ConstantTimeCompare([]byte(token), []byte(key)) != 1 {
+		log.Info(c.Request.Context(), "mustAdmin", log.String("session", "invalid authorization"))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, L(GeneralUnAuthorized))
+		return
+	}
+}
+
 const operatorKey = "_op_"
 
+// mustOrgAPIKey the operator of an api key has org id only, errors are replied in OneRoster format
+func (Server) mustOrgAPIKey(c *gin.Context) {
+	token := c.GetHeader("Authorization")
+	if token == "" {
+		log.Info(c.Request.Context(), "mustOrgAPIKey", log.String("session", "no authorization"))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, v2.NewOneRosterErrorReply(v2.OneRosterCodeMinorUnauthorisedRequest, "missing api key"))
+		return
+	}
+
+	prefix := "Bearer "
+	if strings.HasPrefix(token, prefix) {
+		token = token[len(prefix):]
+	}
+
+	op, err := model.GetOrganizationAPIKeyModel().Authenticate(c.Request.Context(), token)
+	switch err {
+	case nil:
+		c.Set(operatorKey, op)
+	case constant.ErrUnAuthorized:
+		c.AbortWithStatusJSON(http.StatusUnauthorized, v2.NewOneRosterErrorReply(v2.OneRosterCodeMinorUnauthorisedRequest, "invalid api key"))
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, v2.NewOneRosterErrorReply(v2.OneRosterCodeMinorServerBusy, "authenticate failed"))
+	}
+}
+
 func (Server) mustLogin(c *gin.Context) {
 	token, err := ExtractSession(c)
 	if err != nil {
@@ -203,6 +259,17 @@ func (s Server) logger() gin.HandlerFunc {
 			fields = append(fields, log.Any("durations", durations))
 		}
 
+		// log external calls batched by the loaders
+		if loaderStats, found := external.GetLoaderStats(c.Request.Context()); found && len(loaderStats) > 0 {
+			fields = append(fields, log.Any("loaders", loaderStats))
+			if txn := newrelic.FromContext(c.Request.Context()); txn != nil {
+				for name, stats := range loaderStats {
+					txn.AddAttribute("loader_"+name+"_calls", stats.Calls)
+					txn.AddAttribute("loader_"+name+"_batches", stats.Batches)
+				}
+			}
+		}
+
 		fn := log.Info
 		if duration > constant.FunctionExpirationLimit {
 			fn = log.Warn
@@ -267,6 +334,216 @@ func (s Server) contextStopwatch() gin.HandlerFunc {
 	}
 }
 
+// contextLoader BatchGet of external providers in one request are batched and deduped
+func (s Server) contextLoader() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := external.WithLoaders(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// audit record mutating requests of organization members, handlers only reading data are skipped
+func (s Server) audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		action := entity.AuditAction(c.HandlerName())
+		if entity.IsAuditReadOnlyAction(action) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		body := s.peekAuditBody(c)
+		ctx, recorder := model.WithAuditRecorder(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		op := s.getOperator(c)
+		if op.OrgID == "" {
+			return
+		}
+
+		targetID, before, after := recorder.Snapshots()
+		if targetID == "" {
+			targetID = auditTargetID(c)
+		}
+		if after == "" {
+			after = entity.RedactAuditBody(c.ContentType(), body)
+		}
+
+		// X-Forwarded-For is only used when the peer is one of the trusted proxies
+		ip := c.ClientIP()
+
+		auditLog := &entity.AuditLog{
+			OrgID:      op.OrgID,
+			ActorID:    op.UserID,
+			Action:     action,
+			TargetType: entity.AuditTargetType(c.FullPath()),
+			TargetID:   targetID,
+			Before:     before,
+			After:      after,
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			StatusCode: c.Writer.Status(),
+			IP:         ip,
+			UserAgent:  c.Request.UserAgent(),
+			Duration:   time.Since(start).Milliseconds(),
+		}
+		// the request may have been cancelled by the client, the log is saved anyway
+		model.GetAuditModel().Record(utils.CloneContextWithTrace(c.Request.Context()), auditLog)
+	}
+}
+
+// peekAuditBody read the head of the body for the audit log and put it back, uploads are not recorded
+func (s Server) peekAuditBody(c *gin.Context) string {
+	if c.Request.Body == nil || strings.HasPrefix(c.ContentType(), "multipart/") {
+		return ""
+	}
+
+	head, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, entity.AuditSnapshotLimit))
+	if err != nil {
+		log.Warn(c.Request.Context(), "read body for audit log failed", log.Err(err))
+	}
+	c.Request.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(head), c.Request.Body))
+
+	return string(head)
+}
+
+// auditTargetID id of the route, e.g. :id or :content_id
+func auditTargetID(c *gin.Context) string {
+	if id := c.Param("id"); id != "" {
+		return id
+	}
+	for _, param := range c.Params {
+		if strings.HasSuffix(param.Key, "_id") {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// idempotentWriter keeps a copy of the response for replays
+type idempotentWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotentWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotentWriter) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// idempotent a retry with the same Idempotency-Key header gets the response of the first request of the caller,
+// a retry while the first request is in progress gets 409. Requests without the header are handled as usual.
+// Server errors are not kept, the request can be retried with the same key.
+// The caller is the operator of a logged in request, callbacks without a login are identified by their signed body.
+func (s Server) idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(constant.IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		if len(key) > constant.IdempotencyKeyMaxLength {
+			log.Warn(ctx, "idempotency key too long", log.Int("length", len(key)))
+			c.AbortWithStatusJSON(http.StatusBadRequest, L(GeneralUnknown))
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(c.Request.Body)
+			if err != nil {
+				log.Warn(ctx, "read body for idempotency failed", log.Err(err))
+				c.AbortWithStatusJSON(http.StatusBadRequest, L(GeneralUnknown))
+				return
+			}
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		hash := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(hash[:])
+
+		scope := fmt.Sprintf("%s:%s:%s", c.Request.Method, c.FullPath(), s.idempotencyCaller(c, requestHash))
+		record, err := model.GetIdempotencyModel().Begin(ctx, scope, key, requestHash)
+		switch err {
+		case nil:
+		case model.ErrIdempotencyConflict:
+			c.AbortWithStatusJSON(http.StatusConflict, L(GeneralRequestInProgress))
+			return
+		case model.ErrIdempotencyMismatch:
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, L(GeneralIdempotencyReused))
+			return
+		default:
+			// creating is more important than deduplicating, the request is handled without the key
+			log.Warn(ctx, "begin idempotent request failed", log.Err(err), log.String("scope", scope))
+			c.Next()
+			return
+		}
+
+		if record != nil {
+			c.Header(constant.IdempotencyReplayedHeader, "true")
+			c.Data(record.StatusCode, record.ContentType, record.Body)
+			c.Abort()
+			return
+		}
+
+		writer := &idempotentWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			// the handler panicked or failed on the server
+			err := model.GetIdempotencyModel().Abort(utils.CloneContextWithTrace(ctx), scope, key)
+			if err != nil {
+				log.Warn(ctx, "abort idempotent request failed", log.Err(err), log.String("scope", scope))
+			}
+		}()
+
+		c.Next()
+
+		// throttled requests are retried with the same key
+		if writer.Status() >= http.StatusInternalServerError || writer.Status() == http.StatusTooManyRequests {
+			return
+		}
+		err = model.GetIdempotencyModel().Complete(utils.CloneContextWithTrace(ctx), scope, key, requestHash,
+			writer.Status(), writer.Header().Get("Content-Type"), writer.body.Bytes())
+		if err != nil {
+			log.Warn(ctx, "complete idempotent request failed", log.Err(err), log.String("scope", scope))
+			return
+		}
+		completed = true
+	}
+}
+
+// idempotencyCaller callbacks carry a token signed for the request in the body instead of a login,
+// a key without the same signed body can't replay their responses
+func (s Server) idempotencyCaller(c *gin.Context, requestHash string) string {
+	op := s.getOperator(c)
+	if op.UserID == "" {
+		return "signed:" + requestHash
+	}
+	return fmt.Sprintf("operator:%s:%s", op.OrgID, op.UserID)
+}
+
 func (s Server) getNewRelicMiddleware() gin.HandlerFunc {
 	nrCfg := &config.Get().NewRelic
 	nrApp, err := newrelic.NewApplication(
@@ -292,3 +569,190 @@ func (s Server) newRelicMiddlewareRectifier() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// etagWriter sets the ETag header of successful responses, the revision is loaded after the handler has written
+type etagWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	id       string
+	resource etagResource
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if code >= http.StatusOK && code < http.StatusMultipleChoices && w.Header().Get(constant.ETagHeader) == "" {
+		etag, _, err := w.resource.current(w.ctx, w.id)
+		if err == nil {
+			w.Header().Set(constant.ETagHeader, etag)
+		} else if err != constant.ErrRecordNotFound {
+			log.Warn(w.ctx, "load etag failed", log.Err(err), log.String("id", w.id))
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// etag successful responses of the resource identified by the path param carry its ETag,
+// writes with an If-Match header that doesn't match the current revision are rejected with 412 and the current ETag.
+// A matching write only succeeds while the revision is unchanged in its transaction, the loser of concurrent
+// writes with the same ETag gets constant.ErrPreconditionFailed. Requests without the header are handled as usual.
+func (s Server) etag(param string, resource etagResource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		id := c.Param(param)
+		if ifMatch := c.GetHeader(constant.IfMatchHeader); ifMatch != "" {
+			current, revision, err := resource.current(ctx, id)
+			switch err {
+			case nil:
+				if !entity.ETagMatches(ifMatch, current) {
+					log.Info(ctx, "precondition failed",
+						log.String("id", id),
+						log.String("if_match", ifMatch),
+						log.String("etag", current))
+					c.Header(constant.ETagHeader, current)
+					c.AbortWithStatusJSON(http.StatusPreconditionFailed, LD(GeneralPreconditionFailed, current))
+					return
+				}
+				ctx = model.WithExpectedRevision(ctx, resource.table, id, revision)
+				c.Request = c.Request.WithContext(ctx)
+			case constant.ErrRecordNotFound:
+				// the handler responds to missing resources as usual
+			default:
+				s.defaultErrorHandler(c, err)
+				c.Abort()
+				return
+			}
+		}
+
+		c.Writer = &etagWriter{ResponseWriter: c.Writer, ctx: ctx, id: id, resource: resource}
+		c.Next()
+	}
+}
+
+// preconditionFailed respond a write which lost to a concurrent one as a stale If-Match
+func (s Server) preconditionFailed(c *gin.Context) {
+	var current string
+	if w, ok := c.Writer.(*etagWriter); ok {
+		etag, _, err := w.resource.current(w.ctx, w.id)
+		if err == nil {
+			current = etag
+			c.Header(constant.ETagHeader, current)
+		}
+	}
+	c.JSON(http.StatusPreconditionFailed, LD(GeneralPreconditionFailed, current))
+}
+
+// reportExportWriter holds the json response of the report back until it has been rendered
+type reportExportWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *reportExportWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *reportExportWriter) WriteHeaderNow() {}
+
+func (w *reportExportWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *reportExportWriter) WriteString(data string) (int, error) {
+	return w.body.WriteString(data)
+}
+
+func (w *reportExportWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *reportExportWriter) Written() bool {
+	return w.status != 0 || w.body.Len() > 0
+}
+
+// reportExport a report requested with the format query is responded as a file of the format.
+// Requests with async=true, and reports with more rows than the sync limit, are exported by a job,
+// the job id is responded and the file is downloaded from the report exports api once the job succeeded.
+func (s Server) reportExport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := entity.ReportExportFormat(c.Query(reportExportFormatKey))
+		if format == "" {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		if !format.Valid() {
+			log.Warn(ctx, "invalid report export format", log.Any("format", format))
+			c.AbortWithStatusJSON(http.StatusBadRequest, L(GeneralUnknown))
+			return
+		}
+		if s.mustLogin(c); c.IsAborted() {
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(c.Request.Body)
+			if err != nil {
+				log.Warn(ctx, "read body for report export failed", log.Err(err))
+				c.AbortWithStatusJSON(http.StatusBadRequest, L(GeneralUnknown))
+				return
+			}
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		query := c.Request.URL.Query()
+		query.Del(reportExportFormatKey)
+		query.Del(asyncQueryKey)
+		params := &entity.ReportExportJobParams{
+			Method: c.Request.Method,
+			Path:   c.Request.URL.Path,
+			Query:  query.Encode(),
+			Body:   string(body),
+			Format: format,
+		}
+		if isAsyncRequest(c) {
+			s.submitJob(c, entity.JobTypeExportReport, params)
+			c.Abort()
+			return
+		}
+
+		writer := &reportExportWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		// the recovery middleware responds on the original writer when the handler panics
+		defer func() { c.Writer = writer.ResponseWriter }()
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.Status() != http.StatusOK {
+			c.Data(writer.Status(), writer.Header().Get("Content-Type"), writer.body.Bytes())
+			return
+		}
+
+		title, fileName := reportExportName(params.Path)
+		table, err := entity.NewReportTable(title, writer.body.Bytes())
+		if err != nil {
+			log.Error(ctx, "flatten report failed", log.Err(err), log.Any("params", params))
+			s.defaultErrorHandler(c, err)
+			return
+		}
+		if len(table.Rows) > constant.ReportExportSyncRowLimit {
+			log.Info(ctx, "report is exported by a job", log.Int("rows", len(table.Rows)), log.Any("params", params))
+			s.submitJob(c, entity.JobTypeExportReport, params)
+			return
+		}
+
+		file, err := model.GetReportExportModel().Render(ctx, format, fileName, table)
+		if err != nil {
+			s.defaultErrorHandler(c, err)
+			return
+		}
+		c.Header("Content-Type", file.ContentType)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", file.FileName))
+		c.Data(http.StatusOK, file.ContentType, file.Data)
+	}
+}
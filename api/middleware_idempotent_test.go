@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+func newIdempotentTestEngine(calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/idempotent_test", server.idempotent(), func(c *gin.Context) {
+		*calls++
+		c.JSON(http.StatusCreated, gin.H{"calls": *calls})
+	})
+	return engine
+}
+
+func doIdempotent(engine *gin.Engine, key string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/idempotent_test", strings.NewReader(body))
+	request.Header.Set(constant.IdempotencyKeyHeader, key)
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	calls := 0
+	engine := newIdempotentTestEngine(&calls)
+	key := utils.NewID()
+	body := `{"token":"signed"}`
+
+	first := doIdempotent(engine, key, body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first request: expected %d, got %d", http.StatusCreated, first.Code)
+	}
+
+	replay := doIdempotent(engine, key, body)
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Errorf("replay: expected %d %s, got %d %s", http.StatusCreated, first.Body.String(), replay.Code, replay.Body.String())
+	}
+	if replay.Header().Get(constant.IdempotencyReplayedHeader) != "true" {
+		t.Error("replay should be marked as replayed")
+	}
+	if calls != 1 {
+		t.Errorf("handler should run once, ran %d times", calls)
+	}
+
+	// callbacks without a login are scoped by their signed body, another body doesn't replay the response
+	other := doIdempotent(engine, key, `{"token":"other"}`)
+	if other.Code != http.StatusCreated || other.Header().Get(constant.IdempotencyReplayedHeader) != "" {
+		t.Errorf("another body should be handled, got %d", other.Code)
+	}
+	if calls != 2 {
+		t.Errorf("handler should run twice, ran %d times", calls)
+	}
+}
+
+func TestIdempotentConflict(t *testing.T) {
+	calls := 0
+	engine := newIdempotentTestEngine(&calls)
+	key := utils.NewID()
+	body := `{"token":"signed"}`
+
+	// the first request is in progress
+	hash := sha256.Sum256([]byte(body))
+	requestHash := hex.EncodeToString(hash[:])
+	scope := http.MethodPost + ":/idempotent_test:signed:" + requestHash
+	ctx := context.Background()
+	if _, err := model.GetIdempotencyModel().Begin(ctx, scope, key, requestHash); err != nil {
+		t.Fatal(err)
+	}
+
+	conflict := doIdempotent(engine, key, body)
+	if conflict.Code != http.StatusConflict {
+		t.Errorf("expected %d while the first request is in progress, got %d", http.StatusConflict, conflict.Code)
+	}
+	if calls != 0 {
+		t.Errorf("handler should not run, ran %d times", calls)
+	}
+
+	// an aborted request can be retried with the key
+	if err := model.GetIdempotencyModel().Abort(ctx, scope, key); err != nil {
+		t.Fatal(err)
+	}
+	retry := doIdempotent(engine, key, body)
+	if retry.Code != http.StatusCreated || calls != 1 {
+		t.Errorf("retry after abort: expected %d, got %d", http.StatusCreated, retry.Code)
+	}
+}
+
+func TestIdempotencyCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if caller := server.idempotencyCaller(c, "hash"); caller != "signed:hash" {
+		t.Errorf("callers without a login should be scoped by the body, got %s", caller)
+	}
+
+	c.Set(operatorKey, &entity.Operator{OrgID: "org", UserID: "user"})
+	if caller := server.idempotencyCaller(c, "hash"); caller != "operator:org:user" {
+		t.Errorf("logged in callers should be scoped by the operator, got %s", caller)
+	}
+}
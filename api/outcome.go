@@ -1062,6 +1062,7 @@ func (s *Server) verifyImportOutcomes(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param outcome body entity.ImportOutcomeRequest true "import outcome data"
+// @Param async query bool false "submit a job and return its id, the job result is the response"
 // @Success 200 {object} entity.VerifyImportOutcomeResponse
 // @Failure 400 {object} BadRequestResponse
 // @Failure 403 {object} ForbiddenResponse
@@ -1101,6 +1102,11 @@ func (s *Server) importOutcomes(c *gin.Context) {
 		return
 	}
 
+	if isAsyncRequest(c) {
+		s.submitJob(c, entity.JobTypeImportOutcomes, &req)
+		return
+	}
+
 	result, err := model.GetOutcomeModel().Import(ctx, op, &req)
 	switch err {
 	case model.ErrBadRequest:
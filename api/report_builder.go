@@ -0,0 +1,258 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// @Summary get report builder metadata
+// @Description dimensions, metrics and time grains of report builder queries
+// @Tags reports/builder
+// @ID getReportBuilderMetadata
+// @Accept json
+// @Produce json
+// @Success 200 {object} entity.ReportBuilderMetadata
+// @Router /reports/builder/metadata [get]
+func (s *Server) getReportBuilderMetadata(c *gin.Context) {
+	c.JSON(http.StatusOK, model.GetReportBuilderModel().Metadata(c.Request.Context()))
+}
+
+// @Summary query report builder
+// @Description group the metrics by the dimensions over the classes and teachers the operator can view reports of
+// @Tags reports/builder
+// @ID queryReportBuilder
+// @Accept json
+// @Produce json
+// @Param req body entity.ReportBuilderQuery true "report builder query"
+// @Success 200 {object} entity.ReportBuilderResult
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /reports/builder/query [post]
+func (s *Server) queryReportBuilder(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.ReportBuilderQuery)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "query report builder: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetReportBuilderModel().Query(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary run report definition
+// @Description run a saved report definition with the permissions of the operator
+// @Tags reports/builder
+// @ID runReportDefinition
+// @Accept json
+// @Produce json
+// @Param id path string true "report definition id"
+// @Success 200 {object} entity.ReportBuilderResult
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /reports/builder/definitions/{id}/result [get]
+func (s *Server) runReportDefinition(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetReportBuilderModel().Run(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary add report definition
+// @Description save a report builder query, shared definitions are visible to the whole organization
+// @Tags reportDefinition
+// @ID addReportDefinition
+// @Accept json
+// @Produce json
+// @Param req body entity.ReportDefinitionAddReq true "add report definition args"
+// @Success 200 {object} entity.ReportDefinitionView
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_definitions [post]
+func (s *Server) addReportDefinition(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.ReportDefinitionAddReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "add report definition: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetReportBuilderModel().AddDefinition(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary query report definitions
+// @Description report definitions of the operator and definitions shared in the organization
+// @Tags reportDefinition
+// @ID queryReportDefinitions
+// @Accept json
+// @Produce json
+// @Param name query string false "search by name"
+// @Param page_index query integer false "page index"
+// @Param page_size query integer false "page size"
+// @Success 200 {object} entity.ReportDefinitionPageReply
+// @Failure 400 {object} BadRequestResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_definitions [get]
+func (s *Server) queryReportDefinitions(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.ReportDefinitionQueryReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "query report definitions: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	if req.PageSize <= 0 || req.PageIndex <= 0 {
+		req.PageIndex = constant.DefaultPageIndex
+		req.PageSize = constant.DefaultPageSize
+	}
+
+	result, err := model.GetReportBuilderModel().QueryDefinitions(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary get report definition
+// @Description get a report definition of the operator or shared in the organization
+// @Tags reportDefinition
+// @ID getReportDefinition
+// @Accept json
+// @Produce json
+// @Param id path string true "report definition id"
+// @Success 200 {object} entity.ReportDefinitionView
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_definitions/{id} [get]
+func (s *Server) getReportDefinition(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetReportBuilderModel().GetDefinition(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary update report definition
+// @Description only the creator updates a report definition
+// @Tags reportDefinition
+// @ID updateReportDefinition
+// @Accept json
+// @Produce json
+// @Param id path string true "report definition id"
+// @Param req body entity.ReportDefinitionAddReq true "update report definition args"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_definitions/{id} [put]
+func (s *Server) updateReportDefinition(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.ReportDefinitionUpdateReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "update report definition: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+	req.ID = c.Param("id")
+
+	err := model.GetReportBuilderModel().UpdateDefinition(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary delete report definition
+// @Description only the creator deletes a report definition
+// @Tags reportDefinition
+// @ID deleteReportDefinition
+// @Accept json
+// @Produce json
+// @Param id path string true "report definition id"
+// @Success 200 {string} string "OK"
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_definitions/{id} [delete]
+func (s *Server) deleteReportDefinition(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	err := model.GetReportBuilderModel().DeleteDefinition(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// reportExportFormatKey any report api responds a file of the format, csv, xlsx or pdf
+const reportExportFormatKey = "format"
+
+// reportExportName title of the report and file name without extension by the path of the report api
+func reportExportName(path string) (string, string) {
+	title := strings.TrimPrefix(strings.TrimPrefix(path, "/v1"), "/reports/")
+	fileName := fmt.Sprintf("report_%s_%s", strings.ReplaceAll(title, "/", "_"), time.Now().UTC().Format("20060102"))
+	return title, fileName
+}
+
+// reportExportRecorder response of a report request replayed by the export job
+type reportExportRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *reportExportRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *reportExportRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(data)
+}
+
+func (r *reportExportRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+// exportReportJob replay the report request with the submitter's token, then render and upload the file
+func (s *Server) exportReportJob(ctx context.Context, op *entity.Operator, params []byte, reporter model.IJobReporter) (*model.JobOutput, error) {
+	req := new(entity.ReportExportJobParams)
+	if err := json.Unmarshal(params, req); err != nil || !req.Format.Valid() || !strings.HasPrefix(req.Path, "/v1/reports/") {
+		log.Warn(ctx, "export report job: params invalid", log.Err(err), log.String("params", string(params)))
+		return nil, &model.JobPermanentError{Err: model.ErrBadRequest}
+	}
+
+	if err := reporter.Progress(ctx, 0, 3); err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, req.Method, req.Path+"?"+req.Query, strings.NewReader(req.Body))
+	if err != nil {
+		return nil, &model.JobPermanentError{Err: err}
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.AddCookie(&http.Cookie{Name: "access", Value: op.Token})
+
+	recorder := &reportExportRecorder{header: make(http.Header)}
+	s.engine.ServeHTTP(recorder, request)
+	switch {
+	case recorder.status == http.StatusOK:
+	case recorder.status >= http.StatusBadRequest && recorder.status < http.StatusInternalServerError:
+		// the request is rejected again when retried, e.g. the token has expired
+		log.Warn(ctx, "export report job: report rejected", log.Int("status", recorder.status), log.String("body", recorder.body.String()))
+		return nil, &model.JobPermanentError{Err: fmt.Errorf("report responded %d: %s", recorder.status, recorder.body.String())}
+	default:
+		return nil, fmt.Errorf("report responded %d", recorder.status)
+	}
+
+	if err := reporter.Progress(ctx, 1, 3); err != nil {
+		return nil, err
+	}
+
+	title, fileName := reportExportName(req.Path)
+	table, err := entity.NewReportTable(title, recorder.body.Bytes())
+	if err != nil {
+		log.Error(ctx, "export report job: flatten report failed", log.Err(err), log.Any("req", req))
+		return nil, &model.JobPermanentError{Err: err}
+	}
+	file, err := model.GetReportExportModel().Render(ctx, req.Format, fileName, table)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := reporter.Progress(ctx, 2, 3); err != nil {
+		return nil, err
+	}
+
+	result, err := model.GetReportExportModel().Save(ctx, op, file, len(table.Rows))
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.JobOutput{
+		Result: result,
+	}, nil
+}
+
+// @Summary get report export
+// @Description temporary download link of a report exported by a job, any report api submits the job with format and async=true
+// @Tags reports
+// @ID getReportExport
+// @Accept json
+// @Produce json
+// @Param id path string true "job id"
+// @Success 200 {object} entity.ReportExportDownload
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /reports/exports/{id} [get]
+func (s *Server) getReportExport(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetReportExportModel().GetDownload(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
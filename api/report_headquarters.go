@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// @Summary get headquarters comparison report
+// @Description compare the child organizations of the headquarters or their schools by a metric, with ranking, percentile bands and weekly trends
+// @Tags reports/headquarters
+// @ID getHeadquartersReport
+// @Accept json
+// @Produce json
+// @Param metric query string true "metric" enums(attendance,assignment_completion,outcome_achievement,content_usage,teacher_load)
+// @Param level query string false "level, organization by default" enums(organization,school)
+// @Param child_org_id query string false "compare the schools of one child organization"
+// @Param start_at query integer true "start time"
+// @Param end_at query integer true "end time"
+// @Success 200 {object} entity.HeadquartersReportResponse
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /reports/headquarters [get]
+func (s *Server) getHeadquartersReport(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.HeadquartersReportRequest)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "get headquarters report: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetHeadquartersReportModel().Query(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// @Summary get insight rules
+// @Description rules of the app insight messages, the default rules when the organization has none
+// @Tags insightRule
+// @ID getInsightRules
+// @Accept json
+// @Produce json
+// @Success 200 {object} entity.InsightRuleSetView
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /insight_rules [get]
+func (s *Server) getInsightRules(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetInsightRuleModel().GetRules(ctx, op)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary update insight rules
+// @Description replace the rules of the organization, the enabled rule of the highest priority fires in its category
+// @Tags insightRule
+// @ID updateInsightRules
+// @Accept json
+// @Produce json
+// @Param req body entity.InsightRuleSetUpdateReq true "insight rules"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /insight_rules [put]
+func (s *Server) updateInsightRules(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.InsightRuleSetUpdateReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "update insight rules: bind body failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	err := model.GetInsightRuleModel().UpdateRules(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary reset insight rules
+// @Description use the default rules again
+// @Tags insightRule
+// @ID resetInsightRules
+// @Accept json
+// @Produce json
+// @Success 200 {string} string "OK"
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /insight_rules [delete]
+func (s *Server) resetInsightRules(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	err := model.GetInsightRuleModel().ResetRules(ctx, op)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary preview insight rules
+// @Description fire the rules against a student, unsaved rules in the body are tried instead of the saved ones
+// @Tags insightRule
+// @ID previewInsightRules
+// @Accept json
+// @Produce json
+// @Param req body entity.InsightRulePreviewReq true "student and rules"
+// @Success 200 {object} entity.InsightRulePreviewReply
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /insight_rules/preview [post]
+func (s *Server) previewInsightRules(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.InsightRulePreviewReq)
+	if err := c.ShouldBindJSON(req); err != nil || req.ClassID == "" || req.StudentID == "" {
+		log.Warn(ctx, "preview insight rules: bind body failed", log.Err(err), log.Any("req", req))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	if err := s.checkPermissionForReportStudentProgress(ctx, op, req.ClassID, req.StudentID); err != nil {
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+		return
+	}
+
+	result, err := model.GetInsightRuleModel().Preview(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary query insight rule stats
+// @Description times every rule fired in the organization, previews are not counted
+// @Tags insightRule
+// @ID queryInsightRuleStats
+// @Accept json
+// @Produce json
+// @Param start_at query integer false "fired at or after, unix seconds"
+// @Param end_at query integer false "fired before, unix seconds"
+// @Success 200 {array} entity.InsightRuleStat
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /insight_rules/stats [get]
+func (s *Server) queryInsightRuleStats(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.InsightRuleStatsReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "query insight rule stats: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetInsightRuleModel().QueryStats(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
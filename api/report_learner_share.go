@@ -0,0 +1,336 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// @Summary get learner report
+// @Description classes attended, assignments done, outcomes achieved and teacher comments of a student in a class, worded for parents
+// @Tags reports/learner
+// @ID getLearnerReport
+// @Accept json
+// @Produce json
+// @Param class_id query string true "class id"
+// @Param student_id query string true "student id"
+// @Param start_at query integer true "start time"
+// @Param end_at query integer true "end time"
+// @Success 200 {object} entity.LearnerReport
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /reports/learner_report [get]
+func (s *Server) getLearnerReport(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.LearnerReportRequest)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "get learner report: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetLearnerReportModel().Get(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary get learner report pdf
+// @Description the learner report printed as a pdf
+// @Tags reports/learner
+// @ID getLearnerReportPdf
+// @Produce application/pdf
+// @Param class_id query string true "class id"
+// @Param student_id query string true "student id"
+// @Param start_at query integer true "start time"
+// @Param end_at query integer true "end time"
+// @Success 200 {file} file "pdf"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /reports/learner_report/pdf [get]
+func (s *Server) getLearnerReportPdf(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.LearnerReportRequest)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "get learner report pdf: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	report, err := model.GetLearnerReportModel().Get(ctx, op, req)
+	var file *entity.JobFile
+	if err == nil {
+		file, err = model.GetLearnerReportModel().Pdf(ctx, report)
+	}
+	switch err {
+	case nil:
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", file.FileName))
+		c.Data(http.StatusOK, file.ContentType, file.Data)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary add learner report share link
+// @Description a signed link of the learner report which parents open without login until it expires or is revoked
+// @Tags learnerReportLink
+// @ID addLearnerReportShareLink
+// @Accept json
+// @Produce json
+// @Param req body entity.LearnerReportShareLinkAddReq true "add learner report share link args"
+// @Success 200 {object} entity.LearnerReportShareLinkView
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /learner_report_links [post]
+func (s *Server) addLearnerReportShareLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.LearnerReportShareLinkAddReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "add learner report share link: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetLearnerReportModel().AddShareLink(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary query learner report share links
+// @Description share links of a student in a class
+// @Tags learnerReportLink
+// @ID queryLearnerReportShareLinks
+// @Accept json
+// @Produce json
+// @Param class_id query string true "class id"
+// @Param student_id query string true "student id"
+// @Param page_index query integer false "page index"
+// @Param page_size query integer false "page size"
+// @Success 200 {object} entity.LearnerReportShareLinkPageReply
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /learner_report_links [get]
+func (s *Server) queryLearnerReportShareLinks(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.LearnerReportShareLinkQueryReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "query learner report share links: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	if req.PageSize <= 0 || req.PageIndex <= 0 {
+		req.PageIndex = constant.DefaultPageIndex
+		req.PageSize = constant.DefaultPageSize
+	}
+
+	result, err := model.GetLearnerReportModel().QueryShareLinks(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary revoke learner report share link
+// @Description the link stops working at once
+// @Tags learnerReportLink
+// @ID revokeLearnerReportShareLink
+// @Accept json
+// @Produce json
+// @Param id path string true "learner report share link id"
+// @Success 200 {string} string "OK"
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /learner_report_links/{id}/revoke [post]
+func (s *Server) revokeLearnerReportShareLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	err := model.GetLearnerReportModel().RevokeShareLink(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary view learner report share link
+// @Description the learner report of a signed share link as a page, no login is required
+// @Tags learnerReportLink
+// @ID viewLearnerReportShareLink
+// @Produce html
+// @Param id path string true "learner report share link id"
+// @Param expire_at query integer true "expire time"
+// @Param sig query string true "signature"
+// @Success 200 {string} string "html"
+// @Failure 404 {string} string "html"
+// @Failure 410 {string} string "html"
+// @Failure 429 {string} string "html"
+// @Failure 500 {string} string "html"
+// @Router /learner_report_links/{id} [get]
+func (s *Server) viewLearnerReportShareLink(c *gin.Context) {
+	report, ok := s.viewSharedLearnerReport(c)
+	if !ok {
+		return
+	}
+
+	query := url.Values{}
+	query.Set("expire_at", c.Query("expire_at"))
+	query.Set("sig", c.Query("sig"))
+	pdfURL := fmt.Sprintf("%s/pdf?%s", c.Request.URL.Path, query.Encode())
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(learnerReportPage(report, pdfURL)))
+}
+
+// @Summary download learner report share link pdf
+// @Description the learner report of a signed share link as a pdf, no login is required
+// @Tags learnerReportLink
+// @ID downloadLearnerReportShareLinkPdf
+// @Produce application/pdf
+// @Param id path string true "learner report share link id"
+// @Param expire_at query integer true "expire time"
+// @Param sig query string true "signature"
+// @Success 200 {file} file "pdf"
+// @Failure 404 {string} string "html"
+// @Failure 410 {string} string "html"
+// @Failure 429 {string} string "html"
+// @Failure 500 {string} string "html"
+// @Router /learner_report_links/{id}/pdf [get]
+func (s *Server) downloadLearnerReportShareLinkPdf(c *gin.Context) {
+	ctx := c.Request.Context()
+	report, ok := s.viewSharedLearnerReport(c)
+	if !ok {
+		return
+	}
+
+	file, err := model.GetLearnerReportModel().Pdf(ctx, report)
+	if err != nil {
+		log.Error(ctx, "render learner report pdf failed", log.Err(err), log.String("id", c.Param("id")))
+		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8", []byte("<p>Please try again later.</p>"))
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", file.FileName))
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
+
+// viewSharedLearnerReport writes the error page when the link can not be viewed
+func (s *Server) viewSharedLearnerReport(c *gin.Context) (*entity.LearnerReport, bool) {
+	ctx := c.Request.Context()
+	// X-Forwarded-For is only used when the peer is one of the trusted proxies, clients can't pick their limit key
+	visit := &entity.LearnerReportShareVisit{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Method:    c.Request.Method,
+		Path:      c.FullPath(),
+	}
+
+	expireAt, err := strconv.ParseInt(c.Query("expire_at"), 10, 64)
+	if err != nil {
+		c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte("<p>Link not found.</p>"))
+		return nil, false
+	}
+
+	report, err := model.GetLearnerReportModel().ViewSharedReport(ctx, c.Param("id"), expireAt, c.Query("sig"), visit)
+	switch err {
+	case nil:
+		return report, true
+	case constant.ErrRecordNotFound, constant.ErrForbidden:
+		c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte("<p>Link not found.</p>"))
+	case constant.ErrOutOfDate:
+		c.Data(http.StatusGone, "text/html; charset=utf-8", []byte("<p>This link has expired, please ask the teacher for a new one.</p>"))
+	case constant.ErrExceededLimit:
+		c.Data(http.StatusTooManyRequests, "text/html; charset=utf-8", []byte("<p>Too many visits, please try again in a minute.</p>"))
+	default:
+		log.Error(ctx, "view learner report share link failed", log.Err(err), log.String("id", c.Param("id")))
+		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8", []byte("<p>Please try again later.</p>"))
+	}
+	return nil, false
+}
+
+func learnerReportPage(report *entity.LearnerReport, pdfURL string) string {
+	zone := time.FixedZone("", config.Get().ReportRollup.TimeZoneOffset)
+	date := func(ts int64) string {
+		return time.Unix(ts, 0).In(zone).Format("2006-01-02")
+	}
+
+	page := new(strings.Builder)
+	page.WriteString("<!DOCTYPE html><html><head><meta charset=\"utf-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">")
+	fmt.Fprintf(page, "<title>Learning report of %s</title></head><body>", html.EscapeString(report.StudentName))
+	fmt.Fprintf(page, "<h2>Learning report of %s</h2>", html.EscapeString(report.StudentName))
+	fmt.Fprintf(page, "<p>%s, %s - %s</p>", html.EscapeString(report.ClassName), date(report.StartAt), date(report.EndAt-1))
+
+	page.WriteString("<ul>")
+	for _, highlight := range report.Highlights {
+		fmt.Fprintf(page, "<li>%s</li>", html.EscapeString(highlight))
+	}
+	page.WriteString("</ul>")
+
+	if len(report.AchievedOutcomes) > 0 {
+		page.WriteString("<h3>Learning outcomes achieved</h3><ul>")
+		for _, name := range report.AchievedOutcomes {
+			fmt.Fprintf(page, "<li>%s</li>", html.EscapeString(name))
+		}
+		page.WriteString("</ul>")
+	}
+
+	if len(report.Comments) > 0 {
+		page.WriteString("<h3>Comments from teachers</h3>")
+		for _, comment := range report.Comments {
+			fmt.Fprintf(page, "<blockquote><p>%s</p><footer>%s, %s, %s</footer></blockquote>",
+				html.EscapeString(comment.Comment), html.EscapeString(comment.TeacherName), html.EscapeString(comment.Title), date(comment.CreateAt))
+		}
+	}
+
+	fmt.Fprintf(page, "<p><a href=\"%s\">Download as PDF</a></p></body></html>", html.EscapeString(pdfURL))
+	return page.String()
+}
@@ -10,6 +10,7 @@ import (
 )
 
 // @Summary  getAppInsightMessage
+// @Description messages of the insight rules of the organization, the label ids are kept for the app
 // @Tags reports/studentProgress
 // @ID getAppInsightMessage
 // @Accept json
@@ -18,6 +19,7 @@ import (
 // @Param student_id query string true "student_id"
 // @Param org_id query string true "org_id"
 // @Param end_time query int true "end_time" default(0)
+// @Param lang query string false "language of the messages" default(en)
 // @Success 200 {object} entity.AppInsightMessageResponse
 // @Failure 400 {object} BadRequestResponse
 // @Failure 403 {object} ForbiddenResponse
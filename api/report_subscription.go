@@ -0,0 +1,320 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// @Summary add report subscription
+// @Description the report runs as the operator with their permissions and is mailed to the recipients on the cadence
+// @Tags reportSubscription
+// @ID addReportSubscription
+// @Accept json
+// @Produce json
+// @Param req body entity.ReportSubscriptionAddReq true "add report subscription args"
+// @Success 200 {object} entity.ReportSubscriptionView
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_subscriptions [post]
+func (s *Server) addReportSubscription(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.ReportSubscriptionAddReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "add report subscription: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetReportSubscriptionModel().Add(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary query report subscriptions
+// @Description report subscriptions created by the operator
+// @Tags reportSubscription
+// @ID queryReportSubscriptions
+// @Accept json
+// @Produce json
+// @Success 200 {array} entity.ReportSubscriptionView
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_subscriptions [get]
+func (s *Server) queryReportSubscriptions(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetReportSubscriptionModel().Query(ctx, op)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary get report subscription
+// @Description get a report subscription created by the operator
+// @Tags reportSubscription
+// @ID getReportSubscription
+// @Accept json
+// @Produce json
+// @Param id path string true "report subscription id"
+// @Success 200 {object} entity.ReportSubscriptionView
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_subscriptions/{id} [get]
+func (s *Server) getReportSubscription(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	result, err := model.GetReportSubscriptionModel().GetByID(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary update report subscription
+// @Description update the report, recipients or cadence, the next run is scheduled again
+// @Tags reportSubscription
+// @ID updateReportSubscription
+// @Accept json
+// @Produce json
+// @Param id path string true "report subscription id"
+// @Param req body entity.ReportSubscriptionAddReq true "update report subscription args"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_subscriptions/{id} [put]
+func (s *Server) updateReportSubscription(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.ReportSubscriptionUpdateReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "update report subscription: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+	req.ID = c.Param("id")
+
+	err := model.GetReportSubscriptionModel().Update(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary delete report subscription
+// @Description delete a report subscription, the delivery history is kept
+// @Tags reportSubscription
+// @ID deleteReportSubscription
+// @Accept json
+// @Produce json
+// @Param id path string true "report subscription id"
+// @Success 200 {string} string "OK"
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_subscriptions/{id} [delete]
+func (s *Server) deleteReportSubscription(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	err := model.GetReportSubscriptionModel().Delete(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary pause report subscription
+// @Description stop mailing the report until it is resumed
+// @Tags reportSubscription
+// @ID pauseReportSubscription
+// @Accept json
+// @Produce json
+// @Param id path string true "report subscription id"
+// @Success 200 {string} string "OK"
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_subscriptions/{id}/pause [post]
+func (s *Server) pauseReportSubscription(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	err := model.GetReportSubscriptionModel().Pause(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary resume report subscription
+// @Description the next run is scheduled from now, runs missed while paused are not delivered
+// @Tags reportSubscription
+// @ID resumeReportSubscription
+// @Accept json
+// @Produce json
+// @Param id path string true "report subscription id"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} BadRequestResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_subscriptions/{id}/resume [post]
+func (s *Server) resumeReportSubscription(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+
+	err := model.GetReportSubscriptionModel().Resume(ctx, op, c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary query report subscription deliveries
+// @Description delivery history of a report subscription, latest first
+// @Tags reportSubscription
+// @ID queryReportSubscriptionDeliveries
+// @Accept json
+// @Produce json
+// @Param id path string true "report subscription id"
+// @Param page query int false "page number" default(1)
+// @Param page_size query integer false "page size" format(int) default(10)
+// @Success 200 {object} entity.ReportSubscriptionDeliveryPageReply
+// @Failure 400 {object} BadRequestResponse
+// @Failure 404 {object} NotFoundResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /report_subscriptions/{id}/deliveries [get]
+func (s *Server) queryReportSubscriptionDeliveries(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.ReportSubscriptionDeliveryQueryReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "query report subscription deliveries: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+	req.SubscriptionID = c.Param("id")
+
+	if req.PageSize <= 0 || req.PageIndex <= 0 {
+		req.PageIndex = constant.DefaultPageIndex
+		req.PageSize = constant.DefaultPageSize
+	}
+
+	result, err := model.GetReportSubscriptionModel().QueryDeliveries(ctx, op, req)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary confirm report subscription link
+// @Description page of a pause or unsubscribe link in a report mail, the form posts back to the link so mail scanners opening it change nothing
+// @Tags reportSubscription
+// @ID confirmReportSubscriptionLink
+// @Produce html
+// @Param id path string true "report subscription id"
+// @Param action path string true "action" enums(pause,unsubscribe)
+// @Param recipient query string true "recipient email"
+// @Param sig query string true "signature"
+// @Success 200 {string} string "html"
+// @Failure 404 {string} string "html"
+// @Router /report_subscriptions_links/{id}/{action} [get]
+func (s *Server) confirmReportSubscriptionLink(c *gin.Context) {
+	action := entity.ReportSubscriptionLinkAction(c.Param("action"))
+	if !action.Valid() {
+		c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte("<p>Link not found.</p>"))
+		return
+	}
+
+	label := "Pause this report for every recipient"
+	if action == entity.ReportSubscriptionLinkUnsubscribe {
+		label = fmt.Sprintf("Unsubscribe %s from this report", c.Query("recipient"))
+	}
+	// an empty action posts to the url of the page with its query
+	page := fmt.Sprintf("<form method=\"post\" action=\"\"><button type=\"submit\">%s</button></form>", html.EscapeString(label))
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+}
+
+// @Summary handle report subscription link
+// @Description pause the subscription or unsubscribe the recipient by a signed link of a report mail, no login is required
+// @Tags reportSubscription
+// @ID handleReportSubscriptionLink
+// @Produce html
+// @Param id path string true "report subscription id"
+// @Param action path string true "action" enums(pause,unsubscribe)
+// @Param recipient query string true "recipient email"
+// @Param sig query string true "signature"
+// @Success 200 {string} string "html"
+// @Failure 404 {string} string "html"
+// @Failure 500 {string} string "html"
+// @Router /report_subscriptions_links/{id}/{action} [post]
+func (s *Server) handleReportSubscriptionLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	action := entity.ReportSubscriptionLinkAction(c.Param("action"))
+
+	err := model.GetReportSubscriptionModel().HandleLink(ctx, c.Param("id"), c.Query("recipient"), action, c.Query("sig"))
+	switch err {
+	case nil:
+		message := "The report is paused."
+		if action == entity.ReportSubscriptionLinkUnsubscribe {
+			message = "You are unsubscribed from the report."
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte("<p>"+message+"</p>"))
+	case constant.ErrRecordNotFound:
+		c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte("<p>Link not found.</p>"))
+	default:
+		log.Error(ctx, "handle report subscription link failed", log.Err(err), log.String("id", c.Param("id")))
+		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8", []byte("<p>Please try again later.</p>"))
+	}
+}
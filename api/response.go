@@ -21,6 +21,9 @@ const (
 	GeneralUnAuthorized        ResponseLabel = "general_error_unauthorized"
 	GeneralUnAuthorizedNoOrgID ResponseLabel = "general_error_no_organization"
 	GeneralNoPermission        ResponseLabel = "general_error_no_permission"
+	GeneralRequestInProgress   ResponseLabel = "general_error_request_in_progress"
+	GeneralIdempotencyReused   ResponseLabel = "general_error_idempotency_key_reused"
+	GeneralPreconditionFailed  ResponseLabel = "general_error_precondition_failed"
 
 	// Assessment
 	AssessMsgOneStudent   ResponseLabel = "assess_msg_one_student"
@@ -116,6 +119,10 @@ type IDResponse struct {
 }
 
 func (s Server) defaultErrorHandler(c *gin.Context, err error) {
+	if err == constant.ErrPreconditionFailed {
+		s.preconditionFailed(c)
+		return
+	}
 	eType, ok := err.(entity.TypedError)
 	if ok && config.Get().ShowInternalErrorType {
 		c.Header(constant.ResponseHeaderKeyInternalErrorType, eType.ErrorType())
@@ -6,6 +6,7 @@ import (
 
 	"github.com/KL-Engineering/kidsloop-cms-service/config"
 	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
 
 	"github.com/gin-gonic/gin"
 )
@@ -37,14 +38,14 @@ func (s Server) registeRoute() {
 
 	content := s.engine.Group("/v1")
 	{
-		content.POST("/contents", s.mustLogin, s.createContent)
+		content.POST("/contents", s.mustLogin, s.idempotent(), s.createContent)
 
 		//Inherent & unchangeable
-		content.GET("/contents/:content_id", s.mustLogin, s.getContent)
+		content.GET("/contents/:content_id", s.mustLogin, s.etag("content_id", contentETag), s.getContent)
 		//Inherent & unchangeable
 		content.GET("/contents", s.mustLogin, s.queryContent)
 
-		content.PUT("/contents/:content_id", s.mustLogin, s.updateContent)
+		content.PUT("/contents/:content_id", s.mustLogin, s.etag("content_id", contentETag), s.updateContent)
 		content.PUT("/contents/:content_id/lock", s.mustLogin, s.lockContent)
 		content.PUT("/contents/:content_id/publish", s.mustLogin, s.publishContent)
 		content.PUT("/contents/:content_id/publish/assets", s.mustLogin, s.publishContentWithAssets)
@@ -53,7 +54,7 @@ func (s Server) registeRoute() {
 		content.PUT("/contents_review/approve", s.mustLogin, s.approveBulk)
 		content.PUT("/contents_review/reject", s.mustLogin, s.rejectBulk)
 
-		content.DELETE("/contents/:content_id", s.mustLogin, s.deleteContent)
+		content.DELETE("/contents/:content_id", s.mustLogin, s.etag("content_id", contentETag), s.deleteContent)
 		content.GET("/contents/:content_id/statistics", s.mustLogin, s.contentDataCount)
 		content.GET("/contents_private", s.mustLogin, s.queryPrivateContent)
 		content.GET("/contents_pending", s.mustLogin, s.queryPendingContent)
@@ -75,10 +76,10 @@ func (s Server) registeRoute() {
 
 	schedules := s.engine.Group("/v1")
 	{
-		schedules.PUT("/schedules/:id", s.mustLogin, s.updateSchedule)
-		schedules.DELETE("/schedules/:id", s.mustLogin, s.deleteSchedule)
-		schedules.POST("/schedules", s.mustLogin, s.addSchedule)
-		schedules.GET("/schedules/:id", s.mustLogin, s.getScheduleByID)
+		schedules.PUT("/schedules/:id", s.mustLogin, s.etag("id", scheduleETag), s.updateSchedule)
+		schedules.DELETE("/schedules/:id", s.mustLogin, s.etag("id", scheduleETag), s.deleteSchedule)
+		schedules.POST("/schedules", s.mustLogin, s.idempotent(), s.addSchedule)
+		schedules.GET("/schedules/:id", s.mustLogin, s.etag("id", scheduleETag), s.getScheduleByID)
 		schedules.GET("/schedules", s.mustLogin, s.querySchedule)
 		schedules.GET("/schedules_time_view", s.mustLogin, s.getScheduleTimeView)
 		schedules.GET("/schedules/:id/live/token", s.mustLogin, s.getScheduleLiveToken)
@@ -111,7 +112,7 @@ func (s Server) registeRoute() {
 		assessments.PUT("/assessments_v2/:id", s.mustLogin, s.updateAssessmentV2)
 
 		// live room callback
-		assessments.POST("/assessments", s.addAssessment)
+		assessments.POST("/assessments", s.idempotent(), s.addAssessment)
 
 		// offlineStudy
 		//assessments.GET("/user_offline_study", s.mustLogin, s.queryUserOfflineStudy)
@@ -122,9 +123,58 @@ func (s Server) registeRoute() {
 		assessments.GET("/assessments_summary", s.mustLogin, s.getAssessmentsSummary)
 		assessments.GET("/assessments_for_student", s.mustLogin, s.getStudentAssessments)
 		assessments.GET("/assessments", s.mustLogin, s.queryAssessments)
+
+		// rubric
+		assessments.GET("/assessment_rubrics", s.mustLogin, s.queryAssessmentRubrics)
+		assessments.POST("/assessment_rubrics", s.mustLogin, s.addAssessmentRubric)
+		assessments.GET("/assessment_rubrics/:id", s.mustLogin, s.getAssessmentRubric)
+		assessments.PUT("/assessment_rubrics/:id", s.mustLogin, s.updateAssessmentRubric)
+		assessments.DELETE("/assessment_rubrics/:id", s.mustLogin, s.deleteAssessmentRubric)
+		assessments.POST("/assessment_rubrics_relations", s.mustLogin, s.attachAssessmentRubric)
+		assessments.DELETE("/assessment_rubrics_relations", s.mustLogin, s.detachAssessmentRubric)
+
+		// gradebook
+		assessments.GET("/assessments_gradebooks", s.mustLogin, s.getAssessmentGradebook)
+		assessments.GET("/assessments_gradebooks/export", s.mustLogin, s.exportAssessmentGradebook)
+		assessments.POST("/assessments_gradebooks/sync", s.mustLogin, s.syncAssessmentGradebook)
+
+		assessments.GET("/organizations_api_keys", s.mustLogin, s.queryOrganizationAPIKeys)
+		assessments.POST("/organizations_api_keys", s.mustLogin, s.addOrganizationAPIKey)
+		assessments.DELETE("/organizations_api_keys/:id", s.mustLogin, s.deleteOrganizationAPIKey)
+
+		assessments.GET("/assessments_item_analysis", s.mustLogin, s.getAssessmentItemAnalysis)
+
+		// moderation
+		assessments.GET("/assessments_moderation_policies", s.mustLogin, s.queryAssessmentModerationPolicies)
+		assessments.PUT("/assessments_moderation_policies", s.mustLogin, s.setAssessmentModerationPolicy)
+		assessments.DELETE("/assessments_moderation_policies/:program_id", s.mustLogin, s.deleteAssessmentModerationPolicy)
+		assessments.GET("/assessments_moderations", s.mustLogin, s.queryAssessmentModerations)
+		assessments.GET("/assessments_moderations/:id", s.mustLogin, s.getAssessmentModeration)
+		assessments.PUT("/assessments_moderations/:id", s.mustLogin, s.updateAssessmentModeration)
+
+		// auto complete
+		assessments.GET("/assessments_auto_complete_rules", s.mustLogin, s.queryAssessmentAutoCompleteRules)
+		assessments.POST("/assessments_auto_complete_rules", s.mustLogin, s.addAssessmentAutoCompleteRule)
+		assessments.PUT("/assessments_auto_complete_rules/:id", s.mustLogin, s.updateAssessmentAutoCompleteRule)
+		assessments.DELETE("/assessments_auto_complete_rules/:id", s.mustLogin, s.deleteAssessmentAutoCompleteRule)
+		assessments.PUT("/assessments_v2/:id/reopen", s.mustLogin, s.reopenAssessment)
 	}
 
-	reports := s.engine.Group("/v1")
+	oneRoster := s.engine.Group(v2.OneRosterGradebookPath, s.mustOrgAPIKey)
+	{
+		oneRoster.GET("/lineItems", s.getOneRosterLineItems)
+		oneRoster.GET("/lineItems/:id", s.getOneRosterLineItem)
+		oneRoster.GET("/lineItems/:id/results", s.getOneRosterResultsForLineItem)
+		oneRoster.GET("/results", s.getOneRosterResults)
+		oneRoster.GET("/results/:id", s.getOneRosterResult)
+		oneRoster.GET("/classes/:class_id/lineItems", s.getOneRosterLineItemsForClass)
+		oneRoster.GET("/classes/:class_id/results", s.getOneRosterResultsForClass)
+		oneRoster.GET("/classes/:class_id/students/:student_id/results", s.getOneRosterResultsForStudentForClass)
+		oneRoster.GET("/categories", s.getOneRosterCategories)
+		oneRoster.GET("/categories/:id", s.getOneRosterCategory)
+	}
+
+	reports := s.engine.Group("/v1", s.reportExport())
 	{
 		reports.GET("/reports/students_achievement_overview", s.mustLogin, s.getLearningOutcomeOverView)
 		reports.GET("/reports/students", s.mustLogin, s.listStudentsAchievementReport)
@@ -166,14 +216,25 @@ func (s Server) registeRoute() {
 		reports.POST("/reports/learner_usage/overview", s.mustLogin, s.getLearnerUsageOverview)
 
 		reports.GET("/reports/class_widget", s.mustLogin, s.getClassWidget)
+
+		reports.GET("/reports/headquarters", s.mustLogin, s.getHeadquartersReport)
+
+		reports.GET("/reports/builder/metadata", s.mustLogin, s.getReportBuilderMetadata)
+		reports.POST("/reports/builder/query", s.mustLogin, s.queryReportBuilder)
+		reports.GET("/reports/builder/definitions/:id/result", s.mustLogin, s.runReportDefinition)
+
+		reports.GET("/reports/learner_report", s.mustLogin, s.getLearnerReport)
+		reports.GET("/reports/learner_report/pdf", s.mustLogin, s.getLearnerReportPdf)
+
+		reports.GET("/reports/exports/:id", s.mustLogin, s.getReportExport)
 	}
 
 	outcomes := s.engine.Group("/v1")
 	{
 		outcomes.POST("/learning_outcomes", s.mustLogin, s.createOutcome)
-		outcomes.GET("/learning_outcomes/:id", s.mustLogin, s.getOutcome)
-		outcomes.PUT("/learning_outcomes/:id", s.mustLogin, s.updateOutcome)
-		outcomes.DELETE("/learning_outcomes/:id", s.mustLogin, s.deleteOutcome)
+		outcomes.GET("/learning_outcomes/:id", s.mustLogin, s.etag("id", outcomeETag), s.getOutcome)
+		outcomes.PUT("/learning_outcomes/:id", s.mustLogin, s.etag("id", outcomeETag), s.updateOutcome)
+		outcomes.DELETE("/learning_outcomes/:id", s.mustLogin, s.etag("id", outcomeETag), s.deleteOutcome)
 		outcomes.GET("/learning_outcomes", s.mustLogin, s.queryOutcomes)
 		outcomes.POST("/learning_outcomes/export", s.mustLogin, s.exportOutcomes)
 		outcomes.POST("/learning_outcomes/verify_import", s.mustLogin, s.verifyImportOutcomes)
@@ -203,16 +264,16 @@ func (s Server) registeRoute() {
 	folders := s.engine.Group("/v1/folders")
 	{
 		folders.POST("", s.mustLogin, s.createFolder)
-		folders.DELETE("/items/:item_id", s.mustLogin, s.removeFolderItem)
+		folders.DELETE("/items/:item_id", s.mustLogin, s.etag("item_id", folderETag), s.removeFolderItem)
 		folders.DELETE("/items", s.mustLogin, s.removeFolderItemBulk)
-		folders.PUT("/items/details/:item_id", s.mustLogin, s.updateFolderItem)
+		folders.PUT("/items/details/:item_id", s.mustLogin, s.etag("item_id", folderETag), s.updateFolderItem)
 		folders.PUT("/items/move", s.mustLogin, s.moveFolderItem)
 		folders.PUT("/items/bulk/move", s.mustLogin, s.moveFolderItemBulk)
 
 		folders.GET("/items/list/:item_id", s.mustLogin, s.listFolderItems)
 		folders.GET("/items/search/private", s.mustLogin, s.searchPrivateFolderItems)
 		folders.GET("/items/search/org", s.mustLogin, s.searchOrgFolderItems)
-		folders.GET("/items/details/:item_id", s.mustLogin, s.getFolderItemByID)
+		folders.GET("/items/details/:item_id", s.mustLogin, s.etag("item_id", folderETag), s.getFolderItemByID)
 
 		folders.GET("/share", s.mustLogin, s.getFoldersSharedRecords)
 		folders.PUT("/share", s.mustLogin, s.shareFolders)
@@ -302,10 +363,10 @@ func (s Server) registeRoute() {
 	milestone := s.engine.Group("/v1")
 	{
 		milestone.POST("/milestones", s.mustLogin, s.createMilestone)
-		milestone.GET("/milestones/:id", s.mustLogin, s.obtainMilestone)
+		milestone.GET("/milestones/:id", s.mustLogin, s.etag("id", milestoneETag), s.obtainMilestone)
 
 		milestone.PUT("/milestones/:id/occupy", s.mustLogin, s.occupyMilestone)
-		milestone.PUT("/milestones/:id", s.mustLogin, s.updateMilestone)
+		milestone.PUT("/milestones/:id", s.mustLogin, s.etag("id", milestoneETag), s.updateMilestone)
 
 		milestone.DELETE("/milestones", s.mustLogin, s.deleteMilestone)
 
@@ -325,7 +386,104 @@ func (s Server) registeRoute() {
 
 	studentUsageReport := s.engine.Group("/v1/student_usage_record")
 	{
-		studentUsageReport.POST("/event", s.addStudentUsageRecordEvent)
+		studentUsageReport.POST("/event", s.idempotent(), s.addStudentUsageRecordEvent)
+		studentUsageReport.POST("/events", s.idempotent(), s.addStudentUsageRecordEvents)
+	}
+
+	webhooks := s.engine.Group("/v1")
+	{
+		webhooks.GET("/webhooks", s.mustLogin, s.queryWebhooks)
+		webhooks.POST("/webhooks", s.mustLogin, s.addWebhook)
+		webhooks.PUT("/webhooks/:id", s.mustLogin, s.updateWebhook)
+		webhooks.DELETE("/webhooks/:id", s.mustLogin, s.deleteWebhook)
+		webhooks.GET("/webhooks_deliveries", s.mustLogin, s.queryWebhookDeliveries)
+		webhooks.POST("/webhooks_deliveries/:id/redeliver", s.mustLogin, s.redeliverWebhookDelivery)
+	}
+
+	reportSubscriptions := s.engine.Group("/v1")
+	{
+		reportSubscriptions.POST("/report_subscriptions", s.mustLogin, s.addReportSubscription)
+		reportSubscriptions.GET("/report_subscriptions", s.mustLogin, s.queryReportSubscriptions)
+		reportSubscriptions.GET("/report_subscriptions/:id", s.mustLogin, s.getReportSubscription)
+		reportSubscriptions.PUT("/report_subscriptions/:id", s.mustLogin, s.updateReportSubscription)
+		reportSubscriptions.DELETE("/report_subscriptions/:id", s.mustLogin, s.deleteReportSubscription)
+		reportSubscriptions.POST("/report_subscriptions/:id/pause", s.mustLogin, s.pauseReportSubscription)
+		reportSubscriptions.POST("/report_subscriptions/:id/resume", s.mustLogin, s.resumeReportSubscription)
+		reportSubscriptions.GET("/report_subscriptions/:id/deliveries", s.mustLogin, s.queryReportSubscriptionDeliveries)
+		// links in the mails, signed instead of login
+		reportSubscriptions.GET("/report_subscriptions_links/:id/:action", s.confirmReportSubscriptionLink)
+		reportSubscriptions.POST("/report_subscriptions_links/:id/:action", s.handleReportSubscriptionLink)
+	}
+
+	studentRisks := s.engine.Group("/v1")
+	{
+		studentRisks.GET("/student_risk_settings", s.mustLogin, s.getStudentRiskSetting)
+		studentRisks.PUT("/student_risk_settings", s.mustLogin, s.updateStudentRiskSetting)
+		studentRisks.GET("/reports/student_risks", s.mustLogin, s.queryStudentRisks)
+		studentRisks.GET("/reports/student_risks/histories", s.mustLogin, s.queryStudentRiskHistories)
+	}
+
+	insightRules := s.engine.Group("/v1/insight_rules")
+	{
+		insightRules.GET("", s.mustLogin, s.getInsightRules)
+		insightRules.PUT("", s.mustLogin, s.updateInsightRules)
+		insightRules.DELETE("", s.mustLogin, s.resetInsightRules)
+		insightRules.POST("/preview", s.mustLogin, s.previewInsightRules)
+		insightRules.GET("/stats", s.mustLogin, s.queryInsightRuleStats)
+	}
+
+	reportDefinitions := s.engine.Group("/v1")
+	{
+		reportDefinitions.POST("/report_definitions", s.mustLogin, s.addReportDefinition)
+		reportDefinitions.GET("/report_definitions", s.mustLogin, s.queryReportDefinitions)
+		reportDefinitions.GET("/report_definitions/:id", s.mustLogin, s.getReportDefinition)
+		reportDefinitions.PUT("/report_definitions/:id", s.mustLogin, s.updateReportDefinition)
+		reportDefinitions.DELETE("/report_definitions/:id", s.mustLogin, s.deleteReportDefinition)
+	}
+
+	learnerReportLinks := s.engine.Group("/v1")
+	{
+		learnerReportLinks.POST("/learner_report_links", s.mustLogin, s.addLearnerReportShareLink)
+		learnerReportLinks.GET("/learner_report_links", s.mustLogin, s.queryLearnerReportShareLinks)
+		learnerReportLinks.POST("/learner_report_links/:id/revoke", s.mustLogin, s.revokeLearnerReportShareLink)
+		// opened by parents, signed instead of login
+		learnerReportLinks.GET("/learner_report_links/:id", s.viewLearnerReportShareLink)
+		learnerReportLinks.GET("/learner_report_links/:id/pdf", s.downloadLearnerReportShareLinkPdf)
+	}
+
+	xapi := s.engine.Group("/v1/xapi")
+	{
+		xapi.POST("/statements", s.mustLogin, s.storeXAPIStatements)
+		xapi.GET("/statements", s.mustLogin, s.queryXAPIStatements)
+		xapi.GET("/statements/export", s.mustLogin, s.exportXAPIStatements)
+	}
+
+	jobs := s.engine.Group("/v1/jobs")
+	{
+		jobs.GET("", s.mustLogin, s.queryJobs)
+		jobs.GET("/:id", s.mustLogin, s.getJob)
+		jobs.POST("/:id/cancel", s.mustLogin, s.cancelJob)
+		jobs.GET("/:id/file", s.mustLogin, s.downloadJobFile)
+	}
+
+	audits := s.engine.Group("/v1")
+	{
+		audits.GET("/audit_logs", s.mustLogin, s.queryAuditLogs)
+		audits.GET("/audit_logs/export", s.mustLogin, s.exportAuditLogs)
+		audits.GET("/audit_settings", s.mustLogin, s.getAuditSetting)
+		audits.PUT("/audit_settings", s.mustLogin, s.updateAuditSetting)
+	}
+
+	admin := s.engine.Group("/v1/admin", s.mustAdmin)
+	{
+		admin.GET("/domain_events", s.queryDomainEvents)
+		admin.POST("/domain_events/:id/replay", s.replayDomainEvent)
+		admin.GET("/cache_stats", s.getCacheStats)
+		admin.GET("/external_status", s.getExternalStatus)
+		admin.GET("/student_usage_ingest_stats", s.getStudentUsageIngestStats)
+		admin.POST("/directory/:type", s.upsertDirectory)
+		admin.POST("/directory/:type/import", s.importDirectory)
+		admin.DELETE("/directory/:type", s.deleteDirectory)
 	}
 
 	internal := s.engine.Group("/v1/internal")
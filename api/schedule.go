@@ -1116,7 +1116,9 @@ func (s *Server) updateScheduleStatus(c *gin.Context) {
 		return
 	}
 
-	err := model.GetScheduleModel().UpdateScheduleStatus(ctx, dbo.MustGetDB(ctx), op, id, scheduleStatus)
+	err := dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		return model.GetScheduleModel().UpdateScheduleStatus(ctx, tx, op, id, scheduleStatus)
+	})
 	log.Info(ctx, "schedule status error", log.String("id", id), log.String("status", status))
 	switch err {
 	case constant.ErrRecordNotFound:
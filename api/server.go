@@ -9,6 +9,8 @@ import (
 
 	"github.com/KL-Engineering/common-log/log"
 	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
@@ -28,6 +30,13 @@ func NewServer() *Server {
 
 	log.Debug(context.TODO(), "init gin success")
 
+	// X-Forwarded-For is only believed when the peer is a configured proxy
+	if err := server.engine.SetTrustedProxies(config.Get().CORS.TrustedProxies); err != nil {
+		log.Error(context.TODO(), "set trusted proxies failed",
+			log.Err(err),
+			log.Strings("trustedProxies", config.Get().CORS.TrustedProxies))
+	}
+
 	if config.Get().NewRelic.Enable() {
 		// New Relic middle should insert in the very beginning as the new relic doc says.
 		// And newRelicMiddlewareRectifier retrieve the txn applied by previous new relic middleware,
@@ -41,7 +50,7 @@ func NewServer() *Server {
 		log.Warn(context.TODO(), "new relic plugin disabled because the necessary environment variables are missing!")
 	}
 
-	server.engine.Use(server.logger(), server.recovery(), server.contextStopwatch())
+	server.engine.Use(server.logger(), server.recovery(), server.contextStopwatch(), server.contextLoader(), server.audit())
 
 	// CORS
 	if len(config.Get().CORS.AllowOrigins) > 0 {
@@ -62,6 +71,11 @@ func NewServer() *Server {
 
 	log.Debug(context.TODO(), "register route success")
 
+	// report exports replay the report request through the engine
+	if err := model.GetJobModel().Register(entity.JobTypeExportReport, server.exportReportJob); err != nil {
+		log.Warn(context.TODO(), "register report export job failed", log.Err(err))
+	}
+
 	return server
 }
 
@@ -92,3 +92,78 @@ func (s *Server) addStudentUsageRecordEvent(c *gin.Context) {
 	}
 	return
 }
+
+// @Summary student usage record batch
+// @Description queue a batch of student usage events, each token is signed like the token of a single event. Events are validated, deduplicated and written in bulk, the whole batch is refused with 429 when the queue is full
+// @Tags usageRecord
+// @ID studentUsageRecordBatch
+// @Accept json
+// @Produce json
+// @Param events body entity.StudentUsageRecordBatchReq true "record usages"
+// @Success 202 {object} entity.StudentUsageIngestResult
+// @Failure 400 {object} BadRequestResponse
+// @Failure 429 {object} BadRequestResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /student_usage_record/events [post]
+func (s *Server) addStudentUsageRecordEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.StudentUsageRecordBatchReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Warn(ctx, "add student usage record events: bind body json failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+	if len(req.Tokens) == 0 || len(req.Tokens) > constant.StudentUsageIngestMaxEvents {
+		log.Warn(ctx, "add student usage record events: invalid number of events", log.Int("events", len(req.Tokens)))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	// rejected tokens are left nil
+	events := make([]*entity.StudentUsageRecord, len(req.Tokens))
+	var rejected []*entity.StudentUsageIngestRejection
+	for i, token := range req.Tokens {
+		log.Info(ctx, "get event token",
+			log.String("log type", "report"),
+			log.String("token", token),
+			log.String("step", "REPORT step1"))
+
+		claims := entity.StudentUsageRecordInJwt{}
+		_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+			return config.Get().Assessment.AddAssessmentSecret, nil
+		})
+		if err != nil {
+			log.Warn(ctx, "add student usage record events: parse token failed", log.Err(err), log.Int("index", i))
+			rejected = append(rejected, &entity.StudentUsageIngestRejection{Index: i, Reason: "invalid token"})
+			continue
+		}
+		claims.ContentType = strings.ToLower(strings.TrimSpace(claims.ContentType))
+		events[i] = &claims.StudentUsageRecord
+	}
+
+	result, err := model.GetStudentUsageIngestModel().Submit(ctx, op, events)
+	switch err {
+	case nil:
+		result.Rejected = append(rejected, result.Rejected...)
+		c.JSON(http.StatusAccepted, result)
+	case constant.ErrExceededLimit:
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusTooManyRequests, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary get student usage ingest stats
+// @Description counters and queue of the student usage ingestion on the instance which handles the request, since it started
+// @Tags admin
+// @ID getStudentUsageIngestStats
+// @Accept json
+// @Produce json
+// @Success 200 {object} entity.StudentUsageIngestStats
+// @Failure 401 {object} UnAuthorizedResponse
+// @Router /admin/student_usage_ingest_stats [get]
+func (s *Server) getStudentUsageIngestStats(c *gin.Context) {
+	c.JSON(http.StatusOK, model.GetStudentUsageIngestModel().Stats())
+}
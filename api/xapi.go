@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// @Summary store xapi statements
+// @Description store a statement or an array of them like the statements resource of an lrs, ids and stored are assigned by cms. They are forwarded to the lrs if one is configured. A statement with the id of a different one stored before conflicts
+// @Tags xapi
+// @ID storeXAPIStatements
+// @Accept json
+// @Produce json
+// @Param req body entity.XAPIStatement true "a statement or an array of statements"
+// @Success 200 {array} string
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 409 {object} ConflictResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /xapi/statements [post]
+func (s *Server) storeXAPIStatements(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	c.Header(entity.XAPIHeaderVersion, entity.XAPIVersion)
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, constant.XAPIStoreBodyMaxBytes))
+	if err != nil {
+		log.Warn(ctx, "store xapi statements: read body failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetXAPIModel().Store(ctx, op, body)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	case constant.ErrConflict:
+		c.JSON(http.StatusConflict, L(GeneralUnknown))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary query xapi statements
+// @Description statements of the organization, latest stored first, parameters follow the statements resource of xapi. since and until are compared in seconds. more is the url of the next page, empty on the last page
+// @Tags xapi
+// @ID queryXAPIStatements
+// @Accept json
+// @Produce json
+// @Param statementId query string false "statement id"
+// @Param agent query string false "json of an agent, e.g. {\"account\":{\"homePage\":\"https://kidsloop.net\",\"name\":\"user id\"}}"
+// @Param verb query string false "verb iri"
+// @Param activity query string false "activity iri"
+// @Param since query string false "stored at or after, iso 8601"
+// @Param until query string false "stored at or before, iso 8601"
+// @Param limit query integer false "statements in a page, 0 means the maximum 500"
+// @Param ascending query boolean false "earliest stored first"
+// @Param page query integer false "page"
+// @Success 200 {object} entity.XAPIStatementResult
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /xapi/statements [get]
+func (s *Server) queryXAPIStatements(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	c.Header(entity.XAPIHeaderVersion, entity.XAPIVersion)
+	req := new(entity.XAPIStatementQueryReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "query xapi statements: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	result, err := model.GetXAPIModel().Query(ctx, op, req)
+	switch err {
+	case nil:
+		if result.HasMore {
+			query := c.Request.URL.Query()
+			query.Set("page", strconv.Itoa(req.Page+1))
+			result.More = c.Request.URL.Path + "?" + query.Encode()
+		}
+		c.JSON(http.StatusOK, result)
+	case constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
+
+// @Summary export xapi statements
+// @Description statements of the organization as json lines, earliest stored first, at most 100000
+// @Tags xapi
+// @ID exportXAPIStatements
+// @Accept json
+// @Produce octet-stream
+// @Param agent query string false "json of an agent"
+// @Param verb query string false "verb iri"
+// @Param activity query string false "activity iri"
+// @Param since query string false "stored at or after, iso 8601"
+// @Param until query string false "stored at or before, iso 8601"
+// @Success 200 {file} file
+// @Failure 400 {object} BadRequestResponse
+// @Failure 403 {object} ForbiddenResponse
+// @Failure 500 {object} InternalServerErrorResponse
+// @Router /xapi/statements/export [get]
+func (s *Server) exportXAPIStatements(c *gin.Context) {
+	ctx := c.Request.Context()
+	op := s.getOperator(c)
+	req := new(entity.XAPIStatementQueryReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		log.Warn(ctx, "export xapi statements: bind query failed", log.Err(err))
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+		return
+	}
+
+	// headers are written with the first page, errors before it are replied as usual
+	started := false
+	start := func() {
+		fileName := fmt.Sprintf("xapi_statements_%s.jsonl", time.Now().UTC().Format("20060102"))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header(entity.XAPIHeaderVersion, entity.XAPIVersion)
+		c.Status(http.StatusOK)
+		started = true
+	}
+	err := model.GetXAPIModel().Export(ctx, op, req, func(statements []json.RawMessage) error {
+		if !started {
+			start()
+		}
+		for _, item := range statements {
+			if _, err := c.Writer.Write(append(item, '\n')); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil && !started {
+		// nothing matched, reply an empty file
+		start()
+	}
+
+	switch {
+	case err == nil:
+	case started:
+		// the file has been partly sent, nothing else can be replied
+		log.Error(ctx, "export xapi statements: write file failed", log.Err(err), log.Any("req", req))
+	case err == constant.ErrInvalidArgs:
+		c.JSON(http.StatusBadRequest, L(GeneralUnknown))
+	case err == constant.ErrForbidden:
+		c.JSON(http.StatusForbidden, L(ReportMsgNoPermission))
+	default:
+		s.defaultErrorHandler(c, err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/KL-Engineering/dbo"
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
+)
+
+// rebuild report rollups of an organization, or every organization with schedules since -from if -org is empty.
+// Reports of an organization read the rollups once it has been rebuilt.
+func main() {
+	a, err := parseArgs()
+	if err != nil {
+		flag.Usage()
+		fmt.Println()
+		panic(err)
+	}
+
+	confirmArgs(a)
+	if err := initConfig(a); err != nil {
+		panic(err)
+	}
+
+	result, err := model.GetReportRollupModel().Rebuild(context.Background(), a.OrgID, a.StartAt)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("=> Congratulation! %d days of %d organizations rebuilt!\n", result.Days, len(result.OrgIDs))
+}
+
+type args struct {
+	DSN     string `json:"dsn"`
+	OrgID   string `json:"org_id"`
+	From    string `json:"from"`
+	Offset  int    `json:"offset"`
+	StartAt int64  `json:"start_at"`
+}
+
+func parseArgs() (*args, error) {
+	a := args{}
+	flag.StringVar(&a.DSN, "dsn", "", `db connection string, required`)
+	flag.StringVar(&a.OrgID, "org", "", `organization id, every organization if empty`)
+	flag.StringVar(&a.From, "from", "", `rebuild from, unix timestamp or date like 2022-01-01, required`)
+	flag.IntVar(&a.Offset, "offset", 0, `time zone offset in seconds the rollups are dated by, must match report_rollup_time_zone_offset of the service`)
+	flag.Parse()
+
+	if a.DSN == "" {
+		return nil, errors.New("require dsn argument")
+	}
+	if a.From == "" {
+		return nil, errors.New("require from argument")
+	}
+
+	startAt, err := strconv.ParseInt(a.From, 10, 64)
+	if err != nil {
+		from, err := time.ParseInLocation("2006-01-02", a.From, time.FixedZone("report_rollup", a.Offset))
+		if err != nil {
+			return nil, fmt.Errorf("invalid from argument: %w", err)
+		}
+		startAt = from.Unix()
+	}
+	a.StartAt = startAt
+
+	fmt.Println("=> Parse args done!")
+
+	return &a, nil
+}
+
+func confirmArgs(a *args) {
+	bs, err := json.Marshal(a)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Please check args:", string(bs))
+	fmt.Print("Enter to continue ...")
+	if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+		panic(err)
+	}
+}
+
+func initConfig(a *args) error {
+	c := &config.Config{
+		DBConfig:     config.DBConfig{ConnectionString: a.DSN},
+		ReportRollup: config.ReportRollupConfig{TimeZoneOffset: a.Offset},
+	}
+	config.Set(c)
+
+	newDBO, err := dbo.NewWithConfig(dbo.WithConnectionString(c.DBConfig.ConnectionString))
+	if err != nil {
+		log.Println("connection mysql error:", err)
+		return err
+	}
+	dbo.ReplaceGlobal(newDBO)
+
+	fmt.Println("=> Init config done!")
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+// re-ingest student usage events from a file through the batch endpoint of a running service.
+// Each line of the file is an event token like {"token":"..."}, as the event endpoint logs it,
+// or a student usage record in json which is signed with -key.
+// Records written before are deduplicated by the service, so a file can be replayed more than once.
+// The records the service failed to write are dead lettered in these lines to the redis list
+// student_usage:ingest_dead_letter, dump it with `redis-cli --raw LRANGE student_usage:ingest_dead_letter 0 -1`.
+func main() {
+	a, err := parseArgs()
+	if err != nil {
+		flag.Usage()
+		fmt.Println()
+		panic(err)
+	}
+
+	confirmArgs(a)
+
+	var key *rsa.PrivateKey
+	if a.KeyPath != "" {
+		content, err := ioutil.ReadFile(a.KeyPath)
+		if err != nil {
+			panic(err)
+		}
+		key, err = jwt.ParseRSAPrivateKeyFromPEM(content)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	file, err := os.Open(a.File)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	r := &replayer{args: a, key: key, client: &http.Client{Timeout: time.Minute}}
+	if err := r.replay(file); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("=> Congratulation! %d lines replayed, %d records accepted, %d duplicated, %d rejected!\n",
+		r.lines, r.accepted, r.duplicated, r.rejected)
+}
+
+type args struct {
+	Endpoint   string `json:"endpoint"`
+	File       string `json:"file"`
+	KeyPath    string `json:"key_path"`
+	BatchSize  int    `json:"batch_size"`
+	MaxRetries int    `json:"max_retries"`
+}
+
+func parseArgs() (*args, error) {
+	a := args{}
+	flag.StringVar(&a.Endpoint, "endpoint", "", `url of the service, like https://cms.example.com/v1, required`)
+	flag.StringVar(&a.File, "file", "", `file of the events, one event in each line, required`)
+	flag.StringVar(&a.KeyPath, "key", "", `rsa private key in pem which signs the lines of records, the public key is ams_assessment_jwt_public_key_path of the service`)
+	flag.IntVar(&a.BatchSize, "batch", 500, `events in a request, at most 1000`)
+	flag.IntVar(&a.MaxRetries, "retries", 10, `retries of a request refused or failed by the service`)
+	flag.Parse()
+
+	if a.Endpoint == "" {
+		return nil, errors.New("require endpoint argument")
+	}
+	if a.File == "" {
+		return nil, errors.New("require file argument")
+	}
+	if a.BatchSize <= 0 || a.BatchSize > 1000 {
+		return nil, errors.New("batch argument must be in 1-1000")
+	}
+	a.Endpoint = strings.TrimSuffix(a.Endpoint, "/")
+
+	fmt.Println("=> Parse args done!")
+
+	return &a, nil
+}
+
+func confirmArgs(a *args) {
+	bs, err := json.Marshal(a)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Please check args:", string(bs))
+	fmt.Print("Enter to continue ...")
+	if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+		panic(err)
+	}
+}
+
+type replayer struct {
+	args   *args
+	key    *rsa.PrivateKey
+	client *http.Client
+
+	lines      int
+	accepted   int
+	duplicated int
+	rejected   int
+}
+
+func (r *replayer) replay(file *os.File) error {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+
+	var tokens []string
+	// lineNumbers of the tokens in the batch
+	var lineNumbers []int
+	for scanner.Scan() {
+		r.lines++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		token, err := r.token(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", r.lines, err)
+		}
+		tokens = append(tokens, token)
+		lineNumbers = append(lineNumbers, r.lines)
+
+		if len(tokens) >= r.args.BatchSize {
+			if err := r.send(tokens, lineNumbers); err != nil {
+				return err
+			}
+			tokens, lineNumbers = nil, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(tokens) > 0 {
+		return r.send(tokens, lineNumbers)
+	}
+	return nil
+}
+
+func (r *replayer) token(line string) (string, error) {
+	jwtToken := entity.JwtToken{}
+	if err := json.Unmarshal([]byte(line), &jwtToken); err != nil {
+		return "", err
+	}
+	if jwtToken.Token != "" {
+		return jwtToken.Token, nil
+	}
+
+	if r.key == nil {
+		return "", errors.New("require key argument to sign records")
+	}
+	claims := entity.StudentUsageRecordInJwt{StandardClaims: &jwt.StandardClaims{}}
+	if err := json.Unmarshal([]byte(line), &claims.StudentUsageRecord); err != nil {
+		return "", err
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(r.key)
+}
+
+func (r *replayer) send(tokens []string, lineNumbers []int) error {
+	body, err := json.Marshal(&entity.StudentUsageRecordBatchReq{Tokens: tokens})
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		status, data, retryAfter, err := r.post(body)
+		if err == nil && status == http.StatusAccepted {
+			result := entity.StudentUsageIngestResult{}
+			if err := json.Unmarshal(data, &result); err != nil {
+				return err
+			}
+			r.accepted += result.Accepted
+			r.duplicated += result.Duplicated
+			r.rejected += len(result.Rejected)
+			for _, rejection := range result.Rejected {
+				fmt.Printf("line %d rejected: %s\n", lineNumbers[rejection.Index], rejection.Reason)
+			}
+			fmt.Printf("=> %d lines sent\n", lineNumbers[len(lineNumbers)-1])
+			return nil
+		}
+
+		retryable := err != nil || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+		if !retryable || attempt >= r.args.MaxRetries {
+			return fmt.Errorf("send lines %d-%d failed: status %d, %s, %v",
+				lineNumbers[0], lineNumbers[len(lineNumbers)-1], status, string(data), err)
+		}
+
+		wait := retryAfter
+		if wait <= 0 {
+			wait = time.Duration(attempt+1) * time.Second
+		}
+		fmt.Printf("=> retry in %v, status %d, %v\n", wait, status, err)
+		time.Sleep(wait)
+	}
+}
+
+func (r *replayer) post(body []byte) (int, []byte, time.Duration, error) {
+	response, err := r.client.Post(r.args.Endpoint+"/student_usage_record/events", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	return response.StatusCode, data, retryAfter, nil
+}
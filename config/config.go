@@ -32,27 +32,42 @@ func NewStorageDownloadMode(mode string) StorageDownloadMode {
 }
 
 type Config struct {
-	StorageConfig         StorageConfig         `yaml:"storage_config"`
-	CDNConfig             CDNConfig             `yaml:"cdn_config"`
-	Schedule              ScheduleConfig        `json:"schedule" yaml:"schedule"`
-	DBConfig              DBConfig              `yaml:"db_config"`
-	RedisConfig           RedisConfig           `yaml:"redis_config"`
-	CryptoConfig          CryptoConfig          `yaml:"crypto_config"`
-	LiveTokenConfig       LiveTokenConfig       `yaml:"live_token_config"`
-	Assessment            AssessmentConfig      `yaml:"assessment_config"`
-	AMS                   AMSConfig             `json:"ams" yaml:"ams"`
-	H5P                   H5PServiceConfig      `json:"h5p" yaml:"h5p"`
-	DataService           DataServiceConfig     `json:"data_service" yaml:"data_service"`
-	KidsLoopRegion        string                `json:"kidsloop_region" yaml:"kidsloop_region"`
-	TencentConfig         TencentConfig         `json:"tencent" yaml:"tencent"`
-	KidsloopCNLoginConfig KidsloopCNLoginConfig `json:"kidsloop_cn" yaml:"kidsloop_cn"`
-	CORS                  CORSConfig            `json:"cors" yaml:"cors"`
-	ShowInternalErrorType bool                  `json:"show_internal_error_type"`
-	User                  UserConfig            `json:"user" yaml:"user"`
-	Report                ReportConfig          `json:"report" yaml:"report"`
-	NewRelic              NewRelicConfig        `json:"new_relic" yaml:"new_relic"`
-	Log                   LogConfig             `json:"log_config" yaml:"log_config"`
-	STMInternal           STMInternalConfig     `json:"stm_internal_config" yaml:"stm_internal_config"`
+	StorageConfig         StorageConfig            `yaml:"storage_config"`
+	CDNConfig             CDNConfig                `yaml:"cdn_config"`
+	Schedule              ScheduleConfig           `json:"schedule" yaml:"schedule"`
+	DBConfig              DBConfig                 `yaml:"db_config"`
+	RedisConfig           RedisConfig              `yaml:"redis_config"`
+	CryptoConfig          CryptoConfig             `yaml:"crypto_config"`
+	LiveTokenConfig       LiveTokenConfig          `yaml:"live_token_config"`
+	Assessment            AssessmentConfig         `yaml:"assessment_config"`
+	AMS                   AMSConfig                `json:"ams" yaml:"ams"`
+	H5P                   H5PServiceConfig         `json:"h5p" yaml:"h5p"`
+	DataService           DataServiceConfig        `json:"data_service" yaml:"data_service"`
+	KidsLoopRegion        string                   `json:"kidsloop_region" yaml:"kidsloop_region"`
+	TencentConfig         TencentConfig            `json:"tencent" yaml:"tencent"`
+	KidsloopCNLoginConfig KidsloopCNLoginConfig    `json:"kidsloop_cn" yaml:"kidsloop_cn"`
+	CORS                  CORSConfig               `json:"cors" yaml:"cors"`
+	ShowInternalErrorType bool                     `json:"show_internal_error_type"`
+	User                  UserConfig               `json:"user" yaml:"user"`
+	Report                ReportConfig             `json:"report" yaml:"report"`
+	NewRelic              NewRelicConfig           `json:"new_relic" yaml:"new_relic"`
+	Log                   LogConfig                `json:"log_config" yaml:"log_config"`
+	STMInternal           STMInternalConfig        `json:"stm_internal_config" yaml:"stm_internal_config"`
+	EventBus              EventBusConfig           `json:"event_bus" yaml:"event_bus"`
+	Admin                 AdminConfig              `json:"admin" yaml:"admin"`
+	Webhook               WebhookConfig            `json:"webhook" yaml:"webhook"`
+	Job                   JobConfig                `json:"job" yaml:"job"`
+	Audit                 AuditConfig              `json:"audit" yaml:"audit"`
+	Resilience            ResilienceConfig         `json:"resilience" yaml:"resilience"`
+	Directory             DirectoryConfig          `json:"directory" yaml:"directory"`
+	Idempotency           IdempotencyConfig        `json:"idempotency" yaml:"idempotency"`
+	ReportRollup          ReportRollupConfig       `json:"report_rollup" yaml:"report_rollup"`
+	Email                 EmailConfig              `json:"email" yaml:"email"`
+	ReportSubscription    ReportSubscriptionConfig `json:"report_subscription" yaml:"report_subscription"`
+	StudentRisk           StudentRiskConfig        `json:"student_risk" yaml:"student_risk"`
+	LearnerReport         LearnerReportConfig      `json:"learner_report" yaml:"learner_report"`
+	StudentUsageIngest    StudentUsageIngestConfig `json:"student_usage_ingest" yaml:"student_usage_ingest"`
+	XAPI                  XAPIConfig               `json:"xapi" yaml:"xapi"`
 }
 
 type STMInternalConfig struct {
@@ -68,6 +83,8 @@ type LogConfig struct {
 type CORSConfig struct {
 	AllowOrigins      []string `json:"allow_origins"`
 	AllowFileProtocol bool     `json:"allow_file_protocol"`
+	// TrustedProxies the only peers whose X-Forwarded-For is believed, the client ip is the peer address otherwise
+	TrustedProxies []string `json:"trusted_proxies"`
 }
 
 type CryptoConfig struct {
@@ -76,6 +93,9 @@ type CryptoConfig struct {
 
 type RedisConfig struct {
 	OpenCache bool `yaml:"open_cache"`
+	// LocalCacheCapacity entries of the in-process tier in front of redis, zero turns it off
+	LocalCacheCapacity   int           `yaml:"local_cache_capacity"`
+	LocalCacheExpiration time.Duration `yaml:"local_cache_expiration"`
 	// DEPRECATED
 	// will remove in future, please use Option instead
 	Host string `yaml:"host"`
@@ -142,6 +162,144 @@ type AssessmentConfig struct {
 	CacheExpiration      time.Duration `json:"cache_expiration" yaml:"cache_expiration"`
 	AddAssessmentSecret  interface{}   `json:"-"`
 	DefaultRemainingTime time.Duration `json:"default_remaining_time" yaml:"default_remaining_time"`
+	// auto complete worker is disabled when interval is empty, it reads H5P scores with the authorized key
+	AutoCompleteInterval time.Duration `json:"auto_complete_interval" yaml:"auto_complete_interval"`
+}
+
+type EventBusConfig struct {
+	DispatchInterval time.Duration `json:"dispatch_interval" yaml:"dispatch_interval"`
+	DispatchTimeout  time.Duration `json:"dispatch_timeout" yaml:"dispatch_timeout"`
+	MaxAttempts      int           `json:"max_attempts" yaml:"max_attempts"`
+	RetryBackoff     time.Duration `json:"retry_backoff" yaml:"retry_backoff"`
+}
+
+type WebhookConfig struct {
+	DeliveryInterval time.Duration `json:"delivery_interval" yaml:"delivery_interval"`
+	MaxAttempts      int           `json:"max_attempts" yaml:"max_attempts"`
+	RetryBackoff     time.Duration `json:"retry_backoff" yaml:"retry_backoff"`
+	Timeout          time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+type JobConfig struct {
+	RelayInterval   time.Duration `json:"relay_interval" yaml:"relay_interval"`
+	MaxAttempts     int           `json:"max_attempts" yaml:"max_attempts"`
+	RetryBackoff    time.Duration `json:"retry_backoff" yaml:"retry_backoff"`
+	ClaimTimeout    time.Duration `json:"claim_timeout" yaml:"claim_timeout"`
+	RunningTimeout  time.Duration `json:"running_timeout" yaml:"running_timeout"`
+	TokenExpiration time.Duration `json:"token_expiration" yaml:"token_expiration"`
+}
+
+type AuditConfig struct {
+	// DefaultRetentionDays for organizations without an audit setting
+	DefaultRetentionDays int           `json:"default_retention_days" yaml:"default_retention_days"`
+	PurgeInterval        time.Duration `json:"purge_interval" yaml:"purge_interval"`
+	ExportLimit          int           `json:"export_limit" yaml:"export_limit"`
+}
+
+// DirectoryConfig where users, organizations, schools, classes, roles, permissions, programs and subjects come from
+type DirectoryConfig struct {
+	// Provider ams or local, the local directory is kept in mysql
+	Provider string `json:"provider" yaml:"provider"`
+	// SeedDir csv files named after the directory types in it are imported into the local directory at startup
+	SeedDir string `json:"seed_dir" yaml:"seed_dir"`
+}
+
+// IdempotencyConfig requests with an Idempotency-Key header
+type IdempotencyConfig struct {
+	Window      time.Duration `json:"window" yaml:"window"`
+	LockTimeout time.Duration `json:"lock_timeout" yaml:"lock_timeout"`
+}
+
+// ReportRollupConfig pre-aggregated report tables
+type ReportRollupConfig struct {
+	RefreshInterval time.Duration `json:"refresh_interval" yaml:"refresh_interval"`
+	// TimeZoneOffset seconds east of utc, days and weeks of the rollups start at midnight of it
+	TimeZoneOffset int `json:"time_zone_offset" yaml:"time_zone_offset"`
+}
+
+// EmailConfig smtp server of outgoing mails
+type EmailConfig struct {
+	Host          string `json:"host" yaml:"host"`
+	Port          int    `json:"port" yaml:"port"`
+	User          string `json:"user" yaml:"user"`
+	Password      string `json:"-" yaml:"password"`
+	SenderAddress string `json:"sender_address" yaml:"sender_address"`
+	SenderName    string `json:"sender_name" yaml:"sender_name"`
+}
+
+// ReportSubscriptionConfig reports mailed on a cadence
+type ReportSubscriptionConfig struct {
+	DeliveryInterval time.Duration `json:"delivery_interval" yaml:"delivery_interval"`
+	// LinkBaseURL public url of this service, pause and unsubscribe links in the mails start with it
+	LinkBaseURL string `json:"link_base_url" yaml:"link_base_url"`
+	// LinkSecret signs the links, mails are not sent when it is empty
+	LinkSecret string `json:"-" yaml:"link_secret"`
+}
+
+// StudentRiskConfig at-risk student evaluation of the organizations enabling it
+type StudentRiskConfig struct {
+	EvaluateInterval time.Duration `json:"evaluate_interval" yaml:"evaluate_interval"`
+}
+
+// LearnerReportConfig learner reports shared with parents by signed links
+type LearnerReportConfig struct {
+	// LinkBaseURL public url of this service, share links start with it
+	LinkBaseURL string `json:"link_base_url" yaml:"link_base_url"`
+	// LinkSecret signs the share links, links are not created when it is empty
+	LinkSecret string `json:"-" yaml:"link_secret"`
+	// LinkViewLimit views of a link in a rate window
+	LinkViewLimit int `json:"link_view_limit" yaml:"link_view_limit"`
+	// ClientViewLimit views of any link from an ip in a rate window
+	ClientViewLimit int           `json:"client_view_limit" yaml:"client_view_limit"`
+	RateWindow      time.Duration `json:"rate_window" yaml:"rate_window"`
+}
+
+// StudentUsageIngestConfig buffered pipeline of batched student usage events
+type StudentUsageIngestConfig struct {
+	// QueueSize records buffered on an instance, batches are refused when it is full
+	QueueSize     int           `json:"queue_size" yaml:"queue_size"`
+	BatchSize     int           `json:"batch_size" yaml:"batch_size"`
+	FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval"`
+	Workers       int           `json:"workers" yaml:"workers"`
+	DedupeTTL     time.Duration `json:"dedupe_ttl" yaml:"dedupe_ttl"`
+}
+
+// XAPIConfig xapi statements of learning activities, kept in cms and forwarded to an external lrs
+type XAPIConfig struct {
+	// Enabled statements are generated from material views, attendance, assessments and outcomes
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// HomePage accounts of actors and ids of activities start with it
+	HomePage string `json:"home_page" yaml:"home_page"`
+	// LRSEndpoint base url of the lrs, statements are only kept in cms when it is empty
+	LRSEndpoint     string        `json:"lrs_endpoint" yaml:"lrs_endpoint"`
+	LRSUsername     string        `json:"-" yaml:"lrs_username"`
+	LRSPassword     string        `json:"-" yaml:"lrs_password"`
+	ForwardInterval time.Duration `json:"forward_interval" yaml:"forward_interval"`
+	BatchSize       int           `json:"batch_size" yaml:"batch_size"`
+	MaxAttempts     int           `json:"max_attempts" yaml:"max_attempts"`
+	RetryBackoff    time.Duration `json:"retry_backoff" yaml:"retry_backoff"`
+	Timeout         time.Duration `json:"timeout" yaml:"timeout"`
+	// IDSecret ids of generated statements can't be predicted by clients without it
+	IDSecret string `json:"-" yaml:"id_secret"`
+}
+
+// ResilienceConfig calls of ams, data service and h5p
+type ResilienceConfig struct {
+	// MaxAttempts of idempotent queries, mutations run once
+	MaxAttempts int           `json:"max_attempts" yaml:"max_attempts"`
+	BaseBackoff time.Duration `json:"base_backoff" yaml:"base_backoff"`
+	MaxBackoff  time.Duration `json:"max_backoff" yaml:"max_backoff"`
+	// HedgeDelay a slow query is sent again after the delay, zero is off
+	HedgeDelay       time.Duration `json:"hedge_delay" yaml:"hedge_delay"`
+	FailureThreshold int           `json:"failure_threshold" yaml:"failure_threshold"`
+	OpenTimeout      time.Duration `json:"open_timeout" yaml:"open_timeout"`
+	// StaleExpiration how long the last good response of a query is kept, zero is off
+	StaleExpiration time.Duration `json:"stale_expiration" yaml:"stale_expiration"`
+}
+
+// AdminConfig operation apis are rejected when the key is empty
+type AdminConfig struct {
+	AuthorizedKey string `json:"-" yaml:"authorized_key"`
 }
 
 type AMSConfig struct {
@@ -159,6 +317,8 @@ type DataServiceConfig struct {
 
 type H5PServiceConfig struct {
 	EndPoint string `json:"endpoint" yaml:"endpoint"`
+	// used by background workers, whose operators have no token
+	AuthorizedKey string `json:"-" yaml:"authorized_key"`
 }
 
 type KidsloopCNLoginConfig struct {
@@ -252,6 +412,21 @@ func LoadEnvConfig() {
 	loadNewRelicConfig(ctx)
 	loadLogConfig(ctx)
 	LoadSTMConfig(ctx)
+	loadEventBusConfig(ctx)
+	loadAdminConfig(ctx)
+	loadWebhookConfig(ctx)
+	loadJobConfig(ctx)
+	loadAuditConfig(ctx)
+	loadResilienceConfig(ctx)
+	loadDirectoryConfig(ctx)
+	loadIdempotencyConfig(ctx)
+	loadReportRollupConfig(ctx)
+	loadEmailConfig(ctx)
+	loadReportSubscriptionConfig(ctx)
+	loadStudentRiskConfig(ctx)
+	loadLearnerReportConfig(ctx)
+	loadStudentUsageIngestConfig(ctx)
+	loadXAPIConfig(ctx)
 }
 
 func loadShowInternalErrorTypeConfig(ctx context.Context) {
@@ -354,6 +529,16 @@ func loadStorageEnvConfig(ctx context.Context) {
 func LoadRedisEnvConfig(ctx context.Context) {
 	openCache, _ := strconv.ParseBool(os.Getenv("open_cache"))
 	config.RedisConfig.OpenCache = openCache
+
+	config.RedisConfig.LocalCacheCapacity = constant.LocalCacheDefaultCapacity
+	if capacity, err := strconv.Atoi(os.Getenv("local_cache_capacity")); err == nil && capacity >= 0 {
+		config.RedisConfig.LocalCacheCapacity = capacity
+	}
+	config.RedisConfig.LocalCacheExpiration = constant.LocalCacheDefaultExpiration
+	if expiration, err := time.ParseDuration(os.Getenv("local_cache_expiration")); err == nil {
+		config.RedisConfig.LocalCacheExpiration = expiration
+	}
+
 	redisURL := os.Getenv("redis_url")
 	if redisURL != "" {
 		// new config
@@ -542,6 +727,11 @@ func loadAssessmentConfig(ctx context.Context) {
 	} else {
 		config.Assessment.DefaultRemainingTime = defaultRemainingTime
 	}
+
+	autoCompleteInterval, err := time.ParseDuration(os.Getenv("assessment_auto_complete_interval"))
+	if err == nil {
+		config.Assessment.AutoCompleteInterval = autoCompleteInterval
+	}
 }
 
 func LoadAMSConfig(ctx context.Context) {
@@ -574,6 +764,7 @@ func LoadH5PServiceConfig(ctx context.Context) {
 	if h5pEndpoint != "" {
 		config.H5P.EndPoint = h5pEndpoint
 	}
+	config.H5P.AuthorizedKey = os.Getenv("h5p_service_api_key")
 }
 
 func loadDataServiceConfig(ctx context.Context) {
@@ -586,6 +777,12 @@ func loadDataServiceConfig(ctx context.Context) {
 func loadCORSConfig(ctx context.Context) {
 	config.CORS.AllowOrigins = strings.Split(os.Getenv("cors_domain_list"), ",")
 	config.CORS.AllowFileProtocol, _ = strconv.ParseBool(os.Getenv("cors_allow_file_protocol"))
+	config.CORS.TrustedProxies = nil
+	for _, proxy := range strings.Split(os.Getenv("trusted_proxies"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			config.CORS.TrustedProxies = append(config.CORS.TrustedProxies, proxy)
+		}
+	}
 }
 
 func loadUserConfig(ctx context.Context) {
@@ -627,6 +824,174 @@ func loadNewRelicConfig(ctx context.Context) {
 	}
 }
 
+func loadEventBusConfig(ctx context.Context) {
+	config.EventBus.DispatchInterval = constant.DomainEventDefaultDispatchInterval
+	if interval, err := time.ParseDuration(os.Getenv("event_bus_dispatch_interval")); err == nil {
+		config.EventBus.DispatchInterval = interval
+	}
+
+	config.EventBus.DispatchTimeout = constant.DomainEventDefaultDispatchTimeout
+	if timeout, err := time.ParseDuration(os.Getenv("event_bus_dispatch_timeout")); err == nil {
+		config.EventBus.DispatchTimeout = timeout
+	}
+
+	config.EventBus.MaxAttempts = constant.DomainEventDefaultMaxAttempts
+	if maxAttempts, err := strconv.Atoi(os.Getenv("event_bus_max_attempts")); err == nil && maxAttempts > 0 {
+		config.EventBus.MaxAttempts = maxAttempts
+	}
+
+	config.EventBus.RetryBackoff = constant.DomainEventDefaultRetryBackoff
+	if backoff, err := time.ParseDuration(os.Getenv("event_bus_retry_backoff")); err == nil {
+		config.EventBus.RetryBackoff = backoff
+	}
+}
+
+func loadWebhookConfig(ctx context.Context) {
+	config.Webhook.DeliveryInterval = constant.WebhookDefaultDeliveryInterval
+	if interval, err := time.ParseDuration(os.Getenv("webhook_delivery_interval")); err == nil {
+		config.Webhook.DeliveryInterval = interval
+	}
+
+	config.Webhook.MaxAttempts = constant.WebhookDefaultMaxAttempts
+	if maxAttempts, err := strconv.Atoi(os.Getenv("webhook_max_attempts")); err == nil && maxAttempts > 0 {
+		config.Webhook.MaxAttempts = maxAttempts
+	}
+
+	config.Webhook.RetryBackoff = constant.WebhookDefaultRetryBackoff
+	if backoff, err := time.ParseDuration(os.Getenv("webhook_retry_backoff")); err == nil {
+		config.Webhook.RetryBackoff = backoff
+	}
+
+	config.Webhook.Timeout = constant.WebhookDefaultTimeout
+	if timeout, err := time.ParseDuration(os.Getenv("webhook_timeout")); err == nil {
+		config.Webhook.Timeout = timeout
+	}
+}
+
+func loadJobConfig(ctx context.Context) {
+	config.Job.RelayInterval = constant.JobDefaultRelayInterval
+	if interval, err := time.ParseDuration(os.Getenv("job_relay_interval")); err == nil {
+		config.Job.RelayInterval = interval
+	}
+
+	config.Job.MaxAttempts = constant.JobDefaultMaxAttempts
+	if maxAttempts, err := strconv.Atoi(os.Getenv("job_max_attempts")); err == nil && maxAttempts > 0 {
+		config.Job.MaxAttempts = maxAttempts
+	}
+
+	config.Job.RetryBackoff = constant.JobDefaultRetryBackoff
+	if backoff, err := time.ParseDuration(os.Getenv("job_retry_backoff")); err == nil {
+		config.Job.RetryBackoff = backoff
+	}
+
+	config.Job.ClaimTimeout = constant.JobDefaultClaimTimeout
+	if timeout, err := time.ParseDuration(os.Getenv("job_claim_timeout")); err == nil {
+		config.Job.ClaimTimeout = timeout
+	}
+
+	config.Job.RunningTimeout = constant.JobDefaultRunningTimeout
+	if timeout, err := time.ParseDuration(os.Getenv("job_running_timeout")); err == nil {
+		config.Job.RunningTimeout = timeout
+	}
+
+	config.Job.TokenExpiration = constant.JobDefaultTokenExpiration
+	if expiration, err := time.ParseDuration(os.Getenv("job_token_expiration")); err == nil {
+		config.Job.TokenExpiration = expiration
+	}
+}
+
+func loadAuditConfig(ctx context.Context) {
+	config.Audit.DefaultRetentionDays = constant.AuditDefaultRetentionDays
+	if days, err := strconv.Atoi(os.Getenv("audit_default_retention_days")); err == nil && days > 0 && days <= constant.AuditMaxRetentionDays {
+		config.Audit.DefaultRetentionDays = days
+	}
+
+	config.Audit.PurgeInterval = constant.AuditDefaultPurgeInterval
+	if interval, err := time.ParseDuration(os.Getenv("audit_purge_interval")); err == nil {
+		config.Audit.PurgeInterval = interval
+	}
+
+	config.Audit.ExportLimit = constant.AuditDefaultExportLimit
+	if limit, err := strconv.Atoi(os.Getenv("audit_export_limit")); err == nil && limit > 0 {
+		config.Audit.ExportLimit = limit
+	}
+}
+
+func loadResilienceConfig(ctx context.Context) {
+	config.Resilience.MaxAttempts = constant.ResilienceDefaultMaxAttempts
+	if attempts, err := strconv.Atoi(os.Getenv("resilience_max_attempts")); err == nil && attempts > 0 {
+		config.Resilience.MaxAttempts = attempts
+	}
+
+	config.Resilience.BaseBackoff = constant.ResilienceDefaultBaseBackoff
+	if backoff, err := time.ParseDuration(os.Getenv("resilience_base_backoff")); err == nil && backoff >= 0 {
+		config.Resilience.BaseBackoff = backoff
+	}
+
+	config.Resilience.MaxBackoff = constant.ResilienceDefaultMaxBackoff
+	if backoff, err := time.ParseDuration(os.Getenv("resilience_max_backoff")); err == nil && backoff >= 0 {
+		config.Resilience.MaxBackoff = backoff
+	}
+
+	config.Resilience.HedgeDelay = constant.ResilienceDefaultHedgeDelay
+	if delay, err := time.ParseDuration(os.Getenv("resilience_hedge_delay")); err == nil && delay >= 0 {
+		config.Resilience.HedgeDelay = delay
+	}
+
+	config.Resilience.FailureThreshold = constant.ResilienceDefaultFailureThreshold
+	if threshold, err := strconv.Atoi(os.Getenv("resilience_failure_threshold")); err == nil && threshold > 0 {
+		config.Resilience.FailureThreshold = threshold
+	}
+
+	config.Resilience.OpenTimeout = constant.ResilienceDefaultOpenTimeout
+	if timeout, err := time.ParseDuration(os.Getenv("resilience_open_timeout")); err == nil && timeout > 0 {
+		config.Resilience.OpenTimeout = timeout
+	}
+
+	config.Resilience.StaleExpiration = constant.ResilienceDefaultStaleExpiration
+	if expiration, err := time.ParseDuration(os.Getenv("resilience_stale_expiration")); err == nil && expiration >= 0 {
+		config.Resilience.StaleExpiration = expiration
+	}
+}
+
+func loadDirectoryConfig(ctx context.Context) {
+	config.Directory.Provider = constant.DirectoryProviderAms
+	if provider := os.Getenv("directory_provider"); provider != "" {
+		if provider != constant.DirectoryProviderAms && provider != constant.DirectoryProviderLocal {
+			log.Panic(ctx, "invalid directory_provider", log.String("provider", provider))
+		}
+		config.Directory.Provider = provider
+	}
+	config.Directory.SeedDir = os.Getenv("directory_seed_dir")
+}
+
+func loadIdempotencyConfig(ctx context.Context) {
+	config.Idempotency.Window = constant.IdempotencyDefaultWindow
+	if window, err := time.ParseDuration(os.Getenv("idempotency_window")); err == nil && window > 0 {
+		config.Idempotency.Window = window
+	}
+
+	config.Idempotency.LockTimeout = constant.IdempotencyDefaultLockTimeout
+	if timeout, err := time.ParseDuration(os.Getenv("idempotency_lock_timeout")); err == nil && timeout > 0 {
+		config.Idempotency.LockTimeout = timeout
+	}
+}
+
+func loadReportRollupConfig(ctx context.Context) {
+	config.ReportRollup.RefreshInterval = constant.ReportRollupDefaultRefreshInterval
+	if interval, err := time.ParseDuration(os.Getenv("report_rollup_refresh_interval")); err == nil && interval > 0 {
+		config.ReportRollup.RefreshInterval = interval
+	}
+
+	if offset, err := strconv.Atoi(os.Getenv("report_rollup_time_zone_offset")); err == nil && offset > -constant.ReportRollupDay && offset < constant.ReportRollupDay {
+		config.ReportRollup.TimeZoneOffset = offset
+	}
+}
+
+func loadAdminConfig(ctx context.Context) {
+	config.Admin.AuthorizedKey = os.Getenv("admin_authorized_key")
+}
+
 func loadLogConfig(ctx context.Context) {
 	config.Log.Level = log.LogLevel(os.Getenv("LOG_LEVEL"))
 	if config.Log.Level == "" {
@@ -641,3 +1006,120 @@ func Get() *Config {
 func Set(c *Config) {
 	config = c
 }
+
+func loadEmailConfig(ctx context.Context) {
+	config.Email.Host = os.Getenv("email_host")
+	if port, err := strconv.Atoi(os.Getenv("email_port")); err == nil && port > 0 {
+		config.Email.Port = port
+	}
+	config.Email.User = os.Getenv("email_user")
+	config.Email.Password = os.Getenv("email_password")
+	config.Email.SenderAddress = os.Getenv("email_sender_address")
+	config.Email.SenderName = os.Getenv("email_sender_name")
+}
+
+func loadReportSubscriptionConfig(ctx context.Context) {
+	config.ReportSubscription.DeliveryInterval = constant.ReportSubscriptionDefaultDeliveryInterval
+	if interval, err := time.ParseDuration(os.Getenv("report_subscription_delivery_interval")); err == nil && interval > 0 {
+		config.ReportSubscription.DeliveryInterval = interval
+	}
+
+	config.ReportSubscription.LinkBaseURL = strings.TrimSuffix(os.Getenv("report_subscription_link_base_url"), "/")
+	config.ReportSubscription.LinkSecret = os.Getenv("report_subscription_link_secret")
+}
+
+func loadStudentRiskConfig(ctx context.Context) {
+	config.StudentRisk.EvaluateInterval = constant.StudentRiskDefaultEvaluateInterval
+	if interval, err := time.ParseDuration(os.Getenv("student_risk_evaluate_interval")); err == nil && interval > 0 {
+		config.StudentRisk.EvaluateInterval = interval
+	}
+}
+
+func loadLearnerReportConfig(ctx context.Context) {
+	config.LearnerReport.LinkBaseURL = strings.TrimSuffix(os.Getenv("learner_report_link_base_url"), "/")
+	config.LearnerReport.LinkSecret = os.Getenv("learner_report_link_secret")
+
+	config.LearnerReport.LinkViewLimit = constant.LearnerReportShareDefaultLinkViewLimit
+	if limit, err := strconv.Atoi(os.Getenv("learner_report_link_view_limit")); err == nil && limit > 0 {
+		config.LearnerReport.LinkViewLimit = limit
+	}
+
+	config.LearnerReport.ClientViewLimit = constant.LearnerReportShareDefaultClientViewLimit
+	if limit, err := strconv.Atoi(os.Getenv("learner_report_client_view_limit")); err == nil && limit > 0 {
+		config.LearnerReport.ClientViewLimit = limit
+	}
+
+	config.LearnerReport.RateWindow = constant.LearnerReportShareDefaultRateWindow
+	if window, err := time.ParseDuration(os.Getenv("learner_report_rate_window")); err == nil && window > 0 {
+		config.LearnerReport.RateWindow = window
+	}
+}
+
+func loadStudentUsageIngestConfig(ctx context.Context) {
+	config.StudentUsageIngest.QueueSize = constant.StudentUsageIngestDefaultQueueSize
+	if size, err := strconv.Atoi(os.Getenv("student_usage_ingest_queue_size")); err == nil && size > 0 {
+		config.StudentUsageIngest.QueueSize = size
+	}
+
+	config.StudentUsageIngest.BatchSize = constant.StudentUsageIngestDefaultBatchSize
+	if size, err := strconv.Atoi(os.Getenv("student_usage_ingest_batch_size")); err == nil && size > 0 {
+		config.StudentUsageIngest.BatchSize = size
+	}
+
+	config.StudentUsageIngest.FlushInterval = constant.StudentUsageIngestDefaultFlushInterval
+	if interval, err := time.ParseDuration(os.Getenv("student_usage_ingest_flush_interval")); err == nil && interval > 0 {
+		config.StudentUsageIngest.FlushInterval = interval
+	}
+
+	config.StudentUsageIngest.Workers = constant.StudentUsageIngestDefaultWorkers
+	if workers, err := strconv.Atoi(os.Getenv("student_usage_ingest_workers")); err == nil && workers > 0 {
+		config.StudentUsageIngest.Workers = workers
+	}
+
+	config.StudentUsageIngest.DedupeTTL = constant.StudentUsageIngestDefaultDedupeTTL
+	if ttl, err := time.ParseDuration(os.Getenv("student_usage_ingest_dedupe_ttl")); err == nil && ttl > 0 {
+		config.StudentUsageIngest.DedupeTTL = ttl
+	}
+}
+
+func loadXAPIConfig(ctx context.Context) {
+	config.XAPI.Enabled, _ = strconv.ParseBool(os.Getenv("xapi_enabled"))
+
+	config.XAPI.HomePage = constant.XAPIDefaultHomePage
+	if homePage := strings.TrimSuffix(os.Getenv("xapi_home_page"), "/"); homePage != "" {
+		config.XAPI.HomePage = homePage
+	}
+
+	config.XAPI.LRSEndpoint = strings.TrimSuffix(os.Getenv("xapi_lrs_endpoint"), "/")
+	config.XAPI.LRSUsername = os.Getenv("xapi_lrs_username")
+	config.XAPI.LRSPassword = os.Getenv("xapi_lrs_password")
+	config.XAPI.IDSecret = os.Getenv("xapi_id_secret")
+	if config.XAPI.Enabled && config.XAPI.IDSecret == "" {
+		log.Warn(ctx, "xapi_id_secret is empty, ids of generated statements are predictable")
+	}
+
+	config.XAPI.ForwardInterval = constant.XAPIDefaultForwardInterval
+	if interval, err := time.ParseDuration(os.Getenv("xapi_forward_interval")); err == nil && interval > 0 {
+		config.XAPI.ForwardInterval = interval
+	}
+
+	config.XAPI.BatchSize = constant.XAPIDefaultForwardBatchSize
+	if size, err := strconv.Atoi(os.Getenv("xapi_batch_size")); err == nil && size > 0 {
+		config.XAPI.BatchSize = size
+	}
+
+	config.XAPI.MaxAttempts = constant.XAPIDefaultMaxAttempts
+	if maxAttempts, err := strconv.Atoi(os.Getenv("xapi_max_attempts")); err == nil && maxAttempts > 0 {
+		config.XAPI.MaxAttempts = maxAttempts
+	}
+
+	config.XAPI.RetryBackoff = constant.XAPIDefaultRetryBackoff
+	if backoff, err := time.ParseDuration(os.Getenv("xapi_retry_backoff")); err == nil {
+		config.XAPI.RetryBackoff = backoff
+	}
+
+	config.XAPI.Timeout = constant.XAPIDefaultTimeout
+	if timeout, err := time.ParseDuration(os.Getenv("xapi_timeout")); err == nil {
+		config.XAPI.Timeout = timeout
+	}
+}
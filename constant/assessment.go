@@ -15,6 +15,11 @@ const (
 
 	AssessmentBatchPageSize = 500
 
+	// AssessmentAutoCompleteBatchSize assessments of an org evaluated in a run, the least recently evaluated first
+	AssessmentAutoCompleteBatchSize = 200
+	// AssessmentAutoCompleteRunTimeout no assessment is evaluated after it, so a run ends before its lock expires
+	AssessmentAutoCompleteRunTimeout = 2 * time.Minute
+
 	AssessmentInitializedKey = "Initialized"
 	AssessmentHistoryFlag    = 1
 	AssessmentCurrentFlag    = 2
@@ -26,4 +31,18 @@ const (
 	TableNameAssessmentsContentsV2        = "assessments_contents_v2"
 	TableNameAssessmentReviewerFeedbackV2 = "assessments_reviewer_feedback_v2"
 	TableNameAssessmentsUsersOutcomesV2   = "assessments_users_outcomes_v2"
+
+	TableNameAssessmentRubricV2          = "assessments_rubrics_v2"
+	TableNameAssessmentRubricCriterionV2 = "assessments_rubrics_criteria_v2"
+	TableNameAssessmentRubricLevelV2     = "assessments_rubrics_levels_v2"
+	TableNameAssessmentRubricRelationV2  = "assessments_rubrics_relations_v2"
+	TableNameAssessmentUserRubricScoreV2 = "assessments_users_rubric_scores_v2"
+
+	TableNameAssessmentGradebookLineItemV2 = "assessments_gradebook_line_items_v2"
+	TableNameAssessmentGradebookResultV2   = "assessments_gradebook_results_v2"
+
+	TableNameAssessmentModerationPolicyV2 = "assessments_moderation_policies_v2"
+	TableNameAssessmentModerationV2       = "assessments_moderations_v2"
+
+	TableNameAssessmentAutoCompleteRuleV2 = "assessments_auto_complete_rules_v2"
 )
@@ -7,6 +7,15 @@ const (
 	MaxCacheExpire = time.Minute * 10
 )
 
+const (
+	LocalCacheDefaultCapacity   = 10000
+	LocalCacheDefaultExpiration = time.Minute
+	// HotObjectCacheExpiration programs, subjects, ages and grades are read by most requests and rarely change
+	HotObjectCacheExpiration = time.Minute * 5
+	// DirectoryCacheExpiration users, organizations, schools and classes of the local directory, writes invalidate them
+	DirectoryCacheExpiration = time.Minute * 5
+)
+
 const (
 	ContentFolderQueryCacheRefreshDuration = time.Minute
 	ContentFolderQueryCacheExpiration      = 0 // never expire
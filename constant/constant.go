@@ -20,14 +20,218 @@ const (
 	TableNameProgramGroup = "programs_groups"
 
 	TableNameOrganizationProperty = "organizations_properties"
+	TableNameOrganizationAPIKey   = "organizations_api_keys"
 
 	TableNameStudentUsageRecord = "student_usage_records"
+
+	TableNameDomainEvent            = "domain_events"
+	TableNameDomainEventConsumption = "domain_events_consumptions"
+
+	TableNameWebhook         = "webhooks"
+	TableNameWebhookDelivery = "webhooks_deliveries"
+
+	TableNameJob     = "jobs"
+	TableNameJobFile = "jobs_files"
+
+	TableNameAuditLog     = "audit_logs"
+	TableNameAuditSetting = "audit_settings"
+
+	TableNameDirectoryUser           = "directory_users"
+	TableNameDirectoryOrganization   = "directory_organizations"
+	TableNameDirectorySchool         = "directory_schools"
+	TableNameDirectoryClass          = "directory_classes"
+	TableNameDirectoryRole           = "directory_roles"
+	TableNameDirectoryProgram        = "directory_programs"
+	TableNameDirectorySubject        = "directory_subjects"
+	TableNameDirectoryMembership     = "directory_memberships"
+	TableNameDirectoryMembershipRole = "directory_membership_roles"
+	TableNameDirectoryRolePermission = "directory_role_permissions"
+	TableNameDirectoryClassSchool    = "directory_class_schools"
+	TableNameDirectoryClassMember    = "directory_class_members"
+	TableNameDirectoryProgramSubject = "directory_program_subjects"
+
+	TableNameReportRollupDaily  = "report_rollups_daily"
+	TableNameReportRollupWeekly = "report_rollups_weekly"
+	TableNameReportRollupDirty  = "report_rollups_dirty"
+	TableNameReportRollupState  = "report_rollups_states"
+
+	TableNameReportSubscription         = "report_subscriptions"
+	TableNameReportSubscriptionDelivery = "report_subscriptions_deliveries"
+
+	TableNameStudentRiskSetting     = "student_risk_settings"
+	TableNameStudentRiskFlag        = "student_risk_flags"
+	TableNameStudentRiskFlagHistory = "student_risk_flags_histories"
+
+	TableNameInsightRuleSet = "insight_rule_sets"
+	TableNameInsightRuleLog = "insight_rule_logs"
+
+	TableNameReportDefinition = "report_definitions"
+
+	TableNameLearnerReportShareLink = "learner_report_share_links"
+
+	TableNameXAPIStatement = "xapi_statements"
 )
 
 const (
 	ScheduleDefaultCacheExpiration = 3 * time.Minute
 )
 
+const (
+	DomainEventDefaultDispatchInterval = 5 * time.Second
+	DomainEventDefaultMaxAttempts      = 8
+	DomainEventDefaultRetryBackoff     = 30 * time.Second
+	// DomainEventDefaultDispatchTimeout a dispatched event is sent again when handlers do not report back in time
+	DomainEventDefaultDispatchTimeout = 10 * time.Minute
+)
+
+const (
+	WebhookDefaultDeliveryInterval = 5 * time.Second
+	WebhookDefaultMaxAttempts      = 10
+	WebhookDefaultRetryBackoff     = time.Minute
+	WebhookDefaultTimeout          = 10 * time.Second
+)
+
+const (
+	JobDefaultRelayInterval = 10 * time.Second
+	JobDefaultMaxAttempts   = 3
+	JobDefaultRetryBackoff  = time.Minute
+	// JobDefaultClaimTimeout an enqueued job is enqueued again when no worker picks it up in time
+	JobDefaultClaimTimeout = 5 * time.Minute
+	// JobDefaultRunningTimeout a running job is retried when it does not report progress in time
+	JobDefaultRunningTimeout = 30 * time.Minute
+	// JobDefaultTokenExpiration the submitter's token is kept for jobs to call other services
+	JobDefaultTokenExpiration = 2 * time.Hour
+)
+
+const (
+	AuditDefaultRetentionDays = 365
+	AuditMaxRetentionDays     = 3650
+	AuditDefaultPurgeInterval = time.Hour
+	// AuditDefaultExportLimit rows in one csv export, narrow the time range for more
+	AuditDefaultExportLimit = 100000
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	IdempotencyKeyMaxLength   = 255
+	// IdempotencyDefaultWindow how long a response is replayed for its key
+	IdempotencyDefaultWindow = 24 * time.Hour
+	// IdempotencyDefaultLockTimeout a request with the key is taken as in progress until it finishes or the timeout
+	IdempotencyDefaultLockTimeout = time.Minute
+)
+
+const (
+	ETagHeader    = "ETag"
+	IfMatchHeader = "If-Match"
+)
+
+const (
+	ReportRollupDefaultRefreshInterval = time.Minute
+	// ReportRollupDay seconds of a daily rollup
+	ReportRollupDay = 24 * 60 * 60
+)
+
+const (
+	// ReportExportSyncRowLimit larger exports are handed over to a job
+	ReportExportSyncRowLimit = 5000
+	// ReportExportPdfRowLimit rows printed in a pdf, the rest are in csv and xlsx only
+	ReportExportPdfRowLimit = 2000
+)
+
+const (
+	ReportSubscriptionDefaultDeliveryInterval = time.Minute
+	ReportSubscriptionMaxRecipients           = 20
+	// ReportSubscriptionSummaryRows rows of the report in the mail body, the attachment has all of them
+	ReportSubscriptionSummaryRows = 20
+)
+
+const (
+	StudentRiskDefaultEvaluateInterval = 6 * time.Hour
+	StudentRiskDefaultWindowDays       = 28
+	StudentRiskMaxWindowDays           = 180
+	StudentRiskDefaultMinSamples       = 3
+	StudentRiskMaxNotifyRecipients     = 20
+	// StudentRiskEvaluateTick classes due are evaluated in batches at the tick
+	StudentRiskEvaluateTick = 5 * time.Minute
+	// StudentRiskEvaluateBatchSize classes evaluated at most in a tick
+	StudentRiskEvaluateBatchSize = 100
+	// StudentRiskEvaluateTimeout a tick stops evaluating in the timeout, well before the evaluate lock expires
+	StudentRiskEvaluateTimeout = 2 * time.Minute
+)
+
+const (
+	InsightRuleMaxRules         = 200
+	InsightRuleMaxConditions    = 10
+	InsightRuleMaxMessageLength = 1024
+)
+
+// HeadquartersReportMaxWeeks weeks of the trend lines of a headquarters report
+const HeadquartersReportMaxWeeks = 53
+
+const (
+	ReportBuilderMaxDimensions   = 5
+	ReportBuilderMaxFilterValues = 500
+	// ReportBuilderMaxPeriods periods of the time range when grouped by period
+	ReportBuilderMaxPeriods = 400
+	// ReportBuilderMaxRows rows of a result, the rest is truncated
+	ReportBuilderMaxRows = 5000
+
+	ReportDefinitionMaxNameLength        = 128
+	ReportDefinitionMaxDescriptionLength = 1024
+)
+
+const (
+	LearnerReportMaxDays = 366
+	// LearnerReportMaxComments latest teacher comments in a learner report
+	LearnerReportMaxComments = 20
+	// LearnerReportMaxAchievedOutcomes latest achieved outcomes named in a learner report
+	LearnerReportMaxAchievedOutcomes = 20
+
+	LearnerReportShareLinkDefaultExpireHours = 7 * 24
+	LearnerReportShareLinkMaxExpireHours     = 90 * 24
+	// LearnerReportShareDefaultLinkViewLimit views of a link in a rate limit window
+	LearnerReportShareDefaultLinkViewLimit = 30
+	// LearnerReportShareDefaultClientViewLimit views of any link from an ip in a rate limit window
+	LearnerReportShareDefaultClientViewLimit = 60
+	LearnerReportShareDefaultRateWindow      = time.Minute
+)
+
+const (
+	// StudentUsageIngestMaxEvents events in a batch request
+	StudentUsageIngestMaxEvents = 1000
+
+	StudentUsageIngestDefaultQueueSize     = 20000
+	StudentUsageIngestDefaultBatchSize     = 500
+	StudentUsageIngestDefaultFlushInterval = time.Second
+	StudentUsageIngestDefaultWorkers       = 2
+	// StudentUsageIngestDefaultDedupeTTL a record is a duplicate of the records written in the ttl
+	StudentUsageIngestDefaultDedupeTTL = 24 * time.Hour
+	// StudentUsageIngestAttempts attempts to resolve a room or write a batch before the records are dead lettered
+	StudentUsageIngestAttempts = 3
+	// StudentUsageIngestRetryBackoff wait before the first retry, doubled after each failure
+	StudentUsageIngestRetryBackoff = 200 * time.Millisecond
+)
+
+const (
+	// XAPIPlatform platform in the context of generated statements
+	XAPIPlatform = "KidsLoop"
+	// XAPIDefaultHomePage accounts of actors and ids of activities start with it
+	XAPIDefaultHomePage = "https://kidsloop.net"
+	// XAPIQueryMaxLimit statements in a page of a query
+	XAPIQueryMaxLimit = 500
+	// XAPIExportLimit statements in an export
+	XAPIExportLimit = 100000
+	// XAPIStoreBodyMaxBytes body of a store request, XAPIQueryMaxLimit statements fit in it
+	XAPIStoreBodyMaxBytes = 8 << 20
+
+	XAPIDefaultForwardInterval  = 10 * time.Second
+	XAPIDefaultForwardBatchSize = 100
+	XAPIDefaultMaxAttempts      = 10
+	XAPIDefaultRetryBackoff     = time.Minute
+	XAPIDefaultTimeout          = 10 * time.Second
+)
+
 var (
 	ErrRecordNotFound  = errors.New("record not found")
 	ErrDuplicateRecord = errors.New("duplicate record")
@@ -43,6 +247,7 @@ var (
 	ErrHasLocked          = errors.New("has locked")
 	ErrOverflow           = errors.New("over flow")
 	ErrOutOfDate          = errors.New("out of date")
+	ErrPreconditionFailed = errors.New("precondition failed")
 	ErrSqlBuilderFailed   = errors.New("sql builder failed")
 	ErrAmsHttpFailed      = errors.New("ams http failed")
 	ErrBadUsageOfKl2Cache = errors.New("bad usage of kl2cache see log for detail")
@@ -1,8 +1,35 @@
 package constant
 
+import "time"
+
 type InternalErrorType string
 
 const (
 	InternalErrorTypeAms           InternalErrorType = "ams"
 	InternalErrorTypeAssessmentApi InternalErrorType = "assessment-api"
 )
+
+// circuit breakers of the upstreams, a breaker of data service for each path
+const (
+	ExternalBreakerAms         = "ams"
+	ExternalBreakerH5P         = "h5p"
+	ExternalBreakerDataService = "data_service"
+)
+
+const (
+	ResilienceDefaultMaxAttempts      = 3
+	ResilienceDefaultBaseBackoff      = 100 * time.Millisecond
+	ResilienceDefaultMaxBackoff       = 2 * time.Second
+	ResilienceDefaultHedgeDelay       = 3 * time.Second
+	ResilienceDefaultFailureThreshold = 5
+	ResilienceDefaultOpenTimeout      = 30 * time.Second
+	ResilienceDefaultStaleExpiration  = 0
+)
+
+// ExternalLoaderWait BatchGet calls of one request within the wait are sent upstream in one batch
+const ExternalLoaderWait = 2 * time.Millisecond
+
+const (
+	DirectoryProviderAms   = "ams"
+	DirectoryProviderLocal = "local"
+)
@@ -2,4 +2,5 @@ package constant
 
 const (
 	H5PServiceDefaultEndpoint = "https://api.alpha.kidsloop.net/assessment/graphql"
+	H5PAuthorizedHeaderKey    = "Authorization"
 )
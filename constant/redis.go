@@ -5,3 +5,5 @@ import "errors"
 var (
 	ErrRedisKeyNotExist = errors.New("redis key not exist")
 )
+
+const RedisKeyPrefixExternalStale = "external:stale"
@@ -20,6 +20,10 @@ type IAssessmentDA interface {
 
 	DeleteByScheduleIDsTx(ctx context.Context, tx *dbo.DBContext, scheduleIDs []string) error
 	PageStudentAssessment(ctx context.Context, condition *StudentAssessmentCondition) (int, []*v2.StudentAssessmentDBView, error)
+	// GetForUpdateTx the assessment is locked until tx ends
+	GetForUpdateTx(ctx context.Context, tx *dbo.DBContext, id string) (*v2.Assessment, error)
+	// MarkAutoCompleteChecked the assessments are evaluated by auto complete rules after the others next time
+	MarkAutoCompleteChecked(ctx context.Context, ids []string, checkedAt int64) error
 }
 
 var (
@@ -189,6 +193,36 @@ func (a *assessmentDA) DeleteByScheduleIDsTx(ctx context.Context, tx *dbo.DBCont
 	return nil
 }
 
+func (a *assessmentDA) GetForUpdateTx(ctx context.Context, tx *dbo.DBContext, id string) (*v2.Assessment, error) {
+	var assessments []*v2.Assessment
+	query := fmt.Sprintf("select * from %s where id = ? for update", constant.TableNameAssessmentV2)
+	if err := a.QueryRawSQLTx(ctx, tx, &assessments, query, id); err != nil {
+		log.Error(ctx, "get assessment for update failed", log.Err(err), log.String("id", id))
+		return nil, err
+	}
+	if len(assessments) <= 0 {
+		return nil, dbo.ErrRecordNotFound
+	}
+
+	return assessments[0], nil
+}
+
+func (a *assessmentDA) MarkAutoCompleteChecked(ctx context.Context, ids []string, checkedAt int64) error {
+	if len(ids) <= 0 {
+		return nil
+	}
+
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+	query := fmt.Sprintf("update %s set auto_complete_checked_at = ? where id in (?)", constant.TableNameAssessmentV2)
+	if err := tx.Exec(query, checkedAt, ids).Error; err != nil {
+		log.Error(ctx, "mark assessments auto complete checked failed", log.Err(err), log.Strings("ids", ids))
+		return err
+	}
+
+	return nil
+}
+
 type StudentAssessmentCondition struct {
 	OrgID           sql.NullString
 	ScheduleIDs     entity.NullStrings
@@ -247,6 +281,7 @@ type StudentIDsAndStatus struct {
 	Status    sql.NullString
 }
 type AssessmentCondition struct {
+	IDs             entity.NullStrings
 	OrgID           sql.NullString
 	ScheduleID      sql.NullString
 	ScheduleIDs     entity.NullStrings
@@ -256,6 +291,7 @@ type AssessmentCondition struct {
 	TeacherIDs      entity.NullStrings
 	ClassIDs        entity.NullStrings
 	DueAtLe         sql.NullInt64
+	ReopenedAt      sql.NullInt64
 
 	UpdateAtGe   sql.NullInt64
 	UpdateAtLe   sql.NullInt64
@@ -274,6 +310,11 @@ func (c AssessmentCondition) GetConditions() ([]string, []interface{}) {
 	var wheres []string
 	var params []interface{}
 
+	if c.IDs.Valid {
+		wheres = append(wheres, "id in (?)")
+		params = append(params, c.IDs.Strings)
+	}
+
 	if c.OrgID.Valid {
 		wheres = append(wheres, "org_id = ?")
 		params = append(params, c.OrgID.String)
@@ -362,6 +403,11 @@ user_id in (?) and
 		params = append(params, c.DueAtLe.Int64)
 	}
 
+	if c.ReopenedAt.Valid {
+		wheres = append(wheres, "reopened_at = ?")
+		params = append(params, c.ReopenedAt.Int64)
+	}
+
 	if c.DeleteAt.Valid {
 		wheres = append(wheres, "delete_at>0")
 	} else {
@@ -389,6 +435,8 @@ const (
 	AssessmentOrderByCompleteAtDesc
 	AssessmentOrderByCreateAtAsc
 	AssessmentOrderByCreateAtDesc
+	// AssessmentOrderByAutoCompleteCheckedAtAsc the least recently evaluated by auto complete rules first
+	AssessmentOrderByAutoCompleteCheckedAtAsc
 )
 
 func NewAssessmentOrderBy(orderBy string) AssessmentOrderBy {
@@ -423,6 +471,8 @@ func (c AssessmentOrderBy) ToSQL() string {
 		return "create_at"
 	case AssessmentOrderByCreateAtDesc:
 		return "create_at desc"
+	case AssessmentOrderByAutoCompleteCheckedAtAsc:
+		return "auto_complete_checked_at, create_at"
 	default:
 		return "create_at desc"
 	}
@@ -0,0 +1,62 @@
+package assessmentV2
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type IAssessmentAutoCompleteRuleDA interface {
+	dbo.DataAccesser
+}
+
+type assessmentAutoCompleteRuleDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_assessmentAutoCompleteRuleOnce sync.Once
+	_assessmentAutoCompleteRuleDA   IAssessmentAutoCompleteRuleDA
+)
+
+func GetAssessmentAutoCompleteRuleDA() IAssessmentAutoCompleteRuleDA {
+	_assessmentAutoCompleteRuleOnce.Do(func() {
+		_assessmentAutoCompleteRuleDA = &assessmentAutoCompleteRuleDA{}
+	})
+	return _assessmentAutoCompleteRuleDA
+}
+
+type AssessmentAutoCompleteRuleCondition struct {
+	OrgID           sql.NullString
+	AssessmentTypes entity.NullStrings
+}
+
+func (c AssessmentAutoCompleteRuleCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.AssessmentTypes.Valid {
+		wheres = append(wheres, "assessment_type in (?)")
+		params = append(params, c.AssessmentTypes.Strings)
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c AssessmentAutoCompleteRuleCondition) GetOrderBy() string {
+	return "org_id, create_at"
+}
+
+func (c AssessmentAutoCompleteRuleCondition) GetPager() *dbo.Pager {
+	return &dbo.NoPager
+}
@@ -0,0 +1,258 @@
+package assessmentV2
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+)
+
+// line items
+
+type IAssessmentGradebookLineItemDA interface {
+	dbo.DataAccesser
+	QueryByOffset(ctx context.Context, condition *AssessmentGradebookLineItemCondition, limit, offset int) (int, []*v2.AssessmentGradebookLineItem, error)
+}
+
+type assessmentGradebookLineItemDA struct {
+	dbo.BaseDA
+}
+
+// QueryByOffset OneRoster pages with limit/offset which is not aligned to page size
+func (a *assessmentGradebookLineItemDA) QueryByOffset(ctx context.Context, condition *AssessmentGradebookLineItemCondition, limit, offset int) (int, []*v2.AssessmentGradebookLineItem, error) {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	wheres, params := condition.GetConditions()
+	whereSql := strings.Join(wheres, " and ")
+
+	var total int
+	if err := tx.Table(constant.TableNameAssessmentGradebookLineItemV2).Where(whereSql, params...).Count(&total).Error; err != nil {
+		log.Error(ctx, "count gradebook line items failed", log.Err(err), log.Any("condition", condition))
+		return 0, nil, err
+	}
+
+	var result []*v2.AssessmentGradebookLineItem
+	if total <= 0 || offset >= total {
+		return total, result, nil
+	}
+
+	if err := tx.Table(constant.TableNameAssessmentGradebookLineItemV2).
+		Where(whereSql, params...).
+		Order(condition.GetOrderBy()).
+		Limit(limit).
+		Offset(offset).
+		Find(&result).Error; err != nil {
+		log.Error(ctx, "query gradebook line items failed", log.Err(err), log.Any("condition", condition))
+		return 0, nil, err
+	}
+
+	return total, result, nil
+}
+
+var (
+	_assessmentGradebookLineItemOnce sync.Once
+	_assessmentGradebookLineItemDA   IAssessmentGradebookLineItemDA
+)
+
+func GetAssessmentGradebookLineItemDA() IAssessmentGradebookLineItemDA {
+	_assessmentGradebookLineItemOnce.Do(func() {
+		_assessmentGradebookLineItemDA = &assessmentGradebookLineItemDA{}
+	})
+	return _assessmentGradebookLineItemDA
+}
+
+type AssessmentGradebookLineItemCondition struct {
+	IDs          entity.NullStrings
+	OrgID        sql.NullString
+	ClassIDs     entity.NullStrings
+	CompleteAtGe sql.NullInt64
+	CompleteAtLe sql.NullInt64
+	// records changed after, including deleted ones
+	ModifiedAtGt   sql.NullInt64
+	IncludeDeleted bool
+}
+
+func (c AssessmentGradebookLineItemCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.IDs.Valid {
+		wheres = append(wheres, "id in (?)")
+		params = append(params, c.IDs.Strings)
+	}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.ClassIDs.Valid {
+		wheres = append(wheres, "class_id in (?)")
+		params = append(params, c.ClassIDs.Strings)
+	}
+
+	if c.CompleteAtGe.Valid {
+		wheres = append(wheres, "complete_at >= ?")
+		params = append(params, c.CompleteAtGe.Int64)
+	}
+
+	if c.CompleteAtLe.Valid {
+		wheres = append(wheres, "complete_at <= ?")
+		params = append(params, c.CompleteAtLe.Int64)
+	}
+
+	if c.ModifiedAtGt.Valid {
+		wheres = append(wheres, "(update_at > ? or delete_at > ?)")
+		params = append(params, c.ModifiedAtGt.Int64, c.ModifiedAtGt.Int64)
+	} else if !c.IncludeDeleted {
+		wheres = append(wheres, "(delete_at=0)")
+	}
+
+	return wheres, params
+}
+
+func (c AssessmentGradebookLineItemCondition) GetOrderBy() string {
+	return "complete_at, id"
+}
+
+func (c AssessmentGradebookLineItemCondition) GetPager() *dbo.Pager {
+	return &dbo.NoPager
+}
+
+// results
+
+type IAssessmentGradebookResultDA interface {
+	dbo.DataAccesser
+	QueryByOffset(ctx context.Context, condition *AssessmentGradebookResultCondition, limit, offset int) (int, []*v2.AssessmentGradebookResult, error)
+	DeleteByLineItemIDTx(ctx context.Context, tx *dbo.DBContext, lineItemID string, excludeIDs []string, deleteAt int64) error
+}
+
+type assessmentGradebookResultDA struct {
+	dbo.BaseDA
+}
+
+func (a *assessmentGradebookResultDA) QueryByOffset(ctx context.Context, condition *AssessmentGradebookResultCondition, limit, offset int) (int, []*v2.AssessmentGradebookResult, error) {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	wheres, params := condition.GetConditions()
+	whereSql := strings.Join(wheres, " and ")
+
+	var total int
+	if err := tx.Table(constant.TableNameAssessmentGradebookResultV2).Where(whereSql, params...).Count(&total).Error; err != nil {
+		log.Error(ctx, "count gradebook results failed", log.Err(err), log.Any("condition", condition))
+		return 0, nil, err
+	}
+
+	var result []*v2.AssessmentGradebookResult
+	if total <= 0 || offset >= total {
+		return total, result, nil
+	}
+
+	if err := tx.Table(constant.TableNameAssessmentGradebookResultV2).
+		Where(whereSql, params...).
+		Order(condition.GetOrderBy()).
+		Limit(limit).
+		Offset(offset).
+		Find(&result).Error; err != nil {
+		log.Error(ctx, "query gradebook results failed", log.Err(err), log.Any("condition", condition))
+		return 0, nil, err
+	}
+
+	return total, result, nil
+}
+
+// DeleteByLineItemIDTx soft delete results of students no longer in the assessment
+func (a *assessmentGradebookResultDA) DeleteByLineItemIDTx(ctx context.Context, tx *dbo.DBContext, lineItemID string, excludeIDs []string, deleteAt int64) error {
+	tx.ResetCondition()
+
+	db := tx.Model(&v2.AssessmentGradebookResult{}).Where("line_item_id = ? and delete_at = 0", lineItemID)
+	if len(excludeIDs) > 0 {
+		db = db.Where("id not in (?)", excludeIDs)
+	}
+
+	if err := db.Update("delete_at", deleteAt).Error; err != nil {
+		log.Error(ctx, "delete gradebook results failed", log.Err(err), log.String("lineItemID", lineItemID))
+		return err
+	}
+
+	return nil
+}
+
+var (
+	_assessmentGradebookResultOnce sync.Once
+	_assessmentGradebookResultDA   IAssessmentGradebookResultDA
+)
+
+func GetAssessmentGradebookResultDA() IAssessmentGradebookResultDA {
+	_assessmentGradebookResultOnce.Do(func() {
+		_assessmentGradebookResultDA = &assessmentGradebookResultDA{}
+	})
+	return _assessmentGradebookResultDA
+}
+
+type AssessmentGradebookResultCondition struct {
+	IDs          entity.NullStrings
+	OrgID        sql.NullString
+	LineItemIDs  entity.NullStrings
+	ClassIDs     entity.NullStrings
+	StudentIDs   entity.NullStrings
+	ModifiedAtGt sql.NullInt64
+
+	IncludeDeleted bool
+}
+
+func (c AssessmentGradebookResultCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.IDs.Valid {
+		wheres = append(wheres, "id in (?)")
+		params = append(params, c.IDs.Strings)
+	}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.LineItemIDs.Valid {
+		wheres = append(wheres, "line_item_id in (?)")
+		params = append(params, c.LineItemIDs.Strings)
+	}
+
+	if c.ClassIDs.Valid {
+		wheres = append(wheres, "class_id in (?)")
+		params = append(params, c.ClassIDs.Strings)
+	}
+
+	if c.StudentIDs.Valid {
+		wheres = append(wheres, "student_id in (?)")
+		params = append(params, c.StudentIDs.Strings)
+	}
+
+	if c.ModifiedAtGt.Valid {
+		wheres = append(wheres, "(update_at > ? or delete_at > ?)")
+		params = append(params, c.ModifiedAtGt.Int64, c.ModifiedAtGt.Int64)
+	} else if !c.IncludeDeleted {
+		wheres = append(wheres, "(delete_at=0)")
+	}
+
+	return wheres, params
+}
+
+func (c AssessmentGradebookResultCondition) GetOrderBy() string {
+	return "line_item_id, id"
+}
+
+func (c AssessmentGradebookResultCondition) GetPager() *dbo.Pager {
+	return &dbo.NoPager
+}
@@ -0,0 +1,140 @@
+package assessmentV2
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+// policies
+
+type IAssessmentModerationPolicyDA interface {
+	dbo.DataAccesser
+}
+
+type assessmentModerationPolicyDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_assessmentModerationPolicyOnce sync.Once
+	_assessmentModerationPolicyDA   IAssessmentModerationPolicyDA
+)
+
+func GetAssessmentModerationPolicyDA() IAssessmentModerationPolicyDA {
+	_assessmentModerationPolicyOnce.Do(func() {
+		_assessmentModerationPolicyDA = &assessmentModerationPolicyDA{}
+	})
+	return _assessmentModerationPolicyDA
+}
+
+type AssessmentModerationPolicyCondition struct {
+	OrgID      sql.NullString
+	ProgramIDs entity.NullStrings
+}
+
+func (c AssessmentModerationPolicyCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.ProgramIDs.Valid {
+		wheres = append(wheres, "program_id in (?)")
+		params = append(params, c.ProgramIDs.Strings)
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c AssessmentModerationPolicyCondition) GetOrderBy() string {
+	return "create_at"
+}
+
+func (c AssessmentModerationPolicyCondition) GetPager() *dbo.Pager {
+	return &dbo.NoPager
+}
+
+// moderations
+
+type IAssessmentModerationDA interface {
+	dbo.DataAccesser
+}
+
+type assessmentModerationDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_assessmentModerationOnce sync.Once
+	_assessmentModerationDA   IAssessmentModerationDA
+)
+
+func GetAssessmentModerationDA() IAssessmentModerationDA {
+	_assessmentModerationOnce.Do(func() {
+		_assessmentModerationDA = &assessmentModerationDA{}
+	})
+	return _assessmentModerationDA
+}
+
+type AssessmentModerationCondition struct {
+	OrgID         sql.NullString
+	AssessmentIDs entity.NullStrings
+	Status        sql.NullString
+	// teachers can only moderate what others marked
+	ExcludeMarkerID sql.NullString
+	// nil means all markers of the organization
+	MarkerIDs entity.NullStrings
+
+	Pager dbo.Pager
+}
+
+func (c AssessmentModerationCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.AssessmentIDs.Valid {
+		wheres = append(wheres, "assessment_id in (?)")
+		params = append(params, c.AssessmentIDs.Strings)
+	}
+
+	if c.Status.Valid {
+		wheres = append(wheres, "status = ?")
+		params = append(params, c.Status.String)
+	}
+
+	if c.ExcludeMarkerID.Valid {
+		wheres = append(wheres, "marker_id <> ?")
+		params = append(params, c.ExcludeMarkerID.String)
+	}
+
+	if c.MarkerIDs.Valid {
+		wheres = append(wheres, "marker_id in (?)")
+		params = append(params, c.MarkerIDs.Strings)
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c AssessmentModerationCondition) GetOrderBy() string {
+	return "create_at"
+}
+
+func (c AssessmentModerationCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
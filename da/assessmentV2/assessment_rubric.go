@@ -0,0 +1,347 @@
+package assessmentV2
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+)
+
+type IAssessmentRubricDA interface {
+	dbo.DataAccesser
+	// LockTx locks the rubrics until tx ends, scoring takes a shared lock and editing an exclusive one
+	LockTx(ctx context.Context, tx *dbo.DBContext, ids []string, exclusive bool) error
+}
+
+type assessmentRubricDA struct {
+	dbo.BaseDA
+}
+
+func (a *assessmentRubricDA) LockTx(ctx context.Context, tx *dbo.DBContext, ids []string, exclusive bool) error {
+	if len(ids) <= 0 {
+		return nil
+	}
+
+	lock := "lock in share mode"
+	if exclusive {
+		lock = "for update"
+	}
+	var rows []*struct {
+		ID string `gorm:"column:id"`
+	}
+	query := fmt.Sprintf("select id from %s where id in (?) %s", constant.TableNameAssessmentRubricV2, lock)
+	if err := a.QueryRawSQLTx(ctx, tx, &rows, query, ids); err != nil {
+		log.Error(ctx, "lock rubrics error", log.Err(err), log.Strings("ids", ids), log.Bool("exclusive", exclusive))
+		return err
+	}
+	return nil
+}
+
+var (
+	_assessmentRubricOnce sync.Once
+	_assessmentRubricDA   IAssessmentRubricDA
+)
+
+func GetAssessmentRubricDA() IAssessmentRubricDA {
+	_assessmentRubricOnce.Do(func() {
+		_assessmentRubricDA = &assessmentRubricDA{}
+	})
+	return _assessmentRubricDA
+}
+
+type AssessmentRubricCondition struct {
+	IDs      entity.NullStrings
+	OrgID    sql.NullString
+	NameLike sql.NullString
+	// deleted rubrics are still needed to read the scores given with them
+	IncludeDeleted bool
+
+	OrderBy AssessmentRubricOrderBy
+	Pager   dbo.Pager
+}
+
+func (c AssessmentRubricCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.IDs.Valid {
+		wheres = append(wheres, "id in (?)")
+		params = append(params, c.IDs.Strings)
+	}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.NameLike.Valid {
+		wheres = append(wheres, "name like ?")
+		params = append(params, "%"+c.NameLike.String+"%")
+	}
+
+	if !c.IncludeDeleted {
+		wheres = append(wheres, "(delete_at=0)")
+	}
+
+	return wheres, params
+}
+
+func (c AssessmentRubricCondition) GetOrderBy() string {
+	return c.OrderBy.ToSQL()
+}
+
+func (c AssessmentRubricCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
+
+type AssessmentRubricOrderBy int
+
+const (
+	AssessmentRubricOrderByCreateAtAsc AssessmentRubricOrderBy = iota + 1
+	AssessmentRubricOrderByCreateAtDesc
+	AssessmentRubricOrderByNameAsc
+	AssessmentRubricOrderByNameDesc
+)
+
+func NewAssessmentRubricOrderBy(orderBy string) AssessmentRubricOrderBy {
+	switch orderBy {
+	case "create_at":
+		return AssessmentRubricOrderByCreateAtAsc
+	case "-create_at":
+		return AssessmentRubricOrderByCreateAtDesc
+	case "name":
+		return AssessmentRubricOrderByNameAsc
+	case "-name":
+		return AssessmentRubricOrderByNameDesc
+	}
+	return AssessmentRubricOrderByCreateAtDesc
+}
+
+func (c AssessmentRubricOrderBy) ToSQL() string {
+	switch c {
+	case AssessmentRubricOrderByCreateAtAsc:
+		return "create_at"
+	case AssessmentRubricOrderByNameAsc:
+		return "name"
+	case AssessmentRubricOrderByNameDesc:
+		return "name desc"
+	default:
+		return "create_at desc"
+	}
+}
+
+// criteria and levels
+
+type IAssessmentRubricItemDA interface {
+	dbo.DataAccesser
+	DeleteByRubricIDTx(ctx context.Context, tx *dbo.DBContext, rubricID string, deleteAt int64) error
+}
+
+type assessmentRubricCriterionDA struct {
+	dbo.BaseDA
+}
+
+func (a *assessmentRubricCriterionDA) DeleteByRubricIDTx(ctx context.Context, tx *dbo.DBContext, rubricID string, deleteAt int64) error {
+	tx.ResetCondition()
+
+	if err := tx.Model(&v2.AssessmentRubricCriterion{}).
+		Where("rubric_id = ? and delete_at = 0", rubricID).
+		Update("delete_at", deleteAt).Error; err != nil {
+		log.Error(ctx, "delete rubric criteria failed", log.Err(err), log.String("rubricID", rubricID))
+		return err
+	}
+
+	return nil
+}
+
+type assessmentRubricLevelDA struct {
+	dbo.BaseDA
+}
+
+func (a *assessmentRubricLevelDA) DeleteByRubricIDTx(ctx context.Context, tx *dbo.DBContext, rubricID string, deleteAt int64) error {
+	tx.ResetCondition()
+
+	if err := tx.Model(&v2.AssessmentRubricLevel{}).
+		Where("rubric_id = ? and delete_at = 0", rubricID).
+		Update("delete_at", deleteAt).Error; err != nil {
+		log.Error(ctx, "delete rubric levels failed", log.Err(err), log.String("rubricID", rubricID))
+		return err
+	}
+
+	return nil
+}
+
+var (
+	_assessmentRubricCriterionOnce sync.Once
+	_assessmentRubricCriterionDA   IAssessmentRubricItemDA
+
+	_assessmentRubricLevelOnce sync.Once
+	_assessmentRubricLevelDA   IAssessmentRubricItemDA
+)
+
+func GetAssessmentRubricCriterionDA() IAssessmentRubricItemDA {
+	_assessmentRubricCriterionOnce.Do(func() {
+		_assessmentRubricCriterionDA = &assessmentRubricCriterionDA{}
+	})
+	return _assessmentRubricCriterionDA
+}
+
+func GetAssessmentRubricLevelDA() IAssessmentRubricItemDA {
+	_assessmentRubricLevelOnce.Do(func() {
+		_assessmentRubricLevelDA = &assessmentRubricLevelDA{}
+	})
+	return _assessmentRubricLevelDA
+}
+
+type AssessmentRubricItemCondition struct {
+	RubricIDs entity.NullStrings
+}
+
+func (c AssessmentRubricItemCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.RubricIDs.Valid {
+		wheres = append(wheres, "rubric_id in (?)")
+		params = append(params, c.RubricIDs.Strings)
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c AssessmentRubricItemCondition) GetOrderBy() string {
+	return "sort_index"
+}
+
+func (c AssessmentRubricItemCondition) GetPager() *dbo.Pager {
+	return &dbo.NoPager
+}
+
+// relations
+
+type IAssessmentRubricRelationDA interface {
+	dbo.DataAccesser
+}
+
+type assessmentRubricRelationDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_assessmentRubricRelationOnce sync.Once
+	_assessmentRubricRelationDA   IAssessmentRubricRelationDA
+)
+
+func GetAssessmentRubricRelationDA() IAssessmentRubricRelationDA {
+	_assessmentRubricRelationOnce.Do(func() {
+		_assessmentRubricRelationDA = &assessmentRubricRelationDA{}
+	})
+	return _assessmentRubricRelationDA
+}
+
+type AssessmentRubricRelationCondition struct {
+	OrgID        sql.NullString
+	RubricIDs    entity.NullStrings
+	RelationType sql.NullString
+	RelationIDs  entity.NullStrings
+}
+
+func (c AssessmentRubricRelationCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.RubricIDs.Valid {
+		wheres = append(wheres, "rubric_id in (?)")
+		params = append(params, c.RubricIDs.Strings)
+	}
+
+	if c.RelationType.Valid {
+		wheres = append(wheres, "relation_type = ?")
+		params = append(params, c.RelationType.String)
+	}
+
+	if c.RelationIDs.Valid {
+		wheres = append(wheres, "relation_id in (?)")
+		params = append(params, c.RelationIDs.Strings)
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c AssessmentRubricRelationCondition) GetOrderBy() string {
+	return "create_at desc"
+}
+
+func (c AssessmentRubricRelationCondition) GetPager() *dbo.Pager {
+	return &dbo.NoPager
+}
+
+// student scores
+
+type IAssessmentUserRubricScoreDA interface {
+	dbo.DataAccesser
+}
+
+type assessmentUserRubricScoreDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_assessmentUserRubricScoreOnce sync.Once
+	_assessmentUserRubricScoreDA   IAssessmentUserRubricScoreDA
+)
+
+func GetAssessmentUserRubricScoreDA() IAssessmentUserRubricScoreDA {
+	_assessmentUserRubricScoreOnce.Do(func() {
+		_assessmentUserRubricScoreDA = &assessmentUserRubricScoreDA{}
+	})
+	return _assessmentUserRubricScoreDA
+}
+
+type AssessmentUserRubricScoreCondition struct {
+	AssessmentUserIDs entity.NullStrings
+	RubricID          sql.NullString
+}
+
+func (c AssessmentUserRubricScoreCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.AssessmentUserIDs.Valid {
+		wheres = append(wheres, "assessment_user_id in (?)")
+		params = append(params, c.AssessmentUserIDs.Strings)
+	}
+
+	if c.RubricID.Valid {
+		wheres = append(wheres, "rubric_id = ?")
+		params = append(params, c.RubricID.String)
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c AssessmentUserRubricScoreCondition) GetOrderBy() string {
+	return ""
+}
+
+func (c AssessmentUserRubricScoreCondition) GetPager() *dbo.Pager {
+	return &dbo.NoPager
+}
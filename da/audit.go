@@ -0,0 +1,186 @@
+package da
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type IAuditLogDA interface {
+	dbo.DataAccesser
+	// Purge delete at most limit logs of the org created before, returns the number deleted
+	Purge(ctx context.Context, orgID string, before int64, limit int) (int64, error)
+	// PurgeOthers same as Purge, for all organizations except the given ones
+	PurgeOthers(ctx context.Context, excludeOrgIDs []string, before int64, limit int) (int64, error)
+}
+
+type auditLogDA struct {
+	dbo.BaseDA
+}
+
+func (d *auditLogDA) Purge(ctx context.Context, orgID string, before int64, limit int) (int64, error) {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	query := fmt.Sprintf("delete from %s where org_id = ? and create_at < ? limit ?", constant.TableNameAuditLog)
+	result := tx.Exec(query, orgID, before, limit)
+	if result.Error != nil {
+		log.Error(ctx, "purge audit logs failed", log.Err(result.Error), log.String("org_id", orgID), log.Int64("before", before))
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (d *auditLogDA) PurgeOthers(ctx context.Context, excludeOrgIDs []string, before int64, limit int) (int64, error) {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	query := fmt.Sprintf("delete from %s where create_at < ? limit ?", constant.TableNameAuditLog)
+	params := []interface{}{before, limit}
+	if len(excludeOrgIDs) > 0 {
+		query = fmt.Sprintf("delete from %s where org_id not in (?) and create_at < ? limit ?", constant.TableNameAuditLog)
+		params = []interface{}{excludeOrgIDs, before, limit}
+	}
+
+	result := tx.Exec(query, params...)
+	if result.Error != nil {
+		log.Error(ctx, "purge audit logs of other organizations failed", log.Err(result.Error), log.Int64("before", before))
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+var (
+	_auditLogOnce sync.Once
+	_auditLogDA   IAuditLogDA
+)
+
+func GetAuditLogDA() IAuditLogDA {
+	_auditLogOnce.Do(func() {
+		_auditLogDA = &auditLogDA{}
+	})
+	return _auditLogDA
+}
+
+type AuditLogCondition struct {
+	OrgID      sql.NullString
+	ActorID    sql.NullString
+	Action     sql.NullString
+	TargetType sql.NullString
+	TargetID   sql.NullString
+	Method     sql.NullString
+	CreateAtGe sql.NullInt64
+	CreateAtLe sql.NullInt64
+
+	Pager dbo.Pager
+}
+
+func (c AuditLogCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.ActorID.Valid {
+		wheres = append(wheres, "actor_id = ?")
+		params = append(params, c.ActorID.String)
+	}
+
+	if c.Action.Valid {
+		wheres = append(wheres, "action = ?")
+		params = append(params, c.Action.String)
+	}
+
+	if c.TargetType.Valid {
+		wheres = append(wheres, "target_type = ?")
+		params = append(params, c.TargetType.String)
+	}
+
+	if c.TargetID.Valid {
+		wheres = append(wheres, "target_id = ?")
+		params = append(params, c.TargetID.String)
+	}
+
+	if c.Method.Valid {
+		wheres = append(wheres, "method = ?")
+		params = append(params, c.Method.String)
+	}
+
+	if c.CreateAtGe.Valid {
+		wheres = append(wheres, "create_at >= ?")
+		params = append(params, c.CreateAtGe.Int64)
+	}
+
+	if c.CreateAtLe.Valid {
+		wheres = append(wheres, "create_at <= ?")
+		params = append(params, c.CreateAtLe.Int64)
+	}
+
+	return wheres, params
+}
+
+func (c AuditLogCondition) GetOrderBy() string {
+	return "create_at desc, id desc"
+}
+
+func (c AuditLogCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
+
+type IAuditSettingDA interface {
+	dbo.DataAccesser
+}
+
+type auditSettingDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_auditSettingOnce sync.Once
+	_auditSettingDA   IAuditSettingDA
+)
+
+func GetAuditSettingDA() IAuditSettingDA {
+	_auditSettingOnce.Do(func() {
+		_auditSettingDA = &auditSettingDA{}
+	})
+	return _auditSettingDA
+}
+
+type AuditSettingCondition struct {
+	OrgIDs entity.NullStrings
+
+	Pager dbo.Pager
+}
+
+func (c AuditSettingCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.OrgIDs.Valid {
+		wheres = append(wheres, "org_id in (?)")
+		params = append(params, c.OrgIDs.Strings)
+	}
+
+	return wheres, params
+}
+
+func (c AuditSettingCondition) GetOrderBy() string {
+	return "org_id"
+}
+
+func (c AuditSettingCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
@@ -0,0 +1,581 @@
+package da
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+// IDirectoryDA the local directory, which replaces ams in standalone deployments
+type IDirectoryDA interface {
+	dbo.DataAccesser
+	// UpsertTx rows of users, organizations, schools, classes, roles, programs or subjects by id
+	UpsertTx(ctx context.Context, tx *dbo.DBContext, rows []interface{}) error
+	// DeactivateTx set the status of rows of a non relation type to inactive
+	DeactivateTx(ctx context.Context, tx *dbo.DBContext, directoryType entity.DirectoryType, ids []string) (int64, error)
+	InsertRelationsTx(ctx context.Context, tx *dbo.DBContext, directoryType entity.DirectoryType, rows []interface{}) error
+	DeleteRelationsTx(ctx context.Context, tx *dbo.DBContext, directoryType entity.DirectoryType, rows []interface{}) (int64, error)
+
+	GetUsers(ctx context.Context, ids []string) ([]*entity.DirectoryUser, error)
+	GetUsersByEmail(ctx context.Context, email string) ([]*entity.DirectoryUser, error)
+	// GetMembers active users of the organization, whose names or emails contain the keyword if it is not empty.
+	// Users in any school or class of the organization are excluded if onlyUnderOrg.
+	GetMembers(ctx context.Context, orgID string, keyword string, onlyUnderOrg bool) ([]*entity.DirectoryUser, error)
+	CountMembers(ctx context.Context, condition *entity.GetUserCountCondition) (int, error)
+
+	GetOrganizations(ctx context.Context, ids []string) ([]*entity.DirectoryOrganization, error)
+
+	GetSchools(ctx context.Context, ids []string) ([]*entity.DirectorySchool, error)
+	GetSchoolsByOrganization(ctx context.Context, orgID string) ([]*entity.DirectorySchool, error)
+	GetSchoolsByClasses(ctx context.Context, classIDs []string) ([]*entity.DirectoryRelatedSchool, error)
+	GetSchoolsByUsers(ctx context.Context, orgID string, userIDs []string) ([]*entity.DirectoryRelatedSchool, error)
+
+	GetClasses(ctx context.Context, ids []string) ([]*entity.DirectoryClass, error)
+	GetClassesByUsers(ctx context.Context, userIDs []string) ([]*entity.DirectoryClassMembership, error)
+	GetClassesByOrganizations(ctx context.Context, orgIDs []string) ([]*entity.DirectoryRelatedClass, error)
+	GetClassesBySchools(ctx context.Context, schoolIDs []string) ([]*entity.DirectoryRelatedClass, error)
+	// GetClassMembers active users joining the classes as the join type, teaching or studying
+	GetClassMembers(ctx context.Context, classIDs []string, joinType string) ([]*entity.DirectoryClassMemberUser, error)
+
+	// GetRoleByName roles of the organization or system roles
+	GetRoleByName(ctx context.Context, orgID string, name string) ([]*entity.DirectoryRole, error)
+	// GetMemberPermissions permissions of the active roles of the user, in all organizations when orgIDs is empty
+	GetMemberPermissions(ctx context.Context, userID string, orgIDs []string, permissionNames []string) ([]*entity.DirectoryMemberPermission, error)
+
+	GetPrograms(ctx context.Context, ids []string) ([]*entity.DirectoryProgram, error)
+	// GetProgramsByOrganization programs of the organization and system programs
+	GetProgramsByOrganization(ctx context.Context, orgID string) ([]*entity.DirectoryProgram, error)
+
+	GetSubjects(ctx context.Context, ids []string) ([]*entity.DirectorySubject, error)
+	// GetSubjectsByOrganization subjects of the organization and system subjects
+	GetSubjectsByOrganization(ctx context.Context, orgID string) ([]*entity.DirectorySubject, error)
+	GetSubjectsByProgram(ctx context.Context, programID string) ([]*entity.DirectorySubject, error)
+}
+
+type directoryDA struct {
+	dbo.BaseDA
+}
+
+var directoryTables = map[entity.DirectoryType]string{
+	entity.DirectoryTypeUser:           constant.TableNameDirectoryUser,
+	entity.DirectoryTypeOrganization:   constant.TableNameDirectoryOrganization,
+	entity.DirectoryTypeSchool:         constant.TableNameDirectorySchool,
+	entity.DirectoryTypeClass:          constant.TableNameDirectoryClass,
+	entity.DirectoryTypeRole:           constant.TableNameDirectoryRole,
+	entity.DirectoryTypeProgram:        constant.TableNameDirectoryProgram,
+	entity.DirectoryTypeSubject:        constant.TableNameDirectorySubject,
+	entity.DirectoryTypeMembership:     constant.TableNameDirectoryMembership,
+	entity.DirectoryTypeMembershipRole: constant.TableNameDirectoryMembershipRole,
+	entity.DirectoryTypeRolePermission: constant.TableNameDirectoryRolePermission,
+	entity.DirectoryTypeClassSchool:    constant.TableNameDirectoryClassSchool,
+	entity.DirectoryTypeClassMember:    constant.TableNameDirectoryClassMember,
+	entity.DirectoryTypeProgramSubject: constant.TableNameDirectoryProgramSubject,
+}
+
+func (d *directoryDA) UpsertTx(ctx context.Context, tx *dbo.DBContext, rows []interface{}) error {
+	now := time.Now().Unix()
+	for _, row := range rows {
+		switch r := row.(type) {
+		case *entity.DirectoryUser:
+			r.CreateAt, r.UpdateAt = now, now
+		case *entity.DirectoryOrganization:
+			r.CreateAt, r.UpdateAt = now, now
+		case *entity.DirectorySchool:
+			r.CreateAt, r.UpdateAt = now, now
+		case *entity.DirectoryClass:
+			r.CreateAt, r.UpdateAt = now, now
+		case *entity.DirectoryRole:
+			r.CreateAt, r.UpdateAt = now, now
+		case *entity.DirectoryProgram:
+			r.CreateAt, r.UpdateAt = now, now
+		case *entity.DirectorySubject:
+			r.CreateAt, r.UpdateAt = now, now
+		default:
+			return fmt.Errorf("upsert directory: %w: unsupported row %T", constant.ErrInvalidArgs, row)
+		}
+
+		// create_at of existing rows is kept
+		tx.ResetCondition()
+		result := tx.Set("gorm:insert_option", "ON DUPLICATE KEY UPDATE "+directoryUpsertColumns(row)).Create(row)
+		if result.Error != nil {
+			log.Error(ctx, "upsert directory row failed", log.Err(result.Error), log.Any("row", row))
+			return result.Error
+		}
+	}
+	return nil
+}
+
+func directoryUpsertColumns(row interface{}) string {
+	var columns []string
+	switch row.(type) {
+	case *entity.DirectoryUser:
+		columns = entity.DirectoryColumns(entity.DirectoryTypeUser)
+	case *entity.DirectoryOrganization:
+		columns = entity.DirectoryColumns(entity.DirectoryTypeOrganization)
+	case *entity.DirectorySchool:
+		columns = entity.DirectoryColumns(entity.DirectoryTypeSchool)
+	case *entity.DirectoryClass:
+		columns = entity.DirectoryColumns(entity.DirectoryTypeClass)
+	case *entity.DirectoryRole:
+		columns = entity.DirectoryColumns(entity.DirectoryTypeRole)
+	case *entity.DirectoryProgram:
+		columns = entity.DirectoryColumns(entity.DirectoryTypeProgram)
+	case *entity.DirectorySubject:
+		columns = entity.DirectoryColumns(entity.DirectoryTypeSubject)
+	}
+
+	updates := make([]string, 0, len(columns))
+	for _, column := range append(columns, "update_at") {
+		if column == "id" {
+			continue
+		}
+		updates = append(updates, fmt.Sprintf("`%s` = values(`%s`)", column, column))
+	}
+	return strings.Join(updates, ", ")
+}
+
+func (d *directoryDA) DeactivateTx(ctx context.Context, tx *dbo.DBContext, directoryType entity.DirectoryType, ids []string) (int64, error) {
+	table, ok := directoryTables[directoryType]
+	if !ok || directoryType.IsRelation() {
+		return 0, fmt.Errorf("deactivate directory: %w: unsupported type %s", constant.ErrInvalidArgs, directoryType)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	tx.ResetCondition()
+	query := fmt.Sprintf("update %s set status = ?, update_at = ? where id in (?)", table)
+	result := tx.Exec(query, entity.DirectoryStatusInactive, time.Now().Unix(), ids)
+	if result.Error != nil {
+		log.Error(ctx, "deactivate directory rows failed", log.Err(result.Error), log.String("type", string(directoryType)), log.Strings("ids", ids))
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+func (d *directoryDA) InsertRelationsTx(ctx context.Context, tx *dbo.DBContext, directoryType entity.DirectoryType, rows []interface{}) error {
+	table, ok := directoryTables[directoryType]
+	if !ok || !directoryType.IsRelation() {
+		return fmt.Errorf("insert directory relations: %w: unsupported type %s", constant.ErrInvalidArgs, directoryType)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	columns := entity.DirectoryColumns(directoryType)
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ")"
+	placeholders := make([]string, 0, len(rows))
+	params := make([]interface{}, 0, len(rows)*len(columns))
+	for _, row := range rows {
+		placeholders = append(placeholders, placeholder)
+		params = append(params, entity.DirectoryRelationValues(row)...)
+	}
+
+	tx.ResetCondition()
+	query := fmt.Sprintf("insert ignore into %s (%s) values %s", table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	err := tx.Exec(query, params...).Error
+	if err != nil {
+		log.Error(ctx, "insert directory relations failed", log.Err(err), log.String("type", string(directoryType)), log.Any("rows", rows))
+		return err
+	}
+	return nil
+}
+
+func (d *directoryDA) DeleteRelationsTx(ctx context.Context, tx *dbo.DBContext, directoryType entity.DirectoryType, rows []interface{}) (int64, error) {
+	table, ok := directoryTables[directoryType]
+	if !ok || !directoryType.IsRelation() {
+		return 0, fmt.Errorf("delete directory relations: %w: unsupported type %s", constant.ErrInvalidArgs, directoryType)
+	}
+
+	columns := entity.DirectoryColumns(directoryType)
+	wheres := make([]string, 0, len(columns))
+	for _, column := range columns {
+		wheres = append(wheres, column+" = ?")
+	}
+	query := fmt.Sprintf("delete from %s where %s", table, strings.Join(wheres, " and "))
+
+	var deleted int64
+	for _, row := range rows {
+		tx.ResetCondition()
+		result := tx.Exec(query, entity.DirectoryRelationValues(row)...)
+		if result.Error != nil {
+			log.Error(ctx, "delete directory relation failed", log.Err(result.Error), log.String("type", string(directoryType)), log.Any("row", row))
+			return 0, result.Error
+		}
+		deleted += result.RowsAffected
+	}
+	return deleted, nil
+}
+
+func (d *directoryDA) GetUsers(ctx context.Context, ids []string) ([]*entity.DirectoryUser, error) {
+	var users []*entity.DirectoryUser
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	query := fmt.Sprintf("select * from %s where id in (?)", constant.TableNameDirectoryUser)
+	err := d.QueryRawSQL(ctx, &users, query, ids)
+	if err != nil {
+		log.Error(ctx, "get directory users failed", log.Err(err), log.Strings("ids", ids))
+		return nil, err
+	}
+	return users, nil
+}
+
+func (d *directoryDA) GetUsersByEmail(ctx context.Context, email string) ([]*entity.DirectoryUser, error) {
+	var users []*entity.DirectoryUser
+	query := fmt.Sprintf("select * from %s where email = ?", constant.TableNameDirectoryUser)
+	err := d.QueryRawSQL(ctx, &users, query, email)
+	if err != nil {
+		log.Error(ctx, "get directory users by email failed", log.Err(err), log.String("email", email))
+		return nil, err
+	}
+	return users, nil
+}
+
+func (d *directoryDA) GetMembers(ctx context.Context, orgID string, keyword string, onlyUnderOrg bool) ([]*entity.DirectoryUser, error) {
+	query := fmt.Sprintf(`select u.* from %s u
+inner join %s m on m.user_id = u.id and m.org_id = ? and m.school_id = ''
+where u.status = ?`, constant.TableNameDirectoryUser, constant.TableNameDirectoryMembership)
+	params := []interface{}{orgID, entity.DirectoryStatusActive}
+
+	if keyword != "" {
+		query += " and (concat(u.given_name, ' ', u.family_name) like ? or u.email like ?)"
+		like := "%" + keyword + "%"
+		params = append(params, like, like)
+	}
+	if onlyUnderOrg {
+		query += fmt.Sprintf(` and not exists (select 1 from %s s where s.user_id = u.id and s.org_id = ? and s.school_id != '')
+and not exists (select 1 from %s cm inner join %s c on c.id = cm.class_id where cm.user_id = u.id and c.org_id = ?)`,
+			constant.TableNameDirectoryMembership, constant.TableNameDirectoryClassMember, constant.TableNameDirectoryClass)
+		params = append(params, orgID, orgID)
+	}
+	query += " order by u.id"
+
+	var users []*entity.DirectoryUser
+	err := d.QueryRawSQL(ctx, &users, query, params...)
+	if err != nil {
+		log.Error(ctx, "get directory members failed", log.Err(err), log.String("org_id", orgID), log.String("keyword", keyword))
+		return nil, err
+	}
+	return users, nil
+}
+
+func (d *directoryDA) CountMembers(ctx context.Context, condition *entity.GetUserCountCondition) (int, error) {
+	wheres := []string{"u.status = ?"}
+	params := []interface{}{entity.DirectoryStatusActive}
+	if condition.OrgID.Valid {
+		wheres = append(wheres, fmt.Sprintf("exists (select 1 from %s m where m.user_id = u.id and m.org_id = ?)", constant.TableNameDirectoryMembership))
+		params = append(params, condition.OrgID.String)
+	}
+	if condition.RoleID.Valid {
+		wheres = append(wheres, fmt.Sprintf("exists (select 1 from %s r where r.user_id = u.id and r.role_id = ?)", constant.TableNameDirectoryMembershipRole))
+		params = append(params, condition.RoleID.String)
+	}
+	if condition.SchoolIDs.Valid {
+		wheres = append(wheres, fmt.Sprintf("exists (select 1 from %s s where s.user_id = u.id and s.school_id in (?))", constant.TableNameDirectoryMembership))
+		params = append(params, condition.SchoolIDs.Strings)
+	}
+	if condition.ClassIDs.Valid {
+		wheres = append(wheres, fmt.Sprintf("exists (select 1 from %s c where c.user_id = u.id and c.class_id in (?))", constant.TableNameDirectoryClassMember))
+		params = append(params, condition.ClassIDs.Strings)
+	}
+
+	var result struct {
+		Total int `gorm:"column:total"`
+	}
+	query := fmt.Sprintf("select count(*) as total from %s u where %s", constant.TableNameDirectoryUser, strings.Join(wheres, " and "))
+	err := d.QueryRawSQL(ctx, &result, query, params...)
+	if err != nil {
+		log.Error(ctx, "count directory members failed", log.Err(err), log.Any("condition", condition))
+		return 0, err
+	}
+	return result.Total, nil
+}
+
+func (d *directoryDA) GetOrganizations(ctx context.Context, ids []string) ([]*entity.DirectoryOrganization, error) {
+	var organizations []*entity.DirectoryOrganization
+	if len(ids) == 0 {
+		return organizations, nil
+	}
+
+	query := fmt.Sprintf("select * from %s where id in (?)", constant.TableNameDirectoryOrganization)
+	err := d.QueryRawSQL(ctx, &organizations, query, ids)
+	if err != nil {
+		log.Error(ctx, "get directory organizations failed", log.Err(err), log.Strings("ids", ids))
+		return nil, err
+	}
+	return organizations, nil
+}
+
+func (d *directoryDA) GetSchools(ctx context.Context, ids []string) ([]*entity.DirectorySchool, error) {
+	var schools []*entity.DirectorySchool
+	if len(ids) == 0 {
+		return schools, nil
+	}
+
+	query := fmt.Sprintf("select * from %s where id in (?)", constant.TableNameDirectorySchool)
+	err := d.QueryRawSQL(ctx, &schools, query, ids)
+	if err != nil {
+		log.Error(ctx, "get directory schools failed", log.Err(err), log.Strings("ids", ids))
+		return nil, err
+	}
+	return schools, nil
+}
+
+func (d *directoryDA) GetSchoolsByOrganization(ctx context.Context, orgID string) ([]*entity.DirectorySchool, error) {
+	var schools []*entity.DirectorySchool
+	query := fmt.Sprintf("select * from %s where org_id = ? order by name", constant.TableNameDirectorySchool)
+	err := d.QueryRawSQL(ctx, &schools, query, orgID)
+	if err != nil {
+		log.Error(ctx, "get directory schools by organization failed", log.Err(err), log.String("org_id", orgID))
+		return nil, err
+	}
+	return schools, nil
+}
+
+func (d *directoryDA) GetSchoolsByClasses(ctx context.Context, classIDs []string) ([]*entity.DirectoryRelatedSchool, error) {
+	var schools []*entity.DirectoryRelatedSchool
+	if len(classIDs) == 0 {
+		return schools, nil
+	}
+
+	query := fmt.Sprintf(`select cs.class_id as related_id, s.* from %s cs
+inner join %s s on s.id = cs.school_id
+where cs.class_id in (?)`, constant.TableNameDirectoryClassSchool, constant.TableNameDirectorySchool)
+	err := d.QueryRawSQL(ctx, &schools, query, classIDs)
+	if err != nil {
+		log.Error(ctx, "get directory schools by classes failed", log.Err(err), log.Strings("class_ids", classIDs))
+		return nil, err
+	}
+	return schools, nil
+}
+
+func (d *directoryDA) GetSchoolsByUsers(ctx context.Context, orgID string, userIDs []string) ([]*entity.DirectoryRelatedSchool, error) {
+	var schools []*entity.DirectoryRelatedSchool
+	if len(userIDs) == 0 {
+		return schools, nil
+	}
+
+	query := fmt.Sprintf(`select m.user_id as related_id, s.* from %s m
+inner join %s s on s.id = m.school_id
+where m.org_id = ? and m.user_id in (?)`, constant.TableNameDirectoryMembership, constant.TableNameDirectorySchool)
+	err := d.QueryRawSQL(ctx, &schools, query, orgID, userIDs)
+	if err != nil {
+		log.Error(ctx, "get directory schools by users failed", log.Err(err), log.String("org_id", orgID), log.Strings("user_ids", userIDs))
+		return nil, err
+	}
+	return schools, nil
+}
+
+func (d *directoryDA) GetClasses(ctx context.Context, ids []string) ([]*entity.DirectoryClass, error) {
+	var classes []*entity.DirectoryClass
+	if len(ids) == 0 {
+		return classes, nil
+	}
+
+	query := fmt.Sprintf("select * from %s where id in (?)", constant.TableNameDirectoryClass)
+	err := d.QueryRawSQL(ctx, &classes, query, ids)
+	if err != nil {
+		log.Error(ctx, "get directory classes failed", log.Err(err), log.Strings("ids", ids))
+		return nil, err
+	}
+	return classes, nil
+}
+
+func (d *directoryDA) GetClassesByUsers(ctx context.Context, userIDs []string) ([]*entity.DirectoryClassMembership, error) {
+	var classes []*entity.DirectoryClassMembership
+	if len(userIDs) == 0 {
+		return classes, nil
+	}
+
+	query := fmt.Sprintf(`select cm.user_id, cm.join_type, c.* from %s cm
+inner join %s c on c.id = cm.class_id
+where cm.user_id in (?)`, constant.TableNameDirectoryClassMember, constant.TableNameDirectoryClass)
+	err := d.QueryRawSQL(ctx, &classes, query, userIDs)
+	if err != nil {
+		log.Error(ctx, "get directory classes by users failed", log.Err(err), log.Strings("user_ids", userIDs))
+		return nil, err
+	}
+	return classes, nil
+}
+
+func (d *directoryDA) GetClassesByOrganizations(ctx context.Context, orgIDs []string) ([]*entity.DirectoryRelatedClass, error) {
+	var classes []*entity.DirectoryRelatedClass
+	if len(orgIDs) == 0 {
+		return classes, nil
+	}
+
+	query := fmt.Sprintf("select org_id as related_id, c.* from %s c where c.org_id in (?)", constant.TableNameDirectoryClass)
+	err := d.QueryRawSQL(ctx, &classes, query, orgIDs)
+	if err != nil {
+		log.Error(ctx, "get directory classes by organizations failed", log.Err(err), log.Strings("org_ids", orgIDs))
+		return nil, err
+	}
+	return classes, nil
+}
+
+func (d *directoryDA) GetClassesBySchools(ctx context.Context, schoolIDs []string) ([]*entity.DirectoryRelatedClass, error) {
+	var classes []*entity.DirectoryRelatedClass
+	if len(schoolIDs) == 0 {
+		return classes, nil
+	}
+
+	query := fmt.Sprintf(`select cs.school_id as related_id, c.* from %s cs
+inner join %s c on c.id = cs.class_id
+where cs.school_id in (?)`, constant.TableNameDirectoryClassSchool, constant.TableNameDirectoryClass)
+	err := d.QueryRawSQL(ctx, &classes, query, schoolIDs)
+	if err != nil {
+		log.Error(ctx, "get directory classes by schools failed", log.Err(err), log.Strings("school_ids", schoolIDs))
+		return nil, err
+	}
+	return classes, nil
+}
+
+func (d *directoryDA) GetClassMembers(ctx context.Context, classIDs []string, joinType string) ([]*entity.DirectoryClassMemberUser, error) {
+	var users []*entity.DirectoryClassMemberUser
+	if len(classIDs) == 0 {
+		return users, nil
+	}
+
+	query := fmt.Sprintf(`select cm.class_id, u.* from %s cm
+inner join %s u on u.id = cm.user_id
+where cm.class_id in (?) and cm.join_type = ? and u.status = ?
+order by u.id`, constant.TableNameDirectoryClassMember, constant.TableNameDirectoryUser)
+	err := d.QueryRawSQL(ctx, &users, query, classIDs, joinType, entity.DirectoryStatusActive)
+	if err != nil {
+		log.Error(ctx, "get directory class members failed", log.Err(err), log.Strings("class_ids", classIDs), log.String("join_type", joinType))
+		return nil, err
+	}
+	return users, nil
+}
+
+func (d *directoryDA) GetRoleByName(ctx context.Context, orgID string, name string) ([]*entity.DirectoryRole, error) {
+	var roles []*entity.DirectoryRole
+	// roles of the organization come first
+	query := fmt.Sprintf("select * from %s where name = ? and org_id in (?, '') order by org_id desc", constant.TableNameDirectoryRole)
+	err := d.QueryRawSQL(ctx, &roles, query, name, orgID)
+	if err != nil {
+		log.Error(ctx, "get directory role by name failed", log.Err(err), log.String("org_id", orgID), log.String("name", name))
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (d *directoryDA) GetMemberPermissions(ctx context.Context, userID string, orgIDs []string, permissionNames []string) ([]*entity.DirectoryMemberPermission, error) {
+	query := fmt.Sprintf(`select distinct mr.org_id, mr.school_id, rp.permission_name from %s mr
+inner join %s r on r.id = mr.role_id and r.status = ?
+inner join %s rp on rp.role_id = mr.role_id
+inner join %s m on m.org_id = mr.org_id and m.school_id = '' and m.user_id = mr.user_id
+where mr.user_id = ?`,
+		constant.TableNameDirectoryMembershipRole,
+		constant.TableNameDirectoryRole,
+		constant.TableNameDirectoryRolePermission,
+		constant.TableNameDirectoryMembership)
+	params := []interface{}{entity.DirectoryStatusActive, userID}
+	if len(orgIDs) > 0 {
+		query += " and mr.org_id in (?)"
+		params = append(params, orgIDs)
+	}
+	if len(permissionNames) > 0 {
+		query += " and rp.permission_name in (?)"
+		params = append(params, permissionNames)
+	}
+
+	var permissions []*entity.DirectoryMemberPermission
+	err := d.QueryRawSQL(ctx, &permissions, query, params...)
+	if err != nil {
+		log.Error(ctx, "get directory member permissions failed",
+			log.Err(err),
+			log.String("user_id", userID),
+			log.Strings("org_ids", orgIDs),
+			log.Strings("permission_names", permissionNames))
+		return nil, err
+	}
+	return permissions, nil
+}
+
+func (d *directoryDA) GetPrograms(ctx context.Context, ids []string) ([]*entity.DirectoryProgram, error) {
+	var programs []*entity.DirectoryProgram
+	if len(ids) == 0 {
+		return programs, nil
+	}
+
+	query := fmt.Sprintf("select * from %s where id in (?)", constant.TableNameDirectoryProgram)
+	err := d.QueryRawSQL(ctx, &programs, query, ids)
+	if err != nil {
+		log.Error(ctx, "get directory programs failed", log.Err(err), log.Strings("ids", ids))
+		return nil, err
+	}
+	return programs, nil
+}
+
+func (d *directoryDA) GetProgramsByOrganization(ctx context.Context, orgID string) ([]*entity.DirectoryProgram, error) {
+	var programs []*entity.DirectoryProgram
+	query := fmt.Sprintf("select * from %s where org_id in (?, '') order by name", constant.TableNameDirectoryProgram)
+	err := d.QueryRawSQL(ctx, &programs, query, orgID)
+	if err != nil {
+		log.Error(ctx, "get directory programs by organization failed", log.Err(err), log.String("org_id", orgID))
+		return nil, err
+	}
+	return programs, nil
+}
+
+func (d *directoryDA) GetSubjects(ctx context.Context, ids []string) ([]*entity.DirectorySubject, error) {
+	var subjects []*entity.DirectorySubject
+	if len(ids) == 0 {
+		return subjects, nil
+	}
+
+	query := fmt.Sprintf("select * from %s where id in (?)", constant.TableNameDirectorySubject)
+	err := d.QueryRawSQL(ctx, &subjects, query, ids)
+	if err != nil {
+		log.Error(ctx, "get directory subjects failed", log.Err(err), log.Strings("ids", ids))
+		return nil, err
+	}
+	return subjects, nil
+}
+
+func (d *directoryDA) GetSubjectsByOrganization(ctx context.Context, orgID string) ([]*entity.DirectorySubject, error) {
+	var subjects []*entity.DirectorySubject
+	query := fmt.Sprintf("select * from %s where org_id in (?, '') order by name", constant.TableNameDirectorySubject)
+	err := d.QueryRawSQL(ctx, &subjects, query, orgID)
+	if err != nil {
+		log.Error(ctx, "get directory subjects by organization failed", log.Err(err), log.String("org_id", orgID))
+		return nil, err
+	}
+	return subjects, nil
+}
+
+func (d *directoryDA) GetSubjectsByProgram(ctx context.Context, programID string) ([]*entity.DirectorySubject, error) {
+	var subjects []*entity.DirectorySubject
+	query := fmt.Sprintf(`select s.* from %s ps
+inner join %s s on s.id = ps.subject_id
+where ps.program_id = ? order by s.name`, constant.TableNameDirectoryProgramSubject, constant.TableNameDirectorySubject)
+	err := d.QueryRawSQL(ctx, &subjects, query, programID)
+	if err != nil {
+		log.Error(ctx, "get directory subjects by program failed", log.Err(err), log.String("program_id", programID))
+		return nil, err
+	}
+	return subjects, nil
+}
+
+var (
+	_directoryOnce sync.Once
+	_directoryDA   IDirectoryDA
+)
+
+func GetDirectoryDA() IDirectoryDA {
+	_directoryOnce.Do(func() {
+		_directoryDA = &directoryDA{}
+	})
+	return _directoryDA
+}
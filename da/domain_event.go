@@ -0,0 +1,144 @@
+package da
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type IDomainEventDA interface {
+	dbo.DataAccesser
+	// MarkDispatched move a pending event to dispatched, false when another instance has taken it
+	MarkDispatched(ctx context.Context, id string, now int64) (bool, error)
+}
+
+type domainEventDA struct {
+	dbo.BaseDA
+}
+
+func (d *domainEventDA) MarkDispatched(ctx context.Context, id string, now int64) (bool, error) {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	result := tx.Model(&entity.DomainEvent{}).
+		Where("id = ? and status = ?", id, entity.DomainEventStatusPending).
+		Updates(map[string]interface{}{
+			"status":    entity.DomainEventStatusDispatched,
+			"update_at": now,
+		})
+	if result.Error != nil {
+		log.Error(ctx, "mark domain event dispatched failed", log.Err(result.Error), log.String("id", id))
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+var (
+	_domainEventOnce sync.Once
+	_domainEventDA   IDomainEventDA
+)
+
+func GetDomainEventDA() IDomainEventDA {
+	_domainEventOnce.Do(func() {
+		_domainEventDA = &domainEventDA{}
+	})
+	return _domainEventDA
+}
+
+type DomainEventCondition struct {
+	Topic  sql.NullString
+	Status sql.NullString
+	// Due pending events to dispatch, and dispatched events whose handlers never reported back
+	Due             sql.NullInt64
+	DispatchedSince sql.NullInt64
+
+	OrderBy string
+	Pager   dbo.Pager
+}
+
+func (c DomainEventCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.Topic.Valid {
+		wheres = append(wheres, "topic = ?")
+		params = append(params, c.Topic.String)
+	}
+
+	if c.Status.Valid {
+		wheres = append(wheres, "status = ?")
+		params = append(params, c.Status.String)
+	}
+
+	if c.Due.Valid && c.DispatchedSince.Valid {
+		wheres = append(wheres, "((status = ? and next_attempt_at <= ?) or (status = ? and update_at <= ?))")
+		params = append(params,
+			entity.DomainEventStatusPending, c.Due.Int64,
+			entity.DomainEventStatusDispatched, c.DispatchedSince.Int64)
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c DomainEventCondition) GetOrderBy() string {
+	if c.OrderBy != "" {
+		return c.OrderBy
+	}
+	return "create_at desc"
+}
+
+func (c DomainEventCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
+
+type IDomainEventConsumptionDA interface {
+	dbo.DataAccesser
+}
+
+type domainEventConsumptionDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_domainEventConsumptionOnce sync.Once
+	_domainEventConsumptionDA   IDomainEventConsumptionDA
+)
+
+func GetDomainEventConsumptionDA() IDomainEventConsumptionDA {
+	_domainEventConsumptionOnce.Do(func() {
+		_domainEventConsumptionDA = &domainEventConsumptionDA{}
+	})
+	return _domainEventConsumptionDA
+}
+
+type DomainEventConsumptionCondition struct {
+	EventIDs entity.NullStrings
+}
+
+func (c DomainEventConsumptionCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.EventIDs.Valid {
+		wheres = append(wheres, "event_id in (?)")
+		params = append(params, c.EventIDs.Strings)
+	}
+
+	return wheres, params
+}
+
+func (c DomainEventConsumptionCondition) GetOrderBy() string {
+	return "create_at"
+}
+
+func (c DomainEventConsumptionCondition) GetPager() *dbo.Pager {
+	return &dbo.NoPager
+}
@@ -0,0 +1,105 @@
+package da
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/ro"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+// IIdempotencyRedisDA records of idempotency keys, they expire with the replay window
+type IIdempotencyRedisDA interface {
+	// Acquire save the record if the key is new, returns false if the key exists
+	Acquire(ctx context.Context, key string, record *entity.IdempotencyRecord, expiration time.Duration) (bool, error)
+	// Get returns nil when the key does not exist
+	Get(ctx context.Context, key string) (*entity.IdempotencyRecord, error)
+	Set(ctx context.Context, key string, record *entity.IdempotencyRecord, expiration time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+type idempotencyRedisDA struct{}
+
+var (
+	_idempotencyRedisOnce sync.Once
+	_idempotencyRedisDA   IIdempotencyRedisDA
+)
+
+func GetIdempotencyRedisDA() IIdempotencyRedisDA {
+	_idempotencyRedisOnce.Do(func() {
+		_idempotencyRedisDA = &idempotencyRedisDA{}
+	})
+	return _idempotencyRedisDA
+}
+
+func (r *idempotencyRedisDA) recordKey(key string) string {
+	return fmt.Sprintf("%v:%v", RedisKeyPrefixIdempotency, key)
+}
+
+func (r *idempotencyRedisDA) Acquire(ctx context.Context, key string, record *entity.IdempotencyRecord, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Error(ctx, "marshal idempotency record failed", log.Err(err), log.String("key", key))
+		return false, err
+	}
+
+	ok, err := ro.MustGetRedis(ctx).SetNX(ctx, r.recordKey(key), data, expiration).Result()
+	if err != nil {
+		log.Error(ctx, "acquire idempotency key failed", log.Err(err), log.String("key", key))
+		return false, err
+	}
+
+	return ok, nil
+}
+
+func (r *idempotencyRedisDA) Get(ctx context.Context, key string) (*entity.IdempotencyRecord, error) {
+	data, err := ro.MustGetRedis(ctx).Get(ctx, r.recordKey(key)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		log.Error(ctx, "get idempotency record failed", log.Err(err), log.String("key", key))
+		return nil, err
+	}
+
+	record := new(entity.IdempotencyRecord)
+	err = json.Unmarshal(data, record)
+	if err != nil {
+		log.Error(ctx, "unmarshal idempotency record failed", log.Err(err), log.String("key", key))
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (r *idempotencyRedisDA) Set(ctx context.Context, key string, record *entity.IdempotencyRecord, expiration time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Error(ctx, "marshal idempotency record failed", log.Err(err), log.String("key", key))
+		return err
+	}
+
+	err = ro.MustGetRedis(ctx).Set(ctx, r.recordKey(key), data, expiration).Err()
+	if err != nil {
+		log.Error(ctx, "set idempotency record failed", log.Err(err), log.String("key", key))
+		return err
+	}
+
+	return nil
+}
+
+func (r *idempotencyRedisDA) Delete(ctx context.Context, key string) error {
+	err := ro.MustGetRedis(ctx).Del(ctx, r.recordKey(key)).Err()
+	if err != nil {
+		log.Error(ctx, "delete idempotency record failed", log.Err(err), log.String("key", key))
+		return err
+	}
+
+	return nil
+}
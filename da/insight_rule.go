@@ -0,0 +1,69 @@
+package da
+
+import (
+	"context"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type IInsightRuleSetDA interface {
+	dbo.DataAccesser
+}
+
+type insightRuleSetDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_insightRuleSetOnce sync.Once
+	_insightRuleSetDA   IInsightRuleSetDA
+)
+
+func GetInsightRuleSetDA() IInsightRuleSetDA {
+	_insightRuleSetOnce.Do(func() {
+		_insightRuleSetDA = &insightRuleSetDA{}
+	})
+	return _insightRuleSetDA
+}
+
+type IInsightRuleLogDA interface {
+	dbo.DataAccesser
+	// CountByRule times every rule fired in the organization, previews are not counted
+	CountByRule(ctx context.Context, orgID string, startAt, endAt int64) ([]*entity.InsightRuleStat, error)
+}
+
+type insightRuleLogDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_insightRuleLogOnce sync.Once
+	_insightRuleLogDA   IInsightRuleLogDA
+)
+
+func GetInsightRuleLogDA() IInsightRuleLogDA {
+	_insightRuleLogOnce.Do(func() {
+		_insightRuleLogDA = &insightRuleLogDA{}
+	})
+	return _insightRuleLogDA
+}
+
+func (d *insightRuleLogDA) CountByRule(ctx context.Context, orgID string, startAt, endAt int64) ([]*entity.InsightRuleStat, error) {
+	sql := `
+select category, rule_key, label_id, count(1) as count
+from insight_rule_logs
+where org_id = ? and preview = false and create_at >= ? and create_at < ?
+group by category, rule_key, label_id
+order by count desc`
+
+	var stats []*entity.InsightRuleStat
+	if err := d.QueryRawSQL(ctx, &stats, sql, orgID, startAt, endAt); err != nil {
+		log.Error(ctx, "count insight rule logs failed", log.Err(err), log.String("org_id", orgID), log.Int64("start_at", startAt), log.Int64("end_at", endAt))
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,229 @@
+package da
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+	"github.com/jinzhu/gorm"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type IJobDA interface {
+	dbo.DataAccesser
+	// MarkRunning move a pending job to running, false when another worker has taken it or it was cancelled
+	MarkRunning(ctx context.Context, id string, now int64) (bool, error)
+	// UpdateProgress also works as the heartbeat of a running job, false when the job is no longer running
+	UpdateProgress(ctx context.Context, id string, progress int, now int64) (bool, error)
+	// Finish save the result of a running job, false when the job was cancelled meanwhile
+	Finish(ctx context.Context, job *entity.Job) (bool, error)
+	// Postpone set the next attempt of a pending job, used when it is enqueued
+	Postpone(ctx context.Context, id string, nextAttemptAt int64) error
+	Cancel(ctx context.Context, id string, now int64) (bool, error)
+}
+
+type jobDA struct {
+	dbo.BaseDA
+}
+
+func (d *jobDA) MarkRunning(ctx context.Context, id string, now int64) (bool, error) {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	result := tx.Model(&entity.Job{}).
+		Where("id = ? and status = ?", id, entity.JobStatusPending).
+		Updates(map[string]interface{}{
+			"status":    entity.JobStatusRunning,
+			"attempts":  gorm.Expr("attempts + 1"),
+			"progress":  0,
+			"start_at":  now,
+			"update_at": now,
+		})
+	if result.Error != nil {
+		log.Error(ctx, "mark job running failed", log.Err(result.Error), log.String("id", id))
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (d *jobDA) UpdateProgress(ctx context.Context, id string, progress int, now int64) (bool, error) {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	result := tx.Model(&entity.Job{}).
+		Where("id = ? and status = ?", id, entity.JobStatusRunning).
+		Updates(map[string]interface{}{
+			"progress":  progress,
+			"update_at": now,
+		})
+	if result.Error != nil {
+		log.Error(ctx, "update job progress failed", log.Err(result.Error), log.String("id", id))
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (d *jobDA) Finish(ctx context.Context, job *entity.Job) (bool, error) {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	result := tx.Model(&entity.Job{}).
+		Where("id = ? and status = ?", job.ID, entity.JobStatusRunning).
+		Updates(map[string]interface{}{
+			"status":          job.Status,
+			"progress":        job.Progress,
+			"last_error":      job.LastError,
+			"result":          job.Result,
+			"has_file":        job.HasFile,
+			"next_attempt_at": job.NextAttemptAt,
+			"finish_at":       job.FinishAt,
+			"update_at":       job.UpdateAt,
+		})
+	if result.Error != nil {
+		log.Error(ctx, "finish job failed", log.Err(result.Error), log.Any("job", job))
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (d *jobDA) Postpone(ctx context.Context, id string, nextAttemptAt int64) error {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	err := tx.Model(&entity.Job{}).
+		Where("id = ? and status = ?", id, entity.JobStatusPending).
+		Update("next_attempt_at", nextAttemptAt).Error
+	if err != nil {
+		log.Error(ctx, "postpone job failed", log.Err(err), log.String("id", id))
+		return err
+	}
+
+	return nil
+}
+
+func (d *jobDA) Cancel(ctx context.Context, id string, now int64) (bool, error) {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	result := tx.Model(&entity.Job{}).
+		Where("id = ? and status in (?)", id, []entity.JobStatus{entity.JobStatusPending, entity.JobStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":    entity.JobStatusCancelled,
+			"finish_at": now,
+			"update_at": now,
+		})
+	if result.Error != nil {
+		log.Error(ctx, "cancel job failed", log.Err(result.Error), log.String("id", id))
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+var (
+	_jobOnce sync.Once
+	_jobDA   IJobDA
+)
+
+func GetJobDA() IJobDA {
+	_jobOnce.Do(func() {
+		_jobDA = &jobDA{}
+	})
+	return _jobDA
+}
+
+type JobCondition struct {
+	IDs       entity.NullStrings
+	OrgID     sql.NullString
+	CreatorID sql.NullString
+	Type      sql.NullString
+	Status    sql.NullString
+	// Due pending jobs whose next attempt is due
+	Due sql.NullInt64
+	// RunningBefore running jobs which have not reported progress since
+	RunningBefore sql.NullInt64
+
+	OrderBy string
+	Pager   dbo.Pager
+}
+
+func (c JobCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.IDs.Valid {
+		wheres = append(wheres, "id in (?)")
+		params = append(params, c.IDs.Strings)
+	}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.CreatorID.Valid {
+		wheres = append(wheres, "creator_id = ?")
+		params = append(params, c.CreatorID.String)
+	}
+
+	if c.Type.Valid {
+		wheres = append(wheres, "type = ?")
+		params = append(params, c.Type.String)
+	}
+
+	if c.Status.Valid {
+		wheres = append(wheres, "status = ?")
+		params = append(params, c.Status.String)
+	}
+
+	if c.Due.Valid {
+		wheres = append(wheres, "status = ? and next_attempt_at <= ?")
+		params = append(params, entity.JobStatusPending, c.Due.Int64)
+	}
+
+	if c.RunningBefore.Valid {
+		wheres = append(wheres, "status = ? and update_at <= ?")
+		params = append(params, entity.JobStatusRunning, c.RunningBefore.Int64)
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c JobCondition) GetOrderBy() string {
+	if c.OrderBy != "" {
+		return c.OrderBy
+	}
+	return "create_at desc"
+}
+
+func (c JobCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
+
+type IJobFileDA interface {
+	dbo.DataAccesser
+}
+
+type jobFileDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_jobFileOnce sync.Once
+	_jobFileDA   IJobFileDA
+)
+
+func GetJobFileDA() IJobFileDA {
+	_jobFileOnce.Do(func() {
+		_jobFileDA = &jobFileDA{}
+	})
+	return _jobFileDA
+}
@@ -0,0 +1,71 @@
+package da
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/ro"
+	"github.com/go-redis/redis/v8"
+)
+
+// IJobRedisDA tokens of job submitters are kept in redis only, they are never written to the database
+type IJobRedisDA interface {
+	SetToken(ctx context.Context, jobID string, token string, expiration time.Duration) error
+	// GetToken returns an empty token when it has expired
+	GetToken(ctx context.Context, jobID string) (string, error)
+	DeleteToken(ctx context.Context, jobID string) error
+}
+
+type jobRedisDA struct{}
+
+var (
+	_jobRedisOnce sync.Once
+	_jobRedisDA   IJobRedisDA
+)
+
+func GetJobRedisDA() IJobRedisDA {
+	_jobRedisOnce.Do(func() {
+		_jobRedisDA = &jobRedisDA{}
+	})
+	return _jobRedisDA
+}
+
+func (r *jobRedisDA) tokenKey(jobID string) string {
+	return fmt.Sprintf("%v:%v", RedisKeyPrefixJobToken, jobID)
+}
+
+func (r *jobRedisDA) SetToken(ctx context.Context, jobID string, token string, expiration time.Duration) error {
+	err := ro.MustGetRedis(ctx).Set(ctx, r.tokenKey(jobID), token, expiration).Err()
+	if err != nil {
+		log.Error(ctx, "set job token failed", log.Err(err), log.String("jobID", jobID))
+		return err
+	}
+
+	return nil
+}
+
+func (r *jobRedisDA) GetToken(ctx context.Context, jobID string) (string, error) {
+	token, err := ro.MustGetRedis(ctx).Get(ctx, r.tokenKey(jobID)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		log.Error(ctx, "get job token failed", log.Err(err), log.String("jobID", jobID))
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (r *jobRedisDA) DeleteToken(ctx context.Context, jobID string) error {
+	err := ro.MustGetRedis(ctx).Del(ctx, r.tokenKey(jobID)).Err()
+	if err != nil {
+		log.Error(ctx, "delete job token failed", log.Err(err), log.String("jobID", jobID))
+		return err
+	}
+
+	return nil
+}
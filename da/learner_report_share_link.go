@@ -0,0 +1,93 @@
+package da
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type ILearnerReportShareLinkDA interface {
+	dbo.DataAccesser
+	// IncreaseViewCount counts a view of the link
+	IncreaseViewCount(ctx context.Context, id string, viewAt int64) error
+}
+
+type learnerReportShareLinkDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_learnerReportShareLinkOnce sync.Once
+	_learnerReportShareLinkDA   ILearnerReportShareLinkDA
+)
+
+func GetLearnerReportShareLinkDA() ILearnerReportShareLinkDA {
+	_learnerReportShareLinkOnce.Do(func() {
+		_learnerReportShareLinkDA = &learnerReportShareLinkDA{}
+	})
+	return _learnerReportShareLinkDA
+}
+
+func (d *learnerReportShareLinkDA) IncreaseViewCount(ctx context.Context, id string, viewAt int64) error {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	query := fmt.Sprintf("update %s set view_count = view_count + 1, last_view_at = ? where id = ?", constant.TableNameLearnerReportShareLink)
+	if err := tx.Exec(query, viewAt, id).Error; err != nil {
+		log.Error(ctx, "increase learner report share link view count failed", log.Err(err), log.String("id", id))
+		return err
+	}
+
+	return nil
+}
+
+type LearnerReportShareLinkCondition struct {
+	IDs       entity.NullStrings
+	OrgID     sql.NullString
+	ClassID   sql.NullString
+	StudentID sql.NullString
+
+	Pager dbo.Pager
+}
+
+func (c LearnerReportShareLinkCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.IDs.Valid {
+		wheres = append(wheres, "id in (?)")
+		params = append(params, c.IDs.Strings)
+	}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.ClassID.Valid {
+		wheres = append(wheres, "class_id = ?")
+		params = append(params, c.ClassID.String)
+	}
+
+	if c.StudentID.Valid {
+		wheres = append(wheres, "student_id = ?")
+		params = append(params, c.StudentID.String)
+	}
+
+	return wheres, params
+}
+
+func (c LearnerReportShareLinkCondition) GetOrderBy() string {
+	return "create_at desc"
+}
+
+func (c LearnerReportShareLinkCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
@@ -0,0 +1,85 @@
+package da
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type IOrganizationAPIKeyDA interface {
+	dbo.DataAccesser
+	UpdateLastUsedAt(ctx context.Context, id string, lastUsedAt int64) error
+}
+
+type organizationAPIKeyDA struct {
+	dbo.BaseDA
+}
+
+func (o *organizationAPIKeyDA) UpdateLastUsedAt(ctx context.Context, id string, lastUsedAt int64) error {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	if err := tx.Model(&entity.OrganizationAPIKey{}).
+		Where("id = ?", id).
+		Update("last_used_at", lastUsedAt).Error; err != nil {
+		log.Error(ctx, "update api key last used time failed", log.Err(err), log.String("id", id))
+		return err
+	}
+
+	return nil
+}
+
+var (
+	_organizationAPIKeyOnce sync.Once
+	_organizationAPIKeyDA   IOrganizationAPIKeyDA
+)
+
+func GetOrganizationAPIKeyDA() IOrganizationAPIKeyDA {
+	_organizationAPIKeyOnce.Do(func() {
+		_organizationAPIKeyDA = &organizationAPIKeyDA{}
+	})
+	return _organizationAPIKeyDA
+}
+
+type OrganizationAPIKeyCondition struct {
+	IDs     entity.NullStrings
+	OrgID   sql.NullString
+	KeyHash sql.NullString
+}
+
+func (c OrganizationAPIKeyCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.IDs.Valid {
+		wheres = append(wheres, "id in (?)")
+		params = append(params, c.IDs.Strings)
+	}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.KeyHash.Valid {
+		wheres = append(wheres, "key_hash = ?")
+		params = append(params, c.KeyHash.String)
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c OrganizationAPIKeyCondition) GetOrderBy() string {
+	return "create_at desc"
+}
+
+func (c OrganizationAPIKeyCondition) GetPager() *dbo.Pager {
+	return &dbo.NoPager
+}
@@ -0,0 +1,51 @@
+package da
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/ro"
+)
+
+// IRateLimitRedisDA fixed window counters shared by the instances of the service
+type IRateLimitRedisDA interface {
+	// Allow counts a hit of the key, false when the hits in the window exceed the limit
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error)
+}
+
+type rateLimitRedisDA struct{}
+
+var (
+	_rateLimitRedisOnce sync.Once
+	_rateLimitRedisDA   IRateLimitRedisDA
+)
+
+func GetRateLimitRedisDA() IRateLimitRedisDA {
+	_rateLimitRedisOnce.Do(func() {
+		_rateLimitRedisDA = &rateLimitRedisDA{}
+	})
+	return _rateLimitRedisDA
+}
+
+func (r *rateLimitRedisDA) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	// the window is in the key, so a counter which misses its expiration is not reused by the next window
+	windowStart := time.Now().UnixNano() / int64(window)
+	redisKey := fmt.Sprintf("%v:%v:%v", RedisKeyPrefixRateLimit, key, windowStart)
+
+	client := ro.MustGetRedis(ctx)
+	count, err := client.Incr(ctx, redisKey).Result()
+	if err != nil {
+		log.Error(ctx, "increase rate limit counter failed", log.Err(err), log.String("key", redisKey))
+		return false, err
+	}
+	if count == 1 {
+		if err := client.Expire(ctx, redisKey, window).Err(); err != nil {
+			log.Warn(ctx, "set rate limit counter expiration failed", log.Err(err), log.String("key", redisKey))
+		}
+	}
+
+	return count <= int64(limit), nil
+}
@@ -38,10 +38,38 @@ const (
 	RedisKeyPrefixReportLearningOutcomeOverview = "report:learning:outcome:overview"
 	RedisKeyPrefixReportTeacherUsageOverview    = "report:teacher:usage:overview"
 	RedisKeyPrefixReportSkillCoverage           = "report:skill:coverage"
+
+	RedisKeyPrefixDomainEventDispatchLock = "domain_event:dispatch:lock"
+	RedisKeyPrefixWebhookDeliveryLock     = "webhook:delivery:lock"
+
+	RedisKeyPrefixJobRelayLock = "job:relay:lock"
+	RedisKeyPrefixJobToken     = "job:token"
+
+	RedisKeyPrefixAuditPurgeLock = "audit:purge:lock"
+
+	RedisKeyPrefixReportRollupRefreshLock = "report_rollup:refresh:lock"
+
+	RedisKeyPrefixReportSubscriptionDeliveryLock = "report_subscription:delivery:lock"
+
+	RedisKeyPrefixStudentRiskEvaluateLock = "student_risk:evaluate:lock"
+	// RedisKeyStudentRiskEvaluated sorted set of the classes scored by their last evaluation
+	RedisKeyStudentRiskEvaluated = "student_risk:evaluated"
+
+	RedisKeyPrefixIdempotency = "idempotency"
+
+	RedisKeyPrefixRateLimit = "rate_limit"
+
+	RedisKeyPrefixStudentUsageIngest = "student_usage:ingest"
+	// RedisKeyStudentUsageIngestDeadLetter list of the records which failed to be written, in lines of the replay tool
+	RedisKeyStudentUsageIngestDeadLetter = "student_usage:ingest_dead_letter"
+
+	RedisKeyPrefixXAPIForwardLock = "xapi:forward:lock"
 )
 
 const (
 	RedisKeyPrefixAssessmentItem = "assessment:item"
 
 	RedisKeyPrefixAssessmentQueryLearningSummaryTimeFilter = "assessment:query_learning_summary_time_filter"
+
+	RedisKeyPrefixAssessmentAutoCompleteLock = "assessment:auto_complete:lock"
 )
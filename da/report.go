@@ -19,6 +19,10 @@ type IReportDA interface {
 	ILearningOutcomeReport
 	ILearnerWeekly
 	ISkillCoverage
+	IStudentRisk
+	IHeadquartersReport
+	IReportBuilder
+	ILearnerReport
 }
 type ReportDA struct {
 	BaseDA
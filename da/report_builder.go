@@ -0,0 +1,225 @@
+package da
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/KL-Engineering/common-log/log"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+)
+
+type IReportBuilder interface {
+	// QueryReportBuilderRows rows of the metrics of a source, grouped by the dimensions of the query
+	QueryReportBuilderRows(ctx context.Context, condition *entity.ReportBuilderCondition) ([]*entity.ReportBuilderRow, error)
+}
+
+// reportBuilderExpr sql with its params
+type reportBuilderExpr struct {
+	sql    string
+	params []interface{}
+}
+
+func newReportBuilderExpr(sql string, params ...interface{}) reportBuilderExpr {
+	return reportBuilderExpr{sql: sql, params: params}
+}
+
+// reportBuilderSource the semantic layer of a source: rows are dated by time and s is their schedule
+type reportBuilderSource struct {
+	from  string
+	where reportBuilderExpr
+	time  reportBuilderExpr
+	// columns dimensions of the rows, the others are relations of the schedule
+	columns map[entity.ReportBuilderDimension]string
+	metrics map[entity.ReportBuilderMetric]reportBuilderExpr
+}
+
+// reportBuilderRelations relation types of the dimensions which are relations of the schedule
+var reportBuilderRelations = map[entity.ReportBuilderDimension][]entity.ScheduleRelationType{
+	entity.ReportBuilderDimensionSchool:  {entity.ScheduleRelationTypeSchool},
+	entity.ReportBuilderDimensionClass:   {entity.ScheduleRelationTypeClassRosterClass},
+	entity.ReportBuilderDimensionTeacher: {entity.ScheduleRelationTypeClassRosterTeacher, entity.ScheduleRelationTypeParticipantTeacher},
+	entity.ReportBuilderDimensionSubject: {entity.ScheduleRelationTypeSubject},
+}
+
+func reportBuilderSourceOf(source entity.ReportBuilderSource) (*reportBuilderSource, error) {
+	assessments := `assessments_users_v2 auv
+inner join assessments_v2 av on av.id = auv.assessment_id
+inner join schedules s on s.id = av.schedule_id`
+	assessmentColumns := map[entity.ReportBuilderDimension]string{
+		entity.ReportBuilderDimensionOrg:        "s.org_id",
+		entity.ReportBuilderDimensionStudent:    "auv.user_id",
+		entity.ReportBuilderDimensionProgram:    "s.program_id",
+		entity.ReportBuilderDimensionLessonPlan: "s.lesson_plan_id",
+	}
+	// study assignments by creation time and classes by end time, like the report rollups
+	assessmentTime := newReportBuilderExpr("if(s.class_type = ?, s.created_at, s.end_at)", entity.ScheduleClassTypeHomework)
+
+	switch source {
+	case entity.ReportBuilderSourceLessons:
+		return &reportBuilderSource{
+			from:    assessments,
+			where:   newReportBuilderExpr("auv.delete_at = 0 and av.delete_at = 0 and auv.user_type = ?", v2.AssessmentUserTypeStudent),
+			time:    assessmentTime,
+			columns: assessmentColumns,
+			metrics: map[entity.ReportBuilderMetric]reportBuilderExpr{
+				entity.ReportBuilderMetricScheduledLessons: newReportBuilderExpr("count(distinct s.id)"),
+				entity.ReportBuilderMetricAttended:         newReportBuilderExpr("sum(auv.status_by_system <> ?)", v2.AssessmentUserSystemStatusNotStarted),
+				entity.ReportBuilderMetricCompletionRate: newReportBuilderExpr("sum(auv.status_by_system in (?)) / count(1)", []v2.AssessmentUserSystemStatus{
+					v2.AssessmentUserSystemStatusDone,
+					v2.AssessmentUserSystemStatusResubmitted,
+					v2.AssessmentUserSystemStatusCompleted,
+				}),
+			},
+		}, nil
+	case entity.ReportBuilderSourceOutcomes:
+		return &reportBuilderSource{
+			from:    assessments + "\ninner join assessments_users_outcomes_v2 auov on auov.assessment_user_id = auv.id",
+			where:   newReportBuilderExpr("auov.delete_at = 0 and auv.delete_at = 0 and av.delete_at = 0 and auv.user_type = ? and av.status = ?", v2.AssessmentUserTypeStudent, v2.AssessmentStatusComplete),
+			time:    assessmentTime,
+			columns: assessmentColumns,
+			metrics: map[entity.ReportBuilderMetric]reportBuilderExpr{
+				entity.ReportBuilderMetricOutcomesAchieved: newReportBuilderExpr("sum(auov.status = ?)", v2.AssessmentUserOutcomeStatusAchieved),
+			},
+		}, nil
+	case entity.ReportBuilderSourceUsages:
+		return &reportBuilderSource{
+			from:  "student_usage_records sur\ninner join schedules s on s.id = sur.room_id",
+			where: newReportBuilderExpr("1 = 1"),
+			time:  newReportBuilderExpr("sur.schedule_start_at"),
+			columns: map[entity.ReportBuilderDimension]string{
+				entity.ReportBuilderDimensionOrg:        "s.org_id",
+				entity.ReportBuilderDimensionClass:      "sur.class_id",
+				entity.ReportBuilderDimensionStudent:    "sur.student_user_id",
+				entity.ReportBuilderDimensionProgram:    "s.program_id",
+				entity.ReportBuilderDimensionLessonPlan: "sur.lesson_plan_id",
+			},
+			metrics: map[entity.ReportBuilderMetric]reportBuilderExpr{
+				entity.ReportBuilderMetricMaterialViews: newReportBuilderExpr("count(distinct sur.student_user_id, sur.lesson_plan_id, sur.lesson_material_id, sur.class_id, sur.content_type)"),
+			},
+		}, nil
+	}
+	return nil, constant.ErrInvalidArgs
+}
+
+// reportBuilderStatement the parts of the query, params are kept in the order of the parts
+type reportBuilderStatement struct {
+	source  *reportBuilderSource
+	grouped map[entity.ReportBuilderDimension]string
+	selects []reportBuilderExpr
+	joins   []reportBuilderExpr
+	wheres  []reportBuilderExpr
+	groups  []string
+}
+
+// relation alias of the joined relation of the dimension
+func (s *reportBuilderStatement) relation(dimension entity.ReportBuilderDimension) string {
+	return "sr_" + string(dimension)
+}
+
+// filter limits the dimension to the values, relations not grouped by are filtered by existence
+func (s *reportBuilderStatement) filter(dimension entity.ReportBuilderDimension, values []string) {
+	if column, ok := s.source.columns[dimension]; ok {
+		s.wheres = append(s.wheres, newReportBuilderExpr(column+" in (?)", values))
+		return
+	}
+	if _, ok := s.grouped[dimension]; ok {
+		s.wheres = append(s.wheres, newReportBuilderExpr(s.relation(dimension)+".relation_id in (?)", values))
+		return
+	}
+	s.wheres = append(s.wheres, newReportBuilderExpr(
+		"exists (select 1 from schedules_relations sr where sr.schedule_id = s.id and sr.relation_type in (?) and sr.relation_id in (?))",
+		reportBuilderRelations[dimension], values))
+}
+
+func (r *ReportDA) QueryReportBuilderRows(ctx context.Context, condition *entity.ReportBuilderCondition) ([]*entity.ReportBuilderRow, error) {
+	source, err := reportBuilderSourceOf(condition.Source)
+	if err != nil {
+		log.Warn(ctx, "QueryReportBuilderRows: invalid source", log.Any("condition", condition))
+		return nil, err
+	}
+
+	s := &reportBuilderStatement{source: source, grouped: make(map[entity.ReportBuilderDimension]string)}
+	for _, dimension := range condition.Query.Dimensions {
+		column := string(dimension) + "_id"
+		if dimension == entity.ReportBuilderDimensionPeriod {
+			column = "period"
+			whens := make([]string, len(condition.Periods))
+			var params []interface{}
+			for i, start := range condition.Periods {
+				end := condition.Query.EndAt
+				if i+1 < len(condition.Periods) {
+					end = condition.Periods[i+1]
+				}
+				whens[i] = fmt.Sprintf("when %s >= %d and %s < %d then %d", source.time.sql, start, source.time.sql, end, start)
+				params = append(params, source.time.params...)
+				params = append(params, source.time.params...)
+			}
+			s.selects = append(s.selects, newReportBuilderExpr(fmt.Sprintf("case %s else 0 end as period", strings.Join(whens, " ")), params...))
+		} else if expr, ok := source.columns[dimension]; ok {
+			s.selects = append(s.selects, newReportBuilderExpr(expr+" as "+column))
+		} else {
+			alias := s.relation(dimension)
+			s.selects = append(s.selects, newReportBuilderExpr("ifnull("+alias+".relation_id, '') as "+column))
+			s.joins = append(s.joins, newReportBuilderExpr(
+				fmt.Sprintf("left join schedules_relations %s on %s.schedule_id = s.id and %s.relation_type in (?)", alias, alias, alias),
+				reportBuilderRelations[dimension]))
+		}
+		s.grouped[dimension] = column
+		s.groups = append(s.groups, column)
+	}
+	for _, metric := range condition.Query.Metrics {
+		if expr, ok := source.metrics[metric]; ok {
+			s.selects = append(s.selects, newReportBuilderExpr(expr.sql+" as "+string(metric), expr.params...))
+		}
+	}
+
+	s.wheres = append(s.wheres,
+		source.where,
+		newReportBuilderExpr("s.org_id = ? and s.delete_at = 0", condition.OrgID),
+		newReportBuilderExpr(source.time.sql+" >= ?", append(append([]interface{}{}, source.time.params...), condition.Query.StartAt)...),
+		newReportBuilderExpr(source.time.sql+" < ?", append(append([]interface{}{}, source.time.params...), condition.Query.EndAt)...),
+	)
+	s.filter(entity.ReportBuilderDimensionClass, condition.ClassIDs)
+	if condition.TeacherIDs.Valid {
+		s.filter(entity.ReportBuilderDimensionTeacher, condition.TeacherIDs.Strings)
+	}
+	for _, filter := range condition.Query.Filters {
+		s.filter(filter.Dimension, filter.Values)
+	}
+
+	var params []interface{}
+	selects := make([]string, len(s.selects))
+	for i, expr := range s.selects {
+		selects[i] = expr.sql
+		params = append(params, expr.params...)
+	}
+	joins := make([]string, len(s.joins))
+	for i, expr := range s.joins {
+		joins[i] = expr.sql
+		params = append(params, expr.params...)
+	}
+	wheres := make([]string, len(s.wheres))
+	for i, expr := range s.wheres {
+		wheres[i] = expr.sql
+		params = append(params, expr.params...)
+	}
+
+	sql := fmt.Sprintf("select %s\nfrom %s\n%s\nwhere %s", strings.Join(selects, ",\n\t"), source.from, strings.Join(joins, "\n"), strings.Join(wheres, "\n\tand "))
+	if len(s.groups) > 0 {
+		sql += fmt.Sprintf("\ngroup by %s\norder by %s", strings.Join(s.groups, ", "), strings.Join(s.groups, ", "))
+	}
+	sql += "\nlimit ?"
+	params = append(params, condition.Limit)
+
+	var rows []*entity.ReportBuilderRow
+	err = r.QueryRawSQL(ctx, &rows, sql, params...)
+	if err != nil {
+		log.Error(ctx, "QueryReportBuilderRows: query failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,77 @@
+package da
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type IReportDefinitionDA interface {
+	dbo.DataAccesser
+}
+
+type reportDefinitionDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_reportDefinitionOnce sync.Once
+	_reportDefinitionDA   IReportDefinitionDA
+)
+
+func GetReportDefinitionDA() IReportDefinitionDA {
+	_reportDefinitionOnce.Do(func() {
+		_reportDefinitionDA = &reportDefinitionDA{}
+	})
+	return _reportDefinitionDA
+}
+
+type ReportDefinitionCondition struct {
+	IDs   entity.NullStrings
+	OrgID sql.NullString
+	// Visible definitions of the user and shared definitions
+	Visible sql.NullString
+	Name    sql.NullString
+
+	Pager dbo.Pager
+}
+
+func (c ReportDefinitionCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.IDs.Valid {
+		wheres = append(wheres, "id in (?)")
+		params = append(params, c.IDs.Strings)
+	}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.Visible.Valid {
+		wheres = append(wheres, "(creator_id = ? or shared = true)")
+		params = append(params, c.Visible.String)
+	}
+
+	if c.Name.Valid {
+		wheres = append(wheres, "name like ?")
+		params = append(params, "%"+c.Name.String+"%")
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c ReportDefinitionCondition) GetOrderBy() string {
+	return "update_at desc"
+}
+
+func (c ReportDefinitionCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
@@ -0,0 +1,150 @@
+package da
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/KL-Engineering/common-log/log"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+)
+
+type IHeadquartersReport interface {
+	// GetHeadquartersReportCounts counts of the metric by organization or school, and by week if weeks are given
+	GetHeadquartersReportCounts(ctx context.Context, condition *entity.HeadquartersReportCondition) ([]*entity.HeadquartersReportCount, error)
+}
+
+// headquartersReportSource rows of a metric, s is the schedule and the time column dates the rows
+type headquartersReportSource struct {
+	from        string
+	time        string
+	count       string
+	countParams []interface{}
+	total       string
+	where       string
+	params      []interface{}
+}
+
+func headquartersReportSourceOf(metric entity.HeadquartersReportMetric) (*headquartersReportSource, error) {
+	assessments := `assessments_users_v2 auv
+inner join assessments_v2 av on av.id = auv.assessment_id
+inner join schedules s on s.id = av.schedule_id`
+	classTypes := []v2.AssessmentType{v2.AssessmentTypeOnlineClass, v2.AssessmentTypeOfflineClass}
+
+	switch metric {
+	case entity.HeadquartersReportMetricAttendance:
+		return &headquartersReportSource{
+			from:        assessments,
+			time:        "s.end_at",
+			count:       "sum(auv.status_by_system <> ?)",
+			countParams: []interface{}{v2.AssessmentUserSystemStatusNotStarted},
+			total:       "count(1)",
+			where:       "auv.delete_at = 0 and av.delete_at = 0 and auv.user_type = ? and av.assessment_type in (?)",
+			params:      []interface{}{v2.AssessmentUserTypeStudent, classTypes},
+		}, nil
+	case entity.HeadquartersReportMetricAssignmentCompletion:
+		return &headquartersReportSource{
+			from:  assessments,
+			time:  "s.created_at",
+			count: "sum(auv.status_by_system in (?))",
+			countParams: []interface{}{
+				[]v2.AssessmentUserSystemStatus{v2.AssessmentUserSystemStatusDone, v2.AssessmentUserSystemStatusResubmitted, v2.AssessmentUserSystemStatusCompleted},
+			},
+			total: "count(1)",
+			where: "auv.delete_at = 0 and av.delete_at = 0 and auv.user_type = ? and av.assessment_type in (?)",
+			params: []interface{}{
+				v2.AssessmentUserTypeStudent,
+				[]v2.AssessmentType{v2.AssessmentTypeOnlineStudy, v2.AssessmentTypeOfflineStudy},
+			},
+		}, nil
+	case entity.HeadquartersReportMetricOutcomeAchievement:
+		return &headquartersReportSource{
+			from:        assessments + "\ninner join assessments_users_outcomes_v2 auov on auov.assessment_user_id = auv.id",
+			time:        "av.complete_at",
+			count:       "sum(auov.status = ?)",
+			countParams: []interface{}{v2.AssessmentUserOutcomeStatusAchieved},
+			total:       "count(1)",
+			where:       "auov.delete_at = 0 and auv.delete_at = 0 and av.delete_at = 0 and auv.user_type = ? and av.status = ? and auv.status_by_system <> ? and auov.status <> ?",
+			params:      []interface{}{v2.AssessmentUserTypeStudent, v2.AssessmentStatusComplete, v2.AssessmentUserSystemStatusNotStarted, v2.AssessmentUserOutcomeStatusNotCovered},
+		}, nil
+	case entity.HeadquartersReportMetricContentUsage:
+		// distinct like the material usage report
+		return &headquartersReportSource{
+			from:  "student_usage_records sur\ninner join schedules s on s.id = sur.room_id",
+			time:  "sur.schedule_start_at",
+			count: "count(distinct sur.student_user_id, sur.lesson_plan_id, sur.lesson_material_id, sur.class_id, sur.content_type)",
+			total: "count(distinct sur.student_user_id)",
+			where: "1 = 1",
+		}, nil
+	case entity.HeadquartersReportMetricTeacherLoad:
+		return &headquartersReportSource{
+			from:   assessments,
+			time:   "s.end_at",
+			count:  "count(distinct av.schedule_id, auv.user_id)",
+			total:  "count(distinct auv.user_id)",
+			where:  "auv.delete_at = 0 and av.delete_at = 0 and auv.user_type = ? and av.assessment_type in (?)",
+			params: []interface{}{v2.AssessmentUserTypeTeacher, classTypes},
+		}, nil
+	}
+	return nil, constant.ErrInvalidArgs
+}
+
+func (r *ReportDA) GetHeadquartersReportCounts(ctx context.Context, condition *entity.HeadquartersReportCondition) ([]*entity.HeadquartersReportCount, error) {
+	counts := []*entity.HeadquartersReportCount{}
+	if len(condition.OrgIDs) == 0 {
+		return counts, nil
+	}
+
+	source, err := headquartersReportSourceOf(condition.Metric)
+	if err != nil {
+		log.Warn(ctx, "GetHeadquartersReportCounts: invalid metric", log.Any("condition", condition))
+		return nil, err
+	}
+
+	school := "''"
+	from := source.from
+	var fromParams []interface{}
+	if condition.Level == entity.HeadquartersReportLevelSchool {
+		// schedules of several schools are counted in each school
+		school = "srSchool.relation_id"
+		from += "\ninner join schedules_relations srSchool on srSchool.schedule_id = s.id and srSchool.relation_type = ?"
+		fromParams = append(fromParams, entity.ScheduleRelationTypeSchool)
+	}
+
+	week := "0"
+	if len(condition.Weeks) > 0 {
+		whens := make([]string, len(condition.Weeks))
+		for i, start := range condition.Weeks {
+			whens[i] = fmt.Sprintf("when %s >= %d and %s < %d then %d", source.time, start, source.time, start+7*constant.ReportRollupDay, start)
+		}
+		week = fmt.Sprintf("case %s else 0 end", strings.Join(whens, " "))
+	}
+
+	sql := fmt.Sprintf(`
+select
+	s.org_id as org_id,
+	%s as school_id,
+	%s as week_start,
+	%s as count,
+	%s as total
+from %s
+where s.org_id in (?) and s.delete_at = 0 and %s >= ? and %s < ? and %s
+group by org_id, school_id, week_start`,
+		school, week, source.count, source.total, from, source.time, source.time, source.where)
+
+	var params []interface{}
+	params = append(params, source.countParams...)
+	params = append(params, fromParams...)
+	params = append(params, condition.OrgIDs, condition.StartAt, condition.EndAt)
+	params = append(params, source.params...)
+
+	err = r.QueryRawSQL(ctx, &counts, sql, params...)
+	if err != nil {
+		log.Error(ctx, "GetHeadquartersReportCounts: query failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+	return counts, nil
+}
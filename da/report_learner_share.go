@@ -0,0 +1,154 @@
+package da
+
+import (
+	"context"
+
+	"github.com/KL-Engineering/common-log/log"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+)
+
+type ILearnerReport interface {
+	GetLearnerReportCounts(ctx context.Context, condition *entity.LearnerReportCondition) (*entity.LearnerReportCounts, error)
+	// GetLearnerReportAchievedOutcomes names of the outcomes achieved in the time range, the latest first
+	GetLearnerReportAchievedOutcomes(ctx context.Context, condition *entity.LearnerReportCondition) ([]string, error)
+	// GetLearnerReportComments reviewer comments of the student made in the time range, the latest first
+	GetLearnerReportComments(ctx context.Context, condition *entity.LearnerReportCondition) ([]*entity.LearnerReportComment, error)
+}
+
+// learnerReportFrom assessment rows dated by their schedules
+const learnerReportFrom = `assessments_users_v2 auv
+inner join assessments_v2 av on av.id = auv.assessment_id
+inner join schedules s on s.id = av.schedule_id`
+
+// learnerReportWhere rows of the student in the schedules of the class, see learnerReportWhereParams
+const learnerReportWhere = `s.org_id = ? and s.delete_at = 0 and av.delete_at = 0 and auv.delete_at = 0
+and auv.user_id = ? and auv.user_type = ?
+and exists (select 1 from schedules_relations sr where sr.schedule_id = s.id and sr.relation_type = ? and sr.relation_id = ?)`
+
+func learnerReportWhereParams(condition *entity.LearnerReportCondition) []interface{} {
+	return []interface{}{
+		condition.OrgID,
+		condition.StudentID, v2.AssessmentUserTypeStudent,
+		entity.ScheduleRelationTypeClassRosterClass, condition.ClassID,
+	}
+}
+
+func (r *ReportDA) GetLearnerReportCounts(ctx context.Context, condition *entity.LearnerReportCondition) (*entity.LearnerReportCounts, error) {
+	classTypes := []v2.AssessmentType{v2.AssessmentTypeOnlineClass, v2.AssessmentTypeOfflineClass}
+	studyTypes := []v2.AssessmentType{v2.AssessmentTypeOnlineStudy, v2.AssessmentTypeOfflineStudy}
+
+	// classes are dated by the end of the schedule and assignments by the creation like the other reports
+	sql := `
+select
+	coalesce(sum(av.assessment_type in (?) and s.end_at >= ? and s.end_at < ?), 0) as classes_scheduled,
+	coalesce(sum(av.assessment_type in (?) and s.end_at >= ? and s.end_at < ? and auv.status_by_system <> ?), 0) as classes_attended,
+	coalesce(sum(av.assessment_type in (?) and s.created_at >= ? and s.created_at < ?), 0) as assignments_assigned,
+	coalesce(sum(av.assessment_type in (?) and s.created_at >= ? and s.created_at < ? and auv.status_by_system in (?)), 0) as assignments_done
+from ` + learnerReportFrom + `
+where ` + learnerReportWhere
+
+	params := []interface{}{
+		classTypes, condition.StartAt, condition.EndAt,
+		classTypes, condition.StartAt, condition.EndAt, v2.AssessmentUserSystemStatusNotStarted,
+		studyTypes, condition.StartAt, condition.EndAt,
+		studyTypes, condition.StartAt, condition.EndAt,
+		[]v2.AssessmentUserSystemStatus{v2.AssessmentUserSystemStatusDone, v2.AssessmentUserSystemStatusResubmitted, v2.AssessmentUserSystemStatusCompleted},
+	}
+	params = append(params, learnerReportWhereParams(condition)...)
+
+	var counts []*entity.LearnerReportCounts
+	err := r.QueryRawSQL(ctx, &counts, sql, params...)
+	if err != nil {
+		log.Error(ctx, "GetLearnerReportCounts: query lessons failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+	result := new(entity.LearnerReportCounts)
+	if len(counts) > 0 {
+		result = counts[0]
+	}
+
+	sql = `
+select
+	count(distinct auov.outcome_id) as outcomes_assessed,
+	count(distinct case when auov.status = ? then auov.outcome_id end) as outcomes_achieved
+from ` + learnerReportFrom + `
+inner join assessments_users_outcomes_v2 auov on auov.assessment_user_id = auv.id
+where ` + learnerReportWhere + `
+and auov.delete_at = 0 and auov.status <> ? and av.status = ? and av.complete_at >= ? and av.complete_at < ?`
+
+	params = []interface{}{v2.AssessmentUserOutcomeStatusAchieved}
+	params = append(params, learnerReportWhereParams(condition)...)
+	params = append(params, v2.AssessmentUserOutcomeStatusNotCovered, v2.AssessmentStatusComplete, condition.StartAt, condition.EndAt)
+
+	var outcomes []*entity.LearnerReportCounts
+	err = r.QueryRawSQL(ctx, &outcomes, sql, params...)
+	if err != nil {
+		log.Error(ctx, "GetLearnerReportCounts: query outcomes failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+	if len(outcomes) > 0 {
+		result.OutcomesAssessed = outcomes[0].OutcomesAssessed
+		result.OutcomesAchieved = outcomes[0].OutcomesAchieved
+	}
+
+	return result, nil
+}
+
+func (r *ReportDA) GetLearnerReportAchievedOutcomes(ctx context.Context, condition *entity.LearnerReportCondition) ([]string, error) {
+	sql := `
+select lo.name as name
+from ` + learnerReportFrom + `
+inner join assessments_users_outcomes_v2 auov on auov.assessment_user_id = auv.id
+inner join learning_outcomes lo on lo.id = auov.outcome_id
+where ` + learnerReportWhere + `
+and auov.delete_at = 0 and auov.status = ? and av.status = ? and av.complete_at >= ? and av.complete_at < ?
+group by auov.outcome_id, lo.name
+order by max(av.complete_at) desc
+limit ?`
+
+	params := learnerReportWhereParams(condition)
+	params = append(params, v2.AssessmentUserOutcomeStatusAchieved, v2.AssessmentStatusComplete, condition.StartAt, condition.EndAt, condition.Limit)
+
+	var rows []*struct {
+		Name string `gorm:"column:name"`
+	}
+	err := r.QueryRawSQL(ctx, &rows, sql, params...)
+	if err != nil {
+		log.Error(ctx, "GetLearnerReportAchievedOutcomes: query failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	names := make([]string, len(rows))
+	for i, row := range rows {
+		names[i] = row.Name
+	}
+	return names, nil
+}
+
+func (r *ReportDA) GetLearnerReportComments(ctx context.Context, condition *entity.LearnerReportCondition) ([]*entity.LearnerReportComment, error) {
+	sql := `
+select
+	av.title as title,
+	arf.reviewer_id as teacher_id,
+	arf.reviewer_comment as comment,
+	arf.create_at as create_at
+from ` + learnerReportFrom + `
+inner join assessments_reviewer_feedback_v2 arf on arf.assessment_user_id = auv.id
+where ` + learnerReportWhere + `
+and arf.delete_at = 0 and arf.reviewer_comment <> '' and arf.create_at >= ? and arf.create_at < ?
+order by arf.create_at desc
+limit ?`
+
+	params := learnerReportWhereParams(condition)
+	params = append(params, condition.StartAt, condition.EndAt, condition.Limit)
+
+	comments := []*entity.LearnerReportComment{}
+	err := r.QueryRawSQL(ctx, &comments, sql, params...)
+	if err != nil {
+		log.Error(ctx, "GetLearnerReportComments: query failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+	return comments, nil
+}
@@ -501,16 +501,22 @@ group by user_id
 		return
 	}
 
-	res = new(entity.LearnerReportOverview)
-	if len(*ret) == 0 {
-		res.Status = constant.LearnerReportOverviewStatusNoData
-		return
-	}
 	m := map[string][]float64{}
 	for _, item := range *ret {
 
 		m[item.StudentID] = append(m[item.StudentID], item.Rate)
 	}
+	res = newLearnerReportOverview(m)
+	return
+}
+
+// newLearnerReportOverview rates of a student are of online classes and studies in order
+func newLearnerReportOverview(m map[string][]float64) (res *entity.LearnerReportOverview) {
+	res = new(entity.LearnerReportOverview)
+	if len(m) == 0 {
+		res.Status = constant.LearnerReportOverviewStatusNoData
+		return
+	}
 
 	for _, rates := range m {
 		var rate0 float64
@@ -0,0 +1,522 @@
+package da
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+	"github.com/jinzhu/gorm"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+)
+
+const reportRollupInsertBatchSize = 500
+
+type IReportRollupDA interface {
+	// MarkDirtyTx mark the days of the schedules dirty, deleted schedules are marked too so the days they left are refreshed
+	MarkDirtyTx(ctx context.Context, tx *dbo.DBContext, scheduleIDs []string, offset int, now int64) error
+	// MarkRepeatDirtyTx mark the days of the repeated schedules from startAt on dirty
+	MarkRepeatDirtyTx(ctx context.Context, tx *dbo.DBContext, repeatID string, startAt int64, offset int, now int64) error
+	// GetDirty days marked the longest ago
+	GetDirty(ctx context.Context, limit int) ([]*entity.ReportRollupDirty, error)
+	// DeleteDirtyTx unmark the day unless it has been marked again since
+	DeleteDirtyTx(ctx context.Context, tx *dbo.DBContext, dirty *entity.ReportRollupDirty) error
+	// HasDirty whether any day of the organization in [startAt, endAt) is dirty
+	HasDirty(ctx context.Context, orgID string, startAt, endAt int64) (bool, error)
+
+	QueryFactsTx(ctx context.Context, tx *dbo.DBContext, orgID string, startAt, endAt int64) ([]*entity.ReportRollupFact, error)
+	QueryDimensionsTx(ctx context.Context, tx *dbo.DBContext, scheduleIDs []string) (map[string]*entity.ReportRollupDimensions, error)
+	// ReplaceDailyTx replace the daily rollups of the organization starting at periodStart
+	ReplaceDailyTx(ctx context.Context, tx *dbo.DBContext, orgID string, periodStart int64, rollups []*entity.ReportRollup) error
+	// RefreshWeeklyTx sum the daily rollups of the week into the weekly ones
+	RefreshWeeklyTx(ctx context.Context, tx *dbo.DBContext, orgID string, weekStart int64, now int64) error
+
+	// QueryOrgIDs organizations with schedules dated from startAt on
+	QueryOrgIDs(ctx context.Context, startAt int64) ([]string, error)
+	// QueryDays starts of the days of the organization with schedules dated from startAt on
+	QueryDays(ctx context.Context, orgID string, startAt int64, offset int) ([]int64, error)
+	// DeleteFromTx delete daily and weekly rollups of the organization starting from startAt on
+	DeleteFromTx(ctx context.Context, tx *dbo.DBContext, orgID string, startAt int64) error
+	// GetState nil if the organization has never been rebuilt
+	GetState(ctx context.Context, orgID string) (*entity.ReportRollupState, error)
+	// SaveRebuild the rollups are complete from coverFrom on, an earlier cover is kept
+	SaveRebuild(ctx context.Context, orgID string, coverFrom int64, now int64) error
+	SaveRefreshTx(ctx context.Context, tx *dbo.DBContext, orgID string, now int64) error
+
+	Summarize(ctx context.Context, condition *entity.ReportRollupCondition) ([]*entity.ReportRollupSummary, error)
+	// GetLearnerReportOverview same as the live overview, read from the rollups
+	GetLearnerReportOverview(ctx context.Context, period entity.ReportRollupPeriod, op *entity.Operator, startAt, endAt int64, cond *entity.GetUserCountCondition) (*entity.LearnerReportOverview, error)
+	// GetTeacherLoadItems same as the live teacher load items, read from the rollups
+	GetTeacherLoadItems(ctx context.Context, period entity.ReportRollupPeriod, op *entity.Operator, startAt, endAt int64, teacherIDs []string, classIDs []string) ([]*entity.TeacherLoadItem, error)
+	// GetClassAttendanceCounts online classes of the students of the class by subject, read from the rollups
+	GetClassAttendanceCounts(ctx context.Context, period entity.ReportRollupPeriod, orgID string, startAt, endAt int64, classID string, subjectIDs []string) ([]*entity.ClassAttendanceCount, error)
+}
+
+type reportRollupDA struct {
+	BaseDA
+}
+
+var (
+	_reportRollupDA     IReportRollupDA
+	_reportRollupDAOnce sync.Once
+)
+
+func GetReportRollupDA() IReportRollupDA {
+	_reportRollupDAOnce.Do(func() {
+		_reportRollupDA = &reportRollupDA{}
+	})
+	return _reportRollupDA
+}
+
+func (d *reportRollupDA) MarkDirtyTx(ctx context.Context, tx *dbo.DBContext, scheduleIDs []string, offset int, now int64) error {
+	if len(scheduleIDs) == 0 {
+		return nil
+	}
+	return d.markDirtyTx(ctx, tx, offset, now, "id in (?)", scheduleIDs)
+}
+
+func (d *reportRollupDA) MarkRepeatDirtyTx(ctx context.Context, tx *dbo.DBContext, repeatID string, startAt int64, offset int, now int64) error {
+	return d.markDirtyTx(ctx, tx, offset, now, "repeat_id = ? and start_at >= ?", repeatID, startAt)
+}
+
+func (d *reportRollupDA) markDirtyTx(ctx context.Context, tx *dbo.DBContext, offset int, now int64, where string, args ...interface{}) error {
+	// same dates as the facts: studies by created_at, classes by end_at
+	query := fmt.Sprintf(`insert into %s (org_id, period_start, mark_at)
+select org_id, floor((if(class_type = ?, created_at, end_at) + ?) / ?) * ? - ?, ?
+from %s where %s
+on duplicate key update mark_at = values(mark_at)`, constant.TableNameReportRollupDirty, constant.TableNameSchedule, where)
+
+	params := []interface{}{
+		entity.ScheduleClassTypeHomework,
+		offset, constant.ReportRollupDay, constant.ReportRollupDay, offset,
+		now,
+	}
+	tx.ResetCondition()
+	err := tx.Exec(query, append(params, args...)...).Error
+	if err != nil {
+		log.Error(ctx, "mark report rollups dirty failed", log.Err(err), log.String("where", where), log.Any("args", args))
+		return err
+	}
+	return nil
+}
+
+func (d *reportRollupDA) GetDirty(ctx context.Context, limit int) ([]*entity.ReportRollupDirty, error) {
+	var dirties []*entity.ReportRollupDirty
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+	err := tx.Order("mark_at").Limit(limit).Find(&dirties).Error
+	if err != nil {
+		log.Error(ctx, "get dirty report rollups failed", log.Err(err))
+		return nil, err
+	}
+	return dirties, nil
+}
+
+func (d *reportRollupDA) DeleteDirtyTx(ctx context.Context, tx *dbo.DBContext, dirty *entity.ReportRollupDirty) error {
+	tx.ResetCondition()
+	err := tx.Where("org_id = ? and period_start = ? and mark_at <= ?", dirty.OrgID, dirty.PeriodStart, dirty.MarkAt).
+		Delete(&entity.ReportRollupDirty{}).Error
+	if err != nil {
+		log.Error(ctx, "delete dirty report rollup failed", log.Err(err), log.Any("dirty", dirty))
+		return err
+	}
+	return nil
+}
+
+func (d *reportRollupDA) HasDirty(ctx context.Context, orgID string, startAt, endAt int64) (bool, error) {
+	var count int
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+	err := tx.Model(&entity.ReportRollupDirty{}).
+		Where("org_id = ? and period_start >= ? and period_start < ?", orgID, startAt, endAt).
+		Count(&count).Error
+	if err != nil {
+		log.Error(ctx, "count dirty report rollups failed", log.Err(err), log.String("org_id", orgID))
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (d *reportRollupDA) QueryFactsTx(ctx context.Context, tx *dbo.DBContext, orgID string, startAt, endAt int64) ([]*entity.ReportRollupFact, error) {
+	query := `
+select
+	s.id as schedule_id,
+	av.assessment_type,
+	auv.user_id,
+	auv.user_type,
+	if(s.class_type = ?, 0, s.end_at - s.start_at) as duration,
+	auv.status_by_system <> ? as attended,
+	av.status = ? as completed
+from assessments_users_v2 auv
+inner join assessments_v2 av on av.id = auv.assessment_id
+inner join schedules s on s.id = av.schedule_id
+where s.org_id = ?
+and ((s.class_type = ? and s.created_at >= ? and s.created_at < ?) or (s.class_type <> ? and s.end_at >= ? and s.end_at < ?))
+`
+	var facts []*entity.ReportRollupFact
+	err := d.QueryRawSQLTx(ctx, tx, &facts, query,
+		entity.ScheduleClassTypeHomework,
+		v2.AssessmentUserSystemStatusNotStarted,
+		v2.AssessmentStatusComplete,
+		orgID,
+		entity.ScheduleClassTypeHomework, startAt, endAt,
+		entity.ScheduleClassTypeHomework, startAt, endAt)
+	if err != nil {
+		log.Error(ctx, "query report rollup facts failed", log.Err(err), log.String("org_id", orgID), log.Int64("start_at", startAt))
+		return nil, err
+	}
+	return facts, nil
+}
+
+func (d *reportRollupDA) QueryDimensionsTx(ctx context.Context, tx *dbo.DBContext, scheduleIDs []string) (map[string]*entity.ReportRollupDimensions, error) {
+	dimensions := make(map[string]*entity.ReportRollupDimensions, len(scheduleIDs))
+	if len(scheduleIDs) == 0 {
+		return dimensions, nil
+	}
+
+	var relations []*entity.ScheduleRelation
+	tx.ResetCondition()
+	err := tx.Where("schedule_id in (?) and relation_type in (?)", scheduleIDs, []entity.ScheduleRelationType{
+		entity.ScheduleRelationTypeSchool,
+		entity.ScheduleRelationTypeClassRosterClass,
+		entity.ScheduleRelationTypeSubject,
+	}).Find(&relations).Error
+	if err != nil {
+		log.Error(ctx, "query report rollup dimensions failed", log.Err(err), log.Int("schedules", len(scheduleIDs)))
+		return nil, err
+	}
+
+	for _, relation := range relations {
+		dimension, ok := dimensions[relation.ScheduleID]
+		if !ok {
+			dimension = &entity.ReportRollupDimensions{}
+			dimensions[relation.ScheduleID] = dimension
+		}
+		switch relation.RelationType {
+		case entity.ScheduleRelationTypeSchool:
+			dimension.SchoolIDs = append(dimension.SchoolIDs, relation.RelationID)
+		case entity.ScheduleRelationTypeClassRosterClass:
+			dimension.ClassID = relation.RelationID
+		case entity.ScheduleRelationTypeSubject:
+			dimension.SubjectIDs = append(dimension.SubjectIDs, relation.RelationID)
+		}
+	}
+	return dimensions, nil
+}
+
+func (d *reportRollupDA) ReplaceDailyTx(ctx context.Context, tx *dbo.DBContext, orgID string, periodStart int64, rollups []*entity.ReportRollup) error {
+	tx.ResetCondition()
+	err := tx.Exec(fmt.Sprintf("delete from %s where org_id = ? and period_start = ?", constant.TableNameReportRollupDaily), orgID, periodStart).Error
+	if err != nil {
+		log.Error(ctx, "delete daily report rollups failed", log.Err(err), log.String("org_id", orgID), log.Int64("period_start", periodStart))
+		return err
+	}
+
+	for start := 0; start < len(rollups); start += reportRollupInsertBatchSize {
+		end := start + reportRollupInsertBatchSize
+		if end > len(rollups) {
+			end = len(rollups)
+		}
+
+		models := make([]entity.BatchInsertModeler, 0, end-start)
+		for _, rollup := range rollups[start:end] {
+			models = append(models, rollup)
+		}
+		if err := d.BatchInsertTx(ctx, tx, models...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *reportRollupDA) RefreshWeeklyTx(ctx context.Context, tx *dbo.DBContext, orgID string, weekStart int64, now int64) error {
+	tx.ResetCondition()
+	err := tx.Exec(fmt.Sprintf("delete from %s where org_id = ? and period_start = ?", constant.TableNameReportRollupWeekly), orgID, weekStart).Error
+	if err != nil {
+		log.Error(ctx, "delete weekly report rollups failed", log.Err(err), log.String("org_id", orgID), log.Int64("week_start", weekStart))
+		return err
+	}
+
+	columns := "org_id, period_start, school_id, class_id, subject_id, user_id, user_type, assessment_type, lessons, attended, completed, duration, update_at"
+	query := fmt.Sprintf(`insert into %s (%s)
+select ?, ?, school_id, class_id, subject_id, user_id, user_type, assessment_type, sum(lessons), sum(attended), sum(completed), sum(duration), ?
+from %s
+where org_id = ? and period_start >= ? and period_start < ?
+group by school_id, class_id, subject_id, user_id, user_type, assessment_type`,
+		constant.TableNameReportRollupWeekly, columns, constant.TableNameReportRollupDaily)
+	err = tx.Exec(query, orgID, weekStart, now, orgID, weekStart, weekStart+entity.ReportRollupPeriodWeekly.Seconds()).Error
+	if err != nil {
+		log.Error(ctx, "insert weekly report rollups failed", log.Err(err), log.String("org_id", orgID), log.Int64("week_start", weekStart))
+		return err
+	}
+	return nil
+}
+
+func (d *reportRollupDA) QueryOrgIDs(ctx context.Context, startAt int64) ([]string, error) {
+	query := fmt.Sprintf(`select distinct org_id from %s
+where (class_type = ? and created_at >= ?) or (class_type <> ? and end_at >= ?)`, constant.TableNameSchedule)
+
+	var rows []*struct {
+		OrgID string `gorm:"column:org_id"`
+	}
+	err := d.QueryRawSQL(ctx, &rows, query,
+		entity.ScheduleClassTypeHomework, startAt,
+		entity.ScheduleClassTypeHomework, startAt)
+	if err != nil {
+		log.Error(ctx, "query report rollup organizations failed", log.Err(err), log.Int64("start_at", startAt))
+		return nil, err
+	}
+
+	orgIDs := make([]string, len(rows))
+	for i, row := range rows {
+		orgIDs[i] = row.OrgID
+	}
+	return orgIDs, nil
+}
+
+func (d *reportRollupDA) QueryDays(ctx context.Context, orgID string, startAt int64, offset int) ([]int64, error) {
+	query := fmt.Sprintf(`select distinct floor((if(class_type = ?, created_at, end_at) + ?) / ?) * ? - ? as period_start
+from %s
+where org_id = ? and ((class_type = ? and created_at >= ?) or (class_type <> ? and end_at >= ?))
+order by period_start`, constant.TableNameSchedule)
+
+	var rows []*struct {
+		PeriodStart int64 `gorm:"column:period_start"`
+	}
+	err := d.QueryRawSQL(ctx, &rows, query,
+		entity.ScheduleClassTypeHomework,
+		offset, constant.ReportRollupDay, constant.ReportRollupDay, offset,
+		orgID,
+		entity.ScheduleClassTypeHomework, startAt,
+		entity.ScheduleClassTypeHomework, startAt)
+	if err != nil {
+		log.Error(ctx, "query report rollup days failed", log.Err(err), log.String("org_id", orgID), log.Int64("start_at", startAt))
+		return nil, err
+	}
+
+	days := make([]int64, len(rows))
+	for i, row := range rows {
+		days[i] = row.PeriodStart
+	}
+	return days, nil
+}
+
+func (d *reportRollupDA) DeleteFromTx(ctx context.Context, tx *dbo.DBContext, orgID string, startAt int64) error {
+	for _, table := range []string{constant.TableNameReportRollupDaily, constant.TableNameReportRollupWeekly} {
+		tx.ResetCondition()
+		err := tx.Exec(fmt.Sprintf("delete from %s where org_id = ? and period_start >= ?", table), orgID, startAt).Error
+		if err != nil {
+			log.Error(ctx, "delete report rollups failed", log.Err(err), log.String("table", table), log.String("org_id", orgID), log.Int64("start_at", startAt))
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *reportRollupDA) GetState(ctx context.Context, orgID string) (*entity.ReportRollupState, error) {
+	state := new(entity.ReportRollupState)
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+	err := tx.Where("org_id = ?", orgID).First(state).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Error(ctx, "get report rollup state failed", log.Err(err), log.String("org_id", orgID))
+		return nil, err
+	}
+	return state, nil
+}
+
+func (d *reportRollupDA) SaveRebuild(ctx context.Context, orgID string, coverFrom int64, now int64) error {
+	query := fmt.Sprintf(`insert into %s (org_id, cover_from, rebuild_at, refresh_at, create_at, update_at)
+values (?, ?, ?, ?, ?, ?)
+on duplicate key update cover_from = least(cover_from, values(cover_from)), rebuild_at = values(rebuild_at), update_at = values(update_at)`,
+		constant.TableNameReportRollupState)
+
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+	err := tx.Exec(query, orgID, coverFrom, now, now, now, now).Error
+	if err != nil {
+		log.Error(ctx, "save report rollup rebuild failed", log.Err(err), log.String("org_id", orgID), log.Int64("cover_from", coverFrom))
+		return err
+	}
+	return nil
+}
+
+func (d *reportRollupDA) SaveRefreshTx(ctx context.Context, tx *dbo.DBContext, orgID string, now int64) error {
+	tx.ResetCondition()
+	err := tx.Model(&entity.ReportRollupState{}).
+		Where("org_id = ?", orgID).
+		Updates(map[string]interface{}{"refresh_at": now, "update_at": now}).Error
+	if err != nil {
+		log.Error(ctx, "save report rollup refresh failed", log.Err(err), log.String("org_id", orgID))
+		return err
+	}
+	return nil
+}
+
+func (d *reportRollupDA) Summarize(ctx context.Context, condition *entity.ReportRollupCondition) ([]*entity.ReportRollupSummary, error) {
+	wheres := []string{"org_id = ?", "period_start >= ?", "period_start < ?"}
+	params := []interface{}{condition.OrgID, condition.StartAt, condition.EndAt}
+
+	if condition.SubjectIDs.Valid {
+		wheres = append(wheres, "subject_id in (?)")
+		params = append(params, condition.SubjectIDs.Strings)
+	} else {
+		wheres = append(wheres, "subject_id = ''")
+	}
+
+	if condition.SchoolIDs.Valid {
+		wheres = append(wheres, "school_id in (?)")
+		params = append(params, condition.SchoolIDs.Strings)
+	} else {
+		wheres = append(wheres, "school_id = ''")
+	}
+	if condition.ClassIDs.Valid {
+		wheres = append(wheres, "class_id in (?)")
+		params = append(params, condition.ClassIDs.Strings)
+	}
+	if condition.UserIDs.Valid {
+		wheres = append(wheres, "user_id in (?)")
+		params = append(params, condition.UserIDs.Strings)
+	}
+	if condition.UserType != "" {
+		wheres = append(wheres, "user_type = ?")
+		params = append(params, condition.UserType)
+	}
+	if len(condition.AssessmentTypes) > 0 {
+		wheres = append(wheres, "assessment_type in (?)")
+		params = append(params, condition.AssessmentTypes)
+	}
+
+	query := fmt.Sprintf(`select user_id, assessment_type, sum(lessons) as lessons, sum(attended) as attended, sum(completed) as completed, sum(duration) as duration
+from %s
+where %s
+group by user_id, assessment_type
+order by user_id, assessment_type`, condition.Period.TableName(), strings.Join(wheres, " and "))
+
+	var summaries []*entity.ReportRollupSummary
+	err := d.QueryRawSQL(ctx, &summaries, query, params...)
+	if err != nil {
+		log.Error(ctx, "summarize report rollups failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+	return summaries, nil
+}
+
+func (d *reportRollupDA) GetLearnerReportOverview(ctx context.Context, period entity.ReportRollupPeriod, op *entity.Operator, startAt, endAt int64, cond *entity.GetUserCountCondition) (*entity.LearnerReportOverview, error) {
+	condition := &entity.ReportRollupCondition{
+		Period:    period,
+		OrgID:     op.OrgID,
+		StartAt:   startAt,
+		EndAt:     endAt,
+		SchoolIDs: cond.SchoolIDs,
+		ClassIDs:  cond.ClassIDs,
+		UserType:  string(v2.AssessmentUserTypeStudent),
+		AssessmentTypes: []string{
+			string(v2.AssessmentTypeOnlineClass),
+			string(v2.AssessmentTypeOnlineStudy),
+			string(v2.AssessmentTypeOfflineStudy),
+		},
+	}
+	if cond.StudentID.Valid {
+		condition.UserIDs = entity.NullStrings{Strings: []string{cond.StudentID.String}, Valid: true}
+	}
+
+	summaries, err := d.Summarize(ctx, condition)
+	if err != nil {
+		return nil, err
+	}
+
+	// online classes and studies of every student, in that order like the live query
+	type counts struct{ lessons, attended int }
+	classes := make(map[string]*counts)
+	studies := make(map[string]*counts)
+	for _, summary := range summaries {
+		group := studies
+		if summary.AssessmentType == string(v2.AssessmentTypeOnlineClass) {
+			group = classes
+		}
+		if group[summary.UserID] == nil {
+			group[summary.UserID] = &counts{}
+		}
+		group[summary.UserID].lessons += summary.Lessons
+		group[summary.UserID].attended += summary.Attended
+	}
+
+	rates := make(map[string][]float64)
+	for _, group := range []map[string]*counts{classes, studies} {
+		for studentID, c := range group {
+			if c.lessons > 0 {
+				rates[studentID] = append(rates[studentID], float64(c.attended)/float64(c.lessons))
+			}
+		}
+	}
+	return newLearnerReportOverview(rates), nil
+}
+
+func (d *reportRollupDA) GetTeacherLoadItems(ctx context.Context, period entity.ReportRollupPeriod, op *entity.Operator, startAt, endAt int64, teacherIDs []string, classIDs []string) ([]*entity.TeacherLoadItem, error) {
+	summaries, err := d.Summarize(ctx, &entity.ReportRollupCondition{
+		Period:   period,
+		OrgID:    op.OrgID,
+		StartAt:  startAt,
+		EndAt:    endAt,
+		ClassIDs: entity.NullStrings{Strings: classIDs, Valid: true},
+		UserIDs:  entity.NullStrings{Strings: teacherIDs, Valid: true},
+		UserType: string(v2.AssessmentUserTypeTeacher),
+		AssessmentTypes: []string{
+			string(v2.AssessmentTypeOnlineClass),
+			string(v2.AssessmentTypeOfflineClass),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*entity.TeacherLoadItem{}
+	index := make(map[string]*entity.TeacherLoadItem)
+	for _, summary := range summaries {
+		item, ok := index[summary.UserID]
+		if !ok {
+			item = &entity.TeacherLoadItem{TeacherID: summary.UserID}
+			index[summary.UserID] = item
+			items = append(items, item)
+		}
+		item.TotalLessons += int64(summary.Lessons)
+		item.MissedLessons += int64(summary.Lessons - summary.Attended)
+	}
+	return items, nil
+}
+
+func (d *reportRollupDA) GetClassAttendanceCounts(ctx context.Context, period entity.ReportRollupPeriod, orgID string, startAt, endAt int64, classID string, subjectIDs []string) ([]*entity.ClassAttendanceCount, error) {
+	counts := []*entity.ClassAttendanceCount{}
+	if len(subjectIDs) == 0 {
+		return counts, nil
+	}
+
+	query := fmt.Sprintf(`select user_id as student_id, subject_id, sum(lessons) as lessons, sum(attended) as attended
+from %s
+where org_id = ? and period_start >= ? and period_start < ? and school_id = '' and class_id = ? and subject_id in (?)
+and user_type = ? and assessment_type = ?
+group by user_id, subject_id
+order by subject_id`, period.TableName())
+
+	err := d.QueryRawSQL(ctx, &counts, query,
+		orgID, startAt, endAt, classID, subjectIDs,
+		v2.AssessmentUserTypeStudent, v2.AssessmentTypeOnlineClass)
+	if err != nil {
+		log.Error(ctx, "query class attendance from report rollups failed",
+			log.Err(err),
+			log.String("org_id", orgID),
+			log.String("class_id", classID),
+			log.Strings("subject_ids", subjectIDs))
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,66 @@
+package da
+
+import (
+	"context"
+
+	"github.com/KL-Engineering/common-log/log"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type IStudentRisk interface {
+	// GetStudentRiskClassIDs classes of the organization with schedules in the window
+	GetStudentRiskClassIDs(ctx context.Context, orgID string, startAt, endAt int64) ([]string, error)
+	// GetStudentRiskSubjectIDs subjects of the schedules of the class, schedules without subject are ''
+	GetStudentRiskSubjectIDs(ctx context.Context, classID string) ([]string, error)
+}
+
+// schedules are in the window by start time, study assignments without one by creation time
+const studentRiskScheduleWindow = `((s.start_at >= ? and s.start_at < ?) or (s.start_at = 0 and s.created_at >= ? and s.created_at < ?))`
+
+func (r *ReportDA) GetStudentRiskClassIDs(ctx context.Context, orgID string, startAt, endAt int64) ([]string, error) {
+	sql := `
+select distinct sr.relation_id as class_id
+from schedules s
+inner join schedules_relations sr on sr.schedule_id = s.id and sr.relation_type = ?
+where s.org_id = ? and s.delete_at = 0 and ` + studentRiskScheduleWindow
+
+	var rows []*struct {
+		ClassID string `gorm:"column:class_id"`
+	}
+	err := r.QueryRawSQL(ctx, &rows, sql, entity.ScheduleRelationTypeClassRosterClass, orgID, startAt, endAt, startAt, endAt)
+	if err != nil {
+		log.Error(ctx, "GetStudentRiskClassIDs: query failed", log.Err(err), log.String("org_id", orgID))
+		return nil, err
+	}
+
+	classIDs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		classIDs = append(classIDs, row.ClassID)
+	}
+	return classIDs, nil
+}
+
+func (r *ReportDA) GetStudentRiskSubjectIDs(ctx context.Context, classID string) ([]string, error) {
+	sql := `
+select distinct if(srSubject.relation_id is null, '', srSubject.relation_id) as subject_id
+from schedules s
+inner join schedules_relations srClass on srClass.schedule_id = s.id and srClass.relation_type = ? and srClass.relation_id = ?
+left join schedules_relations srSubject on srSubject.schedule_id = s.id and srSubject.relation_type = ?
+where s.delete_at = 0`
+
+	var rows []*struct {
+		SubjectID string `gorm:"column:subject_id"`
+	}
+	err := r.QueryRawSQL(ctx, &rows, sql, entity.ScheduleRelationTypeClassRosterClass, classID, entity.ScheduleRelationTypeSubject)
+	if err != nil {
+		log.Error(ctx, "GetStudentRiskSubjectIDs: query failed", log.Err(err), log.String("class_id", classID))
+		return nil, err
+	}
+
+	subjectIDs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		subjectIDs = append(subjectIDs, row.SubjectID)
+	}
+	return subjectIDs, nil
+}
@@ -0,0 +1,157 @@
+package da
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type IReportSubscriptionDA interface {
+	dbo.DataAccesser
+	// Claim move the next run of an active subscription from the run due to the next one,
+	// false when another worker has claimed the run or the subscription was changed meanwhile
+	Claim(ctx context.Context, id string, dueRunAt, nextRunAt, now int64) (bool, error)
+}
+
+type reportSubscriptionDA struct {
+	dbo.BaseDA
+}
+
+func (d *reportSubscriptionDA) Claim(ctx context.Context, id string, dueRunAt, nextRunAt, now int64) (bool, error) {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	result := tx.Model(&entity.ReportSubscription{}).
+		Where("id = ? and status = ? and next_run_at = ? and delete_at = 0", id, entity.ReportSubscriptionStatusActive, dueRunAt).
+		Updates(map[string]interface{}{
+			"next_run_at": nextRunAt,
+			"last_run_at": now,
+			"update_at":   now,
+		})
+	if result.Error != nil {
+		log.Error(ctx, "claim report subscription run failed", log.Err(result.Error), log.String("id", id))
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+var (
+	_reportSubscriptionOnce sync.Once
+	_reportSubscriptionDA   IReportSubscriptionDA
+)
+
+func GetReportSubscriptionDA() IReportSubscriptionDA {
+	_reportSubscriptionOnce.Do(func() {
+		_reportSubscriptionDA = &reportSubscriptionDA{}
+	})
+	return _reportSubscriptionDA
+}
+
+type ReportSubscriptionCondition struct {
+	IDs       entity.NullStrings
+	OrgID     sql.NullString
+	CreatorID sql.NullString
+	// Due active subscriptions whose next run is due
+	Due sql.NullInt64
+
+	OrderBy string
+	Pager   dbo.Pager
+}
+
+func (c ReportSubscriptionCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.IDs.Valid {
+		wheres = append(wheres, "id in (?)")
+		params = append(params, c.IDs.Strings)
+	}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.CreatorID.Valid {
+		wheres = append(wheres, "creator_id = ?")
+		params = append(params, c.CreatorID.String)
+	}
+
+	if c.Due.Valid {
+		wheres = append(wheres, "status = ? and next_run_at <= ?")
+		params = append(params, entity.ReportSubscriptionStatusActive, c.Due.Int64)
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c ReportSubscriptionCondition) GetOrderBy() string {
+	if c.OrderBy != "" {
+		return c.OrderBy
+	}
+	return "create_at desc"
+}
+
+func (c ReportSubscriptionCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
+
+type IReportSubscriptionDeliveryDA interface {
+	dbo.DataAccesser
+}
+
+type reportSubscriptionDeliveryDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_reportSubscriptionDeliveryOnce sync.Once
+	_reportSubscriptionDeliveryDA   IReportSubscriptionDeliveryDA
+)
+
+func GetReportSubscriptionDeliveryDA() IReportSubscriptionDeliveryDA {
+	_reportSubscriptionDeliveryOnce.Do(func() {
+		_reportSubscriptionDeliveryDA = &reportSubscriptionDeliveryDA{}
+	})
+	return _reportSubscriptionDeliveryDA
+}
+
+type ReportSubscriptionDeliveryCondition struct {
+	OrgID          sql.NullString
+	SubscriptionID sql.NullString
+
+	Pager dbo.Pager
+}
+
+func (c ReportSubscriptionDeliveryCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.SubscriptionID.Valid {
+		wheres = append(wheres, "subscription_id = ?")
+		params = append(params, c.SubscriptionID.String)
+	}
+
+	return wheres, params
+}
+
+func (c ReportSubscriptionDeliveryCondition) GetOrderBy() string {
+	return "create_at desc"
+}
+
+func (c ReportSubscriptionDeliveryCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
@@ -0,0 +1,70 @@
+package da
+
+import (
+	"context"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+	"github.com/jinzhu/gorm"
+)
+
+// IRevisionDA the revision column of the resources the api serves with an ETag.
+// It is not a field of the entities, a Save of an entity read earlier must not roll it back.
+type IRevisionDA interface {
+	GetRevision(ctx context.Context, table, id string) (int64, error)
+	// IncreaseTx increase the revision of a resource written in tx, the row stays locked until tx ends.
+	// With expected >= 0 only the expected revision is increased, false when the row has another one.
+	IncreaseTx(ctx context.Context, tx *dbo.DBContext, table, id string, expected int64) (bool, error)
+}
+
+type revisionDA struct{}
+
+func (d *revisionDA) GetRevision(ctx context.Context, table, id string) (int64, error) {
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+
+	var row struct {
+		Revision int64 `gorm:"column:revision"`
+	}
+	err := tx.Table(table).Select("revision").Where("id = ?", id).Scan(&row).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return 0, dbo.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get revision failed", log.Err(err), log.String("table", table), log.String("id", id))
+		return 0, err
+	}
+	return row.Revision, nil
+}
+
+func (d *revisionDA) IncreaseTx(ctx context.Context, tx *dbo.DBContext, table, id string, expected int64) (bool, error) {
+	tx.ResetCondition()
+
+	db := tx.Table(table).Where("id = ?", id)
+	if expected >= 0 {
+		db = db.Where("revision = ?", expected)
+	}
+	result := db.UpdateColumn("revision", gorm.Expr("revision + 1"))
+	if result.Error != nil {
+		log.Error(ctx, "increase revision failed",
+			log.Err(result.Error),
+			log.String("table", table),
+			log.String("id", id),
+			log.Int64("expected", expected))
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+var (
+	_revisionOnce sync.Once
+	_revisionDA   IRevisionDA
+)
+
+func GetRevisionDA() IRevisionDA {
+	_revisionOnce.Do(func() {
+		_revisionDA = &revisionDA{}
+	})
+	return _revisionDA
+}
@@ -0,0 +1,211 @@
+package da
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type IStudentRiskSettingDA interface {
+	dbo.DataAccesser
+}
+
+type studentRiskSettingDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_studentRiskSettingOnce sync.Once
+	_studentRiskSettingDA   IStudentRiskSettingDA
+)
+
+func GetStudentRiskSettingDA() IStudentRiskSettingDA {
+	_studentRiskSettingOnce.Do(func() {
+		_studentRiskSettingDA = &studentRiskSettingDA{}
+	})
+	return _studentRiskSettingDA
+}
+
+type StudentRiskSettingCondition struct {
+	Enabled sql.NullBool
+
+	Pager dbo.Pager
+}
+
+func (c StudentRiskSettingCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.Enabled.Valid {
+		wheres = append(wheres, "enabled = ?")
+		params = append(params, c.Enabled.Bool)
+	}
+
+	return wheres, params
+}
+
+func (c StudentRiskSettingCondition) GetOrderBy() string {
+	return "org_id"
+}
+
+func (c StudentRiskSettingCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
+
+type IStudentRiskFlagDA interface {
+	dbo.DataAccesser
+	// GetAtRiskClassIDs classes of the organization with students flagged at risk
+	GetAtRiskClassIDs(ctx context.Context, orgID string) ([]string, error)
+	// UpsertTx saves the flag of the student in the class, the id and create_at of an existing flag are kept
+	UpsertTx(ctx context.Context, tx *dbo.DBContext, flag *entity.StudentRiskFlag) error
+}
+
+type studentRiskFlagDA struct {
+	dbo.BaseDA
+}
+
+func (d *studentRiskFlagDA) GetAtRiskClassIDs(ctx context.Context, orgID string) ([]string, error) {
+	sql := fmt.Sprintf("select distinct class_id from %s where org_id = ? and at_risk = ?", entity.StudentRiskFlag{}.TableName())
+
+	var rows []*struct {
+		ClassID string `gorm:"column:class_id"`
+	}
+	err := d.QueryRawSQL(ctx, &rows, sql, orgID, true)
+	if err != nil {
+		log.Error(ctx, "query classes with students at risk failed", log.Err(err), log.String("org_id", orgID))
+		return nil, err
+	}
+
+	classIDs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		classIDs = append(classIDs, row.ClassID)
+	}
+	return classIDs, nil
+}
+
+func (d *studentRiskFlagDA) UpsertTx(ctx context.Context, tx *dbo.DBContext, flag *entity.StudentRiskFlag) error {
+	tx.ResetCondition()
+	result := tx.Set("gorm:insert_option", "ON DUPLICATE KEY UPDATE "+
+		"at_risk = VALUES(at_risk), score = VALUES(score), signals = VALUES(signals), reasons = VALUES(reasons), "+
+		"window_start = VALUES(window_start), window_end = VALUES(window_end), changed_at = VALUES(changed_at), "+
+		"update_at = VALUES(update_at)").Create(flag)
+	if result.Error != nil {
+		log.Error(ctx, "upsert student risk flag failed", log.Err(result.Error), log.Any("flag", flag))
+		return result.Error
+	}
+	return nil
+}
+
+var (
+	_studentRiskFlagOnce sync.Once
+	_studentRiskFlagDA   IStudentRiskFlagDA
+)
+
+func GetStudentRiskFlagDA() IStudentRiskFlagDA {
+	_studentRiskFlagOnce.Do(func() {
+		_studentRiskFlagDA = &studentRiskFlagDA{}
+	})
+	return _studentRiskFlagDA
+}
+
+type StudentRiskFlagCondition struct {
+	OrgID    sql.NullString
+	ClassIDs entity.NullStrings
+	AtRisk   sql.NullBool
+
+	Pager dbo.Pager
+}
+
+func (c StudentRiskFlagCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.ClassIDs.Valid {
+		wheres = append(wheres, "class_id in (?)")
+		params = append(params, c.ClassIDs.Strings)
+	}
+
+	if c.AtRisk.Valid {
+		wheres = append(wheres, "at_risk = ?")
+		params = append(params, c.AtRisk.Bool)
+	}
+
+	return wheres, params
+}
+
+func (c StudentRiskFlagCondition) GetOrderBy() string {
+	return "score desc"
+}
+
+func (c StudentRiskFlagCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
+
+type IStudentRiskFlagHistoryDA interface {
+	dbo.DataAccesser
+}
+
+type studentRiskFlagHistoryDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_studentRiskFlagHistoryOnce sync.Once
+	_studentRiskFlagHistoryDA   IStudentRiskFlagHistoryDA
+)
+
+func GetStudentRiskFlagHistoryDA() IStudentRiskFlagHistoryDA {
+	_studentRiskFlagHistoryOnce.Do(func() {
+		_studentRiskFlagHistoryDA = &studentRiskFlagHistoryDA{}
+	})
+	return _studentRiskFlagHistoryDA
+}
+
+type StudentRiskFlagHistoryCondition struct {
+	OrgID     sql.NullString
+	ClassID   sql.NullString
+	StudentID sql.NullString
+
+	Pager dbo.Pager
+}
+
+func (c StudentRiskFlagHistoryCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.ClassID.Valid {
+		wheres = append(wheres, "class_id = ?")
+		params = append(params, c.ClassID.String)
+	}
+
+	if c.StudentID.Valid {
+		wheres = append(wheres, "student_id = ?")
+		params = append(params, c.StudentID.String)
+	}
+
+	return wheres, params
+}
+
+func (c StudentRiskFlagHistoryCondition) GetOrderBy() string {
+	return "create_at desc"
+}
+
+func (c StudentRiskFlagHistoryCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
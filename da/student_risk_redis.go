@@ -0,0 +1,77 @@
+package da
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/ro"
+	"github.com/go-redis/redis/v8"
+)
+
+// IStudentRiskRedisDA when the classes were evaluated, shared by the instances of the service
+type IStudentRiskRedisDA interface {
+	// GetEvaluatedAt last evaluations of the classes, classes never evaluated are left out
+	GetEvaluatedAt(ctx context.Context, classIDs []string) (map[string]int64, error)
+	// SetEvaluatedAt marks the class evaluated at the time
+	SetEvaluatedAt(ctx context.Context, classID string, evaluatedAt int64) error
+	// Forget the classes evaluated before the time
+	Forget(ctx context.Context, before int64) error
+}
+
+type studentRiskRedisDA struct{}
+
+var (
+	_studentRiskRedisOnce sync.Once
+	_studentRiskRedisDA   IStudentRiskRedisDA
+)
+
+func GetStudentRiskRedisDA() IStudentRiskRedisDA {
+	_studentRiskRedisOnce.Do(func() {
+		_studentRiskRedisDA = &studentRiskRedisDA{}
+	})
+	return _studentRiskRedisDA
+}
+
+func (r *studentRiskRedisDA) GetEvaluatedAt(ctx context.Context, classIDs []string) (map[string]int64, error) {
+	result := make(map[string]int64, len(classIDs))
+	if len(classIDs) == 0 {
+		return result, nil
+	}
+
+	pipe := ro.MustGetRedis(ctx).Pipeline()
+	cmds := make([]*redis.FloatCmd, len(classIDs))
+	for i, classID := range classIDs {
+		cmds[i] = pipe.ZScore(ctx, RedisKeyStudentRiskEvaluated, classID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		log.Error(ctx, "get student risk evaluations failed", log.Err(err), log.Int("classes", len(classIDs)))
+		return nil, err
+	}
+
+	for i, cmd := range cmds {
+		if cmd.Err() == nil {
+			result[classIDs[i]] = int64(cmd.Val())
+		}
+	}
+	return result, nil
+}
+
+func (r *studentRiskRedisDA) SetEvaluatedAt(ctx context.Context, classID string, evaluatedAt int64) error {
+	err := ro.MustGetRedis(ctx).ZAdd(ctx, RedisKeyStudentRiskEvaluated, &redis.Z{Score: float64(evaluatedAt), Member: classID}).Err()
+	if err != nil {
+		log.Error(ctx, "set student risk evaluation failed", log.Err(err), log.String("class_id", classID))
+		return err
+	}
+	return nil
+}
+
+func (r *studentRiskRedisDA) Forget(ctx context.Context, before int64) error {
+	err := ro.MustGetRedis(ctx).ZRemRangeByScore(ctx, RedisKeyStudentRiskEvaluated, "-inf", "("+strconv.FormatInt(before, 10)).Err()
+	if err != nil {
+		log.Error(ctx, "forget student risk evaluations failed", log.Err(err), log.Int64("before", before))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package da
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/ro"
+	"github.com/go-redis/redis/v8"
+)
+
+// IStudentUsageIngestRedisDA keys of the student usage records written recently, shared by the instances of the service
+type IStudentUsageIngestRedisDA interface {
+	// Claim marks the keys as written, false for the keys claimed before
+	Claim(ctx context.Context, keys []string, expiration time.Duration) ([]bool, error)
+	// Release the keys of records which failed to be written, so that they can be ingested again
+	Release(ctx context.Context, keys []string) error
+	// DeadLetter appends the lines of records which failed to be written, see cmd/student_usage_replay
+	DeadLetter(ctx context.Context, lines []string) error
+}
+
+type studentUsageIngestRedisDA struct{}
+
+var (
+	_studentUsageIngestRedisOnce sync.Once
+	_studentUsageIngestRedisDA   IStudentUsageIngestRedisDA
+)
+
+func GetStudentUsageIngestRedisDA() IStudentUsageIngestRedisDA {
+	_studentUsageIngestRedisOnce.Do(func() {
+		_studentUsageIngestRedisDA = &studentUsageIngestRedisDA{}
+	})
+	return _studentUsageIngestRedisDA
+}
+
+func (r *studentUsageIngestRedisDA) key(key string) string {
+	return fmt.Sprintf("%v:%v", RedisKeyPrefixStudentUsageIngest, key)
+}
+
+func (r *studentUsageIngestRedisDA) Claim(ctx context.Context, keys []string, expiration time.Duration) ([]bool, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	pipe := ro.MustGetRedis(ctx).Pipeline()
+	cmds := make([]*redis.BoolCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.SetNX(ctx, r.key(key), 1, expiration)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Error(ctx, "claim student usage ingest keys failed", log.Err(err), log.Int("keys", len(keys)))
+		return nil, err
+	}
+
+	claimed := make([]bool, len(cmds))
+	for i, cmd := range cmds {
+		claimed[i] = cmd.Val()
+	}
+	return claimed, nil
+}
+
+func (r *studentUsageIngestRedisDA) Release(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = r.key(key)
+	}
+	if err := ro.MustGetRedis(ctx).Del(ctx, redisKeys...).Err(); err != nil {
+		log.Error(ctx, "release student usage ingest keys failed", log.Err(err), log.Int("keys", len(keys)))
+		return err
+	}
+	return nil
+}
+
+func (r *studentUsageIngestRedisDA) DeadLetter(ctx context.Context, lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	values := make([]interface{}, len(lines))
+	for i, line := range lines {
+		values[i] = line
+	}
+	if err := ro.MustGetRedis(ctx).RPush(ctx, RedisKeyStudentUsageIngestDeadLetter, values...).Err(); err != nil {
+		log.Error(ctx, "dead letter student usage records failed", log.Err(err), log.Int("records", len(lines)))
+		return err
+	}
+	return nil
+}
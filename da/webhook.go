@@ -0,0 +1,145 @@
+package da
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type IWebhookDA interface {
+	dbo.DataAccesser
+}
+
+type webhookDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_webhookOnce sync.Once
+	_webhookDA   IWebhookDA
+)
+
+func GetWebhookDA() IWebhookDA {
+	_webhookOnce.Do(func() {
+		_webhookDA = &webhookDA{}
+	})
+	return _webhookDA
+}
+
+type WebhookCondition struct {
+	IDs     entity.NullStrings
+	OrgID   sql.NullString
+	Enabled sql.NullBool
+}
+
+func (c WebhookCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.IDs.Valid {
+		wheres = append(wheres, "id in (?)")
+		params = append(params, c.IDs.Strings)
+	}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.Enabled.Valid {
+		wheres = append(wheres, "enabled = ?")
+		params = append(params, c.Enabled.Bool)
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c WebhookCondition) GetOrderBy() string {
+	return "create_at desc"
+}
+
+func (c WebhookCondition) GetPager() *dbo.Pager {
+	return &dbo.NoPager
+}
+
+type IWebhookDeliveryDA interface {
+	dbo.DataAccesser
+}
+
+type webhookDeliveryDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_webhookDeliveryOnce sync.Once
+	_webhookDeliveryDA   IWebhookDeliveryDA
+)
+
+func GetWebhookDeliveryDA() IWebhookDeliveryDA {
+	_webhookDeliveryOnce.Do(func() {
+		_webhookDeliveryDA = &webhookDeliveryDA{}
+	})
+	return _webhookDeliveryDA
+}
+
+type WebhookDeliveryCondition struct {
+	OrgID     sql.NullString
+	WebhookID sql.NullString
+	EventType sql.NullString
+	Status    sql.NullString
+	// Due pending deliveries whose next attempt is due
+	Due sql.NullInt64
+
+	OrderBy string
+	Pager   dbo.Pager
+}
+
+func (c WebhookDeliveryCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.WebhookID.Valid {
+		wheres = append(wheres, "webhook_id = ?")
+		params = append(params, c.WebhookID.String)
+	}
+
+	if c.EventType.Valid {
+		wheres = append(wheres, "event_type = ?")
+		params = append(params, c.EventType.String)
+	}
+
+	if c.Status.Valid {
+		wheres = append(wheres, "status = ?")
+		params = append(params, c.Status.String)
+	}
+
+	if c.Due.Valid {
+		wheres = append(wheres, "status = ? and next_attempt_at <= ?")
+		params = append(params, entity.WebhookDeliveryStatusPending, c.Due.Int64)
+	}
+
+	wheres = append(wheres, "(delete_at=0)")
+
+	return wheres, params
+}
+
+func (c WebhookDeliveryCondition) GetOrderBy() string {
+	if c.OrderBy != "" {
+		return c.OrderBy
+	}
+	return "create_at desc"
+}
+
+func (c WebhookDeliveryCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
@@ -0,0 +1,162 @@
+package da
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type IXAPIStatementDA interface {
+	dbo.DataAccesser
+	// InsertIgnore statements stored before in the org are skipped
+	InsertIgnore(ctx context.Context, records []*entity.XAPIStatementRecord) error
+	InsertIgnoreTx(ctx context.Context, tx *dbo.DBContext, records []*entity.XAPIStatementRecord) error
+	MarkForwarded(ctx context.Context, orgID string, ids []string, forwardedAt int64) error
+}
+
+type xapiStatementDA struct {
+	dbo.BaseDA
+}
+
+var (
+	_xapiStatementOnce sync.Once
+	_xapiStatementDA   IXAPIStatementDA
+)
+
+func GetXAPIStatementDA() IXAPIStatementDA {
+	_xapiStatementOnce.Do(func() {
+		_xapiStatementDA = &xapiStatementDA{}
+	})
+	return _xapiStatementDA
+}
+
+func (d *xapiStatementDA) InsertIgnore(ctx context.Context, records []*entity.XAPIStatementRecord) error {
+	return d.InsertIgnoreTx(ctx, dbo.MustGetDB(ctx), records)
+}
+
+func (d *xapiStatementDA) InsertIgnoreTx(ctx context.Context, tx *dbo.DBContext, records []*entity.XAPIStatementRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var columns []string
+	placeholders := make([]string, 0, len(records))
+	params := make([]interface{}, 0, len(records)*14)
+	for _, record := range records {
+		cols, values := record.GetBatchInsertColsAndValues()
+		columns = cols
+		placeholders = append(placeholders, "("+strings.TrimSuffix(strings.Repeat("?,", len(cols)), ",")+")")
+		params = append(params, values...)
+	}
+
+	tx.ResetCondition()
+	query := fmt.Sprintf("insert ignore into %s (%s) values %s", constant.TableNameXAPIStatement, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	if err := tx.Exec(query, params...).Error; err != nil {
+		log.Error(ctx, "insert xapi statements failed", log.Err(err), log.Int("count", len(records)))
+		return err
+	}
+	return nil
+}
+
+func (d *xapiStatementDA) MarkForwarded(ctx context.Context, orgID string, ids []string, forwardedAt int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tx := dbo.MustGetDB(ctx)
+	tx.ResetCondition()
+	query := fmt.Sprintf("update %s set forward_status = ?, attempts = attempts + 1, last_error = '', forwarded_at = ?, update_at = ? where org_id = ? and id in (?)", constant.TableNameXAPIStatement)
+	if err := tx.Exec(query, entity.XAPIForwardStatusForwarded, forwardedAt, forwardedAt, orgID, ids).Error; err != nil {
+		log.Error(ctx, "mark xapi statements forwarded failed", log.Err(err), log.String("org_id", orgID), log.Strings("ids", ids))
+		return err
+	}
+	return nil
+}
+
+type XAPIStatementCondition struct {
+	ID       sql.NullString
+	IDs      entity.NullStrings
+	OrgID    sql.NullString
+	ActorKey sql.NullString
+	VerbID   sql.NullString
+	ObjectID sql.NullString
+	// CreateAtGe CreateAtLe the stored time
+	CreateAtGe sql.NullInt64
+	CreateAtLe sql.NullInt64
+	// Due pending statements whose next attempt is due
+	Due sql.NullInt64
+
+	OrderBy string
+	Pager   dbo.Pager
+}
+
+func (c XAPIStatementCondition) GetConditions() ([]string, []interface{}) {
+	var wheres []string
+	var params []interface{}
+
+	if c.ID.Valid {
+		wheres = append(wheres, "id = ?")
+		params = append(params, c.ID.String)
+	}
+
+	if c.IDs.Valid {
+		wheres = append(wheres, "id in (?)")
+		params = append(params, c.IDs.Strings)
+	}
+
+	if c.OrgID.Valid {
+		wheres = append(wheres, "org_id = ?")
+		params = append(params, c.OrgID.String)
+	}
+
+	if c.ActorKey.Valid {
+		wheres = append(wheres, "actor_key = ?")
+		params = append(params, c.ActorKey.String)
+	}
+
+	if c.VerbID.Valid {
+		wheres = append(wheres, "verb_id = ?")
+		params = append(params, c.VerbID.String)
+	}
+
+	if c.ObjectID.Valid {
+		wheres = append(wheres, "object_id = ?")
+		params = append(params, c.ObjectID.String)
+	}
+
+	if c.CreateAtGe.Valid {
+		wheres = append(wheres, "create_at >= ?")
+		params = append(params, c.CreateAtGe.Int64)
+	}
+
+	if c.CreateAtLe.Valid {
+		wheres = append(wheres, "create_at <= ?")
+		params = append(params, c.CreateAtLe.Int64)
+	}
+
+	if c.Due.Valid {
+		wheres = append(wheres, "forward_status = ? and next_attempt_at <= ?")
+		params = append(params, entity.XAPIForwardStatusPending, c.Due.Int64)
+	}
+
+	return wheres, params
+}
+
+func (c XAPIStatementCondition) GetOrderBy() string {
+	if c.OrderBy != "" {
+		return c.OrderBy
+	}
+	return "create_at desc"
+}
+
+func (c XAPIStatementCondition) GetPager() *dbo.Pager {
+	return &c.Pager
+}
@@ -0,0 +1,232 @@
+package entity
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+// AuditSnapshotLimit snapshots longer than it are truncated
+const AuditSnapshotLimit = 64 * 1024
+
+// AuditLog one mutating request of an operator, rows are never updated
+type AuditLog struct {
+	ID         string `gorm:"column:id;PRIMARY_KEY"`
+	OrgID      string `gorm:"column:org_id"`
+	ActorID    string `gorm:"column:actor_id"`
+	Action     string `gorm:"column:action"`
+	TargetType string `gorm:"column:target_type"`
+	TargetID   string `gorm:"column:target_id"`
+	// Before snapshot of the target recorded by the model, empty when the model does not record it
+	Before string `gorm:"column:before_snapshot"`
+	// After request body, or the snapshot recorded by the model
+	After      string `gorm:"column:after_snapshot"`
+	Method     string `gorm:"column:method"`
+	Path       string `gorm:"column:path"`
+	StatusCode int    `gorm:"column:status_code"`
+	IP         string `gorm:"column:ip"`
+	UserAgent  string `gorm:"column:user_agent"`
+	Duration   int64  `gorm:"column:duration;type:bigint"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+}
+
+func (AuditLog) TableName() string {
+	return constant.TableNameAuditLog
+}
+
+// AuditSetting organizations without a setting keep logs for the configured default retention
+type AuditSetting struct {
+	OrgID         string `gorm:"column:org_id;PRIMARY_KEY"`
+	RetentionDays int    `gorm:"column:retention_days"`
+	UpdaterID     string `gorm:"column:updater_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+}
+
+func (AuditSetting) TableName() string {
+	return constant.TableNameAuditSetting
+}
+
+// AuditAction the action is the name of the api handler, e.g. updateSchedule
+func AuditAction(handlerName string) string {
+	name := handlerName
+	if index := strings.LastIndex(name, "."); index >= 0 {
+		name = name[index+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// AuditTargetType the target type is the resource of the route, e.g. schedules of /v1/schedules/:id
+func AuditTargetType(fullPath string) string {
+	segments := strings.Split(strings.Trim(fullPath, "/"), "/")
+	for i, segment := range segments {
+		if i == 0 && segment == "v1" {
+			continue
+		}
+		if segment == "internal" || segment == "admin" || strings.HasPrefix(segment, ":") {
+			continue
+		}
+		return segment
+	}
+	return ""
+}
+
+var auditReadOnlyActionPrefixes = []string{"get", "query", "list", "search", "check", "has", "summary"}
+
+// IsAuditReadOnlyAction some queries are posted for long conditions, they change nothing
+func IsAuditReadOnlyAction(action string) bool {
+	for _, prefix := range auditReadOnlyActionPrefixes {
+		if strings.HasPrefix(action, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// TruncateAuditSnapshot keep the head of a long snapshot, a multibyte character is not cut in half
+func TruncateAuditSnapshot(snapshot string) string {
+	if len(snapshot) <= AuditSnapshotLimit {
+		return snapshot
+	}
+	end := AuditSnapshotLimit
+	for end > 0 && !utf8.RuneStart(snapshot[end]) {
+		end--
+	}
+	return snapshot[:end]
+}
+
+type AuditLogQueryReq struct {
+	ActorID    string `form:"actor_id"`
+	Action     string `form:"action"`
+	TargetType string `form:"target_type"`
+	TargetID   string `form:"target_id"`
+	Method     string `form:"method"`
+	// StartAt EndAt unix seconds, zero means no limit
+	StartAt   int64 `form:"start_at"`
+	EndAt     int64 `form:"end_at"`
+	PageIndex int   `form:"page"`
+	PageSize  int   `form:"page_size"`
+}
+
+func (r AuditLogQueryReq) Valid() bool {
+	if r.StartAt < 0 || r.EndAt < 0 {
+		return false
+	}
+	return r.EndAt == 0 || r.StartAt <= r.EndAt
+}
+
+type AuditLogView struct {
+	ID         string `json:"id"`
+	ActorID    string `json:"actor_id"`
+	Action     string `json:"action"`
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	Before     string `json:"before"`
+	After      string `json:"after"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	StatusCode int    `json:"status_code"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	Duration   int64  `json:"duration"`
+	CreateAt   int64  `json:"create_at"`
+}
+
+type AuditLogPageReply struct {
+	Total int             `json:"total"`
+	Data  []*AuditLogView `json:"data"`
+}
+
+type AuditSettingView struct {
+	RetentionDays int   `json:"retention_days"`
+	UpdateAt      int64 `json:"update_at"`
+}
+
+type AuditSettingUpdateReq struct {
+	RetentionDays int `json:"retention_days"`
+}
+
+func (r AuditSettingUpdateReq) Valid() bool {
+	return r.RetentionDays > 0 && r.RetentionDays <= constant.AuditMaxRetentionDays
+}
+
+// AuditRedacted replaces the value of a sensitive field in a snapshot
+const AuditRedacted = "[REDACTED]"
+
+// auditSensitiveKeyParts a field whose normalized name contains one of them is redacted
+var auditSensitiveKeyParts = []string{
+	"password", "passwd", "secret", "token", "apikey", "privatekey", "authorization", "cookie",
+	"email", "phone", "mobile", "address", "birthday", "dateofbirth",
+	"givenname", "familyname", "fullname", "firstname", "lastname", "username",
+}
+
+// isAuditSensitiveKey field names are compared case and separator insensitively, e.g. api_key and apiKey
+func isAuditSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	key = strings.NewReplacer("_", "", "-", "").Replace(key)
+	for _, part := range auditSensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactAuditBody keep a request body in the audit log without credentials and personal data,
+// a body which can not be parsed, truncated ones included, is not kept at all
+func RedactAuditBody(contentType, body string) string {
+	if body == "" {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		var value interface{}
+		if err := json.Unmarshal([]byte(body), &value); err != nil {
+			return ""
+		}
+		redacted, err := json.Marshal(redactAuditValue(value))
+		if err != nil {
+			return ""
+		}
+		return string(redacted)
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			return ""
+		}
+		for key := range values {
+			if isAuditSensitiveKey(key) {
+				values[key] = []string{AuditRedacted}
+			}
+		}
+		return values.Encode()
+	default:
+		return ""
+	}
+}
+
+func redactAuditValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isAuditSensitiveKey(key) {
+				v[key] = AuditRedacted
+				continue
+			}
+			v[key] = redactAuditValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactAuditValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,94 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAuditAction(t *testing.T) {
+	tests := map[string]string{
+		"github.com/KL-Engineering/kidsloop-cms-service/api.(*Server).updateSchedule-fm": "updateSchedule",
+		"github.com/KL-Engineering/kidsloop-cms-service/api.Server.deleteFolder-fm":      "deleteFolder",
+		"rejectOutcome": "rejectOutcome",
+	}
+	for name, want := range tests {
+		if got := AuditAction(name); got != want {
+			t.Errorf("AuditAction(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestAuditTargetType(t *testing.T) {
+	tests := map[string]string{
+		"/v1/schedules/:id":                           "schedules",
+		"/v1/contents/:content_id/publish":            "contents",
+		"/v1/internal/schedules/update_review_status": "schedules",
+		"/v1/admin/domain_events/:id/replay":          "domain_events",
+		"":                                            "",
+	}
+	for path, want := range tests {
+		if got := AuditTargetType(path); got != want {
+			t.Errorf("AuditTargetType(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestIsAuditReadOnlyAction(t *testing.T) {
+	if !IsAuditReadOnlyAction("getScheduleTimeViewList") || !IsAuditReadOnlyAction("queryPublishedOutcomes") {
+		t.Error("handlers posting queries should be read only")
+	}
+	if IsAuditReadOnlyAction("rejectOutcome") || IsAuditReadOnlyAction("deleteFolder") {
+		t.Error("mutating handlers should not be read only")
+	}
+}
+
+func TestTruncateAuditSnapshot(t *testing.T) {
+	short := `{"id":"1"}`
+	if got := TruncateAuditSnapshot(short); got != short {
+		t.Errorf("short snapshot should be kept: %q", got)
+	}
+
+	long := strings.Repeat("a", AuditSnapshotLimit-1) + "中文"
+	got := TruncateAuditSnapshot(long)
+	if len(got) > AuditSnapshotLimit || !utf8.ValidString(got) {
+		t.Errorf("long snapshot should be truncated on a character boundary, length %d", len(got))
+	}
+}
+
+func TestAuditLogQueryReqValid(t *testing.T) {
+	if !(AuditLogQueryReq{}).Valid() || !(AuditLogQueryReq{StartAt: 100}).Valid() || !(AuditLogQueryReq{StartAt: 100, EndAt: 200}).Valid() {
+		t.Error("open and ordered ranges should be valid")
+	}
+	if (AuditLogQueryReq{StartAt: 200, EndAt: 100}).Valid() || (AuditLogQueryReq{StartAt: -1}).Valid() {
+		t.Error("reversed or negative ranges should be invalid")
+	}
+}
+
+func TestAuditSettingUpdateReqValid(t *testing.T) {
+	if !(AuditSettingUpdateReq{RetentionDays: 30}).Valid() {
+		t.Error("30 days should be valid")
+	}
+	if (AuditSettingUpdateReq{}).Valid() || (AuditSettingUpdateReq{RetentionDays: 100000}).Valid() {
+		t.Error("zero and too long retention should be invalid")
+	}
+}
+
+func TestRedactAuditBody(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		{"application/json", `{"name":"math","api_key":"k","users":[{"id":"1","Email":"a@b.c","givenName":"A"}]}`, `{"api_key":"[REDACTED]","name":"math","users":[{"Email":"[REDACTED]","givenName":"[REDACTED]","id":"1"}]}`},
+		{"application/json; charset=utf-8", `[{"password":"p"}]`, `[{"password":"[REDACTED]"}]`},
+		{"application/json", `{"name":"trunc`, ""},
+		{"application/x-www-form-urlencoded", "name=math&phone=123", "name=math&phone=%5BREDACTED%5D"},
+		{"text/plain", "token=1", ""},
+	}
+	for _, tt := range tests {
+		if got := RedactAuditBody(tt.contentType, tt.body); got != tt.want {
+			t.Errorf("RedactAuditBody(%q, %q) = %q, want %q", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package entity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+// DirectoryType the kinds of rows of the local directory, relation rows are identified by all of their columns
+type DirectoryType string
+
+const (
+	DirectoryTypeUser           DirectoryType = "users"
+	DirectoryTypeOrganization   DirectoryType = "organizations"
+	DirectoryTypeSchool         DirectoryType = "schools"
+	DirectoryTypeClass          DirectoryType = "classes"
+	DirectoryTypeRole           DirectoryType = "roles"
+	DirectoryTypeProgram        DirectoryType = "programs"
+	DirectoryTypeSubject        DirectoryType = "subjects"
+	DirectoryTypeMembership     DirectoryType = "memberships"
+	DirectoryTypeMembershipRole DirectoryType = "membership_roles"
+	DirectoryTypeRolePermission DirectoryType = "role_permissions"
+	DirectoryTypeClassSchool    DirectoryType = "class_schools"
+	DirectoryTypeClassMember    DirectoryType = "class_members"
+	DirectoryTypeProgramSubject DirectoryType = "program_subjects"
+)
+
+const (
+	DirectoryStatusActive   = "active"
+	DirectoryStatusInactive = "inactive"
+
+	DirectoryJoinTypeTeaching = "teaching"
+	DirectoryJoinTypeStudying = "studying"
+)
+
+// DirectoryTypes in the order of import, rows referenced by relations come first
+var DirectoryTypes = []DirectoryType{
+	DirectoryTypeOrganization,
+	DirectoryTypeUser,
+	DirectoryTypeSchool,
+	DirectoryTypeClass,
+	DirectoryTypeRole,
+	DirectoryTypeProgram,
+	DirectoryTypeSubject,
+	DirectoryTypeMembership,
+	DirectoryTypeMembershipRole,
+	DirectoryTypeRolePermission,
+	DirectoryTypeClassSchool,
+	DirectoryTypeClassMember,
+	DirectoryTypeProgramSubject,
+}
+
+func (t DirectoryType) Valid() bool {
+	for _, directoryType := range DirectoryTypes {
+		if t == directoryType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRelation relation rows are inserted or deleted, other rows are upserted by id and deactivated instead of deleted
+func (t DirectoryType) IsRelation() bool {
+	switch t {
+	case DirectoryTypeMembership, DirectoryTypeMembershipRole, DirectoryTypeRolePermission,
+		DirectoryTypeClassSchool, DirectoryTypeClassMember, DirectoryTypeProgramSubject:
+		return true
+	default:
+		return false
+	}
+}
+
+// Cached users, organizations, schools and classes are cached by id, writes of them invalidate the cache
+func (t DirectoryType) Cached() bool {
+	switch t {
+	case DirectoryTypeUser, DirectoryTypeOrganization, DirectoryTypeSchool, DirectoryTypeClass:
+		return true
+	default:
+		return false
+	}
+}
+
+type DirectoryUser struct {
+	ID         string `gorm:"column:id;PRIMARY_KEY" json:"id"`
+	GivenName  string `gorm:"column:given_name" json:"given_name"`
+	FamilyName string `gorm:"column:family_name" json:"family_name"`
+	Email      string `gorm:"column:email" json:"email"`
+	Avatar     string `gorm:"column:avatar" json:"avatar"`
+	Status     string `gorm:"column:status" json:"status"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint" json:"-"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint" json:"-"`
+}
+
+func (DirectoryUser) TableName() string {
+	return constant.TableNameDirectoryUser
+}
+
+type DirectoryOrganization struct {
+	ID     string `gorm:"column:id;PRIMARY_KEY" json:"id"`
+	Name   string `gorm:"column:name" json:"name"`
+	Status string `gorm:"column:status" json:"status"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint" json:"-"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint" json:"-"`
+}
+
+func (DirectoryOrganization) TableName() string {
+	return constant.TableNameDirectoryOrganization
+}
+
+type DirectorySchool struct {
+	ID     string `gorm:"column:id;PRIMARY_KEY" json:"id"`
+	OrgID  string `gorm:"column:org_id" json:"org_id"`
+	Name   string `gorm:"column:name" json:"name"`
+	Status string `gorm:"column:status" json:"status"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint" json:"-"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint" json:"-"`
+}
+
+func (DirectorySchool) TableName() string {
+	return constant.TableNameDirectorySchool
+}
+
+type DirectoryClass struct {
+	ID     string `gorm:"column:id;PRIMARY_KEY" json:"id"`
+	OrgID  string `gorm:"column:org_id" json:"org_id"`
+	Name   string `gorm:"column:name" json:"name"`
+	Status string `gorm:"column:status" json:"status"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint" json:"-"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint" json:"-"`
+}
+
+func (DirectoryClass) TableName() string {
+	return constant.TableNameDirectoryClass
+}
+
+// DirectoryRole roles without an organization are system roles shared by all organizations
+type DirectoryRole struct {
+	ID     string `gorm:"column:id;PRIMARY_KEY" json:"id"`
+	OrgID  string `gorm:"column:org_id" json:"org_id"`
+	Name   string `gorm:"column:name" json:"name"`
+	Status string `gorm:"column:status" json:"status"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint" json:"-"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint" json:"-"`
+}
+
+func (DirectoryRole) TableName() string {
+	return constant.TableNameDirectoryRole
+}
+
+// DirectoryProgram programs without an organization are system programs
+type DirectoryProgram struct {
+	ID        string `gorm:"column:id;PRIMARY_KEY" json:"id"`
+	OrgID     string `gorm:"column:org_id" json:"org_id"`
+	Name      string `gorm:"column:name" json:"name"`
+	GroupName string `gorm:"column:group_name" json:"group_name"`
+	Status    string `gorm:"column:status" json:"status"`
+	System    bool   `gorm:"column:system" json:"system"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint" json:"-"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint" json:"-"`
+}
+
+func (DirectoryProgram) TableName() string {
+	return constant.TableNameDirectoryProgram
+}
+
+// DirectorySubject subjects without an organization are system subjects
+type DirectorySubject struct {
+	ID     string `gorm:"column:id;PRIMARY_KEY" json:"id"`
+	OrgID  string `gorm:"column:org_id" json:"org_id"`
+	Name   string `gorm:"column:name" json:"name"`
+	Status string `gorm:"column:status" json:"status"`
+	System bool   `gorm:"column:system" json:"system"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint" json:"-"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint" json:"-"`
+}
+
+func (DirectorySubject) TableName() string {
+	return constant.TableNameDirectorySubject
+}
+
+// DirectoryMembership a user in an organization, or in one of its schools when the school id is not empty
+type DirectoryMembership struct {
+	OrgID    string `json:"org_id"`
+	SchoolID string `json:"school_id"`
+	UserID   string `json:"user_id"`
+}
+
+// DirectoryMembershipRole a role of a membership, the permissions of school roles are granted in the school only
+type DirectoryMembershipRole struct {
+	OrgID    string `json:"org_id"`
+	SchoolID string `json:"school_id"`
+	UserID   string `json:"user_id"`
+	RoleID   string `json:"role_id"`
+}
+
+type DirectoryRolePermission struct {
+	RoleID         string `json:"role_id"`
+	PermissionName string `json:"permission_name"`
+}
+
+type DirectoryClassSchool struct {
+	ClassID  string `json:"class_id"`
+	SchoolID string `json:"school_id"`
+}
+
+type DirectoryClassMember struct {
+	ClassID  string `json:"class_id"`
+	UserID   string `json:"user_id"`
+	JoinType string `json:"join_type"`
+}
+
+type DirectoryProgramSubject struct {
+	ProgramID string `json:"program_id"`
+	SubjectID string `json:"subject_id"`
+}
+
+// DirectoryMemberPermission a permission of a user in an organization, or in one of its schools
+type DirectoryMemberPermission struct {
+	OrgID          string `gorm:"column:org_id"`
+	SchoolID       string `gorm:"column:school_id"`
+	PermissionName string `gorm:"column:permission_name"`
+}
+
+// DirectoryClassMembership a class of a user
+type DirectoryClassMembership struct {
+	UserID   string `gorm:"column:user_id"`
+	JoinType string `gorm:"column:join_type"`
+	DirectoryClass
+}
+
+// DirectoryClassMemberUser a user of a class
+type DirectoryClassMemberUser struct {
+	ClassID string `gorm:"column:class_id"`
+	DirectoryUser
+}
+
+// DirectoryRelatedSchool a school related to a class or a user
+type DirectoryRelatedSchool struct {
+	RelatedID string `gorm:"column:related_id"`
+	DirectorySchool
+}
+
+// DirectoryRelatedClass a class related to an organization or a school
+type DirectoryRelatedClass struct {
+	RelatedID string `gorm:"column:related_id"`
+	DirectoryClass
+}
+
+type DirectoryImportResult struct {
+	Type DirectoryType `json:"type"`
+	Rows int           `json:"rows"`
+}
+
+// DirectoryDeleteResult rows deleted or deactivated
+type DirectoryDeleteResult struct {
+	Type DirectoryType `json:"type"`
+	Rows int64         `json:"rows"`
+}
+
+// DirectoryColumns columns of the rows of the type, csv files must have a header of them in any order
+func DirectoryColumns(t DirectoryType) []string {
+	switch t {
+	case DirectoryTypeUser:
+		return []string{"id", "given_name", "family_name", "email", "avatar", "status"}
+	case DirectoryTypeOrganization:
+		return []string{"id", "name", "status"}
+	case DirectoryTypeSchool, DirectoryTypeClass, DirectoryTypeRole:
+		return []string{"id", "org_id", "name", "status"}
+	case DirectoryTypeProgram:
+		return []string{"id", "org_id", "name", "group_name", "status", "system"}
+	case DirectoryTypeSubject:
+		return []string{"id", "org_id", "name", "status", "system"}
+	case DirectoryTypeMembership:
+		return []string{"org_id", "school_id", "user_id"}
+	case DirectoryTypeMembershipRole:
+		return []string{"org_id", "school_id", "user_id", "role_id"}
+	case DirectoryTypeRolePermission:
+		return []string{"role_id", "permission_name"}
+	case DirectoryTypeClassSchool:
+		return []string{"class_id", "school_id"}
+	case DirectoryTypeClassMember:
+		return []string{"class_id", "user_id", "join_type"}
+	case DirectoryTypeProgramSubject:
+		return []string{"program_id", "subject_id"}
+	default:
+		return nil
+	}
+}
+
+// DirectoryRequiredColumns columns which must not be empty
+func DirectoryRequiredColumns(t DirectoryType) []string {
+	switch t {
+	case DirectoryTypeUser, DirectoryTypeOrganization, DirectoryTypeProgram, DirectoryTypeSubject:
+		return []string{"id"}
+	case DirectoryTypeSchool, DirectoryTypeClass:
+		return []string{"id", "org_id"}
+	case DirectoryTypeRole:
+		return []string{"id", "name"}
+	case DirectoryTypeMembership:
+		return []string{"org_id", "user_id"}
+	case DirectoryTypeMembershipRole:
+		return []string{"org_id", "user_id", "role_id"}
+	default:
+		return DirectoryColumns(t)
+	}
+}
+
+// ParseDirectoryRecord converts a record of csv or api to the row of the type, status is active by default
+func ParseDirectoryRecord(t DirectoryType, record map[string]string) (interface{}, error) {
+	for _, column := range DirectoryRequiredColumns(t) {
+		if strings.TrimSpace(record[column]) == "" {
+			return nil, fmt.Errorf("%s: %w: %s is required", t, constant.ErrInvalidArgs, column)
+		}
+	}
+
+	value := func(column string) string {
+		return strings.TrimSpace(record[column])
+	}
+	status := value("status")
+	if status == "" {
+		status = DirectoryStatusActive
+	}
+	if status != DirectoryStatusActive && status != DirectoryStatusInactive {
+		return nil, fmt.Errorf("%s: %w: invalid status %s", t, constant.ErrInvalidArgs, status)
+	}
+	system := false
+	if s := value("system"); s != "" {
+		var err error
+		system, err = strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w: invalid system %s", t, constant.ErrInvalidArgs, s)
+		}
+	}
+
+	switch t {
+	case DirectoryTypeUser:
+		return &DirectoryUser{ID: value("id"), GivenName: value("given_name"), FamilyName: value("family_name"),
+			Email: value("email"), Avatar: value("avatar"), Status: status}, nil
+	case DirectoryTypeOrganization:
+		return &DirectoryOrganization{ID: value("id"), Name: value("name"), Status: status}, nil
+	case DirectoryTypeSchool:
+		return &DirectorySchool{ID: value("id"), OrgID: value("org_id"), Name: value("name"), Status: status}, nil
+	case DirectoryTypeClass:
+		return &DirectoryClass{ID: value("id"), OrgID: value("org_id"), Name: value("name"), Status: status}, nil
+	case DirectoryTypeRole:
+		return &DirectoryRole{ID: value("id"), OrgID: value("org_id"), Name: value("name"), Status: status}, nil
+	case DirectoryTypeProgram:
+		return &DirectoryProgram{ID: value("id"), OrgID: value("org_id"), Name: value("name"), GroupName: value("group_name"),
+			Status: status, System: system}, nil
+	case DirectoryTypeSubject:
+		return &DirectorySubject{ID: value("id"), OrgID: value("org_id"), Name: value("name"), Status: status, System: system}, nil
+	case DirectoryTypeMembership:
+		return &DirectoryMembership{OrgID: value("org_id"), SchoolID: value("school_id"), UserID: value("user_id")}, nil
+	case DirectoryTypeMembershipRole:
+		return &DirectoryMembershipRole{OrgID: value("org_id"), SchoolID: value("school_id"), UserID: value("user_id"),
+			RoleID: value("role_id")}, nil
+	case DirectoryTypeRolePermission:
+		return &DirectoryRolePermission{RoleID: value("role_id"), PermissionName: value("permission_name")}, nil
+	case DirectoryTypeClassSchool:
+		return &DirectoryClassSchool{ClassID: value("class_id"), SchoolID: value("school_id")}, nil
+	case DirectoryTypeClassMember:
+		joinType := value("join_type")
+		if joinType != DirectoryJoinTypeTeaching && joinType != DirectoryJoinTypeStudying {
+			return nil, fmt.Errorf("%s: %w: invalid join_type %s", t, constant.ErrInvalidArgs, joinType)
+		}
+		return &DirectoryClassMember{ClassID: value("class_id"), UserID: value("user_id"), JoinType: joinType}, nil
+	case DirectoryTypeProgramSubject:
+		return &DirectoryProgramSubject{ProgramID: value("program_id"), SubjectID: value("subject_id")}, nil
+	default:
+		return nil, fmt.Errorf("%w: invalid directory type %s", constant.ErrInvalidArgs, t)
+	}
+}
+
+// DirectoryRelationValues values of the columns of a relation row, in the order of DirectoryColumns
+func DirectoryRelationValues(row interface{}) []interface{} {
+	switch r := row.(type) {
+	case *DirectoryMembership:
+		return []interface{}{r.OrgID, r.SchoolID, r.UserID}
+	case *DirectoryMembershipRole:
+		return []interface{}{r.OrgID, r.SchoolID, r.UserID, r.RoleID}
+	case *DirectoryRolePermission:
+		return []interface{}{r.RoleID, r.PermissionName}
+	case *DirectoryClassSchool:
+		return []interface{}{r.ClassID, r.SchoolID}
+	case *DirectoryClassMember:
+		return []interface{}{r.ClassID, r.UserID, r.JoinType}
+	case *DirectoryProgramSubject:
+		return []interface{}{r.ProgramID, r.SubjectID}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+func TestParseDirectoryRecord(t *testing.T) {
+	row, err := ParseDirectoryRecord(DirectoryTypeSchool, map[string]string{"id": " s1 ", "org_id": "o1", "name": "School"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &DirectorySchool{ID: "s1", OrgID: "o1", Name: "School", Status: DirectoryStatusActive}
+	if !reflect.DeepEqual(row, want) {
+		t.Errorf("got %+v, want %+v", row, want)
+	}
+
+	row, err = ParseDirectoryRecord(DirectoryTypeProgram, map[string]string{"id": "p1", "status": "inactive", "system": "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if program := row.(*DirectoryProgram); program.Status != DirectoryStatusInactive || !program.System || program.OrgID != "" {
+		t.Errorf("unexpected program %+v", program)
+	}
+
+	row, err = ParseDirectoryRecord(DirectoryTypeMembership, map[string]string{"org_id": "o1", "user_id": "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values := DirectoryRelationValues(row); !reflect.DeepEqual(values, []interface{}{"o1", "", "u1"}) {
+		t.Errorf("an organization membership has an empty school, got %v", values)
+	}
+}
+
+func TestParseDirectoryRecordInvalid(t *testing.T) {
+	tests := []struct {
+		directoryType DirectoryType
+		record        map[string]string
+	}{
+		{DirectoryTypeClass, map[string]string{"id": "c1"}},
+		{DirectoryTypeUser, map[string]string{"id": "u1", "status": "deleted"}},
+		{DirectoryTypeSubject, map[string]string{"id": "s1", "system": "maybe"}},
+		{DirectoryTypeClassMember, map[string]string{"class_id": "c1", "user_id": "u1", "join_type": "visiting"}},
+		{DirectoryTypeRolePermission, map[string]string{"role_id": "r1"}},
+		{DirectoryType("teachers"), map[string]string{"id": "t1"}},
+	}
+	for _, test := range tests {
+		if _, err := ParseDirectoryRecord(test.directoryType, test.record); !errors.Is(err, constant.ErrInvalidArgs) {
+			t.Errorf("%s %v: want invalid args, got %v", test.directoryType, test.record, err)
+		}
+	}
+}
+
+func TestDirectoryTypes(t *testing.T) {
+	for _, directoryType := range DirectoryTypes {
+		if !directoryType.Valid() || len(DirectoryColumns(directoryType)) == 0 {
+			t.Errorf("%s should be valid with columns", directoryType)
+		}
+		if directoryType.IsRelation() {
+			if values := DirectoryRelationValues(mustParseRelation(t, directoryType)); len(values) != len(DirectoryColumns(directoryType)) {
+				t.Errorf("%s: values %v don't match the columns", directoryType, values)
+			}
+		}
+	}
+	if DirectoryType("teachers").Valid() {
+		t.Error("teachers is not a directory type")
+	}
+}
+
+func mustParseRelation(t *testing.T, directoryType DirectoryType) interface{} {
+	record := map[string]string{"join_type": DirectoryJoinTypeStudying}
+	for _, column := range DirectoryColumns(directoryType) {
+		if column != "join_type" {
+			record[column] = column
+		}
+	}
+	row, err := ParseDirectoryRecord(directoryType, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
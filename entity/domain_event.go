@@ -0,0 +1,99 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+type DomainEventStatus string
+
+const (
+	// DomainEventStatusPending waiting to be dispatched, either new or retrying after a failure
+	DomainEventStatusPending DomainEventStatus = "Pending"
+	// DomainEventStatusDispatched published to the message queue, handlers are running
+	DomainEventStatusDispatched DomainEventStatus = "Dispatched"
+	DomainEventStatusSucceeded  DomainEventStatus = "Succeeded"
+	// DomainEventStatusDead all attempts failed, only replayed manually
+	DomainEventStatusDead DomainEventStatus = "Dead"
+)
+
+func (s DomainEventStatus) Valid() bool {
+	switch s {
+	case DomainEventStatusPending, DomainEventStatusDispatched, DomainEventStatusSucceeded, DomainEventStatusDead:
+		return true
+	}
+	return false
+}
+
+// DomainEvent outbox record, written in the same transaction as the change it describes
+type DomainEvent struct {
+	ID            string            `gorm:"column:id;PRIMARY_KEY"`
+	Topic         string            `gorm:"column:topic"`
+	Payload       string            `gorm:"column:payload"`
+	Operator      string            `gorm:"column:operator"`
+	Status        DomainEventStatus `gorm:"column:status"`
+	Attempts      int               `gorm:"column:attempts"`
+	LastError     string            `gorm:"column:last_error"`
+	NextAttemptAt int64             `gorm:"column:next_attempt_at;type:bigint"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (DomainEvent) TableName() string {
+	return constant.TableNameDomainEvent
+}
+
+// Fail record a failed attempt, the event is retried with exponential backoff until max attempts
+func (e *DomainEvent) Fail(reason string, maxAttempts int, backoff time.Duration, now int64) {
+	e.Attempts++
+	e.LastError = reason
+	e.UpdateAt = now
+
+	if e.Attempts >= maxAttempts {
+		e.Status = DomainEventStatusDead
+		return
+	}
+
+	e.Status = DomainEventStatusPending
+	e.NextAttemptAt = now + int64(RetryDelay(backoff, e.Attempts).Seconds())
+}
+
+// DomainEventConsumption a handler has processed the event, consumers skip it on redelivery
+type DomainEventConsumption struct {
+	ID       string `gorm:"column:id;PRIMARY_KEY"`
+	EventID  string `gorm:"column:event_id"`
+	Handler  string `gorm:"column:handler"`
+	CreateAt int64  `gorm:"column:create_at;type:bigint"`
+}
+
+func (DomainEventConsumption) TableName() string {
+	return constant.TableNameDomainEventConsumption
+}
+
+type DomainEventQueryReq struct {
+	Topic     string            `form:"topic"`
+	Status    DomainEventStatus `form:"status"`
+	PageIndex int               `form:"page"`
+	PageSize  int               `form:"page_size"`
+}
+
+type DomainEventView struct {
+	ID            string            `json:"id"`
+	Topic         string            `json:"topic"`
+	Payload       string            `json:"payload"`
+	Status        DomainEventStatus `json:"status" enums:"Pending,Dispatched,Succeeded,Dead"`
+	Attempts      int               `json:"attempts"`
+	LastError     string            `json:"last_error"`
+	NextAttemptAt int64             `json:"next_attempt_at"`
+	Handlers      []string          `json:"handlers"`
+	CreateAt      int64             `json:"create_at"`
+	UpdateAt      int64             `json:"update_at"`
+}
+
+type DomainEventPageReply struct {
+	Total int                `json:"total"`
+	Data  []*DomainEventView `json:"data"`
+}
@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDomainEventFail(t *testing.T) {
+	event := &DomainEvent{Status: DomainEventStatusDispatched}
+
+	event.Fail("timeout", 2, 30*time.Second, 1000)
+	if event.Status != DomainEventStatusPending || event.Attempts != 1 || event.NextAttemptAt != 1030 || event.LastError != "timeout" {
+		t.Fatalf("first failure should be retried: %+v", event)
+	}
+
+	event.Status = DomainEventStatusDispatched
+	event.Fail("timeout again", 2, 30*time.Second, 2000)
+	if event.Status != DomainEventStatusDead || event.Attempts != 2 || event.LastError != "timeout again" {
+		t.Fatalf("last failure should be dead: %+v", event)
+	}
+
+	event = &DomainEvent{Status: DomainEventStatusDispatched}
+	event.Fail("invalid args", 0, 0, 1000)
+	if event.Status != DomainEventStatusDead {
+		t.Errorf("permanent failure should be dead: %+v", event)
+	}
+}
@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ETag the entity tag of a resource revision. The revision counter is increased by the writes checking it,
+// the update time tells the other writes apart.
+func ETag(revision, updateAt int64) string {
+	return fmt.Sprintf(`"%d-%d"`, revision, updateAt)
+}
+
+// ETagMatches whether the If-Match header value matches the current etag.
+// An empty header or "*" matches any revision, weak tags never match because If-Match compares strongly.
+func ETagMatches(ifMatch, etag string) bool {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" || ifMatch == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(tag) == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package entity
+
+import "testing"
+
+func TestETagMatches(t *testing.T) {
+	etag := ETag(3, 1650000000)
+	if etag != `"3-1650000000"` {
+		t.Fatalf("unexpected etag %s", etag)
+	}
+
+	tests := []struct {
+		ifMatch string
+		want    bool
+	}{
+		{"", true},
+		{"*", true},
+		{`"3-1650000000"`, true},
+		{`"2-1640000000", "3-1650000000"`, true},
+		{`"2-1640000000"`, false},
+		{`W/"3-1650000000"`, false},
+		{`3-1650000000`, false},
+	}
+	for _, tt := range tests {
+		if got := ETagMatches(tt.ifMatch, etag); got != tt.want {
+			t.Errorf("ETagMatches(%q) = %v, want %v", tt.ifMatch, got, tt.want)
+		}
+	}
+}
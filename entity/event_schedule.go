@@ -0,0 +1,9 @@
+package entity
+
+type ScheduleStatusChangedEvent struct {
+	ScheduleID     string         `json:"schedule_id"`
+	OrgID          string         `json:"org_id"`
+	Status         ScheduleStatus `json:"status"`
+	PreviousStatus ScheduleStatus `json:"previous_status"`
+	ChangedAt      int64          `json:"changed_at"`
+}
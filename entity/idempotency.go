@@ -0,0 +1,19 @@
+package entity
+
+type IdempotencyState string
+
+const (
+	IdempotencyStateProcessing IdempotencyState = "processing"
+	IdempotencyStateCompleted  IdempotencyState = "completed"
+)
+
+// IdempotencyRecord a request with an idempotency key, the response is kept once it has completed
+type IdempotencyRecord struct {
+	State IdempotencyState `json:"state"`
+	// RequestHash a key is bound to the request it was first used with
+	RequestHash string `json:"request_hash"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+	CreateAt    int64  `json:"create_at"`
+}
@@ -0,0 +1,152 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+type JobType string
+
+const (
+	JobTypePublishContentBulk JobType = "content.publish_bulk"
+	JobTypeDeleteContentBulk  JobType = "content.delete_bulk"
+	JobTypeImportOutcomes     JobType = "outcome.import"
+	JobTypeExportReport       JobType = "report.export"
+)
+
+type JobStatus string
+
+const (
+	// JobStatusPending waiting to be picked up, either new or retrying after a failure
+	JobStatusPending   JobStatus = "Pending"
+	JobStatusRunning   JobStatus = "Running"
+	JobStatusSucceeded JobStatus = "Succeeded"
+	// JobStatusFailed all attempts failed or the failure is permanent
+	JobStatusFailed    JobStatus = "Failed"
+	JobStatusCancelled JobStatus = "Cancelled"
+)
+
+func (s JobStatus) Valid() bool {
+	switch s {
+	case JobStatusPending, JobStatusRunning, JobStatusSucceeded, JobStatusFailed, JobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (s JobStatus) Finished() bool {
+	return s == JobStatusSucceeded || s == JobStatusFailed || s == JobStatusCancelled
+}
+
+// Job long operation run by the job worker, params and result are json
+type Job struct {
+	ID            string    `gorm:"column:id;PRIMARY_KEY"`
+	OrgID         string    `gorm:"column:org_id"`
+	Type          JobType   `gorm:"column:type"`
+	Params        string    `gorm:"column:params"`
+	Status        JobStatus `gorm:"column:status"`
+	Progress      int       `gorm:"column:progress"`
+	Attempts      int       `gorm:"column:attempts"`
+	LastError     string    `gorm:"column:last_error"`
+	Result        string    `gorm:"column:result"`
+	HasFile       bool      `gorm:"column:has_file"`
+	NextAttemptAt int64     `gorm:"column:next_attempt_at;type:bigint"`
+	StartAt       int64     `gorm:"column:start_at;type:bigint"`
+	FinishAt      int64     `gorm:"column:finish_at;type:bigint"`
+	CreatorID     string    `gorm:"column:creator_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (Job) TableName() string {
+	return constant.TableNameJob
+}
+
+func (j *Job) Succeed(result string, hasFile bool, now int64) {
+	j.Status = JobStatusSucceeded
+	j.Progress = 100
+	j.Result = result
+	j.HasFile = hasFile
+	j.LastError = ""
+	j.FinishAt = now
+	j.UpdateAt = now
+}
+
+// Fail record a failed attempt, the job is retried with exponential backoff until max attempts
+func (j *Job) Fail(reason string, maxAttempts int, backoff time.Duration, now int64) {
+	j.LastError = reason
+	j.UpdateAt = now
+
+	if j.Attempts >= maxAttempts {
+		j.Status = JobStatusFailed
+		j.FinishAt = now
+		return
+	}
+
+	j.Status = JobStatusPending
+	j.Progress = 0
+	j.NextAttemptAt = now + int64(RetryDelay(backoff, j.Attempts).Seconds())
+}
+
+// JobProgress percentage of done in total, a running job never reports 100
+func JobProgress(done, total int) int {
+	if total <= 0 || done <= 0 {
+		return 0
+	}
+	if done >= total {
+		return 99
+	}
+	return done * 100 / total
+}
+
+// JobFile downloadable result of a job
+type JobFile struct {
+	JobID       string `gorm:"column:job_id;PRIMARY_KEY"`
+	FileName    string `gorm:"column:file_name"`
+	ContentType string `gorm:"column:content_type"`
+	Data        []byte `gorm:"column:data"`
+	CreateAt    int64  `gorm:"column:create_at;type:bigint"`
+}
+
+func (JobFile) TableName() string {
+	return constant.TableNameJobFile
+}
+
+type ContentBulkJobParams struct {
+	IDs []string `json:"ids"`
+}
+
+type ContentBulkJobResult struct {
+	Count int `json:"count"`
+}
+
+type JobQueryReq struct {
+	Type      JobType   `form:"type"`
+	Status    JobStatus `form:"status"`
+	PageIndex int       `form:"page"`
+	PageSize  int       `form:"page_size"`
+}
+
+type JobView struct {
+	ID        string    `json:"id"`
+	Type      JobType   `json:"type"`
+	Status    JobStatus `json:"status" enums:"Pending,Running,Succeeded,Failed,Cancelled"`
+	Progress  int       `json:"progress"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	// Result json result of the job handler, empty until the job succeeded
+	Result   string `json:"result"`
+	HasFile  bool   `json:"has_file"`
+	StartAt  int64  `json:"start_at"`
+	FinishAt int64  `json:"finish_at"`
+	CreateAt int64  `json:"create_at"`
+	UpdateAt int64  `json:"update_at"`
+}
+
+type JobPageReply struct {
+	Total int        `json:"total"`
+	Data  []*JobView `json:"data"`
+}
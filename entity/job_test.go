@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJobFail(t *testing.T) {
+	job := &Job{Status: JobStatusRunning, Attempts: 1, Progress: 40}
+
+	job.Fail("timeout", 2, time.Minute, 1000)
+	if job.Status != JobStatusPending || job.NextAttemptAt != 1060 || job.Progress != 0 || job.FinishAt != 0 {
+		t.Fatalf("first failure should be retried: %+v", job)
+	}
+
+	job.Status = JobStatusRunning
+	job.Attempts++
+	job.Fail("timeout again", 2, time.Minute, 2000)
+	if job.Status != JobStatusFailed || job.FinishAt != 2000 || job.LastError != "timeout again" {
+		t.Fatalf("last failure should fail the job: %+v", job)
+	}
+
+	job = &Job{Status: JobStatusRunning, Attempts: 1}
+	job.Fail("invalid args", 0, time.Minute, 1000)
+	if job.Status != JobStatusFailed {
+		t.Errorf("permanent failure should fail the job: %+v", job)
+	}
+}
+
+func TestJobSucceed(t *testing.T) {
+	job := &Job{Status: JobStatusRunning, Progress: 50, LastError: "timeout"}
+	job.Succeed(`{"count":2}`, true, 1000)
+	if job.Status != JobStatusSucceeded || job.Progress != 100 || job.LastError != "" || !job.HasFile || job.FinishAt != 1000 {
+		t.Errorf("job should succeed: %+v", job)
+	}
+	if !job.Status.Finished() || JobStatusRunning.Finished() {
+		t.Error("only succeeded, failed and cancelled jobs are finished")
+	}
+}
+
+func TestJobProgress(t *testing.T) {
+	tests := []struct {
+		done, total, want int
+	}{
+		{0, 0, 0},
+		{1, 0, 0},
+		{0, 10, 0},
+		{3, 10, 30},
+		{10, 10, 99},
+		{1, 3, 33},
+	}
+
+	for _, tt := range tests {
+		if got := JobProgress(tt.done, tt.total); got != tt.want {
+			t.Errorf("JobProgress(%d, %d) = %d, want %d", tt.done, tt.total, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package entity
+
+import "github.com/KL-Engineering/kidsloop-cms-service/constant"
+
+// OrganizationAPIKey key used by external systems (e.g. SIS) to read organization data,
+// only the sha256 of the key is stored
+type OrganizationAPIKey struct {
+	ID         string `gorm:"column:id;PRIMARY_KEY"`
+	OrgID      string `gorm:"column:org_id"`
+	Name       string `gorm:"column:name"`
+	KeyPrefix  string `gorm:"column:key_prefix"`
+	KeyHash    string `gorm:"column:key_hash"`
+	CreatorID  string `gorm:"column:creator_id"`
+	LastUsedAt int64  `gorm:"column:last_used_at;type:bigint"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (OrganizationAPIKey) TableName() string {
+	return constant.TableNameOrganizationAPIKey
+}
+
+type OrganizationAPIKeyAddReq struct {
+	Name string `json:"name"`
+}
+
+type OrganizationAPIKeyReply struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	KeyPrefix  string `json:"key_prefix"`
+	CreatorID  string `json:"creator_id"`
+	LastUsedAt int64  `json:"last_used_at"`
+	CreateAt   int64  `json:"create_at"`
+}
+
+// OrganizationAPIKeyCreatedReply the plain key is only returned once, when the key is created
+type OrganizationAPIKeyCreatedReply struct {
+	OrganizationAPIKeyReply
+	Key string `json:"key"`
+}
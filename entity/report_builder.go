@@ -0,0 +1,390 @@
+package entity
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+type ReportBuilderDimension string
+
+const (
+	ReportBuilderDimensionOrg        ReportBuilderDimension = "org"
+	ReportBuilderDimensionSchool     ReportBuilderDimension = "school"
+	ReportBuilderDimensionClass      ReportBuilderDimension = "class"
+	ReportBuilderDimensionTeacher    ReportBuilderDimension = "teacher"
+	ReportBuilderDimensionStudent    ReportBuilderDimension = "student"
+	ReportBuilderDimensionSubject    ReportBuilderDimension = "subject"
+	ReportBuilderDimensionProgram    ReportBuilderDimension = "program"
+	ReportBuilderDimensionLessonPlan ReportBuilderDimension = "lesson_plan"
+	// ReportBuilderDimensionPeriod start of the day, week or month by the time grain of the query
+	ReportBuilderDimensionPeriod ReportBuilderDimension = "period"
+)
+
+var ReportBuilderDimensions = []ReportBuilderDimension{
+	ReportBuilderDimensionOrg,
+	ReportBuilderDimensionSchool,
+	ReportBuilderDimensionClass,
+	ReportBuilderDimensionTeacher,
+	ReportBuilderDimensionStudent,
+	ReportBuilderDimensionSubject,
+	ReportBuilderDimensionProgram,
+	ReportBuilderDimensionLessonPlan,
+	ReportBuilderDimensionPeriod,
+}
+
+func (d ReportBuilderDimension) Valid() bool {
+	for _, dimension := range ReportBuilderDimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	return false
+}
+
+type ReportBuilderMetric string
+
+const (
+	// ReportBuilderMetricScheduledLessons classes and study assignments of the students
+	ReportBuilderMetricScheduledLessons ReportBuilderMetric = "scheduled_lessons"
+	// ReportBuilderMetricAttended classes and study assignments the students started
+	ReportBuilderMetricAttended ReportBuilderMetric = "attended"
+	// ReportBuilderMetricCompletionRate finished of all classes and study assignments of the students, 0 to 1
+	ReportBuilderMetricCompletionRate ReportBuilderMetric = "completion_rate"
+	// ReportBuilderMetricOutcomesAchieved outcomes achieved by the students in completed assessments
+	ReportBuilderMetricOutcomesAchieved ReportBuilderMetric = "outcomes_achieved"
+	// ReportBuilderMetricMaterialViews lesson materials viewed by the students, like the material usage report
+	ReportBuilderMetricMaterialViews ReportBuilderMetric = "material_views"
+)
+
+var ReportBuilderMetrics = []ReportBuilderMetric{
+	ReportBuilderMetricScheduledLessons,
+	ReportBuilderMetricAttended,
+	ReportBuilderMetricCompletionRate,
+	ReportBuilderMetricOutcomesAchieved,
+	ReportBuilderMetricMaterialViews,
+}
+
+// ReportBuilderSource the table a metric is computed from, metrics of a source are queried together
+type ReportBuilderSource string
+
+const (
+	ReportBuilderSourceLessons  ReportBuilderSource = "lessons"
+	ReportBuilderSourceOutcomes ReportBuilderSource = "outcomes"
+	ReportBuilderSourceUsages   ReportBuilderSource = "usages"
+)
+
+func (m ReportBuilderMetric) Source() ReportBuilderSource {
+	switch m {
+	case ReportBuilderMetricScheduledLessons, ReportBuilderMetricAttended, ReportBuilderMetricCompletionRate:
+		return ReportBuilderSourceLessons
+	case ReportBuilderMetricOutcomesAchieved:
+		return ReportBuilderSourceOutcomes
+	case ReportBuilderMetricMaterialViews:
+		return ReportBuilderSourceUsages
+	}
+	return ""
+}
+
+func (m ReportBuilderMetric) Valid() bool {
+	return m.Source() != ""
+}
+
+type ReportBuilderTimeGrain string
+
+const (
+	ReportBuilderTimeGrainDay   ReportBuilderTimeGrain = "day"
+	ReportBuilderTimeGrainWeek  ReportBuilderTimeGrain = "week"
+	ReportBuilderTimeGrainMonth ReportBuilderTimeGrain = "month"
+)
+
+func (g ReportBuilderTimeGrain) Valid() bool {
+	return g == ReportBuilderTimeGrainDay || g == ReportBuilderTimeGrainWeek || g == ReportBuilderTimeGrainMonth
+}
+
+// Start of the period containing ts, days start at midnight of the time zone offset and weeks on mondays
+func (g ReportBuilderTimeGrain) Start(ts int64, offset int) int64 {
+	switch g {
+	case ReportBuilderTimeGrainDay:
+		return ReportRollupPeriodDaily.Start(ts, offset)
+	case ReportBuilderTimeGrainWeek:
+		return ReportRollupPeriodWeekly.Start(ts, offset)
+	}
+	t := time.Unix(ts+int64(offset), 0).UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).Unix() - int64(offset)
+}
+
+// Next start of the period after the period starting at start
+func (g ReportBuilderTimeGrain) Next(start int64, offset int) int64 {
+	switch g {
+	case ReportBuilderTimeGrainDay:
+		return start + ReportRollupPeriodDaily.Seconds()
+	case ReportBuilderTimeGrainWeek:
+		return start + ReportRollupPeriodWeekly.Seconds()
+	}
+	return time.Unix(start+int64(offset), 0).UTC().AddDate(0, 1, 0).Unix() - int64(offset)
+}
+
+type ReportBuilderFilter struct {
+	Dimension ReportBuilderDimension `json:"dimension" enums:"org,school,class,teacher,student,subject,program,lesson_plan"`
+	Values    []string               `json:"values"`
+}
+
+type ReportBuilderQuery struct {
+	Dimensions []ReportBuilderDimension `json:"dimensions" enums:"org,school,class,teacher,student,subject,program,lesson_plan,period"`
+	Metrics    []ReportBuilderMetric    `json:"metrics" enums:"scheduled_lessons,attended,completion_rate,outcomes_achieved,material_views"`
+	Filters    []*ReportBuilderFilter   `json:"filters"`
+	TimeGrain  ReportBuilderTimeGrain   `json:"time_grain" enums:"day,week,month"`
+	StartAt    int64                    `json:"start_at"`
+	EndAt      int64                    `json:"end_at"`
+}
+
+func (q *ReportBuilderQuery) Valid() bool {
+	if q.TimeGrain == "" {
+		q.TimeGrain = ReportBuilderTimeGrainWeek
+	}
+	if !q.TimeGrain.Valid() || q.StartAt <= 0 || q.StartAt >= q.EndAt {
+		return false
+	}
+	if len(q.Metrics) == 0 || len(q.Dimensions) > constant.ReportBuilderMaxDimensions {
+		return false
+	}
+
+	dimensions := make(map[ReportBuilderDimension]bool)
+	for _, dimension := range q.Dimensions {
+		if !dimension.Valid() || dimensions[dimension] {
+			return false
+		}
+		dimensions[dimension] = true
+	}
+	metrics := make(map[ReportBuilderMetric]bool)
+	for _, metric := range q.Metrics {
+		if !metric.Valid() || metrics[metric] {
+			return false
+		}
+		metrics[metric] = true
+	}
+	for _, filter := range q.Filters {
+		if filter == nil || !filter.Dimension.Valid() || filter.Dimension == ReportBuilderDimensionPeriod {
+			return false
+		}
+		if len(filter.Values) == 0 || len(filter.Values) > constant.ReportBuilderMaxFilterValues {
+			return false
+		}
+	}
+	return true
+}
+
+// HasDimension the dimension is grouped by or filtered
+func (q *ReportBuilderQuery) HasDimension(dimension ReportBuilderDimension) bool {
+	for _, d := range q.Dimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	for _, filter := range q.Filters {
+		if filter.Dimension == dimension {
+			return true
+		}
+	}
+	return false
+}
+
+// Sources sources of the metrics, in the order of the metrics
+func (q *ReportBuilderQuery) Sources() []ReportBuilderSource {
+	var sources []ReportBuilderSource
+	seen := make(map[ReportBuilderSource]bool)
+	for _, metric := range q.Metrics {
+		if source := metric.Source(); !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// Periods starts of the periods overlapping the time range, nil if not grouped by period
+func (q *ReportBuilderQuery) Periods(offset int) []int64 {
+	var periods []int64
+	for _, dimension := range q.Dimensions {
+		if dimension != ReportBuilderDimensionPeriod {
+			continue
+		}
+		for start := q.TimeGrain.Start(q.StartAt, offset); start < q.EndAt; start = q.TimeGrain.Next(start, offset) {
+			periods = append(periods, start)
+		}
+	}
+	return periods
+}
+
+type ReportBuilderCondition struct {
+	Source  ReportBuilderSource
+	OrgID   string
+	Query   *ReportBuilderQuery
+	Periods []int64
+	// ClassIDs classes the user can view reports of
+	ClassIDs []string
+	// TeacherIDs teachers the user can view reports of, only if the teacher dimension is used
+	TeacherIDs NullStrings
+	Limit      int
+}
+
+// ReportBuilderRow a row of a source, only the grouped dimensions and the metrics of the source are set
+type ReportBuilderRow struct {
+	OrgID        string `gorm:"column:org_id"`
+	SchoolID     string `gorm:"column:school_id"`
+	ClassID      string `gorm:"column:class_id"`
+	TeacherID    string `gorm:"column:teacher_id"`
+	StudentID    string `gorm:"column:student_id"`
+	SubjectID    string `gorm:"column:subject_id"`
+	ProgramID    string `gorm:"column:program_id"`
+	LessonPlanID string `gorm:"column:lesson_plan_id"`
+	Period       int64  `gorm:"column:period"`
+
+	ScheduledLessons int64   `gorm:"column:scheduled_lessons"`
+	Attended         int64   `gorm:"column:attended"`
+	CompletionRate   float64 `gorm:"column:completion_rate"`
+	OutcomesAchieved int64   `gorm:"column:outcomes_achieved"`
+	MaterialViews    int64   `gorm:"column:material_views"`
+}
+
+// Dimension value of the dimension, the period as unix seconds
+func (r *ReportBuilderRow) Dimension(dimension ReportBuilderDimension) interface{} {
+	switch dimension {
+	case ReportBuilderDimensionOrg:
+		return r.OrgID
+	case ReportBuilderDimensionSchool:
+		return r.SchoolID
+	case ReportBuilderDimensionClass:
+		return r.ClassID
+	case ReportBuilderDimensionTeacher:
+		return r.TeacherID
+	case ReportBuilderDimensionStudent:
+		return r.StudentID
+	case ReportBuilderDimensionSubject:
+		return r.SubjectID
+	case ReportBuilderDimensionProgram:
+		return r.ProgramID
+	case ReportBuilderDimensionLessonPlan:
+		return r.LessonPlanID
+	case ReportBuilderDimensionPeriod:
+		return r.Period
+	}
+	return nil
+}
+
+func (r *ReportBuilderRow) Metric(metric ReportBuilderMetric) interface{} {
+	switch metric {
+	case ReportBuilderMetricScheduledLessons:
+		return r.ScheduledLessons
+	case ReportBuilderMetricAttended:
+		return r.Attended
+	case ReportBuilderMetricCompletionRate:
+		return r.CompletionRate
+	case ReportBuilderMetricOutcomesAchieved:
+		return r.OutcomesAchieved
+	case ReportBuilderMetricMaterialViews:
+		return r.MaterialViews
+	}
+	return nil
+}
+
+// ReportBuilderNameColumn column of the names of a dimension
+func ReportBuilderNameColumn(dimension ReportBuilderDimension) string {
+	return string(dimension) + "_name"
+}
+
+// ReportBuilderResult rows are keyed by the columns, dimensions have a column of their names except the period
+type ReportBuilderResult struct {
+	Columns []string                 `json:"columns"`
+	Rows    []map[string]interface{} `json:"rows"`
+	// Truncated rows exceeding the limit are not returned
+	Truncated bool `json:"truncated"`
+}
+
+type ReportBuilderMetadata struct {
+	Dimensions []ReportBuilderDimension `json:"dimensions"`
+	Metrics    []ReportBuilderMetric    `json:"metrics"`
+	TimeGrains []ReportBuilderTimeGrain `json:"time_grains"`
+}
+
+// ReportDefinition a saved report builder query, shared definitions are visible to the whole organization
+type ReportDefinition struct {
+	ID          string `gorm:"column:id;PRIMARY_KEY"`
+	OrgID       string `gorm:"column:org_id"`
+	Name        string `gorm:"column:name"`
+	Description string `gorm:"column:description"`
+	// Query json of ReportBuilderQuery
+	Query     string `gorm:"column:query"`
+	Shared    bool   `gorm:"column:shared"`
+	CreatorID string `gorm:"column:creator_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (ReportDefinition) TableName() string {
+	return constant.TableNameReportDefinition
+}
+
+type ReportDefinitionAddReq struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Query       *ReportBuilderQuery `json:"query"`
+	Shared      bool                `json:"shared"`
+}
+
+func (r *ReportDefinitionAddReq) Valid() bool {
+	name := strings.TrimSpace(r.Name)
+	return name != "" && len(name) <= constant.ReportDefinitionMaxNameLength &&
+		len(r.Description) <= constant.ReportDefinitionMaxDescriptionLength &&
+		r.Query != nil && r.Query.Valid()
+}
+
+type ReportDefinitionUpdateReq struct {
+	ID string `json:"-"`
+	ReportDefinitionAddReq
+}
+
+type ReportDefinitionQueryReq struct {
+	Name      string `form:"name"`
+	PageIndex int    `form:"page_index"`
+	PageSize  int    `form:"page_size"`
+}
+
+type ReportDefinitionView struct {
+	ID          string              `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Query       *ReportBuilderQuery `json:"query"`
+	Shared      bool                `json:"shared"`
+	CreatorID   string              `json:"creator_id"`
+	// Editable only the creator updates and deletes a definition
+	Editable bool  `json:"editable"`
+	CreateAt int64 `json:"create_at"`
+	UpdateAt int64 `json:"update_at"`
+}
+
+func NewReportDefinitionView(definition *ReportDefinition, userID string) *ReportDefinitionView {
+	query := new(ReportBuilderQuery)
+	_ = json.Unmarshal([]byte(definition.Query), query)
+	return &ReportDefinitionView{
+		ID:          definition.ID,
+		Name:        definition.Name,
+		Description: definition.Description,
+		Query:       query,
+		Shared:      definition.Shared,
+		CreatorID:   definition.CreatorID,
+		Editable:    definition.CreatorID == userID,
+		CreateAt:    definition.CreateAt,
+		UpdateAt:    definition.UpdateAt,
+	}
+}
+
+type ReportDefinitionPageReply struct {
+	Total int                     `json:"total"`
+	Data  []*ReportDefinitionView `json:"data"`
+}
@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReportBuilderQueryValid(t *testing.T) {
+	valid := func() *ReportBuilderQuery {
+		return &ReportBuilderQuery{
+			Dimensions: []ReportBuilderDimension{ReportBuilderDimensionClass, ReportBuilderDimensionPeriod},
+			Metrics:    []ReportBuilderMetric{ReportBuilderMetricAttended, ReportBuilderMetricMaterialViews},
+			Filters:    []*ReportBuilderFilter{{Dimension: ReportBuilderDimensionSubject, Values: []string{"math"}}},
+			StartAt:    1,
+			EndAt:      2,
+		}
+	}
+	query := valid()
+	if !query.Valid() || query.TimeGrain != ReportBuilderTimeGrainWeek {
+		t.Fatalf("want valid with the week grain, %+v", query)
+	}
+
+	tests := []func(q *ReportBuilderQuery){
+		func(q *ReportBuilderQuery) { q.Metrics = nil },
+		func(q *ReportBuilderQuery) { q.Metrics = append(q.Metrics, ReportBuilderMetricAttended) },
+		func(q *ReportBuilderQuery) { q.Dimensions = append(q.Dimensions, "grade") },
+		func(q *ReportBuilderQuery) { q.Dimensions = append(q.Dimensions, ReportBuilderDimensionClass) },
+		func(q *ReportBuilderQuery) { q.Filters[0].Dimension = ReportBuilderDimensionPeriod },
+		func(q *ReportBuilderQuery) { q.Filters[0].Values = nil },
+		func(q *ReportBuilderQuery) { q.TimeGrain = "year" },
+		func(q *ReportBuilderQuery) { q.EndAt = q.StartAt },
+	}
+	for i, change := range tests {
+		query := valid()
+		change(query)
+		if query.Valid() {
+			t.Errorf("case %d: invalid query is accepted", i)
+		}
+	}
+
+	if sources := valid().Sources(); len(sources) != 2 || sources[0] != ReportBuilderSourceLessons || sources[1] != ReportBuilderSourceUsages {
+		t.Errorf("unexpected sources %v", sources)
+	}
+	if !valid().HasDimension(ReportBuilderDimensionSubject) || valid().HasDimension(ReportBuilderDimensionTeacher) {
+		t.Error("unexpected dimensions")
+	}
+}
+
+func TestReportBuilderQueryPeriods(t *testing.T) {
+	offset := 8 * 60 * 60
+	zone := time.FixedZone("", offset)
+	unix := func(year int, month time.Month, day int) int64 {
+		return time.Date(year, month, day, 0, 0, 0, 0, zone).Unix()
+	}
+
+	query := &ReportBuilderQuery{
+		Dimensions: []ReportBuilderDimension{ReportBuilderDimensionPeriod},
+		TimeGrain:  ReportBuilderTimeGrainMonth,
+		StartAt:    unix(2022, 1, 15),
+		EndAt:      unix(2022, 3, 1),
+	}
+	periods := query.Periods(offset)
+	if len(periods) != 2 || periods[0] != unix(2022, 1, 1) || periods[1] != unix(2022, 2, 1) {
+		t.Errorf("unexpected months %v", periods)
+	}
+
+	// 2022-01-05 is a wednesday
+	query.TimeGrain = ReportBuilderTimeGrainWeek
+	query.StartAt, query.EndAt = unix(2022, 1, 5), unix(2022, 1, 11)
+	periods = query.Periods(offset)
+	if len(periods) != 2 || periods[0] != unix(2022, 1, 3) || periods[1] != unix(2022, 1, 10) {
+		t.Errorf("unexpected weeks %v", periods)
+	}
+
+	query.Dimensions = []ReportBuilderDimension{ReportBuilderDimensionClass}
+	if periods := query.Periods(offset); periods != nil {
+		t.Errorf("not grouped by period, got %v", periods)
+	}
+}
@@ -0,0 +1,308 @@
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type ReportExportFormat string
+
+const (
+	ReportExportFormatCsv  ReportExportFormat = "csv"
+	ReportExportFormatXlsx ReportExportFormat = "xlsx"
+	ReportExportFormatPdf  ReportExportFormat = "pdf"
+)
+
+func (f ReportExportFormat) Valid() bool {
+	switch f {
+	case ReportExportFormatCsv, ReportExportFormatXlsx, ReportExportFormatPdf:
+		return true
+	}
+	return false
+}
+
+func (f ReportExportFormat) ContentType() string {
+	switch f {
+	case ReportExportFormatXlsx:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case ReportExportFormatPdf:
+		return "application/pdf"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ReportExportJobParams the report request replayed by the export job with the submitter's token
+type ReportExportJobParams struct {
+	Method string             `json:"method"`
+	Path   string             `json:"path"`
+	Query  string             `json:"query"`
+	Body   string             `json:"body"`
+	Format ReportExportFormat `json:"format"`
+}
+
+type ReportExportJobResult struct {
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	// Path of the file in the report export storage partition
+	Path string `json:"path"`
+	Rows int    `json:"rows"`
+}
+
+type ReportExportDownload struct {
+	FileName string `json:"file_name"`
+	URL      string `json:"url"`
+}
+
+// ReportTable a report response flattened to rows, nested objects become columns joined by dots
+type ReportTable struct {
+	Title string
+	// Summary scalar fields of the response besides the rows
+	Summary [][2]string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// ExportRows header followed by the rows, summary rows come first separated by an empty row
+func (t *ReportTable) ExportRows() [][]interface{} {
+	rows := make([][]interface{}, 0, len(t.Summary)+len(t.Rows)+2)
+	for _, item := range t.Summary {
+		rows = append(rows, []interface{}{item[0], item[1]})
+	}
+	if len(t.Summary) > 0 {
+		rows = append(rows, []interface{}{})
+	}
+
+	header := make([]interface{}, len(t.Columns))
+	for i, column := range t.Columns {
+		header[i] = column
+	}
+	rows = append(rows, header)
+	return append(rows, t.Rows...)
+}
+
+// orderedObject json object keeping the order of its keys, columns follow the order of the response
+type orderedObject struct {
+	keys   []string
+	values []interface{}
+}
+
+// NewReportTable flatten a json report response.
+// An array response is the rows, otherwise the longest array of objects in it (at most one level deep) is,
+// and the remaining scalar fields are the summary. A response without such array is a single row.
+func NewReportTable(title string, data []byte) (*ReportTable, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &ReportTable{Title: title}
+	items, ok := root.([]interface{})
+	if !ok {
+		object, isObject := root.(*orderedObject)
+		if !isObject {
+			table.Columns = []string{"value"}
+			table.Rows = [][]interface{}{{reportCell(root)}}
+			return table, nil
+		}
+
+		var path []string
+		items, path = longestObjectArray(object)
+		if path == nil {
+			items = []interface{}{object}
+		} else {
+			table.Summary = reportSummary(object, path)
+		}
+	}
+
+	index := make(map[string]int)
+	flattened := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		flattened[i] = make(map[string]interface{})
+		flattenReportValue("", item, flattened[i], func(key string) {
+			if _, ok := index[key]; !ok {
+				index[key] = len(table.Columns)
+				table.Columns = append(table.Columns, key)
+			}
+		})
+	}
+
+	table.Rows = make([][]interface{}, len(flattened))
+	for i, values := range flattened {
+		row := make([]interface{}, len(table.Columns))
+		for key, value := range values {
+			row[index[key]] = value
+		}
+		table.Rows[i] = row
+	}
+	return table, nil
+}
+
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := &orderedObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			object.keys = append(object.keys, fmt.Sprint(key))
+			object.values = append(object.values, value)
+		}
+		_, err = dec.Token()
+		return object, err
+	case json.Delim('['):
+		items := []interface{}{}
+		for dec.More() {
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		_, err = dec.Token()
+		return items, err
+	}
+	return token, nil
+}
+
+// longestObjectArray path of the longest array of objects in the fields or the fields of object fields
+func longestObjectArray(object *orderedObject) ([]interface{}, []string) {
+	var longest []interface{}
+	var path []string
+	check := func(value interface{}, keys ...string) {
+		items, ok := value.([]interface{})
+		if !ok || len(items) == 0 || len(items) <= len(longest) {
+			return
+		}
+		if _, ok := items[0].(*orderedObject); ok {
+			longest, path = items, keys
+		}
+	}
+
+	for i, key := range object.keys {
+		check(object.values[i], key)
+		if child, ok := object.values[i].(*orderedObject); ok {
+			for j, childKey := range child.keys {
+				check(child.values[j], key, childKey)
+			}
+		}
+	}
+	return longest, path
+}
+
+// reportSummary scalar fields of object out of the path of the rows
+func reportSummary(object *orderedObject, path []string) [][2]string {
+	var summary [][2]string
+	for i, key := range object.keys {
+		if key == path[0] && len(path) == 1 {
+			continue
+		}
+		if child, ok := object.values[i].(*orderedObject); ok && key == path[0] {
+			for _, item := range reportSummary(child, path[1:]) {
+				summary = append(summary, [2]string{key + "." + item[0], item[1]})
+			}
+			continue
+		}
+
+		values := make(map[string]interface{})
+		flattenReportValue(key, object.values[i], values, func(column string) {
+			summary = append(summary, [2]string{column, fmt.Sprint(values[column])})
+		})
+	}
+	return summary
+}
+
+// flattenReportValue objects are flattened, arrays of scalars joined and arrays of objects kept as json
+func flattenReportValue(prefix string, value interface{}, values map[string]interface{}, onColumn func(key string)) {
+	switch v := value.(type) {
+	case *orderedObject:
+		for i, key := range v.keys {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flattenReportValue(key, v.values[i], values, onColumn)
+		}
+		return
+	case []interface{}:
+		values[prefix] = joinReportArray(v)
+	default:
+		values[prefix] = reportCell(v)
+	}
+	onColumn(prefix)
+}
+
+func joinReportArray(items []interface{}) string {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case *orderedObject, []interface{}:
+			buf := new(bytes.Buffer)
+			writeOrderedJSON(buf, v)
+			parts = append(parts, buf.String())
+		default:
+			parts = append(parts, fmt.Sprint(reportCell(v)))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func writeOrderedJSON(w io.Writer, value interface{}) {
+	switch v := value.(type) {
+	case *orderedObject:
+		io.WriteString(w, "{")
+		for i, key := range v.keys {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			data, _ := json.Marshal(key)
+			w.Write(data)
+			io.WriteString(w, ":")
+			writeOrderedJSON(w, v.values[i])
+		}
+		io.WriteString(w, "}")
+	case []interface{}:
+		io.WriteString(w, "[")
+		for i, item := range v {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			writeOrderedJSON(w, item)
+		}
+		io.WriteString(w, "]")
+	default:
+		data, _ := json.Marshal(v)
+		w.Write(data)
+	}
+}
+
+// reportCell numbers are kept as numbers for spreadsheets
+func reportCell(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case nil:
+		return nil
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,69 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewReportTableArray(t *testing.T) {
+	table, err := NewReportTable("teachers", []byte(`[
+		{"teacher_id": "t1", "load": {"lessons": 3, "rate": 0.5}, "classes": ["c1", "c2"]},
+		{"teacher_id": "t2", "missed": 1}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantColumns := []string{"teacher_id", "load.lessons", "load.rate", "classes", "missed"}
+	if !reflect.DeepEqual(table.Columns, wantColumns) {
+		t.Fatalf("columns = %v, want %v", table.Columns, wantColumns)
+	}
+	wantRows := [][]interface{}{
+		{"t1", int64(3), 0.5, "c1; c2", nil},
+		{"t2", nil, nil, nil, int64(1)},
+	}
+	if !reflect.DeepEqual(table.Rows, wantRows) {
+		t.Errorf("rows = %v, want %v", table.Rows, wantRows)
+	}
+	if len(table.Summary) != 0 {
+		t.Errorf("unexpected summary %v", table.Summary)
+	}
+}
+
+func TestNewReportTableObject(t *testing.T) {
+	table, err := NewReportTable("summary", []byte(`{
+		"total": 2,
+		"filter": {"year": 2022, "items": [{"id": "a"}]},
+		"data": {"page": 1, "items": [{"id": "s1", "score": 8}, {"id": "s2", "score": 9}]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(table.Columns, []string{"id", "score"}) || len(table.Rows) != 2 {
+		t.Fatalf("unexpected table %+v", table)
+	}
+	wantSummary := [][2]string{{"total", "2"}, {"filter.year", "2022"}, {"filter.items", `{"id":"a"}`}, {"data.page", "1"}}
+	if !reflect.DeepEqual(table.Summary, wantSummary) {
+		t.Errorf("summary = %v, want %v", table.Summary, wantSummary)
+	}
+
+	rows := table.ExportRows()
+	if len(rows) != len(wantSummary)+1+1+2 || rows[len(wantSummary)+1][0] != "id" {
+		t.Errorf("unexpected export rows %v", rows)
+	}
+}
+
+func TestNewReportTableSingleRow(t *testing.T) {
+	table, err := NewReportTable("overview", []byte(`{"num_above": 1, "num_meet": 2, "status": "ok"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(table.Columns, []string{"num_above", "num_meet", "status"}) || len(table.Rows) != 1 {
+		t.Errorf("unexpected table %+v", table)
+	}
+
+	if _, err := NewReportTable("broken", []byte(`{"a": `)); err == nil {
+		t.Error("want error of broken json")
+	}
+}
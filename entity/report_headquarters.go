@@ -0,0 +1,240 @@
+package entity
+
+import (
+	"sort"
+)
+
+type HeadquartersReportMetric string
+
+const (
+	// HeadquartersReportMetricAttendance attended classes of all classes of the students
+	HeadquartersReportMetricAttendance HeadquartersReportMetric = "attendance"
+	// HeadquartersReportMetricAssignmentCompletion finished study assignments of all study assignments of the students
+	HeadquartersReportMetricAssignmentCompletion HeadquartersReportMetric = "assignment_completion"
+	// HeadquartersReportMetricOutcomeAchievement achieved outcomes of all assessed outcomes of the students
+	HeadquartersReportMetricOutcomeAchievement HeadquartersReportMetric = "outcome_achievement"
+	// HeadquartersReportMetricContentUsage viewed lesson materials per student who viewed any
+	HeadquartersReportMetricContentUsage HeadquartersReportMetric = "content_usage"
+	// HeadquartersReportMetricTeacherLoad classes per teacher
+	HeadquartersReportMetricTeacherLoad HeadquartersReportMetric = "teacher_load"
+)
+
+func (m HeadquartersReportMetric) Valid() bool {
+	switch m {
+	case HeadquartersReportMetricAttendance,
+		HeadquartersReportMetricAssignmentCompletion,
+		HeadquartersReportMetricOutcomeAchievement,
+		HeadquartersReportMetricContentUsage,
+		HeadquartersReportMetricTeacherLoad:
+		return true
+	}
+	return false
+}
+
+// Report path of the existing organization report to drill down into
+func (m HeadquartersReportMetric) Report() string {
+	switch m {
+	case HeadquartersReportMetricAttendance:
+		return "/v1/reports/student_progress/class_attendance"
+	case HeadquartersReportMetricAssignmentCompletion:
+		return "/v1/reports/student_progress/assignment_completion"
+	case HeadquartersReportMetricOutcomeAchievement:
+		return "/v1/reports/student_progress/learn_outcome_achievement"
+	case HeadquartersReportMetricContentUsage:
+		return "/v1/reports/student_usage/material"
+	case HeadquartersReportMetricTeacherLoad:
+		return "/v1/reports/teacher_load/lessons_summary"
+	}
+	return ""
+}
+
+type HeadquartersReportLevel string
+
+const (
+	HeadquartersReportLevelOrganization HeadquartersReportLevel = "organization"
+	HeadquartersReportLevelSchool       HeadquartersReportLevel = "school"
+)
+
+func (l HeadquartersReportLevel) Valid() bool {
+	return l == HeadquartersReportLevelOrganization || l == HeadquartersReportLevelSchool
+}
+
+type HeadquartersReportBand string
+
+const (
+	HeadquartersReportBandTop         HeadquartersReportBand = "top"
+	HeadquartersReportBandUpperMiddle HeadquartersReportBand = "upper_middle"
+	HeadquartersReportBandLowerMiddle HeadquartersReportBand = "lower_middle"
+	HeadquartersReportBandBottom      HeadquartersReportBand = "bottom"
+)
+
+// HeadquartersReportBandOf quartile of the percentile
+func HeadquartersReportBandOf(percentile float64) HeadquartersReportBand {
+	switch {
+	case percentile >= 75:
+		return HeadquartersReportBandTop
+	case percentile >= 50:
+		return HeadquartersReportBandUpperMiddle
+	case percentile >= 25:
+		return HeadquartersReportBandLowerMiddle
+	}
+	return HeadquartersReportBandBottom
+}
+
+type HeadquartersReportRequest struct {
+	Metric HeadquartersReportMetric `json:"metric" form:"metric" enums:"attendance,assignment_completion,outcome_achievement,content_usage,teacher_load"`
+	Level  HeadquartersReportLevel  `json:"level" form:"level" enums:"organization,school"`
+	// ChildOrgID compare the schools of one child organization only
+	ChildOrgID string `json:"child_org_id" form:"child_org_id"`
+	StartAt    int64  `json:"start_at" form:"start_at"`
+	EndAt      int64  `json:"end_at" form:"end_at"`
+}
+
+func (r *HeadquartersReportRequest) Valid() bool {
+	if r.Level == "" {
+		r.Level = HeadquartersReportLevelOrganization
+	}
+	return r.Metric.Valid() && r.Level.Valid() && r.StartAt > 0 && r.StartAt < r.EndAt
+}
+
+type HeadquartersReportCondition struct {
+	Metric  HeadquartersReportMetric
+	Level   HeadquartersReportLevel
+	OrgIDs  []string
+	StartAt int64
+	EndAt   int64
+	// Weeks starts of the weeks the counts are grouped by, not grouped by week if empty
+	Weeks []int64
+}
+
+// HeadquartersReportCount counts of an organization or a school, of a week if grouped by week
+type HeadquartersReportCount struct {
+	OrgID     string `gorm:"column:org_id"`
+	SchoolID  string `gorm:"column:school_id"`
+	WeekStart int64  `gorm:"column:week_start"`
+	Count     int64  `gorm:"column:count"`
+	Total     int64  `gorm:"column:total"`
+}
+
+// HeadquartersReportValue the value is count/total, a rate or an average depending on the metric
+type HeadquartersReportValue struct {
+	Count int64   `json:"count"`
+	Total int64   `json:"total"`
+	Value float64 `json:"value"`
+}
+
+func NewHeadquartersReportValue(count, total int64) HeadquartersReportValue {
+	value := HeadquartersReportValue{Count: count, Total: total}
+	if total > 0 {
+		value.Value = float64(count) / float64(total)
+	}
+	return value
+}
+
+type HeadquartersReportTrendPoint struct {
+	WeekStart int64 `json:"week_start"`
+	HeadquartersReportValue
+}
+
+// HeadquartersReportDrillDown the existing report of the child organization, requested with org_id of the child organization
+type HeadquartersReportDrillDown struct {
+	OrgID    string `json:"org_id"`
+	SchoolID string `json:"school_id"`
+	Report   string `json:"report"`
+}
+
+type HeadquartersReportItem struct {
+	OrgID      string `json:"org_id"`
+	OrgName    string `json:"org_name"`
+	SchoolID   string `json:"school_id"`
+	SchoolName string `json:"school_name"`
+	HeadquartersReportValue
+	// Rank 1 is the highest value, items without data are not ranked
+	Rank       int                             `json:"rank"`
+	Percentile float64                         `json:"percentile"`
+	Band       HeadquartersReportBand          `json:"band" enums:"top,upper_middle,lower_middle,bottom"`
+	Trend      []*HeadquartersReportTrendPoint `json:"trend"`
+	// DrillDown nil if the user is not authorized in the child organization
+	DrillDown *HeadquartersReportDrillDown `json:"drill_down,omitempty"`
+}
+
+// HeadquartersReportBands values at the quartiles of the ranked items
+type HeadquartersReportBands struct {
+	P25 float64 `json:"p25"`
+	P50 float64 `json:"p50"`
+	P75 float64 `json:"p75"`
+}
+
+type HeadquartersReportResponse struct {
+	Metric  HeadquartersReportMetric `json:"metric"`
+	Level   HeadquartersReportLevel  `json:"level"`
+	StartAt int64                    `json:"start_at"`
+	EndAt   int64                    `json:"end_at"`
+	Weeks   []int64                  `json:"weeks"`
+	// Overall sum of the items, schedules of several schools are counted in each school
+	Overall      HeadquartersReportValue         `json:"overall"`
+	OverallTrend []*HeadquartersReportTrendPoint `json:"overall_trend"`
+	Bands        HeadquartersReportBands         `json:"bands"`
+	Items        []*HeadquartersReportItem       `json:"items"`
+}
+
+// RankHeadquartersReportItems sorts the items by value, ranks them and returns the bands, items without data are last
+func RankHeadquartersReportItems(items []*HeadquartersReportItem) HeadquartersReportBands {
+	sort.SliceStable(items, func(i, j int) bool {
+		if (items[i].Total > 0) != (items[j].Total > 0) {
+			return items[i].Total > 0
+		}
+		return items[i].Value > items[j].Value
+	})
+
+	var values []float64
+	for _, item := range items {
+		if item.Total > 0 {
+			values = append(values, item.Value)
+		}
+	}
+
+	n := len(values)
+	for i := 0; i < n; i++ {
+		// ties share the best rank and the average percentile of their positions
+		first, last := i, i
+		for first > 0 && values[first-1] == values[i] {
+			first--
+		}
+		for last < n-1 && values[last+1] == values[i] {
+			last++
+		}
+
+		item := items[i]
+		item.Rank = first + 1
+		item.Percentile = 100
+		if n > 1 {
+			below := float64(n-1-last) + float64(last-first)/2
+			item.Percentile = below / float64(n-1) * 100
+		}
+		item.Band = HeadquartersReportBandOf(item.Percentile)
+	}
+
+	if n == 0 {
+		return HeadquartersReportBands{}
+	}
+	ascending := make([]float64, n)
+	for i := range values {
+		ascending[n-1-i] = values[i]
+	}
+	return HeadquartersReportBands{
+		P25: quantile(ascending, 0.25),
+		P50: quantile(ascending, 0.5),
+		P75: quantile(ascending, 0.75),
+	}
+}
+
+// quantile of the ascending values with linear interpolation
+func quantile(ascending []float64, q float64) float64 {
+	position := q * float64(len(ascending)-1)
+	lower := int(position)
+	if lower+1 >= len(ascending) {
+		return ascending[lower]
+	}
+	return ascending[lower] + (ascending[lower+1]-ascending[lower])*(position-float64(lower))
+}
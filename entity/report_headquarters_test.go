@@ -0,0 +1,71 @@
+package entity
+
+import "testing"
+
+func TestRankHeadquartersReportItems(t *testing.T) {
+	item := func(id string, count, total int64) *HeadquartersReportItem {
+		return &HeadquartersReportItem{OrgID: id, HeadquartersReportValue: NewHeadquartersReportValue(count, total)}
+	}
+	items := []*HeadquartersReportItem{
+		item("empty", 0, 0),
+		item("low", 2, 10),
+		item("high", 9, 10),
+		item("middle1", 5, 10),
+		item("middle2", 5, 10),
+	}
+
+	bands := RankHeadquartersReportItems(items)
+
+	want := []struct {
+		id         string
+		rank       int
+		percentile float64
+		band       HeadquartersReportBand
+	}{
+		{"high", 1, 100, HeadquartersReportBandTop},
+		{"middle1", 2, 50, HeadquartersReportBandUpperMiddle},
+		{"middle2", 2, 50, HeadquartersReportBandUpperMiddle},
+		{"low", 4, 0, HeadquartersReportBandBottom},
+		{"empty", 0, 0, ""},
+	}
+	for i, w := range want {
+		got := items[i]
+		if got.OrgID != w.id || got.Rank != w.rank || got.Percentile != w.percentile || got.Band != w.band {
+			t.Errorf("item %d: got %s rank %d percentile %v band %q, want %+v", i, got.OrgID, got.Rank, got.Percentile, got.Band, w)
+		}
+	}
+
+	// values 0.2, 0.5, 0.5, 0.9
+	if bands.P25 != 0.425 || bands.P50 != 0.5 || bands.P75 != 0.6 {
+		t.Errorf("unexpected bands %+v", bands)
+	}
+}
+
+func TestRankHeadquartersReportItemsSingle(t *testing.T) {
+	items := []*HeadquartersReportItem{{HeadquartersReportValue: NewHeadquartersReportValue(1, 4)}}
+	bands := RankHeadquartersReportItems(items)
+	if items[0].Rank != 1 || items[0].Percentile != 100 || bands.P25 != 0.25 || bands.P75 != 0.25 {
+		t.Errorf("unexpected item %+v bands %+v", items[0], bands)
+	}
+
+	if bands := RankHeadquartersReportItems(nil); bands != (HeadquartersReportBands{}) {
+		t.Errorf("unexpected bands %+v", bands)
+	}
+}
+
+func TestHeadquartersReportRequestValid(t *testing.T) {
+	req := &HeadquartersReportRequest{Metric: HeadquartersReportMetricTeacherLoad, StartAt: 1, EndAt: 2}
+	if !req.Valid() || req.Level != HeadquartersReportLevelOrganization {
+		t.Errorf("unexpected request %+v", req)
+	}
+
+	for _, req := range []*HeadquartersReportRequest{
+		{Metric: "score", StartAt: 1, EndAt: 2},
+		{Metric: HeadquartersReportMetricAttendance, Level: "class", StartAt: 1, EndAt: 2},
+		{Metric: HeadquartersReportMetricAttendance, StartAt: 2, EndAt: 2},
+	} {
+		if req.Valid() {
+			t.Errorf("invalid request is accepted %+v", req)
+		}
+	}
+}
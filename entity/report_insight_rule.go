@@ -0,0 +1,565 @@
+package entity
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+type InsightCategory string
+
+const (
+	InsightCategoryLearningOutcome InsightCategory = "learning_outcome"
+	InsightCategoryAttendance      InsightCategory = "attendance"
+	InsightCategoryAssignment      InsightCategory = "assignment"
+)
+
+func (c InsightCategory) Valid() bool {
+	switch c {
+	case InsightCategoryLearningOutcome, InsightCategoryAttendance, InsightCategoryAssignment:
+		return true
+	default:
+		return false
+	}
+}
+
+// InsightCategories in the order of the app insight message
+var InsightCategories = []InsightCategory{
+	InsightCategoryLearningOutcome,
+	InsightCategoryAttendance,
+	InsightCategoryAssignment,
+}
+
+// InsightMetric values of a metric are weekly, percentages are from 0 to 100
+type InsightMetric string
+
+const (
+	// InsightMetricStudentRate achieved outcomes, attended classes or completed assignments of the student
+	InsightMetricStudentRate InsightMetric = "student_rate"
+	// InsightMetricClassRate average of students of the class
+	InsightMetricClassRate InsightMetric = "class_rate"
+	// InsightMetricOtherSubjectsRate the student in subjects not selected
+	InsightMetricOtherSubjectsRate InsightMetric = "other_subjects_rate"
+	// InsightMetricReviewRate re-achieved outcomes of the student, learning outcome only
+	InsightMetricReviewRate InsightMetric = "review_rate"
+	// InsightMetricCount achieved outcomes, attended classes or completed assignments
+	InsightMetricCount InsightMetric = "count"
+	// InsightMetricTotal learnt outcomes, scheduled classes or assignments
+	InsightMetricTotal InsightMetric = "total"
+	// InsightMetricDiffClass student_rate - class_rate
+	InsightMetricDiffClass InsightMetric = "diff_class"
+	// InsightMetricReviewDiffClass review_rate - class_rate, learning outcome only
+	InsightMetricReviewDiffClass InsightMetric = "review_diff_class"
+)
+
+func (m InsightMetric) Valid() bool {
+	switch m {
+	case InsightMetricStudentRate, InsightMetricClassRate, InsightMetricOtherSubjectsRate, InsightMetricReviewRate,
+		InsightMetricCount, InsightMetricTotal, InsightMetricDiffClass, InsightMetricReviewDiffClass:
+		return true
+	default:
+		return false
+	}
+}
+
+// InsightMetrics values of the Repoet4W weeks of every metric, the oldest week is 0 and the latest is 3
+type InsightMetrics map[InsightMetric][]float64
+
+// Value 0 for metrics or weeks without data
+func (m InsightMetrics) Value(metric InsightMetric, week int) float64 {
+	values := m[metric]
+	if week < 0 || week >= len(values) {
+		return 0
+	}
+	return values[week]
+}
+
+// insightPrecision differences are rounded before comparing, 0.7*100-0.5*100 is 20
+func insightPrecision(value float64) float64 {
+	return math.Round(value*1e6) / 1e6
+}
+
+func newInsightMetrics(studentRate, classRate, otherSubjectsRate, count, total func(week int) float64) InsightMetrics {
+	metrics := InsightMetrics{}
+	for week := 0; week < Repoet4W; week++ {
+		metrics[InsightMetricStudentRate] = append(metrics[InsightMetricStudentRate], insightPrecision(studentRate(week)))
+		metrics[InsightMetricClassRate] = append(metrics[InsightMetricClassRate], insightPrecision(classRate(week)))
+		metrics[InsightMetricOtherSubjectsRate] = append(metrics[InsightMetricOtherSubjectsRate], insightPrecision(otherSubjectsRate(week)))
+		metrics[InsightMetricCount] = append(metrics[InsightMetricCount], count(week))
+		metrics[InsightMetricTotal] = append(metrics[InsightMetricTotal], total(week))
+		metrics[InsightMetricDiffClass] = append(metrics[InsightMetricDiffClass],
+			insightPrecision(metrics[InsightMetricStudentRate][week]-metrics[InsightMetricClassRate][week]))
+	}
+	return metrics
+}
+
+// NewLearnOutcomeInsightMetrics res must be of Repoet4W durations
+func NewLearnOutcomeInsightMetrics(res *LearnOutcomeAchievementResponse) InsightMetrics {
+	item := func(week int) *LearnOutcomeAchievementResponseItem {
+		if week < len(res.Items) && res.Items[week] != nil {
+			return res.Items[week]
+		}
+		return &LearnOutcomeAchievementResponseItem{}
+	}
+
+	metrics := newInsightMetrics(
+		func(week int) float64 {
+			return (item(week).FirstAchievedPercentage + item(week).ReAchievedPercentage) * 100
+		},
+		func(week int) float64 { return item(week).ClassAverageAchievedPercentage * 100 },
+		func(week int) float64 { return item(week).UnSelectedSubjectsAverageAchievedPercentage * 100 },
+		func(week int) float64 { return float64(item(week).FirstAchievedCount + item(week).ReAchievedCount) },
+		func(week int) float64 {
+			return float64(item(week).FirstAchievedCount + item(week).ReAchievedCount + item(week).UnAchievedCount)
+		},
+	)
+	for week := 0; week < Repoet4W; week++ {
+		reviewRate := insightPrecision(item(week).ReAchievedPercentage * 100)
+		metrics[InsightMetricReviewRate] = append(metrics[InsightMetricReviewRate], reviewRate)
+		metrics[InsightMetricReviewDiffClass] = append(metrics[InsightMetricReviewDiffClass],
+			insightPrecision(reviewRate-metrics[InsightMetricClassRate][week]))
+	}
+	return metrics
+}
+
+// NewAttendanceInsightMetrics res must be of Repoet4W durations
+func NewAttendanceInsightMetrics(res *ClassAttendanceResponse) InsightMetrics {
+	item := func(week int) *ClassAttendanceResponseItem {
+		if week < len(res.Items) && res.Items[week] != nil {
+			return res.Items[week]
+		}
+		return &ClassAttendanceResponseItem{}
+	}
+
+	return newInsightMetrics(
+		func(week int) float64 { return item(week).AttendancePercentage * 100 },
+		func(week int) float64 { return item(week).ClassAverageAttendancePercentage * 100 },
+		func(week int) float64 { return item(week).UnSelectedSubjectsAverageAttendancePercentage * 100 },
+		func(week int) float64 { return float64(item(week).AttendedCount) },
+		func(week int) float64 { return float64(item(week).ScheduledCount) },
+	)
+}
+
+// NewAssignmentInsightMetrics res must be of Repoet4W durations
+func NewAssignmentInsightMetrics(res *AssignmentResponse) InsightMetrics {
+	item := func(week int) *AssignmentCompletionRate {
+		if week < len(res.Assignments) && res.Assignments[week] != nil {
+			return res.Assignments[week]
+		}
+		return &AssignmentCompletionRate{}
+	}
+
+	return newInsightMetrics(
+		func(week int) float64 { return item(week).StudentDesignatedSubject * 100 },
+		func(week int) float64 { return item(week).ClassDesignatedSubject * 100 },
+		func(week int) float64 { return item(week).StudentNonDesignatedSubject * 100 },
+		func(week int) float64 { return float64(item(week).StudentCompleteAssignment) },
+		func(week int) float64 { return float64(item(week).StudentTotalAssignment) },
+	)
+}
+
+type InsightConditionType string
+
+const (
+	// InsightConditionThreshold the metric of every week compared to the value
+	InsightConditionThreshold InsightConditionType = "threshold"
+	// InsightConditionTrend the change of the metric between every two consecutive weeks compared to the value
+	InsightConditionTrend InsightConditionType = "trend"
+	// InsightConditionClassCompare the metric minus the class average of every week compared to the value
+	InsightConditionClassCompare InsightConditionType = "class_compare"
+)
+
+type InsightOperator string
+
+const (
+	InsightOperatorGt  InsightOperator = "gt"
+	InsightOperatorGte InsightOperator = "gte"
+	InsightOperatorLt  InsightOperator = "lt"
+	InsightOperatorLte InsightOperator = "lte"
+	InsightOperatorEq  InsightOperator = "eq"
+	InsightOperatorNe  InsightOperator = "ne"
+)
+
+func (o InsightOperator) Compare(left, right float64) bool {
+	left, right = insightPrecision(left), insightPrecision(right)
+	switch o {
+	case InsightOperatorGt:
+		return left > right
+	case InsightOperatorGte:
+		return left >= right
+	case InsightOperatorLt:
+		return left < right
+	case InsightOperatorLte:
+		return left <= right
+	case InsightOperatorEq:
+		return left == right
+	case InsightOperatorNe:
+		return left != right
+	default:
+		return false
+	}
+}
+
+type InsightRuleCondition struct {
+	Type InsightConditionType `json:"type" enums:"threshold,trend,class_compare"`
+	// Metric class_compare uses student_rate when it is empty
+	Metric InsightMetric `json:"metric,omitempty" enums:"student_rate,class_rate,other_subjects_rate,review_rate,count,total,diff_class,review_diff_class"`
+	// Weeks 0 is the oldest and 3 is the latest, the condition must hold for all of them, trend needs at least 2
+	Weeks    []int           `json:"weeks"`
+	Operator InsightOperator `json:"operator" enums:"gt,gte,lt,lte,eq,ne"`
+	Value    float64         `json:"value"`
+}
+
+func (c *InsightRuleCondition) Valid() bool {
+	switch c.Type {
+	case InsightConditionThreshold, InsightConditionTrend:
+		if !c.Metric.Valid() {
+			return false
+		}
+	case InsightConditionClassCompare:
+		if c.Metric != "" && !c.Metric.Valid() {
+			return false
+		}
+	default:
+		return false
+	}
+
+	switch c.Operator {
+	case InsightOperatorGt, InsightOperatorGte, InsightOperatorLt, InsightOperatorLte, InsightOperatorEq, InsightOperatorNe:
+	default:
+		return false
+	}
+
+	if len(c.Weeks) == 0 || (c.Type == InsightConditionTrend && len(c.Weeks) < 2) {
+		return false
+	}
+	for _, week := range c.Weeks {
+		if week < 0 || week >= Repoet4W {
+			return false
+		}
+	}
+
+	return !math.IsNaN(c.Value) && !math.IsInf(c.Value, 0)
+}
+
+func (c *InsightRuleCondition) Match(metrics InsightMetrics) bool {
+	switch c.Type {
+	case InsightConditionThreshold:
+		for _, week := range c.Weeks {
+			if !c.Operator.Compare(metrics.Value(c.Metric, week), c.Value) {
+				return false
+			}
+		}
+		return true
+	case InsightConditionTrend:
+		for i := 1; i < len(c.Weeks); i++ {
+			change := metrics.Value(c.Metric, c.Weeks[i]) - metrics.Value(c.Metric, c.Weeks[i-1])
+			if !c.Operator.Compare(change, c.Value) {
+				return false
+			}
+		}
+		return len(c.Weeks) >= 2
+	case InsightConditionClassCompare:
+		metric := c.Metric
+		if metric == "" {
+			metric = InsightMetricStudentRate
+		}
+		for _, week := range c.Weeks {
+			if !c.Operator.Compare(metrics.Value(metric, week)-metrics.Value(InsightMetricClassRate, week), c.Value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// InsightRule fires when all conditions hold, the enabled rule of the highest priority fires in its category
+type InsightRule struct {
+	// Key unique in the rule set, fired rules are logged by it
+	Key      string          `json:"key"`
+	Category InsightCategory `json:"category" enums:"learning_outcome,attendance,assignment"`
+	Priority int             `json:"priority"`
+	Enabled  bool            `json:"enabled"`
+	// LabelID label of the app, the built-in labels are kept for app versions localizing them
+	LabelID    string                  `json:"label_id"`
+	Conditions []*InsightRuleCondition `json:"conditions"`
+	// Messages templates by language, e.g. "en", placeholders are {{metric.w3}} for the value of a week,
+	// {{metric.w2_w3}} for the change between two weeks and {{metric.avg_w1_w3}} for the average of weeks,
+	// values are magnitudes rounded up
+	Messages map[string]string `json:"messages"`
+	// Params label params of the app by name, each is a placeholder
+	Params map[string]string `json:"params,omitempty"`
+}
+
+var insightRuleKeyPattern = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+
+func (r *InsightRule) Valid() bool {
+	if !insightRuleKeyPattern.MatchString(r.Key) || !r.Category.Valid() {
+		return false
+	}
+
+	r.LabelID = strings.TrimSpace(r.LabelID)
+	if len(r.LabelID) > 128 || len(r.Conditions) > constant.InsightRuleMaxConditions {
+		return false
+	}
+	for _, condition := range r.Conditions {
+		if condition == nil || !condition.Valid() {
+			return false
+		}
+	}
+
+	if len(r.Messages) == 0 && r.LabelID == "" {
+		return false
+	}
+	for lang, message := range r.Messages {
+		if lang == "" || len(lang) > 16 || len(message) > constant.InsightRuleMaxMessageLength {
+			return false
+		}
+	}
+
+	for name, param := range r.Params {
+		matches := insightPlaceholderPattern.FindStringSubmatch(strings.TrimSpace(param))
+		if name == "" || len(matches) != 3 || !InsightMetric(matches[1]).Valid() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *InsightRule) Match(metrics InsightMetrics) bool {
+	for _, condition := range r.Conditions {
+		if !condition.Match(metrics) {
+			return false
+		}
+	}
+	return true
+}
+
+// Render the message of the language, then english, then any, with the params
+func (r *InsightRule) Render(metrics InsightMetrics, lang string) (string, map[string]float64) {
+	message, ok := r.Messages[lang]
+	if !ok {
+		message, ok = r.Messages[InsightDefaultLanguage]
+	}
+	if !ok {
+		langs := make([]string, 0, len(r.Messages))
+		for key := range r.Messages {
+			langs = append(langs, key)
+		}
+		sort.Strings(langs)
+		if len(langs) > 0 {
+			message = r.Messages[langs[0]]
+		}
+	}
+
+	message = insightPlaceholderPattern.ReplaceAllStringFunc(message, func(placeholder string) string {
+		value, ok := metrics.Placeholder(placeholder)
+		if !ok {
+			return placeholder
+		}
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	})
+
+	params := make(map[string]float64, len(r.Params))
+	for name, placeholder := range r.Params {
+		params[name], _ = metrics.Placeholder(strings.TrimSpace(placeholder))
+	}
+	return message, params
+}
+
+const InsightDefaultLanguage = "en"
+
+var insightPlaceholderPattern = regexp.MustCompile(`\{\{\s*([a-z_]+)\.(w\d|w\d_w\d|avg_w\d_w\d)\s*\}\}`)
+
+// Placeholder value of {{metric.w3}}, {{metric.w2_w3}} or {{metric.avg_w1_w3}}, as a magnitude rounded up
+func (m InsightMetrics) Placeholder(placeholder string) (float64, bool) {
+	matches := insightPlaceholderPattern.FindStringSubmatch(placeholder)
+	if len(matches) != 3 {
+		return 0, false
+	}
+	metric := InsightMetric(matches[1])
+	if _, ok := m[metric]; !ok {
+		return 0, false
+	}
+
+	weeks := strings.Split(strings.TrimPrefix(matches[2], "avg_"), "_")
+	from, _ := strconv.Atoi(strings.TrimPrefix(weeks[0], "w"))
+	to := from
+	if len(weeks) > 1 {
+		to, _ = strconv.Atoi(strings.TrimPrefix(weeks[1], "w"))
+	}
+	if from >= Repoet4W || to >= Repoet4W {
+		return 0, false
+	}
+
+	var value float64
+	switch {
+	case strings.HasPrefix(matches[2], "avg_"):
+		if from > to {
+			from, to = to, from
+		}
+		for week := from; week <= to; week++ {
+			value += m.Value(metric, week)
+		}
+		value /= float64(to - from + 1)
+	case len(weeks) > 1:
+		value = m.Value(metric, to) - m.Value(metric, from)
+	default:
+		value = m.Value(metric, from)
+	}
+	return math.Ceil(insightPrecision(math.Abs(value))), true
+}
+
+type InsightRules []*InsightRule
+
+func (rules InsightRules) Valid() bool {
+	if len(rules) > constant.InsightRuleMaxRules {
+		return false
+	}
+
+	keys := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if rule == nil || !rule.Valid() || keys[rule.Key] {
+			return false
+		}
+		keys[rule.Key] = true
+	}
+	return true
+}
+
+// Sorted enabled rules of the category, the highest priority first, rules of the same priority keep their order
+func (rules InsightRules) Sorted(category InsightCategory) InsightRules {
+	result := InsightRules{}
+	for _, rule := range rules {
+		if rule.Enabled && rule.Category == category {
+			result = append(result, rule)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Priority > result[j].Priority
+	})
+	return result
+}
+
+// Fire the first matched rule of Sorted, nil if none matched
+func (rules InsightRules) Fire(category InsightCategory, metrics InsightMetrics) *InsightRule {
+	for _, rule := range rules.Sorted(category) {
+		if rule.Match(metrics) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// InsightRuleSet rules of an organization, organizations without rules use DefaultInsightRules
+type InsightRuleSet struct {
+	OrgID string `gorm:"column:org_id;PRIMARY_KEY"`
+	// Rules json of InsightRules, empty is reset to the default rules
+	Rules     string `gorm:"column:rules"`
+	UpdaterID string `gorm:"column:updater_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+}
+
+func (InsightRuleSet) TableName() string {
+	return constant.TableNameInsightRuleSet
+}
+
+// InsightRuleLog a fired rule, for analytics
+type InsightRuleLog struct {
+	ID         string          `gorm:"column:id;PRIMARY_KEY"`
+	OrgID      string          `gorm:"column:org_id"`
+	ClassID    string          `gorm:"column:class_id"`
+	StudentID  string          `gorm:"column:student_id"`
+	Category   InsightCategory `gorm:"column:category"`
+	RuleKey    string          `gorm:"column:rule_key"`
+	LabelID    string          `gorm:"column:label_id"`
+	Params     string          `gorm:"column:params"`
+	Preview    bool            `gorm:"column:preview"`
+	OperatorID string          `gorm:"column:operator_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+}
+
+func (InsightRuleLog) TableName() string {
+	return constant.TableNameInsightRuleLog
+}
+
+type InsightRuleSetView struct {
+	Rules InsightRules `json:"rules"`
+	// Customized false when the default rules are used
+	Customized bool  `json:"customized"`
+	UpdateAt   int64 `json:"update_at"`
+}
+
+type InsightRuleSetUpdateReq struct {
+	Rules InsightRules `json:"rules"`
+}
+
+// InsightMessage the fired rule of a category
+type InsightMessage struct {
+	Category InsightCategory    `json:"category"`
+	RuleKey  string             `json:"rule_key"`
+	LabelID  string             `json:"label_id"`
+	Message  string             `json:"message"`
+	Params   map[string]float64 `json:"params"`
+}
+
+type InsightRulePreviewReq struct {
+	ClassID   string `json:"class_id"`
+	StudentID string `json:"student_id"`
+	EndTime   int    `json:"end_time"`
+	Lang      string `json:"lang"`
+	// Rules unsaved rules to try, the rules of the organization when it is empty
+	Rules InsightRules `json:"rules"`
+}
+
+type InsightRuleResult struct {
+	Key      string `json:"key"`
+	Priority int    `json:"priority"`
+	LabelID  string `json:"label_id"`
+	Matched  bool   `json:"matched"`
+}
+
+type InsightCategoryPreview struct {
+	Category InsightCategory `json:"category"`
+	Metrics  InsightMetrics  `json:"metrics"`
+	// Rules enabled rules of the category, the highest priority first
+	Rules []*InsightRuleResult `json:"rules"`
+	Fired *InsightMessage      `json:"fired"`
+}
+
+type InsightRulePreviewReply struct {
+	Categories []*InsightCategoryPreview `json:"categories"`
+}
+
+type InsightRuleStatsReq struct {
+	StartAt int64 `form:"start_at"`
+	EndAt   int64 `form:"end_at"`
+}
+
+// InsightRuleStat times a rule fired, previews are not counted
+type InsightRuleStat struct {
+	Category InsightCategory `json:"category" gorm:"column:category"`
+	RuleKey  string          `json:"rule_key" gorm:"column:rule_key"`
+	LabelID  string          `json:"label_id" gorm:"column:label_id"`
+	Count    int64           `json:"count" gorm:"column:count"`
+}
+
+// SetLabelParams the params of a fired rule by their json names
+func SetLabelParams(params map[string]float64, labelParams interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, labelParams)
+}
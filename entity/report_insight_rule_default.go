@@ -0,0 +1,126 @@
+package entity
+
+// DefaultInsightRules rules of organizations without their own, same labels and params as the built-in app insight messages
+func DefaultInsightRules() InsightRules {
+	threshold := func(metric InsightMetric, operator InsightOperator, value float64, weeks ...int) *InsightRuleCondition {
+		return &InsightRuleCondition{Type: InsightConditionThreshold, Metric: metric, Weeks: weeks, Operator: operator, Value: value}
+	}
+	trend := func(operator InsightOperator, value float64, weeks ...int) *InsightRuleCondition {
+		return &InsightRuleCondition{Type: InsightConditionTrend, Metric: InsightMetricStudentRate, Weeks: weeks, Operator: operator, Value: value}
+	}
+	classCompare := func(metric InsightMetric, operator InsightOperator, value float64, weeks ...int) *InsightRuleCondition {
+		return &InsightRuleCondition{Type: InsightConditionClassCompare, Metric: metric, Weeks: weeks, Operator: operator, Value: value}
+	}
+	noData := func(weeks ...int) []*InsightRuleCondition {
+		return []*InsightRuleCondition{
+			threshold(InsightMetricStudentRate, InsightOperatorEq, 0, weeks...),
+			threshold(InsightMetricClassRate, InsightOperatorEq, 0, weeks...),
+			threshold(InsightMetricOtherSubjectsRate, InsightOperatorEq, 0, weeks...),
+		}
+	}
+
+	// category, key prefix, label prefix, noun, params of the label names
+	type labels struct {
+		category     InsightCategory
+		key          string
+		label        string
+		noun         string
+		count        string
+		total        string
+		newCount     string
+		compareClass string
+		class3Week   string
+		lastWeek     string
+		last3Week    string
+	}
+	categories := []labels{
+		{InsightCategoryLearningOutcome, "lo", "report_msg_lo", "learning outcome achievement",
+			"achieved_lo_count", "learnt_lo_count", "achieved_lo_count",
+			"lo_compare_class", "lo_compare_class_3_week", "lo_compare_last_week", "lo_compare_last_3_week"},
+		{InsightCategoryAttendance, "att", "report_msg_att", "attendance",
+			"attended_count", "scheduled_count", "attended_count",
+			"lo_compare_class", "lo_compare_class_3_week", "attend_compare_last_week", "attend_compare_last_3_week"},
+		{InsightCategoryAssignment, "assign", "report_msg_assign", "assignment completion",
+			"assign_complete_count", "assignment_count", "assignment_complete_count",
+			"assign_compare_class", "assign_compare_class_3_week", "assign_compare_last_week", "assign_compare_3_week"},
+	}
+
+	rules := InsightRules{}
+	for _, c := range categories {
+		rule := func(name string, priority int, message string, params map[string]string, conditions ...*InsightRuleCondition) *InsightRule {
+			return &InsightRule{
+				Key:        c.key + "_" + name,
+				Category:   c.category,
+				Priority:   priority,
+				Enabled:    true,
+				LabelID:    c.label + "_" + name,
+				Conditions: conditions,
+				Messages:   map[string]string{InsightDefaultLanguage: message},
+				Params:     params,
+			}
+		}
+
+		rules = append(rules,
+			&InsightRule{
+				Key:        c.key + "_no_data",
+				Category:   c.category,
+				Priority:   100,
+				Enabled:    true,
+				LabelID:    ReportInsightMessageNoData,
+				Conditions: noData(3),
+				Messages:   map[string]string{InsightDefaultLanguage: "There is no " + c.noun + " data this week."},
+			},
+			rule("new", 90, "{{count.w3}} of {{total.w3}} this week, the first week with "+c.noun+" data.",
+				map[string]string{c.newCount: "{{count.w3}}", c.total: "{{total.w3}}"},
+				noData(0, 1, 2)...),
+			rule("high_class_3w", 80, "The "+c.noun+" has been {{diff_class.avg_w1_w3}}% above the class average for 3 weeks.",
+				map[string]string{c.class3Week: "{{diff_class.avg_w1_w3}}"},
+				classCompare(InsightMetricStudentRate, InsightOperatorGt, 0, 1, 2, 3)),
+			rule("low_class_3w", 80, "The "+c.noun+" has been {{diff_class.avg_w1_w3}}% below the class average for 3 weeks.",
+				map[string]string{c.class3Week: "{{diff_class.avg_w1_w3}}"},
+				classCompare(InsightMetricStudentRate, InsightOperatorLt, 0, 1, 2, 3)),
+			rule("increase_previous_large_w", 70, "The "+c.noun+" increased by {{student_rate.w2_w3}}% from last week.",
+				map[string]string{c.lastWeek: "{{student_rate.w2_w3}}"},
+				trend(InsightOperatorGte, 20, 2, 3)),
+			rule("decrease_previous_large_w", 70, "The "+c.noun+" decreased by {{student_rate.w2_w3}}% from last week.",
+				map[string]string{c.lastWeek: "{{student_rate.w2_w3}}"},
+				trend(InsightOperatorLte, -20, 2, 3)),
+		)
+
+		if c.category == InsightCategoryLearningOutcome {
+			rules = append(rules,
+				rule("high_class_review_w", 60, "Reviewed outcomes are {{review_diff_class.w3}}% above the class average this week.",
+					map[string]string{"lo_review_compare_class": "{{review_diff_class.w3}}"},
+					classCompare(InsightMetricReviewRate, InsightOperatorGte, 10, 3)),
+				rule("low_class_review_w", 60, "Reviewed outcomes are {{review_diff_class.w3}}% below the class average this week.",
+					map[string]string{"lo_review_compare_class": "{{review_diff_class.w3}}"},
+					classCompare(InsightMetricReviewRate, InsightOperatorLte, -10, 3)),
+			)
+		}
+
+		rules = append(rules,
+			rule("increase_3w", 50, "The "+c.noun+" has increased for 3 weeks, by {{student_rate.w0_w3}}% in total.",
+				map[string]string{c.last3Week: "{{student_rate.w0_w3}}"},
+				trend(InsightOperatorGt, 0, 0, 1, 2, 3)),
+			rule("decrease_3w", 50, "The "+c.noun+" has decreased for 3 weeks, by {{student_rate.w0_w3}}% in total.",
+				map[string]string{c.last3Week: "{{student_rate.w0_w3}}"},
+				trend(InsightOperatorLt, 0, 0, 1, 2, 3)),
+			rule("high_class_w", 40, "The "+c.noun+" is {{diff_class.w3}}% above the class average this week.",
+				map[string]string{c.compareClass: "{{diff_class.w3}}"},
+				classCompare(InsightMetricStudentRate, InsightOperatorGt, 0, 3)),
+			rule("low_class_w", 40, "The "+c.noun+" is {{diff_class.w3}}% below the class average this week.",
+				map[string]string{c.compareClass: "{{diff_class.w3}}"},
+				classCompare(InsightMetricStudentRate, InsightOperatorLt, 0, 3)),
+			rule("increase_previous_w", 30, "The "+c.noun+" increased by {{student_rate.w2_w3}}% from last week.",
+				map[string]string{c.lastWeek: "{{student_rate.w2_w3}}"},
+				trend(InsightOperatorGt, 0, 2, 3)),
+			rule("decrease_previous_w", 30, "The "+c.noun+" decreased by {{student_rate.w2_w3}}% from last week.",
+				map[string]string{c.lastWeek: "{{student_rate.w2_w3}}"},
+				trend(InsightOperatorLt, 0, 2, 3)),
+			rule("default", 0, "{{count.w3}} of {{total.w3}} this week.",
+				map[string]string{c.count: "{{count.w3}}", c.total: "{{total.w3}}"}),
+		)
+	}
+
+	return rules
+}
@@ -0,0 +1,118 @@
+package entity
+
+import (
+	"testing"
+)
+
+func TestDefaultInsightRulesValid(t *testing.T) {
+	if !DefaultInsightRules().Valid() {
+		t.Fatal("default rules are invalid")
+	}
+}
+
+func TestDefaultInsightRulesFire(t *testing.T) {
+	rules := DefaultInsightRules()
+	attendance := func(studentRates, classRates [Repoet4W]float64) InsightMetrics {
+		res := &ClassAttendanceResponse{}
+		for week := 0; week < Repoet4W; week++ {
+			res.Items = append(res.Items, &ClassAttendanceResponseItem{
+				AttendancePercentage:             studentRates[week],
+				ClassAverageAttendancePercentage: classRates[week],
+				AttendedCount:                    int(studentRates[week] * 10),
+				ScheduledCount:                   10,
+			})
+		}
+		return NewAttendanceInsightMetrics(res)
+	}
+
+	tests := []struct {
+		name    string
+		metrics InsightMetrics
+		label   string
+		params  map[string]float64
+	}{
+		{"no data", attendance([4]float64{0.5, 0.5, 0.5, 0}, [4]float64{0.5, 0.5, 0.5, 0}), ReportInsightMessageNoData, map[string]float64{}},
+		{"new", attendance([4]float64{0, 0, 0, 0.7}, [4]float64{0, 0, 0, 0.6}), AttNew, map[string]float64{"attended_count": 7, "scheduled_count": 10}},
+		{"above class 3 weeks", attendance([4]float64{0.5, 0.9, 0.8, 0.9}, [4]float64{0.5, 0.6, 0.6, 0.6}), AttHighClass3w, map[string]float64{"lo_compare_class_3_week": 27}},
+		{"large decrease", attendance([4]float64{0.5, 0.6, 0.9, 0.7}, [4]float64{0.5, 0.6, 0.8, 0.8}), AttDecreasePreviousLargeW, map[string]float64{"attend_compare_last_week": 20}},
+		{"increase 3 weeks", attendance([4]float64{0.5, 0.6, 0.7, 0.8}, [4]float64{0.6, 0.5, 0.8, 0.8}), AttIncrease3w, map[string]float64{"attend_compare_last_3_week": 30}},
+		{"default", attendance([4]float64{0.5, 0.5, 0.5, 0.5}, [4]float64{0.5, 0.5, 0.5, 0.5}), AttDefault, map[string]float64{"attended_count": 5, "scheduled_count": 10}},
+	}
+	for _, tt := range tests {
+		rule := rules.Fire(InsightCategoryAttendance, tt.metrics)
+		if rule == nil || rule.LabelID != tt.label {
+			t.Errorf("%s: fired %+v, want %s", tt.name, rule, tt.label)
+			continue
+		}
+		_, params := rule.Render(tt.metrics, "ko")
+		for name, want := range tt.params {
+			if params[name] != want {
+				t.Errorf("%s: param %s = %v, want %v", tt.name, name, params[name], want)
+			}
+		}
+	}
+}
+
+func TestInsightRuleRender(t *testing.T) {
+	metrics := InsightMetrics{
+		InsightMetricStudentRate: {40, 50, 60, 45.5},
+		InsightMetricDiffClass:   {-1, -2, -3, -4},
+	}
+	rule := &InsightRule{
+		Messages: map[string]string{
+			"en": "{{ student_rate.w3 }}% now, {{student_rate.w2_w3}}% less, {{diff_class.avg_w1_w3}} below, {{count.w3}}",
+			"ko": "{{student_rate.w3}}%",
+		},
+		Params: map[string]string{"change": "{{student_rate.w0_w2}}"},
+	}
+
+	message, params := rule.Render(metrics, "fr")
+	if message != "46% now, 15% less, 3 below, {{count.w3}}" {
+		t.Errorf("unexpected message %q", message)
+	}
+	if params["change"] != 20 {
+		t.Errorf("unexpected params %v", params)
+	}
+	if message, _ = rule.Render(metrics, "ko"); message != "46%" {
+		t.Errorf("unexpected message %q", message)
+	}
+}
+
+func TestInsightRulesValid(t *testing.T) {
+	valid := func() *InsightRule {
+		return &InsightRule{
+			Key:      "att_low",
+			Category: InsightCategoryAttendance,
+			Enabled:  true,
+			Conditions: []*InsightRuleCondition{
+				{Type: InsightConditionThreshold, Metric: InsightMetricStudentRate, Weeks: []int{3}, Operator: InsightOperatorLt, Value: 50},
+			},
+			Messages: map[string]string{"en": "low attendance"},
+		}
+	}
+	if !(InsightRules{valid()}).Valid() {
+		t.Fatal("want valid")
+	}
+
+	tests := []func(rule *InsightRule){
+		func(rule *InsightRule) { rule.Key = "Att Low" },
+		func(rule *InsightRule) { rule.Category = "unknown" },
+		func(rule *InsightRule) { rule.Conditions[0].Weeks = []int{4} },
+		func(rule *InsightRule) { rule.Conditions[0].Type = InsightConditionTrend },
+		func(rule *InsightRule) { rule.Conditions[0].Operator = "between" },
+		func(rule *InsightRule) { rule.Conditions[0].Metric = "score" },
+		func(rule *InsightRule) { rule.Params = map[string]string{"value": "student_rate"} },
+		func(rule *InsightRule) { rule.Messages = nil },
+	}
+	for i, change := range tests {
+		rule := valid()
+		change(rule)
+		if (InsightRules{rule}).Valid() {
+			t.Errorf("case %d: invalid rule is accepted", i)
+		}
+	}
+
+	if (InsightRules{valid(), valid()}).Valid() {
+		t.Error("duplicate keys are accepted")
+	}
+}
@@ -0,0 +1,274 @@
+package entity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+// LearnerReportRequest the report of a student in a class, worded for parents
+type LearnerReportRequest struct {
+	ClassID   string `json:"class_id" form:"class_id"`
+	StudentID string `json:"student_id" form:"student_id"`
+	StartAt   int64  `json:"start_at" form:"start_at"`
+	EndAt     int64  `json:"end_at" form:"end_at"`
+}
+
+func (r *LearnerReportRequest) Valid() bool {
+	return r.ClassID != "" && r.StudentID != "" &&
+		r.StartAt >= 0 && r.StartAt < r.EndAt &&
+		r.EndAt-r.StartAt <= constant.LearnerReportMaxDays*constant.ReportRollupDay
+}
+
+// LearnerReportCounts classes, assignments and outcomes of the student in the time range
+type LearnerReportCounts struct {
+	ClassesScheduled    int `gorm:"column:classes_scheduled"`
+	ClassesAttended     int `gorm:"column:classes_attended"`
+	AssignmentsAssigned int `gorm:"column:assignments_assigned"`
+	AssignmentsDone     int `gorm:"column:assignments_done"`
+	OutcomesAssessed    int `gorm:"column:outcomes_assessed"`
+	OutcomesAchieved    int `gorm:"column:outcomes_achieved"`
+}
+
+type LearnerReportComment struct {
+	Title       string `json:"title" gorm:"column:title"`
+	TeacherID   string `json:"-" gorm:"column:teacher_id"`
+	TeacherName string `json:"teacher_name" gorm:"-"`
+	Comment     string `json:"comment" gorm:"column:comment"`
+	CreateAt    int64  `json:"create_at" gorm:"column:create_at"`
+}
+
+type LearnerReport struct {
+	ClassID     string `json:"class_id"`
+	ClassName   string `json:"class_name"`
+	StudentID   string `json:"student_id"`
+	StudentName string `json:"student_name"`
+	StartAt     int64  `json:"start_at"`
+	EndAt       int64  `json:"end_at"`
+
+	ClassesScheduled    int `json:"classes_scheduled"`
+	ClassesAttended     int `json:"classes_attended"`
+	AssignmentsAssigned int `json:"assignments_assigned"`
+	AssignmentsDone     int `json:"assignments_done"`
+	OutcomesAssessed    int `json:"outcomes_assessed"`
+	OutcomesAchieved    int `json:"outcomes_achieved"`
+	// AchievedOutcomes names of the latest achieved outcomes
+	AchievedOutcomes []string                `json:"achieved_outcomes"`
+	Comments         []*LearnerReportComment `json:"comments"`
+	// Highlights the counts in plain sentences
+	Highlights []string `json:"highlights"`
+}
+
+func NewLearnerReport(req *LearnerReportRequest, counts *LearnerReportCounts) *LearnerReport {
+	report := &LearnerReport{
+		ClassID:             req.ClassID,
+		StudentID:           req.StudentID,
+		StartAt:             req.StartAt,
+		EndAt:               req.EndAt,
+		ClassesScheduled:    counts.ClassesScheduled,
+		ClassesAttended:     counts.ClassesAttended,
+		AssignmentsAssigned: counts.AssignmentsAssigned,
+		AssignmentsDone:     counts.AssignmentsDone,
+		OutcomesAssessed:    counts.OutcomesAssessed,
+		OutcomesAchieved:    counts.OutcomesAchieved,
+		AchievedOutcomes:    []string{},
+		Comments:            []*LearnerReportComment{},
+	}
+	report.Highlights = report.highlights()
+	return report
+}
+
+func learnerReportCount(count int, one, many string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, one)
+	}
+	return fmt.Sprintf("%d %s", count, many)
+}
+
+func (r *LearnerReport) highlights() []string {
+	var highlights []string
+
+	switch {
+	case r.ClassesScheduled == 0:
+		highlights = append(highlights, "There were no classes in this period.")
+	case r.ClassesAttended >= r.ClassesScheduled:
+		highlights = append(highlights, fmt.Sprintf("Joined every class, %s in total. Great attendance!", learnerReportCount(r.ClassesScheduled, "class", "classes")))
+	case r.ClassesAttended == 0:
+		highlights = append(highlights, fmt.Sprintf("Missed all %s in this period.", learnerReportCount(r.ClassesScheduled, "class", "classes")))
+	default:
+		highlights = append(highlights, fmt.Sprintf("Joined %d of %s.", r.ClassesAttended, learnerReportCount(r.ClassesScheduled, "class", "classes")))
+	}
+
+	switch {
+	case r.AssignmentsAssigned == 0:
+		highlights = append(highlights, "No assignments were given in this period.")
+	case r.AssignmentsDone >= r.AssignmentsAssigned:
+		highlights = append(highlights, fmt.Sprintf("Finished every assignment, %s in total. Well done!", learnerReportCount(r.AssignmentsAssigned, "assignment", "assignments")))
+	default:
+		highlights = append(highlights, fmt.Sprintf("Finished %d of %s, %d still to do.",
+			r.AssignmentsDone, learnerReportCount(r.AssignmentsAssigned, "assignment", "assignments"), r.AssignmentsAssigned-r.AssignmentsDone))
+	}
+
+	switch {
+	case r.OutcomesAssessed == 0:
+		highlights = append(highlights, "No learning outcomes were assessed yet.")
+	case r.OutcomesAchieved == 0:
+		highlights = append(highlights, fmt.Sprintf("Is still working towards %s.", learnerReportCount(r.OutcomesAssessed, "learning outcome", "learning outcomes")))
+	default:
+		highlights = append(highlights, fmt.Sprintf("Achieved %d of %s.", r.OutcomesAchieved, learnerReportCount(r.OutcomesAssessed, "learning outcome", "learning outcomes")))
+	}
+
+	return highlights
+}
+
+// Table the report printed as a pdf
+func (r *LearnerReport) Table(timeOffset int) *ReportTable {
+	zone := time.FixedZone("", timeOffset)
+	table := &ReportTable{
+		Title: fmt.Sprintf("Learning report of %s", r.StudentName),
+		Summary: [][2]string{
+			{"Class", r.ClassName},
+			{"Period", fmt.Sprintf("%s - %s",
+				time.Unix(r.StartAt, 0).In(zone).Format("2006-01-02"),
+				time.Unix(r.EndAt-1, 0).In(zone).Format("2006-01-02"))},
+			{"Classes joined", fmt.Sprintf("%d / %d", r.ClassesAttended, r.ClassesScheduled)},
+			{"Assignments finished", fmt.Sprintf("%d / %d", r.AssignmentsDone, r.AssignmentsAssigned)},
+			{"Learning outcomes achieved", fmt.Sprintf("%d / %d", r.OutcomesAchieved, r.OutcomesAssessed)},
+		},
+		Columns: []string{"Date", "Teacher", "Lesson", "Comment"},
+	}
+	for i, highlight := range r.Highlights {
+		table.Summary = append(table.Summary, [2]string{"Highlight " + strconv.Itoa(i+1), highlight})
+	}
+	for _, name := range r.AchievedOutcomes {
+		table.Summary = append(table.Summary, [2]string{"Achieved", name})
+	}
+	for _, comment := range r.Comments {
+		table.Rows = append(table.Rows, []interface{}{
+			time.Unix(comment.CreateAt, 0).In(zone).Format("2006-01-02"),
+			comment.TeacherName,
+			comment.Title,
+			comment.Comment,
+		})
+	}
+	return table
+}
+
+// LearnerReportShareLink a signed link of a learner report, parents open it without login
+type LearnerReportShareLink struct {
+	ID         string `gorm:"column:id;PRIMARY_KEY"`
+	OrgID      string `gorm:"column:org_id"`
+	ClassID    string `gorm:"column:class_id"`
+	StudentID  string `gorm:"column:student_id"`
+	StartAt    int64  `gorm:"column:start_at"`
+	EndAt      int64  `gorm:"column:end_at"`
+	ExpireAt   int64  `gorm:"column:expire_at"`
+	RevokeAt   int64  `gorm:"column:revoke_at"`
+	ViewCount  int    `gorm:"column:view_count"`
+	LastViewAt int64  `gorm:"column:last_view_at"`
+	CreatorID  string `gorm:"column:creator_id"`
+	CreateAt   int64  `gorm:"column:create_at"`
+	UpdateAt   int64  `gorm:"column:update_at"`
+}
+
+func (LearnerReportShareLink) TableName() string {
+	return constant.TableNameLearnerReportShareLink
+}
+
+type LearnerReportShareLinkStatus string
+
+const (
+	LearnerReportShareLinkStatusActive  LearnerReportShareLinkStatus = "active"
+	LearnerReportShareLinkStatusExpired LearnerReportShareLinkStatus = "expired"
+	LearnerReportShareLinkStatusRevoked LearnerReportShareLinkStatus = "revoked"
+)
+
+func (l *LearnerReportShareLink) Status(now int64) LearnerReportShareLinkStatus {
+	if l.RevokeAt > 0 {
+		return LearnerReportShareLinkStatusRevoked
+	}
+	if now >= l.ExpireAt {
+		return LearnerReportShareLinkStatusExpired
+	}
+	return LearnerReportShareLinkStatusActive
+}
+
+func (l *LearnerReportShareLink) Request() *LearnerReportRequest {
+	return &LearnerReportRequest{
+		ClassID:   l.ClassID,
+		StudentID: l.StudentID,
+		StartAt:   l.StartAt,
+		EndAt:     l.EndAt,
+	}
+}
+
+// LearnerReportShareLinkSignature hex(hmac_sha256(secret, "<id>.<expire_at>")), a changed expiry breaks the signature
+func LearnerReportShareLinkSignature(secret, id string, expireAt int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id + "." + strconv.FormatInt(expireAt, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type LearnerReportShareLinkAddReq struct {
+	LearnerReportRequest
+	// ExpireHours the link works for, constant.LearnerReportShareLinkDefaultExpireHours by default
+	ExpireHours int `json:"expire_hours"`
+}
+
+func (r *LearnerReportShareLinkAddReq) Valid() bool {
+	if r.ExpireHours == 0 {
+		r.ExpireHours = constant.LearnerReportShareLinkDefaultExpireHours
+	}
+	return r.LearnerReportRequest.Valid() &&
+		r.ExpireHours > 0 && r.ExpireHours <= constant.LearnerReportShareLinkMaxExpireHours
+}
+
+type LearnerReportShareLinkQueryReq struct {
+	ClassID   string `json:"class_id" form:"class_id"`
+	StudentID string `json:"student_id" form:"student_id"`
+	PageIndex int    `json:"page_index" form:"page_index"`
+	PageSize  int    `json:"page_size" form:"page_size"`
+}
+
+type LearnerReportShareLinkView struct {
+	ID         string                       `json:"id"`
+	ClassID    string                       `json:"class_id"`
+	StudentID  string                       `json:"student_id"`
+	StartAt    int64                        `json:"start_at"`
+	EndAt      int64                        `json:"end_at"`
+	URL        string                       `json:"url"`
+	ExpireAt   int64                        `json:"expire_at"`
+	Status     LearnerReportShareLinkStatus `json:"status"`
+	ViewCount  int                          `json:"view_count"`
+	LastViewAt int64                        `json:"last_view_at"`
+	CreatorID  string                       `json:"creator_id"`
+	CreateAt   int64                        `json:"create_at"`
+}
+
+type LearnerReportShareLinkPageReply struct {
+	Total int                           `json:"total"`
+	Data  []*LearnerReportShareLinkView `json:"data"`
+}
+
+// LearnerReportShareVisit the client opening a share link, every visit is audited
+type LearnerReportShareVisit struct {
+	IP        string
+	UserAgent string
+	Method    string
+	Path      string
+}
+
+// LearnerReportCondition rows of the student in the schedules of the class
+type LearnerReportCondition struct {
+	OrgID     string
+	ClassID   string
+	StudentID string
+	StartAt   int64
+	EndAt     int64
+	Limit     int
+}
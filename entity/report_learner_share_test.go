@@ -0,0 +1,93 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+func TestNewLearnerReportHighlights(t *testing.T) {
+	req := &LearnerReportRequest{ClassID: "class", StudentID: "student", StartAt: 1, EndAt: 2}
+	tests := []struct {
+		counts LearnerReportCounts
+		want   []string
+	}{
+		{
+			counts: LearnerReportCounts{},
+			want: []string{
+				"There were no classes in this period.",
+				"No assignments were given in this period.",
+				"No learning outcomes were assessed yet.",
+			},
+		},
+		{
+			counts: LearnerReportCounts{ClassesScheduled: 1, ClassesAttended: 1, AssignmentsAssigned: 3, AssignmentsDone: 3, OutcomesAssessed: 2},
+			want: []string{
+				"Joined every class, 1 class in total. Great attendance!",
+				"Finished every assignment, 3 assignments in total. Well done!",
+				"Is still working towards 2 learning outcomes.",
+			},
+		},
+		{
+			counts: LearnerReportCounts{ClassesScheduled: 5, ClassesAttended: 3, AssignmentsAssigned: 4, AssignmentsDone: 1, OutcomesAssessed: 6, OutcomesAchieved: 4},
+			want: []string{
+				"Joined 3 of 5 classes.",
+				"Finished 1 of 4 assignments, 3 still to do.",
+				"Achieved 4 of 6 learning outcomes.",
+			},
+		},
+		{
+			counts: LearnerReportCounts{ClassesScheduled: 2, AssignmentsAssigned: 1, OutcomesAssessed: 1, OutcomesAchieved: 1},
+			want: []string{
+				"Missed all 2 classes in this period.",
+				"Finished 0 of 1 assignment, 1 still to do.",
+				"Achieved 1 of 1 learning outcome.",
+			},
+		},
+	}
+	for i, test := range tests {
+		report := NewLearnerReport(req, &test.counts)
+		if !reflect.DeepEqual(report.Highlights, test.want) {
+			t.Errorf("case %d: want %v, got %v", i, test.want, report.Highlights)
+		}
+	}
+}
+
+func TestLearnerReportShareLink(t *testing.T) {
+	signature := LearnerReportShareLinkSignature("secret", "link", 100)
+	if signature != LearnerReportShareLinkSignature("secret", "link", 100) {
+		t.Error("signature is not stable")
+	}
+	if signature == LearnerReportShareLinkSignature("secret", "link", 101) ||
+		signature == LearnerReportShareLinkSignature("other", "link", 100) ||
+		signature == LearnerReportShareLinkSignature("secret", "other", 100) {
+		t.Error("signature does not cover the secret, id and expiry")
+	}
+
+	link := &LearnerReportShareLink{ExpireAt: 100}
+	if status := link.Status(99); status != LearnerReportShareLinkStatusActive {
+		t.Errorf("want active, got %s", status)
+	}
+	if status := link.Status(100); status != LearnerReportShareLinkStatusExpired {
+		t.Errorf("want expired, got %s", status)
+	}
+	link.RevokeAt = 50
+	if status := link.Status(99); status != LearnerReportShareLinkStatusRevoked {
+		t.Errorf("want revoked, got %s", status)
+	}
+
+	req := &LearnerReportShareLinkAddReq{LearnerReportRequest: LearnerReportRequest{ClassID: "class", StudentID: "student", StartAt: 1, EndAt: 2}}
+	if !req.Valid() || req.ExpireHours != constant.LearnerReportShareLinkDefaultExpireHours {
+		t.Errorf("want valid with the default expiry, %+v", req)
+	}
+	req.ExpireHours = constant.LearnerReportShareLinkMaxExpireHours + 1
+	if req.Valid() {
+		t.Error("expiry over the limit is accepted")
+	}
+	req.ExpireHours = 1
+	req.EndAt = req.StartAt + (constant.LearnerReportMaxDays+1)*constant.ReportRollupDay
+	if req.Valid() {
+		t.Error("time range over the limit is accepted")
+	}
+}
@@ -0,0 +1,242 @@
+package entity
+
+import (
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+type ReportRollupPeriod string
+
+const (
+	ReportRollupPeriodDaily  ReportRollupPeriod = "daily"
+	ReportRollupPeriodWeekly ReportRollupPeriod = "weekly"
+)
+
+func (p ReportRollupPeriod) Valid() bool {
+	return p == ReportRollupPeriodDaily || p == ReportRollupPeriodWeekly
+}
+
+func (p ReportRollupPeriod) TableName() string {
+	if p == ReportRollupPeriodWeekly {
+		return constant.TableNameReportRollupWeekly
+	}
+	return constant.TableNameReportRollupDaily
+}
+
+// Seconds length of the period
+func (p ReportRollupPeriod) Seconds() int64 {
+	if p == ReportRollupPeriodWeekly {
+		return 7 * constant.ReportRollupDay
+	}
+	return constant.ReportRollupDay
+}
+
+// Start of the period containing ts, days start at midnight of the time zone offset and weeks on mondays
+func (p ReportRollupPeriod) Start(ts int64, offset int) int64 {
+	day := floorDiv(ts+int64(offset), constant.ReportRollupDay)
+	if p == ReportRollupPeriodWeekly {
+		// 1970-01-01 was a thursday
+		day -= (day%7 + 7 + 3) % 7
+	}
+	return day*constant.ReportRollupDay - int64(offset)
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// ReportRollupPeriodOf the longest period the time range is aligned to, false if it isn't aligned to days
+func ReportRollupPeriodOf(start, end int64, offset int) (ReportRollupPeriod, bool) {
+	if start >= end {
+		return "", false
+	}
+	for _, period := range []ReportRollupPeriod{ReportRollupPeriodWeekly, ReportRollupPeriodDaily} {
+		if period.Start(start, offset) == start && period.Start(end, offset) == end {
+			return period, true
+		}
+	}
+	return "", false
+}
+
+// ReportRollup assessment users of the schedules in a period, grouped by school, class, subject, user and assessment type.
+// Classes are dated by end_at and studies by created_at like the live reports.
+// Rows with an empty school or subject count all schools or subjects,
+// a schedule of several schools or subjects is counted in the row of each of them.
+type ReportRollup struct {
+	Period ReportRollupPeriod `gorm:"-"`
+
+	OrgID          string `gorm:"column:org_id"`
+	PeriodStart    int64  `gorm:"column:period_start"`
+	SchoolID       string `gorm:"column:school_id"`
+	ClassID        string `gorm:"column:class_id"`
+	SubjectID      string `gorm:"column:subject_id"`
+	UserID         string `gorm:"column:user_id"`
+	UserType       string `gorm:"column:user_type"`
+	AssessmentType string `gorm:"column:assessment_type"`
+	Lessons        int    `gorm:"column:lessons"`
+	Attended       int    `gorm:"column:attended"`
+	Completed      int    `gorm:"column:completed"`
+	// Duration seconds of the lessons as scheduled
+	Duration int64 `gorm:"column:duration"`
+	UpdateAt int64 `gorm:"column:update_at"`
+}
+
+func (r ReportRollup) TableName() string {
+	return r.Period.TableName()
+}
+
+func (r ReportRollup) GetBatchInsertColsAndValues() (cols []string, values []interface{}) {
+	cols = []string{"org_id", "period_start", "school_id", "class_id", "subject_id", "user_id", "user_type", "assessment_type", "lessons", "attended", "completed", "duration", "update_at"}
+	values = []interface{}{r.OrgID, r.PeriodStart, r.SchoolID, r.ClassID, r.SubjectID, r.UserID, r.UserType, r.AssessmentType, r.Lessons, r.Attended, r.Completed, r.Duration, r.UpdateAt}
+	return
+}
+
+// ReportRollupFact an assessment user of a schedule, rollups are aggregated from them
+type ReportRollupFact struct {
+	ScheduleID     string `gorm:"column:schedule_id"`
+	AssessmentType string `gorm:"column:assessment_type"`
+	UserID         string `gorm:"column:user_id"`
+	UserType       string `gorm:"column:user_type"`
+	Duration       int64  `gorm:"column:duration"`
+	Attended       bool   `gorm:"column:attended"`
+	Completed      bool   `gorm:"column:completed"`
+}
+
+// ReportRollupDimensions relations of a schedule the rollups are grouped by
+type ReportRollupDimensions struct {
+	SchoolIDs  []string
+	ClassID    string
+	SubjectIDs []string
+}
+
+type reportRollupKey struct {
+	schoolID       string
+	classID        string
+	subjectID      string
+	userID         string
+	userType       string
+	assessmentType string
+}
+
+// AggregateReportRollups daily rollups of the facts of a day, dimensions are keyed by schedule id
+func AggregateReportRollups(orgID string, periodStart int64, facts []*ReportRollupFact, dimensions map[string]*ReportRollupDimensions, now int64) []*ReportRollup {
+	var rollups []*ReportRollup
+	index := make(map[reportRollupKey]*ReportRollup)
+	for _, fact := range facts {
+		dimension := dimensions[fact.ScheduleID]
+		if dimension == nil {
+			dimension = &ReportRollupDimensions{}
+		}
+
+		for _, schoolID := range withAllRollup(dimension.SchoolIDs) {
+			for _, subjectID := range withAllRollup(dimension.SubjectIDs) {
+				key := reportRollupKey{
+					schoolID:       schoolID,
+					classID:        dimension.ClassID,
+					subjectID:      subjectID,
+					userID:         fact.UserID,
+					userType:       fact.UserType,
+					assessmentType: fact.AssessmentType,
+				}
+				rollup, ok := index[key]
+				if !ok {
+					rollup = &ReportRollup{
+						Period:         ReportRollupPeriodDaily,
+						OrgID:          orgID,
+						PeriodStart:    periodStart,
+						SchoolID:       schoolID,
+						ClassID:        dimension.ClassID,
+						SubjectID:      subjectID,
+						UserID:         fact.UserID,
+						UserType:       fact.UserType,
+						AssessmentType: fact.AssessmentType,
+						UpdateAt:       now,
+					}
+					index[key] = rollup
+					rollups = append(rollups, rollup)
+				}
+
+				rollup.Lessons++
+				rollup.Duration += fact.Duration
+				if fact.Attended {
+					rollup.Attended++
+				}
+				if fact.Completed {
+					rollup.Completed++
+				}
+			}
+		}
+	}
+	return rollups
+}
+
+// withAllRollup the empty id of all followed by the distinct ids
+func withAllRollup(ids []string) []string {
+	result := []string{""}
+	seen := map[string]bool{"": true}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// ReportRollupCondition rollup rows summed by user and assessment type,
+// rows of all schools and subjects are read unless schools or subjects are given
+type ReportRollupCondition struct {
+	Period          ReportRollupPeriod
+	OrgID           string
+	StartAt         int64
+	EndAt           int64
+	SchoolIDs       NullStrings
+	ClassIDs        NullStrings
+	SubjectIDs      NullStrings
+	UserIDs         NullStrings
+	UserType        string
+	AssessmentTypes []string
+}
+
+type ReportRollupSummary struct {
+	UserID         string `gorm:"column:user_id"`
+	AssessmentType string `gorm:"column:assessment_type"`
+	Lessons        int    `gorm:"column:lessons"`
+	Attended       int    `gorm:"column:attended"`
+	Completed      int    `gorm:"column:completed"`
+	Duration       int64  `gorm:"column:duration"`
+}
+
+// ReportRollupDirty a day of an organization whose rollups are out of date
+type ReportRollupDirty struct {
+	OrgID       string `gorm:"column:org_id;PRIMARY_KEY"`
+	PeriodStart int64  `gorm:"column:period_start;PRIMARY_KEY"`
+	MarkAt      int64  `gorm:"column:mark_at"`
+}
+
+func (ReportRollupDirty) TableName() string {
+	return constant.TableNameReportRollupDirty
+}
+
+// ReportRollupState rollups of an organization are complete from CoverFrom on once it has been rebuilt,
+// later changes are kept up by the refresh worker
+type ReportRollupState struct {
+	OrgID     string `gorm:"column:org_id;PRIMARY_KEY"`
+	CoverFrom int64  `gorm:"column:cover_from"`
+	RebuildAt int64  `gorm:"column:rebuild_at"`
+	RefreshAt int64  `gorm:"column:refresh_at"`
+	CreateAt  int64  `gorm:"column:create_at"`
+	UpdateAt  int64  `gorm:"column:update_at"`
+}
+
+func (ReportRollupState) TableName() string {
+	return constant.TableNameReportRollupState
+}
+
+type ReportRollupRebuildResult struct {
+	OrgIDs []string `json:"org_ids"`
+	Days   int      `json:"days"`
+}
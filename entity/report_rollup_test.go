@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReportRollupPeriodStart(t *testing.T) {
+	// 2022-03-16 is a wednesday
+	kst := 9 * 60 * 60
+	ts := time.Date(2022, 3, 16, 1, 30, 0, 0, time.FixedZone("KST", kst)).Unix()
+
+	if got, want := ReportRollupPeriodDaily.Start(ts, kst), time.Date(2022, 3, 16, 0, 0, 0, 0, time.FixedZone("KST", kst)).Unix(); got != want {
+		t.Errorf("daily start = %d, want %d", got, want)
+	}
+	if got, want := ReportRollupPeriodWeekly.Start(ts, kst), time.Date(2022, 3, 14, 0, 0, 0, 0, time.FixedZone("KST", kst)).Unix(); got != want {
+		t.Errorf("weekly start = %d, want %d", got, want)
+	}
+	if got, want := ReportRollupPeriodDaily.Start(ts, 0), time.Date(2022, 3, 15, 0, 0, 0, 0, time.UTC).Unix(); got != want {
+		t.Errorf("utc daily start = %d, want %d", got, want)
+	}
+}
+
+func TestReportRollupPeriodOf(t *testing.T) {
+	monday := time.Date(2022, 3, 14, 0, 0, 0, 0, time.UTC).Unix()
+	day := int64(24 * 60 * 60)
+
+	tests := []struct {
+		start, end int64
+		period     ReportRollupPeriod
+		ok         bool
+	}{
+		{monday, monday + 14*day, ReportRollupPeriodWeekly, true},
+		{monday + day, monday + 3*day, ReportRollupPeriodDaily, true},
+		{monday, monday + 3*day, ReportRollupPeriodDaily, true},
+		{monday + 60, monday + day, "", false},
+		{monday, monday, "", false},
+	}
+	for _, tt := range tests {
+		period, ok := ReportRollupPeriodOf(tt.start, tt.end, 0)
+		if period != tt.period || ok != tt.ok {
+			t.Errorf("ReportRollupPeriodOf(%d, %d) = %s %v, want %s %v", tt.start, tt.end, period, ok, tt.period, tt.ok)
+		}
+	}
+}
+
+func TestAggregateReportRollups(t *testing.T) {
+	facts := []*ReportRollupFact{
+		{ScheduleID: "s1", AssessmentType: "OnlineClass", UserID: "u1", UserType: "Student", Duration: 1800, Attended: true},
+		{ScheduleID: "s2", AssessmentType: "OnlineClass", UserID: "u1", UserType: "Student", Duration: 600, Completed: true},
+		{ScheduleID: "s3", AssessmentType: "OnlineClass", UserID: "u1", UserType: "Student", Duration: 60},
+	}
+	dimensions := map[string]*ReportRollupDimensions{
+		"s1": {SchoolIDs: []string{"school1", "school1"}, ClassID: "c1", SubjectIDs: []string{"math", "art"}},
+		"s2": {SchoolIDs: []string{"school1"}, ClassID: "c1", SubjectIDs: []string{"math"}},
+	}
+
+	rollups := AggregateReportRollups("org1", 100, facts, dimensions, 200)
+	// s1 and s2: all schools and school1 by all subjects, math and art; s3 has no class
+	if len(rollups) != 7 {
+		t.Fatalf("got %d rollups, want 7", len(rollups))
+	}
+
+	all := rollups[0]
+	if all.SchoolID != "" || all.SubjectID != "" || all.ClassID != "c1" || all.Lessons != 2 || all.Attended != 1 || all.Completed != 1 || all.Duration != 2400 {
+		t.Errorf("unexpected rollup of all schools and subjects %+v", all)
+	}
+	for _, rollup := range rollups {
+		if rollup.SubjectID == "art" && rollup.Lessons != 1 {
+			t.Errorf("art has one lesson, got %+v", rollup)
+		}
+		if rollup.ClassID == "" && (rollup.SchoolID != "" || rollup.Lessons != 1) {
+			t.Errorf("unexpected rollup of the schedule without relations %+v", rollup)
+		}
+		if rollup.OrgID != "org1" || rollup.PeriodStart != 100 || rollup.UpdateAt != 200 || rollup.Period != ReportRollupPeriodDaily {
+			t.Errorf("unexpected rollup %+v", rollup)
+		}
+	}
+}
@@ -5,6 +5,8 @@ type AppInsightMessageRequest struct {
 	StudentID string `json:"student_id" form:"student_id"`
 	OrgID     string `json:"org_id"  form:"org_id"`
 	EndTime   int    `json:"end_time" form:"end_time"`
+	// Lang language of the messages, english when the rule has no message of it
+	Lang string `json:"lang" form:"lang"`
 }
 
 type AppInsightMessageResponse struct {
@@ -14,4 +16,6 @@ type AppInsightMessageResponse struct {
 	AttedanceLabelParams                 AttedanceLabelParams                 `json:"attedance_label_params"`
 	AssignmentLabelID                    string                               `json:"assignment_label_id"`
 	AssignmentLabelParams                AssignmentLabelParams                `json:"assignment_label_params"`
+	// Messages fired rules of the categories, the labels above are of the same rules
+	Messages []*InsightMessage `json:"messages"`
 }
@@ -40,6 +40,14 @@ type ClassAttendance struct {
 	IsAttendance bool   `gorm:"column:is_attendance" json:"is_attendance"`
 }
 
+// ClassAttendanceCount online classes of a student in a subject and how many of them were attended
+type ClassAttendanceCount struct {
+	StudentID string `gorm:"column:student_id"`
+	SubjectID string `gorm:"column:subject_id"`
+	Lessons   int    `gorm:"column:lessons"`
+	Attended  int    `gorm:"column:attended"`
+}
+
 type ClassAttendanceQueryParameters struct {
 	ClassID    string
 	SubjectIDS []string
@@ -0,0 +1,301 @@
+package entity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/mail"
+	"strings"
+	"time"
+	// subscriptions run in iana time zones, images without a zoneinfo database load them from the binary
+	_ "time/tzdata"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+type ReportSubscriptionReportType string
+
+const (
+	ReportSubscriptionLearnerWeeklyOverview ReportSubscriptionReportType = "learner_weekly_overview"
+	ReportSubscriptionTeacherLoadOverview   ReportSubscriptionReportType = "teacher_load_overview"
+	// ReportSubscriptionTeacherLoadLessons params are TeacherLoadLessonArgs, the duration is the period of the delivery
+	ReportSubscriptionTeacherLoadLessons ReportSubscriptionReportType = "teacher_load_lessons"
+	ReportSubscriptionTeacherLoadSummary ReportSubscriptionReportType = "teacher_load_lessons_summary"
+	// ReportSubscriptionLiveClassesSummary params are LearningSummaryFilter, the week range is the period of the delivery
+	ReportSubscriptionLiveClassesSummary ReportSubscriptionReportType = "learning_summary_live_classes"
+	ReportSubscriptionAssignmentsSummary ReportSubscriptionReportType = "learning_summary_assignments"
+)
+
+func (t ReportSubscriptionReportType) Valid() bool {
+	switch t {
+	case ReportSubscriptionLearnerWeeklyOverview, ReportSubscriptionTeacherLoadOverview,
+		ReportSubscriptionTeacherLoadLessons, ReportSubscriptionTeacherLoadSummary,
+		ReportSubscriptionLiveClassesSummary, ReportSubscriptionAssignmentsSummary:
+		return true
+	}
+	return false
+}
+
+type ReportSubscriptionCadence string
+
+const (
+	ReportSubscriptionDaily   ReportSubscriptionCadence = "daily"
+	ReportSubscriptionWeekly  ReportSubscriptionCadence = "weekly"
+	ReportSubscriptionMonthly ReportSubscriptionCadence = "monthly"
+)
+
+func (c ReportSubscriptionCadence) Valid() bool {
+	return c == ReportSubscriptionDaily || c == ReportSubscriptionWeekly || c == ReportSubscriptionMonthly
+}
+
+type ReportSubscriptionStatus string
+
+const (
+	ReportSubscriptionStatusActive ReportSubscriptionStatus = "Active"
+	ReportSubscriptionStatusPaused ReportSubscriptionStatus = "Paused"
+)
+
+// ReportSubscription a report run as the creator with their permissions and mailed to the recipients on a cadence
+type ReportSubscription struct {
+	ID         string                       `gorm:"column:id;PRIMARY_KEY"`
+	OrgID      string                       `gorm:"column:org_id"`
+	Name       string                       `gorm:"column:name"`
+	ReportType ReportSubscriptionReportType `gorm:"column:report_type"`
+	Params     string                       `gorm:"column:params"`
+	Format     ReportExportFormat           `gorm:"column:format"`
+	Recipients string                       `gorm:"column:recipients"`
+	Cadence    ReportSubscriptionCadence    `gorm:"column:cadence"`
+	// Weekday 0 is sunday, weekly subscriptions only
+	Weekday int `gorm:"column:weekday"`
+	// MonthDay 1 to 28, monthly subscriptions only
+	MonthDay  int                      `gorm:"column:month_day"`
+	Hour      int                      `gorm:"column:hour"`
+	TimeZone  string                   `gorm:"column:time_zone"`
+	Status    ReportSubscriptionStatus `gorm:"column:status"`
+	NextRunAt int64                    `gorm:"column:next_run_at;type:bigint"`
+	LastRunAt int64                    `gorm:"column:last_run_at;type:bigint"`
+	CreatorID string                   `gorm:"column:creator_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (ReportSubscription) TableName() string {
+	return constant.TableNameReportSubscription
+}
+
+func (s *ReportSubscription) GetRecipients() []string {
+	if s.Recipients == "" {
+		return []string{}
+	}
+	return strings.Split(s.Recipients, constant.StringArraySeparator)
+}
+
+func (s *ReportSubscription) SetRecipients(recipients []string) {
+	s.Recipients = strings.Join(recipients, constant.StringArraySeparator)
+}
+
+func (s *ReportSubscription) location() *time.Location {
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// NextRun the first run of the cadence strictly after the time, in the time zone of the subscription
+func (s *ReportSubscription) NextRun(after int64) int64 {
+	t := time.Unix(after, 0).In(s.location())
+	var next time.Time
+	switch s.Cadence {
+	case ReportSubscriptionWeekly:
+		days := (s.Weekday - int(t.Weekday()) + 7) % 7
+		next = time.Date(t.Year(), t.Month(), t.Day()+days, s.Hour, 0, 0, 0, t.Location())
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 7)
+		}
+	case ReportSubscriptionMonthly:
+		next = time.Date(t.Year(), t.Month(), s.MonthDay, s.Hour, 0, 0, 0, t.Location())
+		if !next.After(t) {
+			next = next.AddDate(0, 1, 0)
+		}
+	default:
+		next = time.Date(t.Year(), t.Month(), t.Day(), s.Hour, 0, 0, 0, t.Location())
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next.Unix()
+}
+
+// Period reported by the run, the day, 7 days or calendar month before the day of the run
+func (s *ReportSubscription) Period(runAt int64) (int64, int64) {
+	t := time.Unix(runAt, 0).In(s.location())
+	end := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch s.Cadence {
+	case ReportSubscriptionWeekly:
+		return end.AddDate(0, 0, -7).Unix(), end.Unix()
+	case ReportSubscriptionMonthly:
+		end = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		return end.AddDate(0, -1, 0).Unix(), end.Unix()
+	}
+	return end.AddDate(0, 0, -1).Unix(), end.Unix()
+}
+
+type ReportSubscriptionLinkAction string
+
+const (
+	// ReportSubscriptionLinkPause pause the subscription for every recipient, the creator resumes it
+	ReportSubscriptionLinkPause ReportSubscriptionLinkAction = "pause"
+	// ReportSubscriptionLinkUnsubscribe remove the recipient from the subscription
+	ReportSubscriptionLinkUnsubscribe ReportSubscriptionLinkAction = "unsubscribe"
+)
+
+func (a ReportSubscriptionLinkAction) Valid() bool {
+	return a == ReportSubscriptionLinkPause || a == ReportSubscriptionLinkUnsubscribe
+}
+
+// ReportSubscriptionLinkSignature hex(hmac_sha256(secret, "<id>.<recipient>.<action>")), links in the mails need no login
+func ReportSubscriptionLinkSignature(secret, id, recipient string, action ReportSubscriptionLinkAction) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id + "." + recipient + "." + string(action)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type ReportSubscriptionDeliveryStatus string
+
+const (
+	ReportSubscriptionDeliverySucceeded ReportSubscriptionDeliveryStatus = "Succeeded"
+	ReportSubscriptionDeliveryFailed    ReportSubscriptionDeliveryStatus = "Failed"
+)
+
+// ReportSubscriptionDelivery history of the runs of a subscription
+type ReportSubscriptionDelivery struct {
+	ID             string                           `gorm:"column:id;PRIMARY_KEY"`
+	SubscriptionID string                           `gorm:"column:subscription_id"`
+	OrgID          string                           `gorm:"column:org_id"`
+	Status         ReportSubscriptionDeliveryStatus `gorm:"column:status"`
+	PeriodStart    int64                            `gorm:"column:period_start;type:bigint"`
+	PeriodEnd      int64                            `gorm:"column:period_end;type:bigint"`
+	Recipients     string                           `gorm:"column:recipients"`
+	Rows           int                              `gorm:"column:rows"`
+	FileName       string                           `gorm:"column:file_name"`
+	Error          string                           `gorm:"column:error"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+}
+
+func (ReportSubscriptionDelivery) TableName() string {
+	return constant.TableNameReportSubscriptionDelivery
+}
+
+const reportSubscriptionMaxNameLength = 128
+
+type ReportSubscriptionAddReq struct {
+	Name       string                       `json:"name"`
+	ReportType ReportSubscriptionReportType `json:"report_type" enums:"learner_weekly_overview,teacher_load_overview,teacher_load_lessons,teacher_load_lessons_summary,learning_summary_live_classes,learning_summary_assignments"`
+	// Params filter of the report, TeacherLoadLessonArgs or LearningSummaryFilter, the time range is filled in by every run
+	Params     json.RawMessage           `json:"params" swaggertype:"object"`
+	Format     ReportExportFormat        `json:"format" enums:"csv,xlsx,pdf"`
+	Recipients []string                  `json:"recipients"`
+	Cadence    ReportSubscriptionCadence `json:"cadence" enums:"daily,weekly,monthly"`
+	Weekday    int                       `json:"weekday"`
+	MonthDay   int                       `json:"month_day"`
+	Hour       int                       `json:"hour"`
+	// TimeZone iana name like Asia/Seoul
+	TimeZone string `json:"time_zone"`
+}
+
+func (r *ReportSubscriptionAddReq) Valid() bool {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > reportSubscriptionMaxNameLength || !r.ReportType.Valid() {
+		return false
+	}
+
+	if r.Format == "" {
+		r.Format = ReportExportFormatCsv
+	}
+	if !r.Format.Valid() {
+		return false
+	}
+
+	if len(r.Recipients) <= 0 || len(r.Recipients) > constant.ReportSubscriptionMaxRecipients {
+		return false
+	}
+	for i, recipient := range r.Recipients {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil || strings.Contains(address.Address, constant.StringArraySeparator) {
+			return false
+		}
+		r.Recipients[i] = address.Address
+	}
+
+	switch r.Cadence {
+	case ReportSubscriptionDaily:
+	case ReportSubscriptionWeekly:
+		if r.Weekday < 0 || r.Weekday > 6 {
+			return false
+		}
+	case ReportSubscriptionMonthly:
+		if r.MonthDay < 1 || r.MonthDay > 28 {
+			return false
+		}
+	default:
+		return false
+	}
+	if r.Hour < 0 || r.Hour > 23 {
+		return false
+	}
+
+	_, err := time.LoadLocation(r.TimeZone)
+	return r.TimeZone != "" && err == nil
+}
+
+type ReportSubscriptionUpdateReq struct {
+	ID string `json:"-"`
+	ReportSubscriptionAddReq
+}
+
+type ReportSubscriptionView struct {
+	ID         string                       `json:"id"`
+	Name       string                       `json:"name"`
+	ReportType ReportSubscriptionReportType `json:"report_type"`
+	Params     json.RawMessage              `json:"params" swaggertype:"object"`
+	Format     ReportExportFormat           `json:"format"`
+	Recipients []string                     `json:"recipients"`
+	Cadence    ReportSubscriptionCadence    `json:"cadence"`
+	Weekday    int                          `json:"weekday"`
+	MonthDay   int                          `json:"month_day"`
+	Hour       int                          `json:"hour"`
+	TimeZone   string                       `json:"time_zone"`
+	Status     ReportSubscriptionStatus     `json:"status" enums:"Active,Paused"`
+	NextRunAt  int64                        `json:"next_run_at"`
+	LastRunAt  int64                        `json:"last_run_at"`
+	CreateAt   int64                        `json:"create_at"`
+	UpdateAt   int64                        `json:"update_at"`
+}
+
+type ReportSubscriptionDeliveryQueryReq struct {
+	SubscriptionID string `json:"-"`
+	PageIndex      int    `form:"page"`
+	PageSize       int    `form:"page_size"`
+}
+
+type ReportSubscriptionDeliveryView struct {
+	ID          string                           `json:"id"`
+	Status      ReportSubscriptionDeliveryStatus `json:"status" enums:"Succeeded,Failed"`
+	PeriodStart int64                            `json:"period_start"`
+	PeriodEnd   int64                            `json:"period_end"`
+	Recipients  []string                         `json:"recipients"`
+	Rows        int                              `json:"rows"`
+	FileName    string                           `json:"file_name"`
+	Error       string                           `json:"error"`
+	CreateAt    int64                            `json:"create_at"`
+}
+
+type ReportSubscriptionDeliveryPageReply struct {
+	Total int                               `json:"total"`
+	Data  []*ReportSubscriptionDeliveryView `json:"data"`
+}
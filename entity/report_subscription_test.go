@@ -0,0 +1,87 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReportSubscriptionNextRun(t *testing.T) {
+	seoul, err := time.LoadLocation("Asia/Seoul")
+	if err != nil {
+		t.Skip("time zone database is not available")
+	}
+	// 2022-03-16 is a wednesday
+	now := time.Date(2022, 3, 16, 10, 30, 0, 0, seoul).Unix()
+
+	tests := []struct {
+		subscription ReportSubscription
+		want         time.Time
+	}{
+		{ReportSubscription{Cadence: ReportSubscriptionDaily, Hour: 8, TimeZone: "Asia/Seoul"}, time.Date(2022, 3, 17, 8, 0, 0, 0, seoul)},
+		{ReportSubscription{Cadence: ReportSubscriptionDaily, Hour: 11, TimeZone: "Asia/Seoul"}, time.Date(2022, 3, 16, 11, 0, 0, 0, seoul)},
+		{ReportSubscription{Cadence: ReportSubscriptionWeekly, Weekday: 1, Hour: 7, TimeZone: "Asia/Seoul"}, time.Date(2022, 3, 21, 7, 0, 0, 0, seoul)},
+		{ReportSubscription{Cadence: ReportSubscriptionWeekly, Weekday: 3, Hour: 10, TimeZone: "Asia/Seoul"}, time.Date(2022, 3, 23, 10, 0, 0, 0, seoul)},
+		{ReportSubscription{Cadence: ReportSubscriptionMonthly, MonthDay: 16, Hour: 12, TimeZone: "Asia/Seoul"}, time.Date(2022, 3, 16, 12, 0, 0, 0, seoul)},
+		{ReportSubscription{Cadence: ReportSubscriptionMonthly, MonthDay: 1, Hour: 0, TimeZone: "Asia/Seoul"}, time.Date(2022, 4, 1, 0, 0, 0, 0, seoul)},
+	}
+	for _, tt := range tests {
+		if got := tt.subscription.NextRun(now); got != tt.want.Unix() {
+			t.Errorf("NextRun of %+v = %v, want %v", tt.subscription, time.Unix(got, 0).In(seoul), tt.want)
+		}
+	}
+}
+
+func TestReportSubscriptionPeriod(t *testing.T) {
+	runAt := time.Date(2022, 3, 21, 7, 0, 0, 0, time.UTC).Unix()
+
+	weekly := ReportSubscription{Cadence: ReportSubscriptionWeekly, TimeZone: "UTC"}
+	start, end := weekly.Period(runAt)
+	if start != time.Date(2022, 3, 14, 0, 0, 0, 0, time.UTC).Unix() || end != time.Date(2022, 3, 21, 0, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("weekly period %v - %v", time.Unix(start, 0).UTC(), time.Unix(end, 0).UTC())
+	}
+
+	monthly := ReportSubscription{Cadence: ReportSubscriptionMonthly, TimeZone: "UTC"}
+	start, end = monthly.Period(runAt)
+	if start != time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC).Unix() || end != time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("monthly period %v - %v", time.Unix(start, 0).UTC(), time.Unix(end, 0).UTC())
+	}
+}
+
+func TestReportSubscriptionAddReqValid(t *testing.T) {
+	req := &ReportSubscriptionAddReq{
+		Name:       " weekly load ",
+		ReportType: ReportSubscriptionTeacherLoadOverview,
+		Recipients: []string{"Principal <principal@example.com>"},
+		Cadence:    ReportSubscriptionWeekly,
+		Weekday:    1,
+		Hour:       7,
+		TimeZone:   "UTC",
+	}
+	if !req.Valid() {
+		t.Fatal("want valid")
+	}
+	if req.Name != "weekly load" || req.Format != ReportExportFormatCsv || req.Recipients[0] != "principal@example.com" {
+		t.Errorf("unexpected normalized request %+v", req)
+	}
+
+	req.Recipients = []string{"not an email"}
+	if req.Valid() {
+		t.Error("invalid recipient is accepted")
+	}
+
+	req.Recipients = []string{"principal@example.com"}
+	req.Weekday = 7
+	if req.Valid() {
+		t.Error("invalid weekday is accepted")
+	}
+}
+
+func TestReportSubscriptionLinkSignature(t *testing.T) {
+	sig := ReportSubscriptionLinkSignature("secret", "id", "a@example.com", ReportSubscriptionLinkPause)
+	if sig != ReportSubscriptionLinkSignature("secret", "id", "a@example.com", ReportSubscriptionLinkPause) {
+		t.Error("signature is not stable")
+	}
+	if sig == ReportSubscriptionLinkSignature("secret", "id", "a@example.com", ReportSubscriptionLinkUnsubscribe) {
+		t.Error("signature does not cover the action")
+	}
+}
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"math"
+	"time"
+)
+
+const maxRetryDelay = 6 * time.Hour
+
+// RetryDelay exponential backoff of background deliveries, backoff, 2*backoff, 4*backoff... capped to 6 hours
+func RetryDelay(backoff time.Duration, attempts int) time.Duration {
+	if attempts <= 0 {
+		return 0
+	}
+
+	delay := float64(backoff) * math.Pow(2, float64(attempts-1))
+	if delay > float64(maxRetryDelay) {
+		return maxRetryDelay
+	}
+
+	return time.Duration(delay)
+}
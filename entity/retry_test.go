@@ -0,0 +1,25 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{20, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := RetryDelay(30*time.Second, tt.attempts); got != tt.want {
+			t.Errorf("RetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
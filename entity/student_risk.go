@@ -0,0 +1,337 @@
+package entity
+
+import (
+	"fmt"
+	"math"
+	"net/mail"
+	"sort"
+	"strings"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+type StudentRiskSignalType string
+
+const (
+	// StudentRiskSignalAttendance attended live classes of all live classes, same as the class attendance report
+	StudentRiskSignalAttendance StudentRiskSignalType = "attendance"
+	// StudentRiskSignalAssignment finished study assignments of all study assignments, same as the assignment completion report
+	StudentRiskSignalAssignment StudentRiskSignalType = "assignment"
+	// StudentRiskSignalOutcome achieved outcomes of all assessed outcomes, same as the learning outcome achievement report
+	StudentRiskSignalOutcome StudentRiskSignalType = "outcome"
+)
+
+// StudentRiskSetting organizations without a setting are not evaluated
+type StudentRiskSetting struct {
+	OrgID   string `gorm:"column:org_id;PRIMARY_KEY"`
+	Enabled bool   `gorm:"column:enabled"`
+	// WindowDays the signals are rates of the rolling window before every evaluation
+	WindowDays       int     `gorm:"column:window_days"`
+	AttendanceWeight float64 `gorm:"column:attendance_weight"`
+	AssignmentWeight float64 `gorm:"column:assignment_weight"`
+	OutcomeWeight    float64 `gorm:"column:outcome_weight"`
+	// thresholds of the rates, a rate below its threshold is explained as a reason
+	AttendanceThreshold float64 `gorm:"column:attendance_threshold"`
+	AssignmentThreshold float64 `gorm:"column:assignment_threshold"`
+	OutcomeThreshold    float64 `gorm:"column:outcome_threshold"`
+	// RiskThreshold students scoring at least the threshold are at risk
+	RiskThreshold float64 `gorm:"column:risk_threshold"`
+	// MinSamples signals of fewer classes, assignments or outcomes are ignored
+	MinSamples int `gorm:"column:min_samples"`
+	// NotifyRecipients email addresses mailed when flags of a class change, comma separated
+	NotifyRecipients string `gorm:"column:notify_recipients"`
+	UpdaterID        string `gorm:"column:updater_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+}
+
+func (StudentRiskSetting) TableName() string {
+	return constant.TableNameStudentRiskSetting
+}
+
+// NewDefaultStudentRiskSetting disabled setting with the default weights and thresholds
+func NewDefaultStudentRiskSetting(orgID string) *StudentRiskSetting {
+	return &StudentRiskSetting{
+		OrgID:               orgID,
+		WindowDays:          constant.StudentRiskDefaultWindowDays,
+		AttendanceWeight:    0.4,
+		AssignmentWeight:    0.3,
+		OutcomeWeight:       0.3,
+		AttendanceThreshold: 0.8,
+		AssignmentThreshold: 0.7,
+		OutcomeThreshold:    0.6,
+		RiskThreshold:       0.35,
+		MinSamples:          constant.StudentRiskDefaultMinSamples,
+	}
+}
+
+func (s *StudentRiskSetting) GetNotifyRecipients() []string {
+	if s.NotifyRecipients == "" {
+		return []string{}
+	}
+	return strings.Split(s.NotifyRecipients, constant.StringArraySeparator)
+}
+
+// StudentRiskSignal rate of a signal over the window
+type StudentRiskSignal struct {
+	Rate    float64 `json:"rate"`
+	Samples int64   `json:"samples"`
+}
+
+type StudentRiskSignals struct {
+	Attendance *StudentRiskSignal `json:"attendance,omitempty"`
+	Assignment *StudentRiskSignal `json:"assignment,omitempty"`
+	Outcome    *StudentRiskSignal `json:"outcome,omitempty"`
+}
+
+// StudentRiskReason a signal explaining the flag
+type StudentRiskReason struct {
+	Signal    StudentRiskSignalType `json:"signal" enums:"attendance,assignment,outcome"`
+	Rate      float64               `json:"rate"`
+	Threshold float64               `json:"threshold"`
+	Samples   int64                 `json:"samples"`
+	// Contribution part of the score from the signal
+	Contribution float64 `json:"contribution"`
+	Message      string  `json:"message"`
+}
+
+type StudentRiskEvaluation struct {
+	AtRisk  bool                 `json:"at_risk"`
+	Score   float64              `json:"score"`
+	Reasons []*StudentRiskReason `json:"reasons"`
+}
+
+// Evaluate score = sum(weight * (1 - rate)) / sum(weight) of the signals with enough samples,
+// reasons are the signals below their thresholds, the largest contribution first
+func (s *StudentRiskSetting) Evaluate(signals *StudentRiskSignals) *StudentRiskEvaluation {
+	type weighted struct {
+		signal    StudentRiskSignalType
+		value     *StudentRiskSignal
+		weight    float64
+		threshold float64
+		noun      string
+	}
+	items := []weighted{
+		{StudentRiskSignalAttendance, signals.Attendance, s.AttendanceWeight, s.AttendanceThreshold, "live classes attended"},
+		{StudentRiskSignalAssignment, signals.Assignment, s.AssignmentWeight, s.AssignmentThreshold, "study assignments completed"},
+		{StudentRiskSignalOutcome, signals.Outcome, s.OutcomeWeight, s.OutcomeThreshold, "learning outcomes achieved"},
+	}
+
+	result := &StudentRiskEvaluation{Reasons: []*StudentRiskReason{}}
+	var totalWeight float64
+	for _, item := range items {
+		if item.value == nil || item.weight <= 0 || item.value.Samples < int64(s.MinSamples) {
+			continue
+		}
+		totalWeight += item.weight
+	}
+	if totalWeight <= 0 {
+		return result
+	}
+
+	var top *StudentRiskReason
+	topContribution := -1.0
+	for _, item := range items {
+		if item.value == nil || item.weight <= 0 || item.value.Samples < int64(s.MinSamples) {
+			continue
+		}
+		contribution := item.weight * (1 - item.value.Rate) / totalWeight
+		result.Score += contribution
+		reason := &StudentRiskReason{
+			Signal:       item.signal,
+			Rate:         item.value.Rate,
+			Threshold:    item.threshold,
+			Samples:      item.value.Samples,
+			Contribution: math.Round(contribution*1000) / 1000,
+			Message: fmt.Sprintf("%.0f%% of %d %s, expected at least %.0f%%",
+				item.value.Rate*100, item.value.Samples, item.noun, item.threshold*100),
+		}
+		if item.value.Rate < item.threshold {
+			result.Reasons = append(result.Reasons, reason)
+		}
+		if contribution > topContribution {
+			top, topContribution = reason, contribution
+		}
+	}
+
+	result.Score = math.Round(result.Score*1000) / 1000
+	result.AtRisk = result.Score >= s.RiskThreshold
+	if result.AtRisk && len(result.Reasons) == 0 {
+		result.Reasons = append(result.Reasons, top)
+	}
+	sort.SliceStable(result.Reasons, func(i, j int) bool {
+		return result.Reasons[i].Contribution > result.Reasons[j].Contribution
+	})
+	return result
+}
+
+// StudentRiskFlag the latest evaluation of a student in a class
+type StudentRiskFlag struct {
+	ID        string  `gorm:"column:id;PRIMARY_KEY"`
+	OrgID     string  `gorm:"column:org_id"`
+	ClassID   string  `gorm:"column:class_id"`
+	StudentID string  `gorm:"column:student_id"`
+	AtRisk    bool    `gorm:"column:at_risk"`
+	Score     float64 `gorm:"column:score"`
+	// Signals json of StudentRiskSignals
+	Signals string `gorm:"column:signals"`
+	// Reasons json of []*StudentRiskReason
+	Reasons     string `gorm:"column:reasons"`
+	WindowStart int64  `gorm:"column:window_start;type:bigint"`
+	WindowEnd   int64  `gorm:"column:window_end;type:bigint"`
+	// ChangedAt when the student became at risk or not at risk
+	ChangedAt int64 `gorm:"column:changed_at;type:bigint"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+}
+
+func (StudentRiskFlag) TableName() string {
+	return constant.TableNameStudentRiskFlag
+}
+
+// StudentRiskFlagHistory a change of the flag of a student in a class
+type StudentRiskFlagHistory struct {
+	ID          string  `gorm:"column:id;PRIMARY_KEY"`
+	OrgID       string  `gorm:"column:org_id"`
+	ClassID     string  `gorm:"column:class_id"`
+	StudentID   string  `gorm:"column:student_id"`
+	AtRisk      bool    `gorm:"column:at_risk"`
+	Score       float64 `gorm:"column:score"`
+	Signals     string  `gorm:"column:signals"`
+	Reasons     string  `gorm:"column:reasons"`
+	WindowStart int64   `gorm:"column:window_start;type:bigint"`
+	WindowEnd   int64   `gorm:"column:window_end;type:bigint"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+}
+
+func (StudentRiskFlagHistory) TableName() string {
+	return constant.TableNameStudentRiskFlagHistory
+}
+
+type StudentRiskSettingView struct {
+	Enabled             bool     `json:"enabled"`
+	WindowDays          int      `json:"window_days"`
+	AttendanceWeight    float64  `json:"attendance_weight"`
+	AssignmentWeight    float64  `json:"assignment_weight"`
+	OutcomeWeight       float64  `json:"outcome_weight"`
+	AttendanceThreshold float64  `json:"attendance_threshold"`
+	AssignmentThreshold float64  `json:"assignment_threshold"`
+	OutcomeThreshold    float64  `json:"outcome_threshold"`
+	RiskThreshold       float64  `json:"risk_threshold"`
+	MinSamples          int      `json:"min_samples"`
+	NotifyRecipients    []string `json:"notify_recipients"`
+	UpdateAt            int64    `json:"update_at"`
+}
+
+type StudentRiskSettingUpdateReq struct {
+	Enabled    bool `json:"enabled"`
+	WindowDays int  `json:"window_days"`
+	// weights are relative to each other, a zero weight turns the signal off
+	AttendanceWeight float64 `json:"attendance_weight"`
+	AssignmentWeight float64 `json:"assignment_weight"`
+	OutcomeWeight    float64 `json:"outcome_weight"`
+	// thresholds are rates from 0 to 1
+	AttendanceThreshold float64  `json:"attendance_threshold"`
+	AssignmentThreshold float64  `json:"assignment_threshold"`
+	OutcomeThreshold    float64  `json:"outcome_threshold"`
+	RiskThreshold       float64  `json:"risk_threshold"`
+	MinSamples          int      `json:"min_samples"`
+	NotifyRecipients    []string `json:"notify_recipients"`
+}
+
+func (r *StudentRiskSettingUpdateReq) Valid() bool {
+	if r.WindowDays < 1 || r.WindowDays > constant.StudentRiskMaxWindowDays || r.MinSamples < 1 {
+		return false
+	}
+
+	for _, weight := range []float64{r.AttendanceWeight, r.AssignmentWeight, r.OutcomeWeight} {
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return false
+		}
+	}
+	if r.AttendanceWeight+r.AssignmentWeight+r.OutcomeWeight <= 0 {
+		return false
+	}
+
+	for _, threshold := range []float64{r.AttendanceThreshold, r.AssignmentThreshold, r.OutcomeThreshold} {
+		if threshold < 0 || threshold > 1 {
+			return false
+		}
+	}
+	if r.RiskThreshold <= 0 || r.RiskThreshold > 1 {
+		return false
+	}
+
+	if len(r.NotifyRecipients) > constant.StudentRiskMaxNotifyRecipients {
+		return false
+	}
+	for i, recipient := range r.NotifyRecipients {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil || strings.Contains(address.Address, constant.StringArraySeparator) {
+			return false
+		}
+		r.NotifyRecipients[i] = address.Address
+	}
+
+	return true
+}
+
+type StudentRiskQueryReq struct {
+	ClassID string `form:"class_id"`
+	// AtRiskOnly students not at risk are left out
+	AtRiskOnly bool `form:"at_risk_only"`
+}
+
+type StudentRiskView struct {
+	StudentID   string               `json:"student_id"`
+	StudentName string               `json:"student_name"`
+	AtRisk      bool                 `json:"at_risk"`
+	Score       float64              `json:"score"`
+	Signals     *StudentRiskSignals  `json:"signals"`
+	Reasons     []*StudentRiskReason `json:"reasons"`
+	WindowStart int64                `json:"window_start"`
+	WindowEnd   int64                `json:"window_end"`
+	ChangedAt   int64                `json:"changed_at"`
+	EvaluatedAt int64                `json:"evaluated_at"`
+}
+
+type StudentRiskClassReply struct {
+	ClassID  string             `json:"class_id"`
+	Students []*StudentRiskView `json:"students"`
+}
+
+type StudentRiskHistoryQueryReq struct {
+	ClassID   string `form:"class_id"`
+	StudentID string `form:"student_id"`
+	PageIndex int    `form:"page"`
+	PageSize  int    `form:"page_size"`
+}
+
+type StudentRiskHistoryView struct {
+	ID          string               `json:"id"`
+	StudentID   string               `json:"student_id"`
+	AtRisk      bool                 `json:"at_risk"`
+	Score       float64              `json:"score"`
+	Signals     *StudentRiskSignals  `json:"signals"`
+	Reasons     []*StudentRiskReason `json:"reasons"`
+	WindowStart int64                `json:"window_start"`
+	WindowEnd   int64                `json:"window_end"`
+	CreateAt    int64                `json:"create_at"`
+}
+
+type StudentRiskHistoryPageReply struct {
+	Total int                       `json:"total"`
+	Data  []*StudentRiskHistoryView `json:"data"`
+}
+
+// WebhookStudentRiskData data of student.risk_changed
+type WebhookStudentRiskData struct {
+	ClassID   string               `json:"class_id"`
+	StudentID string               `json:"student_id"`
+	AtRisk    bool                 `json:"at_risk"`
+	Score     float64              `json:"score"`
+	Reasons   []*StudentRiskReason `json:"reasons"`
+}
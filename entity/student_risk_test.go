@@ -0,0 +1,76 @@
+package entity
+
+import "testing"
+
+func TestStudentRiskSettingEvaluate(t *testing.T) {
+	setting := NewDefaultStudentRiskSetting("org")
+
+	result := setting.Evaluate(&StudentRiskSignals{
+		Attendance: &StudentRiskSignal{Rate: 0.25, Samples: 8},
+		Assignment: &StudentRiskSignal{Rate: 0.5, Samples: 4},
+		Outcome:    &StudentRiskSignal{Rate: 0.9, Samples: 10},
+	})
+	// 0.4*0.75 + 0.3*0.5 + 0.3*0.1
+	if result.Score != 0.48 || !result.AtRisk {
+		t.Fatalf("unexpected evaluation %+v", result)
+	}
+	if len(result.Reasons) != 2 || result.Reasons[0].Signal != StudentRiskSignalAttendance || result.Reasons[1].Signal != StudentRiskSignalAssignment {
+		t.Errorf("unexpected reasons %+v %+v", result.Reasons[0], result.Reasons[len(result.Reasons)-1])
+	}
+
+	result = setting.Evaluate(&StudentRiskSignals{
+		Attendance: &StudentRiskSignal{Rate: 0.9, Samples: 10},
+		Assignment: &StudentRiskSignal{Rate: 0, Samples: 1},
+	})
+	// the assignment signal has too few samples
+	if result.Score != 0.1 || result.AtRisk || len(result.Reasons) != 0 {
+		t.Errorf("unexpected evaluation %+v", result)
+	}
+
+	result = setting.Evaluate(&StudentRiskSignals{})
+	if result.Score != 0 || result.AtRisk {
+		t.Errorf("no signal should not be at risk, %+v", result)
+	}
+}
+
+func TestStudentRiskSettingEvaluateExplainsTopSignal(t *testing.T) {
+	setting := NewDefaultStudentRiskSetting("org")
+	setting.RiskThreshold = 0.2
+	setting.AttendanceThreshold = 0.5
+	setting.AssignmentThreshold = 0.5
+	setting.OutcomeThreshold = 0.5
+
+	result := setting.Evaluate(&StudentRiskSignals{
+		Attendance: &StudentRiskSignal{Rate: 0.7, Samples: 10},
+		Assignment: &StudentRiskSignal{Rate: 0.8, Samples: 10},
+		Outcome:    &StudentRiskSignal{Rate: 0.8, Samples: 10},
+	})
+	if !result.AtRisk || len(result.Reasons) != 1 || result.Reasons[0].Signal != StudentRiskSignalAttendance {
+		t.Errorf("the largest contribution should explain the flag, %+v", result)
+	}
+}
+
+func TestStudentRiskSettingUpdateReqValid(t *testing.T) {
+	req := &StudentRiskSettingUpdateReq{
+		WindowDays:          28,
+		AttendanceWeight:    1,
+		AttendanceThreshold: 0.8,
+		RiskThreshold:       0.3,
+		MinSamples:          3,
+		NotifyRecipients:    []string{"Head <head@example.com>"},
+	}
+	if !req.Valid() || req.NotifyRecipients[0] != "head@example.com" {
+		t.Fatalf("want valid, %+v", req)
+	}
+
+	req.AttendanceWeight = 0
+	if req.Valid() {
+		t.Error("all zero weights are accepted")
+	}
+
+	req.AttendanceWeight = 1
+	req.RiskThreshold = 1.5
+	if req.Valid() {
+		t.Error("risk threshold above 1 is accepted")
+	}
+}
@@ -0,0 +1,109 @@
+package entity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// StudentUsageRecordBatchReq tokens signed like the token of a single event, one event in each of them
+type StudentUsageRecordBatchReq struct {
+	Tokens []string `json:"tokens"`
+}
+
+type StudentUsageIngestRejection struct {
+	// Index of the token in the request
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+}
+
+type StudentUsageIngestResult struct {
+	// Accepted records queued for writing, one record for each student of an event
+	Accepted int `json:"accepted"`
+	// Duplicated records repeated in the request, the records written before are skipped when writing
+	Duplicated int                            `json:"duplicated"`
+	Rejected   []*StudentUsageIngestRejection `json:"rejected"`
+}
+
+// StudentUsageIngestStats counters of the ingestion pipeline on the instance which handles the request, since it started
+type StudentUsageIngestStats struct {
+	Received   int64 `json:"received"`
+	Accepted   int64 `json:"accepted"`
+	Rejected   int64 `json:"rejected"`
+	Duplicated int64 `json:"duplicated"`
+	// Throttled records refused because the queue was full
+	Throttled int64 `json:"throttled"`
+	// Skipped records of rooms without lesson plans, which are not reported
+	Skipped int64 `json:"skipped"`
+	Written int64 `json:"written"`
+	Failed  int64 `json:"failed"`
+	// DeadLettered failed records kept for the replay tool
+	DeadLettered int64 `json:"dead_lettered"`
+	Batches      int64 `json:"batches"`
+
+	QueueLength      int   `json:"queue_length"`
+	QueueCapacity    int   `json:"queue_capacity"`
+	LastFlushAt      int64 `json:"last_flush_at"`
+	LastFlushMillis  int64 `json:"last_flush_millis"`
+	LastFlushRecords int64 `json:"last_flush_records"`
+}
+
+// IngestInvalidReason the reason a record is rejected, empty when it is valid
+func (r *StudentUsageRecord) IngestInvalidReason() string {
+	switch {
+	case r.RoomID == "":
+		return "room_id is required"
+	case strings.TrimSpace(r.LessonMaterialUrl) == "":
+		return "lesson_material_url is required"
+	case r.Timestamp <= 0:
+		return "timestamp is required"
+	case len(r.Students) == 0:
+		return "students is required"
+	}
+	for _, student := range r.Students {
+		if student == nil || student.UserID == "" {
+			return "user_id of students is required"
+		}
+	}
+	return ""
+}
+
+// SplitByStudent a record for each student of the event, like the records written by a single event
+func (r *StudentUsageRecord) SplitByStudent() []*StudentUsageRecord {
+	records := make([]*StudentUsageRecord, 0, len(r.Students))
+	for _, student := range r.Students {
+		record := *r
+		record.Students = nil
+		record.StudentUserID = student.UserID
+		record.StudentName = student.Name
+		record.StudentEmail = student.Email
+		records = append(records, &record)
+	}
+	return records
+}
+
+// ReplayLine the record of a student as a line of cmd/student_usage_replay, an event of the student alone
+func (r *StudentUsageRecord) ReplayLine() ([]byte, error) {
+	return json.Marshal(&StudentUsageRecord{
+		ClassType:         r.ClassType,
+		RoomID:            r.RoomID,
+		LessonMaterialUrl: r.LessonMaterialUrl,
+		ContentType:       r.ContentType,
+		ActionType:        r.ActionType,
+		Timestamp:         r.Timestamp,
+		Students:          []*Student{{UserID: r.StudentUserID, Email: r.StudentEmail, Name: r.StudentName}},
+	})
+}
+
+// IngestKey records of the same room, material, student and timestamp are duplicates
+func (r *StudentUsageRecord) IngestKey() string {
+	hash := sha256.Sum256([]byte(strings.Join([]string{
+		r.RoomID,
+		strings.TrimSpace(r.LessonMaterialUrl),
+		r.StudentUserID,
+		strconv.FormatInt(r.Timestamp, 10),
+	}, "\n")))
+	return hex.EncodeToString(hash[:])
+}
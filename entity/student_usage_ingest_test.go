@@ -0,0 +1,87 @@
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStudentUsageRecordIngest(t *testing.T) {
+	valid := func() *StudentUsageRecord {
+		return &StudentUsageRecord{
+			RoomID:            "room",
+			LessonMaterialUrl: "assets/1.mp4",
+			Timestamp:         100,
+			Students:          []*Student{{UserID: "s1", Name: "one"}, {UserID: "s2", Email: "two@example.com"}},
+		}
+	}
+	if reason := valid().IngestInvalidReason(); reason != "" {
+		t.Fatalf("valid record is rejected: %s", reason)
+	}
+
+	tests := []func(r *StudentUsageRecord){
+		func(r *StudentUsageRecord) { r.RoomID = "" },
+		func(r *StudentUsageRecord) { r.LessonMaterialUrl = " " },
+		func(r *StudentUsageRecord) { r.Timestamp = 0 },
+		func(r *StudentUsageRecord) { r.Students = nil },
+		func(r *StudentUsageRecord) { r.Students[1].UserID = "" },
+		func(r *StudentUsageRecord) { r.Students[0] = nil },
+	}
+	for i, change := range tests {
+		record := valid()
+		change(record)
+		if record.IngestInvalidReason() == "" {
+			t.Errorf("case %d: invalid record is accepted", i)
+		}
+	}
+
+	records := valid().SplitByStudent()
+	if len(records) != 2 || records[0].StudentUserID != "s1" || records[0].StudentName != "one" ||
+		records[1].StudentUserID != "s2" || records[1].StudentEmail != "two@example.com" || records[1].Students != nil {
+		t.Fatalf("unexpected records %+v %+v", records[0], records[1])
+	}
+
+	if records[0].IngestKey() == records[1].IngestKey() {
+		t.Error("records of different students have the same key")
+	}
+	again := valid().SplitByStudent()[0]
+	again.LessonMaterialUrl = " assets/1.mp4 "
+	again.ActionType = "view"
+	if again.IngestKey() != records[0].IngestKey() {
+		t.Error("duplicated records have different keys")
+	}
+	again.Timestamp++
+	if again.IngestKey() == records[0].IngestKey() {
+		t.Error("records of different timestamps have the same key")
+	}
+}
+
+func TestStudentUsageRecordReplayLine(t *testing.T) {
+	record := (&StudentUsageRecord{
+		RoomID:            "room",
+		LessonMaterialUrl: "assets/1.mp4",
+		Timestamp:         100,
+		Students:          []*Student{{UserID: "s1", Name: "one"}},
+	}).SplitByStudent()[0]
+	// filled by the ingest before the write fails
+	record.ID = "id"
+	record.ClassID = "class"
+
+	line, err := record.ReplayLine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := StudentUsageRecord{}
+	if err := json.Unmarshal(line, &replayed); err != nil {
+		t.Fatal(err)
+	}
+	if reason := replayed.IngestInvalidReason(); reason != "" {
+		t.Fatalf("replayed record is rejected: %s", reason)
+	}
+	if replayed.ID != "" || replayed.ClassID != "" {
+		t.Errorf("fields filled by the ingest should not be replayed, got %+v", replayed)
+	}
+	records := replayed.SplitByStudent()
+	if len(records) != 1 || records[0].IngestKey() != record.IngestKey() || records[0].StudentName != "one" {
+		t.Errorf("replayed record should be the same record, got %+v", records[0])
+	}
+}
@@ -123,30 +123,33 @@ type AssessmentTeacher struct {
 	ID string `json:"id"`
 }
 type AssessmentDetailReply struct {
-	ID                string           `json:"id"`
-	Title             string           `json:"title"`
-	AssessmentType    AssessmentType   `json:"assessment_type"`
-	Status            AssessmentStatus `json:"status"`
-	RoomID            string           `json:"room_id"`
-	Class             *entity.IDName   `json:"class"`
-	TeacherIDs        []string         `json:"teacher_ids"`
-	Program           *entity.IDName   `json:"program"`
-	Subjects          []*entity.IDName `json:"subjects"`
-	ClassEndAt        int64            `json:"class_end_at"`
-	ClassLength       int              `json:"class_length"`
-	RemainingTime     int64            `json:"remaining_time"`
-	CompleteAt        int64            `json:"complete_at"`
-	ScheduleTitle     string           `json:"schedule_title"`
-	ScheduleDueAt     int64            `json:"schedule_due_at"`
-	CompleteRate      float64          `json:"complete_rate"`
-	IsAnyOneAttempted bool             `json:"is_anyone_attempted"`
-	Description       string           `json:"description"`
+	ID                string               `json:"id"`
+	Title             string               `json:"title"`
+	AssessmentType    AssessmentType       `json:"assessment_type"`
+	Status            AssessmentStatus     `json:"status"`
+	RoomID            string               `json:"room_id"`
+	Class             *entity.IDName       `json:"class"`
+	TeacherIDs        []string             `json:"teacher_ids"`
+	Program           *entity.IDName       `json:"program"`
+	Subjects          []*entity.IDName     `json:"subjects"`
+	ClassEndAt        int64                `json:"class_end_at"`
+	ClassLength       int                  `json:"class_length"`
+	RemainingTime     int64                `json:"remaining_time"`
+	CompleteAt        int64                `json:"complete_at"`
+	CompleteBy        AssessmentCompleteBy `json:"complete_by" enums:"Teacher,System"`
+	ScheduleTitle     string               `json:"schedule_title"`
+	ScheduleDueAt     int64                `json:"schedule_due_at"`
+	CompleteRate      float64              `json:"complete_rate"`
+	IsAnyOneAttempted bool                 `json:"is_anyone_attempted"`
+	Description       string               `json:"description"`
 
 	Outcomes []*AssessmentOutcomeReply `json:"outcomes"`
 	Contents []*AssessmentContentReply `json:"contents"`
 	Students []*AssessmentStudentReply `json:"students"`
 
 	DiffContentStudents []*AssessmentDiffContentStudentsReply `json:"diff_content_students,omitempty"`
+
+	Rubric *AssessmentRubricReply `json:"rubric,omitempty"`
 }
 
 type AssessmentDiffContentStudentsReply struct {
@@ -185,6 +188,7 @@ type AssessmentStudentReply struct {
 	ProcessStatus   AssessmentUserSystemStatus      `json:"process_status"`
 	ReviewerComment string                          `json:"reviewer_comment"`
 	Results         []*AssessmentStudentResultReply `json:"results"`
+	RubricResult    *AssessmentStudentRubricReply   `json:"rubric_result,omitempty"`
 	//OfflineStudyResult *StudentOfflineStudyResult      `json:"offline_study_result,omitempty"`
 }
 
@@ -255,6 +259,8 @@ type AssessmentStudentUpdateReq struct {
 	Status          AssessmentUserStatus          `json:"status"  enums:"Participate,NotParticipate"`
 	ReviewerComment string                        `json:"reviewer_comment"`
 	Results         []*AssessmentStudentResultReq `json:"results"`
+	// scores of the rubric attached to the lesson plan or schedule, one level per criterion
+	RubricScores []*AssessmentStudentRubricScoreReq `json:"rubric_scores"`
 }
 
 type FeedbackAssignmentsReq struct {
@@ -0,0 +1,195 @@
+package v2
+
+import (
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+type AssessmentCompleteBy string
+
+const (
+	AssessmentCompleteByTeacher AssessmentCompleteBy = "Teacher"
+	// AssessmentCompleteBySystem completed by an auto complete rule, teachers can reopen it
+	AssessmentCompleteBySystem AssessmentCompleteBy = "System"
+)
+
+// AssessmentAutoCompleteRule an assessment is completed by the system when any rule of its org matches
+type AssessmentAutoCompleteRule struct {
+	ID             string                        `gorm:"column:id;PRIMARY_KEY"`
+	OrgID          string                        `gorm:"org_id"`
+	AssessmentType AssessmentType                `gorm:"assessment_type"`
+	Trigger        AssessmentAutoCompleteTrigger `gorm:"trigger_type"`
+	Days           int                           `gorm:"days"`
+	CompletionRate float64                       `gorm:"completion_rate"`
+	CreatorID      string                        `gorm:"creator_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (AssessmentAutoCompleteRule) TableName() string {
+	return constant.TableNameAssessmentAutoCompleteRuleV2
+}
+
+type AssessmentAutoCompleteTrigger string
+
+const (
+	// AssessmentAutoCompleteTriggerDaysAfterClassEnd online class only
+	AssessmentAutoCompleteTriggerDaysAfterClassEnd AssessmentAutoCompleteTrigger = "DaysAfterClassEnd"
+	// AssessmentAutoCompleteTriggerDaysAfterDue online study only
+	AssessmentAutoCompleteTriggerDaysAfterDue         AssessmentAutoCompleteTrigger = "DaysAfterDue"
+	AssessmentAutoCompleteTriggerAllStudentsAttempted AssessmentAutoCompleteTrigger = "AllStudentsAttempted"
+	AssessmentAutoCompleteTriggerCompletionRate       AssessmentAutoCompleteTrigger = "CompletionRate"
+)
+
+const (
+	assessmentAutoCompleteMaxDays = 365
+	assessmentAutoCompleteDay     = 24 * 60 * 60
+)
+
+// NeedDetail the rule can only be judged on H5P results of the assessment
+func (r *AssessmentAutoCompleteRule) NeedDetail() bool {
+	return r.Trigger == AssessmentAutoCompleteTriggerAllStudentsAttempted ||
+		r.Trigger == AssessmentAutoCompleteTriggerCompletionRate
+}
+
+// AssessmentAutoCompleteInput Detail is nil until a rule needs it
+type AssessmentAutoCompleteInput struct {
+	AssessmentType AssessmentType
+	ClassEndAt     int64
+	DueAt          int64
+	ReopenedAt     int64
+	Detail         *AssessmentDetailReply
+}
+
+func (r *AssessmentAutoCompleteRule) Match(input *AssessmentAutoCompleteInput, now int64) bool {
+	// teachers complete what they reopened themselves
+	if r.AssessmentType != input.AssessmentType || input.ReopenedAt > 0 {
+		return false
+	}
+
+	switch r.Trigger {
+	case AssessmentAutoCompleteTriggerDaysAfterClassEnd:
+		return input.ClassEndAt > 0 && now >= input.ClassEndAt+int64(r.Days)*assessmentAutoCompleteDay
+	case AssessmentAutoCompleteTriggerDaysAfterDue:
+		return input.DueAt > 0 && now >= input.DueAt+int64(r.Days)*assessmentAutoCompleteDay
+	case AssessmentAutoCompleteTriggerAllStudentsAttempted:
+		return input.Detail != nil && assessmentAllStudentsAttempted(input.Detail)
+	case AssessmentAutoCompleteTriggerCompletionRate:
+		return input.Detail != nil && input.Detail.CompleteRate >= r.CompletionRate
+	}
+
+	return false
+}
+
+// assessmentAllStudentsAttempted every participating student has H5P results, false when nobody participates
+func assessmentAllStudentsAttempted(detail *AssessmentDetailReply) bool {
+	participants := 0
+	for _, student := range detail.Students {
+		if student.Status == AssessmentUserStatusNotParticipate {
+			continue
+		}
+		participants++
+
+		attempted := false
+		for _, item := range student.Results {
+			if item.Attempted {
+				attempted = true
+				break
+			}
+		}
+		if !attempted {
+			return false
+		}
+	}
+
+	return participants > 0
+}
+
+// NewAssessmentAutoCompleteUpdateReq complete the assessment with what the teacher would see,
+// outcome statuses of an unsaved assessment are already derived from score thresholds in the detail
+func NewAssessmentAutoCompleteUpdateReq(detail *AssessmentDetailReply) *AssessmentUpdateReq {
+	result := &AssessmentUpdateReq{
+		ID:       detail.ID,
+		Action:   AssessmentActionComplete,
+		Students: make([]*AssessmentStudentUpdateReq, 0, len(detail.Students)),
+		Contents: make([]*AssessmentUpdateContentReq, 0, len(detail.Contents)),
+	}
+
+	for _, item := range detail.Contents {
+		result.Contents = append(result.Contents, &AssessmentUpdateContentReq{
+			ParentID:        item.ParentID,
+			ContentID:       item.ContentID,
+			ReviewerComment: item.ReviewerComment,
+			Status:          item.Status,
+		})
+	}
+
+	for _, student := range detail.Students {
+		studentReq := &AssessmentStudentUpdateReq{
+			StudentID:       student.StudentID,
+			Status:          student.Status,
+			ReviewerComment: student.ReviewerComment,
+			Results:         make([]*AssessmentStudentResultReq, 0, len(student.Results)),
+		}
+		for _, resultItem := range student.Results {
+			resultReq := &AssessmentStudentResultReq{
+				ContentID: resultItem.ContentID,
+				Score:     resultItem.Score,
+				Outcomes:  make([]*AssessmentStudentResultOutcomeReq, 0, len(resultItem.Outcomes)),
+			}
+			for _, outcome := range resultItem.Outcomes {
+				resultReq.Outcomes = append(resultReq.Outcomes, &AssessmentStudentResultOutcomeReq{
+					OutcomeID: outcome.OutcomeID,
+					Status:    outcome.Status,
+				})
+			}
+			studentReq.Results = append(studentReq.Results, resultReq)
+		}
+		result.Students = append(result.Students, studentReq)
+	}
+
+	return result
+}
+
+type AssessmentAutoCompleteRuleAddReq struct {
+	AssessmentType AssessmentType                `json:"assessment_type" enums:"OnlineClass,OnlineStudy"`
+	Trigger        AssessmentAutoCompleteTrigger `json:"trigger" enums:"DaysAfterClassEnd,DaysAfterDue,AllStudentsAttempted,CompletionRate"`
+	Days           int                           `json:"days"`
+	CompletionRate float64                       `json:"completion_rate"`
+}
+
+func (r *AssessmentAutoCompleteRuleAddReq) Valid() bool {
+	switch r.AssessmentType {
+	case AssessmentTypeOnlineClass, AssessmentTypeOnlineStudy:
+	default:
+		return false
+	}
+
+	switch r.Trigger {
+	case AssessmentAutoCompleteTriggerDaysAfterClassEnd:
+		return r.AssessmentType == AssessmentTypeOnlineClass && r.Days >= 0 && r.Days <= assessmentAutoCompleteMaxDays
+	case AssessmentAutoCompleteTriggerDaysAfterDue:
+		return r.AssessmentType == AssessmentTypeOnlineStudy && r.Days >= 0 && r.Days <= assessmentAutoCompleteMaxDays
+	case AssessmentAutoCompleteTriggerAllStudentsAttempted:
+		return true
+	case AssessmentAutoCompleteTriggerCompletionRate:
+		return r.CompletionRate > 0 && r.CompletionRate <= 1
+	}
+
+	return false
+}
+
+type AssessmentAutoCompleteRuleUpdateReq struct {
+	ID string `json:"-"`
+	AssessmentAutoCompleteRuleAddReq
+}
+
+type AssessmentAutoCompleteRuleReply struct {
+	ID             string                        `json:"id"`
+	AssessmentType AssessmentType                `json:"assessment_type"`
+	Trigger        AssessmentAutoCompleteTrigger `json:"trigger"`
+	Days           int                           `json:"days"`
+	CompletionRate float64                       `json:"completion_rate"`
+	UpdateAt       int64                         `json:"update_at"`
+}
@@ -0,0 +1,177 @@
+package v2
+
+import (
+	"testing"
+)
+
+func TestAssessmentAutoCompleteRuleMatch(t *testing.T) {
+	const now = int64(10 * assessmentAutoCompleteDay)
+
+	attempted := &AssessmentDetailReply{
+		CompleteRate: 0.5,
+		Students: []*AssessmentStudentReply{
+			{StudentID: "student-1", Status: AssessmentUserStatusParticipate, Results: []*AssessmentStudentResultReply{{ContentID: "h5p-1", Attempted: true}}},
+			{StudentID: "student-2", Status: AssessmentUserStatusNotParticipate},
+		},
+	}
+	notAttempted := &AssessmentDetailReply{
+		CompleteRate: 0.5,
+		Students: []*AssessmentStudentReply{
+			{StudentID: "student-1", Status: AssessmentUserStatusParticipate, Results: []*AssessmentStudentResultReply{{ContentID: "h5p-1", Attempted: true}}},
+			{StudentID: "student-2", Status: AssessmentUserStatusParticipate, Results: []*AssessmentStudentResultReply{{ContentID: "h5p-1"}}},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		rule  *AssessmentAutoCompleteRule
+		input *AssessmentAutoCompleteInput
+		want  bool
+	}{
+		{
+			name:  "days after class end reached",
+			rule:  &AssessmentAutoCompleteRule{AssessmentType: AssessmentTypeOnlineClass, Trigger: AssessmentAutoCompleteTriggerDaysAfterClassEnd, Days: 3},
+			input: &AssessmentAutoCompleteInput{AssessmentType: AssessmentTypeOnlineClass, ClassEndAt: now - 3*assessmentAutoCompleteDay},
+			want:  true,
+		},
+		{
+			name:  "days after class end not reached",
+			rule:  &AssessmentAutoCompleteRule{AssessmentType: AssessmentTypeOnlineClass, Trigger: AssessmentAutoCompleteTriggerDaysAfterClassEnd, Days: 3},
+			input: &AssessmentAutoCompleteInput{AssessmentType: AssessmentTypeOnlineClass, ClassEndAt: now - 2*assessmentAutoCompleteDay},
+			want:  false,
+		},
+		{
+			name:  "class not ended",
+			rule:  &AssessmentAutoCompleteRule{AssessmentType: AssessmentTypeOnlineClass, Trigger: AssessmentAutoCompleteTriggerDaysAfterClassEnd},
+			input: &AssessmentAutoCompleteInput{AssessmentType: AssessmentTypeOnlineClass},
+			want:  false,
+		},
+		{
+			name:  "other assessment type",
+			rule:  &AssessmentAutoCompleteRule{AssessmentType: AssessmentTypeOnlineClass, Trigger: AssessmentAutoCompleteTriggerDaysAfterClassEnd},
+			input: &AssessmentAutoCompleteInput{AssessmentType: AssessmentTypeOnlineStudy, ClassEndAt: 1},
+			want:  false,
+		},
+		{
+			name:  "days after due reached",
+			rule:  &AssessmentAutoCompleteRule{AssessmentType: AssessmentTypeOnlineStudy, Trigger: AssessmentAutoCompleteTriggerDaysAfterDue, Days: 1},
+			input: &AssessmentAutoCompleteInput{AssessmentType: AssessmentTypeOnlineStudy, DueAt: now - assessmentAutoCompleteDay},
+			want:  true,
+		},
+		{
+			name:  "reopened after completed by system",
+			rule:  &AssessmentAutoCompleteRule{AssessmentType: AssessmentTypeOnlineClass, Trigger: AssessmentAutoCompleteTriggerDaysAfterClassEnd, Days: 3},
+			input: &AssessmentAutoCompleteInput{AssessmentType: AssessmentTypeOnlineClass, ClassEndAt: now - 3*assessmentAutoCompleteDay, ReopenedAt: now - 1},
+			want:  false,
+		},
+		{
+			name:  "no due date",
+			rule:  &AssessmentAutoCompleteRule{AssessmentType: AssessmentTypeOnlineStudy, Trigger: AssessmentAutoCompleteTriggerDaysAfterDue},
+			input: &AssessmentAutoCompleteInput{AssessmentType: AssessmentTypeOnlineStudy},
+			want:  false,
+		},
+		{
+			name:  "all participants attempted",
+			rule:  &AssessmentAutoCompleteRule{AssessmentType: AssessmentTypeOnlineClass, Trigger: AssessmentAutoCompleteTriggerAllStudentsAttempted},
+			input: &AssessmentAutoCompleteInput{AssessmentType: AssessmentTypeOnlineClass, Detail: attempted},
+			want:  true,
+		},
+		{
+			name:  "some participants not attempted",
+			rule:  &AssessmentAutoCompleteRule{AssessmentType: AssessmentTypeOnlineClass, Trigger: AssessmentAutoCompleteTriggerAllStudentsAttempted},
+			input: &AssessmentAutoCompleteInput{AssessmentType: AssessmentTypeOnlineClass, Detail: notAttempted},
+			want:  false,
+		},
+		{
+			name:  "detail not loaded",
+			rule:  &AssessmentAutoCompleteRule{AssessmentType: AssessmentTypeOnlineClass, Trigger: AssessmentAutoCompleteTriggerAllStudentsAttempted},
+			input: &AssessmentAutoCompleteInput{AssessmentType: AssessmentTypeOnlineClass},
+			want:  false,
+		},
+		{
+			name:  "completion rate reached",
+			rule:  &AssessmentAutoCompleteRule{AssessmentType: AssessmentTypeOnlineStudy, Trigger: AssessmentAutoCompleteTriggerCompletionRate, CompletionRate: 0.5},
+			input: &AssessmentAutoCompleteInput{AssessmentType: AssessmentTypeOnlineStudy, Detail: notAttempted},
+			want:  true,
+		},
+		{
+			name:  "completion rate not reached",
+			rule:  &AssessmentAutoCompleteRule{AssessmentType: AssessmentTypeOnlineStudy, Trigger: AssessmentAutoCompleteTriggerCompletionRate, CompletionRate: 0.8},
+			input: &AssessmentAutoCompleteInput{AssessmentType: AssessmentTypeOnlineStudy, Detail: notAttempted},
+			want:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Match(tt.input, now); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssessmentAutoCompleteRuleAddReqValid(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *AssessmentAutoCompleteRuleAddReq
+		want bool
+	}{
+		{"class end for online class", &AssessmentAutoCompleteRuleAddReq{AssessmentType: AssessmentTypeOnlineClass, Trigger: AssessmentAutoCompleteTriggerDaysAfterClassEnd, Days: 7}, true},
+		{"class end for online study", &AssessmentAutoCompleteRuleAddReq{AssessmentType: AssessmentTypeOnlineStudy, Trigger: AssessmentAutoCompleteTriggerDaysAfterClassEnd, Days: 7}, false},
+		{"due for online study", &AssessmentAutoCompleteRuleAddReq{AssessmentType: AssessmentTypeOnlineStudy, Trigger: AssessmentAutoCompleteTriggerDaysAfterDue, Days: 0}, true},
+		{"negative days", &AssessmentAutoCompleteRuleAddReq{AssessmentType: AssessmentTypeOnlineStudy, Trigger: AssessmentAutoCompleteTriggerDaysAfterDue, Days: -1}, false},
+		{"too many days", &AssessmentAutoCompleteRuleAddReq{AssessmentType: AssessmentTypeOnlineStudy, Trigger: AssessmentAutoCompleteTriggerDaysAfterDue, Days: 366}, false},
+		{"completion rate", &AssessmentAutoCompleteRuleAddReq{AssessmentType: AssessmentTypeOnlineClass, Trigger: AssessmentAutoCompleteTriggerCompletionRate, CompletionRate: 1}, true},
+		{"zero completion rate", &AssessmentAutoCompleteRuleAddReq{AssessmentType: AssessmentTypeOnlineClass, Trigger: AssessmentAutoCompleteTriggerCompletionRate}, false},
+		{"offline class", &AssessmentAutoCompleteRuleAddReq{AssessmentType: AssessmentTypeOfflineClass, Trigger: AssessmentAutoCompleteTriggerAllStudentsAttempted}, false},
+		{"unknown trigger", &AssessmentAutoCompleteRuleAddReq{AssessmentType: AssessmentTypeOnlineClass, Trigger: "Unknown"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.req.Valid(); got != tt.want {
+				t.Errorf("Valid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAssessmentAutoCompleteUpdateReq(t *testing.T) {
+	detail := &AssessmentDetailReply{
+		ID: "assessment-1",
+		Contents: []*AssessmentContentReply{
+			{ContentID: "material-1", ParentID: "plan-1", Status: AssessmentContentStatusCovered},
+		},
+		Students: []*AssessmentStudentReply{
+			{
+				StudentID: "student-1",
+				Status:    AssessmentUserStatusParticipate,
+				Results: []*AssessmentStudentResultReply{
+					{
+						ContentID: "material-1",
+						Score:     6,
+						Outcomes: []*AssessmentStudentResultOutcomeReply{
+							{OutcomeID: "outcome-1", Status: AssessmentUserOutcomeStatusAchieved},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	req := NewAssessmentAutoCompleteUpdateReq(detail)
+	if req.ID != "assessment-1" || req.Action != AssessmentActionComplete {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if len(req.Contents) != 1 || req.Contents[0].ParentID != "plan-1" || req.Contents[0].Status != AssessmentContentStatusCovered {
+		t.Errorf("contents not copied: %+v", req.Contents)
+	}
+	if len(req.Students) != 1 || len(req.Students[0].Results) != 1 {
+		t.Fatalf("students not copied: %+v", req.Students)
+	}
+	result := req.Students[0].Results[0]
+	if result.Score != 6 || len(result.Outcomes) != 1 || result.Outcomes[0].Status != AssessmentUserOutcomeStatusAchieved {
+		t.Errorf("result not copied: %+v", result)
+	}
+}
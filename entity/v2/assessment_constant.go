@@ -28,6 +28,7 @@ func GetAssessmentStatusByReq() map[AssessmentStatusForApiCompliant][]string {
 			AssessmentStatusStarted.String(),
 			AssessmentStatusInDraft.String(),
 			AssessmentStatusPending.String(),
+			AssessmentStatusPendingModeration.String(),
 		},
 		AssessmentStatusCompliantCompleted: []string{
 			AssessmentStatusComplete.String(),
@@ -187,7 +188,9 @@ const (
 	AssessmentStatusStarted AssessmentStatus = "Started"
 	// when teacher click save
 	AssessmentStatusInDraft AssessmentStatus = "Draft"
-	// when teacher click complete
+	// when teacher click complete and the program requires a second marker to moderate
+	AssessmentStatusPendingModeration AssessmentStatus = "PendingModeration"
+	// when teacher click complete, or the moderator reconciled the result
 	AssessmentStatusComplete AssessmentStatus = "Complete"
 )
 
@@ -15,6 +15,10 @@ type Assessment struct {
 	ClassLength    int              `gorm:"class_length"`
 	ClassEndAt     int64            `gorm:"class_end_at"`
 	MigrateFlag    int              `gorm:"migrate_flag"`
+	// empty for assessments completed before it was recorded
+	CompleteBy AssessmentCompleteBy `gorm:"complete_by"`
+	// ReopenedAt reopened after it was completed by the system, auto complete rules skip it
+	ReopenedAt int64 `gorm:"reopened_at"`
 
 	CreateAt int64 `gorm:"column:create_at;type:bigint"`
 	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
@@ -0,0 +1,333 @@
+package v2
+
+import (
+	"math"
+	"time"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+// AssessmentGradebookLineItem one gradable column of a class gradebook, snapshot of a completed assessment
+type AssessmentGradebookLineItem struct {
+	ID             string         `gorm:"column:id;PRIMARY_KEY"`
+	OrgID          string         `gorm:"org_id"`
+	ClassID        string         `gorm:"class_id"`
+	SchoolID       string         `gorm:"school_id"`
+	ScheduleID     string         `gorm:"schedule_id"`
+	AssessmentType AssessmentType `gorm:"assessment_type"`
+	Title          string         `gorm:"title"`
+	MaxScore       float64        `gorm:"max_score"`
+	AssignAt       int64          `gorm:"assign_at"`
+	DueAt          int64          `gorm:"due_at"`
+	CompleteAt     int64          `gorm:"complete_at"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (AssessmentGradebookLineItem) TableName() string {
+	return constant.TableNameAssessmentGradebookLineItemV2
+}
+
+// AssessmentGradebookResult score of a student on a line item, id is the assessment user id
+type AssessmentGradebookResult struct {
+	ID                  string                         `gorm:"column:id;PRIMARY_KEY"`
+	OrgID               string                         `gorm:"org_id"`
+	LineItemID          string                         `gorm:"line_item_id"`
+	ClassID             string                         `gorm:"class_id"`
+	StudentID           string                         `gorm:"student_id"`
+	ScoreStatus         AssessmentGradebookScoreStatus `gorm:"score_status"`
+	Score               float64                        `gorm:"score"`
+	MaxScore            float64                        `gorm:"max_score"`
+	Comment             string                         `gorm:"comment"`
+	OutcomesAchieved    int                            `gorm:"outcomes_achieved"`
+	OutcomesNotAchieved int                            `gorm:"outcomes_not_achieved"`
+	OutcomesTotal       int                            `gorm:"outcomes_total"`
+	ScoreAt             int64                          `gorm:"score_at"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (AssessmentGradebookResult) TableName() string {
+	return constant.TableNameAssessmentGradebookResultV2
+}
+
+// AssessmentGradebookScoreStatus values follow the OneRoster scoreStatus vocabulary
+type AssessmentGradebookScoreStatus string
+
+const (
+	AssessmentGradebookScoreStatusFullyGraded  AssessmentGradebookScoreStatus = "fully graded"
+	AssessmentGradebookScoreStatusNotSubmitted AssessmentGradebookScoreStatus = "not submitted"
+)
+
+func (a AssessmentGradebookScoreStatus) String() string {
+	return string(a)
+}
+
+// AssessmentGradebookInput everything needed to flatten a completed assessment into the gradebook
+type AssessmentGradebookInput struct {
+	OrgID      string
+	SchoolID   string
+	ScheduleID string
+	AssignAt   int64
+	// key: student id, value: assessment user id
+	AssessmentUserIDs map[string]string
+	Detail            *AssessmentDetailReply
+}
+
+// NewAssessmentGradebook flatten assessment detail into a line item and one result per student
+func NewAssessmentGradebook(input *AssessmentGradebookInput, now int64) (*AssessmentGradebookLineItem, []*AssessmentGradebookResult) {
+	detail := input.Detail
+
+	lineItem := &AssessmentGradebookLineItem{
+		ID:             detail.ID,
+		OrgID:          input.OrgID,
+		SchoolID:       input.SchoolID,
+		ScheduleID:     input.ScheduleID,
+		AssessmentType: detail.AssessmentType,
+		Title:          detail.Title,
+		MaxScore:       gradebookMaxScore(detail),
+		AssignAt:       input.AssignAt,
+		DueAt:          detail.ScheduleDueAt,
+		CompleteAt:     detail.CompleteAt,
+		CreateAt:       now,
+		UpdateAt:       now,
+	}
+	if detail.Class != nil {
+		lineItem.ClassID = detail.Class.ID
+	}
+
+	results := make([]*AssessmentGradebookResult, 0, len(detail.Students))
+	for _, student := range detail.Students {
+		id, ok := input.AssessmentUserIDs[student.StudentID]
+		if !ok {
+			continue
+		}
+
+		resultItem := &AssessmentGradebookResult{
+			ID:          id,
+			OrgID:       input.OrgID,
+			LineItemID:  lineItem.ID,
+			ClassID:     lineItem.ClassID,
+			StudentID:   student.StudentID,
+			ScoreStatus: AssessmentGradebookScoreStatusNotSubmitted,
+			MaxScore:    lineItem.MaxScore,
+			Comment:     student.ReviewerComment,
+			ScoreAt:     detail.CompleteAt,
+			CreateAt:    now,
+			UpdateAt:    now,
+		}
+		resultItem.OutcomesAchieved, resultItem.OutcomesNotAchieved, resultItem.OutcomesTotal = gradebookOutcomeCount(student)
+
+		if score, ok := gradebookStudentScore(detail, student); ok {
+			resultItem.ScoreStatus = AssessmentGradebookScoreStatusFullyGraded
+			resultItem.Score = score
+		}
+
+		results = append(results, resultItem)
+	}
+
+	return lineItem, results
+}
+
+// gradebookScoredContents contents which contribute to the score, containers are skipped because their children are scored
+func gradebookScoredContents(detail *AssessmentDetailReply) map[string]*AssessmentContentReply {
+	result := make(map[string]*AssessmentContentReply)
+	for _, item := range detail.Contents {
+		if item.FileType != AssessmentFileTypeSupportScoreStandAlone ||
+			item.Status == AssessmentContentStatusNotCovered ||
+			item.MaxScore <= 0 {
+			continue
+		}
+		result[item.ContentID] = item
+	}
+	return result
+}
+
+func gradebookMaxScore(detail *AssessmentDetailReply) float64 {
+	if detail.Rubric != nil && detail.Rubric.MaxPoints > 0 {
+		return float64(detail.Rubric.MaxPoints)
+	}
+
+	if detail.AssessmentType == AssessmentTypeOfflineStudy {
+		return float64(AssessmentUserAssessExcellent)
+	}
+
+	var result float64
+	for _, item := range gradebookScoredContents(detail) {
+		result += item.MaxScore
+	}
+	return result
+}
+
+// gradebookStudentScore rubric points first, then reviewer assess score for offline study, H5P room scores for the others
+func gradebookStudentScore(detail *AssessmentDetailReply, student *AssessmentStudentReply) (float64, bool) {
+	if student.Status == AssessmentUserStatusNotParticipate {
+		return 0, false
+	}
+
+	if student.RubricResult != nil && student.RubricResult.MaxPoints > 0 {
+		return float64(student.RubricResult.TotalPoints), student.RubricResult.IsCompleted
+	}
+
+	if detail.AssessmentType == AssessmentTypeOfflineStudy {
+		for _, item := range student.Results {
+			if item.AssessScore.Valid() {
+				return float64(item.AssessScore), true
+			}
+		}
+		return 0, false
+	}
+
+	scoredContents := gradebookScoredContents(detail)
+	var result float64
+	for _, item := range student.Results {
+		if _, ok := scoredContents[item.ContentID]; ok {
+			result += item.Score
+		}
+	}
+
+	return math.Round(result*100) / 100, true
+}
+
+// gradebookOutcomeCount an outcome is achieved when any content marks it achieved
+func gradebookOutcomeCount(student *AssessmentStudentReply) (achieved int, notAchieved int, total int) {
+	statusMap := make(map[string]AssessmentUserOutcomeStatus)
+	for _, result := range student.Results {
+		for _, item := range result.Outcomes {
+			if statusMap[item.OutcomeID] == AssessmentUserOutcomeStatusAchieved {
+				continue
+			}
+			if item.Status == AssessmentUserOutcomeStatusNotAchieved || item.Status == AssessmentUserOutcomeStatusAchieved {
+				statusMap[item.OutcomeID] = item.Status
+				continue
+			}
+			if _, ok := statusMap[item.OutcomeID]; !ok {
+				statusMap[item.OutcomeID] = item.Status
+			}
+		}
+	}
+
+	for _, status := range statusMap {
+		switch status {
+		case AssessmentUserOutcomeStatusAchieved:
+			achieved++
+		case AssessmentUserOutcomeStatusNotAchieved:
+			notAchieved++
+		}
+	}
+
+	return achieved, notAchieved, len(statusMap)
+}
+
+type AssessmentGradebookReq struct {
+	ClassID      string `form:"class_id"`
+	CompleteAtGe int64  `form:"complete_at_ge"`
+	CompleteAtLe int64  `form:"complete_at_le"`
+}
+
+type AssessmentGradebookExportReq struct {
+	AssessmentGradebookReq
+	Format AssessmentGradebookExportFormat `form:"format"`
+}
+
+type AssessmentGradebookExportFormat string
+
+const (
+	AssessmentGradebookExportFormatCsv  AssessmentGradebookExportFormat = "csv"
+	AssessmentGradebookExportFormatXlsx AssessmentGradebookExportFormat = "xlsx"
+)
+
+func (a AssessmentGradebookExportFormat) Valid() bool {
+	switch a {
+	case AssessmentGradebookExportFormatCsv, AssessmentGradebookExportFormatXlsx:
+		return true
+	}
+	return false
+}
+
+type AssessmentGradebookSyncReq struct {
+	ClassID string `json:"class_id"`
+}
+
+type AssessmentGradebookReply struct {
+	ClassID   string                              `json:"class_id"`
+	ClassName string                              `json:"class_name"`
+	LineItems []*AssessmentGradebookLineItemReply `json:"line_items"`
+	Students  []*AssessmentGradebookStudentReply  `json:"students"`
+}
+
+type AssessmentGradebookLineItemReply struct {
+	ID             string         `json:"id"`
+	Title          string         `json:"title"`
+	AssessmentType AssessmentType `json:"assessment_type"`
+	MaxScore       float64        `json:"max_score"`
+	DueAt          int64          `json:"due_at"`
+	CompleteAt     int64          `json:"complete_at"`
+}
+
+type AssessmentGradebookStudentReply struct {
+	StudentID   string                                   `json:"student_id"`
+	StudentName string                                   `json:"student_name"`
+	Results     []*AssessmentGradebookStudentResultReply `json:"results"`
+}
+
+type AssessmentGradebookStudentResultReply struct {
+	LineItemID          string                         `json:"line_item_id"`
+	ScoreStatus         AssessmentGradebookScoreStatus `json:"score_status" enums:"fully graded,not submitted"`
+	Score               float64                        `json:"score"`
+	MaxScore            float64                        `json:"max_score"`
+	Comment             string                         `json:"comment"`
+	OutcomesAchieved    int                            `json:"outcomes_achieved"`
+	OutcomesNotAchieved int                            `json:"outcomes_not_achieved"`
+	OutcomesTotal       int                            `json:"outcomes_total"`
+}
+
+var AssessmentGradebookExportHeader = []interface{}{
+	"Class ID", "Class Name", "Student ID", "Student Name",
+	"Assessment ID", "Assessment Title", "Assessment Type", "Complete Date",
+	"Score Status", "Score", "Max Score", "Percentage",
+	"Outcomes Achieved", "Outcomes Not Achieved", "Outcomes Total", "Comment",
+}
+
+// ExportRows one row per student and line item, the first row is the header
+func (g *AssessmentGradebookReply) ExportRows() [][]interface{} {
+	lineItemMap := make(map[string]*AssessmentGradebookLineItemReply, len(g.LineItems))
+	for _, item := range g.LineItems {
+		lineItemMap[item.ID] = item
+	}
+
+	rows := make([][]interface{}, 0, len(g.Students)*len(g.LineItems)+1)
+	rows = append(rows, AssessmentGradebookExportHeader)
+	for _, student := range g.Students {
+		for _, result := range student.Results {
+			lineItem, ok := lineItemMap[result.LineItemID]
+			if !ok {
+				continue
+			}
+
+			var completeDate, score, percentage interface{}
+			if lineItem.CompleteAt > 0 {
+				completeDate = time.Unix(lineItem.CompleteAt, 0).UTC().Format("2006-01-02")
+			}
+			if result.ScoreStatus == AssessmentGradebookScoreStatusFullyGraded {
+				score = result.Score
+				if result.MaxScore > 0 {
+					percentage = math.Round(result.Score/result.MaxScore*10000) / 100
+				}
+			}
+
+			rows = append(rows, []interface{}{
+				g.ClassID, g.ClassName, student.StudentID, student.StudentName,
+				lineItem.ID, lineItem.Title, lineItem.AssessmentType.String(), completeDate,
+				result.ScoreStatus.String(), score, result.MaxScore, percentage,
+				result.OutcomesAchieved, result.OutcomesNotAchieved, result.OutcomesTotal, result.Comment,
+			})
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,265 @@
+package v2
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+type gradebookFixture struct {
+	OrgID             string                 `json:"org_id"`
+	SchoolID          string                 `json:"school_id"`
+	ScheduleID        string                 `json:"schedule_id"`
+	AssignAt          int64                  `json:"assign_at"`
+	AssessmentUserIDs map[string]string      `json:"assessment_user_ids"`
+	Detail            *AssessmentDetailReply `json:"detail"`
+}
+
+const gradebookFixtureNow = 1650000100
+
+func loadGradebookFixture(t *testing.T, name string, v interface{}) {
+	data, err := os.ReadFile(filepath.Join("testdata", "gradebook", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("unmarshal %s failed: %v", name, err)
+	}
+}
+
+func buildGradebookFixture(t *testing.T, name string) (*AssessmentGradebookLineItem, []*AssessmentGradebookResult) {
+	fixture := new(gradebookFixture)
+	loadGradebookFixture(t, name, fixture)
+
+	return NewAssessmentGradebook(&AssessmentGradebookInput{
+		OrgID:             fixture.OrgID,
+		SchoolID:          fixture.SchoolID,
+		ScheduleID:        fixture.ScheduleID,
+		AssignAt:          fixture.AssignAt,
+		AssessmentUserIDs: fixture.AssessmentUserIDs,
+		Detail:            fixture.Detail,
+	}, gradebookFixtureNow)
+}
+
+// assertJSONEqual compare the json encoding of got with the expected fixture, key order does not matter
+func assertJSONEqual(t *testing.T, got interface{}, expectedFixture string) map[string]interface{} {
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotValue, wantValue map[string]interface{}
+	if err := json.Unmarshal(data, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	loadGradebookFixture(t, expectedFixture, &wantValue)
+
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		want, _ := json.MarshalIndent(wantValue, "", "  ")
+		gotIndent, _ := json.MarshalIndent(gotValue, "", "  ")
+		t.Errorf("%s mismatch\nwant: %s\ngot: %s", expectedFixture, want, gotIndent)
+	}
+
+	return gotValue
+}
+
+var oneRosterDateTimeRegexp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z$`)
+
+func assertOneRosterGUIDRef(t *testing.T, name string, value interface{}, refType string) {
+	ref, ok := value.(map[string]interface{})
+	if !ok {
+		t.Errorf("%s should be a GUIDRef, got %v", name, value)
+		return
+	}
+	for _, key := range []string{"href", "sourcedId", "type"} {
+		if s, ok := ref[key].(string); !ok || s == "" {
+			t.Errorf("%s.%s is required", name, key)
+		}
+	}
+	if ref["type"] != refType {
+		t.Errorf("%s.type want %s, got %v", name, refType, ref["type"])
+	}
+}
+
+func assertOneRosterBase(t *testing.T, name string, value map[string]interface{}) {
+	if s, ok := value["sourcedId"].(string); !ok || s == "" {
+		t.Errorf("%s.sourcedId is required", name)
+	}
+	if value["status"] != string(OneRosterStatusActive) && value["status"] != string(OneRosterStatusToBeDeleted) {
+		t.Errorf("%s.status is invalid: %v", name, value["status"])
+	}
+	if s, _ := value["dateLastModified"].(string); !oneRosterDateTimeRegexp.MatchString(s) {
+		t.Errorf("%s.dateLastModified is not an ISO 8601 UTC datetime: %v", name, value["dateLastModified"])
+	}
+}
+
+// assertOneRosterLineItem check the fields the OneRoster 1.2 gradebook binding marks as required
+func assertOneRosterLineItem(t *testing.T, value map[string]interface{}) {
+	assertOneRosterBase(t, "lineItem", value)
+	for _, key := range []string{"title", "assignDate", "dueDate"} {
+		if s, ok := value[key].(string); !ok || s == "" {
+			t.Errorf("lineItem.%s is required", key)
+		}
+	}
+	for _, key := range []string{"assignDate", "dueDate"} {
+		if s, _ := value[key].(string); !oneRosterDateTimeRegexp.MatchString(s) {
+			t.Errorf("lineItem.%s is not an ISO 8601 UTC datetime: %v", key, value[key])
+		}
+	}
+	assertOneRosterGUIDRef(t, "lineItem.class", value["class"], "class")
+	assertOneRosterGUIDRef(t, "lineItem.school", value["school"], "org")
+	assertOneRosterGUIDRef(t, "lineItem.category", value["category"], "category")
+	for _, key := range []string{"resultValueMin", "resultValueMax"} {
+		if _, ok := value[key].(float64); !ok {
+			t.Errorf("lineItem.%s should be a number", key)
+		}
+	}
+}
+
+func assertOneRosterResult(t *testing.T, value map[string]interface{}) {
+	assertOneRosterBase(t, "result", value)
+	assertOneRosterGUIDRef(t, "result.lineItem", value["lineItem"], "lineItem")
+	assertOneRosterGUIDRef(t, "result.student", value["student"], "user")
+	if class, ok := value["class"]; ok {
+		assertOneRosterGUIDRef(t, "result.class", class, "class")
+	}
+	if s, _ := value["scoreDate"].(string); !oneRosterDateTimeRegexp.MatchString(s) {
+		t.Errorf("result.scoreDate is not an ISO 8601 UTC datetime: %v", value["scoreDate"])
+	}
+
+	switch value["scoreStatus"] {
+	case "exempt", "fully graded", "not submitted", "partially graded", "submitted":
+	default:
+		t.Errorf("result.scoreStatus is invalid: %v", value["scoreStatus"])
+	}
+	if _, ok := value["score"]; ok && value["scoreStatus"] == "not submitted" {
+		t.Errorf("result.score should be omitted when not submitted")
+	}
+}
+
+func TestOneRosterGradebookContract(t *testing.T) {
+	tests := []struct {
+		input    string
+		lineItem string
+		results  string
+	}{
+		{input: "online_class_input.json", lineItem: "online_class_line_item.json", results: "online_class_results.json"},
+		{input: "offline_study_input.json", lineItem: "offline_study_line_item.json", results: "offline_study_results.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			lineItem, results := buildGradebookFixture(t, tt.input)
+
+			lineItemReply := assertJSONEqual(t, &OneRosterLineItemReply{LineItem: lineItem.ToOneRoster()}, tt.lineItem)
+			if value, ok := lineItemReply["lineItem"].(map[string]interface{}); ok {
+				assertOneRosterLineItem(t, value)
+			} else {
+				t.Error("lineItem envelope is missing")
+			}
+
+			oneRosterResults := make([]*OneRosterResult, 0, len(results))
+			for _, item := range results {
+				oneRosterResults = append(oneRosterResults, item.ToOneRoster())
+			}
+			resultsReply := assertJSONEqual(t, &OneRosterResultsReply{Results: oneRosterResults}, tt.results)
+			items, _ := resultsReply["results"].([]interface{})
+			for _, item := range items {
+				value, _ := item.(map[string]interface{})
+				assertOneRosterResult(t, value)
+			}
+		})
+	}
+}
+
+func TestOneRosterDeletedRecord(t *testing.T) {
+	lineItem, results := buildGradebookFixture(t, "online_class_input.json")
+	lineItem.DeleteAt = gradebookFixtureNow + 100
+	results[0].DeleteAt = gradebookFixtureNow + 100
+
+	if got := lineItem.ToOneRoster(); got.Status != OneRosterStatusToBeDeleted || got.DateLastModified != "2022-04-15T05:23:20.000Z" {
+		t.Errorf("deleted line item want tobedeleted at delete time, got %s %s", got.Status, got.DateLastModified)
+	}
+	if got := results[0].ToOneRoster(); got.Status != OneRosterStatusToBeDeleted {
+		t.Errorf("deleted result want tobedeleted, got %s", got.Status)
+	}
+}
+
+func TestOneRosterErrorReply(t *testing.T) {
+	data, err := json.Marshal(NewOneRosterErrorReply(OneRosterCodeMinorUnknownObject, "line item not found"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"imsx_codeMajor":"failure","imsx_severity":"error","imsx_description":"line item not found","imsx_CodeMinor":{"imsx_codeMinorField":[{"imsx_codeMinorFieldName":"TargetEndSystem","imsx_codeMinorFieldValue":"unknownobject"}]}}`
+	if string(data) != want {
+		t.Errorf("want %s, got %s", want, data)
+	}
+}
+
+func TestAssessmentGradebookExportRows(t *testing.T) {
+	gradebook := &AssessmentGradebookReply{
+		ClassID:   "class-1",
+		ClassName: "Class A",
+		LineItems: []*AssessmentGradebookLineItemReply{
+			{ID: "assessment-1", Title: "Unit 1", AssessmentType: AssessmentTypeOnlineClass, MaxScore: 8, CompleteAt: 1650000000},
+		},
+		Students: []*AssessmentGradebookStudentReply{
+			{StudentID: "student-1", StudentName: "Tom", Results: []*AssessmentGradebookStudentResultReply{
+				{LineItemID: "assessment-1", ScoreStatus: AssessmentGradebookScoreStatusFullyGraded, Score: 6, MaxScore: 8, OutcomesAchieved: 1, OutcomesTotal: 2},
+			}},
+			{StudentID: "student-2", StudentName: "Jerry", Results: []*AssessmentGradebookStudentResultReply{
+				{LineItemID: "assessment-1", ScoreStatus: AssessmentGradebookScoreStatusNotSubmitted, MaxScore: 8},
+				{LineItemID: "unknown"},
+			}},
+		},
+	}
+
+	rows := gradebook.ExportRows()
+	if len(rows) != 3 {
+		t.Fatalf("want header and 2 rows, got %d", len(rows))
+	}
+	if !reflect.DeepEqual(rows[0], AssessmentGradebookExportHeader) {
+		t.Errorf("first row should be the header, got %v", rows[0])
+	}
+
+	want := []interface{}{
+		"class-1", "Class A", "student-1", "Tom",
+		"assessment-1", "Unit 1", "OnlineClass", "2022-04-15",
+		"fully graded", 6.0, 8.0, 75.0,
+		1, 0, 2, "",
+	}
+	if !reflect.DeepEqual(rows[1], want) {
+		t.Errorf("want %v, got %v", want, rows[1])
+	}
+	if rows[2][9] != nil || rows[2][11] != nil {
+		t.Errorf("score and percentage should be empty when not submitted, got %v", rows[2])
+	}
+}
+
+func TestParseOneRosterFilter(t *testing.T) {
+	tests := []struct {
+		filter  string
+		want    int64
+		wantErr bool
+	}{
+		{filter: "", want: 0},
+		{filter: "dateLastModified>'2022-04-15T05:20:00.000Z'", want: 1650000000},
+		{filter: "dateLastModified>'2022-04-15T05:20:00Z'", want: 1650000000},
+		{filter: "dateLastModified>'2022-04-15'", want: 1649980800},
+		{filter: "status='active'", wantErr: true},
+		{filter: "dateLastModified>'yesterday'", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseOneRosterFilter(tt.filter)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: want error %v, got %v", tt.filter, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: want %d, got %d", tt.filter, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package v2
+
+import (
+	"math"
+	"sort"
+)
+
+// item analysis flags, thresholds follow the usual classical test theory rules of thumb
+const (
+	AssessmentItemAnalysisMaxAssessments = 200
+
+	assessmentItemAnalysisTooHard        = 0.2
+	assessmentItemAnalysisTooEasy        = 0.95
+	assessmentItemAnalysisLowDiscrim     = 0.2
+	assessmentItemAnalysisMinResponses   = 5
+	assessmentItemAnalysisMaxAnswerTypes = 10
+	assessmentItemAnalysisOtherAnswers   = "(other)"
+)
+
+type AssessmentItemAnalysisFlag string
+
+const (
+	AssessmentItemAnalysisFlagTooHard                AssessmentItemAnalysisFlag = "TooHard"
+	AssessmentItemAnalysisFlagTooEasy                AssessmentItemAnalysisFlag = "TooEasy"
+	AssessmentItemAnalysisFlagLowDiscrimination      AssessmentItemAnalysisFlag = "LowDiscrimination"
+	AssessmentItemAnalysisFlagNegativeDiscrimination AssessmentItemAnalysisFlag = "NegativeDiscrimination"
+	AssessmentItemAnalysisFlagNoAttempts             AssessmentItemAnalysisFlag = "NoAttempts"
+)
+
+type AssessmentItemAnalysisReq struct {
+	ContentID    string `form:"content_id"`
+	ClassID      string `form:"class_id"`
+	CompleteAtGe int64  `form:"complete_at_ge"`
+	CompleteAtLe int64  `form:"complete_at_le"`
+}
+
+// AssessmentItemResponse one student's response to one H5P item in one assessment
+type AssessmentItemResponse struct {
+	AssessmentID string
+	StudentID    string
+	ItemID       string
+	ParentID     string
+	ItemName     string
+	ItemType     string
+	Score        float64
+	MaxScore     float64
+	Attempted    bool
+	Attempts     int
+	Answer       string
+}
+
+type AssessmentItemAnalysisReply struct {
+	ContentID   string                                   `json:"content_id"`
+	ContentName string                                   `json:"content_name"`
+	Scanned     int                                      `json:"scanned"`
+	Truncated   bool                                     `json:"truncated"`
+	Assessments []*AssessmentItemAnalysisAssessmentReply `json:"assessments"`
+	Items       []*AssessmentItemAnalysisItemReply       `json:"items"`
+}
+
+type AssessmentItemAnalysisAssessmentReply struct {
+	ID             string         `json:"id"`
+	Title          string         `json:"title"`
+	AssessmentType AssessmentType `json:"assessment_type"`
+	ClassID        string         `json:"class_id"`
+	ClassName      string         `json:"class_name"`
+	CompleteAt     int64          `json:"complete_at"`
+	StudentCount   int            `json:"student_count"`
+}
+
+type AssessmentItemAnalysisItemReply struct {
+	ItemID   string  `json:"item_id"`
+	ParentID string  `json:"parent_id"`
+	Name     string  `json:"name"`
+	ItemType string  `json:"item_type"`
+	MaxScore float64 `json:"max_score"`
+
+	StudentCount   int `json:"student_count"`
+	AttemptedCount int `json:"attempted_count"`
+	AttemptCount   int `json:"attempt_count"`
+
+	// Difficulty mean of score / max score of attempted students, nil when the item is not scored
+	Difficulty *float64 `json:"difficulty"`
+	// Discrimination point-biserial correlation between full marks on the item and the rest of the material score
+	Discrimination *float64 `json:"discrimination"`
+
+	AnswerDistribution []*AssessmentItemAnswerCount           `json:"answer_distribution"`
+	Flags              []AssessmentItemAnalysisFlag           `json:"flags"`
+	Assessments        []*AssessmentItemAnalysisItemDrillDown `json:"assessments"`
+}
+
+type AssessmentItemAnswerCount struct {
+	Answer string `json:"answer"`
+	Count  int    `json:"count"`
+}
+
+// AssessmentItemAnalysisItemDrillDown numbers of the item within one assessment
+type AssessmentItemAnalysisItemDrillDown struct {
+	AssessmentID   string   `json:"assessment_id"`
+	StudentCount   int      `json:"student_count"`
+	AttemptedCount int      `json:"attempted_count"`
+	AttemptCount   int      `json:"attempt_count"`
+	Difficulty     *float64 `json:"difficulty"`
+}
+
+// NewAssessmentItemAnalysis aggregate responses by item, items keep the order they first appear
+func NewAssessmentItemAnalysis(responses []*AssessmentItemResponse) []*AssessmentItemAnalysisItemReply {
+	itemIDs := make([]string, 0)
+	itemResponses := make(map[string][]*AssessmentItemResponse)
+	for _, item := range responses {
+		if _, ok := itemResponses[item.ItemID]; !ok {
+			itemIDs = append(itemIDs, item.ItemID)
+		}
+		itemResponses[item.ItemID] = append(itemResponses[item.ItemID], item)
+	}
+
+	totals := assessmentItemStudentTotals(responses)
+
+	result := make([]*AssessmentItemAnalysisItemReply, 0, len(itemIDs))
+	for _, itemID := range itemIDs {
+		result = append(result, assessmentItemAnalyze(itemResponses[itemID], totals))
+	}
+
+	return result
+}
+
+type assessmentItemTotal struct {
+	score    float64
+	maxScore float64
+}
+
+func assessmentItemStudentKey(assessmentID, studentID string) string {
+	return assessmentID + "_" + studentID
+}
+
+// assessmentItemStudentTotals total score of each student on the material in each assessment, unattempted scored items count as 0
+func assessmentItemStudentTotals(responses []*AssessmentItemResponse) map[string]*assessmentItemTotal {
+	result := make(map[string]*assessmentItemTotal)
+	for _, item := range responses {
+		if item.MaxScore <= 0 {
+			continue
+		}
+		key := assessmentItemStudentKey(item.AssessmentID, item.StudentID)
+		total, ok := result[key]
+		if !ok {
+			total = new(assessmentItemTotal)
+			result[key] = total
+		}
+		total.maxScore += item.MaxScore
+		if item.Attempted {
+			total.score += item.Score
+		}
+	}
+	return result
+}
+
+func assessmentItemAnalyze(responses []*AssessmentItemResponse, totals map[string]*assessmentItemTotal) *AssessmentItemAnalysisItemReply {
+	first := responses[0]
+	result := &AssessmentItemAnalysisItemReply{
+		ItemID:             first.ItemID,
+		ParentID:           first.ParentID,
+		Name:               first.ItemName,
+		ItemType:           first.ItemType,
+		AnswerDistribution: []*AssessmentItemAnswerCount{},
+		Flags:              []AssessmentItemAnalysisFlag{},
+		Assessments:        []*AssessmentItemAnalysisItemDrillDown{},
+	}
+
+	assessmentIDs := make([]string, 0)
+	assessmentResponses := make(map[string][]*AssessmentItemResponse)
+	for _, item := range responses {
+		if item.MaxScore > result.MaxScore {
+			result.MaxScore = item.MaxScore
+		}
+		if _, ok := assessmentResponses[item.AssessmentID]; !ok {
+			assessmentIDs = append(assessmentIDs, item.AssessmentID)
+		}
+		assessmentResponses[item.AssessmentID] = append(assessmentResponses[item.AssessmentID], item)
+	}
+
+	result.StudentCount, result.AttemptedCount, result.AttemptCount, result.Difficulty = assessmentItemCounts(responses)
+	for _, assessmentID := range assessmentIDs {
+		drillDown := &AssessmentItemAnalysisItemDrillDown{AssessmentID: assessmentID}
+		drillDown.StudentCount, drillDown.AttemptedCount, drillDown.AttemptCount, drillDown.Difficulty = assessmentItemCounts(assessmentResponses[assessmentID])
+		result.Assessments = append(result.Assessments, drillDown)
+	}
+
+	result.AnswerDistribution = assessmentItemAnswerDistribution(responses)
+	result.Discrimination = assessmentItemDiscrimination(responses, totals)
+
+	if result.StudentCount > 0 && result.AttemptedCount == 0 {
+		result.Flags = append(result.Flags, AssessmentItemAnalysisFlagNoAttempts)
+	}
+	if result.Difficulty != nil && result.AttemptedCount >= assessmentItemAnalysisMinResponses {
+		if *result.Difficulty < assessmentItemAnalysisTooHard {
+			result.Flags = append(result.Flags, AssessmentItemAnalysisFlagTooHard)
+		}
+		if *result.Difficulty > assessmentItemAnalysisTooEasy {
+			result.Flags = append(result.Flags, AssessmentItemAnalysisFlagTooEasy)
+		}
+	}
+	if result.Discrimination != nil {
+		if *result.Discrimination < 0 {
+			result.Flags = append(result.Flags, AssessmentItemAnalysisFlagNegativeDiscrimination)
+		} else if *result.Discrimination < assessmentItemAnalysisLowDiscrim {
+			result.Flags = append(result.Flags, AssessmentItemAnalysisFlagLowDiscrimination)
+		}
+	}
+
+	return result
+}
+
+func assessmentItemCounts(responses []*AssessmentItemResponse) (students int, attempted int, attempts int, difficulty *float64) {
+	var ratioSum float64
+	var ratioCount int
+	for _, item := range responses {
+		students++
+		if !item.Attempted {
+			continue
+		}
+		attempted++
+		attempts += item.Attempts
+		if item.MaxScore > 0 {
+			ratioSum += item.Score / item.MaxScore
+			ratioCount++
+		}
+	}
+	if ratioCount > 0 {
+		difficulty = assessmentItemRound(ratioSum / float64(ratioCount))
+	}
+	return
+}
+
+// assessmentItemAnswerDistribution answers sorted by count, the long tail is merged into one bucket
+func assessmentItemAnswerDistribution(responses []*AssessmentItemResponse) []*AssessmentItemAnswerCount {
+	countMap := make(map[string]int)
+	for _, item := range responses {
+		if !item.Attempted || item.Answer == "" {
+			continue
+		}
+		countMap[item.Answer]++
+	}
+
+	result := make([]*AssessmentItemAnswerCount, 0, len(countMap))
+	for answer, count := range countMap {
+		result = append(result, &AssessmentItemAnswerCount{Answer: answer, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Answer < result[j].Answer
+	})
+
+	if len(result) > assessmentItemAnalysisMaxAnswerTypes {
+		other := &AssessmentItemAnswerCount{Answer: assessmentItemAnalysisOtherAnswers}
+		for _, item := range result[assessmentItemAnalysisMaxAnswerTypes-1:] {
+			other.Count += item.Count
+		}
+		result = append(result[:assessmentItemAnalysisMaxAnswerTypes-1], other)
+	}
+
+	return result
+}
+
+// assessmentItemDiscrimination point-biserial correlation, the item is dichotomized as full marks or not
+// and the total excludes the item itself so it does not correlate with itself
+func assessmentItemDiscrimination(responses []*AssessmentItemResponse, totals map[string]*assessmentItemTotal) *float64 {
+	var correct, rest []float64
+	for _, item := range responses {
+		if !item.Attempted || item.MaxScore <= 0 {
+			continue
+		}
+		total, ok := totals[assessmentItemStudentKey(item.AssessmentID, item.StudentID)]
+		if !ok || total.maxScore-item.MaxScore <= 0 {
+			continue
+		}
+
+		value := 0.0
+		if item.Score >= item.MaxScore {
+			value = 1
+		}
+		correct = append(correct, value)
+		rest = append(rest, (total.score-item.Score)/(total.maxScore-item.MaxScore))
+	}
+
+	if len(correct) < assessmentItemAnalysisMinResponses {
+		return nil
+	}
+
+	return assessmentItemPearson(correct, rest)
+}
+
+func assessmentItemPearson(x, y []float64) *float64 {
+	n := float64(len(x))
+	var sumX, sumY float64
+	for i := range x {
+		sumX += x[i]
+		sumY += y[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var cov, varX, varY float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return nil
+	}
+
+	return assessmentItemRound(cov / math.Sqrt(varX*varY))
+}
+
+func assessmentItemRound(value float64) *float64 {
+	result := math.Round(value*1000) / 1000
+	return &result
+}
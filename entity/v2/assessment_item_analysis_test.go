@@ -0,0 +1,122 @@
+package v2
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func newItemResponses(assessmentID string, itemID string, maxScore float64, scores map[string]float64, attempted map[string]bool) []*AssessmentItemResponse {
+	var result []*AssessmentItemResponse
+	for i := 1; i <= 6; i++ {
+		studentID := fmt.Sprintf("student-%d", i)
+		item := &AssessmentItemResponse{
+			AssessmentID: assessmentID,
+			StudentID:    studentID,
+			ItemID:       itemID,
+			ItemName:     itemID,
+			MaxScore:     maxScore,
+			Score:        scores[studentID],
+			Attempted:    attempted[studentID],
+		}
+		if item.Attempted {
+			item.Attempts = 1
+			item.Answer = fmt.Sprint(item.Score)
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+func TestNewAssessmentItemAnalysis(t *testing.T) {
+	attempted := map[string]bool{"student-1": true, "student-2": true, "student-3": true, "student-4": true, "student-5": true}
+
+	var responses []*AssessmentItemResponse
+	responses = append(responses, newItemResponses("assessment-1", "q1", 1,
+		map[string]float64{"student-1": 1, "student-2": 1, "student-3": 1}, attempted)...)
+	responses = append(responses, newItemResponses("assessment-1", "q2", 1,
+		map[string]float64{"student-1": 1, "student-2": 1, "student-5": 1}, attempted)...)
+	responses = append(responses, newItemResponses("assessment-1", "q3", 2,
+		map[string]float64{"student-1": 2, "student-2": 1, "student-3": 2}, attempted)...)
+	responses = append(responses, newItemResponses("assessment-1", "video", 0, nil, nil)...)
+
+	items := NewAssessmentItemAnalysis(responses)
+	if len(items) != 4 {
+		t.Fatalf("want 4 items, got %d", len(items))
+	}
+
+	q1 := items[0]
+	if q1.ItemID != "q1" || q1.StudentCount != 6 || q1.AttemptedCount != 5 || q1.AttemptCount != 5 {
+		t.Errorf("q1 counts are wrong: %+v", q1)
+	}
+	if q1.Difficulty == nil || *q1.Difficulty != 0.6 {
+		t.Errorf("q1 difficulty want 0.6, got %v", q1.Difficulty)
+	}
+	if q1.Discrimination == nil || *q1.Discrimination != 0.881 {
+		t.Errorf("q1 discrimination want 0.881, got %v", q1.Discrimination)
+	}
+	if len(q1.Flags) != 0 {
+		t.Errorf("q1 should not be flagged, got %v", q1.Flags)
+	}
+	wantAnswers := []*AssessmentItemAnswerCount{{Answer: "1", Count: 3}, {Answer: "0", Count: 2}}
+	if !reflect.DeepEqual(q1.AnswerDistribution, wantAnswers) {
+		t.Errorf("q1 answer distribution want %v, got %v", wantAnswers, q1.AnswerDistribution)
+	}
+	if len(q1.Assessments) != 1 || q1.Assessments[0].AssessmentID != "assessment-1" || q1.Assessments[0].AttemptedCount != 5 {
+		t.Errorf("q1 drill down is wrong: %+v", q1.Assessments)
+	}
+
+	video := items[3]
+	if video.Difficulty != nil || video.Discrimination != nil {
+		t.Errorf("unscored item should have no difficulty or discrimination, got %+v", video)
+	}
+	if !reflect.DeepEqual(video.Flags, []AssessmentItemAnalysisFlag{AssessmentItemAnalysisFlagNoAttempts}) {
+		t.Errorf("video want NoAttempts flag, got %v", video.Flags)
+	}
+}
+
+func TestAssessmentItemAnalysisFlags(t *testing.T) {
+	attempted := map[string]bool{"student-1": true, "student-2": true, "student-3": true, "student-4": true, "student-5": true, "student-6": true}
+
+	var responses []*AssessmentItemResponse
+	// nobody gets the hard item, and only weak students get the reversed item right
+	responses = append(responses, newItemResponses("assessment-1", "hard", 1, nil, attempted)...)
+	responses = append(responses, newItemResponses("assessment-1", "reversed", 1,
+		map[string]float64{"student-5": 1, "student-6": 1}, attempted)...)
+	responses = append(responses, newItemResponses("assessment-1", "anchor", 4,
+		map[string]float64{"student-1": 4, "student-2": 4, "student-3": 3, "student-4": 2}, attempted)...)
+
+	items := NewAssessmentItemAnalysis(responses)
+
+	if !reflect.DeepEqual(items[0].Flags, []AssessmentItemAnalysisFlag{AssessmentItemAnalysisFlagTooHard}) {
+		t.Errorf("hard item want TooHard, got %v", items[0].Flags)
+	}
+	if items[0].Discrimination != nil {
+		t.Errorf("item without variance should have no discrimination, got %v", *items[0].Discrimination)
+	}
+	if !reflect.DeepEqual(items[1].Flags, []AssessmentItemAnalysisFlag{AssessmentItemAnalysisFlagNegativeDiscrimination}) {
+		t.Errorf("reversed item want NegativeDiscrimination, got %v", items[1].Flags)
+	}
+}
+
+func TestAssessmentItemAnswerDistribution(t *testing.T) {
+	var responses []*AssessmentItemResponse
+	for i := 0; i < 12; i++ {
+		for j := 0; j <= i; j++ {
+			responses = append(responses, &AssessmentItemResponse{Attempted: true, Answer: fmt.Sprintf("answer-%02d", i)})
+		}
+	}
+	responses = append(responses, &AssessmentItemResponse{Attempted: false, Answer: "ignored"})
+
+	result := assessmentItemAnswerDistribution(responses)
+	if len(result) != assessmentItemAnalysisMaxAnswerTypes {
+		t.Fatalf("want %d buckets, got %d", assessmentItemAnalysisMaxAnswerTypes, len(result))
+	}
+	if result[0].Answer != "answer-11" || result[0].Count != 12 {
+		t.Errorf("most frequent answer should be first, got %+v", result[0])
+	}
+	last := result[len(result)-1]
+	if last.Answer != assessmentItemAnalysisOtherAnswers || last.Count != 1+2+3 {
+		t.Errorf("tail should be merged, got %+v", last)
+	}
+}
@@ -0,0 +1,231 @@
+package v2
+
+import (
+	"hash/fnv"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+// AssessmentModerationPolicy moderation rules of a program, one policy per program in an organization
+type AssessmentModerationPolicy struct {
+	ID            string  `gorm:"column:id;PRIMARY_KEY"`
+	OrgID         string  `gorm:"org_id"`
+	ProgramID     string  `gorm:"program_id"`
+	SamplePercent int     `gorm:"sample_percent"`
+	FailThreshold float64 `gorm:"fail_threshold"`
+	CreatorID     string  `gorm:"creator_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (AssessmentModerationPolicy) TableName() string {
+	return constant.TableNameAssessmentModerationPolicyV2
+}
+
+// IsSampled sampling is deterministic by assessment id, so re-submitting an assessment does not change the decision
+func (p *AssessmentModerationPolicy) IsSampled(assessmentID string) bool {
+	if p.SamplePercent <= 0 {
+		return false
+	}
+	if p.SamplePercent >= 100 {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(assessmentID))
+	return int(h.Sum32()%100) < p.SamplePercent
+}
+
+// HasFailingScore any participating student scored below the fail threshold
+func (p *AssessmentModerationPolicy) HasFailingScore(detail *AssessmentDetailReply) bool {
+	if p.FailThreshold <= 0 {
+		return false
+	}
+
+	maxScore := gradebookMaxScore(detail)
+	if maxScore <= 0 {
+		return false
+	}
+
+	for _, student := range detail.Students {
+		score, ok := gradebookStudentScore(detail, student)
+		if !ok {
+			continue
+		}
+		if score/maxScore < p.FailThreshold {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reason why the assessment must be moderated, empty when it does not
+func (p *AssessmentModerationPolicy) Reason(detail *AssessmentDetailReply) AssessmentModerationReason {
+	if p.HasFailingScore(detail) {
+		return AssessmentModerationReasonFailingScore
+	}
+	if p.IsSampled(detail.ID) {
+		return AssessmentModerationReasonSampled
+	}
+	return ""
+}
+
+type AssessmentModeration struct {
+	ID           string                     `gorm:"column:id;PRIMARY_KEY"`
+	OrgID        string                     `gorm:"org_id"`
+	AssessmentID string                     `gorm:"assessment_id"`
+	ProgramID    string                     `gorm:"program_id"`
+	Reason       AssessmentModerationReason `gorm:"reason"`
+	Status       AssessmentModerationStatus `gorm:"status"`
+	MarkerID     string                     `gorm:"marker_id"`
+	ModeratorID  string                     `gorm:"moderator_id"`
+	// json of AssessmentModerationResult
+	Original     string `gorm:"original"`
+	Moderated    string `gorm:"moderated"`
+	Final        string `gorm:"final"`
+	ReconciledAt int64  `gorm:"reconciled_at"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (AssessmentModeration) TableName() string {
+	return constant.TableNameAssessmentModerationV2
+}
+
+type AssessmentModerationReason string
+
+const (
+	AssessmentModerationReasonSampled      AssessmentModerationReason = "Sampled"
+	AssessmentModerationReasonFailingScore AssessmentModerationReason = "FailingScore"
+)
+
+type AssessmentModerationStatus string
+
+const (
+	AssessmentModerationStatusPending    AssessmentModerationStatus = "Pending"
+	AssessmentModerationStatusReconciled AssessmentModerationStatus = "Reconciled"
+)
+
+func (s AssessmentModerationStatus) Valid() bool {
+	switch s {
+	case AssessmentModerationStatusPending,
+		AssessmentModerationStatusReconciled:
+		return true
+	}
+	return false
+}
+
+type AssessmentModerationAction string
+
+const (
+	AssessmentModerationActionDraft     AssessmentModerationAction = "Draft"
+	AssessmentModerationActionReconcile AssessmentModerationAction = "Reconcile"
+)
+
+func (a AssessmentModerationAction) Valid() bool {
+	switch a {
+	case AssessmentModerationActionDraft,
+		AssessmentModerationActionReconcile:
+		return true
+	}
+	return false
+}
+
+// AssessmentModerationResult scores and outcome decisions, same shape as the teacher's update request
+type AssessmentModerationResult struct {
+	Students []*AssessmentStudentUpdateReq `json:"students"`
+	Contents []*AssessmentUpdateContentReq `json:"contents"`
+}
+
+func NewAssessmentModerationResult(req *AssessmentUpdateReq) *AssessmentModerationResult {
+	return &AssessmentModerationResult{
+		Students: req.Students,
+		Contents: req.Contents,
+	}
+}
+
+func (r *AssessmentModerationResult) ToUpdateReq(assessmentID string, action AssessmentAction) *AssessmentUpdateReq {
+	return &AssessmentUpdateReq{
+		ID:       assessmentID,
+		Action:   action,
+		Students: r.Students,
+		Contents: r.Contents,
+	}
+}
+
+type AssessmentModerationPolicySetReq struct {
+	ProgramID     string  `json:"program_id"`
+	SamplePercent int     `json:"sample_percent"`
+	FailThreshold float64 `json:"fail_threshold"`
+}
+
+func (r *AssessmentModerationPolicySetReq) Valid() bool {
+	if r.ProgramID == "" {
+		return false
+	}
+	if r.SamplePercent < 0 || r.SamplePercent > 100 {
+		return false
+	}
+	if r.FailThreshold < 0 || r.FailThreshold > 1 {
+		return false
+	}
+	return true
+}
+
+type AssessmentModerationPolicyReply struct {
+	ID            string  `json:"id"`
+	ProgramID     string  `json:"program_id"`
+	ProgramName   string  `json:"program_name"`
+	SamplePercent int     `json:"sample_percent"`
+	FailThreshold float64 `json:"fail_threshold"`
+	UpdateAt      int64   `json:"update_at"`
+}
+
+type AssessmentModerationQueryReq struct {
+	Status    string `form:"status" enums:"Pending,Reconciled"`
+	PageIndex int    `form:"page"`
+	PageSize  int    `form:"page_size"`
+}
+
+type AssessmentModerationUpdateReq struct {
+	ID       string                        `json:"-"`
+	Action   AssessmentModerationAction    `json:"action" enums:"Draft,Reconcile"`
+	Students []*AssessmentStudentUpdateReq `json:"students"`
+	Contents []*AssessmentUpdateContentReq `json:"contents"`
+}
+
+type AssessmentModerationPageReply struct {
+	Total int                          `json:"total"`
+	Data  []*AssessmentModerationReply `json:"data"`
+}
+
+type AssessmentModerationReply struct {
+	ID              string                     `json:"id"`
+	AssessmentID    string                     `json:"assessment_id"`
+	AssessmentTitle string                     `json:"assessment_title"`
+	AssessmentType  AssessmentType             `json:"assessment_type"`
+	ProgramID       string                     `json:"program_id"`
+	Reason          AssessmentModerationReason `json:"reason" enums:"Sampled,FailingScore"`
+	Status          AssessmentModerationStatus `json:"status" enums:"Pending,Reconciled"`
+	MarkerID        string                     `json:"marker_id"`
+	MarkerName      string                     `json:"marker_name"`
+	ModeratorID     string                     `json:"moderator_id"`
+	ModeratorName   string                     `json:"moderator_name"`
+	ReconciledAt    int64                      `json:"reconciled_at"`
+	CreateAt        int64                      `json:"create_at"`
+}
+
+// AssessmentModerationDetailReply moderator view, original result next to the editable moderated one
+type AssessmentModerationDetailReply struct {
+	AssessmentModerationReply
+	Assessment *AssessmentDetailReply      `json:"assessment"`
+	Original   *AssessmentModerationResult `json:"original"`
+	// moderated starts as a copy of the original until the moderator saves a draft
+	Moderated *AssessmentModerationResult `json:"moderated"`
+	Final     *AssessmentModerationResult `json:"final,omitempty"`
+}
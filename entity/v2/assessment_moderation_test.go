@@ -0,0 +1,79 @@
+package v2
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAssessmentModerationPolicyIsSampled(t *testing.T) {
+	policy := &AssessmentModerationPolicy{SamplePercent: 30}
+
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("assessment-%d", i)
+		result := policy.IsSampled(id)
+		if result != policy.IsSampled(id) {
+			t.Fatalf("sampling of %s is not deterministic", id)
+		}
+		if result {
+			sampled++
+		}
+	}
+	if sampled < 250 || sampled > 350 {
+		t.Errorf("want about 30%% sampled, got %d of 1000", sampled)
+	}
+
+	if (&AssessmentModerationPolicy{SamplePercent: 0}).IsSampled("assessment-1") {
+		t.Errorf("0%% should never sample")
+	}
+	if !(&AssessmentModerationPolicy{SamplePercent: 100}).IsSampled("assessment-1") {
+		t.Errorf("100%% should always sample")
+	}
+}
+
+func TestAssessmentModerationPolicyReason(t *testing.T) {
+	detail := &AssessmentDetailReply{
+		ID:             "assessment-1",
+		AssessmentType: AssessmentTypeOnlineClass,
+		Contents: []*AssessmentContentReply{
+			{ContentID: "h5p-1", FileType: AssessmentFileTypeSupportScoreStandAlone, MaxScore: 10},
+		},
+		Students: []*AssessmentStudentReply{
+			{StudentID: "student-1", Status: AssessmentUserStatusParticipate, Results: []*AssessmentStudentResultReply{{ContentID: "h5p-1", Score: 8}}},
+			{StudentID: "student-2", Status: AssessmentUserStatusParticipate, Results: []*AssessmentStudentResultReply{{ContentID: "h5p-1", Score: 4}}},
+			{StudentID: "student-3", Status: AssessmentUserStatusNotParticipate},
+		},
+	}
+
+	policy := &AssessmentModerationPolicy{FailThreshold: 0.5}
+	if reason := policy.Reason(detail); reason != AssessmentModerationReasonFailingScore {
+		t.Errorf("want FailingScore, got %q", reason)
+	}
+
+	policy.FailThreshold = 0.4
+	if reason := policy.Reason(detail); reason != "" {
+		t.Errorf("no student is below 40%%, got %q", reason)
+	}
+
+	policy.SamplePercent = 100
+	if reason := policy.Reason(detail); reason != AssessmentModerationReasonSampled {
+		t.Errorf("want Sampled, got %q", reason)
+	}
+}
+
+func TestAssessmentModerationPolicySetReqValid(t *testing.T) {
+	tests := []struct {
+		req  AssessmentModerationPolicySetReq
+		want bool
+	}{
+		{AssessmentModerationPolicySetReq{ProgramID: "program-1", SamplePercent: 10, FailThreshold: 0.5}, true},
+		{AssessmentModerationPolicySetReq{ProgramID: "", SamplePercent: 10}, false},
+		{AssessmentModerationPolicySetReq{ProgramID: "program-1", SamplePercent: 101}, false},
+		{AssessmentModerationPolicySetReq{ProgramID: "program-1", FailThreshold: 1.5}, false},
+	}
+	for i, item := range tests {
+		if got := item.req.Valid(); got != item.want {
+			t.Errorf("case %d: want %v, got %v", i, item.want, got)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package v2
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// OneRoster 1.2 gradebook service, only the read part of lineItems/results/categories is supported
+
+const (
+	OneRosterGradebookPath = "/ims/oneroster/gradebook/v1p2"
+	OneRosterRosteringPath = "/ims/oneroster/rostering/v1p2"
+
+	OneRosterDefaultLimit = 100
+	OneRosterMaxLimit     = 1000
+
+	// categories are derived from assessment types and never change
+	oneRosterCategoriesModifiedAt = 1640995200
+)
+
+type OneRosterStatus string
+
+const (
+	OneRosterStatusActive      OneRosterStatus = "active"
+	OneRosterStatusToBeDeleted OneRosterStatus = "tobedeleted"
+)
+
+type OneRosterGUIDType string
+
+const (
+	OneRosterGUIDTypeClass    OneRosterGUIDType = "class"
+	OneRosterGUIDTypeOrg      OneRosterGUIDType = "org"
+	OneRosterGUIDTypeUser     OneRosterGUIDType = "user"
+	OneRosterGUIDTypeLineItem OneRosterGUIDType = "lineItem"
+	OneRosterGUIDTypeCategory OneRosterGUIDType = "category"
+)
+
+type OneRosterGUIDRef struct {
+	Href      string            `json:"href"`
+	SourcedID string            `json:"sourcedId"`
+	Type      OneRosterGUIDType `json:"type"`
+}
+
+type OneRosterLineItem struct {
+	SourcedID        string            `json:"sourcedId"`
+	Status           OneRosterStatus   `json:"status"`
+	DateLastModified string            `json:"dateLastModified"`
+	Title            string            `json:"title"`
+	AssignDate       string            `json:"assignDate"`
+	DueDate          string            `json:"dueDate"`
+	Class            *OneRosterGUIDRef `json:"class"`
+	School           *OneRosterGUIDRef `json:"school"`
+	Category         *OneRosterGUIDRef `json:"category"`
+	ResultValueMin   float64           `json:"resultValueMin"`
+	ResultValueMax   float64           `json:"resultValueMax"`
+}
+
+type OneRosterResult struct {
+	SourcedID        string                         `json:"sourcedId"`
+	Status           OneRosterStatus                `json:"status"`
+	DateLastModified string                         `json:"dateLastModified"`
+	Metadata         *OneRosterResultMetadata       `json:"metadata,omitempty"`
+	LineItem         *OneRosterGUIDRef              `json:"lineItem"`
+	Student          *OneRosterGUIDRef              `json:"student"`
+	Class            *OneRosterGUIDRef              `json:"class,omitempty"`
+	ScoreStatus      AssessmentGradebookScoreStatus `json:"scoreStatus"`
+	Score            *float64                       `json:"score,omitempty"`
+	ScoreDate        string                         `json:"scoreDate"`
+	Comment          string                         `json:"comment,omitempty"`
+}
+
+// OneRosterResultMetadata outcome summary, passed as OneRoster extension metadata
+type OneRosterResultMetadata struct {
+	OutcomesAchieved    int `json:"kidsloop.outcomesAchieved"`
+	OutcomesNotAchieved int `json:"kidsloop.outcomesNotAchieved"`
+	OutcomesTotal       int `json:"kidsloop.outcomesTotal"`
+}
+
+type OneRosterCategory struct {
+	SourcedID        string          `json:"sourcedId"`
+	Status           OneRosterStatus `json:"status"`
+	DateLastModified string          `json:"dateLastModified"`
+	Title            string          `json:"title"`
+}
+
+type OneRosterLineItemsReply struct {
+	LineItems []*OneRosterLineItem `json:"lineItems"`
+}
+
+type OneRosterLineItemReply struct {
+	LineItem *OneRosterLineItem `json:"lineItem"`
+}
+
+type OneRosterResultsReply struct {
+	Results []*OneRosterResult `json:"results"`
+}
+
+type OneRosterResultReply struct {
+	Result *OneRosterResult `json:"result"`
+}
+
+type OneRosterCategoriesReply struct {
+	Categories []*OneRosterCategory `json:"categories"`
+}
+
+type OneRosterCategoryReply struct {
+	Category *OneRosterCategory `json:"category"`
+}
+
+type OneRosterErrorReply struct {
+	CodeMajor   string              `json:"imsx_codeMajor"`
+	Severity    string              `json:"imsx_severity"`
+	Description string              `json:"imsx_description"`
+	CodeMinor   *OneRosterCodeMinor `json:"imsx_CodeMinor"`
+}
+
+type OneRosterCodeMinor struct {
+	Fields []*OneRosterCodeMinorField `json:"imsx_codeMinorField"`
+}
+
+type OneRosterCodeMinorField struct {
+	Name  string `json:"imsx_codeMinorFieldName"`
+	Value string `json:"imsx_codeMinorFieldValue"`
+}
+
+// OneRoster imsx_codeMinorFieldValue
+const (
+	OneRosterCodeMinorUnknownObject       = "unknownobject"
+	OneRosterCodeMinorInvalidData         = "invaliddata"
+	OneRosterCodeMinorUnauthorisedRequest = "unauthorisedrequest"
+	OneRosterCodeMinorForbidden           = "forbidden"
+	OneRosterCodeMinorServerBusy          = "server_busy"
+)
+
+func NewOneRosterErrorReply(codeMinor string, description string) *OneRosterErrorReply {
+	return &OneRosterErrorReply{
+		CodeMajor:   "failure",
+		Severity:    "error",
+		Description: description,
+		CodeMinor: &OneRosterCodeMinor{
+			Fields: []*OneRosterCodeMinorField{{
+				Name:  "TargetEndSystem",
+				Value: codeMinor,
+			}},
+		},
+	}
+}
+
+// OneRosterQuery paging and delta query shared by all collection endpoints
+type OneRosterQuery struct {
+	Limit  int `form:"limit"`
+	Offset int `form:"offset"`
+	// dateLastModified from filter=dateLastModified>'...', deleted records are returned when it is set
+	DateLastModifiedGt int64 `form:"-"`
+}
+
+var ErrOneRosterFilterNotSupported = errors.New("oneroster filter not supported")
+
+// ParseOneRosterFilter only the delta query filter dateLastModified>'2022-01-01T00:00:00.000Z' is supported
+func ParseOneRosterFilter(filter string) (int64, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return 0, nil
+	}
+
+	prefix := "dateLastModified>"
+	if !strings.HasPrefix(filter, prefix) {
+		return 0, ErrOneRosterFilterNotSupported
+	}
+
+	value := strings.Trim(strings.TrimSpace(filter[len(prefix):]), "'")
+	for _, layout := range []string{"2006-01-02T15:04:05.000Z", time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Unix(), nil
+		}
+	}
+
+	return 0, ErrOneRosterFilterNotSupported
+}
+
+// OneRosterDateTime OneRoster uses ISO 8601 in UTC
+func OneRosterDateTime(unix int64) string {
+	if unix <= 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func oneRosterStatus(deleteAt int64) OneRosterStatus {
+	if deleteAt > 0 {
+		return OneRosterStatusToBeDeleted
+	}
+	return OneRosterStatusActive
+}
+
+func oneRosterLastModified(updateAt int64, deleteAt int64) string {
+	if deleteAt > updateAt {
+		return OneRosterDateTime(deleteAt)
+	}
+	return OneRosterDateTime(updateAt)
+}
+
+func (l *AssessmentGradebookLineItem) ToOneRoster() *OneRosterLineItem {
+	result := &OneRosterLineItem{
+		SourcedID:        l.ID,
+		Status:           oneRosterStatus(l.DeleteAt),
+		DateLastModified: oneRosterLastModified(l.UpdateAt, l.DeleteAt),
+		Title:            l.Title,
+		AssignDate:       OneRosterDateTime(l.AssignAt),
+		DueDate:          OneRosterDateTime(l.DueAt),
+		Class: &OneRosterGUIDRef{
+			Href:      OneRosterRosteringPath + "/classes/" + l.ClassID,
+			SourcedID: l.ClassID,
+			Type:      OneRosterGUIDTypeClass,
+		},
+		School: &OneRosterGUIDRef{
+			Href:      OneRosterRosteringPath + "/schools/" + l.SchoolID,
+			SourcedID: l.SchoolID,
+			Type:      OneRosterGUIDTypeOrg,
+		},
+		Category: &OneRosterGUIDRef{
+			Href:      OneRosterGradebookPath + "/categories/" + l.AssessmentType.String(),
+			SourcedID: l.AssessmentType.String(),
+			Type:      OneRosterGUIDTypeCategory,
+		},
+		ResultValueMin: 0,
+		ResultValueMax: l.MaxScore,
+	}
+
+	// due date is required, assessments without due date are due when they are completed
+	if result.DueDate == "" {
+		result.DueDate = OneRosterDateTime(l.CompleteAt)
+	}
+	if result.AssignDate == "" {
+		result.AssignDate = OneRosterDateTime(l.CreateAt)
+	}
+
+	return result
+}
+
+func (r *AssessmentGradebookResult) ToOneRoster() *OneRosterResult {
+	result := &OneRosterResult{
+		SourcedID:        r.ID,
+		Status:           oneRosterStatus(r.DeleteAt),
+		DateLastModified: oneRosterLastModified(r.UpdateAt, r.DeleteAt),
+		Metadata: &OneRosterResultMetadata{
+			OutcomesAchieved:    r.OutcomesAchieved,
+			OutcomesNotAchieved: r.OutcomesNotAchieved,
+			OutcomesTotal:       r.OutcomesTotal,
+		},
+		LineItem: &OneRosterGUIDRef{
+			Href:      OneRosterGradebookPath + "/lineItems/" + r.LineItemID,
+			SourcedID: r.LineItemID,
+			Type:      OneRosterGUIDTypeLineItem,
+		},
+		Student: &OneRosterGUIDRef{
+			Href:      OneRosterRosteringPath + "/users/" + r.StudentID,
+			SourcedID: r.StudentID,
+			Type:      OneRosterGUIDTypeUser,
+		},
+		ScoreStatus: r.ScoreStatus,
+		ScoreDate:   OneRosterDateTime(r.ScoreAt),
+		Comment:     r.Comment,
+	}
+
+	if r.ClassID != "" {
+		result.Class = &OneRosterGUIDRef{
+			Href:      OneRosterRosteringPath + "/classes/" + r.ClassID,
+			SourcedID: r.ClassID,
+			Type:      OneRosterGUIDTypeClass,
+		}
+	}
+
+	if r.ScoreStatus == AssessmentGradebookScoreStatusFullyGraded {
+		score := r.Score
+		result.Score = &score
+	}
+
+	return result
+}
+
+// OneRosterCategories line items are categorized by assessment type
+func OneRosterCategories() []*OneRosterCategory {
+	types := []AssessmentType{
+		AssessmentTypeOnlineClass,
+		AssessmentTypeOfflineClass,
+		AssessmentTypeOnlineStudy,
+		AssessmentTypeOfflineStudy,
+		AssessmentTypeReviewStudy,
+	}
+
+	result := make([]*OneRosterCategory, 0, len(types))
+	for _, item := range types {
+		result = append(result, &OneRosterCategory{
+			SourcedID:        item.String(),
+			Status:           OneRosterStatusActive,
+			DateLastModified: OneRosterDateTime(oneRosterCategoriesModifiedAt),
+			Title:            item.String(),
+		})
+	}
+
+	return result
+}
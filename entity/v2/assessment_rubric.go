@@ -0,0 +1,290 @@
+package v2
+
+import (
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+type AssessmentRubric struct {
+	ID          string `gorm:"column:id;PRIMARY_KEY"`
+	OrgID       string `gorm:"org_id"`
+	Name        string `gorm:"name"`
+	Description string `gorm:"description"`
+	CreatorID   string `gorm:"creator_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (AssessmentRubric) TableName() string {
+	return constant.TableNameAssessmentRubricV2
+}
+
+type AssessmentRubricCriterion struct {
+	ID          string `gorm:"column:id;PRIMARY_KEY"`
+	RubricID    string `gorm:"rubric_id"`
+	Name        string `gorm:"name"`
+	Description string `gorm:"description"`
+	OutcomeID   string `gorm:"outcome_id"`
+	SortIndex   int    `gorm:"sort_index"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (AssessmentRubricCriterion) TableName() string {
+	return constant.TableNameAssessmentRubricCriterionV2
+}
+
+// AssessmentRubricLevel performance levels are shared by all criteria of a rubric
+type AssessmentRubricLevel struct {
+	ID          string `gorm:"column:id;PRIMARY_KEY"`
+	RubricID    string `gorm:"rubric_id"`
+	Name        string `gorm:"name"`
+	Description string `gorm:"description"`
+	Points      int    `gorm:"points"`
+	SortIndex   int    `gorm:"sort_index"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (AssessmentRubricLevel) TableName() string {
+	return constant.TableNameAssessmentRubricLevelV2
+}
+
+type AssessmentRubricRelation struct {
+	ID           string                       `gorm:"column:id;PRIMARY_KEY"`
+	OrgID        string                       `gorm:"org_id"`
+	RubricID     string                       `gorm:"rubric_id"`
+	RelationType AssessmentRubricRelationType `gorm:"relation_type"`
+	RelationID   string                       `gorm:"relation_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (AssessmentRubricRelation) TableName() string {
+	return constant.TableNameAssessmentRubricRelationV2
+}
+
+type AssessmentUserRubricScore struct {
+	ID               string `gorm:"column:id;PRIMARY_KEY"`
+	AssessmentUserID string `gorm:"assessment_user_id"`
+	RubricID         string `gorm:"rubric_id"`
+	CriterionID      string `gorm:"criterion_id"`
+	LevelID          string `gorm:"level_id"`
+	Points           int    `gorm:"points"`
+	ReviewerID       string `gorm:"reviewer_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (AssessmentUserRubricScore) TableName() string {
+	return constant.TableNameAssessmentUserRubricScoreV2
+}
+
+// LatestScoredRubricID the rubric of the latest score when students were scored with several rubrics,
+// ties are broken by rubric id so the choice doesn't depend on the order of scores
+func LatestScoredRubricID(scores []*AssessmentUserRubricScore) string {
+	var latest *AssessmentUserRubricScore
+	for _, item := range scores {
+		if item.RubricID == "" {
+			continue
+		}
+		if latest == nil || item.CreateAt > latest.CreateAt ||
+			(item.CreateAt == latest.CreateAt && item.RubricID < latest.RubricID) {
+			latest = item
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.RubricID
+}
+
+type AssessmentRubricRelationType string
+
+const (
+	AssessmentRubricRelationTypeLessonPlan AssessmentRubricRelationType = "LessonPlan"
+	AssessmentRubricRelationTypeSchedule   AssessmentRubricRelationType = "Schedule"
+)
+
+func (a AssessmentRubricRelationType) String() string {
+	return string(a)
+}
+
+func (a AssessmentRubricRelationType) Valid() bool {
+	switch a {
+	case AssessmentRubricRelationTypeLessonPlan, AssessmentRubricRelationTypeSchedule:
+		return true
+	}
+	return false
+}
+
+type AssessmentRubricQueryReq struct {
+	Name      string `form:"name"`
+	OrderBy   string `form:"order_by"`
+	PageIndex int    `form:"page"`
+	PageSize  int    `form:"page_size"`
+}
+
+type AssessmentRubricAddReq struct {
+	Name        string                          `json:"name"`
+	Description string                          `json:"description"`
+	Criteria    []*AssessmentRubricCriterionReq `json:"criteria"`
+	Levels      []*AssessmentRubricLevelReq     `json:"levels"`
+}
+
+type AssessmentRubricUpdateReq struct {
+	ID string `json:"-"`
+	AssessmentRubricAddReq
+}
+
+type AssessmentRubricCriterionReq struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	OutcomeID   string `json:"outcome_id"`
+}
+
+type AssessmentRubricLevelReq struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Points      int    `json:"points"`
+}
+
+type AssessmentRubricAttachReq struct {
+	RubricID     string                       `json:"rubric_id"`
+	RelationType AssessmentRubricRelationType `json:"relation_type" enums:"LessonPlan,Schedule"`
+	RelationID   string                       `json:"relation_id"`
+}
+
+type AssessmentRubricPageReply struct {
+	Total int                      `json:"total"`
+	Data  []*AssessmentRubricReply `json:"data"`
+}
+
+type AssessmentRubricReply struct {
+	ID          string                            `json:"id"`
+	Name        string                            `json:"name"`
+	Description string                            `json:"description"`
+	CreatorID   string                            `json:"creator_id"`
+	MaxPoints   int                               `json:"max_points"`
+	Criteria    []*AssessmentRubricCriterionReply `json:"criteria"`
+	Levels      []*AssessmentRubricLevelReply     `json:"levels"`
+	CreateAt    int64                             `json:"create_at"`
+	UpdateAt    int64                             `json:"update_at"`
+}
+
+type AssessmentRubricCriterionReply struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	OutcomeID   string `json:"outcome_id"`
+}
+
+type AssessmentRubricLevelReply struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Points      int    `json:"points"`
+}
+
+type AssessmentStudentRubricScoreReq struct {
+	CriterionID string `json:"criterion_id"`
+	LevelID     string `json:"level_id"`
+}
+
+type AssessmentStudentRubricReply struct {
+	RubricID    string                               `json:"rubric_id"`
+	TotalPoints int                                  `json:"total_points"`
+	MaxPoints   int                                  `json:"max_points"`
+	IsCompleted bool                                 `json:"is_completed"`
+	Scores      []*AssessmentStudentRubricScoreReply `json:"scores"`
+}
+
+type AssessmentStudentRubricScoreReply struct {
+	CriterionID string `json:"criterion_id"`
+	LevelID     string `json:"level_id"`
+	Points      int    `json:"points"`
+}
+
+// AssessmentRubricDetail rubric with its criteria and levels, used when scoring
+type AssessmentRubricDetail struct {
+	Rubric   *AssessmentRubric
+	Criteria []*AssessmentRubricCriterion
+	Levels   []*AssessmentRubricLevel
+}
+
+func (d *AssessmentRubricDetail) MaxLevelPoints() int {
+	result := 0
+	for _, item := range d.Levels {
+		if item.Points > result {
+			result = item.Points
+		}
+	}
+	return result
+}
+
+func (d *AssessmentRubricDetail) MaxPoints() int {
+	return d.MaxLevelPoints() * len(d.Criteria)
+}
+
+// IsCompleted a student is fully scored when every criterion has a score
+func (d *AssessmentRubricDetail) IsCompleted(scores []*AssessmentUserRubricScore) bool {
+	if len(d.Criteria) <= 0 {
+		return false
+	}
+
+	scoredMap := make(map[string]struct{}, len(scores))
+	for _, item := range scores {
+		scoredMap[item.CriterionID] = struct{}{}
+	}
+	for _, item := range d.Criteria {
+		if _, ok := scoredMap[item.ID]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d *AssessmentRubricDetail) TotalPoints(scores []*AssessmentUserRubricScore) int {
+	criterionMap := make(map[string]struct{}, len(d.Criteria))
+	for _, item := range d.Criteria {
+		criterionMap[item.ID] = struct{}{}
+	}
+
+	total := 0
+	for _, item := range scores {
+		if _, ok := criterionMap[item.CriterionID]; ok {
+			total += item.Points
+		}
+	}
+
+	return total
+}
+
+// OutcomeStatus derive outcome status from the level selected on a criterion,
+// the ratio of points to the highest level is compared with the outcome score threshold
+func (d *AssessmentRubricDetail) OutcomeStatus(points int, scoreThreshold float32, assumed bool) AssessmentUserOutcomeStatus {
+	if assumed {
+		return AssessmentUserOutcomeStatusAchieved
+	}
+
+	maxLevelPoints := d.MaxLevelPoints()
+	if maxLevelPoints <= 0 {
+		return AssessmentUserOutcomeStatusUnknown
+	}
+
+	if float32(points)/float32(maxLevelPoints) >= scoreThreshold {
+		return AssessmentUserOutcomeStatusAchieved
+	}
+
+	return AssessmentUserOutcomeStatusNotAchieved
+}
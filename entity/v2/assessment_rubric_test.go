@@ -0,0 +1,78 @@
+package v2
+
+import "testing"
+
+func TestAssessmentRubricDetail(t *testing.T) {
+	rubric := &AssessmentRubricDetail{
+		Rubric: &AssessmentRubric{ID: "rubric"},
+		Criteria: []*AssessmentRubricCriterion{
+			{ID: "c1", RubricID: "rubric"},
+			{ID: "c2", RubricID: "rubric", OutcomeID: "o1"},
+		},
+		Levels: []*AssessmentRubricLevel{
+			{ID: "l1", RubricID: "rubric", Points: 0},
+			{ID: "l2", RubricID: "rubric", Points: 2},
+			{ID: "l3", RubricID: "rubric", Points: 4},
+		},
+	}
+
+	if rubric.MaxPoints() != 8 {
+		t.Errorf("max points want 8, got %d", rubric.MaxPoints())
+	}
+
+	scores := []*AssessmentUserRubricScore{
+		{CriterionID: "c1", LevelID: "l3", Points: 4},
+		{CriterionID: "other", LevelID: "l3", Points: 4},
+	}
+	if rubric.IsCompleted(scores) {
+		t.Error("student should not be completed when a criterion is not scored")
+	}
+	if rubric.TotalPoints(scores) != 4 {
+		t.Errorf("total points want 4, got %d", rubric.TotalPoints(scores))
+	}
+
+	scores = append(scores, &AssessmentUserRubricScore{CriterionID: "c2", LevelID: "l2", Points: 2})
+	if !rubric.IsCompleted(scores) {
+		t.Error("student should be completed when all criteria are scored")
+	}
+	if rubric.TotalPoints(scores) != 6 {
+		t.Errorf("total points want 6, got %d", rubric.TotalPoints(scores))
+	}
+
+	tests := []struct {
+		points    int
+		threshold float32
+		assumed   bool
+		want      AssessmentUserOutcomeStatus
+	}{
+		{points: 2, threshold: 0.5, want: AssessmentUserOutcomeStatusAchieved},
+		{points: 2, threshold: 0.8, want: AssessmentUserOutcomeStatusNotAchieved},
+		{points: 0, threshold: 0.8, assumed: true, want: AssessmentUserOutcomeStatusAchieved},
+	}
+	for _, tt := range tests {
+		if got := rubric.OutcomeStatus(tt.points, tt.threshold, tt.assumed); got != tt.want {
+			t.Errorf("OutcomeStatus(%d, %v, %v) want %s, got %s", tt.points, tt.threshold, tt.assumed, tt.want, got)
+		}
+	}
+}
+
+func TestLatestScoredRubricID(t *testing.T) {
+	scores := []*AssessmentUserRubricScore{
+		{RubricID: "r2", CreateAt: 100},
+		{RubricID: "r3", CreateAt: 200},
+		{RubricID: "r1", CreateAt: 200},
+		{RubricID: "", CreateAt: 300},
+	}
+	if id := LatestScoredRubricID(scores); id != "r1" {
+		t.Errorf("want r1, got %s", id)
+	}
+
+	scores[0], scores[2] = scores[2], scores[0]
+	if id := LatestScoredRubricID(scores); id != "r1" {
+		t.Errorf("choice depends on the order of scores, got %s", id)
+	}
+
+	if id := LatestScoredRubricID(nil); id != "" {
+		t.Errorf("want empty, got %s", id)
+	}
+}
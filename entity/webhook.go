@@ -0,0 +1,332 @@
+package entity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+type WebhookEventType string
+
+const (
+	WebhookEventContentPublished    WebhookEventType = "content.published"
+	WebhookEventContentDeleted      WebhookEventType = "content.deleted"
+	WebhookEventScheduleCreated     WebhookEventType = "schedule.created"
+	WebhookEventScheduleUpdated     WebhookEventType = "schedule.updated"
+	WebhookEventScheduleCancelled   WebhookEventType = "schedule.cancelled"
+	WebhookEventAssessmentCompleted WebhookEventType = "assessment.completed"
+	WebhookEventOutcomeApproved     WebhookEventType = "outcome.approved"
+	WebhookEventStudentRiskChanged  WebhookEventType = "student.risk_changed"
+)
+
+func (t WebhookEventType) Valid() bool {
+	switch t {
+	case WebhookEventContentPublished, WebhookEventContentDeleted,
+		WebhookEventScheduleCreated, WebhookEventScheduleUpdated, WebhookEventScheduleCancelled,
+		WebhookEventAssessmentCompleted, WebhookEventOutcomeApproved, WebhookEventStudentRiskChanged:
+		return true
+	}
+	return false
+}
+
+// Webhook registered by an organization, the secret signs every delivery
+type Webhook struct {
+	ID         string `gorm:"column:id;PRIMARY_KEY"`
+	OrgID      string `gorm:"column:org_id"`
+	Name       string `gorm:"column:name"`
+	URL        string `gorm:"column:url"`
+	Secret     string `gorm:"column:secret"`
+	EventTypes string `gorm:"column:event_types"`
+	Enabled    bool   `gorm:"column:enabled"`
+	CreatorID  string `gorm:"column:creator_id"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (Webhook) TableName() string {
+	return constant.TableNameWebhook
+}
+
+func (w *Webhook) GetEventTypes() []WebhookEventType {
+	if w.EventTypes == "" {
+		return []WebhookEventType{}
+	}
+
+	parts := strings.Split(w.EventTypes, constant.StringArraySeparator)
+	result := make([]WebhookEventType, len(parts))
+	for i := range parts {
+		result[i] = WebhookEventType(parts[i])
+	}
+	return result
+}
+
+func (w *Webhook) SetEventTypes(eventTypes []WebhookEventType) {
+	parts := make([]string, len(eventTypes))
+	for i := range eventTypes {
+		parts[i] = string(eventTypes[i])
+	}
+	w.EventTypes = strings.Join(parts, constant.StringArraySeparator)
+}
+
+func (w *Webhook) Subscribed(eventType WebhookEventType) bool {
+	if !w.Enabled {
+		return false
+	}
+
+	for _, item := range w.GetEventTypes() {
+		if item == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvent body posted to the webhook url
+type WebhookEvent struct {
+	ID         string           `json:"id"`
+	Type       WebhookEventType `json:"type"`
+	OrgID      string           `json:"org_id"`
+	OccurredAt int64            `json:"occurred_at"`
+	Data       interface{}      `json:"data"`
+}
+
+type WebhookContentData struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	ContentType   int    `json:"content_type"`
+	PublishStatus string `json:"publish_status"`
+	AuthorID      string `json:"author_id"`
+}
+
+type WebhookScheduleData struct {
+	ID string `json:"id"`
+	// PreviousID schedules are recreated when updated, this is the id before the update
+	PreviousID string `json:"previous_id,omitempty"`
+	RepeatID   string `json:"repeat_id,omitempty"`
+	// EditType with_following means the following schedules of the repeat are changed too
+	EditType  string `json:"edit_type,omitempty"`
+	Title     string `json:"title"`
+	ClassType string `json:"class_type"`
+	ClassID   string `json:"class_id"`
+	StartAt   int64  `json:"start_at"`
+	EndAt     int64  `json:"end_at"`
+	DueAt     int64  `json:"due_at"`
+	Status    string `json:"status"`
+}
+
+type WebhookAssessmentData struct {
+	ID             string `json:"id"`
+	ScheduleID     string `json:"schedule_id"`
+	AssessmentType string `json:"assessment_type"`
+	Title          string `json:"title"`
+	CompleteAt     int64  `json:"complete_at"`
+	CompleteBy     string `json:"complete_by"`
+}
+
+type WebhookOutcomeData struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Shortcode string `json:"shortcode"`
+}
+
+const (
+	WebhookHeaderEvent     = "X-KidsLoop-Event"
+	WebhookHeaderDelivery  = "X-KidsLoop-Delivery"
+	WebhookHeaderSignature = "X-KidsLoop-Signature"
+)
+
+// WebhookSignature receivers recompute hex(hmac_sha256(secret, "<t>.<body>")) and compare it with v1,
+// the timestamp lets them reject replayed deliveries
+func WebhookSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d.", timestamp)))
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryStatusPending waiting for the first attempt or a retry
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "Pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "Succeeded"
+	// WebhookDeliveryStatusDead all attempts failed, only re-sent manually
+	WebhookDeliveryStatusDead WebhookDeliveryStatus = "Dead"
+)
+
+func (s WebhookDeliveryStatus) Valid() bool {
+	switch s {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusSucceeded, WebhookDeliveryStatusDead:
+		return true
+	}
+	return false
+}
+
+// WebhookDelivery delivery log, one per webhook and event
+type WebhookDelivery struct {
+	ID             string                `gorm:"column:id;PRIMARY_KEY"`
+	WebhookID      string                `gorm:"column:webhook_id"`
+	OrgID          string                `gorm:"column:org_id"`
+	EventID        string                `gorm:"column:event_id"`
+	EventType      WebhookEventType      `gorm:"column:event_type"`
+	Payload        string                `gorm:"column:payload"`
+	Status         WebhookDeliveryStatus `gorm:"column:status"`
+	Attempts       int                   `gorm:"column:attempts"`
+	ResponseStatus int                   `gorm:"column:response_status"`
+	ResponseBody   string                `gorm:"column:response_body"`
+	LastError      string                `gorm:"column:last_error"`
+	NextAttemptAt  int64                 `gorm:"column:next_attempt_at;type:bigint"`
+	DeliveredAt    int64                 `gorm:"column:delivered_at;type:bigint"`
+
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+	DeleteAt int64 `gorm:"column:delete_at;type:bigint"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return constant.TableNameWebhookDelivery
+}
+
+func (d *WebhookDelivery) Succeed(responseStatus int, responseBody string, now int64) {
+	d.Attempts++
+	d.Status = WebhookDeliveryStatusSucceeded
+	d.ResponseStatus = responseStatus
+	d.ResponseBody = responseBody
+	d.LastError = ""
+	d.DeliveredAt = now
+	d.UpdateAt = now
+}
+
+// Fail record a failed attempt, the delivery is retried with exponential backoff until max attempts
+func (d *WebhookDelivery) Fail(responseStatus int, responseBody string, reason string, maxAttempts int, backoff time.Duration, now int64) {
+	d.Attempts++
+	d.ResponseStatus = responseStatus
+	d.ResponseBody = responseBody
+	d.LastError = reason
+	d.UpdateAt = now
+
+	if d.Attempts >= maxAttempts {
+		d.Status = WebhookDeliveryStatusDead
+		return
+	}
+
+	d.Status = WebhookDeliveryStatusPending
+	d.NextAttemptAt = now + int64(RetryDelay(backoff, d.Attempts).Seconds())
+}
+
+const webhookMaxNameLength = 128
+
+type WebhookAddReq struct {
+	Name       string             `json:"name"`
+	URL        string             `json:"url"`
+	EventTypes []WebhookEventType `json:"event_types" enums:"content.published,content.deleted,schedule.created,schedule.updated,schedule.cancelled,assessment.completed,outcome.approved,student.risk_changed"`
+}
+
+// Valid only https urls are accepted, payloads contain organization data.
+// Hosts which are ip addresses must be public, names are resolved and checked by the caller
+func (r *WebhookAddReq) Valid() bool {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > webhookMaxNameLength {
+		return false
+	}
+
+	u, err := url.Parse(r.URL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return false
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil && !IsWebhookPublicIP(ip) {
+		return false
+	}
+
+	if len(r.EventTypes) <= 0 {
+		return false
+	}
+	for _, item := range r.EventTypes {
+		if !item.Valid() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// carrier-grade nat, it is not covered by net.IP.IsPrivate
+var webhookSharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsWebhookPublicIP webhooks must not reach loopback, private, link-local (cloud metadata) or other internal addresses
+func IsWebhookPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		if ip4[0] == 0 || webhookSharedAddressSpace.Contains(ip4) {
+			return false
+		}
+	}
+
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+type WebhookUpdateReq struct {
+	ID string `json:"-"`
+	WebhookAddReq
+	Enabled bool `json:"enabled"`
+}
+
+type WebhookReply struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	URL        string             `json:"url"`
+	EventTypes []WebhookEventType `json:"event_types"`
+	Enabled    bool               `json:"enabled"`
+	CreatorID  string             `json:"creator_id"`
+	CreateAt   int64              `json:"create_at"`
+	UpdateAt   int64              `json:"update_at"`
+}
+
+// WebhookCreatedReply the secret is only returned once, when the webhook is created
+type WebhookCreatedReply struct {
+	WebhookReply
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryQueryReq struct {
+	WebhookID string                `form:"webhook_id"`
+	EventType WebhookEventType      `form:"event_type"`
+	Status    WebhookDeliveryStatus `form:"status"`
+	PageIndex int                   `form:"page"`
+	PageSize  int                   `form:"page_size"`
+}
+
+type WebhookDeliveryView struct {
+	ID             string                `json:"id"`
+	WebhookID      string                `json:"webhook_id"`
+	EventID        string                `json:"event_id"`
+	EventType      WebhookEventType      `json:"event_type"`
+	Payload        string                `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" enums:"Pending,Succeeded,Dead"`
+	Attempts       int                   `json:"attempts"`
+	ResponseStatus int                   `json:"response_status"`
+	ResponseBody   string                `json:"response_body"`
+	LastError      string                `json:"last_error"`
+	NextAttemptAt  int64                 `json:"next_attempt_at"`
+	DeliveredAt    int64                 `json:"delivered_at"`
+	CreateAt       int64                 `json:"create_at"`
+}
+
+type WebhookDeliveryPageReply struct {
+	Total int                    `json:"total"`
+	Data  []*WebhookDeliveryView `json:"data"`
+}
@@ -0,0 +1,92 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	// echo -n '1600000000.{"id":"1"}' | openssl dgst -sha256 -hmac secret
+	want := "t=1600000000,v1=3831eb7dbf183fdbdf6145e3aa0b7029f210195f352de3815ebec7b67268edbc"
+	got := WebhookSignature("secret", 1600000000, []byte(`{"id":"1"}`))
+	if got != want {
+		t.Errorf("WebhookSignature() = %s, want %s", got, want)
+	}
+
+	if WebhookSignature("other", 1600000000, []byte(`{"id":"1"}`)) == got {
+		t.Error("signature should depend on the secret")
+	}
+	if WebhookSignature("secret", 1600000001, []byte(`{"id":"1"}`)) == got {
+		t.Error("signature should depend on the timestamp")
+	}
+}
+
+func TestWebhookAddReqValid(t *testing.T) {
+	tests := []struct {
+		name string
+		req  WebhookAddReq
+		want bool
+	}{
+		{"valid", WebhookAddReq{Name: " hook ", URL: "https://example.com/hook", EventTypes: []WebhookEventType{WebhookEventContentPublished}}, true},
+		{"empty name", WebhookAddReq{Name: " ", URL: "https://example.com/hook", EventTypes: []WebhookEventType{WebhookEventContentPublished}}, false},
+		{"http", WebhookAddReq{Name: "hook", URL: "http://example.com/hook", EventTypes: []WebhookEventType{WebhookEventContentPublished}}, false},
+		{"no host", WebhookAddReq{Name: "hook", URL: "https:///hook", EventTypes: []WebhookEventType{WebhookEventContentPublished}}, false},
+		{"no events", WebhookAddReq{Name: "hook", URL: "https://example.com/hook"}, false},
+		{"unknown event", WebhookAddReq{Name: "hook", URL: "https://example.com/hook", EventTypes: []WebhookEventType{"content.updated"}}, false},
+		{"public ip", WebhookAddReq{Name: "hook", URL: "https://93.184.216.34:8443/hook", EventTypes: []WebhookEventType{WebhookEventContentPublished}}, true},
+		{"localhost", WebhookAddReq{Name: "hook", URL: "https://localhost/hook", EventTypes: []WebhookEventType{WebhookEventContentPublished}}, false},
+		{"loopback", WebhookAddReq{Name: "hook", URL: "https://127.0.0.1/hook", EventTypes: []WebhookEventType{WebhookEventContentPublished}}, false},
+		{"metadata", WebhookAddReq{Name: "hook", URL: "https://169.254.169.254/latest", EventTypes: []WebhookEventType{WebhookEventContentPublished}}, false},
+		{"private", WebhookAddReq{Name: "hook", URL: "https://10.0.0.8/hook", EventTypes: []WebhookEventType{WebhookEventContentPublished}}, false},
+		{"private v6", WebhookAddReq{Name: "hook", URL: "https://[fd00::1]/hook", EventTypes: []WebhookEventType{WebhookEventContentPublished}}, false},
+		{"mapped loopback", WebhookAddReq{Name: "hook", URL: "https://[::ffff:127.0.0.1]/hook", EventTypes: []WebhookEventType{WebhookEventContentPublished}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.req.Valid(); got != tt.want {
+				t.Errorf("Valid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSubscribed(t *testing.T) {
+	webhook := &Webhook{Enabled: true}
+	webhook.SetEventTypes([]WebhookEventType{WebhookEventScheduleCreated, WebhookEventScheduleCancelled})
+
+	if got := webhook.GetEventTypes(); len(got) != 2 || got[1] != WebhookEventScheduleCancelled {
+		t.Fatalf("GetEventTypes() = %v", got)
+	}
+	if !webhook.Subscribed(WebhookEventScheduleCancelled) {
+		t.Error("should subscribe schedule.cancelled")
+	}
+	if webhook.Subscribed(WebhookEventScheduleUpdated) {
+		t.Error("should not subscribe schedule.updated")
+	}
+
+	webhook.Enabled = false
+	if webhook.Subscribed(WebhookEventScheduleCancelled) {
+		t.Error("disabled webhook should not subscribe anything")
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	delivery := &WebhookDelivery{Status: WebhookDeliveryStatusPending}
+
+	delivery.Fail(500, "oops", "unexpected status 500", 2, time.Minute, 1000)
+	if delivery.Status != WebhookDeliveryStatusPending || delivery.Attempts != 1 || delivery.NextAttemptAt != 1060 || delivery.ResponseStatus != 500 {
+		t.Fatalf("first failure should be retried: %+v", delivery)
+	}
+
+	delivery.Succeed(200, "ok", 1100)
+	if delivery.Status != WebhookDeliveryStatusSucceeded || delivery.Attempts != 2 || delivery.DeliveredAt != 1100 || delivery.LastError != "" {
+		t.Fatalf("delivery should succeed: %+v", delivery)
+	}
+
+	delivery = &WebhookDelivery{Status: WebhookDeliveryStatusPending, Attempts: 1}
+	delivery.Fail(0, "", "timeout", 2, time.Minute, 1000)
+	if delivery.Status != WebhookDeliveryStatusDead || delivery.Attempts != 2 {
+		t.Errorf("last failure should be dead: %+v", delivery)
+	}
+}
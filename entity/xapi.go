@@ -0,0 +1,605 @@
+package entity
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+const (
+	XAPIVersion       = "1.0.3"
+	XAPIHeaderVersion = "X-Experience-API-Version"
+)
+
+const (
+	XAPIVerbExperienced = "http://adlnet.gov/expapi/verbs/experienced"
+	XAPIVerbAttended    = "http://adlnet.gov/expapi/verbs/attended"
+	XAPIVerbCompleted   = "http://adlnet.gov/expapi/verbs/completed"
+	XAPIVerbAchieved    = "https://w3id.org/xapi/dod-isd/verbs/achieved"
+)
+
+const (
+	XAPIActivityTypeMedia      = "http://adlnet.gov/expapi/activities/media"
+	XAPIActivityTypeLesson     = "http://adlnet.gov/expapi/activities/lesson"
+	XAPIActivityTypeMeeting    = "http://adlnet.gov/expapi/activities/meeting"
+	XAPIActivityTypeCourse     = "http://adlnet.gov/expapi/activities/course"
+	XAPIActivityTypeAssessment = "http://adlnet.gov/expapi/activities/assessment"
+	XAPIActivityTypeObjective  = "http://adlnet.gov/expapi/activities/objective"
+)
+
+const xapiLanguage = "en-US"
+
+var xapiVerbDisplays = map[string]string{
+	XAPIVerbExperienced: "experienced",
+	XAPIVerbAttended:    "attended",
+	XAPIVerbCompleted:   "completed",
+	XAPIVerbAchieved:    "achieved",
+}
+
+// xapiNamespace generated statements have name based ids, a statement generated twice is stored once
+var xapiNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://kidsloop.net/cms/xapi/statements"))
+
+type XAPILanguageMap map[string]string
+
+type XAPIAccount struct {
+	HomePage string `json:"homePage"`
+	Name     string `json:"name"`
+}
+
+type XAPIAgent struct {
+	ObjectType  string       `json:"objectType,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	Mbox        string       `json:"mbox,omitempty"`
+	MboxSha1Sum string       `json:"mbox_sha1sum,omitempty"`
+	OpenID      string       `json:"openid,omitempty"`
+	Account     *XAPIAccount `json:"account,omitempty"`
+}
+
+// Key the identifier of the agent, statements are queried by it
+func (a *XAPIAgent) Key() string {
+	switch {
+	case a == nil:
+		return ""
+	case a.Mbox != "":
+		return a.Mbox
+	case a.MboxSha1Sum != "":
+		return "mbox_sha1sum:" + a.MboxSha1Sum
+	case a.OpenID != "":
+		return a.OpenID
+	case a.Account != nil && a.Account.HomePage != "" && a.Account.Name != "":
+		return "account:" + a.Account.HomePage + "|" + a.Account.Name
+	}
+	return ""
+}
+
+type XAPIVerb struct {
+	ID      string          `json:"id"`
+	Display XAPILanguageMap `json:"display,omitempty"`
+}
+
+type XAPIActivityDefinition struct {
+	Name       XAPILanguageMap        `json:"name,omitempty"`
+	Type       string                 `json:"type,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+type XAPIActivity struct {
+	ObjectType string                  `json:"objectType,omitempty"`
+	ID         string                  `json:"id"`
+	Definition *XAPIActivityDefinition `json:"definition,omitempty"`
+}
+
+type XAPIResult struct {
+	Success    *bool  `json:"success,omitempty"`
+	Completion *bool  `json:"completion,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+}
+
+type XAPIContextActivities struct {
+	Parent   []*XAPIActivity `json:"parent,omitempty"`
+	Grouping []*XAPIActivity `json:"grouping,omitempty"`
+}
+
+type XAPIContext struct {
+	Platform          string                 `json:"platform,omitempty"`
+	ContextActivities *XAPIContextActivities `json:"contextActivities,omitempty"`
+	Extensions        map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// XAPIStatement the properties of a statement used by cms, statements posted to cms keep the others as they are.
+// Only activities and statement references are accepted as objects
+type XAPIStatement struct {
+	ID        string        `json:"id,omitempty"`
+	Actor     *XAPIAgent    `json:"actor"`
+	Verb      *XAPIVerb     `json:"verb"`
+	Object    *XAPIActivity `json:"object"`
+	Result    *XAPIResult   `json:"result,omitempty"`
+	Context   *XAPIContext  `json:"context,omitempty"`
+	Timestamp string        `json:"timestamp,omitempty"`
+	Stored    string        `json:"stored,omitempty"`
+}
+
+// InvalidReason the reason a statement is rejected, empty when it is valid
+func (s *XAPIStatement) InvalidReason() string {
+	switch {
+	case s.ID != "" && !isXAPIUUID(s.ID):
+		return "id must be a uuid"
+	case s.Actor.Key() == "":
+		return "actor must have an identifier"
+	case s.Verb == nil || !isXAPIIRI(s.Verb.ID):
+		return "verb id must be an iri"
+	case s.Object == nil || s.Object.ID == "":
+		return "object must be an activity or a statement reference"
+	case s.Object.ObjectType == "StatementRef" && !isXAPIUUID(s.Object.ID):
+		return "id of statement reference must be a uuid"
+	case s.Object.ObjectType != "StatementRef" && !isXAPIIRI(s.Object.ID):
+		return "activity id must be an iri"
+	}
+	if s.Timestamp != "" {
+		if _, err := ParseXAPITime(s.Timestamp); err != nil {
+			return "timestamp must be an iso 8601 time"
+		}
+	}
+	return ""
+}
+
+func isXAPIUUID(s string) bool {
+	_, err := uuid.Parse(s)
+	return err == nil
+}
+
+func isXAPIIRI(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != ""
+}
+
+// ParseXAPITime parses the iso 8601 times of statements and queries
+func ParseXAPITime(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// FormatXAPITime utc with milliseconds
+func FormatXAPITime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+// ParseXAPIAgentKey the key of the agent in the json of the agent parameter of queries
+func ParseXAPIAgentKey(s string) (string, error) {
+	agent := new(XAPIAgent)
+	if err := json.Unmarshal([]byte(s), agent); err != nil {
+		return "", err
+	}
+	key := agent.Key()
+	if key == "" {
+		return "", fmt.Errorf("agent has no identifier")
+	}
+	return key, nil
+}
+
+// xapiDuration iso 8601 duration of seconds
+func xapiDuration(seconds int) string {
+	return fmt.Sprintf("PT%dS", seconds)
+}
+
+// xapiUnixTime usage records of live clients carry milliseconds, others seconds
+func xapiUnixTime(timestamp int64) time.Time {
+	if timestamp > 1e12 {
+		return time.Unix(0, timestamp*int64(time.Millisecond))
+	}
+	return time.Unix(timestamp, 0)
+}
+
+func xapiBool(b bool) *bool {
+	return &b
+}
+
+// XAPIStatementBuilder builds the statements of activities in cms,
+// actors are accounts of the home page and activity ids start with it
+type XAPIStatementBuilder struct {
+	HomePage string
+	// Secret is part of the names of the ids, so clients can't store a statement with the id of one generated later
+	Secret string
+}
+
+func (b XAPIStatementBuilder) statementID(parts ...string) string {
+	name := strings.Join(append([]string{b.Secret}, parts...), "\n")
+	return uuid.NewSHA1(xapiNamespace, []byte(name)).String()
+}
+
+func (b XAPIStatementBuilder) agent(userID string) *XAPIAgent {
+	return &XAPIAgent{
+		ObjectType: "Agent",
+		Account: &XAPIAccount{
+			HomePage: b.HomePage,
+			Name:     userID,
+		},
+	}
+}
+
+func (b XAPIStatementBuilder) verb(id string) *XAPIVerb {
+	return &XAPIVerb{
+		ID:      id,
+		Display: XAPILanguageMap{xapiLanguage: xapiVerbDisplays[id]},
+	}
+}
+
+func (b XAPIStatementBuilder) activity(kind, id, activityType, name string) *XAPIActivity {
+	activity := &XAPIActivity{
+		ObjectType: "Activity",
+		ID:         fmt.Sprintf("%s/xapi/activities/%s/%s", b.HomePage, kind, url.PathEscape(id)),
+		Definition: &XAPIActivityDefinition{
+			Type: activityType,
+		},
+	}
+	if name != "" {
+		activity.Definition.Name = XAPILanguageMap{xapiLanguage: name}
+	}
+	return activity
+}
+
+func (b XAPIStatementBuilder) extension(name string) string {
+	return fmt.Sprintf("%s/xapi/extensions/%s", b.HomePage, name)
+}
+
+func (b XAPIStatementBuilder) context(orgID string, parent []*XAPIActivity, grouping []*XAPIActivity, extensions map[string]interface{}) *XAPIContext {
+	result := &XAPIContext{
+		Platform: constant.XAPIPlatform,
+		Extensions: map[string]interface{}{
+			b.extension("org_id"): orgID,
+		},
+	}
+	for name, value := range extensions {
+		result.Extensions[b.extension(name)] = value
+	}
+	if len(parent) > 0 || len(grouping) > 0 {
+		result.ContextActivities = &XAPIContextActivities{
+			Parent:   parent,
+			Grouping: grouping,
+		}
+	}
+	return result
+}
+
+// MaterialViewed a student viewed a material of a lesson plan, record is the usage record of one student
+func (b XAPIStatementBuilder) MaterialViewed(orgID string, record *StudentUsageRecord) *XAPIStatement {
+	object := b.activity("materials", record.LessonMaterialID, XAPIActivityTypeMedia, "")
+	if record.LessonMaterialID == "" {
+		// the url is not one of the materials of the lesson plan
+		hash := sha1.Sum([]byte(strings.TrimSpace(record.LessonMaterialUrl)))
+		object = b.activity("material_urls", hex.EncodeToString(hash[:]), XAPIActivityTypeMedia, "")
+		object.Definition.Extensions = map[string]interface{}{
+			b.extension("lesson_material_url"): record.LessonMaterialUrl,
+		}
+	}
+
+	var grouping []*XAPIActivity
+	if record.ClassID != "" {
+		grouping = append(grouping, b.activity("classes", record.ClassID, XAPIActivityTypeCourse, ""))
+	}
+	grouping = append(grouping, b.activity("schedules", record.RoomID, XAPIActivityTypeMeeting, ""))
+
+	return &XAPIStatement{
+		ID:     b.statementID("experienced", record.IngestKey()),
+		Actor:  b.agent(record.StudentUserID),
+		Verb:   b.verb(XAPIVerbExperienced),
+		Object: object,
+		Context: b.context(orgID,
+			[]*XAPIActivity{b.activity("lesson_plans", record.LessonPlanID, XAPIActivityTypeLesson, "")},
+			grouping,
+			map[string]interface{}{
+				"content_type": record.ContentType,
+				"class_type":   record.ClassType,
+			}),
+		Timestamp: FormatXAPITime(xapiUnixTime(record.Timestamp)),
+	}
+}
+
+// XAPIClassAttendance a user attended a class, one for each user of the attendance of a live room
+type XAPIClassAttendance struct {
+	OrgID      string
+	ScheduleID string
+	Title      string
+	ClassType  string
+	ClassID    string
+	UserID     string
+	// ClassLength seconds
+	ClassLength int
+	ClassEndAt  int64
+}
+
+func (b XAPIStatementBuilder) ClassAttended(attendance *XAPIClassAttendance) *XAPIStatement {
+	var grouping []*XAPIActivity
+	if attendance.ClassID != "" {
+		grouping = append(grouping, b.activity("classes", attendance.ClassID, XAPIActivityTypeCourse, ""))
+	}
+
+	statement := &XAPIStatement{
+		ID:     b.statementID("attended", attendance.ScheduleID, attendance.UserID),
+		Actor:  b.agent(attendance.UserID),
+		Verb:   b.verb(XAPIVerbAttended),
+		Object: b.activity("schedules", attendance.ScheduleID, XAPIActivityTypeMeeting, attendance.Title),
+		Context: b.context(attendance.OrgID, nil, grouping, map[string]interface{}{
+			"class_type": attendance.ClassType,
+		}),
+		Timestamp: FormatXAPITime(time.Unix(attendance.ClassEndAt, 0)),
+	}
+	if attendance.ClassLength > 0 {
+		statement.Result = &XAPIResult{Duration: xapiDuration(attendance.ClassLength)}
+	}
+	return statement
+}
+
+// XAPIAssessmentCompletion an assessment a student participated in was completed
+type XAPIAssessmentCompletion struct {
+	OrgID          string
+	AssessmentID   string
+	Title          string
+	AssessmentType string
+	ScheduleID     string
+	UserID         string
+	CompleteAt     int64
+}
+
+func (b XAPIStatementBuilder) AssessmentCompleted(completion *XAPIAssessmentCompletion) *XAPIStatement {
+	return &XAPIStatement{
+		ID:     b.statementID("completed", completion.AssessmentID, completion.UserID),
+		Actor:  b.agent(completion.UserID),
+		Verb:   b.verb(XAPIVerbCompleted),
+		Object: b.activity("assessments", completion.AssessmentID, XAPIActivityTypeAssessment, completion.Title),
+		Result: &XAPIResult{Completion: xapiBool(true)},
+		Context: b.context(completion.OrgID,
+			[]*XAPIActivity{b.activity("schedules", completion.ScheduleID, XAPIActivityTypeMeeting, "")},
+			nil,
+			map[string]interface{}{
+				"assessment_type": completion.AssessmentType,
+			}),
+		Timestamp: FormatXAPITime(time.Unix(completion.CompleteAt, 0)),
+	}
+}
+
+// XAPIOutcomeAchievement a student achieved an outcome in an assessment
+type XAPIOutcomeAchievement struct {
+	OrgID        string
+	OutcomeID    string
+	OutcomeName  string
+	Shortcode    string
+	AssessmentID string
+	UserID       string
+	AchievedAt   int64
+}
+
+func (b XAPIStatementBuilder) OutcomeAchieved(achievement *XAPIOutcomeAchievement) *XAPIStatement {
+	object := b.activity("outcomes", achievement.OutcomeID, XAPIActivityTypeObjective, achievement.OutcomeName)
+	if achievement.Shortcode != "" {
+		object.Definition.Extensions = map[string]interface{}{
+			b.extension("shortcode"): achievement.Shortcode,
+		}
+	}
+
+	return &XAPIStatement{
+		ID:     b.statementID("achieved", achievement.AssessmentID, achievement.OutcomeID, achievement.UserID),
+		Actor:  b.agent(achievement.UserID),
+		Verb:   b.verb(XAPIVerbAchieved),
+		Object: object,
+		Result: &XAPIResult{Success: xapiBool(true)},
+		Context: b.context(achievement.OrgID,
+			[]*XAPIActivity{b.activity("assessments", achievement.AssessmentID, XAPIActivityTypeAssessment, "")},
+			nil, nil),
+		Timestamp: FormatXAPITime(time.Unix(achievement.AchievedAt, 0)),
+	}
+}
+
+type XAPIForwardStatus string
+
+const (
+	// XAPIForwardStatusNone no lrs was configured when the statement was stored
+	XAPIForwardStatusNone XAPIForwardStatus = "None"
+	// XAPIForwardStatusPending waiting for the first attempt or a retry
+	XAPIForwardStatusPending   XAPIForwardStatus = "Pending"
+	XAPIForwardStatusForwarded XAPIForwardStatus = "Forwarded"
+	// XAPIForwardStatusDead all attempts failed or the lrs rejected it
+	XAPIForwardStatusDead XAPIForwardStatus = "Dead"
+)
+
+// XAPIStatementRecord a stored statement, the columns besides the json are for queries and forwarding
+type XAPIStatementRecord struct {
+	ID       string `gorm:"column:id;PRIMARY_KEY"`
+	OrgID    string `gorm:"column:org_id;PRIMARY_KEY"`
+	ActorKey string `gorm:"column:actor_key"`
+	VerbID   string `gorm:"column:verb_id"`
+	ObjectID string `gorm:"column:object_id"`
+	// Timestamp when the experience occurred, unix seconds
+	Timestamp int64  `gorm:"column:timestamp;type:bigint"`
+	Statement string `gorm:"column:statement"`
+
+	ForwardStatus XAPIForwardStatus `gorm:"column:forward_status"`
+	Attempts      int               `gorm:"column:attempts"`
+	LastError     string            `gorm:"column:last_error"`
+	NextAttemptAt int64             `gorm:"column:next_attempt_at;type:bigint"`
+	ForwardedAt   int64             `gorm:"column:forwarded_at;type:bigint"`
+
+	// CreateAt the stored time of the statement
+	CreateAt int64 `gorm:"column:create_at;type:bigint"`
+	UpdateAt int64 `gorm:"column:update_at;type:bigint"`
+}
+
+func (XAPIStatementRecord) TableName() string {
+	return constant.TableNameXAPIStatement
+}
+
+func (r *XAPIStatementRecord) GetBatchInsertColsAndValues() (cols []string, values []interface{}) {
+	cols = []string{
+		"id", "org_id", "actor_key", "verb_id", "object_id", "timestamp", "statement",
+		"forward_status", "attempts", "last_error", "next_attempt_at", "forwarded_at", "create_at", "update_at",
+	}
+	values = []interface{}{
+		r.ID, r.OrgID, r.ActorKey, r.VerbID, r.ObjectID, r.Timestamp, r.Statement,
+		r.ForwardStatus, r.Attempts, r.LastError, r.NextAttemptAt, r.ForwardedAt, r.CreateAt, r.UpdateAt,
+	}
+	return
+}
+
+// SameStatement the statements are the same except the properties set when they are stored
+func (r *XAPIStatementRecord) SameStatement(other *XAPIStatementRecord) bool {
+	if r.ID != other.ID || r.OrgID != other.OrgID {
+		return false
+	}
+
+	var statements [2]map[string]interface{}
+	for i, data := range []string{r.Statement, other.Statement} {
+		if err := json.Unmarshal([]byte(data), &statements[i]); err != nil {
+			return false
+		}
+		// timestamp is the stored time if the statement has none
+		delete(statements[i], "stored")
+		delete(statements[i], "timestamp")
+	}
+	return reflect.DeepEqual(statements[0], statements[1])
+}
+
+// Fail record a failed attempt, the statement is retried with exponential backoff until max attempts
+func (r *XAPIStatementRecord) Fail(reason string, maxAttempts int, backoff time.Duration, now int64) {
+	r.Attempts++
+	r.LastError = reason
+	r.UpdateAt = now
+
+	if r.Attempts >= maxAttempts {
+		r.ForwardStatus = XAPIForwardStatusDead
+		return
+	}
+
+	r.ForwardStatus = XAPIForwardStatusPending
+	r.NextAttemptAt = now + int64(RetryDelay(backoff, r.Attempts).Seconds())
+}
+
+// NewXAPIStatementRecord validates the statement and completes it as an lrs does,
+// an id is assigned if it has none and stored is set to now.
+// Properties unknown to XAPIStatement are kept
+func NewXAPIStatementRecord(orgID string, raw json.RawMessage, forward bool, now time.Time) (*XAPIStatementRecord, error) {
+	statement := new(XAPIStatement)
+	if err := json.Unmarshal(raw, statement); err != nil {
+		return nil, constant.ErrInvalidArgs
+	}
+	if reason := statement.InvalidReason(); reason != "" {
+		return nil, fmt.Errorf("%w: %s", constant.ErrInvalidArgs, reason)
+	}
+
+	properties := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &properties); err != nil {
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if statement.ID == "" {
+		statement.ID = uuid.New().String()
+	}
+	stored := FormatXAPITime(now)
+	if statement.Timestamp == "" {
+		statement.Timestamp = stored
+	}
+	timestamp, err := ParseXAPITime(statement.Timestamp)
+	if err != nil {
+		return nil, constant.ErrInvalidArgs
+	}
+
+	id := strings.ToLower(statement.ID)
+	properties["id"], _ = json.Marshal(id)
+	properties["timestamp"], _ = json.Marshal(statement.Timestamp)
+	properties["stored"], _ = json.Marshal(stored)
+	if _, ok := properties["version"]; !ok {
+		properties["version"], _ = json.Marshal(XAPIVersion)
+	}
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &XAPIStatementRecord{
+		ID:            id,
+		OrgID:         orgID,
+		ActorKey:      statement.Actor.Key(),
+		VerbID:        statement.Verb.ID,
+		ObjectID:      statement.Object.ID,
+		Timestamp:     timestamp.Unix(),
+		Statement:     string(data),
+		ForwardStatus: XAPIForwardStatusNone,
+		CreateAt:      now.Unix(),
+		UpdateAt:      now.Unix(),
+	}
+	if forward {
+		record.ForwardStatus = XAPIForwardStatusPending
+		record.NextAttemptAt = now.Unix()
+	}
+	return record, nil
+}
+
+// ParseXAPIStatements the body of a post is a statement or an array of them
+func ParseXAPIStatements(body []byte) ([]json.RawMessage, error) {
+	body = []byte(strings.TrimSpace(string(body)))
+	if len(body) == 0 {
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if body[0] != '[' {
+		return []json.RawMessage{body}, nil
+	}
+
+	var statements []json.RawMessage
+	if err := json.Unmarshal(body, &statements); err != nil {
+		return nil, constant.ErrInvalidArgs
+	}
+	return statements, nil
+}
+
+// XAPIStatementQueryReq parameters of the statements resource of xapi, page continues a query in more
+type XAPIStatementQueryReq struct {
+	StatementID string `form:"statementId"`
+	// Agent json of an agent, statements of other agents with the same identifier are returned
+	Agent     string `form:"agent"`
+	Verb      string `form:"verb"`
+	Activity  string `form:"activity"`
+	Since     string `form:"since"`
+	Until     string `form:"until"`
+	Limit     int    `form:"limit"`
+	Ascending bool   `form:"ascending"`
+	Page      int    `form:"page"`
+}
+
+func (r *XAPIStatementQueryReq) Valid() bool {
+	if r.Agent != "" {
+		if _, err := ParseXAPIAgentKey(r.Agent); err != nil {
+			return false
+		}
+	}
+	for _, item := range []string{r.Since, r.Until} {
+		if item == "" {
+			continue
+		}
+		if _, err := ParseXAPITime(item); err != nil {
+			return false
+		}
+	}
+
+	if r.Limit <= 0 || r.Limit > constant.XAPIQueryMaxLimit {
+		r.Limit = constant.XAPIQueryMaxLimit
+	}
+	if r.Page <= 0 {
+		r.Page = 1
+	}
+	return true
+}
+
+// XAPIStatementResult More is set by the api with the url of the next page
+type XAPIStatementResult struct {
+	Statements []json.RawMessage `json:"statements"`
+	More       string            `json:"more"`
+	HasMore    bool              `json:"-"`
+}
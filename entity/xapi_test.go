@@ -0,0 +1,154 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+func TestXAPIStatementRecord(t *testing.T) {
+	now := time.Unix(1650000000, 0)
+	raw := `{"actor":{"mbox":"mailto:a@example.com"},"verb":{"id":"http://adlnet.gov/expapi/verbs/answered"},` +
+		`"object":{"id":"https://example.com/activities/1"},"attachments":[{"usageType":"x"}]}`
+
+	record, err := NewXAPIStatementRecord("org", json.RawMessage(raw), true, now)
+	if err != nil {
+		t.Fatalf("valid statement is rejected: %v", err)
+	}
+	if record.ID == "" || record.ActorKey != "mailto:a@example.com" || record.ObjectID != "https://example.com/activities/1" ||
+		record.Timestamp != now.Unix() || record.CreateAt != now.Unix() ||
+		record.ForwardStatus != XAPIForwardStatusPending || record.NextAttemptAt != now.Unix() {
+		t.Fatalf("unexpected record %+v", record)
+	}
+
+	properties := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(record.Statement), &properties); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"id", "stored", "timestamp", "version", "attachments"} {
+		if properties[key] == nil {
+			t.Errorf("statement has no %s: %s", key, record.Statement)
+		}
+	}
+
+	record, err = NewXAPIStatementRecord("org", json.RawMessage(raw), false, now)
+	if err != nil || record.ForwardStatus != XAPIForwardStatusNone {
+		t.Errorf("statement is forwarded without lrs: %+v %v", record, err)
+	}
+
+	invalid := []string{
+		`{`,
+		`{"id":"1","actor":{"mbox":"mailto:a@example.com"},"verb":{"id":"http://v"},"object":{"id":"http://o"}}`,
+		`{"actor":{"name":"a"},"verb":{"id":"http://v"},"object":{"id":"http://o"}}`,
+		`{"actor":{"mbox":"mailto:a@example.com"},"verb":{"id":"answered"},"object":{"id":"http://o"}}`,
+		`{"actor":{"mbox":"mailto:a@example.com"},"verb":{"id":"http://v"},"object":{"objectType":"Agent","mbox":"mailto:b@example.com"}}`,
+		`{"actor":{"mbox":"mailto:a@example.com"},"verb":{"id":"http://v"},"object":{"id":"http://o"},"timestamp":"yesterday"}`,
+	}
+	for i, item := range invalid {
+		if _, err := NewXAPIStatementRecord("org", json.RawMessage(item), true, now); !errors.Is(err, constant.ErrInvalidArgs) {
+			t.Errorf("case %d: invalid statement is accepted: %v", i, err)
+		}
+	}
+
+	record.Fail("unavailable", 2, time.Minute, 100)
+	if record.ForwardStatus != XAPIForwardStatusPending || record.NextAttemptAt <= 100 {
+		t.Errorf("failed statement is not retried: %+v", record)
+	}
+	record.Fail("unavailable", 2, time.Minute, 200)
+	if record.ForwardStatus != XAPIForwardStatusDead {
+		t.Errorf("statement is retried after max attempts: %+v", record)
+	}
+}
+
+func TestXAPIStatementRecordSameStatement(t *testing.T) {
+	raw := `{"id":"3e2a1c8e-7d3b-4b8e-9d6f-2a9c1e0b5f41","actor":{"mbox":"mailto:a@example.com"},` +
+		`"verb":{"id":"http://adlnet.gov/expapi/verbs/answered"},"object":{"id":"https://example.com/activities/1"}}`
+	record, err := NewXAPIStatementRecord("org", json.RawMessage(raw), false, time.Unix(1650000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, _ := NewXAPIStatementRecord("org", json.RawMessage(raw), false, time.Unix(1650000100, 0))
+	if !record.SameStatement(again) {
+		t.Error("statement stored again is a different one")
+	}
+
+	other, _ := NewXAPIStatementRecord("org", json.RawMessage(strings.Replace(raw, "activities/1", "activities/2", 1)), false, time.Unix(1650000000, 0))
+	if record.SameStatement(other) {
+		t.Error("statement of another object is the same one")
+	}
+}
+
+func TestXAPIStatementBuilder(t *testing.T) {
+	builder := XAPIStatementBuilder{HomePage: "https://kidsloop.net"}
+	usage := &StudentUsageRecord{
+		RoomID:            "room",
+		LessonMaterialUrl: "assets/1.mp4",
+		Timestamp:         1650000000123,
+		StudentUserID:     "s1",
+		LessonPlanID:      "plan",
+		LessonMaterialID:  "material",
+		ClassID:           "class",
+	}
+
+	statement := builder.MaterialViewed("org", usage)
+	if statement.Verb.ID != XAPIVerbExperienced || statement.Actor.Account.Name != "s1" ||
+		statement.Object.ID != "https://kidsloop.net/xapi/activities/materials/material" ||
+		statement.Timestamp != "2022-04-15T05:20:00.123Z" {
+		t.Fatalf("unexpected statement %+v", statement)
+	}
+	if reason := statement.InvalidReason(); reason != "" {
+		t.Fatalf("generated statement is invalid: %s", reason)
+	}
+	if again := builder.MaterialViewed("org", usage); again.ID != statement.ID {
+		t.Error("statements of the same view have different ids")
+	}
+
+	usage.LessonMaterialID = ""
+	if other := builder.MaterialViewed("org", usage); !strings.Contains(other.Object.ID, "/material_urls/") {
+		t.Errorf("unexpected object of unknown material %s", other.Object.ID)
+	}
+
+	attended := builder.ClassAttended(&XAPIClassAttendance{ScheduleID: "schedule", UserID: "s1", ClassLength: 1800, ClassEndAt: 1650000000})
+	completed := builder.AssessmentCompleted(&XAPIAssessmentCompletion{AssessmentID: "assessment", ScheduleID: "schedule", UserID: "s1", CompleteAt: 1650000000})
+	achieved := builder.OutcomeAchieved(&XAPIOutcomeAchievement{OutcomeID: "outcome", AssessmentID: "assessment", UserID: "s1", AchievedAt: 1650000000})
+	if attended.Result.Duration != "PT1800S" || *completed.Result.Completion != true || *achieved.Result.Success != true {
+		t.Errorf("unexpected results %+v %+v %+v", attended.Result, completed.Result, achieved.Result)
+	}
+	ids := map[string]bool{statement.ID: true, attended.ID: true, completed.ID: true, achieved.ID: true}
+	if len(ids) != 4 {
+		t.Error("statements of different experiences have the same id")
+	}
+}
+
+func TestXAPIStatementQueryReq(t *testing.T) {
+	req := &XAPIStatementQueryReq{
+		Agent: `{"account":{"homePage":"https://kidsloop.net","name":"s1"}}`,
+		Since: "2022-04-15T05:20:00Z",
+		Limit: 1000,
+	}
+	if !req.Valid() || req.Limit != constant.XAPIQueryMaxLimit || req.Page != 1 {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if key, _ := ParseXAPIAgentKey(req.Agent); key != "account:https://kidsloop.net|s1" {
+		t.Errorf("unexpected agent key %s", key)
+	}
+
+	for _, item := range []*XAPIStatementQueryReq{{Agent: `{"name":"s1"}`}, {Until: "2022-04-15"}} {
+		if item.Valid() {
+			t.Errorf("invalid request is accepted %+v", item)
+		}
+	}
+
+	statements, err := ParseXAPIStatements([]byte(` [{"id":"a"},{"id":"b"}] `))
+	if err != nil || len(statements) != 2 {
+		t.Errorf("unexpected statements %v %v", statements, err)
+	}
+	if _, err := ParseXAPIStatements([]byte(" ")); err == nil {
+		t.Error("empty body is accepted")
+	}
+}
@@ -39,6 +39,10 @@ func (n *Age) RelatedIDs() []*cache.RelatedEntity {
 	return nil
 }
 func GetAgeServiceProvider() AgeServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Ages()
+	}
+
 	if config.Get().AMS.UseDeprecatedQuery {
 		return &AmsAgeService{}
 	}
@@ -61,8 +65,7 @@ func (s AmsAgeService) BatchGet(ctx context.Context, operator *entity.Operator,
 		}
 	}
 
-	res := make([]*Age, 0, len(uuids))
-	err := cache.GetPassiveCacheRefresher().BatchGet(ctx, s.Name(), uuids, &res, operator)
+	res, err := loadHotObjects[*Age](ctx, operator, s.Name(), uuids)
 	if err != nil {
 		return nil, err
 	}
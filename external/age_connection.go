@@ -166,7 +166,7 @@ func (acs AmsAgeConnectionService) QueryByIDs(ctx context.Context, ids []string,
 	}
 	sb.WriteString("}")
 
-	request := NewRequest(sb.String(), RequestToken(operator.Token))
+	request := NewRequest(sb.String(), RequestToken(operator.Token), RequestStale())
 	for index, id := range _ids {
 		request.Var(fmt.Sprintf("age_id_%d", index), id)
 	}
@@ -21,7 +21,7 @@ var (
 func GetAmsConnection() *AmsConnection {
 	_amsConnectionOnce.Do(func() {
 		_amsConnection = &AmsConnection{
-			Client: NewClient(config.Get().AMS.EndPoint),
+			Client: NewClient(config.Get().AMS.EndPoint, WithBreakerName(constant.ExternalBreakerAms)),
 			reg:    regexp.MustCompile("access=\\S+"),
 		}
 
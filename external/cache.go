@@ -0,0 +1,53 @@
+package external
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/kidsloop-cache/cache"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils/kl2cache"
+)
+
+// batchGetHotObjects programs, subjects, ages and grades are looked up by most requests,
+// they are kept in the local tier of kl2cache in front of the passive cache.
+// val must be a pointer of a slice of cache.Object, e.g. *[]*Program
+func batchGetHotObjects(ctx context.Context, operator *entity.Operator, name string, ids []string, val interface{}) error {
+	keys := make([]kl2cache.Key, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, kl2cache.KeyByStrings{"kl2cache", name, id})
+	}
+
+	provider := kl2cache.DefaultProvider.WithExpireStrategy(ctx, kl2cache.ExpireStrategyFixed(constant.HotObjectCacheExpiration))
+	return provider.BatchGet(ctx, keys, val, func(ctx context.Context, missedKeys []kl2cache.Key) ([]*kl2cache.KeyValue, error) {
+		missedIDs := make([]string, 0, len(missedKeys))
+		for _, key := range missedKeys {
+			missedIDs = append(missedIDs, key.(kl2cache.KeyByStrings)[2])
+		}
+
+		fetched := reflect.New(reflect.TypeOf(val).Elem())
+		err := cache.GetPassiveCacheRefresher().BatchGet(ctx, name, missedIDs, fetched.Interface(), operator)
+		if err != nil {
+			return nil, err
+		}
+
+		items := fetched.Elem()
+		kvs := make([]*kl2cache.KeyValue, 0, items.Len())
+		for i := 0; i < items.Len(); i++ {
+			item := items.Index(i).Interface()
+			object, ok := item.(cache.Object)
+			if !ok || (items.Index(i).Kind() == reflect.Ptr && items.Index(i).IsNil()) {
+				log.Warn(ctx, "invalid hot object", log.String("name", name), log.Any("item", item))
+				continue
+			}
+			kvs = append(kvs, &kl2cache.KeyValue{
+				Key: kl2cache.KeyByStrings{"kl2cache", name, object.StringID()},
+				Val: item,
+			})
+		}
+		return kvs, nil
+	})
+}
@@ -40,6 +40,10 @@ func (n *Category) RelatedIDs() []*cache.RelatedEntity {
 	return nil
 }
 func GetCategoryServiceProvider() CategoryServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Categories()
+	}
+
 	if config.Get().AMS.UseDeprecatedQuery {
 		return &AmsCategoryService{}
 	}
@@ -62,8 +66,7 @@ func (s AmsCategoryService) BatchGet(ctx context.Context, operator *entity.Opera
 		}
 	}
 
-	res := make([]*Category, 0, len(uuids))
-	err := cache.GetPassiveCacheRefresher().BatchGet(ctx, s.Name(), uuids, &res, operator)
+	res, err := loadPassiveObjects[*Category](ctx, operator, s.Name(), uuids)
 	if err != nil {
 		return nil, err
 	}
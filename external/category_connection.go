@@ -203,7 +203,7 @@ func (accs AmsCategoryConnectionService) QueryByIDs(ctx context.Context, ids []s
 	}
 	sb.WriteString("}")
 
-	request := NewRequest(sb.String(), RequestToken(operator.Token))
+	request := NewRequest(sb.String(), RequestToken(operator.Token), RequestStale())
 	for index, id := range _ids {
 		request.Var(fmt.Sprintf("category_id_%d", index), id)
 	}
@@ -50,6 +50,10 @@ func (n *NullableClass) RelatedIDs() []*cache.RelatedEntity {
 	return nil
 }
 func GetClassServiceProvider() ClassServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Classes()
+	}
+
 	if config.Get().AMS.UseDeprecatedQuery {
 		return &AmsClassService{}
 	}
@@ -178,8 +182,7 @@ func (s AmsClassService) BatchGet(ctx context.Context, operator *entity.Operator
 		return []*NullableClass{}, nil
 	}
 
-	res := make([]*NullableClass, 0, len(ids))
-	err := cache.GetPassiveCacheRefresher().BatchGet(ctx, s.Name(), ids, &res, operator)
+	res, err := loadPassiveObjects[*NullableClass](ctx, operator, s.Name(), ids)
 	if err != nil {
 		return nil, err
 	}
@@ -13,6 +13,7 @@ import (
 	"github.com/KL-Engineering/kidsloop-cms-service/config"
 	"github.com/KL-Engineering/kidsloop-cms-service/constant"
 	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils/resilience"
 )
 
 var (
@@ -34,6 +35,12 @@ type DataServiceClient struct {
 	*http.Client
 }
 
+// dataServiceIdempotentPaths are retried and hedged, the last good response is not served
+// because review data changes while the data service processes it
+var dataServiceIdempotentPaths = map[string]bool{
+	constant.DataServiceCheckScheduleReviewUrlPath: true,
+}
+
 func (c DataServiceClient) Run(ctx context.Context, path string, req, resp interface{}) (int, error) {
 	externalStopwatch, foundStopwatch := utils.GetStopwatch(ctx, constant.ExternalStopwatch)
 	if foundStopwatch {
@@ -52,93 +59,93 @@ func (c DataServiceClient) Run(ctx context.Context, path string, req, resp inter
 	}
 
 	url := config.Get().DataService.EndPoint + path
-	request, err := http.NewRequestWithContext(ctxWithTimeout, http.MethodPost, url, bytes.NewBuffer(reqBuffer))
-	if err != nil {
-		log.Error(ctxWithTimeout, "http.NewRequestWithContext error",
-			log.Err(err),
-			log.Any("req", req))
-		return 0, err
-	}
-
-	request.Header.Set(constant.DataServiceAuthorizedHeaderKey, config.Get().DataService.AuthorizedKey)
-	request.Header.Set("Content-Type", "application/json; charset=utf-8")
-	request.Header.Set("Accept", "application; charset=utf-8")
-
-	var result *http.Response
-	var resultErr error
-	httpDone := make(chan struct{})
-
 	start := time.Now()
+	result, err := runResilient(ctxWithTimeout, constant.ExternalBreakerDataService+path, dataServiceIdempotentPaths[path], "", func(ctx context.Context) (interface{}, error) {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(reqBuffer))
+		if err != nil {
+			log.Error(ctx, "http.NewRequestWithContext error",
+				log.Err(err),
+				log.Any("req", req))
+			return nil, resilience.Permanent(err)
+		}
 
-	go func() {
-		result, resultErr = c.Client.Do(request)
-		httpDone <- struct{}{}
-	}()
+		request.Header.Set(constant.DataServiceAuthorizedHeaderKey, config.Get().DataService.AuthorizedKey)
+		request.Header.Set("Content-Type", "application/json; charset=utf-8")
+		request.Header.Set("Accept", "application; charset=utf-8")
+
+		result, err := c.Client.Do(request)
+		if err != nil {
+			log.Warn(ctx, "do http error",
+				log.Duration("duration", time.Since(start)),
+				log.Err(err),
+				log.String("url", url),
+				log.Any("req", req))
+			return nil, &transportError{err: err}
+		}
+		defer result.Body.Close()
+
+		response, err := ioutil.ReadAll(result.Body)
+		if err != nil {
+			log.Warn(ctx, "read response error",
+				log.Err(err),
+				log.Duration("duration", time.Since(start)),
+				log.String("url", url),
+				log.Any("req", req),
+				log.String("response", string(response)))
+			return nil, &transportError{err: err}
+		}
 
-	select {
-	case <-httpDone:
-		log.Info(ctxWithTimeout, "OK")
-	case <-ctxWithTimeout.Done():
-		duration := time.Since(start)
-		log.Error(ctxWithTimeout, "do http failed",
-			log.Duration("duration", duration),
-			log.Err(resultErr),
-			log.String("url", url),
-			log.Any("req", req))
-		return http.StatusRequestTimeout, ctx.Err()
-	}
+		if result.StatusCode != http.StatusOK {
+			log.Error(ctx, "data service failed",
+				log.Duration("duration", time.Since(start)),
+				log.Int("status_code", result.StatusCode),
+				log.String("url", url),
+				log.Any("req", req),
+				log.String("response", string(response)))
+			if isUpstreamUnavailable(result.StatusCode) {
+				return nil, &errUpstreamUnavailable{statusCode: result.StatusCode}
+			}
+			return &rawResponse{statusCode: result.StatusCode}, resilience.Permanent(constant.ErrDataServiceFailed)
+		}
+		return &rawResponse{statusCode: result.StatusCode, body: response}, nil
+	})
 
 	duration := time.Since(start)
-	if resultErr != nil {
-		log.Error(ctxWithTimeout, "do http error",
-			log.Duration("duration", duration),
-			log.Err(resultErr),
-			log.String("url", url),
-			log.Any("req", req))
-		return 0, resultErr
-	}
-
-	defer result.Body.Close()
-	response, err := ioutil.ReadAll(result.Body)
 	if err != nil {
-		log.Error(ctxWithTimeout, "read response error",
-			log.Err(err),
-			log.Duration("duration", duration),
-			log.String("url", url),
-			log.Any("req", req),
-			log.String("response", string(response)))
-		return result.StatusCode, err
-	}
-
-	if result.StatusCode != http.StatusOK {
-		log.Error(ctxWithTimeout, "data service failed",
+		log.Error(ctxWithTimeout, "do http failed",
 			log.Duration("duration", duration),
+			log.Err(err),
 			log.String("url", url),
-			log.Any("req", req),
-			log.String("response", string(response)))
-		return result.StatusCode, constant.ErrDataServiceFailed
+			log.Any("req", req))
+		if ctxWithTimeout.Err() != nil {
+			return http.StatusRequestTimeout, ctxWithTimeout.Err()
+		}
+		if result != nil {
+			return result.statusCode, err
+		}
+		return 0, err
 	}
 
-	err = json.Unmarshal(response, resp)
+	err = json.Unmarshal(result.body, resp)
 	if err != nil {
 		log.Error(ctxWithTimeout, "json.Unmarshal error",
 			log.Err(err),
 			log.Duration("duration", duration),
 			log.String("url", url),
 			log.Any("req", req),
-			log.String("response", string(response)))
-		return result.StatusCode, err
+			log.String("response", string(result.body)))
+		return result.statusCode, err
 	}
 	log.Debug(ctxWithTimeout, "do http success",
 		log.Duration("duration", duration),
 		log.Any("req", req),
-		log.String("response", string(response)))
+		log.String("response", string(result.body)))
 
 	if foundStopwatch {
 		externalStopwatch.Stop()
 	}
 
-	return result.StatusCode, nil
+	return result.statusCode, nil
 }
 
 var (
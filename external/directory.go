@@ -0,0 +1,41 @@
+package external
+
+import "sync"
+
+// DirectoryProvider the source of users, teachers, students, organizations, schools, classes, roles, permissions
+// and academic profiles
+type DirectoryProvider interface {
+	Users() UserServiceProvider
+	Teachers() TeacherServiceProvider
+	Students() StudentServiceProvider
+	Organizations() OrganizationServiceProvider
+	Schools() SchoolServiceProvider
+	Classes() ClassServiceProvider
+	Roles() RoleServiceProvider
+	Permissions() PermissionServiceProvider
+	Programs() ProgramServiceProvider
+	Subjects() SubjectServiceProvider
+	Ages() AgeServiceProvider
+	Grades() GradeServiceProvider
+	Categories() CategoryServiceProvider
+	SubCategories() SubCategoryServiceProvider
+}
+
+var (
+	_directoryProvider     DirectoryProvider
+	_directoryProviderLock sync.RWMutex
+)
+
+// SetDirectoryProvider replaces ams with provider, nil restores ams.
+// It must be called before the providers are registered as cache data sources.
+func SetDirectoryProvider(provider DirectoryProvider) {
+	_directoryProviderLock.Lock()
+	defer _directoryProviderLock.Unlock()
+	_directoryProvider = provider
+}
+
+func getDirectoryProvider() DirectoryProvider {
+	_directoryProviderLock.RLock()
+	defer _directoryProviderLock.RUnlock()
+	return _directoryProvider
+}
@@ -37,6 +37,10 @@ func (n *Grade) RelatedIDs() []*cache.RelatedEntity {
 	return nil
 }
 func GetGradeServiceProvider() GradeServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Grades()
+	}
+
 	if config.Get().AMS.UseDeprecatedQuery {
 		return &AmsGradeService{}
 	}
@@ -59,8 +63,7 @@ func (s AmsGradeService) BatchGet(ctx context.Context, operator *entity.Operator
 		}
 	}
 
-	res := make([]*Grade, 0, len(uuids))
-	err := cache.GetPassiveCacheRefresher().BatchGet(ctx, s.Name(), uuids, &res, operator)
+	res, err := loadHotObjects[*Grade](ctx, operator, s.Name(), uuids)
 	if err != nil {
 		return nil, err
 	}
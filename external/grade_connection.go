@@ -181,7 +181,7 @@ func (gcs AmsGradeConnectionService) QueryByIDs(ctx context.Context, ids []strin
 	}
 	sb.WriteString("}")
 
-	request := NewRequest(sb.String(), RequestToken(operator.Token))
+	request := NewRequest(sb.String(), RequestToken(operator.Token), RequestStale())
 	for index, id := range _ids {
 		request.Var(fmt.Sprintf("grade_id_%d", index), id)
 	}
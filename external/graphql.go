@@ -7,11 +7,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/KL-Engineering/common-log/log"
 	"github.com/KL-Engineering/tracecontext"
 	"github.com/newrelic/go-agent/v3/newrelic"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/utils/resilience"
 )
 
 type GraphQLError []struct {
@@ -42,6 +45,8 @@ type GraphQLRequest struct {
 	query  string
 	vars   map[string]interface{}
 	Header http.Header
+	// stale the last good response may be served when the upstream is unavailable
+	stale bool
 }
 
 type GraphQLSubResponse struct {
@@ -56,6 +61,14 @@ func RequestToken(token string) OptionFunc {
 	}
 }
 
+// RequestStale opt in to the last good response when the upstream is unavailable.
+// Only catalog lookups such as programs and subjects opt in, permission and membership queries never do.
+func RequestStale() OptionFunc {
+	return func(req *GraphQLRequest) {
+		req.stale = true
+	}
+}
+
 func NewRequest(q string, opt ...OptionFunc) *GraphQLRequest {
 	req := &GraphQLRequest{
 		query:  q,
@@ -89,6 +102,8 @@ type GraphGLClient struct {
 	endpoint    string
 	httpClient  *http.Client
 	httpTimeout time.Duration
+	// breakerName calls of the client share the circuit breaker of the name
+	breakerName string
 }
 
 type OptionClient func(c *GraphGLClient)
@@ -99,6 +114,12 @@ func WithTimeout(duration time.Duration) OptionClient {
 	}
 }
 
+func WithBreakerName(name string) OptionClient {
+	return func(c *GraphGLClient) {
+		c.breakerName = name
+	}
+}
+
 type debugTransport struct{}
 
 func (d debugTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
@@ -117,6 +138,7 @@ func NewClient(endpoint string, options ...OptionClient) *GraphGLClient {
 		endpoint:    endpoint,
 		httpClient:  &http.Client{Transport: newrelic.NewRoundTripper(debugTransport{})},
 		httpTimeout: defaultHttpTimeout,
+		breakerName: endpoint,
 	}
 	for i := range options {
 		options[i](c)
@@ -124,6 +146,11 @@ func NewClient(endpoint string, options ...OptionClient) *GraphGLClient {
 	return c
 }
 
+// isMutation mutations are not idempotent, they are neither retried nor hedged
+func isMutation(query string) bool {
+	return strings.HasPrefix(strings.TrimSpace(query), "mutation")
+}
+
 func (c *GraphGLClient) Run(ctx context.Context, req *GraphQLRequest, resp interface{}) (int, error) {
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, c.httpTimeout)
 	defer cancel()
@@ -140,53 +167,83 @@ func (c *GraphGLClient) Run(ctx context.Context, req *GraphQLRequest, resp inter
 		log.Warn(ctxWithTimeout, "Run: marshalFilter failed", log.Err(err), log.Any("reqBody", reqBody))
 		return 0, err
 	}
-	request, err := http.NewRequestWithContext(ctxWithTimeout, http.MethodPost, c.endpoint, bytes.NewBuffer(reqBuffer))
-	if err != nil {
-		log.Warn(ctxWithTimeout, "Run: New httpRequest failed", log.Err(err), log.Any("reqBody", reqBody))
-		return 0, err
-	}
-	if traceContext, ok := tracecontext.GetTraceContext(ctxWithTimeout); ok {
-		traceContext.SetHeader(request.Header)
+
+	header := req.Header.Clone()
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Accept", "application; charset=utf-8")
+
+	idempotent := !isMutation(req.query)
+	var key string
+	if idempotent && req.stale {
+		key, err = staleKey(c.breakerName, reqBody, header)
+		if err != nil {
+			log.Warn(ctxWithTimeout, "Run: hash request failed", log.Err(err), log.Any("reqBody", reqBody))
+		}
 	}
-	request.Header = req.Header
-	request.Header.Set("Content-Type", "application/json; charset=utf-8")
-	request.Header.Set("Accept", "application; charset=utf-8")
-	var result *http.Response
-	var resultErr error
+
 	start := time.Now()
-	result, resultErr = c.httpClient.Do(request)
+	result, err := runResilient(ctxWithTimeout, c.breakerName, idempotent, key, func(ctx context.Context) (interface{}, error) {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewBuffer(reqBuffer))
+		if err != nil {
+			log.Warn(ctx, "Run: New httpRequest failed", log.Err(err), log.Any("reqBody", reqBody))
+			return nil, resilience.Permanent(err)
+		}
+		request.Header = header.Clone()
+		if traceContext, ok := tracecontext.GetTraceContext(ctx); ok {
+			traceContext.SetHeader(request.Header)
+		}
+
+		result, err := c.httpClient.Do(request)
+		if err != nil {
+			log.Warn(ctx, "Run: do http failed",
+				log.Duration("duration", time.Since(start)),
+				log.Err(err),
+				log.String("endpoint", c.endpoint),
+				log.Any("reqBody", reqBody))
+			return nil, &transportError{err: err}
+		}
+		defer result.Body.Close()
+
+		response, err := ioutil.ReadAll(result.Body)
+		if err != nil {
+			log.Warn(ctx, "Run: read response failed",
+				log.Duration("duration", time.Since(start)),
+				log.Err(err), log.String("endpoint", c.endpoint),
+				log.Any("reqBody", reqBody), log.String("response", string(response)))
+			return nil, &transportError{err: err}
+		}
+		if isUpstreamUnavailable(result.StatusCode) {
+			log.Warn(ctx, "Run: upstream unavailable",
+				log.Duration("duration", time.Since(start)),
+				log.Int("status_code", result.StatusCode),
+				log.String("endpoint", c.endpoint),
+				log.Any("reqBody", reqBody), log.String("response", string(response)))
+			return nil, &errUpstreamUnavailable{statusCode: result.StatusCode}
+		}
+		return &rawResponse{statusCode: result.StatusCode, body: response}, nil
+	})
 
 	duration := time.Since(start)
-	if resultErr != nil {
+	if err != nil {
 		log.Error(ctxWithTimeout, "Run: do http failed",
 			log.Duration("duration", duration),
-			log.Err(resultErr),
+			log.Err(err),
 			log.String("endpoint", c.endpoint),
 			log.Any("reqBody", reqBody))
-		return 0, resultErr
-	}
-
-	defer result.Body.Close()
-	response, err := ioutil.ReadAll(result.Body)
-	if err != nil {
-		log.Error(ctxWithTimeout, "Run: read response failed",
-			log.Duration("duration", duration),
-			log.Err(err), log.String("endpoint", c.endpoint),
-			log.Any("reqBody", reqBody), log.String("response", string(response)))
-		return result.StatusCode, err
+		return 0, err
 	}
 
-	err = json.Unmarshal(response, resp)
+	err = json.Unmarshal(result.body, resp)
 	if err != nil {
 		log.Error(ctxWithTimeout, "Run: unmarshal response failed",
 			log.Duration("duration", duration),
 			log.Err(err), log.String("endpoint", c.endpoint),
-			log.Any("reqBody", reqBody), log.String("response", string(response)))
-		return result.StatusCode, err
+			log.Any("reqBody", reqBody), log.String("response", string(result.body)))
+		return result.statusCode, err
 	}
 	log.Debug(ctxWithTimeout, "Run: Success",
 		log.Duration("duration", duration),
 		log.Any("reqBody", reqBody),
-		log.String("response", string(response)))
-	return result.StatusCode, nil
+		log.String("response", string(result.body)))
+	return result.statusCode, nil
 }
@@ -2,6 +2,10 @@ package external
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
 	"sync"
 
 	"github.com/KL-Engineering/common-log/log"
@@ -11,6 +15,7 @@ import (
 	"github.com/KL-Engineering/kidsloop-cms-service/config"
 	"github.com/KL-Engineering/kidsloop-cms-service/constant"
 	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils/resilience"
 )
 
 var (
@@ -22,6 +27,7 @@ func GetH5PClient() *H5PClient {
 	_h5pClientOnce.Do(func() {
 		_h5pClient = &H5PClient{
 			Client: chlorine.NewClient(config.Get().H5P.EndPoint),
+			reg:    regexp.MustCompile("access=\\S+"),
 		}
 
 	})
@@ -30,15 +36,75 @@ func GetH5PClient() *H5PClient {
 
 type H5PClient struct {
 	*chlorine.Client
+	reg *regexp.Regexp
 }
 
+// Run mutations run once through the circuit breaker, use RunQuery for queries
 func (c H5PClient) Run(ctx context.Context, req *chlorine.Request, resp *chlorine.Response) (int, error) {
+	return c.run(ctx, "", req, resp)
+}
+
+// RunQuery the query is retried and hedged, the last good response of the same query and token
+// is served when h5p is unavailable
+func (c H5PClient) RunQuery(ctx context.Context, query string, req *chlorine.Request, resp *chlorine.Response) (int, error) {
+	return c.run(ctx, query, req, resp)
+}
+
+func (c H5PClient) run(ctx context.Context, query string, req *chlorine.Request, resp *chlorine.Response) (int, error) {
 	externalStopwatch, foundStopwatch := utils.GetStopwatch(ctx, constant.ExternalStopwatch)
 	if foundStopwatch {
 		externalStopwatch.Start()
 	}
 
-	statusCode, err := c.Client.Run(ctx, req, resp)
+	// requests of background workers have no token
+	cookie := req.Header.Get(constant.CookieKey)
+	if !c.reg.MatchString(cookie) {
+		if h5pAuthorizedKey := config.Get().H5P.AuthorizedKey; h5pAuthorizedKey != "" {
+			req.SetHeader(constant.H5PAuthorizedHeaderKey, fmt.Sprintf("Bearer %s", h5pAuthorizedKey))
+		} else {
+			log.Warn(ctx, "Found access h5p without cookie and h5p_service_api_key is empty")
+		}
+	}
+
+	idempotent := query != ""
+	result, err := runResilient(ctx, constant.ExternalBreakerH5P, idempotent, "", func(ctx context.Context) (interface{}, error) {
+		// attempts of a query may run concurrently, each of them has its own response
+		attemptResp := resp
+		var data json.RawMessage
+		if idempotent {
+			attemptResp = &chlorine.Response{Data: &data}
+		}
+
+		statusCode, err := c.Client.Run(ctx, req, attemptResp)
+		switch {
+		case err == nil:
+		case statusCode == 0:
+			return nil, &transportError{err: err}
+		case isUpstreamUnavailable(statusCode):
+			return nil, &errUpstreamUnavailable{statusCode: statusCode}
+		default:
+			return &rawResponse{statusCode: statusCode}, resilience.Permanent(err)
+		}
+
+		if idempotent && len(attemptResp.Errors) > 0 {
+			// h5p answered, the errors are passed to the caller but never kept as the last good response
+			return &rawResponse{statusCode: statusCode}, resilience.Permanent(attemptResp.Errors)
+		}
+		return &rawResponse{statusCode: statusCode, body: data}, nil
+	})
+
+	statusCode := 0
+	if result != nil {
+		statusCode = result.statusCode
+	}
+	var responseErrors chlorine.ResponseErrors
+	if idempotent && errors.As(err, &responseErrors) {
+		resp.Errors = responseErrors
+		err = nil
+	}
+	if err == nil && idempotent && len(result.body) > 0 {
+		err = json.Unmarshal(result.body, resp.Data)
+	}
 	if err != nil {
 		log.Error(
 			ctx,
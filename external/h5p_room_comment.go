@@ -124,7 +124,7 @@ query {
 		Data: &data,
 	}
 
-	_, err = GetH5PClient().Run(ctx, request, response)
+	_, err = GetH5PClient().RunQuery(ctx, buffer.String(), request, response)
 	if err != nil {
 		log.Error(ctx, "get room comments failed",
 			log.Err(err),
@@ -243,7 +243,7 @@ fragment scoresByUser on Room {
 		Data: &data,
 	}
 
-	_, err = GetH5PClient().Run(ctx, request, response)
+	_, err = GetH5PClient().RunQuery(ctx, buffer.String(), request, response)
 	if err != nil {
 		log.Error(ctx, "get room scores failed",
 			log.Err(err),
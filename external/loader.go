@@ -0,0 +1,244 @@
+package external
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/kidsloop-cache/cache"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+type loaderContextKeyType struct{}
+
+var loaderContextKey loaderContextKeyType
+
+// LoaderStats calls of a provider in one request
+type LoaderStats struct {
+	Calls int `json:"calls"`
+	IDs   int `json:"ids"`
+	// Loaded ids served by an earlier batch of the request
+	Loaded int `json:"loaded"`
+	// Batches upstream calls, Fetched the ids sent upstream after dedup
+	Batches int `json:"batches"`
+	Fetched int `json:"fetched"`
+}
+
+type statsLoader interface {
+	providerName() string
+	snapshot() LoaderStats
+}
+
+// requestLoaders loaders of one request, one for each provider and operator
+type requestLoaders struct {
+	ctx     context.Context
+	lock    sync.Mutex
+	loaders map[string]statsLoader
+}
+
+// WithLoaders BatchGet of the providers in ctx are batched and deduped until the request ends
+func WithLoaders(ctx context.Context) context.Context {
+	loaders := &requestLoaders{loaders: make(map[string]statsLoader)}
+	ctx = context.WithValue(ctx, loaderContextKey, loaders)
+	loaders.ctx = ctx
+	return ctx
+}
+
+// GetLoaderStats by provider name
+func GetLoaderStats(ctx context.Context) (map[string]LoaderStats, bool) {
+	loaders, ok := ctx.Value(loaderContextKey).(*requestLoaders)
+	if !ok {
+		return nil, false
+	}
+
+	loaders.lock.Lock()
+	defer loaders.lock.Unlock()
+
+	result := make(map[string]LoaderStats, len(loaders.loaders))
+	for _, loader := range loaders.loaders {
+		stats := loader.snapshot()
+		total := result[loader.providerName()]
+		total.Calls += stats.Calls
+		total.IDs += stats.IDs
+		total.Loaded += stats.Loaded
+		total.Batches += stats.Batches
+		total.Fetched += stats.Fetched
+		result[loader.providerName()] = total
+	}
+	return result, true
+}
+
+type loaderBatch[T cache.Object] struct {
+	ids    []string
+	done   chan struct{}
+	result map[string]T
+	err    error
+}
+
+// batchLoader ids requested within constant.ExternalLoaderWait are fetched in one batch,
+// the results are kept until the request ends
+type batchLoader[T cache.Object] struct {
+	name  string
+	ctx   context.Context
+	fetch func(ctx context.Context, ids []string) ([]T, error)
+
+	lock     sync.Mutex
+	loaded   map[string]T
+	missing  map[string]struct{}
+	inflight map[string]*loaderBatch[T]
+	pending  *loaderBatch[T]
+	stats    LoaderStats
+}
+
+func (l *batchLoader[T]) providerName() string {
+	return l.name
+}
+
+func (l *batchLoader[T]) snapshot() LoaderStats {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.stats
+}
+
+// Load items of ids in the order of ids, ids not found are skipped
+func (l *batchLoader[T]) Load(ctx context.Context, ids []string) ([]T, error) {
+	l.lock.Lock()
+	l.stats.Calls++
+	l.stats.IDs += len(ids)
+	found := make(map[string]T, len(ids))
+	batches := make(map[*loaderBatch[T]]struct{})
+	for _, id := range ids {
+		if item, ok := l.loaded[id]; ok {
+			found[id] = item
+			l.stats.Loaded++
+			continue
+		}
+		if _, ok := l.missing[id]; ok {
+			l.stats.Loaded++
+			continue
+		}
+		if batch, ok := l.inflight[id]; ok {
+			batches[batch] = struct{}{}
+			continue
+		}
+		if l.pending == nil {
+			l.pending = &loaderBatch[T]{done: make(chan struct{})}
+			go l.dispatch(l.pending)
+		}
+		l.pending.ids = append(l.pending.ids, id)
+		l.inflight[id] = l.pending
+		batches[l.pending] = struct{}{}
+	}
+	l.lock.Unlock()
+
+	for batch := range batches {
+		select {
+		case <-batch.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if batch.err != nil {
+			return nil, batch.err
+		}
+		for id, item := range batch.result {
+			found[id] = item
+		}
+	}
+
+	result := make([]T, 0, len(ids))
+	for _, id := range ids {
+		if item, ok := found[id]; ok {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func (l *batchLoader[T]) dispatch(batch *loaderBatch[T]) {
+	defer close(batch.done)
+
+	time.Sleep(constant.ExternalLoaderWait)
+	l.lock.Lock()
+	l.pending = nil
+	l.stats.Batches++
+	l.stats.Fetched += len(batch.ids)
+	l.lock.Unlock()
+
+	items, err := l.safeFetch(batch.ids)
+	result := make(map[string]T, len(items))
+	for _, item := range items {
+		result[item.StringID()] = item
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, id := range batch.ids {
+		delete(l.inflight, id)
+		if err != nil {
+			continue
+		}
+		if item, ok := result[id]; ok {
+			l.loaded[id] = item
+		} else {
+			l.missing[id] = struct{}{}
+		}
+	}
+	batch.result, batch.err = result, err
+}
+
+func (l *batchLoader[T]) safeFetch(ids []string) (items []T, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("external loader %s panic: %+v", l.name, rec)
+			log.Error(l.ctx, "batch load panic", log.Err(err), log.Strings("ids", ids))
+		}
+	}()
+	return l.fetch(l.ctx, ids)
+}
+
+// loadBatch without loaders in ctx, fetch is called directly
+func loadBatch[T cache.Object](ctx context.Context, operator *entity.Operator, name string, ids []string, fetch func(ctx context.Context, ids []string) ([]T, error)) ([]T, error) {
+	loaders, ok := ctx.Value(loaderContextKey).(*requestLoaders)
+	if !ok || operator == nil || len(ids) == 0 {
+		return fetch(ctx, ids)
+	}
+
+	// results depend on the permissions of the operator
+	key := fmt.Sprintf("%s:%s:%s:%s", name, operator.OrgID, operator.UserID, operator.Token)
+	loaders.lock.Lock()
+	loader, ok := loaders.loaders[key].(*batchLoader[T])
+	if !ok {
+		loader = &batchLoader[T]{
+			name:     name,
+			ctx:      loaders.ctx,
+			fetch:    fetch,
+			loaded:   make(map[string]T),
+			missing:  make(map[string]struct{}),
+			inflight: make(map[string]*loaderBatch[T]),
+		}
+		loaders.loaders[key] = loader
+	}
+	loaders.lock.Unlock()
+
+	return loader.Load(ctx, ids)
+}
+
+func loadPassiveObjects[T cache.Object](ctx context.Context, operator *entity.Operator, name string, ids []string) ([]T, error) {
+	return loadBatch(ctx, operator, name, ids, func(ctx context.Context, ids []string) ([]T, error) {
+		res := make([]T, 0, len(ids))
+		err := cache.GetPassiveCacheRefresher().BatchGet(ctx, name, ids, &res, operator)
+		return res, err
+	})
+}
+
+func loadHotObjects[T cache.Object](ctx context.Context, operator *entity.Operator, name string, ids []string) ([]T, error) {
+	return loadBatch(ctx, operator, name, ids, func(ctx context.Context, ids []string) ([]T, error) {
+		res := make([]T, 0, len(ids))
+		err := batchGetHotObjects(ctx, operator, name, ids, &res)
+		return res, err
+	})
+}
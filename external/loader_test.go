@@ -0,0 +1,144 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+// testLoaderFetch records the ids of each upstream call, ids starting with "missing" are not found
+type testLoaderFetch struct {
+	lock  sync.Mutex
+	calls [][]string
+	err   error
+}
+
+func (f *testLoaderFetch) fetch(ctx context.Context, ids []string) ([]*Age, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	sorted := append([]string{}, ids...)
+	sort.Strings(sorted)
+	f.calls = append(f.calls, sorted)
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	items := make([]*Age, 0, len(ids))
+	for _, id := range ids {
+		if !strings.HasPrefix(id, "missing") {
+			items = append(items, &Age{ID: id, Name: "age " + id})
+		}
+	}
+	return items, nil
+}
+
+func ageIDs(items []*Age) string {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestLoadBatchWithoutLoaders(t *testing.T) {
+	f := &testLoaderFetch{}
+	op := &entity.Operator{OrgID: "org", UserID: "user"}
+	for i := 0; i < 2; i++ {
+		if _, err := loadBatch(context.Background(), op, "age", []string{"1"}, f.fetch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(f.calls) != 2 {
+		t.Errorf("every call should be sent upstream without loaders, got %v", f.calls)
+	}
+}
+
+func TestLoadBatchConcurrentCalls(t *testing.T) {
+	ctx := WithLoaders(context.Background())
+	f := &testLoaderFetch{}
+	op := &entity.Operator{OrgID: "org", UserID: "user"}
+
+	requests := [][]string{{"1", "2"}, {"2", "3"}, {"3", "missing"}}
+	results := make([]string, len(requests))
+	start := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i, ids := range requests {
+		wg.Add(1)
+		go func(i int, ids []string) {
+			defer wg.Done()
+			<-start
+			items, err := loadBatch(ctx, op, "age", ids, f.fetch)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results[i] = ageIDs(items)
+		}(i, ids)
+	}
+	close(start)
+	wg.Wait()
+
+	if results[0] != "1,2" || results[1] != "2,3" || results[2] != "3" {
+		t.Errorf("unexpected results %v", results)
+	}
+	if len(f.calls) != 1 || strings.Join(f.calls[0], ",") != "1,2,3,missing" {
+		t.Errorf("calls in the wait should be sent in one deduped batch, got %v", f.calls)
+	}
+
+	// loaded and missing ids are served by the batch before
+	items, err := loadBatch(ctx, op, "age", []string{"missing", "3", "1"}, f.fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ageIDs(items) != "3,1" || len(f.calls) != 1 {
+		t.Errorf("expected 3,1 without upstream calls, got %s after %v", ageIDs(items), f.calls)
+	}
+
+	stats, ok := GetLoaderStats(ctx)
+	if !ok || stats["age"] != (LoaderStats{Calls: 4, IDs: 9, Loaded: 3, Batches: 1, Fetched: 4}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestLoadBatchOperators(t *testing.T) {
+	ctx := WithLoaders(context.Background())
+	f := &testLoaderFetch{}
+
+	// results depend on the permissions of the operator, they are not shared
+	for _, op := range []*entity.Operator{{OrgID: "org", UserID: "user"}, {OrgID: "org", UserID: "other"}} {
+		if _, err := loadBatch(ctx, op, "age", []string{"1"}, f.fetch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(f.calls) != 2 {
+		t.Errorf("each operator should have its own batch, got %v", f.calls)
+	}
+}
+
+func TestLoadBatchError(t *testing.T) {
+	ctx := WithLoaders(context.Background())
+	f := &testLoaderFetch{err: errors.New("upstream")}
+	op := &entity.Operator{OrgID: "org", UserID: "user"}
+
+	if _, err := loadBatch(ctx, op, "age", []string{"1"}, f.fetch); err != f.err {
+		t.Fatalf("expected the upstream error, got %v", err)
+	}
+
+	// failures are not kept, the next call fetches again
+	f.err = nil
+	items, err := loadBatch(ctx, op, "age", []string{"1"}, f.fetch)
+	if err != nil || ageIDs(items) != "1" || len(f.calls) != 2 {
+		t.Errorf("expected 1 fetched again, got %v %v after %v", ageIDs(items), err, f.calls)
+	}
+
+	// a panic of the fetch is returned as an error
+	panicking := func(ctx context.Context, ids []string) ([]*Age, error) { panic("boom") }
+	if _, err := loadBatch(ctx, op, "panic", []string{"1"}, panicking); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+}
@@ -46,6 +46,10 @@ func (n *NullableOrganization) RelatedIDs() []*cache.RelatedEntity {
 }
 
 func GetOrganizationServiceProvider() OrganizationServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Organizations()
+	}
+
 	if config.Get().AMS.UseDeprecatedQuery {
 		return &AmsOrganizationService{}
 	}
@@ -59,8 +63,7 @@ func (s AmsOrganizationService) BatchGet(ctx context.Context, operator *entity.O
 		return []*NullableOrganization{}, nil
 	}
 
-	res := make([]*NullableOrganization, 0, len(ids))
-	err := cache.GetPassiveCacheRefresher().BatchGet(ctx, s.Name(), ids, &res, operator)
+	res, err := loadPassiveObjects[*NullableOrganization](ctx, operator, s.Name(), ids)
 	if err != nil {
 		return nil, err
 	}
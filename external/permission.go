@@ -39,6 +39,10 @@ var (
 )
 
 func GetPermissionServiceProvider() PermissionServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Permissions()
+	}
+
 	_amsPermissionOnce.Do(func() {
 		_amsPermissionService = &AmsPermissionService{
 			client: chlorine.NewClient(config.Get().AMS.EndPoint),
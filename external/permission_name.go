@@ -177,6 +177,23 @@ var AllPermissionNames = []PermissionName{
 	ReportOrganizationTeachingLoad617,
 	ReportSchoolTeachingLoad618,
 	ReportMyTeachingLoad619,
+
+	ManageWebhooks10901,
+
+	ViewAuditLogs10902,
+	ManageAuditSettings10903,
+
+	ManageStudentRiskSettings10904,
+
+	ManageInsightRules10905,
+
+	ViewHeadquartersReports10906,
+
+	ShareLearnerReports10907,
+
+	ManageXAPIStatements10908,
+
+	ManageOrganizationAPIKeys10909,
 }
 
 // Important: If you add a new permission, you must also add it to the AllPermissionNames
@@ -351,6 +368,23 @@ const (
 	ReportOrganizationTeachingLoad617 = "report_organization_teaching_load_617"
 	ReportSchoolTeachingLoad618       = "report_school_teaching_load_618"
 	ReportMyTeachingLoad619           = "report_my_teaching_load_619"
+
+	ManageWebhooks10901 PermissionName = "manage_webhooks_10901"
+
+	ViewAuditLogs10902       PermissionName = "view_audit_logs_10902"
+	ManageAuditSettings10903 PermissionName = "manage_audit_settings_10903"
+
+	ManageStudentRiskSettings10904 PermissionName = "manage_student_risk_settings_10904"
+
+	ManageInsightRules10905 PermissionName = "manage_insight_rules_10905"
+
+	ViewHeadquartersReports10906 PermissionName = "view_headquarters_reports_10906"
+
+	ShareLearnerReports10907 PermissionName = "share_learner_reports_10907"
+
+	ManageXAPIStatements10908 PermissionName = "manage_xapi_statements_10908"
+
+	ManageOrganizationAPIKeys10909 PermissionName = "manage_organization_api_keys_10909"
 )
 
 type TeacherViewPermissionParams struct {
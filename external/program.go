@@ -37,6 +37,10 @@ func (n *Program) RelatedIDs() []*cache.RelatedEntity {
 	return nil
 }
 func GetProgramServiceProvider() ProgramServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Programs()
+	}
+
 	if config.Get().AMS.UseDeprecatedQuery {
 		return &AmsProgramService{}
 	}
@@ -59,8 +63,7 @@ func (s AmsProgramService) BatchGet(ctx context.Context, operator *entity.Operat
 		}
 	}
 
-	res := make([]*Program, 0, len(uuids))
-	err := cache.GetPassiveCacheRefresher().BatchGet(ctx, s.Name(), uuids, &res, operator)
+	res, err := loadHotObjects[*Program](ctx, operator, s.Name(), uuids)
 	if err != nil {
 		return nil, err
 	}
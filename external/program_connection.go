@@ -156,7 +156,7 @@ func (pcs AmsProgramConnectionService) QueryByIDs(ctx context.Context, ids []str
 	}
 	sb.WriteString("}")
 
-	request := NewRequest(sb.String(), RequestToken(operator.Token))
+	request := NewRequest(sb.String(), RequestToken(operator.Token), RequestStale())
 	for index, id := range _ids {
 		request.Var(fmt.Sprintf("program_id_%d", index), id)
 	}
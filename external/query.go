@@ -27,7 +27,7 @@ func pageQuery[ResType ConnectionResponse](ctx context.Context, operator *entity
 		res := GraphQLResponse[ResType]{
 			Data: map[string]ResType{},
 		}
-		err := fetch(ctx, operator, filter, pageInfo.Pager(Forward, PageDefaultCount), qString, &res)
+		err := fetch(ctx, operator, filter, pageInfo.Pager(Forward, PageDefaultCount), qString, filter.ConnectionName().Catalog(), &res)
 		if err != nil {
 			log.Error(ctx, "query: fetch failed",
 				log.Err(err),
@@ -46,8 +46,13 @@ func pageQuery[ResType ConnectionResponse](ctx context.Context, operator *entity
 	return nil
 }
 
-func fetch[ResType ConnectionResponse](ctx context.Context, operator *entity.Operator, filter interface{}, pager map[string]interface{}, query string, res *GraphQLResponse[ResType]) error {
-	req := NewRequest(query, RequestToken(operator.Token))
+// fetch the last good response of catalog connections may be served when ams is unavailable
+func fetch[ResType ConnectionResponse](ctx context.Context, operator *entity.Operator, filter interface{}, pager map[string]interface{}, query string, catalog bool, res *GraphQLResponse[ResType]) error {
+	options := []OptionFunc{RequestToken(operator.Token)}
+	if catalog {
+		options = append(options, RequestStale())
+	}
+	req := NewRequest(query, options...)
 	req.Var("filter", filter)
 	for k, v := range pager {
 		req.Var(k, v)
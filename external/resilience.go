@@ -0,0 +1,178 @@
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/ro"
+	"github.com/newrelic/go-agent/v3/newrelic"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils/resilience"
+)
+
+var (
+	_resilience     *resilience.Registry
+	_resilienceOnce sync.Once
+)
+
+// GetResilience breakers of the upstreams, one per endpoint
+func GetResilience() *resilience.Registry {
+	_resilienceOnce.Do(func() {
+		conf := config.Get().Resilience
+		_resilience = resilience.NewRegistry(resilience.Policy{
+			MaxAttempts:      conf.MaxAttempts,
+			BaseBackoff:      conf.BaseBackoff,
+			MaxBackoff:       conf.MaxBackoff,
+			HedgeDelay:       conf.HedgeDelay,
+			FailureThreshold: conf.FailureThreshold,
+			OpenTimeout:      conf.OpenTimeout,
+		})
+		_resilience.OnStateChange(func(name string, from, to resilience.State) {
+			log.Warn(context.Background(), "external circuit breaker state changed",
+				log.String("endpoint", name),
+				log.String("from", string(from)),
+				log.String("to", string(to)))
+		})
+	})
+	return _resilience
+}
+
+// GetExternalStatuses breakers and counters of this instance
+func GetExternalStatuses() []resilience.Status {
+	return GetResilience().Statuses()
+}
+
+// errUpstreamUnavailable gateway errors are blips of the upstream, they are retried
+type errUpstreamUnavailable struct {
+	statusCode int
+}
+
+func (e *errUpstreamUnavailable) Error() string {
+	return fmt.Sprintf("upstream unavailable: %d", e.statusCode)
+}
+
+func isUpstreamUnavailable(statusCode int) bool {
+	return statusCode == http.StatusBadGateway ||
+		statusCode == http.StatusServiceUnavailable ||
+		statusCode == http.StatusGatewayTimeout
+}
+
+type rawResponse struct {
+	statusCode int
+	body       []byte
+}
+
+// staleKey the last good response is only served to the same credentials,
+// so nobody gets data fetched with somebody else's permissions
+func staleKey(name string, request interface{}, header http.Header) (string, error) {
+	hash, err := utils.Hash([]interface{}{
+		name,
+		request,
+		header.Get(constant.CookieKey),
+		header.Get(constant.AMSAuthorizedHeaderKey),
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s", constant.RedisKeyPrefixExternalStale, hash), nil
+}
+
+func saveStale(ctx context.Context, key string, body []byte) {
+	expiration := config.Get().Resilience.StaleExpiration
+	if key == "" || expiration <= 0 {
+		return
+	}
+	err := ro.MustGetRedis(ctx).Set(ctx, key, body, expiration).Err()
+	if err != nil {
+		log.Warn(ctx, "save last good response failed", log.Err(err), log.String("key", key))
+	}
+}
+
+func loadStale(ctx context.Context, key string) ([]byte, bool) {
+	if key == "" || config.Get().Resilience.StaleExpiration <= 0 {
+		return nil, false
+	}
+	body, err := ro.MustGetRedis(ctx).Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+// hasGraphQLErrors responses with errors are never kept as the last good one
+func hasGraphQLErrors(body []byte) bool {
+	var response struct {
+		Errors json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return true
+	}
+	return len(response.Errors) > 0 && string(response.Errors) != "null" && string(response.Errors) != "[]"
+}
+
+// runResilient fn runs through the breaker of name, idempotent calls are retried and hedged.
+// The response is returned with a permanent error if fn returned both.
+// The last good response of an idempotent call with a stale key is served when the upstream is unavailable,
+// callers only pass the key of the lookups which opted in.
+func runResilient(ctx context.Context, name string, idempotent bool, key string, fn resilience.Func) (*rawResponse, error) {
+	registry := GetResilience()
+	result, err := registry.Do(ctx, name, idempotent, fn)
+	breaker := registry.Breaker(name)
+	defer recordBreakerMetric(ctx, breaker)
+
+	response, _ := result.(*rawResponse)
+	if err == nil {
+		if idempotent && response.statusCode == http.StatusOK && !hasGraphQLErrors(response.body) {
+			saveStale(ctx, key, response.body)
+		}
+		return response, nil
+	}
+
+	var unavailable *errUpstreamUnavailable
+	if !idempotent || ctx.Err() != nil ||
+		(!errors.Is(err, resilience.ErrCircuitOpen) && !errors.As(err, &unavailable) && !isTransportError(err)) {
+		return response, err
+	}
+	body, ok := loadStale(ctx, key)
+	if !ok {
+		return response, err
+	}
+
+	breaker.AddStaleServed(1)
+	if txn := newrelic.FromContext(ctx); txn != nil {
+		txn.AddAttribute("external_stale_served", name)
+	}
+	log.Warn(ctx, "serve last good response", log.Err(err), log.String("endpoint", name))
+	return &rawResponse{statusCode: http.StatusOK, body: body}, nil
+}
+
+// isTransportError the request didn't get a response, e.g. timeout or refused connection
+func isTransportError(err error) bool {
+	var transport *transportError
+	return errors.As(err, &transport)
+}
+
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+
+func recordBreakerMetric(ctx context.Context, breaker *resilience.Breaker) {
+	txn := newrelic.FromContext(ctx)
+	if txn == nil {
+		return
+	}
+	if app := txn.Application(); app != nil {
+		app.RecordCustomMetric(fmt.Sprintf("Custom/External/%s/BreakerState", breaker.Name()), breaker.State().Value())
+	}
+}
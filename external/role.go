@@ -21,6 +21,10 @@ var _roleOnce = sync.Once{}
 var _amsRoleService RoleServiceProvider
 
 func GetRoleServiceProvider() RoleServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Roles()
+	}
+
 	_roleOnce.Do(func() {
 		if config.Get().AMS.UseDeprecatedQuery {
 			_amsRoleService = &AmsRoleService{
@@ -307,6 +307,17 @@ const (
 
 type ConnectionType string
 
+// Catalog programs, subjects, categories, grades and ages, the same for everyone who can read them
+func (c ConnectionType) Catalog() bool {
+	switch c {
+	case ProgramsConnectionType, SubjectsConnectionType, CategoriesConnectionType, SubcategoriesConnectionType,
+		GradesConnectionType, AgeRangesConnectionType:
+		return true
+	default:
+		return false
+	}
+}
+
 const (
 	OrganizationsConnectionType     ConnectionType = "organizationsConnection"
 	SchoolsConnectionType           ConnectionType = "schoolsConnection"
@@ -54,6 +54,10 @@ var (
 )
 
 func GetSchoolServiceProvider() SchoolServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Schools()
+	}
+
 	_amsSchoolOnce.Do(func() {
 		if config.Get().AMS.UseDeprecatedQuery {
 			_amsSchoolService = &AmsSchoolService{
@@ -78,8 +82,7 @@ func (s AmsSchoolService) BatchGet(ctx context.Context, operator *entity.Operato
 	if len(ids) == 0 {
 		return []*NullableSchool{}, nil
 	}
-	res := make([]*NullableSchool, 0, len(ids))
-	err := cache.GetPassiveCacheRefresher().BatchGet(ctx, s.Name(), ids, &res, operator)
+	res, err := loadPassiveObjects[*NullableSchool](ctx, operator, s.Name(), ids)
 	if err != nil {
 		return nil, err
 	}
@@ -55,6 +55,10 @@ var (
 )
 
 func GetStudentServiceProvider() StudentServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Students()
+	}
+
 	_amsStudentOnce.Do(func() {
 		if config.Get().AMS.UseDeprecatedQuery {
 			_amsStudentService = &AmsStudentService{}
@@ -38,6 +38,10 @@ func (n *SubCategory) RelatedIDs() []*cache.RelatedEntity {
 }
 
 func GetSubCategoryServiceProvider() SubCategoryServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.SubCategories()
+	}
+
 	if config.Get().AMS.UseDeprecatedQuery {
 		return &AmsSubCategoryService{}
 	}
@@ -60,8 +64,7 @@ func (s AmsSubCategoryService) BatchGet(ctx context.Context, operator *entity.Op
 		}
 	}
 
-	res := make([]*SubCategory, 0, len(uuids))
-	err := cache.GetPassiveCacheRefresher().BatchGet(ctx, s.Name(), uuids, &res, operator)
+	res, err := loadPassiveObjects[*SubCategory](ctx, operator, s.Name(), uuids)
 	if err != nil {
 		return nil, err
 	}
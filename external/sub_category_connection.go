@@ -170,7 +170,7 @@ func (sccs AmsSubCategoryConnectionService) QueryByIDs(ctx context.Context, ids
 	}
 	sb.WriteString("}")
 
-	request := NewRequest(sb.String(), RequestToken(operator.Token))
+	request := NewRequest(sb.String(), RequestToken(operator.Token), RequestStale())
 	for index, id := range _ids {
 		request.Var(fmt.Sprintf("subcategory_id_%d", index), id)
 	}
@@ -37,6 +37,10 @@ func (n *Subject) RelatedIDs() []*cache.RelatedEntity {
 }
 
 func GetSubjectServiceProvider() SubjectServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Subjects()
+	}
+
 	if config.Get().AMS.UseDeprecatedQuery {
 		return &AmsSubjectService{}
 	}
@@ -120,8 +124,7 @@ func (s AmsSubjectService) BatchGet(ctx context.Context, operator *entity.Operat
 		}
 	}
 
-	res := make([]*Subject, 0, len(uuids))
-	err := cache.GetPassiveCacheRefresher().BatchGet(ctx, s.Name(), uuids, &res, operator)
+	res, err := loadHotObjects[*Subject](ctx, operator, s.Name(), uuids)
 	if err != nil {
 		return nil, err
 	}
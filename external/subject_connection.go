@@ -168,7 +168,7 @@ func (scs AmsSubjectConnectionService) QueryByIDs(ctx context.Context, ids []str
 	}
 	sb.WriteString("}")
 
-	request := NewRequest(sb.String(), RequestToken(operator.Token))
+	request := NewRequest(sb.String(), RequestToken(operator.Token), RequestStale())
 	for index, id := range _ids {
 		request.Var(fmt.Sprintf("subject_id_%d", index), id)
 	}
@@ -55,6 +55,10 @@ var (
 )
 
 func GetTeacherServiceProvider() TeacherServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Teachers()
+	}
+
 	_amsTeacherOnce.Do(func() {
 		if config.Get().AMS.UseDeprecatedQuery {
 			_amsTeacherService = &AmsTeacherService{}
@@ -65,6 +65,10 @@ var (
 )
 
 func GetUserServiceProvider() UserServiceProvider {
+	if provider := getDirectoryProvider(); provider != nil {
+		return provider.Users()
+	}
+
 	_amsUserOnce.Do(func() {
 		if config.Get().AMS.UseDeprecatedQuery {
 			_amsUserService = &AmsUserService{}
@@ -192,8 +196,7 @@ func (s AmsUserService) BatchGet(ctx context.Context, operator *entity.Operator,
 		}
 	}
 
-	res := make([]*NullableUser, 0, len(uuids))
-	err := cache.GetPassiveCacheRefresher().BatchGet(ctx, s.Name(), uuids, &res, operator)
+	res, err := loadPassiveObjects[*NullableUser](ctx, operator, s.Name(), uuids)
 	if err != nil {
 		return nil, err
 	}
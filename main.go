@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/KL-Engineering/common-log/log"
 	"github.com/KL-Engineering/decorator"
@@ -12,6 +14,7 @@ import (
 	"github.com/KL-Engineering/kidsloop-cms-service/constant"
 	"github.com/KL-Engineering/kidsloop-cms-service/da"
 	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/model"
 	"github.com/KL-Engineering/kidsloop-cms-service/model/storage"
 	"github.com/KL-Engineering/kidsloop-cms-service/utils/kl2cache"
 	"github.com/KL-Engineering/tracecontext"
@@ -47,6 +50,7 @@ func initCache(ctx context.Context) {
 		kl2cache.OptEnable(conf.RedisConfig.OpenCache),
 		kl2cache.OptRedis(conf.RedisConfig.Host, conf.RedisConfig.Port, conf.RedisConfig.Password),
 		kl2cache.OptStrategyFixed(constant.MaxCacheExpire),
+		kl2cache.OptLocal(conf.RedisConfig.LocalCacheCapacity, conf.RedisConfig.LocalCacheExpiration),
 	)
 	if err != nil {
 		log.Panic(ctx, "kl2cache.Init failed", log.Err(err))
@@ -109,9 +113,22 @@ func main() {
 	da.InitMySQL(ctx)
 	log.Debug(ctx, "init db successfully")
 
+	// the directory provider must be set before the providers are registered as cache data sources
+	if config.Get().Directory.Provider == constant.DirectoryProviderLocal {
+		external.SetDirectoryProvider(model.GetLocalDirectory())
+	}
+
 	initCache(ctx)
 	log.Debug(ctx, "init cache successfully")
 
+	// seeding invalidates the cached directory rows
+	if config.Get().Directory.Provider == constant.DirectoryProviderLocal {
+		if err := model.SeedDirectory(ctx, config.Get().Directory.SeedDir); err != nil {
+			log.Panic(ctx, "seed directory failed", log.Err(err))
+		}
+		log.Debug(ctx, "init local directory successfully")
+	}
+
 	storage.DefaultStorage()
 	log.Debug(ctx, "init storage successfully")
 
@@ -125,5 +142,29 @@ func main() {
 
 	log.Debug(ctx, "init api server successfully")
 
-	select {}
+	go model.StartDomainEventWorker(ctx)
+	go model.StartAssessmentAutoCompleteWorker(ctx)
+	go model.StartWebhookWorker(ctx)
+	go model.StartJobWorker(ctx)
+	go model.StartAuditRetentionWorker(ctx)
+	go model.StartReportRollupWorker(ctx)
+	go model.StartReportSubscriptionWorker(ctx)
+	go model.StartStudentRiskWorker(ctx)
+	go model.StartXAPIForwardWorker(ctx)
+
+	// the student usage records acknowledged but still queued are written before the process exits
+	ingestCtx, stopIngest := context.WithCancel(ctx)
+	ingestStopped := make(chan struct{})
+	go func() {
+		defer close(ingestStopped)
+		model.StartStudentUsageIngestWorker(ingestCtx)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+	log.Info(ctx, "kidsloop2 api service stopping", log.String("signal", sig.String()))
+
+	stopIngest()
+	<-ingestStopped
 }
@@ -44,6 +44,72 @@ type IAssessmentProcessor interface {
 	Update(ctx context.Context, op *entity.Operator, assessment *v2.Assessment, req *v2.AssessmentUpdateReq) error
 }
 
+type assessmentUpdateTxKey struct{}
+
+// withAssessmentUpdateTx fn runs in the transaction of the next processor update, so the writes of the caller
+// are committed or rolled back along with the assessment. fns added to the same ctx run in the order they were added
+func withAssessmentUpdateTx(ctx context.Context, fn func(ctx context.Context, tx *dbo.DBContext) error) context.Context {
+	previous, ok := ctx.Value(assessmentUpdateTxKey{}).(func(ctx context.Context, tx *dbo.DBContext) error)
+	if !ok || previous == nil {
+		return context.WithValue(ctx, assessmentUpdateTxKey{}, fn)
+	}
+
+	return context.WithValue(ctx, assessmentUpdateTxKey{}, func(ctx context.Context, tx *dbo.DBContext) error {
+		if err := previous(ctx, tx); err != nil {
+			return err
+		}
+		return fn(ctx, tx)
+	})
+}
+
+// withAssessmentCompletedTx the completion events are written to the outbox by the transaction completing the assessment,
+// nothing is written by the updates which don't complete it, e.g. the draft saved before moderation
+func withAssessmentCompletedTx(ctx context.Context, op *entity.Operator, assessment *v2.Assessment) context.Context {
+	return withAssessmentUpdateTx(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if assessment.Status != v2.AssessmentStatusComplete {
+			return nil
+		}
+		emitAssessmentCompletedTx(ctx, tx, op, assessment)
+		emitXAPIAssessmentCompletedTx(ctx, tx, op, assessment)
+		return nil
+	})
+}
+
+// withReportRollupDirtyTx the days of the assessment's schedule are marked dirty by the transaction updating it
+func withReportRollupDirtyTx(ctx context.Context, assessment *v2.Assessment) context.Context {
+	return withAssessmentUpdateTx(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		return GetReportRollupModel().MarkDirtyTx(ctx, tx, assessment.ScheduleID)
+	})
+}
+
+// runAssessmentUpdateTx processors call it at the end of their update transaction
+func runAssessmentUpdateTx(ctx context.Context, tx *dbo.DBContext) error {
+	fn, ok := ctx.Value(assessmentUpdateTxKey{}).(func(ctx context.Context, tx *dbo.DBContext) error)
+	if !ok || fn == nil {
+		return nil
+	}
+
+	return fn(ctx, tx)
+}
+
+type assessmentUpdateCheckTxKey struct{}
+
+// withAssessmentUpdateCheckTx fn runs first in the transaction of the next processor update,
+// an error of it rolls the update back
+func withAssessmentUpdateCheckTx(ctx context.Context, fn func(ctx context.Context, tx *dbo.DBContext) error) context.Context {
+	return context.WithValue(ctx, assessmentUpdateCheckTxKey{}, fn)
+}
+
+// checkAssessmentUpdateTx processors call it at the beginning of their update transaction
+func checkAssessmentUpdateTx(ctx context.Context, tx *dbo.DBContext) error {
+	fn, ok := ctx.Value(assessmentUpdateCheckTxKey{}).(func(ctx context.Context, tx *dbo.DBContext) error)
+	if !ok || fn == nil {
+		return nil
+	}
+
+	return fn(ctx, tx)
+}
+
 type IAssessmentModelV2 interface {
 	Update(ctx context.Context, op *entity.Operator, req *v2.AssessmentUpdateReq) error
 
@@ -628,7 +694,7 @@ func (a *assessmentModelV2) StatisticsCount(ctx context.Context, op *entity.Oper
 	r := new(v2.AssessmentsSummary)
 	for _, a := range assessments {
 		switch a.Status {
-		case v2.AssessmentStatusStarted, v2.AssessmentStatusInDraft:
+		case v2.AssessmentStatusStarted, v2.AssessmentStatusInDraft, v2.AssessmentStatusPendingModeration:
 			r.InProgress++
 		case v2.AssessmentStatusComplete:
 			r.Complete++
@@ -834,6 +900,33 @@ func (a *assessmentModelV2) Update(ctx context.Context, op *entity.Operator, req
 		log.Warn(ctx, "assessment has completed", log.Any("assessment", waitUpdatedAssessment), log.Any("req", req))
 		return ErrAssessmentHasCompleted
 	}
+	if waitUpdatedAssessment.Status == v2.AssessmentStatusPendingModeration {
+		log.Warn(ctx, "assessment is pending moderation", log.Any("assessment", waitUpdatedAssessment), log.Any("req", req))
+		return ErrAssessmentPendingModeration
+	}
+
+	ctx = withAssessmentCompletedTx(ctx, op, waitUpdatedAssessment)
+	ctx = withReportRollupDirtyTx(ctx, waitUpdatedAssessment)
+	if req.Action == v2.AssessmentActionComplete {
+		waitUpdatedAssessment.CompleteBy = v2.AssessmentCompleteByTeacher
+
+		held, err := GetAssessmentModerationModel().Submit(ctx, op, waitUpdatedAssessment, req)
+		if err != nil {
+			return err
+		}
+		if held {
+			return nil
+		}
+	}
+
+	err = AssessmentProcessorMap[waitUpdatedAssessment.AssessmentType].Update(ctx, op, waitUpdatedAssessment, req)
+	if err != nil {
+		return err
+	}
+
+	if req.Action == v2.AssessmentActionComplete {
+		syncGradebookAsync(ctx, op, req.ID)
+	}
 
-	return AssessmentProcessorMap[waitUpdatedAssessment.AssessmentType].Update(ctx, op, waitUpdatedAssessment, req)
+	return nil
 }
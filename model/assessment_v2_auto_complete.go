@@ -0,0 +1,575 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/da/assessmentV2"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/mutex"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+var (
+	assessmentAutoCompleteModelInstance     IAssessmentAutoCompleteModel
+	assessmentAutoCompleteModelInstanceOnce = sync.Once{}
+)
+
+type IAssessmentAutoCompleteModel interface {
+	QueryRules(ctx context.Context, op *entity.Operator) ([]*v2.AssessmentAutoCompleteRuleReply, error)
+	AddRule(ctx context.Context, op *entity.Operator, req *v2.AssessmentAutoCompleteRuleAddReq) (string, error)
+	UpdateRule(ctx context.Context, op *entity.Operator, req *v2.AssessmentAutoCompleteRuleUpdateReq) error
+	DeleteRule(ctx context.Context, op *entity.Operator, id string) error
+
+	// Run evaluate rules of all orgs once, a bounded batch of each org until the run times out,
+	// returns the number of assessments completed by the system
+	Run(ctx context.Context) (int, error)
+	// Reopen put an assessment completed by the system back to draft
+	Reopen(ctx context.Context, op *entity.Operator, id string) error
+}
+
+type assessmentAutoCompleteModel struct {
+	permission *AssessmentPermission
+}
+
+func GetAssessmentAutoCompleteModel() IAssessmentAutoCompleteModel {
+	assessmentAutoCompleteModelInstanceOnce.Do(func() {
+		assessmentAutoCompleteModelInstance = &assessmentAutoCompleteModel{
+			permission: new(AssessmentPermission),
+		}
+	})
+	return assessmentAutoCompleteModelInstance
+}
+
+// StartAssessmentAutoCompleteWorker evaluate auto complete rules periodically, instances take turns by a distributed lock
+func StartAssessmentAutoCompleteWorker(ctx context.Context) {
+	conf := config.Get().Assessment
+	if conf.AutoCompleteInterval <= 0 {
+		log.Info(ctx, "assessment auto complete worker is disabled")
+		return
+	}
+	if config.Get().H5P.AuthorizedKey == "" {
+		log.Warn(ctx, "h5p authorized key is empty, rules on H5P scores can not be evaluated")
+	}
+
+	ticker := time.NewTicker(conf.AutoCompleteInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		runAssessmentAutoComplete(utils.CloneContextWithTrace(ctx))
+	}
+}
+
+func runAssessmentAutoComplete(ctx context.Context) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(ctx, "assessment auto complete panic", log.Any("recover error", err))
+		}
+	}()
+
+	locker, err := mutex.NewLock(ctx, da.RedisKeyPrefixAssessmentAutoCompleteLock)
+	if err != nil {
+		log.Error(ctx, "assessment auto complete: create lock failed", log.Err(err))
+		return
+	}
+	locker.Lock()
+	defer locker.Unlock()
+
+	count, err := GetAssessmentAutoCompleteModel().Run(ctx)
+	if err != nil {
+		log.Error(ctx, "assessment auto complete failed", log.Err(err))
+		return
+	}
+
+	log.Info(ctx, "assessment auto complete finished", log.Int("count", count))
+}
+
+func (m *assessmentAutoCompleteModel) QueryRules(ctx context.Context, op *entity.Operator) ([]*v2.AssessmentAutoCompleteRuleReply, error) {
+	if err := m.permission.IsAllowOrgSettings(ctx, op); err != nil {
+		return nil, err
+	}
+
+	rules, err := m.queryRules(ctx, op.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*v2.AssessmentAutoCompleteRuleReply, 0, len(rules))
+	for _, item := range rules {
+		result = append(result, &v2.AssessmentAutoCompleteRuleReply{
+			ID:             item.ID,
+			AssessmentType: item.AssessmentType,
+			Trigger:        item.Trigger,
+			Days:           item.Days,
+			CompletionRate: item.CompletionRate,
+			UpdateAt:       item.UpdateAt,
+		})
+	}
+
+	return result, nil
+}
+
+func (m *assessmentAutoCompleteModel) AddRule(ctx context.Context, op *entity.Operator, req *v2.AssessmentAutoCompleteRuleAddReq) (string, error) {
+	if !req.Valid() {
+		log.Warn(ctx, "auto complete rule request invalid", log.Any("req", req))
+		return "", constant.ErrInvalidArgs
+	}
+
+	if err := m.permission.IsAllowOrgSettings(ctx, op); err != nil {
+		return "", err
+	}
+
+	now := time.Now().Unix()
+	rule := &v2.AssessmentAutoCompleteRule{
+		ID:             utils.NewID(),
+		OrgID:          op.OrgID,
+		AssessmentType: req.AssessmentType,
+		Trigger:        req.Trigger,
+		Days:           req.Days,
+		CompletionRate: req.CompletionRate,
+		CreatorID:      op.UserID,
+		CreateAt:       now,
+		UpdateAt:       now,
+	}
+	if _, err := assessmentV2.GetAssessmentAutoCompleteRuleDA().Insert(ctx, rule); err != nil {
+		log.Error(ctx, "add auto complete rule error", log.Err(err), log.Any("rule", rule))
+		return "", err
+	}
+
+	return rule.ID, nil
+}
+
+func (m *assessmentAutoCompleteModel) UpdateRule(ctx context.Context, op *entity.Operator, req *v2.AssessmentAutoCompleteRuleUpdateReq) error {
+	if !req.Valid() {
+		log.Warn(ctx, "auto complete rule request invalid", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	if err := m.permission.IsAllowOrgSettings(ctx, op); err != nil {
+		return err
+	}
+
+	rule, err := m.getRule(ctx, op, req.ID)
+	if err != nil {
+		return err
+	}
+
+	rule.AssessmentType = req.AssessmentType
+	rule.Trigger = req.Trigger
+	rule.Days = req.Days
+	rule.CompletionRate = req.CompletionRate
+	rule.UpdateAt = time.Now().Unix()
+	if _, err := assessmentV2.GetAssessmentAutoCompleteRuleDA().Update(ctx, rule); err != nil {
+		log.Error(ctx, "update auto complete rule error", log.Err(err), log.Any("rule", rule))
+		return err
+	}
+
+	return nil
+}
+
+func (m *assessmentAutoCompleteModel) DeleteRule(ctx context.Context, op *entity.Operator, id string) error {
+	if err := m.permission.IsAllowOrgSettings(ctx, op); err != nil {
+		return err
+	}
+
+	rule, err := m.getRule(ctx, op, id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	rule.UpdateAt = now
+	rule.DeleteAt = now
+	if _, err := assessmentV2.GetAssessmentAutoCompleteRuleDA().Update(ctx, rule); err != nil {
+		log.Error(ctx, "delete auto complete rule error", log.Err(err), log.Any("rule", rule))
+		return err
+	}
+
+	return nil
+}
+
+func (m *assessmentAutoCompleteModel) Run(ctx context.Context) (int, error) {
+	rules, err := m.queryRules(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	orgIDs := make([]string, 0)
+	orgRules := make(map[string][]*v2.AssessmentAutoCompleteRule)
+	for _, item := range rules {
+		if _, ok := orgRules[item.OrgID]; !ok {
+			orgIDs = append(orgIDs, item.OrgID)
+		}
+		orgRules[item.OrgID] = append(orgRules[item.OrgID], item)
+	}
+
+	// orgs late in the list are not always the ones left when the run times out
+	rand.Shuffle(len(orgIDs), func(i, j int) {
+		orgIDs[i], orgIDs[j] = orgIDs[j], orgIDs[i]
+	})
+
+	deadline := time.Now().Add(constant.AssessmentAutoCompleteRunTimeout)
+	total := 0
+	for _, orgID := range orgIDs {
+		if time.Now().After(deadline) {
+			log.Warn(ctx, "auto complete run timed out, the rest orgs are evaluated next time", log.String("orgID", orgID))
+			break
+		}
+
+		count, err := m.runOrg(ctx, orgID, orgRules[orgID], deadline)
+		total += count
+		if err != nil {
+			// one org should not block the others
+			log.Warn(ctx, "auto complete assessments of org failed", log.Err(err), log.String("orgID", orgID))
+		}
+	}
+
+	return total, nil
+}
+
+func (m *assessmentAutoCompleteModel) runOrg(ctx context.Context, orgID string, rules []*v2.AssessmentAutoCompleteRule, deadline time.Time) (int, error) {
+	// the operator has no token, ams and h5p are called with the authorized keys
+	op := &entity.Operator{
+		OrgID: orgID,
+	}
+
+	assessments, err := m.queryCandidates(ctx, orgID, rules)
+	if err != nil {
+		return 0, err
+	}
+	if len(assessments) <= 0 {
+		return 0, nil
+	}
+
+	dueAtMap, err := m.getDueAtMap(ctx, assessments)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	now := time.Now().Unix()
+	checkedIDs := make([]string, 0, len(assessments))
+	for _, assessment := range assessments {
+		if time.Now().After(deadline) {
+			break
+		}
+		checkedIDs = append(checkedIDs, assessment.ID)
+
+		completed, err := m.evaluate(ctx, op, rules, assessment, dueAtMap[assessment.ScheduleID], now)
+		if err != nil {
+			log.Warn(ctx, "auto complete assessment failed", log.Err(err), log.Any("assessment", assessment))
+			continue
+		}
+		if completed {
+			count++
+		}
+	}
+
+	if err := assessmentV2.GetAssessmentDA().MarkAutoCompleteChecked(ctx, checkedIDs, now); err != nil {
+		return count, err
+	}
+
+	return count, nil
+}
+
+// queryCandidates a batch of unfinished assessments, the least recently evaluated first,
+// so every assessment is evaluated in turn however many an org has
+func (m *assessmentAutoCompleteModel) queryCandidates(ctx context.Context, orgID string, rules []*v2.AssessmentAutoCompleteRule) ([]*v2.Assessment, error) {
+	assessmentTypes := make([]string, 0, len(rules))
+	for _, item := range rules {
+		assessmentTypes = append(assessmentTypes, item.AssessmentType.String())
+	}
+
+	condition := &assessmentV2.AssessmentCondition{
+		OrgID: sql.NullString{
+			String: orgID,
+			Valid:  true,
+		},
+		AssessmentTypes: entity.NullStrings{
+			Strings: utils.SliceDeduplication(assessmentTypes),
+			Valid:   true,
+		},
+		Status: entity.NullStrings{
+			Strings: []string{
+				v2.AssessmentStatusNotStarted.String(),
+				v2.AssessmentStatusStarted.String(),
+				v2.AssessmentStatusInDraft.String(),
+			},
+			Valid: true,
+		},
+		ReopenedAt: sql.NullInt64{
+			Int64: 0,
+			Valid: true,
+		},
+		OrderBy: assessmentV2.AssessmentOrderByAutoCompleteCheckedAtAsc,
+		Pager: dbo.Pager{
+			Page:     1,
+			PageSize: constant.AssessmentAutoCompleteBatchSize,
+		},
+	}
+
+	var result []*v2.Assessment
+	if _, err := assessmentV2.GetAssessmentDA().Page(ctx, condition, &result); err != nil {
+		log.Error(ctx, "query auto complete candidates error", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (m *assessmentAutoCompleteModel) getDueAtMap(ctx context.Context, assessments []*v2.Assessment) (map[string]int64, error) {
+	scheduleIDs := make([]string, 0, len(assessments))
+	for _, item := range assessments {
+		if item.AssessmentType == v2.AssessmentTypeOnlineStudy {
+			scheduleIDs = append(scheduleIDs, item.ScheduleID)
+		}
+	}
+
+	result := make(map[string]int64, len(scheduleIDs))
+	if len(scheduleIDs) <= 0 {
+		return result, nil
+	}
+
+	schedules, err := GetScheduleModel().QueryUnsafe(ctx, &entity.ScheduleQueryCondition{
+		IDs: entity.NullStrings{
+			Strings: scheduleIDs,
+			Valid:   true,
+		},
+	})
+	if err != nil {
+		log.Error(ctx, "get schedules error", log.Err(err), log.Strings("scheduleIDs", scheduleIDs))
+		return nil, err
+	}
+	for _, item := range schedules {
+		result[item.ID] = item.DueAt
+	}
+
+	return result, nil
+}
+
+func (m *assessmentAutoCompleteModel) evaluate(ctx context.Context, op *entity.Operator, rules []*v2.AssessmentAutoCompleteRule, assessment *v2.Assessment, dueAt int64, now int64) (bool, error) {
+	input := &v2.AssessmentAutoCompleteInput{
+		AssessmentType: assessment.AssessmentType,
+		ClassEndAt:     assessment.ClassEndAt,
+		DueAt:          dueAt,
+		ReopenedAt:     assessment.ReopenedAt,
+	}
+
+	matched := m.matchAny(rules, input, now)
+	// nobody has H5P results before the assessment is started
+	if !matched && assessment.Status != v2.AssessmentStatusNotStarted && m.needDetail(rules, assessment.AssessmentType) {
+		detail, err := ConvertAssessmentDetailReply(ctx, op, assessment)
+		if err != nil {
+			return false, err
+		}
+		input.Detail = detail
+		matched = m.matchAny(rules, input, now)
+	}
+	if !matched {
+		return false, nil
+	}
+
+	if input.Detail == nil {
+		detail, err := ConvertAssessmentDetailReply(ctx, op, assessment)
+		if err != nil {
+			return false, err
+		}
+		input.Detail = detail
+	}
+
+	req := v2.NewAssessmentAutoCompleteUpdateReq(input.Detail)
+	if len(req.Students) <= 0 {
+		log.Debug(ctx, "assessment has no students to complete", log.Any("assessment", assessment))
+		return false, nil
+	}
+
+	assessment.CompleteBy = v2.AssessmentCompleteBySystem
+
+	ctx = withAssessmentCompletedTx(ctx, op, assessment)
+	ctx = withReportRollupDirtyTx(ctx, assessment)
+	// another run, a teacher or a reopen may have changed it since it was queried
+	ctx = withAssessmentUpdateCheckTx(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		current, err := assessmentV2.GetAssessmentDA().GetForUpdateTx(ctx, tx, assessment.ID)
+		if err != nil {
+			return err
+		}
+		if current.ReopenedAt > 0 || current.DeleteAt > 0 ||
+			(current.Status != v2.AssessmentStatusNotStarted &&
+				current.Status != v2.AssessmentStatusStarted &&
+				current.Status != v2.AssessmentStatusInDraft) {
+			log.Info(ctx, "assessment changed before auto complete", log.Any("assessment", current))
+			return ErrAssessmentHasCompleted
+		}
+		return nil
+	})
+
+	held, err := GetAssessmentModerationModel().Submit(ctx, op, assessment, req)
+	if err == ErrAssessmentHasCompleted {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if held {
+		return true, nil
+	}
+
+	err = AssessmentProcessorMap[assessment.AssessmentType].Update(ctx, op, assessment, req)
+	if err == ErrAssessmentHasCompleted {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := GetAssessmentGradebookModel().SyncAssessment(ctx, op, assessment.ID); err != nil {
+		log.Warn(ctx, "sync gradebook failed", log.Err(err), log.String("assessmentID", assessment.ID))
+	}
+
+	return true, nil
+}
+
+func (m *assessmentAutoCompleteModel) matchAny(rules []*v2.AssessmentAutoCompleteRule, input *v2.AssessmentAutoCompleteInput, now int64) bool {
+	for _, item := range rules {
+		if item.Match(input, now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *assessmentAutoCompleteModel) needDetail(rules []*v2.AssessmentAutoCompleteRule, assessmentType v2.AssessmentType) bool {
+	for _, item := range rules {
+		if item.AssessmentType == assessmentType && item.NeedDetail() {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *assessmentAutoCompleteModel) Reopen(ctx context.Context, op *entity.Operator, id string) error {
+	if err := m.permission.IsAllowEdit(ctx, op); err != nil {
+		return err
+	}
+
+	assessment := new(v2.Assessment)
+	err := assessmentV2.GetAssessmentDA().Get(ctx, id, assessment)
+	if err == dbo.ErrRecordNotFound {
+		return constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get assessment error", log.Err(err), log.String("id", id))
+		return err
+	}
+	if assessment.OrgID != op.OrgID {
+		log.Warn(ctx, "assessment not found in org", log.Any("assessment", assessment), log.Any("op", op))
+		return constant.ErrRecordNotFound
+	}
+
+	// teachers' own decisions are final, only what the system completed can be reopened
+	if assessment.Status != v2.AssessmentStatusComplete || assessment.CompleteBy != v2.AssessmentCompleteBySystem {
+		log.Warn(ctx, "assessment is not completed by system", log.Any("assessment", assessment))
+		return constant.ErrInvalidArgs
+	}
+
+	var assessmentUsers []*v2.AssessmentUser
+	err = assessmentV2.GetAssessmentUserDA().Query(ctx, &assessmentV2.AssessmentUserCondition{
+		AssessmentID: sql.NullString{
+			String: assessment.ID,
+			Valid:  true,
+		},
+		UserType: sql.NullString{
+			String: v2.AssessmentUserTypeStudent.String(),
+			Valid:  true,
+		},
+	}, &assessmentUsers)
+	if err != nil {
+		log.Error(ctx, "query assessment users error", log.Err(err), log.String("assessmentID", assessment.ID))
+		return err
+	}
+
+	now := time.Now().Unix()
+	waitUpdatedUsers := make([]*v2.AssessmentUser, 0, len(assessmentUsers))
+	for _, item := range assessmentUsers {
+		if item.StatusBySystem != v2.AssessmentUserSystemStatusCompleted {
+			continue
+		}
+		item.StatusBySystem = v2.AssessmentUserSystemStatusDone
+		item.CompletedAt = 0
+		item.UpdateAt = now
+		waitUpdatedUsers = append(waitUpdatedUsers, item)
+	}
+
+	assessment.Status = v2.AssessmentStatusInDraft
+	assessment.CompleteAt = 0
+	assessment.CompleteBy = ""
+	assessment.ReopenedAt = now
+	assessment.UpdateAt = now
+
+	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if _, err := assessmentV2.GetAssessmentDA().UpdateTx(ctx, tx, assessment); err != nil {
+			return err
+		}
+		if len(waitUpdatedUsers) > 0 {
+			if _, err := assessmentV2.GetAssessmentUserDA().UpdateTx(ctx, tx, waitUpdatedUsers); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(ctx, "reopen assessment error", log.Err(err), log.Any("assessment", assessment))
+		return err
+	}
+
+	if err := GetAssessmentGradebookModel().RemoveAssessment(ctx, assessment.ID); err != nil {
+		log.Warn(ctx, "remove assessment from gradebook failed", log.Err(err), log.String("assessmentID", assessment.ID))
+	}
+
+	return nil
+}
+
+func (m *assessmentAutoCompleteModel) queryRules(ctx context.Context, orgID string) ([]*v2.AssessmentAutoCompleteRule, error) {
+	var rules []*v2.AssessmentAutoCompleteRule
+	err := assessmentV2.GetAssessmentAutoCompleteRuleDA().Query(ctx, &assessmentV2.AssessmentAutoCompleteRuleCondition{
+		OrgID: sql.NullString{
+			String: orgID,
+			Valid:  orgID != "",
+		},
+	}, &rules)
+	if err != nil {
+		log.Error(ctx, "query auto complete rules error", log.Err(err), log.String("orgID", orgID))
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (m *assessmentAutoCompleteModel) getRule(ctx context.Context, op *entity.Operator, id string) (*v2.AssessmentAutoCompleteRule, error) {
+	rule := new(v2.AssessmentAutoCompleteRule)
+	err := assessmentV2.GetAssessmentAutoCompleteRuleDA().Get(ctx, id, rule)
+	if err == dbo.ErrRecordNotFound {
+		return nil, constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get auto complete rule error", log.Err(err), log.String("id", id))
+		return nil, err
+	}
+
+	if rule.OrgID != op.OrgID || rule.DeleteAt != 0 {
+		log.Warn(ctx, "auto complete rule not found in org", log.Any("rule", rule), log.Any("op", op))
+		return nil, constant.ErrRecordNotFound
+	}
+
+	return rule, nil
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+)
+
+func TestAssessmentAutoCompleteReopened(t *testing.T) {
+	now := time.Now().Unix()
+	rules := []*v2.AssessmentAutoCompleteRule{
+		{AssessmentType: v2.AssessmentTypeOnlineClass, Trigger: v2.AssessmentAutoCompleteTriggerDaysAfterClassEnd},
+	}
+	assessment := &v2.Assessment{
+		ID:             "assessment",
+		OrgID:          "org",
+		AssessmentType: v2.AssessmentTypeOnlineClass,
+		Status:         v2.AssessmentStatusInDraft,
+		ClassEndAt:     now - 24*60*60,
+		ReopenedAt:     now - 60,
+	}
+
+	m := &assessmentAutoCompleteModel{permission: new(AssessmentPermission)}
+	completed, err := m.evaluate(context.Background(), &entity.Operator{OrgID: "org"}, rules, assessment, 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed || assessment.Status != v2.AssessmentStatusInDraft || assessment.CompleteBy != "" {
+		t.Errorf("reopened assessment is completed again: %+v", assessment)
+	}
+}
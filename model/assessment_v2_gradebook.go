@@ -0,0 +1,606 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da/assessmentV2"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+var (
+	assessmentGradebookModelInstance     IAssessmentGradebookModel
+	assessmentGradebookModelInstanceOnce = sync.Once{}
+)
+
+type IAssessmentGradebookModel interface {
+	// SyncAssessment snapshot a completed assessment into the gradebook,
+	// scores are read from H5P room scores so op must carry a user token
+	SyncAssessment(ctx context.Context, op *entity.Operator, assessmentID string) error
+	SyncClass(ctx context.Context, op *entity.Operator, classID string) (int, error)
+	// RemoveAssessment soft delete the snapshot of a reopened assessment, OneRoster consumers see it as deleted
+	RemoveAssessment(ctx context.Context, assessmentID string) error
+
+	GetByClass(ctx context.Context, op *entity.Operator, req *v2.AssessmentGradebookReq) (*v2.AssessmentGradebookReply, error)
+
+	// OneRoster, op only carries the organization of the api key
+	QueryOneRosterLineItems(ctx context.Context, op *entity.Operator, classID string, query *v2.OneRosterQuery) (int, []*v2.OneRosterLineItem, error)
+	GetOneRosterLineItem(ctx context.Context, op *entity.Operator, id string) (*v2.OneRosterLineItem, error)
+	QueryOneRosterResults(ctx context.Context, op *entity.Operator, cond *OneRosterResultFilter, query *v2.OneRosterQuery) (int, []*v2.OneRosterResult, error)
+	GetOneRosterResult(ctx context.Context, op *entity.Operator, id string) (*v2.OneRosterResult, error)
+}
+
+type OneRosterResultFilter struct {
+	LineItemID string
+	ClassID    string
+	StudentID  string
+}
+
+type assessmentGradebookModel struct {
+	permission *AssessmentPermission
+}
+
+func GetAssessmentGradebookModel() IAssessmentGradebookModel {
+	assessmentGradebookModelInstanceOnce.Do(func() {
+		assessmentGradebookModelInstance = &assessmentGradebookModel{
+			permission: new(AssessmentPermission),
+		}
+	})
+	return assessmentGradebookModelInstance
+}
+
+// syncGradebookAsync completing an assessment should not wait for the gradebook
+func syncGradebookAsync(ctx context.Context, op *entity.Operator, assessmentID string) {
+	go func() {
+		ctxClone := utils.CloneContextWithTrace(ctx)
+
+		defer func() {
+			if err1 := recover(); err1 != nil {
+				log.Error(ctxClone, "sync gradebook panic", log.Any("recover error", err1))
+			}
+		}()
+
+		if err := GetAssessmentGradebookModel().SyncAssessment(ctxClone, op, assessmentID); err != nil {
+			log.Warn(ctxClone, "sync gradebook failed", log.Err(err), log.String("assessmentID", assessmentID))
+		}
+	}()
+}
+
+func (m *assessmentGradebookModel) SyncAssessment(ctx context.Context, op *entity.Operator, assessmentID string) error {
+	assessment := new(v2.Assessment)
+	err := assessmentV2.GetAssessmentDA().Get(ctx, assessmentID, assessment)
+	if err == dbo.ErrRecordNotFound {
+		return constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get assessment error", log.Err(err), log.String("assessmentID", assessmentID))
+		return err
+	}
+
+	if assessment.Status != v2.AssessmentStatusComplete {
+		log.Warn(ctx, "only completed assessment can be synced to gradebook", log.Any("assessment", assessment))
+		return constant.ErrInvalidArgs
+	}
+
+	input, err := m.buildGradebookInput(ctx, op, assessment)
+	if err != nil {
+		return err
+	}
+
+	lineItem, results := v2.NewAssessmentGradebook(input, time.Now().Unix())
+
+	return m.save(ctx, lineItem, results)
+}
+
+func (m *assessmentGradebookModel) RemoveAssessment(ctx context.Context, assessmentID string) error {
+	var lineItems []*v2.AssessmentGradebookLineItem
+	err := assessmentV2.GetAssessmentGradebookLineItemDA().Query(ctx, &assessmentV2.AssessmentGradebookLineItemCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{assessmentID},
+			Valid:   true,
+		},
+	}, &lineItems)
+	if err != nil {
+		log.Error(ctx, "query gradebook line item error", log.Err(err), log.String("assessmentID", assessmentID))
+		return err
+	}
+	if len(lineItems) <= 0 {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	lineItem := lineItems[0]
+	lineItem.DeleteAt = now
+
+	return dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if _, err := assessmentV2.GetAssessmentGradebookLineItemDA().UpdateTx(ctx, tx, lineItem); err != nil {
+			log.Error(ctx, "delete gradebook line item error", log.Err(err), log.Any("lineItem", lineItem))
+			return err
+		}
+
+		return assessmentV2.GetAssessmentGradebookResultDA().DeleteByLineItemIDTx(ctx, tx, lineItem.ID, nil, now)
+	})
+}
+
+func (m *assessmentGradebookModel) buildGradebookInput(ctx context.Context, op *entity.Operator, assessment *v2.Assessment) (*v2.AssessmentGradebookInput, error) {
+	detail, err := ConvertAssessmentDetailReply(ctx, op, assessment)
+	if err != nil {
+		log.Error(ctx, "convert assessment detail error", log.Err(err), log.Any("assessment", assessment))
+		return nil, err
+	}
+
+	var assessmentUsers []*v2.AssessmentUser
+	err = assessmentV2.GetAssessmentUserDA().Query(ctx, &assessmentV2.AssessmentUserCondition{
+		AssessmentID: sql.NullString{
+			String: assessment.ID,
+			Valid:  true,
+		},
+		UserType: sql.NullString{
+			String: v2.AssessmentUserTypeStudent.String(),
+			Valid:  true,
+		},
+	}, &assessmentUsers)
+	if err != nil {
+		log.Error(ctx, "query assessment users error", log.Err(err), log.String("assessmentID", assessment.ID))
+		return nil, err
+	}
+
+	input := &v2.AssessmentGradebookInput{
+		OrgID:             assessment.OrgID,
+		ScheduleID:        assessment.ScheduleID,
+		AssessmentUserIDs: make(map[string]string, len(assessmentUsers)),
+		Detail:            detail,
+	}
+	for _, item := range assessmentUsers {
+		input.AssessmentUserIDs[item.UserID] = item.ID
+	}
+
+	schedules, err := GetScheduleModel().QueryUnsafe(ctx, &entity.ScheduleQueryCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{assessment.ScheduleID},
+			Valid:   true,
+		},
+	})
+	if err != nil {
+		log.Error(ctx, "get schedule error", log.Err(err), log.String("scheduleID", assessment.ScheduleID))
+		return nil, err
+	}
+	if len(schedules) > 0 {
+		input.AssignAt = schedules[0].StartAt
+	}
+	if input.AssignAt <= 0 {
+		input.AssignAt = assessment.CreateAt
+	}
+
+	if detail.Class != nil && detail.Class.ID != "" {
+		schoolMap, err := external.GetSchoolServiceProvider().GetByClasses(ctx, op, []string{detail.Class.ID})
+		if err != nil {
+			log.Error(ctx, "get schools by class error", log.Err(err), log.String("classID", detail.Class.ID))
+			return nil, err
+		}
+		if schools := schoolMap[detail.Class.ID]; len(schools) > 0 {
+			input.SchoolID = schools[0].ID
+		}
+	}
+
+	return input, nil
+}
+
+// save replace the snapshot of an assessment, results of students removed from the assessment are deleted
+func (m *assessmentGradebookModel) save(ctx context.Context, lineItem *v2.AssessmentGradebookLineItem, results []*v2.AssessmentGradebookResult) error {
+	var oldLineItems []*v2.AssessmentGradebookLineItem
+	err := assessmentV2.GetAssessmentGradebookLineItemDA().Query(ctx, &assessmentV2.AssessmentGradebookLineItemCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{lineItem.ID},
+			Valid:   true,
+		},
+		IncludeDeleted: true,
+	}, &oldLineItems)
+	if err != nil {
+		return err
+	}
+	if len(oldLineItems) > 0 {
+		lineItem.CreateAt = oldLineItems[0].CreateAt
+	}
+
+	resultIDs := make([]string, len(results))
+	for i, item := range results {
+		resultIDs[i] = item.ID
+	}
+	oldResultCreateAtMap := make(map[string]int64)
+	if len(resultIDs) > 0 {
+		var oldResults []*v2.AssessmentGradebookResult
+		err = assessmentV2.GetAssessmentGradebookResultDA().Query(ctx, &assessmentV2.AssessmentGradebookResultCondition{
+			IDs: entity.NullStrings{
+				Strings: resultIDs,
+				Valid:   true,
+			},
+			IncludeDeleted: true,
+		}, &oldResults)
+		if err != nil {
+			return err
+		}
+		for _, item := range oldResults {
+			oldResultCreateAtMap[item.ID] = item.CreateAt
+		}
+	}
+
+	return dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if err := assessmentV2.GetAssessmentGradebookLineItemDA().SaveTx(ctx, tx, lineItem); err != nil {
+			log.Error(ctx, "save gradebook line item error", log.Err(err), log.Any("lineItem", lineItem))
+			return err
+		}
+
+		if err := assessmentV2.GetAssessmentGradebookResultDA().DeleteByLineItemIDTx(ctx, tx, lineItem.ID, resultIDs, lineItem.UpdateAt); err != nil {
+			return err
+		}
+
+		for _, item := range results {
+			if createAt, ok := oldResultCreateAtMap[item.ID]; ok {
+				item.CreateAt = createAt
+			}
+			if err := assessmentV2.GetAssessmentGradebookResultDA().SaveTx(ctx, tx, item); err != nil {
+				log.Error(ctx, "save gradebook result error", log.Err(err), log.Any("result", item))
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// SyncClass backfill the gradebook with all completed assessments of a class
+func (m *assessmentGradebookModel) SyncClass(ctx context.Context, op *entity.Operator, classID string) (int, error) {
+	if classID == "" {
+		return 0, constant.ErrInvalidArgs
+	}
+
+	if err := m.permission.IsAllowEdit(ctx, op); err != nil {
+		return 0, err
+	}
+
+	var assessments []*v2.Assessment
+	err := assessmentV2.GetAssessmentDA().Query(ctx, &assessmentV2.AssessmentCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		ClassIDs: entity.NullStrings{
+			Strings: []string{classID},
+			Valid:   true,
+		},
+		Status: entity.NullStrings{
+			Strings: []string{v2.AssessmentStatusComplete.String()},
+			Valid:   true,
+		},
+	}, &assessments)
+	if err != nil {
+		log.Error(ctx, "query completed assessments of class error", log.Err(err), log.String("classID", classID))
+		return 0, err
+	}
+
+	for _, item := range assessments {
+		if err := m.SyncAssessment(ctx, op, item.ID); err != nil {
+			log.Error(ctx, "sync assessment to gradebook error", log.Err(err), log.String("assessmentID", item.ID))
+			return 0, err
+		}
+	}
+
+	return len(assessments), nil
+}
+
+func (m *assessmentGradebookModel) GetByClass(ctx context.Context, op *entity.Operator, req *v2.AssessmentGradebookReq) (*v2.AssessmentGradebookReply, error) {
+	if req.ClassID == "" {
+		return nil, constant.ErrInvalidArgs
+	}
+
+	teacherIDs, err := new(AssessmentPermission).SearchCompleteTeacherIDs(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	var lineItems []*v2.AssessmentGradebookLineItem
+	err = assessmentV2.GetAssessmentGradebookLineItemDA().Query(ctx, &assessmentV2.AssessmentGradebookLineItemCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		ClassIDs: entity.NullStrings{
+			Strings: []string{req.ClassID},
+			Valid:   true,
+		},
+		CompleteAtGe: sql.NullInt64{
+			Int64: req.CompleteAtGe,
+			Valid: req.CompleteAtGe > 0,
+		},
+		CompleteAtLe: sql.NullInt64{
+			Int64: req.CompleteAtLe,
+			Valid: req.CompleteAtLe > 0,
+		},
+	}, &lineItems)
+	if err != nil {
+		log.Error(ctx, "query gradebook line items error", log.Err(err), log.Any("req", req))
+		return nil, err
+	}
+
+	if teacherIDs != nil {
+		lineItems, err = m.filterLineItemsByTeachers(ctx, lineItems, teacherIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	classNameMap, err := external.GetClassServiceProvider().BatchGetNameMap(ctx, op, []string{req.ClassID})
+	if err != nil {
+		log.Error(ctx, "get class name error", log.Err(err), log.String("classID", req.ClassID))
+		return nil, err
+	}
+
+	result := &v2.AssessmentGradebookReply{
+		ClassID:   req.ClassID,
+		ClassName: classNameMap[req.ClassID],
+		LineItems: make([]*v2.AssessmentGradebookLineItemReply, 0, len(lineItems)),
+		Students:  make([]*v2.AssessmentGradebookStudentReply, 0),
+	}
+	if len(lineItems) <= 0 {
+		return result, nil
+	}
+
+	lineItemIDs := make([]string, len(lineItems))
+	for i, item := range lineItems {
+		lineItemIDs[i] = item.ID
+		result.LineItems = append(result.LineItems, &v2.AssessmentGradebookLineItemReply{
+			ID:             item.ID,
+			Title:          item.Title,
+			AssessmentType: item.AssessmentType,
+			MaxScore:       item.MaxScore,
+			DueAt:          item.DueAt,
+			CompleteAt:     item.CompleteAt,
+		})
+	}
+
+	var results []*v2.AssessmentGradebookResult
+	err = assessmentV2.GetAssessmentGradebookResultDA().Query(ctx, &assessmentV2.AssessmentGradebookResultCondition{
+		LineItemIDs: entity.NullStrings{
+			Strings: lineItemIDs,
+			Valid:   true,
+		},
+	}, &results)
+	if err != nil {
+		log.Error(ctx, "query gradebook results error", log.Err(err), log.Strings("lineItemIDs", lineItemIDs))
+		return nil, err
+	}
+
+	studentResultMap := make(map[string]map[string]*v2.AssessmentGradebookResult)
+	studentIDs := make([]string, 0)
+	for _, item := range results {
+		if _, ok := studentResultMap[item.StudentID]; !ok {
+			studentResultMap[item.StudentID] = make(map[string]*v2.AssessmentGradebookResult)
+			studentIDs = append(studentIDs, item.StudentID)
+		}
+		studentResultMap[item.StudentID][item.LineItemID] = item
+	}
+
+	studentNameMap, err := external.GetUserServiceProvider().BatchGetNameMap(ctx, op, studentIDs)
+	if err != nil {
+		log.Error(ctx, "get student name error", log.Err(err), log.Strings("studentIDs", studentIDs))
+		return nil, err
+	}
+
+	for _, studentID := range studentIDs {
+		studentItem := &v2.AssessmentGradebookStudentReply{
+			StudentID:   studentID,
+			StudentName: studentNameMap[studentID],
+			Results:     make([]*v2.AssessmentGradebookStudentResultReply, 0, len(lineItems)),
+		}
+		for _, lineItem := range lineItems {
+			resultItem, ok := studentResultMap[studentID][lineItem.ID]
+			if !ok {
+				continue
+			}
+			studentItem.Results = append(studentItem.Results, &v2.AssessmentGradebookStudentResultReply{
+				LineItemID:          lineItem.ID,
+				ScoreStatus:         resultItem.ScoreStatus,
+				Score:               resultItem.Score,
+				MaxScore:            resultItem.MaxScore,
+				Comment:             resultItem.Comment,
+				OutcomesAchieved:    resultItem.OutcomesAchieved,
+				OutcomesNotAchieved: resultItem.OutcomesNotAchieved,
+				OutcomesTotal:       resultItem.OutcomesTotal,
+			})
+		}
+		result.Students = append(result.Students, studentItem)
+	}
+
+	sort.SliceStable(result.Students, func(i, j int) bool {
+		return result.Students[i].StudentName < result.Students[j].StudentName
+	})
+
+	return result, nil
+}
+
+func (m *assessmentGradebookModel) filterLineItemsByTeachers(ctx context.Context, lineItems []*v2.AssessmentGradebookLineItem, teacherIDs []string) ([]*v2.AssessmentGradebookLineItem, error) {
+	if len(lineItems) <= 0 || len(teacherIDs) <= 0 {
+		return []*v2.AssessmentGradebookLineItem{}, nil
+	}
+
+	lineItemIDs := make([]string, len(lineItems))
+	for i, item := range lineItems {
+		lineItemIDs[i] = item.ID
+	}
+
+	var teachers []*v2.AssessmentUser
+	err := assessmentV2.GetAssessmentUserDA().Query(ctx, &assessmentV2.AssessmentUserCondition{
+		AssessmentIDs: entity.NullStrings{
+			Strings: lineItemIDs,
+			Valid:   true,
+		},
+		UserType: sql.NullString{
+			String: v2.AssessmentUserTypeTeacher.String(),
+			Valid:  true,
+		},
+		UserIDs: entity.NullStrings{
+			Strings: teacherIDs,
+			Valid:   true,
+		},
+	}, &teachers)
+	if err != nil {
+		log.Error(ctx, "query assessment teachers error", log.Err(err), log.Strings("lineItemIDs", lineItemIDs))
+		return nil, err
+	}
+
+	allowMap := make(map[string]struct{}, len(teachers))
+	for _, item := range teachers {
+		allowMap[item.AssessmentID] = struct{}{}
+	}
+
+	result := make([]*v2.AssessmentGradebookLineItem, 0, len(lineItems))
+	for _, item := range lineItems {
+		if _, ok := allowMap[item.ID]; ok {
+			result = append(result, item)
+		}
+	}
+
+	return result, nil
+}
+
+func (m *assessmentGradebookModel) oneRosterLimit(query *v2.OneRosterQuery) (int, int) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = v2.OneRosterDefaultLimit
+	}
+	if limit > v2.OneRosterMaxLimit {
+		limit = v2.OneRosterMaxLimit
+	}
+
+	offset := query.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
+
+func (m *assessmentGradebookModel) QueryOneRosterLineItems(ctx context.Context, op *entity.Operator, classID string, query *v2.OneRosterQuery) (int, []*v2.OneRosterLineItem, error) {
+	limit, offset := m.oneRosterLimit(query)
+	condition := &assessmentV2.AssessmentGradebookLineItemCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		ClassIDs: entity.NullStrings{
+			Strings: []string{classID},
+			Valid:   classID != "",
+		},
+		ModifiedAtGt: sql.NullInt64{
+			Int64: query.DateLastModifiedGt,
+			Valid: query.DateLastModifiedGt > 0,
+		},
+	}
+
+	total, lineItems, err := assessmentV2.GetAssessmentGradebookLineItemDA().QueryByOffset(ctx, condition, limit, offset)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := make([]*v2.OneRosterLineItem, len(lineItems))
+	for i, item := range lineItems {
+		result[i] = item.ToOneRoster()
+	}
+
+	return total, result, nil
+}
+
+func (m *assessmentGradebookModel) GetOneRosterLineItem(ctx context.Context, op *entity.Operator, id string) (*v2.OneRosterLineItem, error) {
+	var lineItems []*v2.AssessmentGradebookLineItem
+	err := assessmentV2.GetAssessmentGradebookLineItemDA().Query(ctx, &assessmentV2.AssessmentGradebookLineItemCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{id},
+			Valid:   true,
+		},
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+	}, &lineItems)
+	if err != nil {
+		log.Error(ctx, "get gradebook line item error", log.Err(err), log.String("id", id))
+		return nil, err
+	}
+	if len(lineItems) <= 0 {
+		return nil, constant.ErrRecordNotFound
+	}
+
+	return lineItems[0].ToOneRoster(), nil
+}
+
+func (m *assessmentGradebookModel) QueryOneRosterResults(ctx context.Context, op *entity.Operator, cond *OneRosterResultFilter, query *v2.OneRosterQuery) (int, []*v2.OneRosterResult, error) {
+	limit, offset := m.oneRosterLimit(query)
+	condition := &assessmentV2.AssessmentGradebookResultCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		LineItemIDs: entity.NullStrings{
+			Strings: []string{cond.LineItemID},
+			Valid:   cond.LineItemID != "",
+		},
+		ClassIDs: entity.NullStrings{
+			Strings: []string{cond.ClassID},
+			Valid:   cond.ClassID != "",
+		},
+		StudentIDs: entity.NullStrings{
+			Strings: []string{cond.StudentID},
+			Valid:   cond.StudentID != "",
+		},
+		ModifiedAtGt: sql.NullInt64{
+			Int64: query.DateLastModifiedGt,
+			Valid: query.DateLastModifiedGt > 0,
+		},
+	}
+
+	total, results, err := assessmentV2.GetAssessmentGradebookResultDA().QueryByOffset(ctx, condition, limit, offset)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := make([]*v2.OneRosterResult, len(results))
+	for i, item := range results {
+		result[i] = item.ToOneRoster()
+	}
+
+	return total, result, nil
+}
+
+func (m *assessmentGradebookModel) GetOneRosterResult(ctx context.Context, op *entity.Operator, id string) (*v2.OneRosterResult, error) {
+	var results []*v2.AssessmentGradebookResult
+	err := assessmentV2.GetAssessmentGradebookResultDA().Query(ctx, &assessmentV2.AssessmentGradebookResultCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{id},
+			Valid:   true,
+		},
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+	}, &results)
+	if err != nil {
+		log.Error(ctx, "get gradebook result error", log.Err(err), log.String("id", id))
+		return nil, err
+	}
+	if len(results) <= 0 {
+		return nil, constant.ErrRecordNotFound
+	}
+
+	return results[0].ToOneRoster(), nil
+}
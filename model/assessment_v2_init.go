@@ -67,6 +67,9 @@ type AssessmentInit struct {
 	roomContentTree  []*RoomContentTree
 
 	assessmentUserIDTypeMap map[string]*v2.AssessmentUser // key: userID+userType
+
+	rubric         *v2.AssessmentRubricDetail                 // attached to schedule or lesson plan
+	rubricScoreMap map[string][]*v2.AssessmentUserRubricScore // key: AssessmentUserID
 }
 
 func NewAssessmentInit(ctx context.Context, op *entity.Operator, assessment *v2.Assessment) (*AssessmentInit, error) {
@@ -127,7 +130,8 @@ func (at *AssessmentInit) ConfigAssessmentDetailInitMap() {
 		at.initReviewerFeedbackMap,
 		at.initContentsFromScheduleReview,
 		at.initRoomData,
-		at.initOutcomeFromAssessment)
+		at.initOutcomeFromAssessment,
+		at.initRubric)
 
 	data[assessmentInitLevel3] = append(data[assessmentInitLevel3],
 		at.initOutcomeMapFromContent,
@@ -955,6 +959,113 @@ func (at *AssessmentInit) initOutcomeFromAssessment() error {
 	return nil
 }
 
+func (at *AssessmentInit) initRubric() error {
+	if at.rubric != nil {
+		return nil
+	}
+	ctx := at.ctx
+	op := at.op
+
+	if err := at.initRubricScoreMap(); err != nil {
+		return err
+	}
+
+	if at.assessment.AssessmentType != v2.AssessmentTypeOfflineClass &&
+		at.assessment.AssessmentType != v2.AssessmentTypeOfflineStudy {
+		at.rubric = &v2.AssessmentRubricDetail{}
+		return nil
+	}
+
+	if err := at.initSchedule(); err != nil {
+		return err
+	}
+
+	rubricMap, err := GetAssessmentRubricModel().GetRubricsBySchedules(ctx, op, []*entity.Schedule{at.schedule})
+	if err != nil {
+		return err
+	}
+
+	if rubric, ok := rubricMap[at.schedule.ID]; ok {
+		at.rubric = rubric
+	} else {
+		at.rubric = &v2.AssessmentRubricDetail{}
+	}
+
+	// the rubric students were scored with wins, even if it has been deleted or replaced since
+	scores := make([]*v2.AssessmentUserRubricScore, 0)
+	scoredRubricIDs := make([]string, 0)
+	for _, items := range at.rubricScoreMap {
+		for _, item := range items {
+			scores = append(scores, item)
+			scoredRubricIDs = append(scoredRubricIDs, item.RubricID)
+		}
+	}
+	scoredRubricIDs = utils.SliceDeduplicationExcludeEmpty(scoredRubricIDs)
+	if len(scoredRubricIDs) <= 0 || (at.rubric.Rubric != nil && utils.ContainsString(scoredRubricIDs, at.rubric.Rubric.ID)) {
+		return nil
+	}
+
+	scoredRubricID := v2.LatestScoredRubricID(scores)
+	scoredRubricMap, err := GetAssessmentRubricModel().GetScoredRubrics(ctx, op, []string{scoredRubricID})
+	if err != nil {
+		return err
+	}
+	if rubric, ok := scoredRubricMap[scoredRubricID]; ok {
+		at.rubric = rubric
+	}
+
+	return nil
+}
+
+func (at *AssessmentInit) initRubricScoreMap() error {
+	if at.rubricScoreMap != nil {
+		return nil
+	}
+	ctx := at.ctx
+
+	if at.assessment.AssessmentType != v2.AssessmentTypeOfflineClass &&
+		at.assessment.AssessmentType != v2.AssessmentTypeOfflineStudy {
+		at.rubricScoreMap = make(map[string][]*v2.AssessmentUserRubricScore)
+		return nil
+	}
+
+	if err := at.initAssessmentUsers(); err != nil {
+		return err
+	}
+	assessmentUserPKs := make([]string, 0, len(at.assessmentUsers))
+	for _, item := range at.assessmentUsers {
+		if item.UserType == v2.AssessmentUserTypeStudent {
+			assessmentUserPKs = append(assessmentUserPKs, item.ID)
+		}
+	}
+	if len(assessmentUserPKs) <= 0 {
+		at.rubricScoreMap = make(map[string][]*v2.AssessmentUserRubricScore)
+		return nil
+	}
+
+	condition := &assessmentV2.AssessmentUserRubricScoreCondition{
+		AssessmentUserIDs: entity.NullStrings{
+			Strings: assessmentUserPKs,
+			Valid:   true,
+		},
+	}
+	var scores []*v2.AssessmentUserRubricScore
+	err := assessmentV2.GetAssessmentUserRubricScoreDA().Query(ctx, condition, &scores)
+	if err != nil {
+		log.Error(ctx, "query assessment user rubric score error", log.Err(err), log.Any("condition", condition))
+		return err
+	}
+
+	result := make(map[string][]*v2.AssessmentUserRubricScore, len(assessmentUserPKs))
+	for _, item := range scores {
+		result[item.AssessmentUserID] = append(result[item.AssessmentUserID], item)
+	}
+
+	at.rubricScoreMap = result
+
+	return nil
+}
+
 // level 3
 func (at *AssessmentInit) initOutcomeMapFromContent() error {
 	if at.outcomeMapFromContent != nil {
@@ -1290,6 +1401,7 @@ func ConvertAssessmentDetailReply(ctx context.Context, op *entity.Operator, asse
 		ClassEndAt:     assessment.ClassEndAt,
 		ClassLength:    assessment.ClassLength,
 		CompleteAt:     assessment.CompleteAt,
+		CompleteBy:     assessment.CompleteBy,
 		CompleteRate:   0,
 	}
 
@@ -1320,7 +1432,14 @@ func ConvertAssessmentDetailReply(ctx context.Context, op *entity.Operator, asse
 	}
 	if assessment.AssessmentType == v2.AssessmentTypeOfflineStudy {
 		offlineStudy := &OfflineStudyAssessment{}
-		result.CompleteRate = offlineStudy.ProcessCompleteRate(ctx, detailInit.assessmentUsers, detailInit.reviewerFeedbackMap)
+		result.CompleteRate = offlineStudy.ProcessCompleteRate(ctx, detailInit.assessmentUsers, detailInit.reviewerFeedbackMap, detailInit.rubric, detailInit.rubricScoreMap)
+	}
+	if assessment.AssessmentType == v2.AssessmentTypeOfflineClass {
+		offlineClass := &OfflineClassAssessment{}
+		result.CompleteRate = offlineClass.ProcessCompleteRate(ctx, detailInit.assessmentUsers, detailInit.rubric, detailInit.rubricScoreMap)
+	}
+	if detailInit.rubric != nil && detailInit.rubric.Rubric != nil {
+		result.Rubric = ConvertAssessmentRubricReply(detailInit.rubric)
 	}
 
 	result.IsAnyOneAttempted = isAnyOneAttempted || len(detailInit.roomUserScoreMap) > 0
@@ -39,6 +39,8 @@ type AssessmentListInit struct {
 	liveRoomMap          map[string]*external.RoomInfo                // roomID
 	scheduleStuReviewMap map[string]map[string]*entity.ScheduleReview // key:ScheduleID,StudentID
 	assessmentUserMap    map[string][]*v2.AssessmentUser              // assessmentID
+	rubricMap            map[string]*v2.AssessmentRubricDetail        // scheduleID
+	rubricScoreMap       map[string][]*v2.AssessmentUserRubricScore   // assessmentUserID
 }
 
 func NewAssessmentListInit(ctx context.Context, op *entity.Operator, assessments []*v2.Assessment) (*AssessmentListInit, error) {
@@ -94,7 +96,8 @@ func (at *AssessmentListInit) ConfigAssessmentListInitMap() {
 		at.initSubjectMap,
 		at.initClassMap,
 		at.initReviewerFeedbackMap,
-		at.initAssessmentUserMap)
+		at.initAssessmentUserMap,
+		at.initRubricMap)
 	at.listInitMap = data
 }
 
@@ -625,6 +628,132 @@ func (at *AssessmentListInit) initAssessmentUserMap() error {
 	return nil
 }
 
+func (at *AssessmentListInit) initRubricMap() error {
+	if at.rubricMap != nil {
+		return nil
+	}
+
+	ctx := at.ctx
+
+	if err := at.initScheduleMap(); err != nil {
+		return err
+	}
+
+	schedules := make([]*entity.Schedule, 0)
+	for _, item := range at.assessments {
+		if item.AssessmentType != v2.AssessmentTypeOfflineClass && item.AssessmentType != v2.AssessmentTypeOfflineStudy {
+			continue
+		}
+		if schedule, ok := at.scheduleMap[item.ScheduleID]; ok {
+			schedules = append(schedules, schedule)
+		}
+	}
+
+	result, err := GetAssessmentRubricModel().GetRubricsBySchedules(ctx, at.op, schedules)
+	if err != nil {
+		return err
+	}
+
+	// the rubric students were scored with wins, even if it has been deleted or replaced since
+	if err := at.initRubricScoreMap(); err != nil {
+		return err
+	}
+	scheduleScoreMap := make(map[string][]*v2.AssessmentUserRubricScore) // key: scheduleID
+	for _, item := range at.assessmentUsers {
+		scores := at.rubricScoreMap[item.ID]
+		if len(scores) <= 0 {
+			continue
+		}
+		if assessment, ok := at.assessmentMap[item.AssessmentID]; ok {
+			scheduleScoreMap[assessment.ScheduleID] = append(scheduleScoreMap[assessment.ScheduleID], scores...)
+		}
+	}
+	scoredRubricIDMap := make(map[string]string, len(scheduleScoreMap)) // key: scheduleID
+	scoredRubricIDs := make([]string, 0, len(scheduleScoreMap))
+	for scheduleID, scores := range scheduleScoreMap {
+		if rubric, ok := result[scheduleID]; ok && rubric.Rubric != nil && rubricScored(scores, rubric.Rubric.ID) {
+			continue
+		}
+		rubricID := v2.LatestScoredRubricID(scores)
+		scoredRubricIDMap[scheduleID] = rubricID
+		scoredRubricIDs = append(scoredRubricIDs, rubricID)
+	}
+	if len(scoredRubricIDs) > 0 {
+		scoredRubricMap, err := GetAssessmentRubricModel().GetScoredRubrics(ctx, at.op, scoredRubricIDs)
+		if err != nil {
+			return err
+		}
+		for scheduleID, rubricID := range scoredRubricIDMap {
+			if rubric, ok := scoredRubricMap[rubricID]; ok {
+				result[scheduleID] = rubric
+			}
+		}
+	}
+
+	at.rubricMap = result
+
+	return nil
+}
+
+func rubricScored(scores []*v2.AssessmentUserRubricScore, rubricID string) bool {
+	for _, item := range scores {
+		if item.RubricID == rubricID {
+			return true
+		}
+	}
+	return false
+}
+
+func (at *AssessmentListInit) initRubricScoreMap() error {
+	if at.rubricScoreMap != nil {
+		return nil
+	}
+
+	ctx := at.ctx
+
+	if err := at.initAssessmentUsers(); err != nil {
+		return err
+	}
+
+	assessmentUserIDs := make([]string, 0, len(at.assessmentUsers))
+	for _, item := range at.assessmentUsers {
+		if item.UserType != v2.AssessmentUserTypeStudent {
+			continue
+		}
+		if assessment, ok := at.assessmentMap[item.AssessmentID]; ok &&
+			(assessment.AssessmentType == v2.AssessmentTypeOfflineClass || assessment.AssessmentType == v2.AssessmentTypeOfflineStudy) {
+			assessmentUserIDs = append(assessmentUserIDs, item.ID)
+		}
+	}
+
+	if len(assessmentUserIDs) <= 0 {
+		at.rubricScoreMap = make(map[string][]*v2.AssessmentUserRubricScore)
+		return nil
+	}
+
+	condition := &assessmentV2.AssessmentUserRubricScoreCondition{
+		AssessmentUserIDs: entity.NullStrings{
+			Strings: assessmentUserIDs,
+			Valid:   true,
+		},
+	}
+	var scores []*v2.AssessmentUserRubricScore
+	err := assessmentV2.GetAssessmentUserRubricScoreDA().Query(ctx, condition, &scores)
+	if err != nil {
+		log.Error(ctx, "query assessment user rubric score error", log.Err(err), log.Any("condition", condition))
+		return err
+	}
+
+	result := make(map[string][]*v2.AssessmentUserRubricScore, len(assessmentUserIDs))
+	for _, item := range scores {
+		result[item.AssessmentUserID] = append(result[item.AssessmentUserID], item)
+	}
+
+	at.rubricScoreMap = result
+
+	return nil
+}
+
 // assessment
 // async schedule, schedule_relation,schedule_review assessment_user
 // async lessPlan,teacher,program,subject,class,reviewer_feedback,live
@@ -791,21 +920,26 @@ func (at *AssessmentListInit) MatchCompleteRate() map[string]float64 {
 		result[key] = item.CompletionPercentage
 	}
 
-	// Calculate the completion rate of offline Study schedule
-	if len(at.reviewerFeedbackMap) <= 0 {
+	// Calculate the completion rate of offline schedule
+	if len(at.reviewerFeedbackMap) <= 0 && len(at.rubricScoreMap) <= 0 {
 		return result
 	}
 
 	offlineStudy := &OfflineStudyAssessment{}
+	offlineClass := &OfflineClassAssessment{}
 	for _, item := range at.assessmentMap {
-		if item.AssessmentType != v2.AssessmentTypeOfflineStudy {
+		if item.AssessmentType != v2.AssessmentTypeOfflineStudy && item.AssessmentType != v2.AssessmentTypeOfflineClass {
 			continue
 		}
 		assessmentUsers, ok := at.assessmentUserMap[item.ID]
 		if !ok {
 			continue
 		}
-		result[item.ScheduleID] = offlineStudy.ProcessCompleteRate(ctx, assessmentUsers, at.reviewerFeedbackMap)
+		if item.AssessmentType == v2.AssessmentTypeOfflineStudy {
+			result[item.ScheduleID] = offlineStudy.ProcessCompleteRate(ctx, assessmentUsers, at.reviewerFeedbackMap, at.rubricMap[item.ScheduleID], at.rubricScoreMap)
+		} else {
+			result[item.ScheduleID] = offlineClass.ProcessCompleteRate(ctx, assessmentUsers, at.rubricMap[item.ScheduleID], at.rubricScoreMap)
+		}
 	}
 
 	return result
@@ -504,7 +504,8 @@ func (a *assessmentInternalModel) endClassCallbackUpdateAssessment(ctx context.C
 			return err
 		}
 
-		return nil
+		// attendance counts in the report rollups
+		return GetReportRollupModel().MarkDirtyTx(ctx, tx, assessment.ScheduleID)
 	})
 }
 
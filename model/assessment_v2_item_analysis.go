@@ -0,0 +1,361 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/da/assessmentV2"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+)
+
+// rooms are fetched from the H5P service in batches to keep the graphql query small
+const assessmentItemAnalysisRoomBatchSize = 20
+
+var (
+	assessmentItemAnalysisModelInstance     IAssessmentItemAnalysisModel
+	assessmentItemAnalysisModelInstanceOnce = sync.Once{}
+)
+
+type IAssessmentItemAnalysisModel interface {
+	// Analyze item analysis of a lesson material over completed assessments, all versions of the material are included
+	Analyze(ctx context.Context, op *entity.Operator, req *v2.AssessmentItemAnalysisReq) (*v2.AssessmentItemAnalysisReply, error)
+}
+
+type assessmentItemAnalysisModel struct{}
+
+func GetAssessmentItemAnalysisModel() IAssessmentItemAnalysisModel {
+	assessmentItemAnalysisModelInstanceOnce.Do(func() {
+		assessmentItemAnalysisModelInstance = &assessmentItemAnalysisModel{}
+	})
+	return assessmentItemAnalysisModelInstance
+}
+
+func (m *assessmentItemAnalysisModel) Analyze(ctx context.Context, op *entity.Operator, req *v2.AssessmentItemAnalysisReq) (*v2.AssessmentItemAnalysisReply, error) {
+	if req.ContentID == "" || req.CompleteAtGe <= 0 || req.CompleteAtLe < req.CompleteAtGe {
+		log.Warn(ctx, "item analysis args invalid", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	teacherIDs, err := new(AssessmentPermission).SearchCompleteTeacherIDs(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	material, err := da.GetContentDA().GetContentByID(ctx, dbo.MustGetDB(ctx), req.ContentID)
+	if err == dbo.ErrRecordNotFound {
+		return nil, constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get lesson material error", log.Err(err), log.String("contentID", req.ContentID))
+		return nil, err
+	}
+	if material.ContentType != entity.ContentTypeMaterial {
+		log.Warn(ctx, "content is not a lesson material", log.Any("content", material))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	// materials of other orgs are only analyzed when they are shared with the org of operator
+	authMap, err := GetContentModel().ContentsVisibleMap(ctx, []string{material.ID}, op)
+	if err != nil {
+		return nil, err
+	}
+	if authMap[material.ID] != entity.ContentAuthed {
+		log.Warn(ctx, "lesson material is not visible", log.String("contentID", material.ID), log.String("contentOrg", material.Org), log.Any("op", op))
+		return nil, constant.ErrRecordNotFound
+	}
+
+	result := &v2.AssessmentItemAnalysisReply{
+		ContentID:   material.ID,
+		ContentName: material.Name,
+		Assessments: []*v2.AssessmentItemAnalysisAssessmentReply{},
+		Items:       []*v2.AssessmentItemAnalysisItemReply{},
+	}
+	if teacherIDs != nil && len(teacherIDs) <= 0 {
+		return result, nil
+	}
+
+	latestID, versionIDs, err := m.getMaterialVersionIDs(ctx, material)
+	if err != nil {
+		return nil, err
+	}
+
+	condition := &assessmentV2.AssessmentCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		Status: entity.NullStrings{
+			Strings: []string{v2.AssessmentStatusComplete.String()},
+			Valid:   true,
+		},
+		// only online assessments have H5P room scores
+		AssessmentTypes: entity.NullStrings{
+			Strings: []string{v2.AssessmentTypeOnlineClass.String(), v2.AssessmentTypeOnlineStudy.String()},
+			Valid:   true,
+		},
+		TeacherIDs: entity.NullStrings{
+			Strings: teacherIDs,
+			Valid:   teacherIDs != nil,
+		},
+		ClassIDs: entity.NullStrings{
+			Strings: []string{req.ClassID},
+			Valid:   req.ClassID != "",
+		},
+		CompleteAtGe: sql.NullInt64{
+			Int64: req.CompleteAtGe,
+			Valid: true,
+		},
+		CompleteAtLe: sql.NullInt64{
+			Int64: req.CompleteAtLe,
+			Valid: true,
+		},
+		OrderBy: assessmentV2.AssessmentOrderByCompleteAtDesc,
+		Pager: dbo.Pager{
+			Page:     1,
+			PageSize: v2.AssessmentItemAnalysisMaxAssessments,
+		},
+	}
+
+	var assessments []*v2.Assessment
+	total, err := assessmentV2.GetAssessmentDA().Page(ctx, condition, &assessments)
+	if err != nil {
+		log.Error(ctx, "page completed assessments error", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+	result.Scanned = len(assessments)
+	result.Truncated = total > len(assessments)
+	if len(assessments) <= 0 {
+		return result, nil
+	}
+
+	studentMap, err := m.getParticipatedStudentMap(ctx, assessments)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*v2.AssessmentItemResponse, 0)
+	for start := 0; start < len(assessments); start += assessmentItemAnalysisRoomBatchSize {
+		end := start + assessmentItemAnalysisRoomBatchSize
+		if end > len(assessments) {
+			end = len(assessments)
+		}
+		batch := assessments[start:end]
+
+		scheduleIDs := make([]string, len(batch))
+		for i, item := range batch {
+			scheduleIDs[i] = item.ScheduleID
+		}
+		roomMap, err := external.GetAssessmentServiceProvider().Get(ctx, op, scheduleIDs, external.WithAssessmentGetScore())
+		if err != nil {
+			log.Error(ctx, "get room scores error", log.Err(err), log.Strings("scheduleIDs", scheduleIDs))
+			return nil, err
+		}
+
+		for _, item := range batch {
+			room, ok := roomMap[item.ScheduleID]
+			if !ok {
+				continue
+			}
+			responses = append(responses, m.convertRoomResponses(item.ID, room, studentMap[item.ID], latestID, versionIDs)...)
+		}
+	}
+
+	result.Items = v2.NewAssessmentItemAnalysis(responses)
+	result.Assessments, err = m.buildAssessmentsReply(ctx, op, assessments, responses)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// getMaterialVersionIDs H5P scores keep the id of the version used in class, so every version of the material is matched
+func (m *assessmentItemAnalysisModel) getMaterialVersionIDs(ctx context.Context, material *entity.Content) (string, map[string]struct{}, error) {
+	latestID := material.LatestID
+	if latestID == "" {
+		latestID = material.ID
+	}
+
+	versions, err := da.GetContentDA().QueryContent(ctx, dbo.MustGetDB(ctx), &da.ContentCondition{
+		LatestID: latestID,
+	})
+	if err != nil {
+		log.Error(ctx, "query lesson material versions error", log.Err(err), log.String("latestID", latestID))
+		return "", nil, err
+	}
+
+	result := map[string]struct{}{
+		latestID:    {},
+		material.ID: {},
+	}
+	for _, item := range versions {
+		result[item.ID] = struct{}{}
+	}
+
+	return latestID, result, nil
+}
+
+func (m *assessmentItemAnalysisModel) getParticipatedStudentMap(ctx context.Context, assessments []*v2.Assessment) (map[string]map[string]struct{}, error) {
+	assessmentIDs := make([]string, len(assessments))
+	for i, item := range assessments {
+		assessmentIDs[i] = item.ID
+	}
+
+	var students []*v2.AssessmentUser
+	err := assessmentV2.GetAssessmentUserDA().Query(ctx, &assessmentV2.AssessmentUserCondition{
+		AssessmentIDs: entity.NullStrings{
+			Strings: assessmentIDs,
+			Valid:   true,
+		},
+		UserType: sql.NullString{
+			String: v2.AssessmentUserTypeStudent.String(),
+			Valid:  true,
+		},
+		StatusByUser: sql.NullString{
+			String: v2.AssessmentUserStatusParticipate.String(),
+			Valid:  true,
+		},
+	}, &students)
+	if err != nil {
+		log.Error(ctx, "query assessment students error", log.Err(err), log.Strings("assessmentIDs", assessmentIDs))
+		return nil, err
+	}
+
+	result := make(map[string]map[string]struct{}, len(assessments))
+	for _, item := range students {
+		if _, ok := result[item.AssessmentID]; !ok {
+			result[item.AssessmentID] = make(map[string]struct{})
+		}
+		result[item.AssessmentID][item.UserID] = struct{}{}
+	}
+
+	return result, nil
+}
+
+func (m *assessmentItemAnalysisModel) convertRoomResponses(assessmentID string, room *external.RoomInfo, students map[string]struct{}, latestID string, versionIDs map[string]struct{}) []*v2.AssessmentItemResponse {
+	result := make([]*v2.AssessmentItemResponse, 0)
+	for _, userScores := range room.ScoresByUser {
+		if userScores == nil || userScores.User == nil {
+			continue
+		}
+		if _, ok := students[userScores.User.UserID]; !ok {
+			continue
+		}
+
+		for _, score := range userScores.Scores {
+			if score == nil || score.Content == nil {
+				continue
+			}
+			if _, ok := versionIDs[score.Content.ContentID]; !ok {
+				continue
+			}
+
+			item := &v2.AssessmentItemResponse{
+				AssessmentID: assessmentID,
+				StudentID:    userScores.User.UserID,
+				ItemID:       score.Content.GetInternalID(),
+				ParentID:     score.Content.ParentID,
+				ItemName:     score.Content.Name,
+				ItemType:     score.Content.Type,
+			}
+			// the root of different versions has different content ids
+			if item.ParentID == "" {
+				item.ItemID = latestID
+			}
+
+			if score.Score != nil {
+				item.Attempts = len(score.Score.Answers)
+				item.Attempted = len(score.Score.Answers) > 0 || len(score.Score.Scores) > 0
+				if len(score.Score.Scores) > 0 {
+					item.Score = score.Score.Scores[0]
+				}
+				if len(score.Score.Answers) > 0 && score.Score.Answers[0] != nil {
+					item.Answer = score.Score.Answers[0].Answer
+					item.MaxScore = score.Score.Answers[0].MaximumPossibleScore
+				}
+			}
+			// teacher overrides the score the same way as in the assessment detail
+			if len(score.TeacherScores) > 0 && score.TeacherScores[len(score.TeacherScores)-1] != nil {
+				item.Score = score.TeacherScores[len(score.TeacherScores)-1].Score
+				item.Attempted = true
+			}
+
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+func (m *assessmentItemAnalysisModel) buildAssessmentsReply(ctx context.Context, op *entity.Operator, assessments []*v2.Assessment, responses []*v2.AssessmentItemResponse) ([]*v2.AssessmentItemAnalysisAssessmentReply, error) {
+	studentCountMap := make(map[string]map[string]struct{})
+	for _, item := range responses {
+		if _, ok := studentCountMap[item.AssessmentID]; !ok {
+			studentCountMap[item.AssessmentID] = make(map[string]struct{})
+		}
+		studentCountMap[item.AssessmentID][item.StudentID] = struct{}{}
+	}
+
+	analyzed := make([]*v2.Assessment, 0, len(studentCountMap))
+	scheduleIDs := make([]string, 0, len(studentCountMap))
+	for _, item := range assessments {
+		if _, ok := studentCountMap[item.ID]; ok {
+			analyzed = append(analyzed, item)
+			scheduleIDs = append(scheduleIDs, item.ScheduleID)
+		}
+	}
+	if len(analyzed) <= 0 {
+		return []*v2.AssessmentItemAnalysisAssessmentReply{}, nil
+	}
+
+	scheduleRelations, err := GetScheduleRelationModel().Query(ctx, op, &da.ScheduleRelationCondition{
+		ScheduleIDs: entity.NullStrings{
+			Strings: scheduleIDs,
+			Valid:   true,
+		},
+		RelationTypes: entity.NullStrings{
+			Strings: []string{entity.ScheduleRelationTypeClassRosterClass.String()},
+			Valid:   true,
+		},
+	})
+	if err != nil {
+		log.Error(ctx, "query schedule class relations error", log.Err(err), log.Strings("scheduleIDs", scheduleIDs))
+		return nil, err
+	}
+	scheduleClassMap := make(map[string]string, len(scheduleRelations))
+	classIDs := make([]string, 0, len(scheduleRelations))
+	for _, item := range scheduleRelations {
+		scheduleClassMap[item.ScheduleID] = item.RelationID
+		classIDs = append(classIDs, item.RelationID)
+	}
+
+	classNameMap, err := external.GetClassServiceProvider().BatchGetNameMap(ctx, op, classIDs)
+	if err != nil {
+		log.Error(ctx, "get class name error", log.Err(err), log.Strings("classIDs", classIDs))
+		return nil, err
+	}
+
+	result := make([]*v2.AssessmentItemAnalysisAssessmentReply, 0, len(analyzed))
+	for _, item := range analyzed {
+		classID := scheduleClassMap[item.ScheduleID]
+		result = append(result, &v2.AssessmentItemAnalysisAssessmentReply{
+			ID:             item.ID,
+			Title:          item.Title,
+			AssessmentType: item.AssessmentType,
+			ClassID:        classID,
+			ClassName:      classNameMap[classID],
+			CompleteAt:     item.CompleteAt,
+			StudentCount:   len(studentCountMap[item.ID]),
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,607 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da/assessmentV2"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+var (
+	ErrAssessmentPendingModeration = errors.New("assessment is pending moderation")
+
+	assessmentModerationModelInstance     IAssessmentModerationModel
+	assessmentModerationModelInstanceOnce = sync.Once{}
+)
+
+type IAssessmentModerationModel interface {
+	// Submit called when the marker completes an assessment, the result is saved as draft and
+	// the assessment is held for moderation when the program policy requires it, true is returned in that case
+	Submit(ctx context.Context, op *entity.Operator, assessment *v2.Assessment, req *v2.AssessmentUpdateReq) (bool, error)
+
+	QueryPolicies(ctx context.Context, op *entity.Operator) ([]*v2.AssessmentModerationPolicyReply, error)
+	SetPolicy(ctx context.Context, op *entity.Operator, req *v2.AssessmentModerationPolicySetReq) error
+	DeletePolicy(ctx context.Context, op *entity.Operator, programID string) error
+
+	Page(ctx context.Context, op *entity.Operator, req *v2.AssessmentModerationQueryReq) (*v2.AssessmentModerationPageReply, error)
+	GetByID(ctx context.Context, op *entity.Operator, id string) (*v2.AssessmentModerationDetailReply, error)
+	Update(ctx context.Context, op *entity.Operator, req *v2.AssessmentModerationUpdateReq) error
+}
+
+type assessmentModerationModel struct {
+	permission *AssessmentPermission
+}
+
+func GetAssessmentModerationModel() IAssessmentModerationModel {
+	assessmentModerationModelInstanceOnce.Do(func() {
+		assessmentModerationModelInstance = &assessmentModerationModel{
+			permission: new(AssessmentPermission),
+		}
+	})
+	return assessmentModerationModelInstance
+}
+
+func (m *assessmentModerationModel) Submit(ctx context.Context, op *entity.Operator, assessment *v2.Assessment, req *v2.AssessmentUpdateReq) (bool, error) {
+	policy, err := m.getPolicyByAssessment(ctx, assessment)
+	if err != nil {
+		return false, err
+	}
+	if policy == nil {
+		return false, nil
+	}
+	if policy.FailThreshold <= 0 && !policy.IsSampled(assessment.ID) {
+		return false, nil
+	}
+
+	processor, err := m.getProcessor(ctx, assessment)
+	if err != nil {
+		return false, err
+	}
+
+	// save as draft first, failing scores can only be judged on the saved result
+	draftReq := *req
+	draftReq.Action = v2.AssessmentActionDraft
+	if err := processor.Update(ctx, op, assessment, &draftReq); err != nil {
+		return false, err
+	}
+
+	detail, err := ConvertAssessmentDetailReply(ctx, op, assessment)
+	if err != nil {
+		log.Error(ctx, "convert assessment detail error", log.Err(err), log.Any("assessment", assessment))
+		return false, err
+	}
+
+	reason := policy.Reason(detail)
+	if reason == "" {
+		return false, nil
+	}
+
+	original, err := json.Marshal(v2.NewAssessmentModerationResult(req))
+	if err != nil {
+		log.Error(ctx, "marshal moderation original error", log.Err(err), log.Any("req", req))
+		return false, err
+	}
+
+	now := time.Now().Unix()
+	moderation := &v2.AssessmentModeration{
+		ID:           utils.NewID(),
+		OrgID:        assessment.OrgID,
+		AssessmentID: assessment.ID,
+		ProgramID:    policy.ProgramID,
+		Reason:       reason,
+		Status:       v2.AssessmentModerationStatusPending,
+		MarkerID:     op.UserID,
+		Original:     string(original),
+		CreateAt:     now,
+		UpdateAt:     now,
+	}
+	assessment.Status = v2.AssessmentStatusPendingModeration
+	assessment.UpdateAt = now
+
+	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if err := checkAssessmentUpdateTx(ctx, tx); err != nil {
+			return err
+		}
+		if _, err := assessmentV2.GetAssessmentDA().UpdateTx(ctx, tx, assessment); err != nil {
+			return err
+		}
+		if _, err := assessmentV2.GetAssessmentModerationDA().InsertTx(ctx, tx, moderation); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(ctx, "hold assessment for moderation error", log.Err(err), log.Any("moderation", moderation))
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (m *assessmentModerationModel) QueryPolicies(ctx context.Context, op *entity.Operator) ([]*v2.AssessmentModerationPolicyReply, error) {
+	if err := m.permission.IsAllowOrgSettings(ctx, op); err != nil {
+		return nil, err
+	}
+
+	var policies []*v2.AssessmentModerationPolicy
+	err := assessmentV2.GetAssessmentModerationPolicyDA().Query(ctx, &assessmentV2.AssessmentModerationPolicyCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+	}, &policies)
+	if err != nil {
+		log.Error(ctx, "query moderation policies error", log.Err(err), log.Any("op", op))
+		return nil, err
+	}
+
+	result := make([]*v2.AssessmentModerationPolicyReply, 0, len(policies))
+	if len(policies) <= 0 {
+		return result, nil
+	}
+
+	programIDs := make([]string, 0, len(policies))
+	for _, item := range policies {
+		programIDs = append(programIDs, item.ProgramID)
+	}
+	programNameMap, err := external.GetProgramServiceProvider().BatchGetNameMap(ctx, op, programIDs)
+	if err != nil {
+		log.Error(ctx, "get program names error", log.Err(err), log.Strings("programIDs", programIDs))
+		return nil, err
+	}
+
+	for _, item := range policies {
+		result = append(result, &v2.AssessmentModerationPolicyReply{
+			ID:            item.ID,
+			ProgramID:     item.ProgramID,
+			ProgramName:   programNameMap[item.ProgramID],
+			SamplePercent: item.SamplePercent,
+			FailThreshold: item.FailThreshold,
+			UpdateAt:      item.UpdateAt,
+		})
+	}
+
+	return result, nil
+}
+
+func (m *assessmentModerationModel) SetPolicy(ctx context.Context, op *entity.Operator, req *v2.AssessmentModerationPolicySetReq) error {
+	if !req.Valid() {
+		log.Warn(ctx, "moderation policy request invalid", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	if err := m.permission.IsAllowOrgSettings(ctx, op); err != nil {
+		return err
+	}
+
+	policy, err := m.getPolicy(ctx, op.OrgID, req.ProgramID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if policy != nil {
+		policy.SamplePercent = req.SamplePercent
+		policy.FailThreshold = req.FailThreshold
+		policy.UpdateAt = now
+		if _, err := assessmentV2.GetAssessmentModerationPolicyDA().Update(ctx, policy); err != nil {
+			log.Error(ctx, "update moderation policy error", log.Err(err), log.Any("policy", policy))
+			return err
+		}
+		return nil
+	}
+
+	policy = &v2.AssessmentModerationPolicy{
+		ID:            utils.NewID(),
+		OrgID:         op.OrgID,
+		ProgramID:     req.ProgramID,
+		SamplePercent: req.SamplePercent,
+		FailThreshold: req.FailThreshold,
+		CreatorID:     op.UserID,
+		CreateAt:      now,
+		UpdateAt:      now,
+	}
+	if _, err := assessmentV2.GetAssessmentModerationPolicyDA().Insert(ctx, policy); err != nil {
+		log.Error(ctx, "add moderation policy error", log.Err(err), log.Any("policy", policy))
+		return err
+	}
+
+	return nil
+}
+
+// DeletePolicy assessments already held for moderation stay in the queue
+func (m *assessmentModerationModel) DeletePolicy(ctx context.Context, op *entity.Operator, programID string) error {
+	if err := m.permission.IsAllowOrgSettings(ctx, op); err != nil {
+		return err
+	}
+
+	policy, err := m.getPolicy(ctx, op.OrgID, programID)
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		return constant.ErrRecordNotFound
+	}
+
+	now := time.Now().Unix()
+	policy.UpdateAt = now
+	policy.DeleteAt = now
+	if _, err := assessmentV2.GetAssessmentModerationPolicyDA().Update(ctx, policy); err != nil {
+		log.Error(ctx, "delete moderation policy error", log.Err(err), log.Any("policy", policy))
+		return err
+	}
+
+	return nil
+}
+
+func (m *assessmentModerationModel) Page(ctx context.Context, op *entity.Operator, req *v2.AssessmentModerationQueryReq) (*v2.AssessmentModerationPageReply, error) {
+	if err := m.permission.IsAllowEdit(ctx, op); err != nil {
+		return nil, err
+	}
+
+	status := v2.AssessmentModerationStatus(req.Status)
+	if status == "" {
+		status = v2.AssessmentModerationStatusPending
+	}
+	if !status.Valid() {
+		log.Warn(ctx, "moderation status invalid", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	result := &v2.AssessmentModerationPageReply{
+		Data: make([]*v2.AssessmentModerationReply, 0),
+	}
+
+	// moderators see work of the teachers whose completed assessments they can view
+	markerIDs, err := m.permission.SearchCompleteTeacherIDs(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	if markerIDs != nil && len(markerIDs) <= 0 {
+		return result, nil
+	}
+
+	condition := &assessmentV2.AssessmentModerationCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		Status: sql.NullString{
+			String: string(status),
+			Valid:  true,
+		},
+		ExcludeMarkerID: sql.NullString{
+			String: op.UserID,
+			Valid:  true,
+		},
+		MarkerIDs: entity.NullStrings{
+			Strings: markerIDs,
+			Valid:   markerIDs != nil,
+		},
+		Pager: dbo.Pager{
+			Page:     req.PageIndex,
+			PageSize: req.PageSize,
+		},
+	}
+
+	var moderations []*v2.AssessmentModeration
+	result.Total, err = assessmentV2.GetAssessmentModerationDA().Page(ctx, condition, &moderations)
+	if err != nil {
+		log.Error(ctx, "page moderations error", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+	if len(moderations) <= 0 {
+		return result, nil
+	}
+
+	result.Data, err = m.convertReplies(ctx, op, moderations)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (m *assessmentModerationModel) GetByID(ctx context.Context, op *entity.Operator, id string) (*v2.AssessmentModerationDetailReply, error) {
+	if err := m.permission.IsAllowEdit(ctx, op); err != nil {
+		return nil, err
+	}
+
+	moderation, err := m.getModeration(ctx, op, id)
+	if err != nil {
+		return nil, err
+	}
+
+	assessment, err := m.getAssessment(ctx, moderation.AssessmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	replies, err := m.convertReplies(ctx, op, []*v2.AssessmentModeration{moderation})
+	if err != nil {
+		return nil, err
+	}
+
+	// the assessment carries the marker's saved scores and outcome decisions until reconciled
+	detail, err := ConvertAssessmentDetailReply(ctx, op, assessment)
+	if err != nil {
+		log.Error(ctx, "convert assessment detail error", log.Err(err), log.Any("assessment", assessment))
+		return nil, err
+	}
+
+	result := &v2.AssessmentModerationDetailReply{
+		AssessmentModerationReply: *replies[0],
+		Assessment:                detail,
+	}
+
+	if result.Original, err = m.unmarshalResult(ctx, moderation.Original); err != nil {
+		return nil, err
+	}
+	if result.Moderated, err = m.unmarshalResult(ctx, moderation.Moderated); err != nil {
+		return nil, err
+	}
+	if result.Moderated == nil {
+		result.Moderated = result.Original
+	}
+	if result.Final, err = m.unmarshalResult(ctx, moderation.Final); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (m *assessmentModerationModel) Update(ctx context.Context, op *entity.Operator, req *v2.AssessmentModerationUpdateReq) error {
+	if !req.Action.Valid() || len(req.Students) <= 0 {
+		log.Warn(ctx, "moderation update request invalid", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	if err := m.permission.IsAllowEdit(ctx, op); err != nil {
+		return err
+	}
+
+	moderation, err := m.getModeration(ctx, op, req.ID)
+	if err != nil {
+		return err
+	}
+	if moderation.Status != v2.AssessmentModerationStatusPending {
+		log.Warn(ctx, "moderation has been reconciled", log.Any("moderation", moderation))
+		return ErrAssessmentHasCompleted
+	}
+	if moderation.MarkerID == op.UserID {
+		log.Warn(ctx, "marker can not moderate own assessment", log.Any("moderation", moderation), log.Any("op", op))
+		return constant.ErrForbidden
+	}
+
+	moderated := &v2.AssessmentModerationResult{
+		Students: req.Students,
+		Contents: req.Contents,
+	}
+	data, err := json.Marshal(moderated)
+	if err != nil {
+		log.Error(ctx, "marshal moderated result error", log.Err(err), log.Any("req", req))
+		return err
+	}
+
+	now := time.Now().Unix()
+	moderation.Moderated = string(data)
+	moderation.ModeratorID = op.UserID
+	moderation.UpdateAt = now
+
+	if req.Action == v2.AssessmentModerationActionDraft {
+		if _, err := assessmentV2.GetAssessmentModerationDA().Update(ctx, moderation); err != nil {
+			log.Error(ctx, "update moderation error", log.Err(err), log.Any("moderation", moderation))
+			return err
+		}
+		return nil
+	}
+
+	assessment, err := m.getAssessment(ctx, moderation.AssessmentID)
+	if err != nil {
+		return err
+	}
+	if assessment.Status != v2.AssessmentStatusPendingModeration {
+		log.Warn(ctx, "assessment is not pending moderation", log.Any("assessment", assessment), log.Any("moderation", moderation))
+		return constant.ErrInvalidArgs
+	}
+
+	processor, err := m.getProcessor(ctx, assessment)
+	if err != nil {
+		return err
+	}
+
+	moderation.Final = moderation.Moderated
+	moderation.Status = v2.AssessmentModerationStatusReconciled
+	moderation.ReconciledAt = now
+
+	// the reconciled result replaces the marker's one and completes the assessment,
+	// the moderation is reconciled in the same transaction
+	assessment.CompleteBy = v2.AssessmentCompleteByTeacher
+	updateCtx := withAssessmentUpdateTx(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if _, err := assessmentV2.GetAssessmentModerationDA().UpdateTx(ctx, tx, moderation); err != nil {
+			log.Error(ctx, "reconcile moderation error", log.Err(err), log.Any("moderation", moderation))
+			return err
+		}
+		return nil
+	})
+	updateCtx = withAssessmentCompletedTx(updateCtx, op, assessment)
+	updateCtx = withReportRollupDirtyTx(updateCtx, assessment)
+	if err := processor.Update(updateCtx, op, assessment, moderated.ToUpdateReq(assessment.ID, v2.AssessmentActionComplete)); err != nil {
+		return err
+	}
+
+	syncGradebookAsync(ctx, op, assessment.ID)
+
+	return nil
+}
+
+func (m *assessmentModerationModel) getPolicy(ctx context.Context, orgID, programID string) (*v2.AssessmentModerationPolicy, error) {
+	var policies []*v2.AssessmentModerationPolicy
+	err := assessmentV2.GetAssessmentModerationPolicyDA().Query(ctx, &assessmentV2.AssessmentModerationPolicyCondition{
+		OrgID: sql.NullString{
+			String: orgID,
+			Valid:  true,
+		},
+		ProgramIDs: entity.NullStrings{
+			Strings: []string{programID},
+			Valid:   true,
+		},
+	}, &policies)
+	if err != nil {
+		log.Error(ctx, "query moderation policy error", log.Err(err), log.String("orgID", orgID), log.String("programID", programID))
+		return nil, err
+	}
+	if len(policies) <= 0 {
+		return nil, nil
+	}
+
+	return policies[0], nil
+}
+
+func (m *assessmentModerationModel) getPolicyByAssessment(ctx context.Context, assessment *v2.Assessment) (*v2.AssessmentModerationPolicy, error) {
+	schedules, err := GetScheduleModel().QueryUnsafe(ctx, &entity.ScheduleQueryCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{assessment.ScheduleID},
+			Valid:   true,
+		},
+	})
+	if err != nil {
+		log.Error(ctx, "get schedule error", log.Err(err), log.String("scheduleID", assessment.ScheduleID))
+		return nil, err
+	}
+	if len(schedules) <= 0 || schedules[0].ProgramID == "" {
+		return nil, nil
+	}
+
+	return m.getPolicy(ctx, assessment.OrgID, schedules[0].ProgramID)
+}
+
+func (m *assessmentModerationModel) getModeration(ctx context.Context, op *entity.Operator, id string) (*v2.AssessmentModeration, error) {
+	moderation := new(v2.AssessmentModeration)
+	err := assessmentV2.GetAssessmentModerationDA().Get(ctx, id, moderation)
+	if err == dbo.ErrRecordNotFound {
+		return nil, constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get moderation error", log.Err(err), log.String("id", id))
+		return nil, err
+	}
+
+	if moderation.OrgID != op.OrgID || moderation.DeleteAt != 0 {
+		log.Warn(ctx, "moderation not found in org", log.Any("moderation", moderation), log.Any("op", op))
+		return nil, constant.ErrRecordNotFound
+	}
+
+	return moderation, nil
+}
+
+func (m *assessmentModerationModel) getAssessment(ctx context.Context, id string) (*v2.Assessment, error) {
+	assessment := new(v2.Assessment)
+	err := assessmentV2.GetAssessmentDA().Get(ctx, id, assessment)
+	if err == dbo.ErrRecordNotFound {
+		return nil, constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get assessment error", log.Err(err), log.String("assessmentID", id))
+		return nil, err
+	}
+
+	return assessment, nil
+}
+
+func (m *assessmentModerationModel) getProcessor(ctx context.Context, assessment *v2.Assessment) (IAssessmentProcessor, error) {
+	// processors are registered along with the assessment model
+	GetAssessmentModelV2()
+
+	processor, ok := AssessmentProcessorMap[assessment.AssessmentType]
+	if !ok {
+		log.Warn(ctx, "assessment type not supported", log.Any("assessment", assessment))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	return processor, nil
+}
+
+func (m *assessmentModerationModel) unmarshalResult(ctx context.Context, data string) (*v2.AssessmentModerationResult, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	result := new(v2.AssessmentModerationResult)
+	if err := json.Unmarshal([]byte(data), result); err != nil {
+		log.Error(ctx, "unmarshal moderation result error", log.Err(err), log.String("data", data))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (m *assessmentModerationModel) convertReplies(ctx context.Context, op *entity.Operator, moderations []*v2.AssessmentModeration) ([]*v2.AssessmentModerationReply, error) {
+	assessmentIDs := make([]string, 0, len(moderations))
+	userIDs := make([]string, 0, len(moderations)*2)
+	for _, item := range moderations {
+		assessmentIDs = append(assessmentIDs, item.AssessmentID)
+		userIDs = append(userIDs, item.MarkerID)
+		if item.ModeratorID != "" {
+			userIDs = append(userIDs, item.ModeratorID)
+		}
+	}
+
+	var assessments []*v2.Assessment
+	err := assessmentV2.GetAssessmentDA().Query(ctx, &assessmentV2.AssessmentCondition{
+		IDs: entity.NullStrings{
+			Strings: assessmentIDs,
+			Valid:   true,
+		},
+	}, &assessments)
+	if err != nil {
+		log.Error(ctx, "query assessments error", log.Err(err), log.Strings("assessmentIDs", assessmentIDs))
+		return nil, err
+	}
+	assessmentMap := make(map[string]*v2.Assessment, len(assessments))
+	for _, item := range assessments {
+		assessmentMap[item.ID] = item
+	}
+
+	userNameMap, err := external.GetUserServiceProvider().BatchGetNameMap(ctx, op, utils.SliceDeduplicationExcludeEmpty(userIDs))
+	if err != nil {
+		log.Error(ctx, "get user names error", log.Err(err), log.Strings("userIDs", userIDs))
+		return nil, err
+	}
+
+	result := make([]*v2.AssessmentModerationReply, 0, len(moderations))
+	for _, item := range moderations {
+		reply := &v2.AssessmentModerationReply{
+			ID:            item.ID,
+			AssessmentID:  item.AssessmentID,
+			ProgramID:     item.ProgramID,
+			Reason:        item.Reason,
+			Status:        item.Status,
+			MarkerID:      item.MarkerID,
+			MarkerName:    userNameMap[item.MarkerID],
+			ModeratorID:   item.ModeratorID,
+			ModeratorName: userNameMap[item.ModeratorID],
+			ReconciledAt:  item.ReconciledAt,
+			CreateAt:      item.CreateAt,
+		}
+		if assessment, ok := assessmentMap[item.AssessmentID]; ok {
+			reply.AssessmentTitle = assessment.Title
+			reply.AssessmentType = assessment.AssessmentType
+		}
+		result = append(result, reply)
+	}
+
+	return result, nil
+}
@@ -120,6 +120,22 @@ func (o *OfflineClassAssessment) Update(ctx context.Context, op *entity.Operator
 		}
 	}
 
+	// rubric
+	if err := at.initRubric(); err != nil {
+		return err
+	}
+	if err := at.initRubricScoreMap(); err != nil {
+		return err
+	}
+	waitAddRubricScores, waitUpdateRubricScores, err := prepareRubricScoresUpdateData(ctx, op, at, req)
+	if err != nil {
+		return err
+	}
+	waitUpdateAssessmentOutcomes, waitAddAssessmentOutcomes, err := mergeRubricOutcomesUpdateData(ctx, op, at, waitUpdateAssessmentOutcomes)
+	if err != nil {
+		return err
+	}
+
 	var status v2.AssessmentStatus
 	if req.Action == v2.AssessmentActionDraft {
 		status = v2.AssessmentStatusInDraft
@@ -137,6 +153,10 @@ func (o *OfflineClassAssessment) Update(ctx context.Context, op *entity.Operator
 	}
 
 	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if err := checkAssessmentUpdateTx(ctx, tx); err != nil {
+			return err
+		}
+
 		if _, err := assessmentV2.GetAssessmentDA().UpdateTx(ctx, tx, assessment); err != nil {
 			return err
 		}
@@ -159,6 +179,31 @@ func (o *OfflineClassAssessment) Update(ctx context.Context, op *entity.Operator
 			}
 		}
 
+		if len(waitAddAssessmentOutcomes) > 0 {
+			if _, err = assessmentV2.GetAssessmentUserOutcomeDA().InsertTx(ctx, tx, waitAddAssessmentOutcomes); err != nil {
+				return err
+			}
+		}
+
+		if len(waitAddRubricScores) > 0 {
+			if err = lockScoredRubricsTx(ctx, tx, waitAddRubricScores); err != nil {
+				return err
+			}
+			if _, err = assessmentV2.GetAssessmentUserRubricScoreDA().InsertTx(ctx, tx, waitAddRubricScores); err != nil {
+				return err
+			}
+		}
+
+		if len(waitUpdateRubricScores) > 0 {
+			if _, err = assessmentV2.GetAssessmentUserRubricScoreDA().UpdateTx(ctx, tx, waitUpdateRubricScores); err != nil {
+				return err
+			}
+		}
+
+		if err := runAssessmentUpdateTx(ctx, tx); err != nil {
+			return err
+		}
+
 		return nil
 	})
 
@@ -240,6 +285,7 @@ func (o *OfflineClassAssessment) ProcessStudents(ctx context.Context, at *Assess
 			Status:        item.StatusByUser,
 			ProcessStatus: item.StatusBySystem,
 			Results:       nil,
+			RubricResult:  ProcessStudentRubricResult(at.rubric, at.rubricScoreMap[item.ID]),
 		}
 
 		for _, content := range contents {
@@ -284,6 +330,33 @@ func (o *OfflineClassAssessment) ProcessStudents(ctx context.Context, at *Assess
 	return result, nil
 }
 
+// ProcessCompleteRate the rate of participating students who are fully scored on the rubric,
+// it is always 0 if no rubric is attached
+func (o *OfflineClassAssessment) ProcessCompleteRate(ctx context.Context, assessmentUsers []*v2.AssessmentUser, rubric *v2.AssessmentRubricDetail, rubricScoreMap map[string][]*v2.AssessmentUserRubricScore) float64 {
+	if rubric == nil || rubric.Rubric == nil {
+		return 0
+	}
+
+	studentCount := 0
+	studentCompleteCount := 0
+	for _, userItem := range assessmentUsers {
+		if userItem.UserType != v2.AssessmentUserTypeStudent || userItem.StatusByUser == v2.AssessmentUserStatusNotParticipate {
+			continue
+		}
+
+		studentCount++
+		if rubric.IsCompleted(rubricScoreMap[userItem.ID]) {
+			studentCompleteCount++
+		}
+	}
+
+	if studentCount == 0 || studentCompleteCount == 0 {
+		return 0
+	}
+
+	return float64(studentCompleteCount) / float64(studentCount)
+}
+
 func (o *OfflineClassAssessment) ProcessDiffContents(ctx context.Context, at *AssessmentInit) []*v2.AssessmentDiffContentStudentsReply {
 	return make([]*v2.AssessmentDiffContentStudentsReply, 0)
 }
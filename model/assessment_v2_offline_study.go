@@ -20,7 +20,8 @@ func NewOfflineStudyAssessment() IAssessmentProcessor {
 
 type OfflineStudyAssessment struct{}
 
-func (o *OfflineStudyAssessment) ProcessCompleteRate(ctx context.Context, assessmentUsers []*v2.AssessmentUser, reviewerFeedbackMap map[string]*v2.AssessmentReviewerFeedback) float64 {
+// ProcessCompleteRate a student is complete when feedback is submitted or the rubric is fully scored
+func (o *OfflineStudyAssessment) ProcessCompleteRate(ctx context.Context, assessmentUsers []*v2.AssessmentUser, reviewerFeedbackMap map[string]*v2.AssessmentReviewerFeedback, rubric *v2.AssessmentRubricDetail, rubricScoreMap map[string][]*v2.AssessmentUserRubricScore) float64 {
 	studentCount := 0
 	studentCompleteCount := 0
 	for _, userItem := range assessmentUsers {
@@ -29,6 +30,8 @@ func (o *OfflineStudyAssessment) ProcessCompleteRate(ctx context.Context, assess
 
 			if _, ok := reviewerFeedbackMap[userItem.ID]; ok {
 				studentCompleteCount++
+			} else if rubric != nil && rubric.IsCompleted(rubricScoreMap[userItem.ID]) {
+				studentCompleteCount++
 			}
 		}
 	}
@@ -38,7 +41,8 @@ func (o *OfflineStudyAssessment) ProcessCompleteRate(ctx context.Context, assess
 	} else if studentCompleteCount > studentCount {
 		log.Warn(ctx, "Completion rate result greater than 1",
 			log.Any("assessmentUsers", assessmentUsers),
-			log.Any("reviewerFeedbackMap", reviewerFeedbackMap))
+			log.Any("reviewerFeedbackMap", reviewerFeedbackMap),
+			log.Any("rubricScoreMap", rubricScoreMap))
 		return 1
 	} else {
 		return float64(studentCompleteCount) / float64(studentCount)
@@ -112,6 +116,7 @@ func (o *OfflineStudyAssessment) ProcessStudents(ctx context.Context, at *Assess
 			Status:        item.StatusByUser,
 			ProcessStatus: item.StatusBySystem,
 			Results:       make([]*v2.AssessmentStudentResultReply, 0),
+			RubricResult:  ProcessStudentRubricResult(at.rubric, at.rubricScoreMap[item.ID]),
 		}
 
 		studentResultItem := new(v2.AssessmentStudentResultReply)
@@ -476,7 +481,30 @@ func (o *OfflineStudyAssessment) Update(ctx context.Context, op *entity.Operator
 		return err
 	}
 
+	// rubric
+	if err = at.initRubric(); err != nil {
+		return err
+	}
+	if err = at.initRubricScoreMap(); err != nil {
+		return err
+	}
+	if err = at.initOutcomeFromAssessment(); err != nil {
+		return err
+	}
+	waitAddRubricScores, waitUpdateRubricScores, err := prepareRubricScoresUpdateData(ctx, op, at, req)
+	if err != nil {
+		return err
+	}
+	waitUpdateUserOutcomes, waitAddUserOutcomes, err := mergeRubricOutcomesUpdateData(ctx, op, at, waitUpdateUserOutcomes)
+	if err != nil {
+		return err
+	}
+
 	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if err := checkAssessmentUpdateTx(ctx, tx); err != nil {
+			return err
+		}
+
 		_, err := assessmentV2.GetAssessmentDA().UpdateTx(ctx, tx, waitUpdateAssessment)
 		if err != nil {
 			log.Error(ctx, "update assessment data error", log.Err(err), log.Any("waitUpdateAssessment", waitUpdateAssessment))
@@ -498,6 +526,14 @@ func (o *OfflineStudyAssessment) Update(ctx context.Context, op *entity.Operator
 			}
 		}
 
+		if len(waitAddUserOutcomes) > 0 {
+			_, err := assessmentV2.GetAssessmentUserOutcomeDA().InsertTx(ctx, tx, waitAddUserOutcomes)
+			if err != nil {
+				log.Error(ctx, "add assessment user outcome data error", log.Err(err), log.Any("waitAddUserOutcomes", waitAddUserOutcomes))
+				return err
+			}
+		}
+
 		if len(waitUpdateReviewerFeedbacks) > 0 {
 			_, err := assessmentV2.GetAssessmentUserResultDA().UpdateTx(ctx, tx, waitUpdateReviewerFeedbacks)
 			if err != nil {
@@ -514,6 +550,29 @@ func (o *OfflineStudyAssessment) Update(ctx context.Context, op *entity.Operator
 			}
 		}
 
+		if len(waitAddRubricScores) > 0 {
+			if err := lockScoredRubricsTx(ctx, tx, waitAddRubricScores); err != nil {
+				return err
+			}
+			_, err := assessmentV2.GetAssessmentUserRubricScoreDA().InsertTx(ctx, tx, waitAddRubricScores)
+			if err != nil {
+				log.Error(ctx, "add assessment user rubric score data error", log.Err(err), log.Any("waitAddRubricScores", waitAddRubricScores))
+				return err
+			}
+		}
+
+		if len(waitUpdateRubricScores) > 0 {
+			_, err := assessmentV2.GetAssessmentUserRubricScoreDA().UpdateTx(ctx, tx, waitUpdateRubricScores)
+			if err != nil {
+				log.Error(ctx, "update assessment user rubric score data error", log.Err(err), log.Any("waitUpdateRubricScores", waitUpdateRubricScores))
+				return err
+			}
+		}
+
+		if err := runAssessmentUpdateTx(ctx, tx); err != nil {
+			return err
+		}
+
 		return nil
 	})
 
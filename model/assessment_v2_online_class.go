@@ -317,6 +317,10 @@ func (o *OnlineClassAssessment) Update(ctx context.Context, op *entity.Operator,
 	}
 
 	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if err := checkAssessmentUpdateTx(ctx, tx); err != nil {
+			return err
+		}
+
 		if _, err := assessmentV2.GetAssessmentDA().UpdateTx(ctx, tx, assessment); err != nil {
 			return err
 		}
@@ -363,6 +367,10 @@ func (o *OnlineClassAssessment) Update(ctx context.Context, op *entity.Operator,
 			}
 		}
 
+		if err := runAssessmentUpdateTx(ctx, tx); err != nil {
+			return err
+		}
+
 		return nil
 	})
 
@@ -601,6 +609,7 @@ func (o *OnlineClassAssessment) ProcessStudents(ctx context.Context, at *Assessm
 					OutcomeID: outcomeID,
 				}
 				if at.assessment.Status == v2.AssessmentStatusInDraft ||
+					at.assessment.Status == v2.AssessmentStatusPendingModeration ||
 					at.assessment.Status == v2.AssessmentStatusComplete {
 					if userOutcome != nil && userOutcome.Status != "" {
 						userOutcomeReplyItem.Status = userOutcome.Status
@@ -140,6 +140,7 @@ func (o *OnlineStudyAssessment) ProcessStudents(ctx context.Context, at *Assessm
 					OutcomeID: outcomeID,
 				}
 				if at.assessment.Status == v2.AssessmentStatusInDraft ||
+					at.assessment.Status == v2.AssessmentStatusPendingModeration ||
 					at.assessment.Status == v2.AssessmentStatusComplete {
 					if userOutcome != nil && userOutcome.Status != "" {
 						userOutcomeReplyItem.Status = userOutcome.Status
@@ -9,6 +9,7 @@ import (
 	"github.com/KL-Engineering/kidsloop-cms-service/entity"
 	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
 	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
 )
 
 type AssessmentOrgPermission struct {
@@ -135,6 +136,35 @@ func (c *AssessmentPermission) SearchAllPermissions(ctx context.Context, op *ent
 	return nil
 }
 
+// SearchCompleteTeacherIDs teachers whose completed assessments the operator can view, nil means the whole organization
+func (c *AssessmentPermission) SearchCompleteTeacherIDs(ctx context.Context, op *entity.Operator) ([]string, error) {
+	if err := c.SearchAllPermissions(ctx, op); err != nil {
+		return nil, err
+	}
+	if !c.allowStatusComplete {
+		log.Warn(ctx, "user has no permission to view completed assessments", log.Any("operator", op))
+		return nil, constant.ErrForbidden
+	}
+
+	hasComplete := func(status entity.NullStrings) bool {
+		return status.Valid && utils.ContainsString(status.Strings, v2.AssessmentStatusComplete.String())
+	}
+
+	if hasComplete(c.OrgPermission.Status) {
+		return nil, nil
+	}
+
+	teacherIDs := make([]string, 0)
+	if hasComplete(c.SchoolPermission.Status) {
+		teacherIDs = append(teacherIDs, c.SchoolPermission.UserIDs...)
+	}
+	if hasComplete(c.MyPermission.Status) {
+		teacherIDs = append(teacherIDs, c.MyPermission.UserID)
+	}
+
+	return teacherIDs, nil
+}
+
 func (c *AssessmentPermission) IsAllowEdit(ctx context.Context, op *entity.Operator) error {
 	isAllow, err := external.GetPermissionServiceProvider().HasOrganizationPermission(ctx, op, external.AssessmentEditInProgressAssessment439)
 	if err != nil {
@@ -151,3 +181,18 @@ func (c *AssessmentPermission) IsAllowEdit(ctx context.Context, op *entity.Opera
 
 	return nil
 }
+
+// IsAllowOrgSettings settings apply to every assessment of the organization, so org level view permission is required
+func (c *AssessmentPermission) IsAllowOrgSettings(ctx context.Context, op *entity.Operator) error {
+	isAllow, err := external.GetPermissionServiceProvider().HasOrganizationPermission(ctx, op, external.AssessmentViewOrgCompletedAssessments424)
+	if err != nil {
+		log.Error(ctx, "check permission 424 failed", log.Err(err), log.Any("operator", op))
+		return err
+	}
+	if !isAllow {
+		log.Warn(ctx, "user has no permission to change assessment settings", log.Any("operator", op))
+		return constant.ErrForbidden
+	}
+
+	return nil
+}
@@ -177,6 +177,10 @@ func (o *ReviewStudyAssessment) Update(ctx context.Context, op *entity.Operator,
 	}
 
 	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if err := checkAssessmentUpdateTx(ctx, tx); err != nil {
+			return err
+		}
+
 		if _, err := assessmentV2.GetAssessmentDA().UpdateTx(ctx, tx, assessment); err != nil {
 			return err
 		}
@@ -199,6 +203,10 @@ func (o *ReviewStudyAssessment) Update(ctx context.Context, op *entity.Operator,
 			}
 		}
 
+		if err := runAssessmentUpdateTx(ctx, tx); err != nil {
+			return err
+		}
+
 		return nil
 	})
 
@@ -0,0 +1,954 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/da/assessmentV2"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+var (
+	ErrAssessmentRubricInUse = errors.New("rubric has been used to score students")
+
+	assessmentRubricModelInstance     IAssessmentRubricModel
+	assessmentRubricModelInstanceOnce = sync.Once{}
+)
+
+type IAssessmentRubricModel interface {
+	Add(ctx context.Context, op *entity.Operator, req *v2.AssessmentRubricAddReq) (string, error)
+	Update(ctx context.Context, op *entity.Operator, req *v2.AssessmentRubricUpdateReq) error
+	Delete(ctx context.Context, op *entity.Operator, id string) error
+	GetByID(ctx context.Context, op *entity.Operator, id string) (*v2.AssessmentRubricReply, error)
+	Page(ctx context.Context, op *entity.Operator, req *v2.AssessmentRubricQueryReq) (*v2.AssessmentRubricPageReply, error)
+
+	Attach(ctx context.Context, op *entity.Operator, req *v2.AssessmentRubricAttachReq) error
+	Detach(ctx context.Context, op *entity.Operator, relationType v2.AssessmentRubricRelationType, relationID string) error
+
+	GetRubricsBySchedules(ctx context.Context, op *entity.Operator, schedules []*entity.Schedule) (map[string]*v2.AssessmentRubricDetail, error)
+	GetScoredRubrics(ctx context.Context, op *entity.Operator, rubricIDs []string) (map[string]*v2.AssessmentRubricDetail, error)
+}
+
+type assessmentRubricModel struct {
+	permission *AssessmentPermission
+}
+
+func GetAssessmentRubricModel() IAssessmentRubricModel {
+	assessmentRubricModelInstanceOnce.Do(func() {
+		assessmentRubricModelInstance = &assessmentRubricModel{
+			permission: new(AssessmentPermission),
+		}
+	})
+	return assessmentRubricModelInstance
+}
+
+func (m *assessmentRubricModel) Add(ctx context.Context, op *entity.Operator, req *v2.AssessmentRubricAddReq) (string, error) {
+	if err := m.permission.IsAllowEdit(ctx, op); err != nil {
+		return "", err
+	}
+
+	if err := m.verifyRubricReq(ctx, op, req); err != nil {
+		return "", err
+	}
+
+	now := time.Now().Unix()
+	rubric := &v2.AssessmentRubric{
+		ID:          utils.NewID(),
+		OrgID:       op.OrgID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		CreatorID:   op.UserID,
+		CreateAt:    now,
+		UpdateAt:    now,
+	}
+	criteria, levels := m.buildRubricItems(rubric.ID, req, now)
+
+	err := dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if _, err := assessmentV2.GetAssessmentRubricDA().InsertTx(ctx, tx, rubric); err != nil {
+			return err
+		}
+		if _, err := assessmentV2.GetAssessmentRubricCriterionDA().InsertTx(ctx, tx, criteria); err != nil {
+			return err
+		}
+		if _, err := assessmentV2.GetAssessmentRubricLevelDA().InsertTx(ctx, tx, levels); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(ctx, "add rubric error", log.Err(err), log.Any("req", req))
+		return "", err
+	}
+
+	return rubric.ID, nil
+}
+
+func (m *assessmentRubricModel) Update(ctx context.Context, op *entity.Operator, req *v2.AssessmentRubricUpdateReq) error {
+	if err := m.permission.IsAllowEdit(ctx, op); err != nil {
+		return err
+	}
+
+	if err := m.verifyRubricReq(ctx, op, &req.AssessmentRubricAddReq); err != nil {
+		return err
+	}
+
+	rubric, err := m.getRubric(ctx, op, req.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	rubric.Name = strings.TrimSpace(req.Name)
+	rubric.Description = req.Description
+	rubric.UpdateAt = now
+	criteria, levels := m.buildRubricItems(rubric.ID, &req.AssessmentRubricAddReq, now)
+
+	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		// criteria and levels are referenced by student scores, so they are frozen once used,
+		// the lock keeps students from being scored between the count and the update
+		if err := assessmentV2.GetAssessmentRubricDA().LockTx(ctx, tx, []string{rubric.ID}, true); err != nil {
+			return err
+		}
+		scoreCount, err := assessmentV2.GetAssessmentUserRubricScoreDA().CountTx(ctx, tx, &assessmentV2.AssessmentUserRubricScoreCondition{
+			RubricID: sql.NullString{
+				String: rubric.ID,
+				Valid:  true,
+			},
+		}, &v2.AssessmentUserRubricScore{})
+		if err != nil {
+			log.Error(ctx, "count rubric scores error", log.Err(err), log.String("rubricID", rubric.ID))
+			return err
+		}
+		if scoreCount > 0 {
+			log.Warn(ctx, "rubric has been used", log.Any("rubric", rubric), log.Int("scoreCount", scoreCount))
+			return ErrAssessmentRubricInUse
+		}
+
+		if _, err := assessmentV2.GetAssessmentRubricDA().UpdateTx(ctx, tx, rubric); err != nil {
+			return err
+		}
+		if err := assessmentV2.GetAssessmentRubricCriterionDA().DeleteByRubricIDTx(ctx, tx, rubric.ID, now); err != nil {
+			return err
+		}
+		if err := assessmentV2.GetAssessmentRubricLevelDA().DeleteByRubricIDTx(ctx, tx, rubric.ID, now); err != nil {
+			return err
+		}
+		if _, err := assessmentV2.GetAssessmentRubricCriterionDA().InsertTx(ctx, tx, criteria); err != nil {
+			return err
+		}
+		if _, err := assessmentV2.GetAssessmentRubricLevelDA().InsertTx(ctx, tx, levels); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err == ErrAssessmentRubricInUse {
+		return err
+	}
+	if err != nil {
+		log.Error(ctx, "update rubric error", log.Err(err), log.Any("req", req))
+		return err
+	}
+
+	return nil
+}
+
+func (m *assessmentRubricModel) Delete(ctx context.Context, op *entity.Operator, id string) error {
+	if err := m.permission.IsAllowEdit(ctx, op); err != nil {
+		return err
+	}
+
+	rubric, err := m.getRubric(ctx, op, id)
+	if err != nil {
+		return err
+	}
+
+	var relations []*v2.AssessmentRubricRelation
+	err = assessmentV2.GetAssessmentRubricRelationDA().Query(ctx, &assessmentV2.AssessmentRubricRelationCondition{
+		RubricIDs: entity.NullStrings{
+			Strings: []string{rubric.ID},
+			Valid:   true,
+		},
+	}, &relations)
+	if err != nil {
+		log.Error(ctx, "query rubric relations error", log.Err(err), log.String("rubricID", rubric.ID))
+		return err
+	}
+
+	now := time.Now().Unix()
+	rubric.DeleteAt = now
+	for _, item := range relations {
+		item.DeleteAt = now
+	}
+
+	// scores already given keep pointing at the deleted rubric, they are read by GetScoredRubrics
+	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if _, err := assessmentV2.GetAssessmentRubricDA().UpdateTx(ctx, tx, rubric); err != nil {
+			return err
+		}
+		if len(relations) > 0 {
+			if _, err := assessmentV2.GetAssessmentRubricRelationDA().UpdateTx(ctx, tx, relations); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(ctx, "delete rubric error", log.Err(err), log.String("id", id))
+		return err
+	}
+
+	return nil
+}
+
+func (m *assessmentRubricModel) GetByID(ctx context.Context, op *entity.Operator, id string) (*v2.AssessmentRubricReply, error) {
+	rubric, err := m.getRubric(ctx, op, id)
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := m.getRubricDetails(ctx, []*v2.AssessmentRubric{rubric})
+	if err != nil {
+		return nil, err
+	}
+
+	return ConvertAssessmentRubricReply(details[0]), nil
+}
+
+func (m *assessmentRubricModel) Page(ctx context.Context, op *entity.Operator, req *v2.AssessmentRubricQueryReq) (*v2.AssessmentRubricPageReply, error) {
+	condition := &assessmentV2.AssessmentRubricCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		NameLike: sql.NullString{
+			String: req.Name,
+			Valid:  req.Name != "",
+		},
+		OrderBy: assessmentV2.NewAssessmentRubricOrderBy(req.OrderBy),
+		Pager: dbo.Pager{
+			Page:     req.PageIndex,
+			PageSize: req.PageSize,
+		},
+	}
+
+	var rubrics []*v2.AssessmentRubric
+	total, err := assessmentV2.GetAssessmentRubricDA().Page(ctx, condition, &rubrics)
+	if err != nil {
+		log.Error(ctx, "page rubric error", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	result := &v2.AssessmentRubricPageReply{
+		Total: total,
+		Data:  make([]*v2.AssessmentRubricReply, 0, len(rubrics)),
+	}
+	if len(rubrics) <= 0 {
+		return result, nil
+	}
+
+	details, err := m.getRubricDetails(ctx, rubrics)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range details {
+		result.Data = append(result.Data, ConvertAssessmentRubricReply(item))
+	}
+
+	return result, nil
+}
+
+func (m *assessmentRubricModel) Attach(ctx context.Context, op *entity.Operator, req *v2.AssessmentRubricAttachReq) error {
+	if err := m.permission.IsAllowEdit(ctx, op); err != nil {
+		return err
+	}
+
+	if !req.RelationType.Valid() || req.RelationID == "" {
+		log.Warn(ctx, "attach rubric request invalid", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	rubric, err := m.getRubric(ctx, op, req.RubricID)
+	if err != nil {
+		return err
+	}
+
+	if err := m.verifyRelationTarget(ctx, op, req.RelationType, req.RelationID); err != nil {
+		return err
+	}
+
+	existRelations, err := m.queryRelations(ctx, op, req.RelationType, []string{req.RelationID})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, item := range existRelations {
+		item.DeleteAt = now
+	}
+	relation := &v2.AssessmentRubricRelation{
+		ID:           utils.NewID(),
+		OrgID:        op.OrgID,
+		RubricID:     rubric.ID,
+		RelationType: req.RelationType,
+		RelationID:   req.RelationID,
+		CreateAt:     now,
+		UpdateAt:     now,
+	}
+
+	// a lesson plan or schedule has at most one rubric, attaching replaces the previous one
+	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if len(existRelations) > 0 {
+			if _, err := assessmentV2.GetAssessmentRubricRelationDA().UpdateTx(ctx, tx, existRelations); err != nil {
+				return err
+			}
+		}
+		if _, err := assessmentV2.GetAssessmentRubricRelationDA().InsertTx(ctx, tx, relation); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(ctx, "attach rubric error", log.Err(err), log.Any("req", req))
+		return err
+	}
+
+	return nil
+}
+
+func (m *assessmentRubricModel) Detach(ctx context.Context, op *entity.Operator, relationType v2.AssessmentRubricRelationType, relationID string) error {
+	if err := m.permission.IsAllowEdit(ctx, op); err != nil {
+		return err
+	}
+
+	if !relationType.Valid() || relationID == "" {
+		log.Warn(ctx, "detach rubric request invalid", log.Any("relationType", relationType), log.String("relationID", relationID))
+		return constant.ErrInvalidArgs
+	}
+
+	existRelations, err := m.queryRelations(ctx, op, relationType, []string{relationID})
+	if err != nil {
+		return err
+	}
+	if len(existRelations) <= 0 {
+		return constant.ErrRecordNotFound
+	}
+
+	now := time.Now().Unix()
+	for _, item := range existRelations {
+		item.DeleteAt = now
+	}
+	if _, err := assessmentV2.GetAssessmentRubricRelationDA().Update(ctx, existRelations); err != nil {
+		log.Error(ctx, "detach rubric error", log.Err(err), log.Any("existRelations", existRelations))
+		return err
+	}
+
+	return nil
+}
+
+// GetRubricsBySchedules a rubric attached to the schedule wins over the one attached to its lesson plan,
+// schedules without rubric are not in the result, key: scheduleID
+func (m *assessmentRubricModel) GetRubricsBySchedules(ctx context.Context, op *entity.Operator, schedules []*entity.Schedule) (map[string]*v2.AssessmentRubricDetail, error) {
+	result := make(map[string]*v2.AssessmentRubricDetail)
+	if len(schedules) <= 0 {
+		return result, nil
+	}
+
+	scheduleIDs := make([]string, 0, len(schedules))
+	lessonPlanIDs := make([]string, 0, len(schedules))
+	for _, item := range schedules {
+		scheduleIDs = append(scheduleIDs, item.ID)
+		if item.LessonPlanID != "" {
+			lessonPlanIDs = append(lessonPlanIDs, item.LessonPlanID)
+		}
+	}
+
+	scheduleRelations, err := m.queryRelations(ctx, op, v2.AssessmentRubricRelationTypeSchedule, scheduleIDs)
+	if err != nil {
+		return nil, err
+	}
+	lessonPlanRelations := make([]*v2.AssessmentRubricRelation, 0)
+	if len(lessonPlanIDs) > 0 {
+		lessonPlanRelations, err = m.queryRelations(ctx, op, v2.AssessmentRubricRelationTypeLessonPlan, utils.SliceDeduplication(lessonPlanIDs))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scheduleRubricIDMap := make(map[string]string, len(scheduleRelations))
+	rubricIDs := make([]string, 0, len(scheduleRelations)+len(lessonPlanRelations))
+	for _, item := range scheduleRelations {
+		scheduleRubricIDMap[item.RelationID] = item.RubricID
+		rubricIDs = append(rubricIDs, item.RubricID)
+	}
+	lessonPlanRubricIDMap := make(map[string]string, len(lessonPlanRelations))
+	for _, item := range lessonPlanRelations {
+		lessonPlanRubricIDMap[item.RelationID] = item.RubricID
+		rubricIDs = append(rubricIDs, item.RubricID)
+	}
+	if len(rubricIDs) <= 0 {
+		return result, nil
+	}
+
+	var rubrics []*v2.AssessmentRubric
+	err = assessmentV2.GetAssessmentRubricDA().Query(ctx, &assessmentV2.AssessmentRubricCondition{
+		IDs: entity.NullStrings{
+			Strings: utils.SliceDeduplication(rubricIDs),
+			Valid:   true,
+		},
+	}, &rubrics)
+	if err != nil {
+		log.Error(ctx, "query rubric error", log.Err(err), log.Strings("rubricIDs", rubricIDs))
+		return nil, err
+	}
+	if len(rubrics) <= 0 {
+		return result, nil
+	}
+
+	details, err := m.getRubricDetails(ctx, rubrics)
+	if err != nil {
+		return nil, err
+	}
+	detailMap := make(map[string]*v2.AssessmentRubricDetail, len(details))
+	for _, item := range details {
+		detailMap[item.Rubric.ID] = item
+	}
+
+	for _, item := range schedules {
+		rubricID, ok := scheduleRubricIDMap[item.ID]
+		if !ok {
+			rubricID, ok = lessonPlanRubricIDMap[item.LessonPlanID]
+		}
+		if !ok {
+			continue
+		}
+		if detail, ok := detailMap[rubricID]; ok {
+			result[item.ID] = detail
+		}
+	}
+
+	return result, nil
+}
+
+// GetScoredRubrics rubrics the scores were given with, deleted ones included, key: rubricID
+func (m *assessmentRubricModel) GetScoredRubrics(ctx context.Context, op *entity.Operator, rubricIDs []string) (map[string]*v2.AssessmentRubricDetail, error) {
+	result := make(map[string]*v2.AssessmentRubricDetail)
+	rubricIDs = utils.SliceDeduplicationExcludeEmpty(rubricIDs)
+	if len(rubricIDs) <= 0 {
+		return result, nil
+	}
+
+	condition := &assessmentV2.AssessmentRubricCondition{
+		IDs: entity.NullStrings{
+			Strings: rubricIDs,
+			Valid:   true,
+		},
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		IncludeDeleted: true,
+	}
+	var rubrics []*v2.AssessmentRubric
+	if err := assessmentV2.GetAssessmentRubricDA().Query(ctx, condition, &rubrics); err != nil {
+		log.Error(ctx, "query scored rubric error", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+	if len(rubrics) <= 0 {
+		return result, nil
+	}
+
+	details, err := m.getRubricDetails(ctx, rubrics)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range details {
+		result[item.Rubric.ID] = item
+	}
+
+	return result, nil
+}
+
+// verifyRelationTarget the schedule or lesson plan must exist in the org of operator
+func (m *assessmentRubricModel) verifyRelationTarget(ctx context.Context, op *entity.Operator, relationType v2.AssessmentRubricRelationType, relationID string) error {
+	switch relationType {
+	case v2.AssessmentRubricRelationTypeSchedule:
+		schedule, err := GetScheduleModel().GetPlainByID(ctx, relationID)
+		if err != nil {
+			return err
+		}
+		if schedule.OrgID != op.OrgID {
+			log.Warn(ctx, "schedule not found in org", log.Any("schedule", schedule), log.Any("op", op))
+			return constant.ErrRecordNotFound
+		}
+	case v2.AssessmentRubricRelationTypeLessonPlan:
+		contents, err := da.GetContentDA().GetContentByIDList(ctx, dbo.MustGetDB(ctx), []string{relationID})
+		if err != nil {
+			log.Error(ctx, "get lesson plan error", log.Err(err), log.String("relationID", relationID))
+			return err
+		}
+		if len(contents) <= 0 || contents[0].ContentType != entity.ContentTypePlan ||
+			contents[0].Org != op.OrgID || contents[0].DeleteAt != 0 {
+			log.Warn(ctx, "lesson plan not found in org", log.Any("contents", contents), log.Any("op", op))
+			return constant.ErrRecordNotFound
+		}
+	}
+
+	return nil
+}
+
+func (m *assessmentRubricModel) verifyRubricReq(ctx context.Context, op *entity.Operator, req *v2.AssessmentRubricAddReq) error {
+	if strings.TrimSpace(req.Name) == "" || len(req.Criteria) <= 0 || len(req.Levels) <= 0 {
+		log.Warn(ctx, "rubric name, criteria and levels are required", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	for _, item := range req.Criteria {
+		if strings.TrimSpace(item.Name) == "" {
+			log.Warn(ctx, "criterion name is required", log.Any("req", req))
+			return constant.ErrInvalidArgs
+		}
+	}
+
+	hasPositivePoints := false
+	for _, item := range req.Levels {
+		if strings.TrimSpace(item.Name) == "" || item.Points < 0 {
+			log.Warn(ctx, "level name is required and points can not be negative", log.Any("req", req))
+			return constant.ErrInvalidArgs
+		}
+		if item.Points > 0 {
+			hasPositivePoints = true
+		}
+	}
+	if !hasPositivePoints {
+		log.Warn(ctx, "at least one level must be worth points", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	outcomeIDs := make([]string, 0, len(req.Criteria))
+	for _, item := range req.Criteria {
+		if item.OutcomeID != "" {
+			outcomeIDs = append(outcomeIDs, item.OutcomeID)
+		}
+	}
+	outcomeIDs = utils.SliceDeduplicationExcludeEmpty(outcomeIDs)
+	if len(outcomeIDs) <= 0 {
+		return nil
+	}
+
+	outcomes, err := GetOutcomeModel().GetByIDs(ctx, op, dbo.MustGetDB(ctx), outcomeIDs)
+	if err != nil {
+		return err
+	}
+	if len(outcomes) != len(outcomeIDs) {
+		log.Warn(ctx, "some outcomes of the rubric not found", log.Strings("outcomeIDs", outcomeIDs), log.Any("outcomes", outcomes))
+		return constant.ErrInvalidArgs
+	}
+
+	return nil
+}
+
+func (m *assessmentRubricModel) buildRubricItems(rubricID string, req *v2.AssessmentRubricAddReq, now int64) ([]*v2.AssessmentRubricCriterion, []*v2.AssessmentRubricLevel) {
+	criteria := make([]*v2.AssessmentRubricCriterion, len(req.Criteria))
+	for i, item := range req.Criteria {
+		criteria[i] = &v2.AssessmentRubricCriterion{
+			ID:          utils.NewID(),
+			RubricID:    rubricID,
+			Name:        strings.TrimSpace(item.Name),
+			Description: item.Description,
+			OutcomeID:   item.OutcomeID,
+			SortIndex:   i,
+			CreateAt:    now,
+			UpdateAt:    now,
+		}
+	}
+
+	levels := make([]*v2.AssessmentRubricLevel, len(req.Levels))
+	for i, item := range req.Levels {
+		levels[i] = &v2.AssessmentRubricLevel{
+			ID:          utils.NewID(),
+			RubricID:    rubricID,
+			Name:        strings.TrimSpace(item.Name),
+			Description: item.Description,
+			Points:      item.Points,
+			SortIndex:   i,
+			CreateAt:    now,
+			UpdateAt:    now,
+		}
+	}
+
+	return criteria, levels
+}
+
+func (m *assessmentRubricModel) getRubric(ctx context.Context, op *entity.Operator, id string) (*v2.AssessmentRubric, error) {
+	rubric := new(v2.AssessmentRubric)
+	err := assessmentV2.GetAssessmentRubricDA().Get(ctx, id, rubric)
+	if err == dbo.ErrRecordNotFound {
+		return nil, constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Warn(ctx, "get rubric error", log.Err(err), log.String("id", id))
+		return nil, err
+	}
+
+	if rubric.OrgID != op.OrgID || rubric.DeleteAt != 0 {
+		log.Warn(ctx, "rubric not found in org", log.Any("rubric", rubric), log.Any("op", op))
+		return nil, constant.ErrRecordNotFound
+	}
+
+	return rubric, nil
+}
+
+func (m *assessmentRubricModel) queryRelations(ctx context.Context, op *entity.Operator, relationType v2.AssessmentRubricRelationType, relationIDs []string) ([]*v2.AssessmentRubricRelation, error) {
+	condition := &assessmentV2.AssessmentRubricRelationCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		RelationType: sql.NullString{
+			String: relationType.String(),
+			Valid:  true,
+		},
+		RelationIDs: entity.NullStrings{
+			Strings: relationIDs,
+			Valid:   true,
+		},
+	}
+
+	var result []*v2.AssessmentRubricRelation
+	err := assessmentV2.GetAssessmentRubricRelationDA().Query(ctx, condition, &result)
+	if err != nil {
+		log.Error(ctx, "query rubric relations error", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (m *assessmentRubricModel) getRubricDetails(ctx context.Context, rubrics []*v2.AssessmentRubric) ([]*v2.AssessmentRubricDetail, error) {
+	rubricIDs := make([]string, len(rubrics))
+	for i, item := range rubrics {
+		rubricIDs[i] = item.ID
+	}
+	itemCond := &assessmentV2.AssessmentRubricItemCondition{
+		RubricIDs: entity.NullStrings{
+			Strings: rubricIDs,
+			Valid:   true,
+		},
+	}
+
+	var criteria []*v2.AssessmentRubricCriterion
+	if err := assessmentV2.GetAssessmentRubricCriterionDA().Query(ctx, itemCond, &criteria); err != nil {
+		log.Error(ctx, "query rubric criteria error", log.Err(err), log.Any("itemCond", itemCond))
+		return nil, err
+	}
+
+	var levels []*v2.AssessmentRubricLevel
+	if err := assessmentV2.GetAssessmentRubricLevelDA().Query(ctx, itemCond, &levels); err != nil {
+		log.Error(ctx, "query rubric levels error", log.Err(err), log.Any("itemCond", itemCond))
+		return nil, err
+	}
+
+	detailMap := make(map[string]*v2.AssessmentRubricDetail, len(rubrics))
+	result := make([]*v2.AssessmentRubricDetail, len(rubrics))
+	for i, item := range rubrics {
+		result[i] = &v2.AssessmentRubricDetail{Rubric: item}
+		detailMap[item.ID] = result[i]
+	}
+	for _, item := range criteria {
+		if detail, ok := detailMap[item.RubricID]; ok {
+			detail.Criteria = append(detail.Criteria, item)
+		}
+	}
+	for _, item := range levels {
+		if detail, ok := detailMap[item.RubricID]; ok {
+			detail.Levels = append(detail.Levels, item)
+		}
+	}
+
+	return result, nil
+}
+
+// lockScoredRubricsTx rubrics can't be edited while students are scored with them
+func lockScoredRubricsTx(ctx context.Context, tx *dbo.DBContext, scores []*v2.AssessmentUserRubricScore) error {
+	rubricIDs := make([]string, 0, len(scores))
+	for _, item := range scores {
+		rubricIDs = append(rubricIDs, item.RubricID)
+	}
+	return assessmentV2.GetAssessmentRubricDA().LockTx(ctx, tx, utils.SliceDeduplicationExcludeEmpty(rubricIDs), false)
+}
+
+func ConvertAssessmentRubricReply(detail *v2.AssessmentRubricDetail) *v2.AssessmentRubricReply {
+	result := &v2.AssessmentRubricReply{
+		ID:          detail.Rubric.ID,
+		Name:        detail.Rubric.Name,
+		Description: detail.Rubric.Description,
+		CreatorID:   detail.Rubric.CreatorID,
+		MaxPoints:   detail.MaxPoints(),
+		Criteria:    make([]*v2.AssessmentRubricCriterionReply, 0, len(detail.Criteria)),
+		Levels:      make([]*v2.AssessmentRubricLevelReply, 0, len(detail.Levels)),
+		CreateAt:    detail.Rubric.CreateAt,
+		UpdateAt:    detail.Rubric.UpdateAt,
+	}
+	for _, item := range detail.Criteria {
+		result.Criteria = append(result.Criteria, &v2.AssessmentRubricCriterionReply{
+			ID:          item.ID,
+			Name:        item.Name,
+			Description: item.Description,
+			OutcomeID:   item.OutcomeID,
+		})
+	}
+	for _, item := range detail.Levels {
+		result.Levels = append(result.Levels, &v2.AssessmentRubricLevelReply{
+			ID:          item.ID,
+			Name:        item.Name,
+			Description: item.Description,
+			Points:      item.Points,
+		})
+	}
+
+	return result
+}
+
+// ProcessStudentRubricResult build the rubric reply of a student, nil when the assessment has no rubric
+func ProcessStudentRubricResult(rubric *v2.AssessmentRubricDetail, scores []*v2.AssessmentUserRubricScore) *v2.AssessmentStudentRubricReply {
+	if rubric == nil || rubric.Rubric == nil {
+		return nil
+	}
+
+	result := &v2.AssessmentStudentRubricReply{
+		RubricID:    rubric.Rubric.ID,
+		TotalPoints: rubric.TotalPoints(scores),
+		MaxPoints:   rubric.MaxPoints(),
+		IsCompleted: rubric.IsCompleted(scores),
+		Scores:      make([]*v2.AssessmentStudentRubricScoreReply, 0, len(scores)),
+	}
+	for _, item := range scores {
+		result.Scores = append(result.Scores, &v2.AssessmentStudentRubricScoreReply{
+			CriterionID: item.CriterionID,
+			LevelID:     item.LevelID,
+			Points:      item.Points,
+		})
+	}
+
+	return result
+}
+
+// prepareRubricScoresUpdateData turn the rubric scores in request into rows to add or update,
+// existing rows are keyed by assessment user id
+func prepareRubricScoresUpdateData(ctx context.Context, op *entity.Operator, at *AssessmentInit, req *v2.AssessmentUpdateReq) ([]*v2.AssessmentUserRubricScore, []*v2.AssessmentUserRubricScore, error) {
+	waitAddScores := make([]*v2.AssessmentUserRubricScore, 0)
+	waitUpdateScores := make([]*v2.AssessmentUserRubricScore, 0)
+
+	rubric := at.rubric
+	if rubric == nil || rubric.Rubric == nil {
+		for _, stuItem := range req.Students {
+			if len(stuItem.RubricScores) > 0 {
+				log.Warn(ctx, "assessment has no rubric", log.Any("req", req))
+				return nil, nil, constant.ErrInvalidArgs
+			}
+		}
+		return waitAddScores, waitUpdateScores, nil
+	}
+
+	criterionMap := make(map[string]*v2.AssessmentRubricCriterion, len(rubric.Criteria))
+	for _, item := range rubric.Criteria {
+		criterionMap[item.ID] = item
+	}
+	levelMap := make(map[string]*v2.AssessmentRubricLevel, len(rubric.Levels))
+	for _, item := range rubric.Levels {
+		levelMap[item.ID] = item
+	}
+
+	now := time.Now().Unix()
+	for _, stuItem := range req.Students {
+		if len(stuItem.RubricScores) <= 0 {
+			continue
+		}
+
+		assessmentUserItem, ok := at.assessmentUserIDTypeMap[GetAssessmentKey([]string{stuItem.StudentID, v2.AssessmentUserTypeStudent.String()})]
+		if !ok {
+			log.Warn(ctx, "student not exist", log.Any("stuItem", stuItem))
+			return nil, nil, constant.ErrInvalidArgs
+		}
+		if stuItem.Status == v2.AssessmentUserStatusNotParticipate ||
+			assessmentUserItem.StatusByUser == v2.AssessmentUserStatusNotParticipate {
+			log.Warn(ctx, "student not participate", log.Any("stuItem", stuItem), log.Any("assessmentUserItem", assessmentUserItem))
+			return nil, nil, constant.ErrInvalidArgs
+		}
+
+		existScoreMap := make(map[string]*v2.AssessmentUserRubricScore)
+		for _, item := range at.rubricScoreMap[assessmentUserItem.ID] {
+			existScoreMap[item.CriterionID] = item
+		}
+
+		for _, scoreItem := range stuItem.RubricScores {
+			if _, ok := criterionMap[scoreItem.CriterionID]; !ok {
+				log.Warn(ctx, "criterion not belong to rubric", log.Any("scoreItem", scoreItem), log.Any("rubric", rubric.Rubric))
+				return nil, nil, constant.ErrInvalidArgs
+			}
+			level, ok := levelMap[scoreItem.LevelID]
+			if !ok {
+				log.Warn(ctx, "level not belong to rubric", log.Any("scoreItem", scoreItem), log.Any("rubric", rubric.Rubric))
+				return nil, nil, constant.ErrInvalidArgs
+			}
+
+			if existItem, ok := existScoreMap[scoreItem.CriterionID]; ok {
+				existItem.LevelID = level.ID
+				existItem.Points = level.Points
+				existItem.ReviewerID = op.UserID
+				existItem.UpdateAt = now
+				waitUpdateScores = append(waitUpdateScores, existItem)
+				continue
+			}
+
+			newItem := &v2.AssessmentUserRubricScore{
+				ID:               utils.NewID(),
+				AssessmentUserID: assessmentUserItem.ID,
+				RubricID:         rubric.Rubric.ID,
+				CriterionID:      scoreItem.CriterionID,
+				LevelID:          level.ID,
+				Points:           level.Points,
+				ReviewerID:       op.UserID,
+				CreateAt:         now,
+				UpdateAt:         now,
+			}
+			existScoreMap[scoreItem.CriterionID] = newItem
+			waitAddScores = append(waitAddScores, newItem)
+		}
+
+		scores := make([]*v2.AssessmentUserRubricScore, 0, len(existScoreMap))
+		for _, item := range existScoreMap {
+			scores = append(scores, item)
+		}
+		at.rubricScoreMap[assessmentUserItem.ID] = scores
+	}
+
+	return waitAddScores, waitUpdateScores, nil
+}
+
+// mergeRubricOutcomesUpdateData derive the outcome status of criteria linked to outcomes
+// and merge them into the outcomes waiting for update, a derived status overrides the one in request.
+// Students without a row of the outcome get a new one, it belongs to the lesson plan if the assessment has it
+func mergeRubricOutcomesUpdateData(ctx context.Context, op *entity.Operator, at *AssessmentInit, waitUpdateOutcomes []*v2.AssessmentUserOutcome) ([]*v2.AssessmentUserOutcome, []*v2.AssessmentUserOutcome, error) {
+	waitAddOutcomes := make([]*v2.AssessmentUserOutcome, 0)
+
+	rubric := at.rubric
+	if rubric == nil || rubric.Rubric == nil {
+		return waitUpdateOutcomes, waitAddOutcomes, nil
+	}
+
+	criterionOutcomeMap := make(map[string]string)
+	outcomeIDs := make([]string, 0)
+	for _, item := range rubric.Criteria {
+		if item.OutcomeID != "" {
+			criterionOutcomeMap[item.ID] = item.OutcomeID
+			outcomeIDs = append(outcomeIDs, item.OutcomeID)
+		}
+	}
+	if len(outcomeIDs) <= 0 {
+		return waitUpdateOutcomes, waitAddOutcomes, nil
+	}
+
+	outcomes, err := GetOutcomeModel().GetByIDs(ctx, op, dbo.MustGetDB(ctx), utils.SliceDeduplicationExcludeEmpty(outcomeIDs))
+	if err != nil {
+		return nil, nil, err
+	}
+	outcomeMap := make(map[string]*entity.Outcome, len(outcomes))
+	for _, item := range outcomes {
+		outcomeMap[item.ID] = item
+	}
+
+	// key: AssessmentUserID+OutcomeID
+	derivedStatusMap := make(map[string]v2.AssessmentUserOutcomeStatus)
+	for assessmentUserID, scores := range at.rubricScoreMap {
+		for _, scoreItem := range scores {
+			outcomeID, ok := criterionOutcomeMap[scoreItem.CriterionID]
+			if !ok {
+				continue
+			}
+			outcome, ok := outcomeMap[outcomeID]
+			if !ok {
+				continue
+			}
+
+			key := GetAssessmentKey([]string{assessmentUserID, outcomeID})
+			status := rubric.OutcomeStatus(scoreItem.Points, outcome.ScoreThreshold, outcome.Assumed)
+			// several criteria may share one outcome, all of them need to be achieved
+			if existStatus, ok := derivedStatusMap[key]; ok && existStatus == v2.AssessmentUserOutcomeStatusNotAchieved {
+				continue
+			}
+			derivedStatusMap[key] = status
+		}
+	}
+
+	now := time.Now().Unix()
+	waitUpdateMap := make(map[string]*v2.AssessmentUserOutcome, len(waitUpdateOutcomes))
+	for _, item := range waitUpdateOutcomes {
+		waitUpdateMap[item.ID] = item
+	}
+	existKeys := make(map[string]bool)
+	for _, item := range at.outcomeMapFromAssessment {
+		key := GetAssessmentKey([]string{item.AssessmentUserID, item.OutcomeID})
+		status, ok := derivedStatusMap[key]
+		if !ok {
+			continue
+		}
+		existKeys[key] = true
+
+		if waitItem, ok := waitUpdateMap[item.ID]; ok {
+			waitItem.Status = status
+			waitItem.UpdateAt = now
+			continue
+		}
+
+		item.Status = status
+		item.UpdateAt = now
+		waitUpdateOutcomes = append(waitUpdateOutcomes, item)
+		waitUpdateMap[item.ID] = item
+	}
+
+	assessmentContentID := ""
+	for _, item := range at.contentMapFromAssessment {
+		if item.ContentType == v2.AssessmentContentTypeLessonPlan {
+			assessmentContentID = item.ID
+			break
+		}
+	}
+	for assessmentUserID, scores := range at.rubricScoreMap {
+		for _, scoreItem := range scores {
+			outcomeID, ok := criterionOutcomeMap[scoreItem.CriterionID]
+			if !ok {
+				continue
+			}
+			key := GetAssessmentKey([]string{assessmentUserID, outcomeID})
+			status, ok := derivedStatusMap[key]
+			if !ok || existKeys[key] {
+				continue
+			}
+			existKeys[key] = true
+
+			waitAddOutcomes = append(waitAddOutcomes, &v2.AssessmentUserOutcome{
+				ID:                  utils.NewID(),
+				AssessmentUserID:    assessmentUserID,
+				AssessmentContentID: assessmentContentID,
+				OutcomeID:           outcomeID,
+				Status:              status,
+				CreateAt:            now,
+				UpdateAt:            now,
+			})
+		}
+	}
+
+	return waitUpdateOutcomes, waitAddOutcomes, nil
+}
@@ -0,0 +1,417 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/mutex"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+const (
+	auditExportPageSize = 1000
+	auditPurgeBatchSize = 1000
+)
+
+type auditRecorderKey struct{}
+
+// AuditRecorder collects snapshots from models while a request is handled, they are saved with the audit log
+type AuditRecorder struct {
+	lock     sync.Mutex
+	targetID string
+	before   string
+	after    string
+}
+
+// WithAuditRecorder models record nothing when the context has no recorder
+func WithAuditRecorder(ctx context.Context) (context.Context, *AuditRecorder) {
+	recorder := new(AuditRecorder)
+	return context.WithValue(ctx, auditRecorderKey{}, recorder), recorder
+}
+
+// Snapshots target id is empty when models recorded nothing
+func (r *AuditRecorder) Snapshots() (targetID, before, after string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.targetID, r.before, r.after
+}
+
+// AuditBefore record the target before it is changed, only the first target of a request is recorded.
+// The target is marshaled at once, changing it afterwards does not change the snapshot.
+func AuditBefore(ctx context.Context, targetID string, target interface{}) {
+	recordAuditSnapshot(ctx, targetID, target, false)
+}
+
+// AuditAfter record the target after it is changed, the request body is saved when it is not recorded
+func AuditAfter(ctx context.Context, targetID string, target interface{}) {
+	recordAuditSnapshot(ctx, targetID, target, true)
+}
+
+func recordAuditSnapshot(ctx context.Context, targetID string, target interface{}, after bool) {
+	recorder, ok := ctx.Value(auditRecorderKey{}).(*AuditRecorder)
+	if !ok {
+		return
+	}
+
+	data, err := json.Marshal(target)
+	if err != nil {
+		log.Warn(ctx, "marshal audit snapshot failed", log.Err(err), log.String("target_id", targetID))
+		return
+	}
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	if recorder.targetID != "" && recorder.targetID != targetID {
+		return
+	}
+	recorder.targetID = targetID
+	if after {
+		recorder.after = string(data)
+	} else if recorder.before == "" {
+		recorder.before = string(data)
+	}
+}
+
+type IAuditModel interface {
+	// Record save the audit log, a failure must not fail the request
+	Record(ctx context.Context, auditLog *entity.AuditLog) error
+	Query(ctx context.Context, op *entity.Operator, req *entity.AuditLogQueryReq) (*entity.AuditLogPageReply, error)
+	// Export call write with pages of logs, latest first, until the export limit
+	Export(ctx context.Context, op *entity.Operator, req *entity.AuditLogQueryReq, write func(logs []*entity.AuditLogView) error) error
+
+	GetSetting(ctx context.Context, op *entity.Operator) (*entity.AuditSettingView, error)
+	UpdateSetting(ctx context.Context, op *entity.Operator, req *entity.AuditSettingUpdateReq) error
+	// Purge delete logs older than the retention of their organization, returns the number deleted
+	Purge(ctx context.Context) (int64, error)
+}
+
+type auditModel struct{}
+
+var (
+	_auditModelOnce sync.Once
+	_auditModel     IAuditModel
+)
+
+func GetAuditModel() IAuditModel {
+	_auditModelOnce.Do(func() {
+		_auditModel = &auditModel{}
+	})
+	return _auditModel
+}
+
+// StartAuditRetentionWorker purge expired audit logs regularly
+func StartAuditRetentionWorker(ctx context.Context) {
+	ticker := time.NewTicker(config.Get().Audit.PurgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purgeAuditLogs(utils.CloneContextWithTrace(ctx))
+	}
+}
+
+func purgeAuditLogs(ctx context.Context) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(ctx, "purge audit logs panic", log.Any("recover error", err))
+		}
+	}()
+
+	locker, err := mutex.NewLock(ctx, da.RedisKeyPrefixAuditPurgeLock)
+	if err != nil {
+		log.Error(ctx, "purge audit logs: create lock failed", log.Err(err))
+		return
+	}
+	locker.Lock()
+	defer locker.Unlock()
+
+	count, err := GetAuditModel().Purge(ctx)
+	if err != nil {
+		log.Error(ctx, "purge audit logs failed", log.Err(err))
+		return
+	}
+
+	if count > 0 {
+		log.Info(ctx, "purge audit logs finished", log.Int64("count", count))
+	}
+}
+
+func (m *auditModel) checkPermission(ctx context.Context, op *entity.Operator, permission external.PermissionName) error {
+	isAllow, err := external.GetPermissionServiceProvider().HasOrganizationPermission(ctx, op, permission)
+	if err != nil {
+		log.Error(ctx, "check audit permission failed", log.Err(err), log.Any("operator", op), log.String("permission", string(permission)))
+		return err
+	}
+	if !isAllow {
+		log.Warn(ctx, "user has no audit permission", log.Any("operator", op), log.String("permission", string(permission)))
+		return constant.ErrForbidden
+	}
+
+	return nil
+}
+
+func (m *auditModel) Record(ctx context.Context, auditLog *entity.AuditLog) error {
+	auditLog.ID = utils.NewID()
+	auditLog.Before = entity.TruncateAuditSnapshot(auditLog.Before)
+	auditLog.After = entity.TruncateAuditSnapshot(auditLog.After)
+	if auditLog.CreateAt == 0 {
+		auditLog.CreateAt = time.Now().Unix()
+	}
+
+	if _, err := da.GetAuditLogDA().Insert(ctx, auditLog); err != nil {
+		log.Error(ctx, "insert audit log failed", log.Err(err), log.Any("audit_log", auditLog))
+		return err
+	}
+
+	return nil
+}
+
+func (m *auditModel) Query(ctx context.Context, op *entity.Operator, req *entity.AuditLogQueryReq) (*entity.AuditLogPageReply, error) {
+	if !req.Valid() {
+		log.Warn(ctx, "audit log query request invalid", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op, external.ViewAuditLogs10902); err != nil {
+		return nil, err
+	}
+
+	condition := m.buildCondition(op, req)
+	condition.Pager = dbo.Pager{
+		Page:     req.PageIndex,
+		PageSize: req.PageSize,
+	}
+
+	var logs []*entity.AuditLog
+	total, err := da.GetAuditLogDA().Page(ctx, condition, &logs)
+	if err != nil {
+		log.Error(ctx, "page audit logs failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	return &entity.AuditLogPageReply{
+		Total: total,
+		Data:  m.convertViews(logs),
+	}, nil
+}
+
+func (m *auditModel) Export(ctx context.Context, op *entity.Operator, req *entity.AuditLogQueryReq, write func(logs []*entity.AuditLogView) error) error {
+	if !req.Valid() {
+		log.Warn(ctx, "audit log export request invalid", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op, external.ViewAuditLogs10902); err != nil {
+		return err
+	}
+
+	condition := m.buildCondition(op, req)
+	// logs inserted while exporting shift pages, the upper bound keeps them out
+	if !condition.CreateAtLe.Valid {
+		condition.CreateAtLe = sql.NullInt64{Int64: time.Now().Unix(), Valid: true}
+	}
+
+	limit := config.Get().Audit.ExportLimit
+	for page, exported := 1, 0; exported < limit; page++ {
+		condition.Pager = dbo.Pager{
+			Page:     page,
+			PageSize: auditExportPageSize,
+		}
+
+		var logs []*entity.AuditLog
+		if err := da.GetAuditLogDA().Query(ctx, condition, &logs); err != nil {
+			log.Error(ctx, "query audit logs failed", log.Err(err), log.Any("condition", condition))
+			return err
+		}
+		if exported+len(logs) > limit {
+			logs = logs[:limit-exported]
+		}
+		if len(logs) == 0 {
+			break
+		}
+
+		if err := write(m.convertViews(logs)); err != nil {
+			log.Warn(ctx, "write audit logs failed", log.Err(err))
+			return err
+		}
+
+		exported += len(logs)
+		if len(logs) < auditExportPageSize {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (m *auditModel) buildCondition(op *entity.Operator, req *entity.AuditLogQueryReq) *da.AuditLogCondition {
+	return &da.AuditLogCondition{
+		OrgID:      sql.NullString{String: op.OrgID, Valid: true},
+		ActorID:    sql.NullString{String: req.ActorID, Valid: req.ActorID != ""},
+		Action:     sql.NullString{String: req.Action, Valid: req.Action != ""},
+		TargetType: sql.NullString{String: req.TargetType, Valid: req.TargetType != ""},
+		TargetID:   sql.NullString{String: req.TargetID, Valid: req.TargetID != ""},
+		Method:     sql.NullString{String: req.Method, Valid: req.Method != ""},
+		CreateAtGe: sql.NullInt64{Int64: req.StartAt, Valid: req.StartAt > 0},
+		CreateAtLe: sql.NullInt64{Int64: req.EndAt, Valid: req.EndAt > 0},
+	}
+}
+
+func (m *auditModel) convertViews(logs []*entity.AuditLog) []*entity.AuditLogView {
+	result := make([]*entity.AuditLogView, 0, len(logs))
+	for _, item := range logs {
+		result = append(result, &entity.AuditLogView{
+			ID:         item.ID,
+			ActorID:    item.ActorID,
+			Action:     item.Action,
+			TargetType: item.TargetType,
+			TargetID:   item.TargetID,
+			Before:     item.Before,
+			After:      item.After,
+			Method:     item.Method,
+			Path:       item.Path,
+			StatusCode: item.StatusCode,
+			IP:         item.IP,
+			UserAgent:  item.UserAgent,
+			Duration:   item.Duration,
+			CreateAt:   item.CreateAt,
+		})
+	}
+	return result
+}
+
+func (m *auditModel) getSetting(ctx context.Context, orgID string) (*entity.AuditSetting, error) {
+	setting := new(entity.AuditSetting)
+	err := da.GetAuditSettingDA().Get(ctx, orgID, setting)
+	if err == dbo.ErrRecordNotFound {
+		return nil, constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get audit setting failed", log.Err(err), log.String("org_id", orgID))
+		return nil, err
+	}
+
+	return setting, nil
+}
+
+func (m *auditModel) GetSetting(ctx context.Context, op *entity.Operator) (*entity.AuditSettingView, error) {
+	if err := m.checkPermission(ctx, op, external.ViewAuditLogs10902); err != nil {
+		return nil, err
+	}
+
+	setting, err := m.getSetting(ctx, op.OrgID)
+	if err == constant.ErrRecordNotFound {
+		return &entity.AuditSettingView{
+			RetentionDays: config.Get().Audit.DefaultRetentionDays,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.AuditSettingView{
+		RetentionDays: setting.RetentionDays,
+		UpdateAt:      setting.UpdateAt,
+	}, nil
+}
+
+func (m *auditModel) UpdateSetting(ctx context.Context, op *entity.Operator, req *entity.AuditSettingUpdateReq) error {
+	if !req.Valid() {
+		log.Warn(ctx, "audit setting update request invalid", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op, external.ManageAuditSettings10903); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	setting, err := m.getSetting(ctx, op.OrgID)
+	if err == constant.ErrRecordNotFound {
+		setting = &entity.AuditSetting{
+			OrgID:         op.OrgID,
+			RetentionDays: req.RetentionDays,
+			UpdaterID:     op.UserID,
+			CreateAt:      now,
+			UpdateAt:      now,
+		}
+		if _, err := da.GetAuditSettingDA().Insert(ctx, setting); err != nil {
+			log.Error(ctx, "insert audit setting failed", log.Err(err), log.Any("setting", setting))
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	setting.RetentionDays = req.RetentionDays
+	setting.UpdaterID = op.UserID
+	setting.UpdateAt = now
+	if _, err := da.GetAuditSettingDA().Update(ctx, setting); err != nil {
+		log.Error(ctx, "update audit setting failed", log.Err(err), log.Any("setting", setting))
+		return err
+	}
+
+	return nil
+}
+
+func (m *auditModel) Purge(ctx context.Context) (int64, error) {
+	var settings []*entity.AuditSetting
+	if err := da.GetAuditSettingDA().Query(ctx, &da.AuditSettingCondition{}, &settings); err != nil {
+		log.Error(ctx, "query audit settings failed", log.Err(err))
+		return 0, err
+	}
+
+	now := time.Now()
+	var total int64
+	orgIDs := make([]string, 0, len(settings))
+	for _, setting := range settings {
+		orgIDs = append(orgIDs, setting.OrgID)
+
+		before := now.AddDate(0, 0, -setting.RetentionDays).Unix()
+		count, err := m.purgeBatches(func() (int64, error) {
+			return da.GetAuditLogDA().Purge(ctx, setting.OrgID, before, auditPurgeBatchSize)
+		})
+		total += count
+		if err != nil {
+			return total, err
+		}
+	}
+
+	before := now.AddDate(0, 0, -config.Get().Audit.DefaultRetentionDays).Unix()
+	count, err := m.purgeBatches(func() (int64, error) {
+		return da.GetAuditLogDA().PurgeOthers(ctx, orgIDs, before, auditPurgeBatchSize)
+	})
+	total += count
+	if err != nil {
+		return total, err
+	}
+
+	return total, nil
+}
+
+// purgeBatches small batches keep the table from being locked for long
+func (m *auditModel) purgeBatches(purge func() (int64, error)) (int64, error) {
+	var total int64
+	for {
+		count, err := purge()
+		if err != nil {
+			return total, err
+		}
+		total += count
+		if count < auditPurgeBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -668,6 +668,8 @@ func (cm *ContentModel) doPublishContent(ctx context.Context, tx *dbo.DBContext,
 				return err
 			}
 		}
+
+		GetWebhookModel().EmitTx(ctx, tx, user, entity.WebhookEventContentPublished, newWebhookContentData(content))
 	}
 
 	//If scope changed, refresh visibility settings
@@ -960,6 +962,10 @@ func (cm *ContentModel) UpdateContent(ctx context.Context, tx *dbo.DBContext, ci
 	if err != nil {
 		return err
 	}
+	err = increaseRevisionTx(ctx, tx, entity.Content{}.TableName(), cid)
+	if err != nil {
+		return err
+	}
 	content, err := da.GetContentDA().GetContentByID(ctx, tx, cid)
 	if err == dbo.ErrRecordNotFound {
 		log.Error(ctx, "record not found", log.Err(err), log.String("cid", cid), log.String("uid", user.UserID))
@@ -1092,6 +1098,8 @@ func (cm *ContentModel) UpdateContentPublishStatus(ctx context.Context, tx *dbo.
 				return err
 			}
 		}
+
+		GetWebhookModel().EmitTx(ctx, tx, operator, entity.WebhookEventContentPublished, newWebhookContentData(content))
 	}
 
 	da.GetContentRedis().CleanContentCache(ctx, []string{cid, content.SourceID})
@@ -1726,6 +1734,8 @@ func (cm *ContentModel) doDeleteContent(ctx context.Context, tx *dbo.DBContext,
 			return err
 		}
 	}
+
+	GetWebhookModel().EmitTx(ctx, tx, user, entity.WebhookEventContentDeleted, newWebhookContentData(obj))
 	return nil
 }
 func (cm *ContentModel) DeleteContentTx(ctx context.Context, cid string, user *entity.Operator) error {
@@ -1738,6 +1748,10 @@ func (cm *ContentModel) DeleteContentTx(ctx context.Context, cid string, user *e
 	})
 }
 func (cm *ContentModel) DeleteContent(ctx context.Context, tx *dbo.DBContext, cid string, user *entity.Operator) error {
+	err := increaseRevisionTx(ctx, tx, entity.Content{}.TableName(), cid)
+	if err != nil {
+		return err
+	}
 	content, err := da.GetContentDA().GetContentByID(ctx, tx, cid)
 	if err == dbo.ErrRecordNotFound {
 		log.Error(ctx, "content not found", log.Err(err), log.String("cid", cid), log.String("uid", user.UserID))
@@ -1747,6 +1761,7 @@ func (cm *ContentModel) DeleteContent(ctx context.Context, tx *dbo.DBContext, ci
 		log.Error(ctx, "can't read content on delete content", log.Err(err), log.String("cid", cid), log.String("uid", user.UserID))
 		return err
 	}
+	AuditBefore(ctx, content.ID, content)
 
 	err = cm.doDeleteContent(ctx, tx, content, user)
 	if err != nil {
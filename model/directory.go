@@ -0,0 +1,231 @@
+package model
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+const directoryImportBatchSize = 500
+
+type IDirectoryModel interface {
+	// Upsert rows of the type, entities are replaced by id and existing relations are kept
+	Upsert(ctx context.Context, directoryType entity.DirectoryType, records []map[string]string) (*entity.DirectoryImportResult, error)
+	// Import a csv file of the type, the header names the columns
+	Import(ctx context.Context, directoryType entity.DirectoryType, reader io.Reader) (*entity.DirectoryImportResult, error)
+	// Delete relations are deleted by all of their columns, entities are deactivated by id so references to them stay valid
+	Delete(ctx context.Context, directoryType entity.DirectoryType, records []map[string]string) (int64, error)
+}
+
+type directoryModel struct{}
+
+var (
+	_directoryModel     IDirectoryModel
+	_directoryModelOnce sync.Once
+)
+
+func GetDirectoryModel() IDirectoryModel {
+	_directoryModelOnce.Do(func() {
+		_directoryModel = &directoryModel{}
+	})
+	return _directoryModel
+}
+
+func (m *directoryModel) parseRecords(ctx context.Context, directoryType entity.DirectoryType, records []map[string]string) ([]interface{}, error) {
+	if !directoryType.Valid() {
+		log.Warn(ctx, "invalid directory type", log.String("type", string(directoryType)))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	rows := make([]interface{}, len(records))
+	for i, record := range records {
+		row, err := entity.ParseDirectoryRecord(directoryType, record)
+		if err != nil {
+			log.Warn(ctx, "invalid directory record", log.Err(err), log.Int("index", i), log.Any("record", record))
+			return nil, constant.ErrInvalidArgs
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+func (m *directoryModel) Upsert(ctx context.Context, directoryType entity.DirectoryType, records []map[string]string) (*entity.DirectoryImportResult, error) {
+	rows, err := m.parseRecords(ctx, directoryType, records)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		for start := 0; start < len(rows); start += directoryImportBatchSize {
+			end := start + directoryImportBatchSize
+			if end > len(rows) {
+				end = len(rows)
+			}
+
+			var err error
+			if directoryType.IsRelation() {
+				err = da.GetDirectoryDA().InsertRelationsTx(ctx, tx, directoryType, rows[start:end])
+			} else {
+				err = da.GetDirectoryDA().UpsertTx(ctx, tx, rows[start:end])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(ctx, "upsert directory failed", log.Err(err), log.String("type", string(directoryType)), log.Int("rows", len(rows)))
+		return nil, err
+	}
+	invalidateDirectoryObjects(ctx, directoryType, m.rowIDs(rows))
+
+	return &entity.DirectoryImportResult{Type: directoryType, Rows: len(rows)}, nil
+}
+
+func (m *directoryModel) Import(ctx context.Context, directoryType entity.DirectoryType, reader io.Reader) (*entity.DirectoryImportResult, error) {
+	if !directoryType.Valid() {
+		log.Warn(ctx, "invalid directory type", log.String("type", string(directoryType)))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	header, err := csvReader.Read()
+	if err != nil {
+		log.Warn(ctx, "read directory csv header failed", log.Err(err), log.String("type", string(directoryType)))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	columns := make(map[string]bool)
+	for _, column := range entity.DirectoryColumns(directoryType) {
+		columns[column] = true
+	}
+	for i := range header {
+		// excel saves csv files with a byte order mark
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+		if !columns[header[i]] {
+			log.Warn(ctx, "unknown directory csv column", log.String("type", string(directoryType)), log.String("column", header[i]))
+			return nil, constant.ErrInvalidArgs
+		}
+	}
+
+	var records []map[string]string
+	for {
+		line, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Warn(ctx, "read directory csv failed", log.Err(err), log.String("type", string(directoryType)), log.Int("line", len(records)+2))
+			return nil, constant.ErrInvalidArgs
+		}
+
+		record := make(map[string]string, len(header))
+		for i, column := range header {
+			record[column] = line[i]
+		}
+		records = append(records, record)
+	}
+
+	return m.Upsert(ctx, directoryType, records)
+}
+
+func (m *directoryModel) Delete(ctx context.Context, directoryType entity.DirectoryType, records []map[string]string) (int64, error) {
+	if !directoryType.Valid() {
+		log.Warn(ctx, "invalid directory type", log.String("type", string(directoryType)))
+		return 0, constant.ErrInvalidArgs
+	}
+
+	var ids []string
+	if !directoryType.IsRelation() {
+		ids = make([]string, 0, len(records))
+		for _, record := range records {
+			if id := strings.TrimSpace(record["id"]); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	var deleted int64
+	err := dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		if !directoryType.IsRelation() {
+			var err error
+			deleted, err = da.GetDirectoryDA().DeactivateTx(ctx, tx, directoryType, ids)
+			return err
+		}
+
+		rows, err := m.parseRecords(ctx, directoryType, records)
+		if err != nil {
+			return err
+		}
+		deleted, err = da.GetDirectoryDA().DeleteRelationsTx(ctx, tx, directoryType, rows)
+		return err
+	})
+	if err != nil {
+		log.Error(ctx, "delete directory rows failed", log.Err(err), log.String("type", string(directoryType)), log.Any("records", records))
+		return 0, err
+	}
+	invalidateDirectoryObjects(ctx, directoryType, ids)
+	return deleted, nil
+}
+
+// rowIDs ids of the cached rows
+func (m *directoryModel) rowIDs(rows []interface{}) []string {
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		switch v := row.(type) {
+		case *entity.DirectoryUser:
+			ids = append(ids, v.ID)
+		case *entity.DirectoryOrganization:
+			ids = append(ids, v.ID)
+		case *entity.DirectorySchool:
+			ids = append(ids, v.ID)
+		case *entity.DirectoryClass:
+			ids = append(ids, v.ID)
+		}
+	}
+	return ids
+}
+
+// SeedDirectory import <type>.csv files in dir into the local directory, entities before the relations of them.
+// Seeding is idempotent, it runs on every startup.
+func SeedDirectory(ctx context.Context, dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	for _, directoryType := range entity.DirectoryTypes {
+		path := filepath.Join(dir, fmt.Sprintf("%s.csv", directoryType))
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			log.Error(ctx, "open directory seed failed", log.Err(err), log.String("path", path))
+			return err
+		}
+
+		result, err := GetDirectoryModel().Import(ctx, directoryType, file)
+		file.Close()
+		if err != nil {
+			log.Error(ctx, "import directory seed failed", log.Err(err), log.String("path", path))
+			return err
+		}
+		log.Info(ctx, "directory seed imported", log.String("path", path), log.Int("rows", result.Rows))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"context"
+
+	"github.com/KL-Engineering/common-log/log"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils/kl2cache"
+)
+
+func directoryCacheKey(directoryType entity.DirectoryType, id string) kl2cache.Key {
+	return kl2cache.KeyByStrings{"kl2cache", "directory", string(directoryType), id}
+}
+
+// getDirectoryObjects users, organizations, schools and classes are looked up by id in kl2cache,
+// the writes of the directory invalidate them on all instances
+func getDirectoryObjects[T any](ctx context.Context, directoryType entity.DirectoryType, ids []string, idOf func(T) string, fetch func(ctx context.Context, ids []string) ([]T, error)) ([]T, error) {
+	ids = utils.SliceDeduplicationExcludeEmpty(ids)
+	if len(ids) == 0 {
+		return []T{}, nil
+	}
+
+	keys := make([]kl2cache.Key, len(ids))
+	for i, id := range ids {
+		keys[i] = directoryCacheKey(directoryType, id)
+	}
+
+	var result []T
+	provider := kl2cache.DefaultProvider.WithExpireStrategy(ctx, kl2cache.ExpireStrategyFixed(constant.DirectoryCacheExpiration))
+	err := provider.BatchGet(ctx, keys, &result, func(ctx context.Context, missedKeys []kl2cache.Key) ([]*kl2cache.KeyValue, error) {
+		missedIDs := make([]string, len(missedKeys))
+		for i, key := range missedKeys {
+			missedIDs[i] = key.(kl2cache.KeyByStrings)[3]
+		}
+
+		objects, err := fetch(ctx, missedIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		kvs := make([]*kl2cache.KeyValue, len(objects))
+		for i, object := range objects {
+			kvs[i] = &kl2cache.KeyValue{Key: directoryCacheKey(directoryType, idOf(object)), Val: object}
+		}
+		return kvs, nil
+	})
+	if err != nil {
+		log.Error(ctx, "get directory objects failed", log.Err(err), log.String("type", string(directoryType)), log.Strings("ids", ids))
+		return nil, err
+	}
+	return result, nil
+}
+
+func getDirectoryUsers(ctx context.Context, ids []string) ([]*entity.DirectoryUser, error) {
+	return getDirectoryObjects(ctx, entity.DirectoryTypeUser, ids,
+		func(user *entity.DirectoryUser) string { return user.ID }, da.GetDirectoryDA().GetUsers)
+}
+
+func getDirectoryOrganizations(ctx context.Context, ids []string) ([]*entity.DirectoryOrganization, error) {
+	return getDirectoryObjects(ctx, entity.DirectoryTypeOrganization, ids,
+		func(organization *entity.DirectoryOrganization) string { return organization.ID }, da.GetDirectoryDA().GetOrganizations)
+}
+
+func getDirectorySchools(ctx context.Context, ids []string) ([]*entity.DirectorySchool, error) {
+	return getDirectoryObjects(ctx, entity.DirectoryTypeSchool, ids,
+		func(school *entity.DirectorySchool) string { return school.ID }, da.GetDirectoryDA().GetSchools)
+}
+
+func getDirectoryClasses(ctx context.Context, ids []string) ([]*entity.DirectoryClass, error) {
+	return getDirectoryObjects(ctx, entity.DirectoryTypeClass, ids,
+		func(class *entity.DirectoryClass) string { return class.ID }, da.GetDirectoryDA().GetClasses)
+}
+
+// invalidateDirectoryObjects call it after the rows are committed,
+// a failed invalidation is bounded by constant.DirectoryCacheExpiration
+func invalidateDirectoryObjects(ctx context.Context, directoryType entity.DirectoryType, ids []string) {
+	if !directoryType.Cached() || len(ids) == 0 {
+		return
+	}
+
+	keys := make([]kl2cache.Key, len(ids))
+	for i, id := range ids {
+		keys[i] = directoryCacheKey(directoryType, id)
+	}
+	if err := kl2cache.DefaultProvider.Invalidate(ctx, keys...); err != nil {
+		log.Error(ctx, "invalidate directory objects failed", log.Err(err), log.String("type", string(directoryType)), log.Strings("ids", ids))
+	}
+}
@@ -0,0 +1,1460 @@
+package model
+
+import (
+	"context"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+	"github.com/KL-Engineering/kidsloop-cache/cache"
+	"github.com/google/uuid"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+// localDirectory providers backed by the directory tables, they behave like the ams connection services
+type localDirectory struct {
+	users         localUserProvider
+	teachers      localTeacherProvider
+	students      localStudentProvider
+	organizations localOrganizationProvider
+	schools       localSchoolProvider
+	classes       localClassProvider
+	roles         localRoleProvider
+	permissions   localPermissionProvider
+	programs      localProgramProvider
+	subjects      localSubjectProvider
+	ages          localAgeProvider
+	grades        localGradeProvider
+	categories    localCategoryProvider
+	subCategories localSubCategoryProvider
+}
+
+func (d *localDirectory) Users() external.UserServiceProvider                 { return d.users }
+func (d *localDirectory) Teachers() external.TeacherServiceProvider           { return d.teachers }
+func (d *localDirectory) Students() external.StudentServiceProvider           { return d.students }
+func (d *localDirectory) Organizations() external.OrganizationServiceProvider { return d.organizations }
+func (d *localDirectory) Schools() external.SchoolServiceProvider             { return d.schools }
+func (d *localDirectory) Classes() external.ClassServiceProvider              { return d.classes }
+func (d *localDirectory) Roles() external.RoleServiceProvider                 { return d.roles }
+func (d *localDirectory) Permissions() external.PermissionServiceProvider     { return d.permissions }
+func (d *localDirectory) Programs() external.ProgramServiceProvider           { return d.programs }
+func (d *localDirectory) Subjects() external.SubjectServiceProvider           { return d.subjects }
+func (d *localDirectory) Ages() external.AgeServiceProvider                   { return d.ages }
+func (d *localDirectory) Grades() external.GradeServiceProvider               { return d.grades }
+func (d *localDirectory) Categories() external.CategoryServiceProvider        { return d.categories }
+func (d *localDirectory) SubCategories() external.SubCategoryServiceProvider  { return d.subCategories }
+
+var (
+	_localDirectory     external.DirectoryProvider
+	_localDirectoryOnce sync.Once
+)
+
+func GetLocalDirectory() external.DirectoryProvider {
+	_localDirectoryOnce.Do(func() {
+		_localDirectory = &localDirectory{}
+	})
+	return _localDirectory
+}
+
+// directoryStatusMatches only active rows match when the status option is absent, the same as ams
+func directoryStatusMatches(condition *external.APCondition, status string) bool {
+	if !condition.Status.Valid {
+		return status == entity.DirectoryStatusActive
+	}
+	return condition.Status.Status == external.Ignore || condition.Status.Status.String() == status
+}
+
+func directorySystemMatches(condition *external.APCondition, system bool) bool {
+	return !condition.System.Valid || condition.System.Bool == system
+}
+
+type localUserProvider struct{}
+
+func (p localUserProvider) toUser(user *entity.DirectoryUser) *external.User {
+	return &external.User{
+		ID:         user.ID,
+		GivenName:  user.GivenName,
+		FamilyName: user.FamilyName,
+		Email:      user.Email,
+		Avatar:     user.Avatar,
+	}
+}
+
+func (p localUserProvider) toUsers(users []*entity.DirectoryUser) []*external.User {
+	result := make([]*external.User, len(users))
+	for i, user := range users {
+		result[i] = p.toUser(user)
+	}
+	return result
+}
+
+func (p localUserProvider) Name() string {
+	return "local_directory_user"
+}
+
+func (p localUserProvider) QueryByIDs(ctx context.Context, ids []string, options ...interface{}) ([]cache.Object, error) {
+	users, err := getDirectoryUsers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]cache.Object, len(users))
+	for i, user := range users {
+		result[i] = &external.NullableUser{Valid: true, StrID: user.ID, User: p.toUser(user)}
+	}
+	return result, nil
+}
+
+func (p localUserProvider) Get(ctx context.Context, operator *entity.Operator, id string) (*external.User, error) {
+	users, err := p.BatchGet(ctx, operator, []string{id})
+	if err != nil {
+		return nil, err
+	}
+
+	if users[0].User == nil || !users[0].Valid {
+		return nil, constant.ErrRecordNotFound
+	}
+
+	return users[0].User, nil
+}
+
+func (p localUserProvider) BatchGet(ctx context.Context, operator *entity.Operator, ids []string) ([]*external.NullableUser, error) {
+	users, err := getDirectoryUsers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	userMap := make(map[string]*entity.DirectoryUser, len(users))
+	for _, user := range users {
+		userMap[user.ID] = user
+	}
+	result := make([]*external.NullableUser, len(ids))
+	for i, id := range ids {
+		user, ok := userMap[id]
+		if !ok {
+			result[i] = &external.NullableUser{Valid: false, StrID: id}
+			continue
+		}
+		result[i] = &external.NullableUser{Valid: true, StrID: id, User: p.toUser(user)}
+	}
+	return result, nil
+}
+
+func (p localUserProvider) BatchGetMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]*external.NullableUser, error) {
+	users, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]*external.NullableUser{}, err
+	}
+
+	dict := make(map[string]*external.NullableUser, len(users))
+	for _, user := range users {
+		if user.User == nil || !user.Valid {
+			continue
+		}
+		dict[user.ID] = user
+	}
+	return dict, nil
+}
+
+func (p localUserProvider) BatchGetNameMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	users, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	dict := make(map[string]string, len(users))
+	for _, user := range users {
+		if user.User == nil || !user.Valid {
+			continue
+		}
+		dict[user.ID] = user.Name()
+	}
+	return dict, nil
+}
+
+func (p localUserProvider) Query(ctx context.Context, operator *entity.Operator, organizationID, keyword string) ([]*external.User, error) {
+	users, err := da.GetDirectoryDA().GetMembers(ctx, organizationID, keyword, false)
+	if err != nil {
+		return nil, err
+	}
+	return p.toUsers(users), nil
+}
+
+func (p localUserProvider) GetByOrganization(ctx context.Context, operator *entity.Operator, organizationID string) ([]*external.User, error) {
+	users, err := da.GetDirectoryDA().GetMembers(ctx, organizationID, "", false)
+	if err != nil {
+		return nil, err
+	}
+	return p.toUsers(users), nil
+}
+
+// NewUser the existing user is returned if the email is taken
+func (p localUserProvider) NewUser(ctx context.Context, operator *entity.Operator, email string) (string, error) {
+	users, err := da.GetDirectoryDA().GetUsersByEmail(ctx, email)
+	if err != nil {
+		return "", err
+	}
+	if len(users) > 0 {
+		return users[0].ID, nil
+	}
+
+	user := &entity.DirectoryUser{
+		ID:     uuid.New().String(),
+		Email:  email,
+		Status: entity.DirectoryStatusActive,
+	}
+	err = da.GetDirectoryDA().UpsertTx(ctx, dbo.MustGetDB(ctx), []interface{}{user})
+	if err != nil {
+		log.Error(ctx, "create directory user failed", log.Err(err), log.String("email", email))
+		return "", err
+	}
+	return user.ID, nil
+}
+
+func (p localUserProvider) GetOnlyUnderOrgUsers(ctx context.Context, op *entity.Operator, orgID string) ([]*external.User, error) {
+	users, err := da.GetDirectoryDA().GetMembers(ctx, op.OrgID, "", true)
+	if err != nil {
+		return nil, err
+	}
+	return p.toUsers(users), nil
+}
+
+func (p localUserProvider) GetUserCount(ctx context.Context, op *entity.Operator, cond *entity.GetUserCountCondition) (int, error) {
+	return da.GetDirectoryDA().CountMembers(ctx, cond)
+}
+
+type localOrganizationProvider struct{}
+
+func (p localOrganizationProvider) toOrganization(organization *entity.DirectoryOrganization) *external.Organization {
+	return &external.Organization{
+		ID:     organization.ID,
+		Name:   organization.Name,
+		Status: external.APStatus(organization.Status),
+	}
+}
+
+func (p localOrganizationProvider) Name() string {
+	return "local_directory_organization"
+}
+
+func (p localOrganizationProvider) QueryByIDs(ctx context.Context, ids []string, options ...interface{}) ([]cache.Object, error) {
+	organizations, err := getDirectoryOrganizations(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]cache.Object, len(organizations))
+	for i, organization := range organizations {
+		result[i] = &external.NullableOrganization{Valid: true, StrID: organization.ID, Organization: *p.toOrganization(organization)}
+	}
+	return result, nil
+}
+
+func (p localOrganizationProvider) BatchGet(ctx context.Context, operator *entity.Operator, ids []string) ([]*external.NullableOrganization, error) {
+	organizations, err := getDirectoryOrganizations(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	organizationMap := make(map[string]*entity.DirectoryOrganization, len(organizations))
+	for _, organization := range organizations {
+		organizationMap[organization.ID] = organization
+	}
+	result := make([]*external.NullableOrganization, len(ids))
+	for i, id := range ids {
+		organization, ok := organizationMap[id]
+		if !ok {
+			result[i] = &external.NullableOrganization{Valid: false, StrID: id}
+			continue
+		}
+		result[i] = &external.NullableOrganization{Valid: true, StrID: id, Organization: *p.toOrganization(organization)}
+	}
+	return result, nil
+}
+
+func (p localOrganizationProvider) BatchGetMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]*external.NullableOrganization, error) {
+	organizations, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]*external.NullableOrganization{}, err
+	}
+
+	dict := make(map[string]*external.NullableOrganization, len(organizations))
+	for _, organization := range organizations {
+		if !organization.Valid {
+			continue
+		}
+		dict[organization.ID] = organization
+	}
+	return dict, nil
+}
+
+func (p localOrganizationProvider) BatchGetNameMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	organizations, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	dict := make(map[string]string, len(organizations))
+	for _, organization := range organizations {
+		if !organization.Valid {
+			continue
+		}
+		dict[organization.ID] = organization.Name
+	}
+	return dict, nil
+}
+
+func (p localOrganizationProvider) GetByClasses(ctx context.Context, operator *entity.Operator, classIDs []string, options ...external.APOption) (map[string]*external.Organization, error) {
+	classes, err := getDirectoryClasses(ctx, classIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	orgIDs := make([]string, len(classes))
+	for i, class := range classes {
+		orgIDs[i] = class.OrgID
+	}
+	organizations, err := getDirectoryOrganizations(ctx, orgIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	condition := external.NewCondition(options...)
+	organizationMap := make(map[string]*external.Organization, len(organizations))
+	for _, organization := range organizations {
+		if directoryStatusMatches(condition, organization.Status) {
+			organizationMap[organization.ID] = p.toOrganization(organization)
+		}
+	}
+	result := make(map[string]*external.Organization, len(classes))
+	for _, class := range classes {
+		if organization, ok := organizationMap[class.OrgID]; ok {
+			result[class.ID] = organization
+		}
+	}
+	return result, nil
+}
+
+func (p localOrganizationProvider) GetNameByOrganizationOrSchool(ctx context.Context, operator *entity.Operator, ids []string) ([]string, error) {
+	nameMap, err := p.GetNameMapByOrganizationOrSchool(ctx, operator, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = nameMap[id]
+	}
+	return names, nil
+}
+
+func (p localOrganizationProvider) GetNameMapByOrganizationOrSchool(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	ids = utils.SliceDeduplicationExcludeEmpty(ids)
+	organizations, err := getDirectoryOrganizations(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	schools, err := getDirectorySchools(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	nameMap := make(map[string]string, len(organizations)+len(schools))
+	for _, organization := range organizations {
+		nameMap[organization.ID] = organization.Name
+	}
+	for _, school := range schools {
+		nameMap[school.ID] = school.Name
+	}
+	return nameMap, nil
+}
+
+func (p localOrganizationProvider) GetByPermission(ctx context.Context, operator *entity.Operator, permissionName external.PermissionName, options ...external.APOption) ([]*external.Organization, error) {
+	permissions, err := da.GetDirectoryDA().GetMemberPermissions(ctx, operator.UserID, nil, []string{permissionName.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	orgIDs := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		if permission.SchoolID == "" {
+			orgIDs = append(orgIDs, permission.OrgID)
+		}
+	}
+	organizations, err := getDirectoryOrganizations(ctx, orgIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	condition := external.NewCondition(options...)
+	result := make([]*external.Organization, 0, len(organizations))
+	for _, organization := range organizations {
+		if directoryStatusMatches(condition, organization.Status) {
+			result = append(result, p.toOrganization(organization))
+		}
+	}
+	return result, nil
+}
+
+type localSchoolProvider struct{}
+
+func (p localSchoolProvider) toSchool(school *entity.DirectorySchool) *external.School {
+	return &external.School{
+		ID:             school.ID,
+		Name:           school.Name,
+		Status:         external.APStatus(school.Status),
+		OrganizationId: school.OrgID,
+	}
+}
+
+func (p localSchoolProvider) toSchools(schools []*entity.DirectorySchool, options ...external.APOption) []*external.School {
+	condition := external.NewCondition(options...)
+	result := make([]*external.School, 0, len(schools))
+	for _, school := range schools {
+		if directoryStatusMatches(condition, school.Status) {
+			result = append(result, p.toSchool(school))
+		}
+	}
+	return result
+}
+
+func (p localSchoolProvider) groupSchools(schools []*entity.DirectoryRelatedSchool, options ...external.APOption) map[string][]*external.School {
+	condition := external.NewCondition(options...)
+	result := make(map[string][]*external.School)
+	for _, school := range schools {
+		if directoryStatusMatches(condition, school.Status) {
+			result[school.RelatedID] = append(result[school.RelatedID], p.toSchool(&school.DirectorySchool))
+		}
+	}
+	return result
+}
+
+func (p localSchoolProvider) Name() string {
+	return "local_directory_school"
+}
+
+func (p localSchoolProvider) QueryByIDs(ctx context.Context, ids []string, options ...interface{}) ([]cache.Object, error) {
+	schools, err := getDirectorySchools(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]cache.Object, len(schools))
+	for i, school := range schools {
+		result[i] = &external.NullableSchool{Valid: true, StrID: school.ID, School: p.toSchool(school)}
+	}
+	return result, nil
+}
+
+func (p localSchoolProvider) BatchGet(ctx context.Context, operator *entity.Operator, ids []string) ([]*external.NullableSchool, error) {
+	schools, err := getDirectorySchools(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	schoolMap := make(map[string]*entity.DirectorySchool, len(schools))
+	for _, school := range schools {
+		schoolMap[school.ID] = school
+	}
+	result := make([]*external.NullableSchool, len(ids))
+	for i, id := range ids {
+		school, ok := schoolMap[id]
+		if !ok {
+			result[i] = &external.NullableSchool{Valid: false, StrID: id}
+			continue
+		}
+		result[i] = &external.NullableSchool{Valid: true, StrID: id, School: p.toSchool(school)}
+	}
+	return result, nil
+}
+
+func (p localSchoolProvider) BatchGetMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]*external.NullableSchool, error) {
+	schools, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]*external.NullableSchool{}, err
+	}
+
+	dict := make(map[string]*external.NullableSchool, len(schools))
+	for _, school := range schools {
+		if school.School == nil || !school.Valid {
+			continue
+		}
+		dict[school.ID] = school
+	}
+	return dict, nil
+}
+
+func (p localSchoolProvider) BatchGetNameMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	schools, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	dict := make(map[string]string, len(schools))
+	for _, school := range schools {
+		if school.School == nil || !school.Valid {
+			continue
+		}
+		dict[school.ID] = school.Name
+	}
+	return dict, nil
+}
+
+func (p localSchoolProvider) GetByClasses(ctx context.Context, operator *entity.Operator, classIDs []string, options ...external.APOption) (map[string][]*external.School, error) {
+	schools, err := da.GetDirectoryDA().GetSchoolsByClasses(ctx, utils.SliceDeduplicationExcludeEmpty(classIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	result := p.groupSchools(schools, options...)
+	// classes without a school are kept, callers tell classes under the organization by them
+	for _, classID := range classIDs {
+		if _, ok := result[classID]; !ok {
+			result[classID] = []*external.School{}
+		}
+	}
+	return result, nil
+}
+
+func (p localSchoolProvider) GetByOrganizationID(ctx context.Context, operator *entity.Operator, organizationID string, options ...external.APOption) ([]*external.School, error) {
+	schools, err := da.GetDirectoryDA().GetSchoolsByOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return p.toSchools(schools, options...), nil
+}
+
+// GetByPermission schools of the operator if the permission is granted in the organization, the same as ams
+func (p localSchoolProvider) GetByPermission(ctx context.Context, operator *entity.Operator, permissionName external.PermissionName, options ...external.APOption) ([]*external.School, error) {
+	hasPermission, err := localPermissionProvider{}.HasOrganizationPermission(ctx, operator, permissionName)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return []*external.School{}, nil
+	}
+	return p.GetByOperator(ctx, operator, options...)
+}
+
+func (p localSchoolProvider) GetByOperator(ctx context.Context, operator *entity.Operator, options ...external.APOption) ([]*external.School, error) {
+	schools, err := p.GetByUsers(ctx, operator, operator.OrgID, []string{operator.UserID}, options...)
+	if err != nil {
+		return nil, err
+	}
+	return schools[operator.UserID], nil
+}
+
+func (p localSchoolProvider) GetByUsers(ctx context.Context, operator *entity.Operator, orgID string, userIDs []string, options ...external.APOption) (map[string][]*external.School, error) {
+	schools, err := da.GetDirectoryDA().GetSchoolsByUsers(ctx, orgID, utils.SliceDeduplicationExcludeEmpty(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	return p.groupSchools(schools, options...), nil
+}
+
+type localClassProvider struct{}
+
+func (p localClassProvider) toClass(class *entity.DirectoryClass, joinType string) *external.Class {
+	return &external.Class{
+		ID:       class.ID,
+		Name:     class.Name,
+		Status:   external.APStatus(class.Status),
+		JoinType: external.JoinType(joinType),
+	}
+}
+
+func (p localClassProvider) groupClasses(classes []*entity.DirectoryRelatedClass, options ...external.APOption) map[string][]*external.Class {
+	condition := external.NewCondition(options...)
+	result := make(map[string][]*external.Class)
+	for _, class := range classes {
+		if directoryStatusMatches(condition, class.Status) {
+			result[class.RelatedID] = append(result[class.RelatedID], p.toClass(&class.DirectoryClass, ""))
+		}
+	}
+	return result
+}
+
+func (p localClassProvider) Name() string {
+	return "local_directory_class"
+}
+
+func (p localClassProvider) QueryByIDs(ctx context.Context, ids []string, options ...interface{}) ([]cache.Object, error) {
+	classes, err := getDirectoryClasses(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]cache.Object, len(classes))
+	for i, class := range classes {
+		result[i] = &external.NullableClass{Valid: true, StrID: class.ID, Class: *p.toClass(class, "")}
+	}
+	return result, nil
+}
+
+func (p localClassProvider) BatchGet(ctx context.Context, operator *entity.Operator, ids []string) ([]*external.NullableClass, error) {
+	classes, err := getDirectoryClasses(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	classMap := make(map[string]*entity.DirectoryClass, len(classes))
+	for _, class := range classes {
+		classMap[class.ID] = class
+	}
+	result := make([]*external.NullableClass, len(ids))
+	for i, id := range ids {
+		class, ok := classMap[id]
+		if !ok {
+			result[i] = &external.NullableClass{Valid: false, StrID: id}
+			continue
+		}
+		result[i] = &external.NullableClass{Valid: true, StrID: id, Class: *p.toClass(class, "")}
+	}
+	return result, nil
+}
+
+func (p localClassProvider) BatchGetMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]*external.NullableClass, error) {
+	classes, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]*external.NullableClass{}, err
+	}
+
+	dict := make(map[string]*external.NullableClass, len(classes))
+	for _, class := range classes {
+		if !class.Valid {
+			continue
+		}
+		dict[class.ID] = class
+	}
+	return dict, nil
+}
+
+func (p localClassProvider) BatchGetNameMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	classes, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	dict := make(map[string]string, len(classes))
+	for _, class := range classes {
+		if !class.Valid {
+			continue
+		}
+		dict[class.ID] = class.Name
+	}
+	return dict, nil
+}
+
+func (p localClassProvider) GetByUserID(ctx context.Context, operator *entity.Operator, userID string, options ...external.APOption) ([]*external.Class, error) {
+	classes, err := p.GetByUserIDs(ctx, operator, []string{userID}, options...)
+	if err != nil {
+		return nil, err
+	}
+	return classes[userID], nil
+}
+
+func (p localClassProvider) GetByUserIDs(ctx context.Context, operator *entity.Operator, userIDs []string, options ...external.APOption) (map[string][]*external.Class, error) {
+	classes, err := da.GetDirectoryDA().GetClassesByUsers(ctx, utils.SliceDeduplicationExcludeEmpty(userIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	condition := external.NewCondition(options...)
+	result := make(map[string][]*external.Class, len(userIDs))
+	for _, class := range classes {
+		if directoryStatusMatches(condition, class.Status) {
+			result[class.UserID] = append(result[class.UserID], p.toClass(&class.DirectoryClass, class.JoinType))
+		}
+	}
+	return result, nil
+}
+
+func (p localClassProvider) GetByOrganizationIDs(ctx context.Context, operator *entity.Operator, orgIDs []string, options ...external.APOption) (map[string][]*external.Class, error) {
+	classes, err := da.GetDirectoryDA().GetClassesByOrganizations(ctx, utils.SliceDeduplicationExcludeEmpty(orgIDs))
+	if err != nil {
+		return nil, err
+	}
+	return p.groupClasses(classes, options...), nil
+}
+
+func (p localClassProvider) GetBySchoolIDs(ctx context.Context, operator *entity.Operator, schoolIDs []string, options ...external.APOption) (map[string][]*external.Class, error) {
+	classes, err := da.GetDirectoryDA().GetClassesBySchools(ctx, utils.SliceDeduplicationExcludeEmpty(schoolIDs))
+	if err != nil {
+		return nil, err
+	}
+	return p.groupClasses(classes, options...), nil
+}
+
+func (p localClassProvider) GetOnlyUnderOrgClasses(ctx context.Context, operator *entity.Operator, orgID string) ([]*external.NullableClass, error) {
+	orgClasses, err := da.GetDirectoryDA().GetClassesByOrganizations(ctx, []string{orgID})
+	if err != nil {
+		return nil, err
+	}
+	if len(orgClasses) == 0 {
+		log.Info(ctx, "no classes under the organization", log.Any("op", operator))
+		return nil, constant.ErrRecordNotFound
+	}
+
+	classIDs := make([]string, len(orgClasses))
+	for i, class := range orgClasses {
+		classIDs[i] = class.ID
+	}
+	schools, err := da.GetDirectoryDA().GetSchoolsByClasses(ctx, classIDs)
+	if err != nil {
+		return nil, err
+	}
+	inSchool := make(map[string]bool, len(schools))
+	for _, school := range schools {
+		inSchool[school.RelatedID] = true
+	}
+
+	result := make([]*external.NullableClass, 0, len(orgClasses))
+	for _, class := range orgClasses {
+		if !inSchool[class.ID] {
+			result = append(result, &external.NullableClass{Valid: true, StrID: class.ID, Class: *p.toClass(&class.DirectoryClass, "")})
+		}
+	}
+	return result, nil
+}
+
+// GetRelatedClassIDWithMeAccordPermission active classes of the organization, of the schools or of the operator,
+// by the widest of the report permissions
+func (p localClassProvider) GetRelatedClassIDWithMeAccordPermission(ctx context.Context, operator *entity.Operator, permissions map[external.PermissionName]bool) ([]string, error) {
+	var classes []*external.Class
+	switch {
+	case permissions[external.ReportOrganizationStudentUsage]:
+		result, err := p.GetByOrganizationIDs(ctx, operator, []string{operator.OrgID}, external.WithStatus(external.Active))
+		if err != nil {
+			return nil, err
+		}
+		classes = result[operator.OrgID]
+	case permissions[external.ReportSchoolStudentUsage]:
+		schools, err := localSchoolProvider{}.GetByPermission(ctx, operator, external.ReportSchoolStudentUsage, external.WithStatus(external.Active))
+		if err != nil {
+			return nil, err
+		}
+		schoolIDs := make([]string, len(schools))
+		for i, school := range schools {
+			schoolIDs[i] = school.ID
+		}
+		result, err := p.GetBySchoolIDs(ctx, operator, schoolIDs, external.WithStatus(external.Active))
+		if err != nil {
+			return nil, err
+		}
+		for _, schoolClasses := range result {
+			classes = append(classes, schoolClasses...)
+		}
+	case permissions[external.ReportTeacherStudentUsage]:
+		result, err := p.GetByUserID(ctx, operator, operator.UserID, external.WithStatus(external.Active))
+		if err != nil {
+			return nil, err
+		}
+		classes = result
+	}
+
+	classIDs := make([]string, len(classes))
+	for i, class := range classes {
+		classIDs[i] = class.ID
+	}
+	return classIDs, nil
+}
+
+// localTeacherProvider users teaching the classes
+type localTeacherProvider struct{}
+
+func (p localTeacherProvider) toTeacher(user *entity.DirectoryUser) *external.Teacher {
+	return &external.Teacher{
+		ID:         user.ID,
+		GivenName:  user.GivenName,
+		FamilyName: user.FamilyName,
+	}
+}
+
+// groupTeachers teachers of the classes grouped by the related ids of the classes, each teacher once per related id
+func (p localTeacherProvider) groupTeachers(ctx context.Context, classes []*entity.DirectoryRelatedClass) (map[string][]*external.Teacher, error) {
+	classIDs := make([]string, len(classes))
+	for i, class := range classes {
+		classIDs[i] = class.ID
+	}
+	members, err := da.GetDirectoryDA().GetClassMembers(ctx, classIDs, entity.DirectoryJoinTypeTeaching)
+	if err != nil {
+		return nil, err
+	}
+	classMembers := make(map[string][]*entity.DirectoryClassMemberUser, len(classIDs))
+	for _, member := range members {
+		classMembers[member.ClassID] = append(classMembers[member.ClassID], member)
+	}
+
+	result := make(map[string][]*external.Teacher)
+	exists := make(map[string]map[string]bool)
+	for _, class := range classes {
+		if exists[class.RelatedID] == nil {
+			exists[class.RelatedID] = make(map[string]bool)
+		}
+		for _, member := range classMembers[class.ID] {
+			if exists[class.RelatedID][member.ID] {
+				continue
+			}
+			exists[class.RelatedID][member.ID] = true
+			result[class.RelatedID] = append(result[class.RelatedID], p.toTeacher(&member.DirectoryUser))
+		}
+	}
+	return result, nil
+}
+
+func (p localTeacherProvider) Name() string {
+	return "local_directory_teacher"
+}
+
+func (p localTeacherProvider) QueryByIDs(ctx context.Context, ids []string, options ...interface{}) ([]cache.Object, error) {
+	users, err := getDirectoryUsers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]cache.Object, len(users))
+	for i, user := range users {
+		result[i] = &external.NullableTeacher{Valid: true, StrID: user.ID, Teacher: p.toTeacher(user)}
+	}
+	return result, nil
+}
+
+func (p localTeacherProvider) BatchGet(ctx context.Context, operator *entity.Operator, ids []string) ([]*external.NullableTeacher, error) {
+	users, err := getDirectoryUsers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	userMap := make(map[string]*entity.DirectoryUser, len(users))
+	for _, user := range users {
+		userMap[user.ID] = user
+	}
+	result := make([]*external.NullableTeacher, len(ids))
+	for i, id := range ids {
+		user, ok := userMap[id]
+		if !ok {
+			result[i] = &external.NullableTeacher{Valid: false, StrID: id}
+			continue
+		}
+		result[i] = &external.NullableTeacher{Valid: true, StrID: id, Teacher: p.toTeacher(user)}
+	}
+	return result, nil
+}
+
+func (p localTeacherProvider) BatchGetMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]*external.NullableTeacher, error) {
+	teachers, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]*external.NullableTeacher{}, err
+	}
+
+	dict := make(map[string]*external.NullableTeacher, len(teachers))
+	for _, teacher := range teachers {
+		if teacher.Teacher == nil || !teacher.Valid {
+			continue
+		}
+		dict[teacher.ID] = teacher
+	}
+	return dict, nil
+}
+
+func (p localTeacherProvider) BatchGetNameMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	teachers, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	dict := make(map[string]string, len(teachers))
+	for _, teacher := range teachers {
+		if teacher.Teacher == nil || !teacher.Valid {
+			continue
+		}
+		dict[teacher.ID] = teacher.Name()
+	}
+	return dict, nil
+}
+
+func (p localTeacherProvider) GetByOrganization(ctx context.Context, operator *entity.Operator, organizationID string) ([]*external.Teacher, error) {
+	teachers, err := p.GetByOrganizations(ctx, operator, []string{organizationID})
+	if err != nil {
+		return nil, err
+	}
+	return teachers[organizationID], nil
+}
+
+// GetByOrganizations teachers of the classes of the organizations in any status, the same as ams
+func (p localTeacherProvider) GetByOrganizations(ctx context.Context, operator *entity.Operator, organizationIDs []string) (map[string][]*external.Teacher, error) {
+	classes, err := da.GetDirectoryDA().GetClassesByOrganizations(ctx, utils.SliceDeduplicationExcludeEmpty(organizationIDs))
+	if err != nil {
+		return nil, err
+	}
+	return p.groupTeachers(ctx, classes)
+}
+
+// GetBySchools teachers of the active classes of the schools
+func (p localTeacherProvider) GetBySchools(ctx context.Context, operator *entity.Operator, schoolIDs []string) (map[string][]*external.Teacher, error) {
+	schoolClasses, err := da.GetDirectoryDA().GetClassesBySchools(ctx, utils.SliceDeduplicationExcludeEmpty(schoolIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	classes := make([]*entity.DirectoryRelatedClass, 0, len(schoolClasses))
+	for _, class := range schoolClasses {
+		if class.Status == entity.DirectoryStatusActive {
+			classes = append(classes, class)
+		}
+	}
+	return p.groupTeachers(ctx, classes)
+}
+
+func (p localTeacherProvider) GetByClasses(ctx context.Context, operator *entity.Operator, classIDs []string) (map[string][]*external.Teacher, error) {
+	ids := utils.SliceDeduplicationExcludeEmpty(classIDs)
+	classes := make([]*entity.DirectoryRelatedClass, len(ids))
+	for i, id := range ids {
+		classes[i] = &entity.DirectoryRelatedClass{RelatedID: id, DirectoryClass: entity.DirectoryClass{ID: id}}
+	}
+	return p.groupTeachers(ctx, classes)
+}
+
+func (p localTeacherProvider) Query(ctx context.Context, operator *entity.Operator, organizationID, keyword string) ([]*external.Teacher, error) {
+	users, err := da.GetDirectoryDA().GetMembers(ctx, organizationID, keyword, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*external.Teacher, len(users))
+	for i, user := range users {
+		result[i] = p.toTeacher(user)
+	}
+	return result, nil
+}
+
+// localStudentProvider users studying in the classes
+type localStudentProvider struct{}
+
+func (p localStudentProvider) toStudent(user *entity.DirectoryUser) *external.Student {
+	return &external.Student{
+		ID:         user.ID,
+		GivenName:  user.GivenName,
+		FamilyName: user.FamilyName,
+	}
+}
+
+func (p localStudentProvider) Name() string {
+	return "local_directory_student"
+}
+
+func (p localStudentProvider) QueryByIDs(ctx context.Context, ids []string, options ...interface{}) ([]cache.Object, error) {
+	users, err := getDirectoryUsers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]cache.Object, len(users))
+	for i, user := range users {
+		result[i] = &external.NullableStudent{Valid: true, StrID: user.ID, Student: p.toStudent(user)}
+	}
+	return result, nil
+}
+
+func (p localStudentProvider) Get(ctx context.Context, operator *entity.Operator, id string) (*external.Student, error) {
+	students, err := p.BatchGet(ctx, operator, []string{id})
+	if err != nil {
+		return nil, err
+	}
+
+	if students[0].Student == nil || !students[0].Valid {
+		return nil, constant.ErrRecordNotFound
+	}
+
+	return students[0].Student, nil
+}
+
+func (p localStudentProvider) BatchGet(ctx context.Context, operator *entity.Operator, ids []string) ([]*external.NullableStudent, error) {
+	users, err := getDirectoryUsers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	userMap := make(map[string]*entity.DirectoryUser, len(users))
+	for _, user := range users {
+		userMap[user.ID] = user
+	}
+	result := make([]*external.NullableStudent, len(ids))
+	for i, id := range ids {
+		user, ok := userMap[id]
+		if !ok {
+			result[i] = &external.NullableStudent{Valid: false, StrID: id}
+			continue
+		}
+		result[i] = &external.NullableStudent{Valid: true, StrID: id, Student: p.toStudent(user)}
+	}
+	return result, nil
+}
+
+func (p localStudentProvider) BatchGetMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]*external.NullableStudent, error) {
+	students, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]*external.NullableStudent{}, err
+	}
+
+	dict := make(map[string]*external.NullableStudent, len(students))
+	for _, student := range students {
+		if student.Student == nil || !student.Valid {
+			continue
+		}
+		dict[student.ID] = student
+	}
+	return dict, nil
+}
+
+func (p localStudentProvider) BatchGetNameMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	students, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	dict := make(map[string]string, len(students))
+	for _, student := range students {
+		if student.Student == nil || !student.Valid {
+			continue
+		}
+		dict[student.ID] = student.Name()
+	}
+	return dict, nil
+}
+
+func (p localStudentProvider) GetByClassID(ctx context.Context, operator *entity.Operator, classID string) ([]*external.Student, error) {
+	students, err := p.GetByClassIDs(ctx, operator, []string{classID})
+	if err != nil {
+		return nil, err
+	}
+	return students[classID], nil
+}
+
+func (p localStudentProvider) GetByClassIDs(ctx context.Context, operator *entity.Operator, classIDs []string) (map[string][]*external.Student, error) {
+	members, err := da.GetDirectoryDA().GetClassMembers(ctx, utils.SliceDeduplicationExcludeEmpty(classIDs), entity.DirectoryJoinTypeStudying)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]*external.Student, len(classIDs))
+	for _, member := range members {
+		result[member.ClassID] = append(result[member.ClassID], p.toStudent(&member.DirectoryUser))
+	}
+	return result, nil
+}
+
+func (p localStudentProvider) Query(ctx context.Context, operator *entity.Operator, organizationID, keyword string) ([]*external.Student, error) {
+	users, err := da.GetDirectoryDA().GetMembers(ctx, organizationID, keyword, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*external.Student, len(users))
+	for i, user := range users {
+		result[i] = p.toStudent(user)
+	}
+	return result, nil
+}
+
+type localRoleProvider struct{}
+
+func (p localRoleProvider) GetRole(ctx context.Context, op *entity.Operator, roleName entity.RoleName) (*entity.Role, error) {
+	roles, err := da.GetDirectoryDA().GetRoleByName(ctx, op.OrgID, string(roleName))
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		log.Warn(ctx, "role not found", log.Any("operator", op), log.String("role_name", string(roleName)))
+		return nil, constant.ErrRecordNotFound
+	}
+
+	return &entity.Role{
+		ID:     roles[0].ID,
+		Name:   roles[0].Name,
+		Status: roles[0].Status,
+	}, nil
+}
+
+// localPermissionProvider roles of a school grant permissions in the school only,
+// roles of an organization grant permissions in the organization and all of its schools
+type localPermissionProvider struct{}
+
+func (p localPermissionProvider) permissionNames(permissionNames []external.PermissionName) []string {
+	names := make([]string, len(permissionNames))
+	for i, name := range permissionNames {
+		names[i] = name.String()
+	}
+	return names
+}
+
+func (p localPermissionProvider) HasOrganizationPermission(ctx context.Context, operator *entity.Operator, permissionName external.PermissionName) (bool, error) {
+	return p.HasAnyOrganizationPermission(ctx, operator, []string{operator.OrgID}, permissionName)
+}
+
+func (p localPermissionProvider) HasSchoolPermission(ctx context.Context, operator *entity.Operator, schoolID string, permissionName external.PermissionName) (bool, error) {
+	return p.HasAnySchoolPermission(ctx, operator, []string{schoolID}, permissionName)
+}
+
+func (p localPermissionProvider) HasAnyOrganizationPermission(ctx context.Context, operator *entity.Operator, orgIDs []string, permissionName external.PermissionName) (bool, error) {
+	orgIDs = utils.SliceDeduplicationExcludeEmpty(orgIDs)
+	if len(orgIDs) == 0 {
+		return false, nil
+	}
+
+	permissions, err := da.GetDirectoryDA().GetMemberPermissions(ctx, operator.UserID, orgIDs, []string{permissionName.String()})
+	if err != nil {
+		return false, err
+	}
+	for _, permission := range permissions {
+		if permission.SchoolID == "" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p localPermissionProvider) HasAnySchoolPermission(ctx context.Context, operator *entity.Operator, schoolIDs []string, permissionName external.PermissionName) (bool, error) {
+	schools, err := getDirectorySchools(ctx, schoolIDs)
+	if err != nil {
+		return false, err
+	}
+	if len(schools) == 0 {
+		return false, nil
+	}
+
+	orgIDs := make([]string, len(schools))
+	for i, school := range schools {
+		orgIDs[i] = school.OrgID
+	}
+	permissions, err := da.GetDirectoryDA().GetMemberPermissions(ctx, operator.UserID, utils.SliceDeduplication(orgIDs), []string{permissionName.String()})
+	if err != nil {
+		return false, err
+	}
+
+	for _, school := range schools {
+		for _, permission := range permissions {
+			if permission.SchoolID == school.ID || (permission.SchoolID == "" && permission.OrgID == school.OrgID) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (p localPermissionProvider) HasOrganizationPermissions(ctx context.Context, operator *entity.Operator, permissionNames []external.PermissionName) (map[external.PermissionName]bool, error) {
+	result := make(map[external.PermissionName]bool, len(permissionNames))
+	if len(permissionNames) == 0 {
+		return result, nil
+	}
+
+	permissions, err := da.GetDirectoryDA().GetMemberPermissions(ctx, operator.UserID, []string{operator.OrgID}, p.permissionNames(permissionNames))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range permissionNames {
+		result[name] = false
+	}
+	for _, permission := range permissions {
+		if permission.SchoolID == "" {
+			result[external.PermissionName(permission.PermissionName)] = true
+		}
+	}
+	return result, nil
+}
+
+type localProgramProvider struct{}
+
+func (p localProgramProvider) toPrograms(programs []*entity.DirectoryProgram, options ...external.APOption) []*external.Program {
+	condition := external.NewCondition(options...)
+	result := make([]*external.Program, 0, len(programs))
+	for _, program := range programs {
+		if !directoryStatusMatches(condition, program.Status) || !directorySystemMatches(condition, program.System) {
+			continue
+		}
+		result = append(result, &external.Program{
+			ID:        program.ID,
+			Name:      program.Name,
+			GroupName: program.GroupName,
+			Status:    external.APStatus(program.Status),
+			System:    program.System,
+		})
+	}
+	return result
+}
+
+func (p localProgramProvider) Name() string {
+	return "local_directory_program"
+}
+
+func (p localProgramProvider) QueryByIDs(ctx context.Context, ids []string, options ...interface{}) ([]cache.Object, error) {
+	programs, err := p.BatchGet(ctx, nil, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]cache.Object, len(programs))
+	for i, program := range programs {
+		result[i] = program
+	}
+	return result, nil
+}
+
+func (p localProgramProvider) BatchGet(ctx context.Context, operator *entity.Operator, ids []string) ([]*external.Program, error) {
+	programs, err := da.GetDirectoryDA().GetPrograms(ctx, utils.SliceDeduplicationExcludeEmpty(ids))
+	if err != nil {
+		return nil, err
+	}
+	return p.toPrograms(programs, external.WithStatus(external.Ignore)), nil
+}
+
+func (p localProgramProvider) BatchGetMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]*external.Program, error) {
+	programs, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]*external.Program{}, err
+	}
+
+	dict := make(map[string]*external.Program, len(programs))
+	for _, program := range programs {
+		dict[program.ID] = program
+	}
+	return dict, nil
+}
+
+func (p localProgramProvider) BatchGetNameMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	programs, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	dict := make(map[string]string, len(programs))
+	for _, program := range programs {
+		dict[program.ID] = program.Name
+	}
+	return dict, nil
+}
+
+func (p localProgramProvider) GetByOrganization(ctx context.Context, operator *entity.Operator, options ...external.APOption) ([]*external.Program, error) {
+	programs, err := da.GetDirectoryDA().GetProgramsByOrganization(ctx, operator.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	return p.toPrograms(programs, options...), nil
+}
+
+type localSubjectProvider struct{}
+
+func (p localSubjectProvider) toSubjects(subjects []*entity.DirectorySubject, options ...external.APOption) []*external.Subject {
+	condition := external.NewCondition(options...)
+	result := make([]*external.Subject, 0, len(subjects))
+	for _, subject := range subjects {
+		if !directoryStatusMatches(condition, subject.Status) || !directorySystemMatches(condition, subject.System) {
+			continue
+		}
+		result = append(result, &external.Subject{
+			ID:     subject.ID,
+			Name:   subject.Name,
+			Status: external.APStatus(subject.Status),
+			System: subject.System,
+		})
+	}
+	return result
+}
+
+func (p localSubjectProvider) Name() string {
+	return "local_directory_subject"
+}
+
+func (p localSubjectProvider) QueryByIDs(ctx context.Context, ids []string, options ...interface{}) ([]cache.Object, error) {
+	subjects, err := p.BatchGet(ctx, nil, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]cache.Object, len(subjects))
+	for i, subject := range subjects {
+		result[i] = subject
+	}
+	return result, nil
+}
+
+func (p localSubjectProvider) BatchGet(ctx context.Context, operator *entity.Operator, ids []string) ([]*external.Subject, error) {
+	subjects, err := da.GetDirectoryDA().GetSubjects(ctx, utils.SliceDeduplicationExcludeEmpty(ids))
+	if err != nil {
+		return nil, err
+	}
+	return p.toSubjects(subjects, external.WithStatus(external.Ignore)), nil
+}
+
+func (p localSubjectProvider) BatchGetMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]*external.Subject, error) {
+	subjects, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]*external.Subject{}, err
+	}
+
+	dict := make(map[string]*external.Subject, len(subjects))
+	for _, subject := range subjects {
+		dict[subject.ID] = subject
+	}
+	return dict, nil
+}
+
+func (p localSubjectProvider) BatchGetNameMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	subjects, err := p.BatchGet(ctx, operator, ids)
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	dict := make(map[string]string, len(subjects))
+	for _, subject := range subjects {
+		dict[subject.ID] = subject.Name
+	}
+	return dict, nil
+}
+
+func (p localSubjectProvider) GetByProgram(ctx context.Context, operator *entity.Operator, programID string, options ...external.APOption) ([]*external.Subject, error) {
+	subjects, err := da.GetDirectoryDA().GetSubjectsByProgram(ctx, programID)
+	if err != nil {
+		return nil, err
+	}
+	return p.toSubjects(subjects, options...), nil
+}
+
+func (p localSubjectProvider) GetByOrganization(ctx context.Context, operator *entity.Operator, options ...external.APOption) ([]*external.Subject, error) {
+	subjects, err := da.GetDirectoryDA().GetSubjectsByOrganization(ctx, operator.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	return p.toSubjects(subjects, options...), nil
+}
+
+// localAgeProvider the directory has no ages, grades or categories, their local providers find none of them
+// instead of asking ams
+type localAgeProvider struct{}
+
+func (p localAgeProvider) Name() string {
+	return "local_directory_age"
+}
+
+func (p localAgeProvider) QueryByIDs(ctx context.Context, ids []string, options ...interface{}) ([]cache.Object, error) {
+	return []cache.Object{}, nil
+}
+
+func (p localAgeProvider) BatchGet(ctx context.Context, operator *entity.Operator, ids []string) ([]*external.Age, error) {
+	return []*external.Age{}, nil
+}
+
+func (p localAgeProvider) BatchGetMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]*external.Age, error) {
+	return map[string]*external.Age{}, nil
+}
+
+func (p localAgeProvider) BatchGetNameMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (p localAgeProvider) GetByProgram(ctx context.Context, operator *entity.Operator, programID string, options ...external.APOption) ([]*external.Age, error) {
+	return []*external.Age{}, nil
+}
+
+func (p localAgeProvider) GetByOrganization(ctx context.Context, operator *entity.Operator, options ...external.APOption) ([]*external.Age, error) {
+	return []*external.Age{}, nil
+}
+
+type localGradeProvider struct{}
+
+func (p localGradeProvider) Name() string {
+	return "local_directory_grade"
+}
+
+func (p localGradeProvider) QueryByIDs(ctx context.Context, ids []string, options ...interface{}) ([]cache.Object, error) {
+	return []cache.Object{}, nil
+}
+
+func (p localGradeProvider) BatchGet(ctx context.Context, operator *entity.Operator, ids []string) ([]*external.Grade, error) {
+	return []*external.Grade{}, nil
+}
+
+func (p localGradeProvider) BatchGetMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]*external.Grade, error) {
+	return map[string]*external.Grade{}, nil
+}
+
+func (p localGradeProvider) BatchGetNameMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (p localGradeProvider) GetByProgram(ctx context.Context, operator *entity.Operator, programID string, options ...external.APOption) ([]*external.Grade, error) {
+	return []*external.Grade{}, nil
+}
+
+func (p localGradeProvider) GetByOrganization(ctx context.Context, operator *entity.Operator, options ...external.APOption) ([]*external.Grade, error) {
+	return []*external.Grade{}, nil
+}
+
+type localCategoryProvider struct{}
+
+func (p localCategoryProvider) Name() string {
+	return "local_directory_category"
+}
+
+func (p localCategoryProvider) QueryByIDs(ctx context.Context, ids []string, options ...interface{}) ([]cache.Object, error) {
+	return []cache.Object{}, nil
+}
+
+func (p localCategoryProvider) BatchGet(ctx context.Context, operator *entity.Operator, ids []string) ([]*external.Category, error) {
+	return []*external.Category{}, nil
+}
+
+func (p localCategoryProvider) BatchGetMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]*external.Category, error) {
+	return map[string]*external.Category{}, nil
+}
+
+func (p localCategoryProvider) BatchGetNameMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (p localCategoryProvider) GetByProgram(ctx context.Context, operator *entity.Operator, programID string, options ...external.APOption) ([]*external.Category, error) {
+	return []*external.Category{}, nil
+}
+
+func (p localCategoryProvider) GetByOrganization(ctx context.Context, operator *entity.Operator, options ...external.APOption) ([]*external.Category, error) {
+	return []*external.Category{}, nil
+}
+
+func (p localCategoryProvider) GetBySubjects(ctx context.Context, operator *entity.Operator, subjectIDs []string, options ...external.APOption) ([]*external.Category, error) {
+	return []*external.Category{}, nil
+}
+
+type localSubCategoryProvider struct{}
+
+func (p localSubCategoryProvider) Name() string {
+	return "local_directory_sub_category"
+}
+
+func (p localSubCategoryProvider) QueryByIDs(ctx context.Context, ids []string, options ...interface{}) ([]cache.Object, error) {
+	return []cache.Object{}, nil
+}
+
+func (p localSubCategoryProvider) BatchGet(ctx context.Context, operator *entity.Operator, ids []string) ([]*external.SubCategory, error) {
+	return []*external.SubCategory{}, nil
+}
+
+func (p localSubCategoryProvider) BatchGetMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]*external.SubCategory, error) {
+	return map[string]*external.SubCategory{}, nil
+}
+
+func (p localSubCategoryProvider) BatchGetNameMap(ctx context.Context, operator *entity.Operator, ids []string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (p localSubCategoryProvider) GetByCategory(ctx context.Context, operator *entity.Operator, categoryID string, options ...external.APOption) ([]*external.SubCategory, error) {
+	return []*external.SubCategory{}, nil
+}
+
+func (p localSubCategoryProvider) GetByOrganization(ctx context.Context, operator *entity.Operator, options ...external.APOption) ([]*external.SubCategory, error) {
+	return []*external.SubCategory{}, nil
+}
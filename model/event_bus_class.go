@@ -2,6 +2,7 @@ package model
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
 
 	"github.com/KL-Engineering/kidsloop-cms-service/utils"
@@ -18,31 +19,43 @@ type BusTopicClassAddMembersFunc func(ctx context.Context, op *entity.Operator,
 type BusTopicClassDeleteMembersFunc func(ctx context.Context, op *entity.Operator, event *entity.ClassUpdateMembersEvent) error
 
 type IClassEventBus interface {
-	SubAddMembers(handler BusTopicClassAddMembersFunc) error
+	SubAddMembers(name string, handler BusTopicClassAddMembersFunc) error
 	PubAddMembers(ctx context.Context, op *entity.Operator, event *entity.ClassUpdateMembersEvent) error
 
-	SubDeleteMembers(handler BusTopicClassDeleteMembersFunc) error
+	SubDeleteMembers(name string, handler BusTopicClassDeleteMembersFunc) error
 	PubDeleteMembers(ctx context.Context, op *entity.Operator, event *entity.ClassUpdateMembersEvent) error
 }
 
 type classEventBus struct {
-	bus *utils.AsyncEventBus
+	bus IDomainEventBus
 }
 
-func (b *classEventBus) SubDeleteMembers(handler BusTopicClassDeleteMembersFunc) error {
-	return b.bus.Sub(BusTopicClassDeleteMembers, handler)
+func (b *classEventBus) SubDeleteMembers(name string, handler BusTopicClassDeleteMembersFunc) error {
+	return b.bus.Subscribe(BusTopicClassDeleteMembers, name, func(ctx context.Context, op *entity.Operator, payload []byte) error {
+		event := new(entity.ClassUpdateMembersEvent)
+		if err := json.Unmarshal(payload, event); err != nil {
+			return err
+		}
+		return handler(ctx, op, event)
+	})
 }
 
 func (b *classEventBus) PubDeleteMembers(ctx context.Context, op *entity.Operator, event *entity.ClassUpdateMembersEvent) error {
-	return b.bus.Pub(BusTopicClassDeleteMembers, ctx, op, event)
+	return b.bus.Publish(ctx, op, BusTopicClassDeleteMembers, event)
 }
 
-func (b *classEventBus) SubAddMembers(handler BusTopicClassAddMembersFunc) error {
-	return b.bus.Sub(BusTopicClassAddMembers, handler)
+func (b *classEventBus) SubAddMembers(name string, handler BusTopicClassAddMembersFunc) error {
+	return b.bus.Subscribe(BusTopicClassAddMembers, name, func(ctx context.Context, op *entity.Operator, payload []byte) error {
+		event := new(entity.ClassUpdateMembersEvent)
+		if err := json.Unmarshal(payload, event); err != nil {
+			return err
+		}
+		return handler(ctx, op, event)
+	})
 }
 
 func (b *classEventBus) PubAddMembers(ctx context.Context, op *entity.Operator, event *entity.ClassUpdateMembersEvent) error {
-	return b.bus.Pub(BusTopicClassAddMembers, ctx, op, event)
+	return b.bus.Publish(ctx, op, BusTopicClassAddMembers, event)
 }
 
 var (
@@ -53,10 +66,10 @@ var (
 func GetClassEventBusModel() IClassEventBus {
 	_busEventOnce.Do(func() {
 		bus := &classEventBus{
-			bus: utils.NewAsyncEventBus(),
+			bus: GetDomainEventBus(),
 		}
-		bus.SubAddMembers(GetScheduleEventModel().AddMembersEvent)
-		bus.SubDeleteMembers(GetScheduleEventModel().DeleteMembersEvent)
+		bus.SubAddMembers("schedule.AddMembersEvent", GetScheduleEventModel().AddMembersEvent)
+		bus.SubDeleteMembers("schedule.DeleteMembersEvent", GetScheduleEventModel().DeleteMembersEvent)
 		_busModel = bus
 	})
 	return _busModel
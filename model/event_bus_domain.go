@@ -0,0 +1,505 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/mq"
+	"github.com/KL-Engineering/kidsloop-cms-service/mutex"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+// domainEventQueueTopic message queue carries event ids only, the outbox is the source of truth
+const domainEventQueueTopic = "cms:domain_event"
+
+const domainEventDispatchBatchSize = 100
+
+// DomainEventHandler payload is the json of the published event,
+// handlers may be called more than once for an event and must not rely on the operator token.
+// The operator keeps its user and org but has no token, ams and h5p are called with the authorized keys
+type DomainEventHandler func(ctx context.Context, op *entity.Operator, payload []byte) error
+
+type IDomainEventBus interface {
+	// Subscribe name identifies the handler in consumption records, do not rename a handler with events in flight
+	Subscribe(topic utils.BusTopic, name string, handler DomainEventHandler) error
+	// Publish write the event to the outbox and dispatch it at once
+	Publish(ctx context.Context, op *entity.Operator, topic utils.BusTopic, event interface{}) error
+	// PublishTx write the event to the outbox in tx, it is dispatched by the worker after tx committed
+	PublishTx(ctx context.Context, tx *dbo.DBContext, op *entity.Operator, topic utils.BusTopic, event interface{}) error
+
+	Dispatch(ctx context.Context) (int, error)
+	Consume(ctx context.Context, id string) error
+
+	Query(ctx context.Context, req *entity.DomainEventQueryReq) (*entity.DomainEventPageReply, error)
+	// Replay dispatch a dead or pending event again, handlers which already succeeded are skipped
+	Replay(ctx context.Context, id string) error
+}
+
+type domainEventSubscriber struct {
+	name    string
+	handler DomainEventHandler
+}
+
+type domainEventBus struct {
+	lock        sync.RWMutex
+	subscribers map[utils.BusTopic][]*domainEventSubscriber
+}
+
+var (
+	_domainEventBusOnce sync.Once
+	_domainEventBus     IDomainEventBus
+)
+
+func GetDomainEventBus() IDomainEventBus {
+	_domainEventBusOnce.Do(func() {
+		_domainEventBus = &domainEventBus{
+			subscribers: make(map[utils.BusTopic][]*domainEventSubscriber),
+		}
+	})
+	return _domainEventBus
+}
+
+// StartDomainEventWorker consume dispatched events and relay due events from the outbox,
+// relaying is done by one instance at a time
+func StartDomainEventWorker(ctx context.Context) {
+	// subscribers are registered when the buses are created
+	GetClassEventBusModel()
+	GetLiveRoomEventBusModel()
+	GetScheduleEventBusModel()
+	GetWebhookModel()
+	GetXAPIModel()
+
+	queue, err := mq.GetMQ(ctx)
+	if err != nil {
+		log.Error(ctx, "domain event worker: get mq failed, events stay in the outbox", log.Err(err))
+		return
+	}
+
+	bus := GetDomainEventBus()
+	queue.SubscribeWithReconnect(domainEventQueueTopic, func(ctx context.Context, message string) error {
+		return bus.Consume(ctx, message)
+	})
+
+	ticker := time.NewTicker(config.Get().EventBus.DispatchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		dispatchDomainEvents(utils.CloneContextWithTrace(ctx))
+	}
+}
+
+func dispatchDomainEvents(ctx context.Context) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(ctx, "dispatch domain events panic", log.Any("recover error", err))
+		}
+	}()
+
+	locker, err := mutex.NewLock(ctx, da.RedisKeyPrefixDomainEventDispatchLock)
+	if err != nil {
+		log.Error(ctx, "dispatch domain events: create lock failed", log.Err(err))
+		return
+	}
+	locker.Lock()
+	defer locker.Unlock()
+
+	count, err := GetDomainEventBus().Dispatch(ctx)
+	if err != nil {
+		log.Error(ctx, "dispatch domain events failed", log.Err(err))
+		return
+	}
+
+	if count > 0 {
+		log.Info(ctx, "dispatch domain events finished", log.Int("count", count))
+	}
+}
+
+func (b *domainEventBus) Subscribe(topic utils.BusTopic, name string, handler DomainEventHandler) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, item := range b.subscribers[topic] {
+		if item.name == name {
+			return fmt.Errorf("handler %s already subscribed topic %v", name, topic)
+		}
+	}
+
+	b.subscribers[topic] = append(b.subscribers[topic], &domainEventSubscriber{
+		name:    name,
+		handler: handler,
+	})
+
+	return nil
+}
+
+func (b *domainEventBus) getSubscribers(topic utils.BusTopic) []*domainEventSubscriber {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.subscribers[topic]
+}
+
+func (b *domainEventBus) Publish(ctx context.Context, op *entity.Operator, topic utils.BusTopic, event interface{}) error {
+	domainEvent, err := b.newDomainEvent(ctx, op, topic, event)
+	if err != nil {
+		return err
+	}
+
+	if _, err := da.GetDomainEventDA().Insert(ctx, domainEvent); err != nil {
+		log.Error(ctx, "insert domain event failed", log.Err(err), log.Any("event", domainEvent))
+		return err
+	}
+
+	// the worker relays it later if the message queue is unavailable now
+	if err := b.dispatch(ctx, domainEvent); err != nil {
+		log.Warn(ctx, "dispatch domain event failed", log.Err(err), log.String("id", domainEvent.ID))
+	}
+
+	return nil
+}
+
+func (b *domainEventBus) PublishTx(ctx context.Context, tx *dbo.DBContext, op *entity.Operator, topic utils.BusTopic, event interface{}) error {
+	domainEvent, err := b.newDomainEvent(ctx, op, topic, event)
+	if err != nil {
+		return err
+	}
+
+	if _, err := da.GetDomainEventDA().InsertTx(ctx, tx, domainEvent); err != nil {
+		log.Error(ctx, "insert domain event failed", log.Err(err), log.Any("event", domainEvent))
+		return err
+	}
+
+	return nil
+}
+
+func (b *domainEventBus) newDomainEvent(ctx context.Context, op *entity.Operator, topic utils.BusTopic, event interface{}) (*entity.DomainEvent, error) {
+	if len(b.getSubscribers(topic)) <= 0 {
+		return nil, fmt.Errorf("not found handler in topic,%v", topic)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Error(ctx, "marshal domain event failed", log.Err(err), log.Any("event", event))
+		return nil, err
+	}
+
+	// token is not marshaled, it would have expired before a retry anyway
+	operator, err := json.Marshal(op)
+	if err != nil {
+		log.Error(ctx, "marshal operator failed", log.Err(err), log.Any("op", op))
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	return &entity.DomainEvent{
+		ID:            utils.NewID(),
+		Topic:         string(topic),
+		Payload:       string(payload),
+		Operator:      string(operator),
+		Status:        entity.DomainEventStatusPending,
+		NextAttemptAt: now,
+		CreateAt:      now,
+		UpdateAt:      now,
+	}, nil
+}
+
+func (b *domainEventBus) dispatch(ctx context.Context, event *entity.DomainEvent) error {
+	now := time.Now().Unix()
+	ok, err := da.GetDomainEventDA().MarkDispatched(ctx, event.ID, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Debug(ctx, "domain event is dispatched by others", log.String("id", event.ID))
+		return nil
+	}
+	event.Status = entity.DomainEventStatusDispatched
+	event.UpdateAt = now
+
+	queue, err := mq.GetMQ(ctx)
+	if err == nil {
+		err = queue.Publish(ctx, domainEventQueueTopic, event.ID)
+	}
+	if err != nil {
+		log.Error(ctx, "publish domain event failed", log.Err(err), log.String("id", event.ID))
+
+		event.Status = entity.DomainEventStatusPending
+		if _, updateErr := da.GetDomainEventDA().Update(ctx, event); updateErr != nil {
+			log.Error(ctx, "reset domain event failed", log.Err(updateErr), log.String("id", event.ID))
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (b *domainEventBus) Dispatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	condition := &da.DomainEventCondition{
+		Due: sql.NullInt64{
+			Int64: now.Unix(),
+			Valid: true,
+		},
+		DispatchedSince: sql.NullInt64{
+			Int64: now.Add(-config.Get().EventBus.DispatchTimeout).Unix(),
+			Valid: true,
+		},
+		OrderBy: "create_at",
+		Pager: dbo.Pager{
+			Page:     1,
+			PageSize: domainEventDispatchBatchSize,
+		},
+	}
+
+	var events []*entity.DomainEvent
+	if err := da.GetDomainEventDA().Query(ctx, condition, &events); err != nil {
+		log.Error(ctx, "query due domain events failed", log.Err(err), log.Any("condition", condition))
+		return 0, err
+	}
+
+	count := 0
+	for _, item := range events {
+		if item.Status == entity.DomainEventStatusDispatched {
+			// handlers never reported back, the consumer probably died
+			log.Warn(ctx, "domain event dispatch timeout", log.Any("event", item))
+			item.Status = entity.DomainEventStatusPending
+			item.UpdateAt = now.Unix()
+			if _, err := da.GetDomainEventDA().Update(ctx, item); err != nil {
+				log.Error(ctx, "reset domain event failed", log.Err(err), log.String("id", item.ID))
+				continue
+			}
+		}
+
+		if err := b.dispatch(ctx, item); err != nil {
+			// the message queue is down, try again next round
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func (b *domainEventBus) Consume(ctx context.Context, id string) error {
+	event := new(entity.DomainEvent)
+	err := da.GetDomainEventDA().Get(ctx, id, event)
+	if err == dbo.ErrRecordNotFound {
+		log.Warn(ctx, "domain event not found", log.String("id", id))
+		return nil
+	}
+	if err != nil {
+		log.Error(ctx, "get domain event failed", log.Err(err), log.String("id", id))
+		return err
+	}
+
+	if event.Status != entity.DomainEventStatusDispatched {
+		log.Debug(ctx, "domain event is not dispatched", log.Any("event", event))
+		return nil
+	}
+
+	consumed, err := b.getConsumedHandlers(ctx, []string{event.ID})
+	if err != nil {
+		return err
+	}
+
+	op := new(entity.Operator)
+	if err := json.Unmarshal([]byte(event.Operator), op); err != nil {
+		log.Warn(ctx, "unmarshal domain event operator failed", log.Err(err), log.Any("event", event))
+	}
+
+	var failures []string
+	permanent := false
+	for _, item := range b.getSubscribers(utils.BusTopic(event.Topic)) {
+		if consumed[event.ID][item.name] {
+			continue
+		}
+
+		if err := b.handle(ctx, item, op, event); err != nil {
+			log.Warn(ctx, "handle domain event failed", log.Err(err), log.String("handler", item.name), log.Any("event", event))
+			failures = append(failures, fmt.Sprintf("%s: %v", item.name, err))
+			if err == constant.ErrInvalidArgs {
+				permanent = true
+			}
+			continue
+		}
+
+		_, err := da.GetDomainEventConsumptionDA().Insert(ctx, &entity.DomainEventConsumption{
+			ID:       utils.NewID(),
+			EventID:  event.ID,
+			Handler:  item.name,
+			CreateAt: time.Now().Unix(),
+		})
+		if err != nil && err != dbo.ErrDuplicateRecord {
+			log.Error(ctx, "insert domain event consumption failed", log.Err(err), log.String("handler", item.name), log.String("id", event.ID))
+		}
+	}
+
+	now := time.Now().Unix()
+	switch {
+	case len(failures) <= 0:
+		event.Status = entity.DomainEventStatusSucceeded
+		event.UpdateAt = now
+	case permanent:
+		// retrying invalid args never helps
+		event.Fail(strings.Join(failures, "; "), 0, 0, now)
+	default:
+		conf := config.Get().EventBus
+		event.Fail(strings.Join(failures, "; "), conf.MaxAttempts, conf.RetryBackoff, now)
+	}
+
+	if _, err := da.GetDomainEventDA().Update(ctx, event); err != nil {
+		log.Error(ctx, "update domain event failed", log.Err(err), log.Any("event", event))
+		return err
+	}
+
+	return nil
+}
+
+func (b *domainEventBus) handle(ctx context.Context, subscriber *domainEventSubscriber, op *entity.Operator, event *entity.DomainEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return subscriber.handler(ctx, op, []byte(event.Payload))
+}
+
+func (b *domainEventBus) getConsumedHandlers(ctx context.Context, eventIDs []string) (map[string]map[string]bool, error) {
+	var consumptions []*entity.DomainEventConsumption
+	err := da.GetDomainEventConsumptionDA().Query(ctx, &da.DomainEventConsumptionCondition{
+		EventIDs: entity.NullStrings{
+			Strings: eventIDs,
+			Valid:   true,
+		},
+	}, &consumptions)
+	if err != nil {
+		log.Error(ctx, "query domain event consumptions failed", log.Err(err), log.Strings("eventIDs", eventIDs))
+		return nil, err
+	}
+
+	result := make(map[string]map[string]bool, len(eventIDs))
+	for _, item := range consumptions {
+		if _, ok := result[item.EventID]; !ok {
+			result[item.EventID] = make(map[string]bool)
+		}
+		result[item.EventID][item.Handler] = true
+	}
+
+	return result, nil
+}
+
+func (b *domainEventBus) Query(ctx context.Context, req *entity.DomainEventQueryReq) (*entity.DomainEventPageReply, error) {
+	if req.Status != "" && !req.Status.Valid() {
+		log.Warn(ctx, "domain event status invalid", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	condition := &da.DomainEventCondition{
+		Topic: sql.NullString{
+			String: req.Topic,
+			Valid:  req.Topic != "",
+		},
+		Status: sql.NullString{
+			String: string(req.Status),
+			Valid:  req.Status != "",
+		},
+		Pager: dbo.Pager{
+			Page:     req.PageIndex,
+			PageSize: req.PageSize,
+		},
+	}
+
+	var events []*entity.DomainEvent
+	total, err := da.GetDomainEventDA().Page(ctx, condition, &events)
+	if err != nil {
+		log.Error(ctx, "page domain events failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	eventIDs := make([]string, len(events))
+	for i := range events {
+		eventIDs[i] = events[i].ID
+	}
+
+	consumed := make(map[string]map[string]bool)
+	if len(eventIDs) > 0 {
+		consumed, err = b.getConsumedHandlers(ctx, eventIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &entity.DomainEventPageReply{
+		Total: total,
+		Data:  make([]*entity.DomainEventView, 0, len(events)),
+	}
+	for _, item := range events {
+		handlers := make([]string, 0, len(consumed[item.ID]))
+		for _, subscriber := range b.getSubscribers(utils.BusTopic(item.Topic)) {
+			if consumed[item.ID][subscriber.name] {
+				handlers = append(handlers, subscriber.name)
+			}
+		}
+
+		result.Data = append(result.Data, &entity.DomainEventView{
+			ID:            item.ID,
+			Topic:         item.Topic,
+			Payload:       item.Payload,
+			Status:        item.Status,
+			Attempts:      item.Attempts,
+			LastError:     item.LastError,
+			NextAttemptAt: item.NextAttemptAt,
+			Handlers:      handlers,
+			CreateAt:      item.CreateAt,
+			UpdateAt:      item.UpdateAt,
+		})
+	}
+
+	return result, nil
+}
+
+func (b *domainEventBus) Replay(ctx context.Context, id string) error {
+	event := new(entity.DomainEvent)
+	err := da.GetDomainEventDA().Get(ctx, id, event)
+	if err == dbo.ErrRecordNotFound {
+		return constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get domain event failed", log.Err(err), log.String("id", id))
+		return err
+	}
+
+	if event.Status != entity.DomainEventStatusDead && event.Status != entity.DomainEventStatusPending {
+		log.Warn(ctx, "domain event can not be replayed", log.Any("event", event))
+		return constant.ErrInvalidArgs
+	}
+
+	now := time.Now().Unix()
+	event.Status = entity.DomainEventStatusPending
+	event.Attempts = 0
+	event.NextAttemptAt = now
+	event.UpdateAt = now
+	if _, err := da.GetDomainEventDA().Update(ctx, event); err != nil {
+		log.Error(ctx, "reset domain event failed", log.Err(err), log.Any("event", event))
+		return err
+	}
+
+	if err := b.dispatch(ctx, event); err != nil {
+		log.Warn(ctx, "dispatch domain event failed", log.Err(err), log.String("id", event.ID))
+	}
+
+	return nil
+}
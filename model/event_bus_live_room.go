@@ -2,6 +2,7 @@ package model
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
 
 	"github.com/KL-Engineering/kidsloop-cms-service/entity"
@@ -17,20 +18,26 @@ const (
 type BusTopicLiveRoomEndClassFunc func(ctx context.Context, op *entity.Operator, event *v2.ScheduleEndClassCallBackReq) error
 
 type ILiveRoomEventBus interface {
-	SubEndClass(handler BusTopicLiveRoomEndClassFunc) error
+	SubEndClass(name string, handler BusTopicLiveRoomEndClassFunc) error
 	PubEndClass(ctx context.Context, op *entity.Operator, event *v2.ScheduleEndClassCallBackReq) error
 }
 
 type liveRoomEventBus struct {
-	bus *utils.AsyncEventBus
+	bus IDomainEventBus
 }
 
-func (b *liveRoomEventBus) SubEndClass(handler BusTopicLiveRoomEndClassFunc) error {
-	return b.bus.Sub(BusTopicLiveRoomEndClass, handler)
+func (b *liveRoomEventBus) SubEndClass(name string, handler BusTopicLiveRoomEndClassFunc) error {
+	return b.bus.Subscribe(BusTopicLiveRoomEndClass, name, func(ctx context.Context, op *entity.Operator, payload []byte) error {
+		event := new(v2.ScheduleEndClassCallBackReq)
+		if err := json.Unmarshal(payload, event); err != nil {
+			return err
+		}
+		return handler(ctx, op, event)
+	})
 }
 
 func (b *liveRoomEventBus) PubEndClass(ctx context.Context, op *entity.Operator, event *v2.ScheduleEndClassCallBackReq) error {
-	return b.bus.Pub(BusTopicLiveRoomEndClass, ctx, op, event)
+	return b.bus.Publish(ctx, op, BusTopicLiveRoomEndClass, event)
 }
 
 var (
@@ -41,11 +48,12 @@ var (
 func GetLiveRoomEventBusModel() ILiveRoomEventBus {
 	_liveRoomBusEventOnce.Do(func() {
 		bus := &liveRoomEventBus{
-			bus: utils.NewAsyncEventBus(),
+			bus: GetDomainEventBus(),
 		}
 
-		bus.SubEndClass(GetAssessmentInternalModel().ScheduleEndClassCallback)
-		bus.SubEndClass(GetClassesAssignmentsModel().CreateRecord)
+		bus.SubEndClass("assessment.ScheduleEndClassCallback", GetAssessmentInternalModel().ScheduleEndClassCallback)
+		bus.SubEndClass("classes_assignments.CreateRecord", GetClassesAssignmentsModel().CreateRecord)
+		bus.SubEndClass("xapi.RecordAttendance", recordXAPIAttendance)
 
 		_liveRoomBusModel = bus
 	})
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+const (
+	BusTopicScheduleStatusChanged utils.BusTopic = "ScheduleStatusChanged"
+)
+
+type BusTopicScheduleStatusChangedFunc func(ctx context.Context, op *entity.Operator, event *entity.ScheduleStatusChangedEvent) error
+
+type IScheduleEventBus interface {
+	SubStatusChanged(name string, handler BusTopicScheduleStatusChangedFunc) error
+	// PubStatusChangedTx the event is written along with the status, in the same transaction
+	PubStatusChangedTx(ctx context.Context, tx *dbo.DBContext, op *entity.Operator, event *entity.ScheduleStatusChangedEvent) error
+}
+
+type scheduleEventBus struct {
+	bus IDomainEventBus
+}
+
+func (b *scheduleEventBus) SubStatusChanged(name string, handler BusTopicScheduleStatusChangedFunc) error {
+	return b.bus.Subscribe(BusTopicScheduleStatusChanged, name, func(ctx context.Context, op *entity.Operator, payload []byte) error {
+		event := new(entity.ScheduleStatusChangedEvent)
+		if err := json.Unmarshal(payload, event); err != nil {
+			return err
+		}
+		return handler(ctx, op, event)
+	})
+}
+
+func (b *scheduleEventBus) PubStatusChangedTx(ctx context.Context, tx *dbo.DBContext, op *entity.Operator, event *entity.ScheduleStatusChangedEvent) error {
+	return b.bus.PublishTx(ctx, tx, op, BusTopicScheduleStatusChanged, event)
+}
+
+var (
+	_scheduleBusEventOnce sync.Once
+	_scheduleBusModel     IScheduleEventBus
+)
+
+func GetScheduleEventBusModel() IScheduleEventBus {
+	_scheduleBusEventOnce.Do(func() {
+		bus := &scheduleEventBus{
+			bus: GetDomainEventBus(),
+		}
+
+		bus.SubStatusChanged("report_rollup.MarkDirty", markReportRollupsOfScheduleStatus)
+
+		_scheduleBusModel = bus
+	})
+	return _scheduleBusModel
+}
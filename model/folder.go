@@ -427,9 +427,19 @@ func (f *FolderModel) UpdateFolder(ctx context.Context, folderID string, d entit
 	folder.Editor = operator.UserID
 	folder.Description = d.Description
 	folder.Keywords = strings.Join(d.Keywords, constant.StringArraySeparator)
-	err = da.GetFolderDA().UpdateFolder(ctx, dbo.MustGetDB(ctx), folderID, folder)
+	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		err := increaseRevisionTx(ctx, tx, entity.FolderItem{}.TableName(), folderID)
+		if err != nil {
+			return err
+		}
+		err = da.GetFolderDA().UpdateFolder(ctx, tx, folderID, folder)
+		if err != nil {
+			log.Error(ctx, "update folder item failed", log.Err(err), log.Any("folder", folder))
+			return err
+		}
+		return nil
+	})
 	if err != nil {
-		log.Error(ctx, "update folder item failed", log.Err(err), log.Any("folder", folder))
 		return err
 	}
 
@@ -443,9 +453,14 @@ func (f *FolderModel) RemoveItem(ctx context.Context, fid string, operator *enti
 	if err != nil {
 		return err
 	}
+	AuditBefore(ctx, folderItem.ID, folderItem)
 
 	return dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
-		err := f.removeItemInternal(ctx, tx, folderItem)
+		err := increaseRevisionTx(ctx, tx, entity.FolderItem{}.TableName(), folderItem.ID)
+		if err != nil {
+			return err
+		}
+		err = f.removeItemInternal(ctx, tx, folderItem)
 		if err != nil {
 			return err
 		}
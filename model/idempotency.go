@@ -0,0 +1,110 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+var (
+	// ErrIdempotencyConflict a request with the same key is in progress
+	ErrIdempotencyConflict = errors.New("idempotency key in progress")
+	// ErrIdempotencyMismatch the key was used with a different request
+	ErrIdempotencyMismatch = errors.New("idempotency key reused with a different request")
+)
+
+// IIdempotencyModel keys are scoped, e.g. by route and caller, so clients can't replay responses of others
+type IIdempotencyModel interface {
+	// Begin returns the completed record to replay, or nil if the request should be handled.
+	// A key being handled returns ErrIdempotencyConflict until it completes, aborts or the lock times out.
+	Begin(ctx context.Context, scope string, key string, requestHash string) (*entity.IdempotencyRecord, error)
+	// Complete keep the response for the replay window
+	Complete(ctx context.Context, scope string, key string, requestHash string, statusCode int, contentType string, body []byte) error
+	// Abort release the key, so the request can be retried with it
+	Abort(ctx context.Context, scope string, key string) error
+}
+
+type idempotencyModel struct{}
+
+var (
+	_idempotencyModel     IIdempotencyModel
+	_idempotencyModelOnce sync.Once
+)
+
+func GetIdempotencyModel() IIdempotencyModel {
+	_idempotencyModelOnce.Do(func() {
+		_idempotencyModel = &idempotencyModel{}
+	})
+	return _idempotencyModel
+}
+
+func (m *idempotencyModel) recordKey(scope string, key string) string {
+	hash := sha256.Sum256([]byte(scope + "\n" + key))
+	return hex.EncodeToString(hash[:])
+}
+
+func (m *idempotencyModel) Begin(ctx context.Context, scope string, key string, requestHash string) (*entity.IdempotencyRecord, error) {
+	recordKey := m.recordKey(scope, key)
+	processing := &entity.IdempotencyRecord{
+		State:       entity.IdempotencyStateProcessing,
+		RequestHash: requestHash,
+		CreateAt:    time.Now().Unix(),
+	}
+
+	// the record may expire between acquire and get, acquire again once
+	for i := 0; i < 2; i++ {
+		acquired, err := da.GetIdempotencyRedisDA().Acquire(ctx, recordKey, processing, config.Get().Idempotency.LockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			return nil, nil
+		}
+
+		record, err := da.GetIdempotencyRedisDA().Get(ctx, recordKey)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			continue
+		}
+
+		if record.RequestHash != requestHash {
+			log.Warn(ctx, "idempotency key reused", log.String("scope", scope), log.String("key", key))
+			return nil, ErrIdempotencyMismatch
+		}
+		if record.State != entity.IdempotencyStateCompleted {
+			log.Info(ctx, "idempotency key in progress", log.String("scope", scope), log.String("key", key))
+			return nil, ErrIdempotencyConflict
+		}
+		log.Info(ctx, "replay idempotent response", log.String("scope", scope), log.String("key", key))
+		return record, nil
+	}
+
+	return nil, ErrIdempotencyConflict
+}
+
+func (m *idempotencyModel) Complete(ctx context.Context, scope string, key string, requestHash string, statusCode int, contentType string, body []byte) error {
+	record := &entity.IdempotencyRecord{
+		State:       entity.IdempotencyStateCompleted,
+		RequestHash: requestHash,
+		StatusCode:  statusCode,
+		ContentType: contentType,
+		Body:        body,
+		CreateAt:    time.Now().Unix(),
+	}
+	return da.GetIdempotencyRedisDA().Set(ctx, m.recordKey(scope, key), record, config.Get().Idempotency.Window)
+}
+
+func (m *idempotencyModel) Abort(ctx context.Context, scope string, key string) error {
+	return da.GetIdempotencyRedisDA().Delete(ctx, m.recordKey(scope, key))
+}
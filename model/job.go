@@ -0,0 +1,585 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/mq"
+	"github.com/KL-Engineering/kidsloop-cms-service/mutex"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+// jobQueueTopic message queue carries job ids only, the jobs table is the source of truth
+const jobQueueTopic = "cms:job"
+
+const jobRelayBatchSize = 100
+
+var ErrJobCancelled = errors.New("job cancelled")
+
+// JobPermanentError a failure retrying can not fix, the job fails at once
+type JobPermanentError struct {
+	Err error
+}
+
+func (e *JobPermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// JobOutput result is saved as json, file is downloadable from the jobs api
+type JobOutput struct {
+	Result interface{}
+	File   *entity.JobFile
+}
+
+// IJobReporter handlers report progress regularly, it is the heartbeat of a running job
+type IJobReporter interface {
+	// Progress returns ErrJobCancelled when the job has been cancelled, handlers should stop and return it
+	Progress(ctx context.Context, done, total int) error
+}
+
+// JobHandler params is the json submitted with the job, op has the submitter's token while it has not expired.
+// A job may run more than once, handlers must be safe to retry.
+type JobHandler func(ctx context.Context, op *entity.Operator, params []byte, reporter IJobReporter) (*JobOutput, error)
+
+type IJobModel interface {
+	Register(jobType entity.JobType, handler JobHandler) error
+	// Submit save the job and enqueue it, returns the job id
+	Submit(ctx context.Context, op *entity.Operator, jobType entity.JobType, params interface{}) (string, error)
+
+	Consume(ctx context.Context, id string) error
+	// Relay enqueue jobs which are due or lost by workers
+	Relay(ctx context.Context) (int, error)
+
+	Query(ctx context.Context, op *entity.Operator, req *entity.JobQueryReq) (*entity.JobPageReply, error)
+	Get(ctx context.Context, op *entity.Operator, id string) (*entity.JobView, error)
+	Cancel(ctx context.Context, op *entity.Operator, id string) error
+	GetFile(ctx context.Context, op *entity.Operator, id string) (*entity.JobFile, error)
+}
+
+type jobModel struct {
+	lock     sync.RWMutex
+	handlers map[entity.JobType]JobHandler
+}
+
+var (
+	_jobModelOnce sync.Once
+	_jobModel     IJobModel
+)
+
+func GetJobModel() IJobModel {
+	_jobModelOnce.Do(func() {
+		m := &jobModel{
+			handlers: make(map[entity.JobType]JobHandler),
+		}
+		m.Register(entity.JobTypePublishContentBulk, publishContentBulkJob)
+		m.Register(entity.JobTypeDeleteContentBulk, deleteContentBulkJob)
+		m.Register(entity.JobTypeImportOutcomes, importOutcomesJob)
+		_jobModel = m
+	})
+	return _jobModel
+}
+
+// StartJobWorker run enqueued jobs and relay lost ones, relaying is done by one instance at a time
+func StartJobWorker(ctx context.Context) {
+	queue, err := mq.GetMQ(ctx)
+	if err != nil {
+		log.Error(ctx, "job worker: get mq failed, jobs stay pending", log.Err(err))
+		return
+	}
+
+	m := GetJobModel()
+	queue.SubscribeWithReconnect(jobQueueTopic, func(ctx context.Context, message string) error {
+		return m.Consume(ctx, message)
+	})
+
+	ticker := time.NewTicker(config.Get().Job.RelayInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		relayJobs(utils.CloneContextWithTrace(ctx))
+	}
+}
+
+func relayJobs(ctx context.Context) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(ctx, "relay jobs panic", log.Any("recover error", err))
+		}
+	}()
+
+	locker, err := mutex.NewLock(ctx, da.RedisKeyPrefixJobRelayLock)
+	if err != nil {
+		log.Error(ctx, "relay jobs: create lock failed", log.Err(err))
+		return
+	}
+	locker.Lock()
+	defer locker.Unlock()
+
+	count, err := GetJobModel().Relay(ctx)
+	if err != nil {
+		log.Error(ctx, "relay jobs failed", log.Err(err))
+		return
+	}
+
+	if count > 0 {
+		log.Info(ctx, "relay jobs finished", log.Int("count", count))
+	}
+}
+
+func (m *jobModel) Register(jobType entity.JobType, handler JobHandler) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.handlers[jobType]; ok {
+		return fmt.Errorf("job type %v already registered", jobType)
+	}
+	m.handlers[jobType] = handler
+
+	return nil
+}
+
+func (m *jobModel) getHandler(jobType entity.JobType) (JobHandler, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	handler, ok := m.handlers[jobType]
+	return handler, ok
+}
+
+func (m *jobModel) Submit(ctx context.Context, op *entity.Operator, jobType entity.JobType, params interface{}) (string, error) {
+	if _, ok := m.getHandler(jobType); !ok {
+		log.Warn(ctx, "job type not registered", log.Any("jobType", jobType))
+		return "", constant.ErrInvalidArgs
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		log.Error(ctx, "marshal job params failed", log.Err(err), log.Any("params", params))
+		return "", err
+	}
+
+	conf := config.Get().Job
+	now := time.Now()
+	job := &entity.Job{
+		ID:            utils.NewID(),
+		OrgID:         op.OrgID,
+		Type:          jobType,
+		Params:        string(data),
+		Status:        entity.JobStatusPending,
+		NextAttemptAt: now.Add(conf.ClaimTimeout).Unix(),
+		CreatorID:     op.UserID,
+		CreateAt:      now.Unix(),
+		UpdateAt:      now.Unix(),
+	}
+
+	// the token is stored first, a worker may pick the job up at once
+	if err := da.GetJobRedisDA().SetToken(ctx, job.ID, op.Token, conf.TokenExpiration); err != nil {
+		return "", err
+	}
+
+	if _, err := da.GetJobDA().Insert(ctx, job); err != nil {
+		log.Error(ctx, "insert job failed", log.Err(err), log.Any("job", job))
+		return "", err
+	}
+
+	if err := m.enqueue(ctx, job.ID); err != nil {
+		// the relay enqueues it later
+		log.Warn(ctx, "enqueue job failed", log.Err(err), log.String("id", job.ID))
+		if err := da.GetJobDA().Postpone(ctx, job.ID, now.Unix()); err != nil {
+			return "", err
+		}
+	}
+
+	return job.ID, nil
+}
+
+func (m *jobModel) enqueue(ctx context.Context, id string) error {
+	queue, err := mq.GetMQ(ctx)
+	if err != nil {
+		return err
+	}
+
+	return queue.Publish(ctx, jobQueueTopic, id)
+}
+
+func (m *jobModel) Consume(ctx context.Context, id string) error {
+	job := new(entity.Job)
+	err := da.GetJobDA().Get(ctx, id, job)
+	if err == dbo.ErrRecordNotFound {
+		log.Warn(ctx, "job not found", log.String("id", id))
+		return nil
+	}
+	if err != nil {
+		log.Error(ctx, "get job failed", log.Err(err), log.String("id", id))
+		return err
+	}
+
+	if job.Status != entity.JobStatusPending {
+		log.Debug(ctx, "job is not pending", log.Any("job", job))
+		return nil
+	}
+
+	now := time.Now().Unix()
+	ok, err := da.GetJobDA().MarkRunning(ctx, job.ID, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Debug(ctx, "job is taken by others", log.String("id", job.ID))
+		return nil
+	}
+	job.Status = entity.JobStatusRunning
+	job.Attempts++
+	job.StartAt = now
+
+	output, err := m.run(ctx, job)
+	if err == ErrJobCancelled {
+		log.Info(ctx, "job cancelled", log.String("id", job.ID))
+		m.deleteToken(ctx, job.ID)
+		return nil
+	}
+
+	now = time.Now().Unix()
+	if err == nil {
+		err = m.succeed(ctx, job, output, now)
+	}
+	if err != nil {
+		conf := config.Get().Job
+		maxAttempts := conf.MaxAttempts
+		if m.isPermanent(err) {
+			maxAttempts = 0
+		}
+		job.Fail(err.Error(), maxAttempts, conf.RetryBackoff, now)
+		log.Warn(ctx, "run job failed", log.Err(err), log.Any("job", job))
+	}
+
+	ok, err = da.GetJobDA().Finish(ctx, job)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Info(ctx, "job was cancelled while running", log.String("id", job.ID))
+	}
+
+	if job.Status.Finished() || !ok {
+		m.deleteToken(ctx, job.ID)
+	}
+
+	return nil
+}
+
+func (m *jobModel) isPermanent(err error) bool {
+	if _, ok := err.(*JobPermanentError); ok {
+		return true
+	}
+	return err == constant.ErrInvalidArgs || err == constant.ErrForbidden
+}
+
+func (m *jobModel) run(ctx context.Context, job *entity.Job) (output *JobOutput, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error(ctx, "run job panic", log.Any("recover error", r), log.Any("job", job))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	handler, ok := m.getHandler(job.Type)
+	if !ok {
+		return nil, &JobPermanentError{Err: fmt.Errorf("job type %v not registered", job.Type)}
+	}
+
+	token, err := da.GetJobRedisDA().GetToken(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	op := &entity.Operator{
+		UserID: job.CreatorID,
+		OrgID:  job.OrgID,
+		Token:  token,
+	}
+
+	return handler(ctx, op, []byte(job.Params), &jobReporter{jobID: job.ID})
+}
+
+func (m *jobModel) succeed(ctx context.Context, job *entity.Job, output *JobOutput, now int64) error {
+	result := ""
+	hasFile := false
+	if output != nil {
+		if output.Result != nil {
+			data, err := json.Marshal(output.Result)
+			if err != nil {
+				log.Error(ctx, "marshal job result failed", log.Err(err), log.Any("job", job))
+				return err
+			}
+			result = string(data)
+		}
+
+		if output.File != nil {
+			output.File.JobID = job.ID
+			output.File.CreateAt = now
+			// a retried job replaces the file of the last attempt
+			if err := da.GetJobFileDA().Save(ctx, output.File); err != nil {
+				log.Error(ctx, "save job file failed", log.Err(err), log.String("id", job.ID), log.String("fileName", output.File.FileName))
+				return err
+			}
+			hasFile = true
+		}
+	}
+
+	job.Succeed(result, hasFile, now)
+	return nil
+}
+
+func (m *jobModel) deleteToken(ctx context.Context, id string) {
+	if err := da.GetJobRedisDA().DeleteToken(ctx, id); err != nil {
+		log.Warn(ctx, "delete job token failed", log.Err(err), log.String("id", id))
+	}
+}
+
+func (m *jobModel) Relay(ctx context.Context) (int, error) {
+	conf := config.Get().Job
+	now := time.Now()
+
+	var stalled []*entity.Job
+	err := da.GetJobDA().Query(ctx, &da.JobCondition{
+		RunningBefore: sql.NullInt64{
+			Int64: now.Add(-conf.RunningTimeout).Unix(),
+			Valid: true,
+		},
+		OrderBy: "update_at",
+		Pager: dbo.Pager{
+			Page:     1,
+			PageSize: jobRelayBatchSize,
+		},
+	}, &stalled)
+	if err != nil {
+		log.Error(ctx, "query stalled jobs failed", log.Err(err))
+		return 0, err
+	}
+
+	for _, item := range stalled {
+		// the worker probably died, the attempt counts as failed
+		log.Warn(ctx, "job running timeout", log.Any("job", item))
+		item.Fail("running timeout", conf.MaxAttempts, conf.RetryBackoff, now.Unix())
+		if _, err := da.GetJobDA().Finish(ctx, item); err != nil {
+			log.Error(ctx, "reset stalled job failed", log.Err(err), log.String("id", item.ID))
+		}
+	}
+
+	var jobs []*entity.Job
+	err = da.GetJobDA().Query(ctx, &da.JobCondition{
+		Due: sql.NullInt64{
+			Int64: now.Unix(),
+			Valid: true,
+		},
+		OrderBy: "next_attempt_at",
+		Pager: dbo.Pager{
+			Page:     1,
+			PageSize: jobRelayBatchSize,
+		},
+	}, &jobs)
+	if err != nil {
+		log.Error(ctx, "query due jobs failed", log.Err(err))
+		return 0, err
+	}
+
+	count := 0
+	for _, item := range jobs {
+		if err := m.enqueue(ctx, item.ID); err != nil {
+			// the message queue is down, try again next round
+			log.Error(ctx, "enqueue job failed", log.Err(err), log.String("id", item.ID))
+			return count, err
+		}
+
+		if err := da.GetJobDA().Postpone(ctx, item.ID, now.Add(conf.ClaimTimeout).Unix()); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func (m *jobModel) Query(ctx context.Context, op *entity.Operator, req *entity.JobQueryReq) (*entity.JobPageReply, error) {
+	if req.Status != "" && !req.Status.Valid() {
+		log.Warn(ctx, "job status invalid", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	condition := &da.JobCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		CreatorID: sql.NullString{
+			String: op.UserID,
+			Valid:  true,
+		},
+		Type: sql.NullString{
+			String: string(req.Type),
+			Valid:  req.Type != "",
+		},
+		Status: sql.NullString{
+			String: string(req.Status),
+			Valid:  req.Status != "",
+		},
+		Pager: dbo.Pager{
+			Page:     req.PageIndex,
+			PageSize: req.PageSize,
+		},
+	}
+
+	var jobs []*entity.Job
+	total, err := da.GetJobDA().Page(ctx, condition, &jobs)
+	if err != nil {
+		log.Error(ctx, "page jobs failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	result := &entity.JobPageReply{
+		Total: total,
+		Data:  make([]*entity.JobView, 0, len(jobs)),
+	}
+	for _, item := range jobs {
+		result.Data = append(result.Data, m.convertView(item))
+	}
+
+	return result, nil
+}
+
+func (m *jobModel) Get(ctx context.Context, op *entity.Operator, id string) (*entity.JobView, error) {
+	job, err := m.getJob(ctx, op, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.convertView(job), nil
+}
+
+func (m *jobModel) Cancel(ctx context.Context, op *entity.Operator, id string) error {
+	job, err := m.getJob(ctx, op, id)
+	if err != nil {
+		return err
+	}
+
+	if job.Status.Finished() {
+		log.Warn(ctx, "job has finished", log.Any("job", job))
+		return constant.ErrInvalidArgs
+	}
+
+	ok, err := da.GetJobDA().Cancel(ctx, job.ID, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Warn(ctx, "job finished before cancelled", log.String("id", job.ID))
+		return constant.ErrInvalidArgs
+	}
+
+	// a running handler stops when it reports progress next time
+	if job.Status == entity.JobStatusPending {
+		m.deleteToken(ctx, job.ID)
+	}
+
+	return nil
+}
+
+func (m *jobModel) GetFile(ctx context.Context, op *entity.Operator, id string) (*entity.JobFile, error) {
+	job, err := m.getJob(ctx, op, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Status != entity.JobStatusSucceeded || !job.HasFile {
+		log.Warn(ctx, "job has no file", log.Any("job", job))
+		return nil, constant.ErrRecordNotFound
+	}
+
+	file := new(entity.JobFile)
+	err = da.GetJobFileDA().Get(ctx, job.ID, file)
+	if err == dbo.ErrRecordNotFound {
+		return nil, constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get job file failed", log.Err(err), log.String("id", job.ID))
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// getJob jobs are only visible to their submitter
+func (m *jobModel) getJob(ctx context.Context, op *entity.Operator, id string) (*entity.Job, error) {
+	var jobs []*entity.Job
+	err := da.GetJobDA().Query(ctx, &da.JobCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{id},
+			Valid:   true,
+		},
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		CreatorID: sql.NullString{
+			String: op.UserID,
+			Valid:  true,
+		},
+	}, &jobs)
+	if err != nil {
+		log.Error(ctx, "query job failed", log.Err(err), log.String("id", id))
+		return nil, err
+	}
+	if len(jobs) <= 0 {
+		return nil, constant.ErrRecordNotFound
+	}
+
+	return jobs[0], nil
+}
+
+func (m *jobModel) convertView(job *entity.Job) *entity.JobView {
+	return &entity.JobView{
+		ID:        job.ID,
+		Type:      job.Type,
+		Status:    job.Status,
+		Progress:  job.Progress,
+		Attempts:  job.Attempts,
+		LastError: job.LastError,
+		Result:    job.Result,
+		HasFile:   job.HasFile,
+		StartAt:   job.StartAt,
+		FinishAt:  job.FinishAt,
+		CreateAt:  job.CreateAt,
+		UpdateAt:  job.UpdateAt,
+	}
+}
+
+type jobReporter struct {
+	jobID string
+}
+
+func (r *jobReporter) Progress(ctx context.Context, done, total int) error {
+	ok, err := da.GetJobDA().UpdateProgress(ctx, r.jobID, entity.JobProgress(done, total), time.Now().Unix())
+	if err != nil {
+		// progress is informative, a failed update should not fail the job
+		log.Warn(ctx, "report job progress failed", log.Err(err), log.String("id", r.jobID))
+		return nil
+	}
+	if !ok {
+		return ErrJobCancelled
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/KL-Engineering/common-log/log"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+func publishContentBulkJob(ctx context.Context, op *entity.Operator, params []byte, reporter IJobReporter) (*JobOutput, error) {
+	req := new(entity.ContentBulkJobParams)
+	if err := json.Unmarshal(params, req); err != nil || len(req.IDs) <= 0 {
+		log.Warn(ctx, "publish content bulk job: params invalid", log.Err(err), log.String("params", string(params)))
+		return nil, &JobPermanentError{Err: ErrBadRequest}
+	}
+
+	if err := reporter.Progress(ctx, 0, len(req.IDs)); err != nil {
+		return nil, err
+	}
+
+	// contents are published in one transaction, progress jumps from 0 to done
+	err := GetContentModel().PublishContentBulkTx(ctx, req.IDs, op)
+	switch err {
+	case nil:
+	case ErrInvalidContentStatusToPublish, ErrInvalidVisibilitySetting, ErrPlanHasArchivedMaterials:
+		return nil, &JobPermanentError{Err: err}
+	default:
+		return nil, err
+	}
+
+	return &JobOutput{
+		Result: &entity.ContentBulkJobResult{Count: len(req.IDs)},
+	}, nil
+}
+
+func deleteContentBulkJob(ctx context.Context, op *entity.Operator, params []byte, reporter IJobReporter) (*JobOutput, error) {
+	req := new(entity.ContentBulkJobParams)
+	if err := json.Unmarshal(params, req); err != nil || len(req.IDs) <= 0 {
+		log.Warn(ctx, "delete content bulk job: params invalid", log.Err(err), log.String("params", string(params)))
+		return nil, &JobPermanentError{Err: ErrBadRequest}
+	}
+
+	if err := reporter.Progress(ctx, 0, len(req.IDs)); err != nil {
+		return nil, err
+	}
+
+	err := GetContentModel().DeleteContentBulkTx(ctx, req.IDs, op)
+	if _, ok := err.(*ErrContentAlreadyLocked); ok {
+		// someone has to unlock the content first
+		return nil, &JobPermanentError{Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &JobOutput{
+		Result: &entity.ContentBulkJobResult{Count: len(req.IDs)},
+	}, nil
+}
+
+func importOutcomesJob(ctx context.Context, op *entity.Operator, params []byte, reporter IJobReporter) (*JobOutput, error) {
+	req := new(entity.ImportOutcomeRequest)
+	if err := json.Unmarshal(params, req); err != nil {
+		log.Warn(ctx, "import outcomes job: params invalid", log.Err(err), log.String("params", string(params)))
+		return nil, &JobPermanentError{Err: ErrBadRequest}
+	}
+
+	if err := reporter.Progress(ctx, 0, len(req.CreateData)+len(req.UpdateData)); err != nil {
+		return nil, err
+	}
+
+	result, err := GetOutcomeModel().Import(ctx, op, req)
+	switch err {
+	case nil:
+	case ErrBadRequest:
+		return nil, &JobPermanentError{Err: err}
+	default:
+		return nil, err
+	}
+
+	return &JobOutput{
+		Result: result,
+	}, nil
+}
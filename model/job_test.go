@@ -0,0 +1,165 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+const testJobType entity.JobType = "test.job"
+
+func insertTestJob(t *testing.T, op *entity.Operator) *entity.Job {
+	now := time.Now().Unix()
+	job := &entity.Job{
+		ID:        utils.NewID(),
+		OrgID:     op.OrgID,
+		Type:      testJobType,
+		Params:    "{}",
+		Status:    entity.JobStatusPending,
+		CreatorID: op.UserID,
+		CreateAt:  now,
+		UpdateAt:  now,
+	}
+	if _, err := da.GetJobDA().Insert(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func getTestJob(t *testing.T, id string) *entity.Job {
+	job := new(entity.Job)
+	if err := da.GetJobDA().Get(context.Background(), id, job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestJobMarkRunning(t *testing.T) {
+	ctx := context.Background()
+	job := insertTestJob(t, &entity.Operator{OrgID: utils.NewID(), UserID: utils.NewID()})
+
+	ok, err := da.GetJobDA().MarkRunning(ctx, job.ID, time.Now().Unix())
+	if err != nil || !ok {
+		t.Fatalf("pending job should be marked running, got %v %v", ok, err)
+	}
+	// another worker can't take it
+	ok, err = da.GetJobDA().MarkRunning(ctx, job.ID, time.Now().Unix())
+	if err != nil || ok {
+		t.Fatalf("running job should not be marked again, got %v %v", ok, err)
+	}
+
+	job = getTestJob(t, job.ID)
+	if job.Status != entity.JobStatusRunning || job.Attempts != 1 {
+		t.Errorf("unexpected job %+v", job)
+	}
+}
+
+func TestJobFinish(t *testing.T) {
+	ctx := context.Background()
+	job := insertTestJob(t, &entity.Operator{OrgID: utils.NewID(), UserID: utils.NewID()})
+
+	// only running jobs are finished
+	job.Succeed(`{"done":true}`, false, time.Now().Unix())
+	if ok, err := da.GetJobDA().Finish(ctx, job); err != nil || ok {
+		t.Fatalf("pending job should not be finished, got %v %v", ok, err)
+	}
+
+	if _, err := da.GetJobDA().MarkRunning(ctx, job.ID, time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := da.GetJobDA().Finish(ctx, job); err != nil || !ok {
+		t.Fatalf("running job should be finished, got %v %v", ok, err)
+	}
+
+	job = getTestJob(t, job.ID)
+	if job.Status != entity.JobStatusSucceeded || job.Progress != 100 || job.Result != `{"done":true}` {
+		t.Errorf("unexpected job %+v", job)
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	ctx := context.Background()
+	op := &entity.Operator{OrgID: utils.NewID(), UserID: utils.NewID()}
+	m := &jobModel{handlers: make(map[entity.JobType]JobHandler)}
+
+	pending := insertTestJob(t, op)
+	if err := m.Cancel(ctx, op, pending.ID); err != nil {
+		t.Fatal(err)
+	}
+	if job := getTestJob(t, pending.ID); job.Status != entity.JobStatusCancelled || job.FinishAt == 0 {
+		t.Errorf("unexpected job %+v", job)
+	}
+	if err := m.Cancel(ctx, op, pending.ID); err != constant.ErrInvalidArgs {
+		t.Errorf("cancelled job should not be cancelled again, got %v", err)
+	}
+
+	// a running job cancelled meanwhile keeps the cancellation when it finishes
+	running := insertTestJob(t, op)
+	if _, err := da.GetJobDA().MarkRunning(ctx, running.ID, time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Cancel(ctx, op, running.ID); err != nil {
+		t.Fatal(err)
+	}
+	running.Succeed("", false, time.Now().Unix())
+	if ok, err := da.GetJobDA().Finish(ctx, running); err != nil || ok {
+		t.Fatalf("cancelled job should not be finished, got %v %v", ok, err)
+	}
+	if job := getTestJob(t, running.ID); job.Status != entity.JobStatusCancelled {
+		t.Errorf("unexpected job %+v", job)
+	}
+
+	// jobs of others are not found
+	other := insertTestJob(t, &entity.Operator{OrgID: op.OrgID, UserID: utils.NewID()})
+	if err := m.Cancel(ctx, op, other.ID); err != constant.ErrRecordNotFound {
+		t.Errorf("job of another user should not be found, got %v", err)
+	}
+}
+
+func TestJobConsume(t *testing.T) {
+	ctx := context.Background()
+	op := &entity.Operator{OrgID: utils.NewID(), UserID: utils.NewID()}
+	m := &jobModel{handlers: make(map[entity.JobType]JobHandler)}
+
+	maxAttempts := config.Get().Job.MaxAttempts
+	config.Get().Job.MaxAttempts = 3
+	defer func() { config.Get().Job.MaxAttempts = maxAttempts }()
+
+	runs := 0
+	m.Register(testJobType, func(ctx context.Context, op *entity.Operator, params []byte, reporter IJobReporter) (*JobOutput, error) {
+		runs++
+		if runs == 1 {
+			return nil, errors.New("transient")
+		}
+		return &JobOutput{Result: map[string]int{"runs": runs}}, nil
+	})
+
+	job := insertTestJob(t, op)
+	if err := m.Consume(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	job = getTestJob(t, job.ID)
+	if job.Status != entity.JobStatusPending || job.Attempts != 1 || job.LastError != "transient" {
+		t.Fatalf("failed job should be retried, got %+v", job)
+	}
+
+	if err := m.Consume(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	job = getTestJob(t, job.ID)
+	if job.Status != entity.JobStatusSucceeded || job.Attempts != 2 || job.Result != `{"runs":2}` {
+		t.Fatalf("retried job should succeed, got %+v", job)
+	}
+
+	// finished jobs are not run again
+	if err := m.Consume(ctx, job.ID); err != nil || runs != 2 {
+		t.Errorf("finished job should not run, got %v after %d runs", err, runs)
+	}
+}
@@ -190,6 +190,10 @@ func (m MilestoneModel) Update(ctx context.Context, op *entity.Operator, perms m
 	locker.Lock()
 	defer locker.Unlock()
 	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		err := increaseRevisionTx(ctx, tx, entity.MilestoneTable, milestone.ID)
+		if err != nil {
+			return err
+		}
 		oldMilestone, err := da.GetMilestoneDA().GetByID(ctx, tx, milestone.ID)
 		if err != nil {
 			log.Error(ctx, "Update: GetByID failed",
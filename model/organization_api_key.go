@@ -0,0 +1,221 @@
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+const (
+	organizationAPIKeyPrefix       = "klk_"
+	organizationAPIKeyDisplayLen   = 12
+	organizationAPIKeyTouchSeconds = 60
+)
+
+var (
+	organizationAPIKeyModelInstance     IOrganizationAPIKeyModel
+	organizationAPIKeyModelInstanceOnce = sync.Once{}
+)
+
+type IOrganizationAPIKeyModel interface {
+	Add(ctx context.Context, op *entity.Operator, req *entity.OrganizationAPIKeyAddReq) (*entity.OrganizationAPIKeyCreatedReply, error)
+	Query(ctx context.Context, op *entity.Operator) ([]*entity.OrganizationAPIKeyReply, error)
+	Delete(ctx context.Context, op *entity.Operator, id string) error
+
+	// Authenticate returns an operator of the key's organization, the operator has no user and no token
+	Authenticate(ctx context.Context, key string) (*entity.Operator, error)
+}
+
+type organizationAPIKeyModel struct{}
+
+func GetOrganizationAPIKeyModel() IOrganizationAPIKeyModel {
+	organizationAPIKeyModelInstanceOnce.Do(func() {
+		organizationAPIKeyModelInstance = &organizationAPIKeyModel{}
+	})
+	return organizationAPIKeyModelInstance
+}
+
+// checkPermission api keys act for the whole organization, only org admins can manage them
+func (m *organizationAPIKeyModel) checkPermission(ctx context.Context, op *entity.Operator) error {
+	isAllow, err := external.GetPermissionServiceProvider().HasOrganizationPermission(ctx, op, external.ManageOrganizationAPIKeys10909)
+	if err != nil {
+		log.Error(ctx, "check permission 10909 failed", log.Err(err), log.Any("operator", op))
+		return err
+	}
+	if !isAllow {
+		log.Warn(ctx, "user has no permission to manage api keys", log.Any("operator", op))
+		return constant.ErrForbidden
+	}
+
+	return nil
+}
+
+func (m *organizationAPIKeyModel) Add(ctx context.Context, op *entity.Operator, req *entity.OrganizationAPIKeyAddReq) (*entity.OrganizationAPIKeyCreatedReply, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		log.Warn(ctx, "api key name is empty", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op); err != nil {
+		return nil, err
+	}
+
+	key, err := m.generateKey()
+	if err != nil {
+		log.Error(ctx, "generate api key failed", log.Err(err))
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	apiKey := &entity.OrganizationAPIKey{
+		ID:        utils.NewID(),
+		OrgID:     op.OrgID,
+		Name:      name,
+		KeyPrefix: key[:organizationAPIKeyDisplayLen],
+		KeyHash:   m.hashKey(key),
+		CreatorID: op.UserID,
+		CreateAt:  now,
+		UpdateAt:  now,
+	}
+	if _, err := da.GetOrganizationAPIKeyDA().Insert(ctx, apiKey); err != nil {
+		log.Error(ctx, "insert api key failed", log.Err(err), log.String("name", name))
+		return nil, err
+	}
+
+	return &entity.OrganizationAPIKeyCreatedReply{
+		OrganizationAPIKeyReply: m.convertReply(apiKey),
+		Key:                     key,
+	}, nil
+}
+
+func (m *organizationAPIKeyModel) Query(ctx context.Context, op *entity.Operator) ([]*entity.OrganizationAPIKeyReply, error) {
+	if err := m.checkPermission(ctx, op); err != nil {
+		return nil, err
+	}
+
+	var apiKeys []*entity.OrganizationAPIKey
+	err := da.GetOrganizationAPIKeyDA().Query(ctx, &da.OrganizationAPIKeyCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+	}, &apiKeys)
+	if err != nil {
+		log.Error(ctx, "query api keys failed", log.Err(err), log.Any("operator", op))
+		return nil, err
+	}
+
+	result := make([]*entity.OrganizationAPIKeyReply, 0, len(apiKeys))
+	for _, item := range apiKeys {
+		reply := m.convertReply(item)
+		result = append(result, &reply)
+	}
+
+	return result, nil
+}
+
+func (m *organizationAPIKeyModel) Delete(ctx context.Context, op *entity.Operator, id string) error {
+	if err := m.checkPermission(ctx, op); err != nil {
+		return err
+	}
+
+	var apiKeys []*entity.OrganizationAPIKey
+	err := da.GetOrganizationAPIKeyDA().Query(ctx, &da.OrganizationAPIKeyCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{id},
+			Valid:   true,
+		},
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+	}, &apiKeys)
+	if err != nil {
+		log.Error(ctx, "query api key failed", log.Err(err), log.String("id", id))
+		return err
+	}
+	if len(apiKeys) <= 0 {
+		return constant.ErrRecordNotFound
+	}
+
+	apiKey := apiKeys[0]
+	apiKey.DeleteAt = time.Now().Unix()
+	if _, err := da.GetOrganizationAPIKeyDA().Update(ctx, apiKey); err != nil {
+		log.Error(ctx, "delete api key failed", log.Err(err), log.String("id", id))
+		return err
+	}
+
+	return nil
+}
+
+func (m *organizationAPIKeyModel) Authenticate(ctx context.Context, key string) (*entity.Operator, error) {
+	if !strings.HasPrefix(key, organizationAPIKeyPrefix) {
+		return nil, constant.ErrUnAuthorized
+	}
+
+	var apiKeys []*entity.OrganizationAPIKey
+	err := da.GetOrganizationAPIKeyDA().Query(ctx, &da.OrganizationAPIKeyCondition{
+		KeyHash: sql.NullString{
+			String: m.hashKey(key),
+			Valid:  true,
+		},
+	}, &apiKeys)
+	if err != nil {
+		log.Error(ctx, "query api key by hash failed", log.Err(err))
+		return nil, err
+	}
+	if len(apiKeys) <= 0 {
+		log.Info(ctx, "api key not found")
+		return nil, constant.ErrUnAuthorized
+	}
+
+	apiKey := apiKeys[0]
+	now := time.Now().Unix()
+	if now-apiKey.LastUsedAt > organizationAPIKeyTouchSeconds {
+		if err := da.GetOrganizationAPIKeyDA().UpdateLastUsedAt(ctx, apiKey.ID, now); err != nil {
+			log.Warn(ctx, "update api key last used time failed", log.Err(err), log.String("id", apiKey.ID))
+		}
+	}
+
+	return &entity.Operator{
+		OrgID: apiKey.OrgID,
+	}, nil
+}
+
+func (m *organizationAPIKeyModel) generateKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return organizationAPIKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+func (m *organizationAPIKeyModel) hashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func (m *organizationAPIKeyModel) convertReply(item *entity.OrganizationAPIKey) entity.OrganizationAPIKeyReply {
+	return entity.OrganizationAPIKeyReply{
+		ID:         item.ID,
+		Name:       item.Name,
+		KeyPrefix:  item.KeyPrefix,
+		CreatorID:  item.CreatorID,
+		LastUsedAt: item.LastUsedAt,
+		CreateAt:   item.CreateAt,
+	}
+}
@@ -358,6 +358,10 @@ func (ocm OutcomeModel) Update(ctx context.Context, operator *entity.Operator, o
 	locker.Lock()
 	defer locker.Unlock()
 	err = dbo.GetTrans(ctx, func(cxt context.Context, tx *dbo.DBContext) error {
+		err := increaseRevisionTx(ctx, tx, entity.OutcomeTable, outcome.ID)
+		if err != nil {
+			return err
+		}
 		data, err := da.GetOutcomeDA().GetOutcomeByID(ctx, tx, outcome.ID)
 		if err == dbo.ErrRecordNotFound {
 			return ErrResourceNotFound
@@ -448,6 +452,10 @@ func (ocm OutcomeModel) Delete(ctx context.Context, operator *entity.Operator, o
 		return err
 	}
 	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		err := increaseRevisionTx(ctx, tx, entity.OutcomeTable, outcomeID)
+		if err != nil {
+			return err
+		}
 		outcome, err := da.GetOutcomeDA().GetOutcomeByID(ctx, tx, outcomeID)
 		if err != nil && err != dbo.ErrRecordNotFound {
 			log.Error(ctx, "Delete: no permission",
@@ -1462,6 +1470,7 @@ func (ocm OutcomeModel) Approve(ctx context.Context, operator *entity.Operator,
 				log.String("outcome_id", outcomeID))
 			return err
 		}
+		AuditBefore(ctx, outcome.ID, outcome)
 		err = ocm.SetStatus(ctx, outcome, entity.OutcomeStatusPublished)
 		if err != nil {
 			log.Error(ctx, "Approve: SetStatus failed",
@@ -1479,6 +1488,7 @@ func (ocm OutcomeModel) Approve(ctx context.Context, operator *entity.Operator,
 				log.Any("outcome", outcome))
 			return err
 		}
+		AuditAfter(ctx, outcome.ID, outcome)
 		err = ocm.hideParent(ctx, operator, tx, outcome)
 		if err != nil {
 			log.Error(ctx, "Approve: hideParent failed",
@@ -1493,6 +1503,7 @@ func (ocm OutcomeModel) Approve(ctx context.Context, operator *entity.Operator,
 				log.Any("outcome", outcome))
 			return err
 		}
+		GetWebhookModel().EmitTx(ctx, tx, operator, entity.WebhookEventOutcomeApproved, newWebhookOutcomeData(outcome))
 		// NKL-1021
 		// err = GetMilestoneModel().BindToGeneral(ctx, operator, tx, outcome)
 		// if err != nil {
@@ -1531,6 +1542,7 @@ func (ocm OutcomeModel) Reject(ctx context.Context, operator *entity.Operator, o
 				log.String("outcome_id", outcomeID))
 			return err
 		}
+		AuditBefore(ctx, outcome.ID, outcome)
 		err = ocm.SetStatus(ctx, outcome, entity.OutcomeStatusRejected)
 		outcome.RejectReason = reason
 		if err != nil {
@@ -1546,6 +1558,7 @@ func (ocm OutcomeModel) Reject(ctx context.Context, operator *entity.Operator, o
 				log.Any("outcome", outcome))
 			return err
 		}
+		AuditAfter(ctx, outcome.ID, outcome)
 		return nil
 	})
 	return err
@@ -1610,6 +1623,7 @@ func (ocm OutcomeModel) BulkApprove(ctx context.Context, operator *entity.Operat
 					log.Any("outcome", outcome))
 				return err
 			}
+			GetWebhookModel().EmitTx(ctx, tx, operator, entity.WebhookEventOutcomeApproved, newWebhookOutcomeData(outcome))
 			// NKL-1021
 			// err = GetMilestoneModel().BindToGeneral(ctx, operator, tx, outcome)
 			// if err != nil {
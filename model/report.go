@@ -1701,6 +1701,7 @@ func (m *reportModel) GetClassWidget(ctx context.Context, op *entity.Operator, r
 					v2.AssessmentStatusNotStarted.String(),
 					v2.AssessmentStatusStarted.String(),
 					v2.AssessmentStatusInDraft.String(),
+					v2.AssessmentStatusPendingModeration.String(),
 				},
 				Valid: true,
 			},
@@ -0,0 +1,481 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+type IReportBuilderModel interface {
+	Metadata(ctx context.Context) *entity.ReportBuilderMetadata
+	// Query run the query over the classes, and teachers if used, the user can view reports of
+	Query(ctx context.Context, op *entity.Operator, query *entity.ReportBuilderQuery) (*entity.ReportBuilderResult, error)
+	// Run run a saved definition as the user
+	Run(ctx context.Context, op *entity.Operator, id string) (*entity.ReportBuilderResult, error)
+
+	AddDefinition(ctx context.Context, op *entity.Operator, req *entity.ReportDefinitionAddReq) (*entity.ReportDefinitionView, error)
+	UpdateDefinition(ctx context.Context, op *entity.Operator, req *entity.ReportDefinitionUpdateReq) error
+	DeleteDefinition(ctx context.Context, op *entity.Operator, id string) error
+	GetDefinition(ctx context.Context, op *entity.Operator, id string) (*entity.ReportDefinitionView, error)
+	// QueryDefinitions definitions of the user and definitions shared in the organization
+	QueryDefinitions(ctx context.Context, op *entity.Operator, req *entity.ReportDefinitionQueryReq) (*entity.ReportDefinitionPageReply, error)
+}
+
+type reportBuilderModel struct{}
+
+var (
+	_reportBuilderModelOnce sync.Once
+	_reportBuilderModel     IReportBuilderModel
+)
+
+func GetReportBuilderModel() IReportBuilderModel {
+	_reportBuilderModelOnce.Do(func() {
+		_reportBuilderModel = &reportBuilderModel{}
+	})
+	return _reportBuilderModel
+}
+
+var (
+	reportBuilderClassPermissions = external.TeacherViewPermissionParams{
+		ViewOrgReports:    external.ReportStudentProgressReportOrganization,
+		ViewSchoolReports: external.ReportStudentProgressReportSchool,
+		ViewMyReports:     external.ReportStudentProgressReportTeacher,
+	}
+	reportBuilderTeacherPermissions = external.TeacherViewPermissionParams{
+		ViewOrgReports:    external.ReportOrganizationTeachingLoad617,
+		ViewSchoolReports: external.ReportSchoolTeachingLoad618,
+		ViewMyReports:     external.ReportMyTeachingLoad619,
+	}
+)
+
+// checkPermission the report builder is for users viewing student progress reports
+func (m *reportBuilderModel) checkPermission(ctx context.Context, op *entity.Operator) error {
+	permissions := []external.PermissionName{
+		reportBuilderClassPermissions.ViewOrgReports,
+		reportBuilderClassPermissions.ViewSchoolReports,
+		reportBuilderClassPermissions.ViewMyReports,
+	}
+	perms, err := external.GetPermissionServiceProvider().HasOrganizationPermissions(ctx, op, permissions)
+	if err != nil {
+		log.Error(ctx, "check report builder permission failed", log.Err(err), log.Any("operator", op))
+		return err
+	}
+
+	for _, permission := range permissions {
+		if perms[permission] {
+			return nil
+		}
+	}
+
+	log.Warn(ctx, "user has no report builder permission", log.Any("operator", op))
+	return constant.ErrForbidden
+}
+
+func (m *reportBuilderModel) Metadata(ctx context.Context) *entity.ReportBuilderMetadata {
+	return &entity.ReportBuilderMetadata{
+		Dimensions: entity.ReportBuilderDimensions,
+		Metrics:    entity.ReportBuilderMetrics,
+		TimeGrains: []entity.ReportBuilderTimeGrain{
+			entity.ReportBuilderTimeGrainDay,
+			entity.ReportBuilderTimeGrainWeek,
+			entity.ReportBuilderTimeGrainMonth,
+		},
+	}
+}
+
+func (m *reportBuilderModel) Query(ctx context.Context, op *entity.Operator, query *entity.ReportBuilderQuery) (*entity.ReportBuilderResult, error) {
+	if query == nil || !query.Valid() {
+		log.Warn(ctx, "invalid report builder query", log.Any("query", query))
+		return nil, constant.ErrInvalidArgs
+	}
+	periods := query.Periods(config.Get().ReportRollup.TimeZoneOffset)
+	if len(periods) > constant.ReportBuilderMaxPeriods {
+		log.Warn(ctx, "too many periods of report builder query", log.Any("query", query), log.Int("periods", len(periods)))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op); err != nil {
+		return nil, err
+	}
+
+	result := &entity.ReportBuilderResult{
+		Columns: m.columns(query),
+		Rows:    []map[string]interface{}{},
+	}
+
+	classIDs, err := GetReportModel().GetClassIDsCanViewReports(ctx, op, reportBuilderClassPermissions)
+	if err != nil {
+		log.Error(ctx, "get report builder classes failed", log.Err(err), log.Any("operator", op))
+		return nil, err
+	}
+	if len(classIDs) == 0 {
+		return result, nil
+	}
+	var teacherIDs entity.NullStrings
+	if query.HasDimension(entity.ReportBuilderDimensionTeacher) {
+		teacherIDs.Strings, err = GetReportModel().GetTeacherIDsCanViewReports(ctx, op, reportBuilderTeacherPermissions)
+		if err != nil {
+			log.Error(ctx, "get report builder teachers failed", log.Err(err), log.Any("operator", op))
+			return nil, err
+		}
+		if len(teacherIDs.Strings) == 0 {
+			return result, nil
+		}
+		teacherIDs.Valid = true
+	}
+
+	rows := make(map[string]map[string]interface{})
+	var keys []string
+	for _, source := range query.Sources() {
+		sourceRows, err := da.GetReportDA().QueryReportBuilderRows(ctx, &entity.ReportBuilderCondition{
+			Source:     source,
+			OrgID:      op.OrgID,
+			Query:      query,
+			Periods:    periods,
+			ClassIDs:   classIDs,
+			TeacherIDs: teacherIDs,
+			Limit:      constant.ReportBuilderMaxRows + 1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(sourceRows) > constant.ReportBuilderMaxRows {
+			result.Truncated = true
+		}
+
+		for _, sourceRow := range sourceRows {
+			key := m.key(query, sourceRow)
+			row, ok := rows[key]
+			if !ok {
+				row = m.newRow(query, sourceRow)
+				rows[key] = row
+				keys = append(keys, key)
+			}
+			for _, metric := range query.Metrics {
+				if metric.Source() == source {
+					row[string(metric)] = sourceRow.Metric(metric)
+				}
+			}
+		}
+	}
+
+	for _, key := range keys {
+		result.Rows = append(result.Rows, rows[key])
+	}
+	m.sort(query, result.Rows)
+	if len(result.Rows) > constant.ReportBuilderMaxRows {
+		result.Rows = result.Rows[:constant.ReportBuilderMaxRows]
+		result.Truncated = true
+	}
+
+	if err := m.fillNames(ctx, op, query, result.Rows); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (m *reportBuilderModel) columns(query *entity.ReportBuilderQuery) []string {
+	var columns []string
+	for _, dimension := range query.Dimensions {
+		columns = append(columns, string(dimension))
+		if dimension != entity.ReportBuilderDimensionPeriod {
+			columns = append(columns, entity.ReportBuilderNameColumn(dimension))
+		}
+	}
+	for _, metric := range query.Metrics {
+		columns = append(columns, string(metric))
+	}
+	return columns
+}
+
+func (m *reportBuilderModel) key(query *entity.ReportBuilderQuery, row *entity.ReportBuilderRow) string {
+	values := make([]string, len(query.Dimensions))
+	for i, dimension := range query.Dimensions {
+		values[i] = fmt.Sprint(row.Dimension(dimension))
+	}
+	return strings.Join(values, "\x00")
+}
+
+// newRow row of the dimensions, metrics of other sources are zero until merged
+func (m *reportBuilderModel) newRow(query *entity.ReportBuilderQuery, sourceRow *entity.ReportBuilderRow) map[string]interface{} {
+	empty := &entity.ReportBuilderRow{}
+	row := make(map[string]interface{}, len(query.Dimensions)*2+len(query.Metrics))
+	for _, dimension := range query.Dimensions {
+		row[string(dimension)] = sourceRow.Dimension(dimension)
+	}
+	for _, metric := range query.Metrics {
+		row[string(metric)] = empty.Metric(metric)
+	}
+	return row
+}
+
+// sort by the dimensions in their order, like the rows of every source
+func (m *reportBuilderModel) sort(query *entity.ReportBuilderQuery, rows []map[string]interface{}) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, dimension := range query.Dimensions {
+			switch a := rows[i][string(dimension)].(type) {
+			case int64:
+				if b := rows[j][string(dimension)].(int64); a != b {
+					return a < b
+				}
+			case string:
+				if b := rows[j][string(dimension)].(string); a != b {
+					return a < b
+				}
+			}
+		}
+		return false
+	})
+}
+
+// fillNames names of the dimensions, ids without a name are kept as their names
+func (m *reportBuilderModel) fillNames(ctx context.Context, op *entity.Operator, query *entity.ReportBuilderQuery, rows []map[string]interface{}) error {
+	for _, dimension := range query.Dimensions {
+		if dimension == entity.ReportBuilderDimensionPeriod {
+			continue
+		}
+
+		var ids []string
+		seen := make(map[string]bool)
+		for _, row := range rows {
+			id := row[string(dimension)].(string)
+			if id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+
+		names, err := m.names(ctx, op, dimension, ids)
+		if err != nil {
+			log.Error(ctx, "get report builder names failed", log.Err(err), log.String("dimension", string(dimension)), log.Strings("ids", ids))
+			return err
+		}
+
+		column := entity.ReportBuilderNameColumn(dimension)
+		for _, row := range rows {
+			id := row[string(dimension)].(string)
+			name, ok := names[id]
+			if !ok {
+				name = id
+			}
+			row[column] = name
+		}
+	}
+	return nil
+}
+
+func (m *reportBuilderModel) names(ctx context.Context, op *entity.Operator, dimension entity.ReportBuilderDimension, ids []string) (map[string]string, error) {
+	if len(ids) == 0 {
+		return map[string]string{}, nil
+	}
+
+	switch dimension {
+	case entity.ReportBuilderDimensionOrg:
+		return external.GetOrganizationServiceProvider().BatchGetNameMap(ctx, op, ids)
+	case entity.ReportBuilderDimensionSchool:
+		return external.GetSchoolServiceProvider().BatchGetNameMap(ctx, op, ids)
+	case entity.ReportBuilderDimensionClass:
+		return external.GetClassServiceProvider().BatchGetNameMap(ctx, op, ids)
+	case entity.ReportBuilderDimensionTeacher:
+		return external.GetTeacherServiceProvider().BatchGetNameMap(ctx, op, ids)
+	case entity.ReportBuilderDimensionStudent:
+		return external.GetStudentServiceProvider().BatchGetNameMap(ctx, op, ids)
+	case entity.ReportBuilderDimensionSubject:
+		return external.GetSubjectServiceProvider().BatchGetNameMap(ctx, op, ids)
+	case entity.ReportBuilderDimensionProgram:
+		return external.GetProgramServiceProvider().BatchGetNameMap(ctx, op, ids)
+	case entity.ReportBuilderDimensionLessonPlan:
+		contents, err := GetContentModel().GetContentNameByIDList(ctx, dbo.MustGetDB(ctx), ids)
+		if err != nil {
+			return nil, err
+		}
+		names := make(map[string]string, len(contents))
+		for _, content := range contents {
+			names[content.ID] = content.Name
+		}
+		return names, nil
+	}
+	return map[string]string{}, nil
+}
+
+func (m *reportBuilderModel) Run(ctx context.Context, op *entity.Operator, id string) (*entity.ReportBuilderResult, error) {
+	definition, err := m.getDefinition(ctx, op, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	query := new(entity.ReportBuilderQuery)
+	if err := json.Unmarshal([]byte(definition.Query), query); err != nil {
+		log.Error(ctx, "unmarshal report definition query failed", log.Err(err), log.String("id", id))
+		return nil, err
+	}
+	return m.Query(ctx, op, query)
+}
+
+func (m *reportBuilderModel) AddDefinition(ctx context.Context, op *entity.Operator, req *entity.ReportDefinitionAddReq) (*entity.ReportDefinitionView, error) {
+	if !req.Valid() {
+		log.Warn(ctx, "invalid report definition", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+	if err := m.checkPermission(ctx, op); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	definition := &entity.ReportDefinition{
+		ID:        utils.NewID(),
+		OrgID:     op.OrgID,
+		CreatorID: op.UserID,
+		CreateAt:  now,
+	}
+	if err := m.apply(definition, req, now); err != nil {
+		return nil, err
+	}
+	if _, err := da.GetReportDefinitionDA().Insert(ctx, definition); err != nil {
+		log.Error(ctx, "insert report definition failed", log.Err(err), log.Any("definition", definition))
+		return nil, err
+	}
+
+	return entity.NewReportDefinitionView(definition, op.UserID), nil
+}
+
+func (m *reportBuilderModel) apply(definition *entity.ReportDefinition, req *entity.ReportDefinitionAddReq, now int64) error {
+	query, err := json.Marshal(req.Query)
+	if err != nil {
+		return err
+	}
+	definition.Name = strings.TrimSpace(req.Name)
+	definition.Description = req.Description
+	definition.Query = string(query)
+	definition.Shared = req.Shared
+	definition.UpdateAt = now
+	return nil
+}
+
+func (m *reportBuilderModel) UpdateDefinition(ctx context.Context, op *entity.Operator, req *entity.ReportDefinitionUpdateReq) error {
+	if !req.Valid() {
+		log.Warn(ctx, "invalid report definition", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	definition, err := m.getDefinition(ctx, op, req.ID, true)
+	if err != nil {
+		return err
+	}
+
+	if err := m.apply(definition, &req.ReportDefinitionAddReq, time.Now().Unix()); err != nil {
+		return err
+	}
+	if _, err := da.GetReportDefinitionDA().Update(ctx, definition); err != nil {
+		log.Error(ctx, "update report definition failed", log.Err(err), log.Any("definition", definition))
+		return err
+	}
+	return nil
+}
+
+func (m *reportBuilderModel) DeleteDefinition(ctx context.Context, op *entity.Operator, id string) error {
+	definition, err := m.getDefinition(ctx, op, id, true)
+	if err != nil {
+		return err
+	}
+
+	definition.DeleteAt = time.Now().Unix()
+	if _, err := da.GetReportDefinitionDA().Update(ctx, definition); err != nil {
+		log.Error(ctx, "delete report definition failed", log.Err(err), log.String("id", id))
+		return err
+	}
+	return nil
+}
+
+func (m *reportBuilderModel) GetDefinition(ctx context.Context, op *entity.Operator, id string) (*entity.ReportDefinitionView, error) {
+	definition, err := m.getDefinition(ctx, op, id, false)
+	if err != nil {
+		return nil, err
+	}
+	return entity.NewReportDefinitionView(definition, op.UserID), nil
+}
+
+func (m *reportBuilderModel) QueryDefinitions(ctx context.Context, op *entity.Operator, req *entity.ReportDefinitionQueryReq) (*entity.ReportDefinitionPageReply, error) {
+	condition := &da.ReportDefinitionCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		Visible: sql.NullString{
+			String: op.UserID,
+			Valid:  true,
+		},
+		Name: sql.NullString{
+			String: req.Name,
+			Valid:  req.Name != "",
+		},
+		Pager: dbo.Pager{
+			Page:     req.PageIndex,
+			PageSize: req.PageSize,
+		},
+	}
+
+	var definitions []*entity.ReportDefinition
+	total, err := da.GetReportDefinitionDA().Page(ctx, condition, &definitions)
+	if err != nil {
+		log.Error(ctx, "page report definitions failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	result := &entity.ReportDefinitionPageReply{
+		Total: total,
+		Data:  make([]*entity.ReportDefinitionView, 0, len(definitions)),
+	}
+	for _, definition := range definitions {
+		result.Data = append(result.Data, entity.NewReportDefinitionView(definition, op.UserID))
+	}
+	return result, nil
+}
+
+// getDefinition shared definitions are visible to the organization, only their creators edit them
+func (m *reportBuilderModel) getDefinition(ctx context.Context, op *entity.Operator, id string, edit bool) (*entity.ReportDefinition, error) {
+	var definitions []*entity.ReportDefinition
+	condition := &da.ReportDefinitionCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{id},
+			Valid:   true,
+		},
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		Visible: sql.NullString{
+			String: op.UserID,
+			Valid:  true,
+		},
+		Pager: dbo.NoPager,
+	}
+	if err := da.GetReportDefinitionDA().Query(ctx, condition, &definitions); err != nil {
+		log.Error(ctx, "query report definition failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+	if len(definitions) == 0 {
+		return nil, constant.ErrRecordNotFound
+	}
+
+	if edit && definitions[0].CreatorID != op.UserID {
+		log.Warn(ctx, "user is not the creator of the report definition", log.Any("operator", op), log.String("id", id))
+		return nil, constant.ErrForbidden
+	}
+	return definitions[0], nil
+}
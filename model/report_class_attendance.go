@@ -17,7 +17,7 @@ func (m *reportModel) ClassAttendanceStatistics(ctx context.Context, op *entity.
 		queryParameters.Duration = duration
 		//step1-query condition all subject
 		queryParameters.SubjectIDS = append(request.SelectedSubjectIDList, request.UnSelectedSubjectIDList...)
-		classAttendanceList, err := da.GetReportDA().GetClassAttendance(ctx, queryParameters)
+		classAttendanceCounts, err := m.getClassAttendanceCounts(ctx, op, queryParameters)
 		if err != nil {
 			return nil, err
 		}
@@ -36,33 +36,27 @@ func (m *reportModel) ClassAttendanceStatistics(ctx context.Context, op *entity.
 		var classSelectSubjectAttendanceTotalRate float64
 		var studentAttendanceCount int
 		var studentScheduleCount int
-		for _, classAttendance := range classAttendanceList {
+		for _, classAttendance := range classAttendanceCounts {
 			for _, selectSubject := range request.SelectedSubjectIDList {
 				//statistical the selected subjects of students in this class
 				if selectSubject == classAttendance.SubjectID {
-					classSelectSubjectTotalMap[classAttendance.SubjectID] = classSelectSubjectTotalMap[classAttendance.SubjectID] + 1
-					if classAttendance.IsAttendance {
-						classSelectSubjectAttendanceTotalMap[classAttendance.SubjectID] = classSelectSubjectAttendanceTotalMap[classAttendance.SubjectID] + 1
-					}
+					classSelectSubjectTotalMap[classAttendance.SubjectID] += classAttendance.Lessons
+					classSelectSubjectAttendanceTotalMap[classAttendance.SubjectID] += classAttendance.Attended
 				}
 
 				//statistical the selected subjects of this student
 				if classAttendance.StudentID == request.StudentID && selectSubject == classAttendance.SubjectID {
-					studentScheduleCount = studentScheduleCount + 1
-					studentSelectSubjectTotalMap[classAttendance.SubjectID] = studentSelectSubjectTotalMap[classAttendance.SubjectID] + 1
-					if classAttendance.IsAttendance {
-						studentAttendanceCount = studentAttendanceCount + 1
-						studentSelectSubjectAttendanceTotalMap[classAttendance.SubjectID] = studentSelectSubjectAttendanceTotalMap[classAttendance.SubjectID] + 1
-					}
+					studentScheduleCount += classAttendance.Lessons
+					studentSelectSubjectTotalMap[classAttendance.SubjectID] += classAttendance.Lessons
+					studentAttendanceCount += classAttendance.Attended
+					studentSelectSubjectAttendanceTotalMap[classAttendance.SubjectID] += classAttendance.Attended
 				}
 			}
 			//statistical the unselected subjects of this student
 			for _, unSelectSubject := range request.UnSelectedSubjectIDList {
 				if classAttendance.StudentID == request.StudentID && unSelectSubject == classAttendance.SubjectID {
-					studentUnSelectSubjectTotalMap[classAttendance.SubjectID] = studentUnSelectSubjectTotalMap[classAttendance.SubjectID] + 1
-					if classAttendance.IsAttendance {
-						studentUnSelectSubjectAttendanceTotalMap[classAttendance.SubjectID] = studentUnSelectSubjectAttendanceTotalMap[classAttendance.SubjectID] + 1
-					}
+					studentUnSelectSubjectTotalMap[classAttendance.SubjectID] += classAttendance.Lessons
+					studentUnSelectSubjectAttendanceTotalMap[classAttendance.SubjectID] += classAttendance.Attended
 				}
 			}
 		}
@@ -222,3 +216,31 @@ func getAbverseSub(data1, data2, data3 *entity.ClassAttendanceResponseItem) (res
 func getSubResult(data1, data2 *entity.ClassAttendanceResponseItem) (result float64) {
 	return (data1.AttendancePercentage - data2.AttendancePercentage) * 100
 }
+
+// getClassAttendanceCounts read from the rollups when they are fresh for the duration, otherwise count the live records
+func (m *reportModel) getClassAttendanceCounts(ctx context.Context, op *entity.Operator, queryParameters *entity.ClassAttendanceQueryParameters) ([]*entity.ClassAttendanceCount, error) {
+	if period, startAt, endAt, ok := GetReportRollupModel().Fresh(ctx, op.OrgID, queryParameters.Duration); ok {
+		return da.GetReportRollupDA().GetClassAttendanceCounts(ctx, period, op.OrgID, startAt, endAt, queryParameters.ClassID, queryParameters.SubjectIDS)
+	}
+
+	classAttendanceList, err := da.GetReportDA().GetClassAttendance(ctx, queryParameters)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]*entity.ClassAttendanceCount, 0, len(classAttendanceList))
+	index := make(map[[2]string]*entity.ClassAttendanceCount)
+	for _, classAttendance := range classAttendanceList {
+		key := [2]string{classAttendance.StudentID, classAttendance.SubjectID}
+		count, ok := index[key]
+		if !ok {
+			count = &entity.ClassAttendanceCount{StudentID: classAttendance.StudentID, SubjectID: classAttendance.SubjectID}
+			index[key] = count
+			counts = append(counts, count)
+		}
+		count.Lessons++
+		if classAttendance.IsAttendance {
+			count.Attended++
+		}
+	}
+	return counts, nil
+}
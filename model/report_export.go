@@ -0,0 +1,196 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/model/storage"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+type IReportExportModel interface {
+	// Render the table as a file of the format
+	Render(ctx context.Context, format entity.ReportExportFormat, fileName string, table *entity.ReportTable) (*entity.JobFile, error)
+	// Save upload the file of an export job to the storage
+	Save(ctx context.Context, op *entity.Operator, file *entity.JobFile, rows int) (*entity.ReportExportJobResult, error)
+	// GetDownload temporary link of the file of an export job submitted by the operator
+	GetDownload(ctx context.Context, op *entity.Operator, jobID string) (*entity.ReportExportDownload, error)
+}
+
+type reportExportModel struct{}
+
+var (
+	_reportExportModel     IReportExportModel
+	_reportExportModelOnce sync.Once
+)
+
+func GetReportExportModel() IReportExportModel {
+	_reportExportModelOnce.Do(func() {
+		_reportExportModel = &reportExportModel{}
+	})
+	return _reportExportModel
+}
+
+func (m *reportExportModel) Render(ctx context.Context, format entity.ReportExportFormat, fileName string, table *entity.ReportTable) (*entity.JobFile, error) {
+	buf := new(bytes.Buffer)
+	var err error
+	switch format {
+	case entity.ReportExportFormatXlsx:
+		err = utils.WriteXlsx(buf, "Report", table.ExportRows())
+	case entity.ReportExportFormatPdf:
+		err = utils.WritePdf(buf, m.pdfReport(table))
+	case entity.ReportExportFormatCsv:
+		err = m.writeCsv(buf, table.ExportRows())
+	default:
+		log.Warn(ctx, "invalid report export format", log.Any("format", format))
+		return nil, constant.ErrInvalidArgs
+	}
+	if err != nil {
+		log.Error(ctx, "render report export failed", log.Err(err), log.Any("format", format), log.String("title", table.Title))
+		return nil, err
+	}
+
+	return &entity.JobFile{
+		FileName:    fmt.Sprintf("%s.%s", fileName, format),
+		ContentType: format.ContentType(),
+		Data:        buf.Bytes(),
+	}, nil
+}
+
+func (m *reportExportModel) writeCsv(buf *bytes.Buffer, rows [][]interface{}) error {
+	writer := csv.NewWriter(buf)
+	for _, row := range rows {
+		if err := writer.Write(formatReportRow(row)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatReportRow text of the cells of a report table row
+func formatReportRow(row []interface{}) []string {
+	record := make([]string, len(row))
+	for i, cell := range row {
+		switch v := cell.(type) {
+		case nil:
+		case float64:
+			record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return record
+}
+
+// pdfReport the first numeric column is charted by the first text column
+func (m *reportExportModel) pdfReport(table *entity.ReportTable) *utils.PdfReport {
+	rows := table.Rows
+	if len(rows) > constant.ReportExportPdfRowLimit {
+		rows = rows[:constant.ReportExportPdfRowLimit]
+	}
+
+	report := &utils.PdfReport{
+		Title:   table.Title,
+		Summary: table.Summary,
+		Columns: table.Columns,
+		Rows:    make([][]string, len(rows)),
+	}
+	for i, row := range rows {
+		report.Rows[i] = formatReportRow(row)
+	}
+	if len(rows) < len(table.Rows) {
+		report.Summary = append(report.Summary, [2]string{"rows", fmt.Sprintf("%d of %d printed", len(rows), len(table.Rows))})
+	}
+
+	labelColumn, valueColumn := -1, -1
+	for i := range table.Columns {
+		for _, row := range rows {
+			switch row[i].(type) {
+			case string:
+				if labelColumn < 0 {
+					labelColumn = i
+				}
+			case int64, float64:
+				if valueColumn < 0 && i != labelColumn {
+					valueColumn = i
+				}
+			default:
+				continue
+			}
+			break
+		}
+	}
+	if labelColumn < 0 || valueColumn < 0 {
+		return report
+	}
+
+	report.Chart = &utils.PdfBarChart{Title: table.Columns[valueColumn]}
+	for _, row := range rows {
+		var value float64
+		switch v := row[valueColumn].(type) {
+		case int64:
+			value = float64(v)
+		case float64:
+			value = v
+		}
+		report.Chart.Labels = append(report.Chart.Labels, fmt.Sprint(row[labelColumn]))
+		report.Chart.Values = append(report.Chart.Values, value)
+	}
+	return report
+}
+
+func (m *reportExportModel) Save(ctx context.Context, op *entity.Operator, file *entity.JobFile, rows int) (*entity.ReportExportJobResult, error) {
+	// a retried job uploads to a new path
+	path := fmt.Sprintf("%s/%s/%s", op.OrgID, utils.NewID(), file.FileName)
+	err := storage.DefaultStorage().UploadFileLAN(ctx, storage.ReportExportStoragePartition, path, file.ContentType, bytes.NewReader(file.Data))
+	if err != nil {
+		log.Error(ctx, "upload report export failed", log.Err(err), log.String("path", path))
+		return nil, err
+	}
+
+	return &entity.ReportExportJobResult{
+		FileName:    file.FileName,
+		ContentType: file.ContentType,
+		Path:        path,
+		Rows:        rows,
+	}, nil
+}
+
+func (m *reportExportModel) GetDownload(ctx context.Context, op *entity.Operator, jobID string) (*entity.ReportExportDownload, error) {
+	job, err := GetJobModel().Get(ctx, op, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Type != entity.JobTypeExportReport || job.Status != entity.JobStatusSucceeded {
+		log.Warn(ctx, "report export is not ready", log.Any("job", job))
+		return nil, constant.ErrRecordNotFound
+	}
+
+	result := new(entity.ReportExportJobResult)
+	if err := json.Unmarshal([]byte(job.Result), result); err != nil {
+		log.Error(ctx, "unmarshal report export result failed", log.Err(err), log.Any("job", job))
+		return nil, err
+	}
+
+	url, err := storage.DefaultStorage().GetFileTempPath(ctx, storage.ReportExportStoragePartition, result.Path)
+	if err != nil {
+		log.Error(ctx, "get report export link failed", log.Err(err), log.Any("result", result))
+		return nil, err
+	}
+
+	return &entity.ReportExportDownload{
+		FileName: result.FileName,
+		URL:      url,
+	}, nil
+}
@@ -0,0 +1,265 @@
+package model
+
+import (
+	"context"
+	"sync"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+)
+
+type IHeadquartersReportModel interface {
+	// Query compare the child organizations of the headquarters, or their schools
+	Query(ctx context.Context, op *entity.Operator, req *entity.HeadquartersReportRequest) (*entity.HeadquartersReportResponse, error)
+}
+
+type headquartersReportModel struct{}
+
+var (
+	_headquartersReportModelOnce sync.Once
+	_headquartersReportModel     IHeadquartersReportModel
+)
+
+func GetHeadquartersReportModel() IHeadquartersReportModel {
+	_headquartersReportModelOnce.Do(func() {
+		_headquartersReportModel = &headquartersReportModel{}
+	})
+	return _headquartersReportModel
+}
+
+// headquartersReportDrillDownPermissions permissions of the existing organization reports in a child organization
+var headquartersReportDrillDownPermissions = map[entity.HeadquartersReportMetric]external.PermissionName{
+	entity.HeadquartersReportMetricAttendance:           external.ReportStudentProgressReportOrganization,
+	entity.HeadquartersReportMetricAssignmentCompletion: external.ReportStudentProgressReportOrganization,
+	entity.HeadquartersReportMetricOutcomeAchievement:   external.ReportStudentProgressReportOrganization,
+	entity.HeadquartersReportMetricContentUsage:         external.ReportOrganizationStudentUsage,
+	entity.HeadquartersReportMetricTeacherLoad:          external.ReportOrganizationTeachingLoad617,
+}
+
+func (m *headquartersReportModel) checkPermission(ctx context.Context, op *entity.Operator) error {
+	perms, err := external.GetPermissionServiceProvider().HasOrganizationPermissions(ctx, op, []external.PermissionName{external.ViewHeadquartersReports10906})
+	if err != nil {
+		log.Error(ctx, "check headquarters report permission failed", log.Err(err), log.Any("operator", op))
+		return err
+	}
+	if !perms[external.ViewHeadquartersReports10906] {
+		log.Warn(ctx, "user has no headquarters report permission", log.Any("operator", op))
+		return constant.ErrForbidden
+	}
+
+	property, err := GetOrganizationPropertyModel().GetOrDefault(ctx, op.OrgID)
+	if err == dbo.ErrRecordNotFound {
+		log.Warn(ctx, "organization is not headquarters", log.Any("operator", op))
+		return constant.ErrForbidden
+	}
+	if err != nil {
+		log.Error(ctx, "get organization property failed", log.Err(err), log.Any("operator", op))
+		return err
+	}
+	if property.Type != entity.OrganizationTypeHeadquarters {
+		log.Warn(ctx, "organization is not headquarters", log.Any("operator", op), log.Any("property", property))
+		return constant.ErrForbidden
+	}
+	return nil
+}
+
+// weeks starts of the weeks overlapping the time range
+func (m *headquartersReportModel) weeks(startAt, endAt int64) []int64 {
+	offset := config.Get().ReportRollup.TimeZoneOffset
+	var weeks []int64
+	for week := entity.ReportRollupPeriodWeekly.Start(startAt, offset); week < endAt; week += entity.ReportRollupPeriodWeekly.Seconds() {
+		weeks = append(weeks, week)
+	}
+	return weeks
+}
+
+func (m *headquartersReportModel) Query(ctx context.Context, op *entity.Operator, req *entity.HeadquartersReportRequest) (*entity.HeadquartersReportResponse, error) {
+	if !req.Valid() {
+		log.Warn(ctx, "invalid headquarters report request", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+	weeks := m.weeks(req.StartAt, req.EndAt)
+	if len(weeks) > constant.HeadquartersReportMaxWeeks {
+		log.Warn(ctx, "headquarters report time range is too long", log.Any("req", req), log.Int("weeks", len(weeks)))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op); err != nil {
+		return nil, err
+	}
+
+	orgs, err := GetOrganizationRegionModel().GetOrganizationByHeadquarterForDetails(ctx, dbo.MustGetDB(ctx), op)
+	if err != nil {
+		return nil, err
+	}
+	if req.ChildOrgID != "" {
+		var child []*entity.RegionOrganizationInfo
+		for _, org := range orgs {
+			if org.ID == req.ChildOrgID {
+				child = append(child, org)
+			}
+		}
+		if len(child) == 0 {
+			log.Warn(ctx, "organization is not a child of the headquarters", log.Any("operator", op), log.Any("req", req))
+			return nil, constant.ErrForbidden
+		}
+		orgs = child
+	}
+
+	orgIDs := make([]string, len(orgs))
+	orgNames := make(map[string]string, len(orgs))
+	for i, org := range orgs {
+		orgIDs[i] = org.ID
+		orgNames[org.ID] = org.Name
+	}
+
+	condition := &entity.HeadquartersReportCondition{
+		Metric:  req.Metric,
+		Level:   req.Level,
+		OrgIDs:  orgIDs,
+		StartAt: req.StartAt,
+		EndAt:   req.EndAt,
+	}
+	totals, err := da.GetReportDA().GetHeadquartersReportCounts(ctx, condition)
+	if err != nil {
+		return nil, err
+	}
+	condition.Weeks = weeks
+	weekly, err := da.GetReportDA().GetHeadquartersReportCounts(ctx, condition)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := m.items(ctx, op, req, orgIDs, orgNames, totals)
+	if err != nil {
+		return nil, err
+	}
+
+	weekIndex := make(map[int64]int, len(weeks))
+	for i, week := range weeks {
+		weekIndex[week] = i
+	}
+	itemIndex := make(map[[2]string]*entity.HeadquartersReportItem, len(items))
+	for _, item := range items {
+		item.Trend = m.trend(weeks)
+		itemIndex[[2]string{item.OrgID, item.SchoolID}] = item
+	}
+	overallTrend := m.trend(weeks)
+	for _, count := range weekly {
+		i, ok := weekIndex[count.WeekStart]
+		if !ok {
+			continue
+		}
+		if item, ok := itemIndex[[2]string{count.OrgID, count.SchoolID}]; ok {
+			item.Trend[i].HeadquartersReportValue = entity.NewHeadquartersReportValue(count.Count, count.Total)
+		}
+		point := overallTrend[i]
+		point.HeadquartersReportValue = entity.NewHeadquartersReportValue(point.Count+count.Count, point.Total+count.Total)
+	}
+
+	var overallCount, overallTotal int64
+	for _, item := range items {
+		overallCount += item.Count
+		overallTotal += item.Total
+	}
+
+	if err := m.fillDrillDown(ctx, op, req.Metric, items); err != nil {
+		return nil, err
+	}
+
+	return &entity.HeadquartersReportResponse{
+		Metric:       req.Metric,
+		Level:        req.Level,
+		StartAt:      req.StartAt,
+		EndAt:        req.EndAt,
+		Weeks:        weeks,
+		Overall:      entity.NewHeadquartersReportValue(overallCount, overallTotal),
+		OverallTrend: overallTrend,
+		Bands:        entity.RankHeadquartersReportItems(items),
+		Items:        items,
+	}, nil
+}
+
+func (m *headquartersReportModel) trend(weeks []int64) []*entity.HeadquartersReportTrendPoint {
+	trend := make([]*entity.HeadquartersReportTrendPoint, len(weeks))
+	for i, week := range weeks {
+		trend[i] = &entity.HeadquartersReportTrendPoint{WeekStart: week}
+	}
+	return trend
+}
+
+// items every child organization, or the schools with data
+func (m *headquartersReportModel) items(ctx context.Context, op *entity.Operator, req *entity.HeadquartersReportRequest, orgIDs []string, orgNames map[string]string, totals []*entity.HeadquartersReportCount) ([]*entity.HeadquartersReportItem, error) {
+	if req.Level == entity.HeadquartersReportLevelOrganization {
+		counts := make(map[string]*entity.HeadquartersReportCount, len(totals))
+		for _, count := range totals {
+			counts[count.OrgID] = count
+		}
+
+		items := make([]*entity.HeadquartersReportItem, len(orgIDs))
+		for i, orgID := range orgIDs {
+			items[i] = &entity.HeadquartersReportItem{OrgID: orgID, OrgName: orgNames[orgID]}
+			if count, ok := counts[orgID]; ok {
+				items[i].HeadquartersReportValue = entity.NewHeadquartersReportValue(count.Count, count.Total)
+			}
+		}
+		return items, nil
+	}
+
+	schoolIDs := make([]string, len(totals))
+	for i, count := range totals {
+		schoolIDs[i] = count.SchoolID
+	}
+	schoolNames, err := external.GetSchoolServiceProvider().BatchGetNameMap(ctx, op, schoolIDs)
+	if err != nil {
+		log.Error(ctx, "get headquarters report school names failed", log.Err(err), log.Strings("school_ids", schoolIDs))
+		return nil, err
+	}
+
+	items := make([]*entity.HeadquartersReportItem, len(totals))
+	for i, count := range totals {
+		items[i] = &entity.HeadquartersReportItem{
+			OrgID:                   count.OrgID,
+			OrgName:                 orgNames[count.OrgID],
+			SchoolID:                count.SchoolID,
+			SchoolName:              schoolNames[count.SchoolID],
+			HeadquartersReportValue: entity.NewHeadquartersReportValue(count.Count, count.Total),
+		}
+	}
+	return items, nil
+}
+
+// fillDrillDown links the items to the existing report of their organization if the user has its permission there
+func (m *headquartersReportModel) fillDrillDown(ctx context.Context, op *entity.Operator, metric entity.HeadquartersReportMetric, items []*entity.HeadquartersReportItem) error {
+	permission := headquartersReportDrillDownPermissions[metric]
+	authorized := make(map[string]bool)
+	for _, item := range items {
+		if _, ok := authorized[item.OrgID]; ok {
+			continue
+		}
+		child := &entity.Operator{UserID: op.UserID, OrgID: item.OrgID, Token: op.Token}
+		perms, err := external.GetPermissionServiceProvider().HasOrganizationPermissions(ctx, child, []external.PermissionName{permission})
+		if err != nil {
+			log.Error(ctx, "check headquarters report drill down permission failed", log.Err(err), log.Any("operator", child), log.String("permission", string(permission)))
+			return err
+		}
+		authorized[item.OrgID] = perms[permission]
+	}
+
+	for _, item := range items {
+		if authorized[item.OrgID] {
+			item.DrillDown = &entity.HeadquartersReportDrillDown{
+				OrgID:    item.OrgID,
+				SchoolID: item.SchoolID,
+				Report:   metric.Report(),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,387 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+type IInsightRuleModel interface {
+	// GetRules rules of the organization, the default rules when it has none
+	GetRules(ctx context.Context, op *entity.Operator) (*entity.InsightRuleSetView, error)
+	// UpdateRules replace the rules of the organization
+	UpdateRules(ctx context.Context, op *entity.Operator, req *entity.InsightRuleSetUpdateReq) error
+	// ResetRules use the default rules again
+	ResetRules(ctx context.Context, op *entity.Operator) error
+	// Preview fire the rules against a student without changing anything, the fired rules are logged as previews
+	Preview(ctx context.Context, op *entity.Operator, req *entity.InsightRulePreviewReq) (*entity.InsightRulePreviewReply, error)
+	QueryStats(ctx context.Context, op *entity.Operator, req *entity.InsightRuleStatsReq) ([]*entity.InsightRuleStat, error)
+
+	// GetAppInsightMessage fire the rules of the organization against the student, the fired rules are logged
+	GetAppInsightMessage(ctx context.Context, op *entity.Operator, req *entity.AppInsightMessageRequest) (*entity.AppInsightMessageResponse, error)
+}
+
+type insightRuleModel struct{}
+
+var (
+	_insightRuleModelOnce sync.Once
+	_insightRuleModel     IInsightRuleModel
+)
+
+func GetInsightRuleModel() IInsightRuleModel {
+	_insightRuleModelOnce.Do(func() {
+		_insightRuleModel = &insightRuleModel{}
+	})
+	return _insightRuleModel
+}
+
+// checkPermission the operator needs any of the permissions
+func (m *insightRuleModel) checkPermission(ctx context.Context, op *entity.Operator, permissions ...external.PermissionName) error {
+	perms, err := external.GetPermissionServiceProvider().HasOrganizationPermissions(ctx, op, permissions)
+	if err != nil {
+		log.Error(ctx, "check insight rule permission failed", log.Err(err), log.Any("operator", op), log.Any("permissions", permissions))
+		return err
+	}
+
+	for _, permission := range permissions {
+		if perms[permission] {
+			return nil
+		}
+	}
+
+	log.Warn(ctx, "user has no insight rule permission", log.Any("operator", op), log.Any("permissions", permissions))
+	return constant.ErrForbidden
+}
+
+func (m *insightRuleModel) getRuleSet(ctx context.Context, orgID string) (*entity.InsightRuleSet, error) {
+	ruleSet := new(entity.InsightRuleSet)
+	err := da.GetInsightRuleSetDA().Get(ctx, orgID, ruleSet)
+	if err == dbo.ErrRecordNotFound {
+		return nil, constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get insight rule set failed", log.Err(err), log.String("org_id", orgID))
+		return nil, err
+	}
+
+	return ruleSet, nil
+}
+
+// getRules rules of the organization, customized is false for the default rules
+func (m *insightRuleModel) getRules(ctx context.Context, orgID string) (rules entity.InsightRules, ruleSet *entity.InsightRuleSet, err error) {
+	ruleSet, err = m.getRuleSet(ctx, orgID)
+	if err == constant.ErrRecordNotFound {
+		return entity.DefaultInsightRules(), nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if ruleSet.Rules == "" {
+		return entity.DefaultInsightRules(), ruleSet, nil
+	}
+
+	if err := json.Unmarshal([]byte(ruleSet.Rules), &rules); err != nil {
+		log.Error(ctx, "unmarshal insight rules failed", log.Err(err), log.String("org_id", orgID))
+		return nil, nil, err
+	}
+	return rules, ruleSet, nil
+}
+
+func (m *insightRuleModel) GetRules(ctx context.Context, op *entity.Operator) (*entity.InsightRuleSetView, error) {
+	if err := m.checkPermission(ctx, op, external.ManageInsightRules10905, external.ReportStudentProgressReportView); err != nil {
+		return nil, err
+	}
+
+	rules, ruleSet, err := m.getRules(ctx, op.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &entity.InsightRuleSetView{Rules: rules}
+	if ruleSet != nil {
+		result.Customized = ruleSet.Rules != ""
+		result.UpdateAt = ruleSet.UpdateAt
+	}
+	return result, nil
+}
+
+func (m *insightRuleModel) UpdateRules(ctx context.Context, op *entity.Operator, req *entity.InsightRuleSetUpdateReq) error {
+	if len(req.Rules) == 0 || !req.Rules.Valid() {
+		log.Warn(ctx, "insight rules update request invalid", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	data, err := json.Marshal(req.Rules)
+	if err != nil {
+		log.Error(ctx, "marshal insight rules failed", log.Err(err), log.Any("req", req))
+		return err
+	}
+
+	return m.saveRules(ctx, op, string(data))
+}
+
+func (m *insightRuleModel) ResetRules(ctx context.Context, op *entity.Operator) error {
+	return m.saveRules(ctx, op, "")
+}
+
+func (m *insightRuleModel) saveRules(ctx context.Context, op *entity.Operator, rules string) error {
+	if err := m.checkPermission(ctx, op, external.ManageInsightRules10905); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	ruleSet, err := m.getRuleSet(ctx, op.OrgID)
+	if err == constant.ErrRecordNotFound {
+		ruleSet = &entity.InsightRuleSet{
+			OrgID:     op.OrgID,
+			Rules:     rules,
+			UpdaterID: op.UserID,
+			CreateAt:  now,
+			UpdateAt:  now,
+		}
+		if _, err := da.GetInsightRuleSetDA().Insert(ctx, ruleSet); err != nil {
+			log.Error(ctx, "insert insight rule set failed", log.Err(err), log.String("org_id", op.OrgID))
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ruleSet.Rules = rules
+	ruleSet.UpdaterID = op.UserID
+	ruleSet.UpdateAt = now
+	if _, err := da.GetInsightRuleSetDA().Update(ctx, ruleSet); err != nil {
+		log.Error(ctx, "update insight rule set failed", log.Err(err), log.String("org_id", op.OrgID))
+		return err
+	}
+
+	return nil
+}
+
+func (m *insightRuleModel) Preview(ctx context.Context, op *entity.Operator, req *entity.InsightRulePreviewReq) (*entity.InsightRulePreviewReply, error) {
+	if req.ClassID == "" || req.StudentID == "" || (len(req.Rules) > 0 && !req.Rules.Valid()) {
+		log.Warn(ctx, "insight rule preview request invalid", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op, external.ManageInsightRules10905); err != nil {
+		return nil, err
+	}
+
+	rules := req.Rules
+	if len(rules) == 0 {
+		var err error
+		rules, _, err = m.getRules(ctx, op.OrgID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	metrics, err := m.getMetrics(ctx, op, req.ClassID, req.StudentID, req.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &entity.InsightRulePreviewReply{Categories: make([]*entity.InsightCategoryPreview, 0, len(entity.InsightCategories))}
+	var messages []*entity.InsightMessage
+	for _, category := range entity.InsightCategories {
+		preview := &entity.InsightCategoryPreview{
+			Category: category,
+			Metrics:  metrics[category],
+			Rules:    []*entity.InsightRuleResult{},
+		}
+		for _, rule := range rules.Sorted(category) {
+			matched := rule.Match(metrics[category])
+			preview.Rules = append(preview.Rules, &entity.InsightRuleResult{
+				Key:      rule.Key,
+				Priority: rule.Priority,
+				LabelID:  rule.LabelID,
+				Matched:  matched,
+			})
+			if matched && preview.Fired == nil {
+				preview.Fired = m.render(category, rule, metrics[category], req.Lang)
+				messages = append(messages, preview.Fired)
+			}
+		}
+		result.Categories = append(result.Categories, preview)
+	}
+
+	m.logFired(ctx, op, req.ClassID, req.StudentID, messages, true)
+	return result, nil
+}
+
+func (m *insightRuleModel) QueryStats(ctx context.Context, op *entity.Operator, req *entity.InsightRuleStatsReq) ([]*entity.InsightRuleStat, error) {
+	if req.StartAt < 0 || (req.EndAt > 0 && req.EndAt < req.StartAt) {
+		log.Warn(ctx, "insight rule stats request invalid", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op, external.ManageInsightRules10905); err != nil {
+		return nil, err
+	}
+
+	endAt := req.EndAt
+	if endAt == 0 {
+		endAt = time.Now().Unix() + 1
+	}
+	return da.GetInsightRuleLogDA().CountByRule(ctx, op.OrgID, req.StartAt, endAt)
+}
+
+func (m *insightRuleModel) GetAppInsightMessage(ctx context.Context, op *entity.Operator, req *entity.AppInsightMessageRequest) (*entity.AppInsightMessageResponse, error) {
+	rules, _, err := m.getRules(ctx, op.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := m.getMetrics(ctx, op, req.ClassID, req.StudentID, req.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &entity.AppInsightMessageResponse{Messages: []*entity.InsightMessage{}}
+	for _, category := range entity.InsightCategories {
+		rule := rules.Fire(category, metrics[category])
+		if rule == nil {
+			continue
+		}
+		message := m.render(category, rule, metrics[category], req.Lang)
+		res.Messages = append(res.Messages, message)
+
+		// label params of the app are typed, params of other names are left out
+		switch category {
+		case entity.InsightCategoryLearningOutcome:
+			res.LearningOutcomeAchivementLabelID = message.LabelID
+			err = entity.SetLabelParams(message.Params, &res.LearningOutcomeAchivementLabelParams)
+		case entity.InsightCategoryAttendance:
+			res.AttedanceLabelID = message.LabelID
+			err = entity.SetLabelParams(message.Params, &res.AttedanceLabelParams)
+		case entity.InsightCategoryAssignment:
+			res.AssignmentLabelID = message.LabelID
+			err = entity.SetLabelParams(message.Params, &res.AssignmentLabelParams)
+		}
+		if err != nil {
+			log.Warn(ctx, "set insight label params failed", log.Err(err), log.Any("message", message))
+		}
+	}
+
+	m.logFired(ctx, op, req.ClassID, req.StudentID, res.Messages, false)
+	return res, nil
+}
+
+func (m *insightRuleModel) render(category entity.InsightCategory, rule *entity.InsightRule, metrics entity.InsightMetrics, lang string) *entity.InsightMessage {
+	message, params := rule.Render(metrics, lang)
+	return &entity.InsightMessage{
+		Category: category,
+		RuleKey:  rule.Key,
+		LabelID:  rule.LabelID,
+		Message:  message,
+		Params:   params,
+	}
+}
+
+// logFired failures are logged only, insights are still replied
+func (m *insightRuleModel) logFired(ctx context.Context, op *entity.Operator, classID, studentID string, messages []*entity.InsightMessage, preview bool) {
+	if len(messages) == 0 {
+		return
+	}
+
+	now := time.Now().Unix()
+	logs := make([]*entity.InsightRuleLog, 0, len(messages))
+	for _, message := range messages {
+		params, err := json.Marshal(message.Params)
+		if err != nil {
+			log.Warn(ctx, "marshal insight params failed", log.Err(err), log.Any("message", message))
+		}
+		logs = append(logs, &entity.InsightRuleLog{
+			ID:         utils.NewID(),
+			OrgID:      op.OrgID,
+			ClassID:    classID,
+			StudentID:  studentID,
+			Category:   message.Category,
+			RuleKey:    message.RuleKey,
+			LabelID:    message.LabelID,
+			Params:     string(params),
+			Preview:    preview,
+			OperatorID: op.UserID,
+			CreateAt:   now,
+		})
+	}
+
+	if _, err := da.GetInsightRuleLogDA().InsertInBatches(ctx, logs, len(logs)); err != nil {
+		log.Warn(ctx, "insert insight rule logs failed", log.Err(err), log.Any("logs", logs))
+	}
+}
+
+// getMetrics the student progress reports of the Repoet4W weeks before endTime in all subjects
+func (m *insightRuleModel) getMetrics(ctx context.Context, op *entity.Operator, classID, studentID string, endTime int) (map[entity.InsightCategory]entity.InsightMetrics, error) {
+	var durations []entity.TimeRange
+	for i := 0; i < entity.Repoet4W; i++ {
+		startAt := strconv.Itoa(endTime - (entity.Repoet4W-i)*7*24*60*60)
+		endAt := strconv.Itoa(endTime - (entity.Repoet4W-(i+1))*7*24*60*60)
+		durations = append(durations, entity.TimeRange(startAt+"-"+endAt))
+	}
+
+	subjects, err := external.GetSubjectServiceProvider().GetByOrganization(ctx, op)
+	if err != nil {
+		log.Error(ctx, "get subjects of organization failed", log.Err(err), log.Any("operator", op))
+		return nil, err
+	}
+	subjectIDs := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		subjectIDs = append(subjectIDs, subject.ID)
+	}
+
+	var (
+		wg                                       sync.WaitGroup
+		outcomeErr, attendanceErr, assignmentErr error
+		outcomeResponse                          *entity.LearnOutcomeAchievementResponse
+		attendanceResponse                       *entity.ClassAttendanceResponse
+		assignmentResponse                       entity.AssignmentResponse
+	)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		outcomeResponse, outcomeErr = GetReportModel().GetStudentProgressLearnOutcomeAchievement(ctx, op, &entity.LearnOutcomeAchievementRequest{
+			ClassID: classID, StudentID: studentID, SelectedSubjectIDList: subjectIDs, Durations: durations,
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		attendanceResponse, attendanceErr = GetReportModel().ClassAttendanceStatistics(ctx, op, &entity.ClassAttendanceRequest{
+			ClassID: classID, StudentID: studentID, SelectedSubjectIDList: subjectIDs, Durations: durations,
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		assignmentResponse, assignmentErr = GetReportModel().GetAssignmentCompletion(ctx, op, &entity.AssignmentRequest{
+			ClassID: classID, StudentID: studentID, SelectedSubjectIDList: subjectIDs, Durations: durations,
+		})
+	}()
+	wg.Wait()
+
+	for _, err := range []error{outcomeErr, attendanceErr, assignmentErr} {
+		if err != nil {
+			log.Error(ctx, "get student progress reports failed", log.Err(err), log.String("class_id", classID), log.String("student_id", studentID))
+			return nil, err
+		}
+	}
+
+	return map[entity.InsightCategory]entity.InsightMetrics{
+		entity.InsightCategoryLearningOutcome: entity.NewLearnOutcomeInsightMetrics(outcomeResponse),
+		entity.InsightCategoryAttendance:      entity.NewAttendanceInsightMetrics(attendanceResponse),
+		entity.InsightCategoryAssignment:      entity.NewAssignmentInsightMetrics(&assignmentResponse),
+	}, nil
+}
@@ -87,7 +87,12 @@ queryUserCount:
 	}
 
 queryAttendance:
-	r, err := da.GetReportDA().GetLearnerWeeklyReportOverview(ctx, op, cond.TimeRange, ucCond)
+	var r *entity.LearnerReportOverview
+	if period, startAt, endAt, ok := GetReportRollupModel().Fresh(ctx, op.OrgID, cond.TimeRange); ok {
+		r, err = da.GetReportRollupDA().GetLearnerReportOverview(ctx, period, op, startAt, endAt, ucCond)
+	} else {
+		r, err = da.GetReportDA().GetLearnerWeeklyReportOverview(ctx, op, cond.TimeRange, ucCond)
+	}
 	if err != nil {
 		return
 	}
@@ -0,0 +1,438 @@
+package model
+
+import (
+	"context"
+	"crypto/hmac"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+type ILearnerReportModel interface {
+	Get(ctx context.Context, op *entity.Operator, req *entity.LearnerReportRequest) (*entity.LearnerReport, error)
+	Pdf(ctx context.Context, report *entity.LearnerReport) (*entity.JobFile, error)
+
+	AddShareLink(ctx context.Context, op *entity.Operator, req *entity.LearnerReportShareLinkAddReq) (*entity.LearnerReportShareLinkView, error)
+	// QueryShareLinks links of the student in the class
+	QueryShareLinks(ctx context.Context, op *entity.Operator, req *entity.LearnerReportShareLinkQueryReq) (*entity.LearnerReportShareLinkPageReply, error)
+	RevokeShareLink(ctx context.Context, op *entity.Operator, id string) error
+
+	// ViewSharedReport the report of a signed share link, no login is required, every visit is audited
+	ViewSharedReport(ctx context.Context, id string, expireAt int64, signature string, visit *entity.LearnerReportShareVisit) (*entity.LearnerReport, error)
+}
+
+type learnerReportModel struct{}
+
+var (
+	_learnerReportModelOnce sync.Once
+	_learnerReportModel     ILearnerReportModel
+)
+
+func GetLearnerReportModel() ILearnerReportModel {
+	_learnerReportModelOnce.Do(func() {
+		_learnerReportModel = &learnerReportModel{}
+	})
+	return _learnerReportModel
+}
+
+// checkPermission the operator needs any of the permissions
+func (m *learnerReportModel) checkPermission(ctx context.Context, op *entity.Operator, permissions ...external.PermissionName) error {
+	perms, err := external.GetPermissionServiceProvider().HasOrganizationPermissions(ctx, op, permissions)
+	if err != nil {
+		log.Error(ctx, "check learner report permission failed", log.Err(err), log.Any("operator", op), log.Any("permissions", permissions))
+		return err
+	}
+
+	for _, permission := range permissions {
+		if perms[permission] {
+			return nil
+		}
+	}
+
+	log.Warn(ctx, "user has no learner report permission", log.Any("operator", op), log.Any("permissions", permissions))
+	return constant.ErrForbidden
+}
+
+// checkStudent the class must be one of the student progress reports the operator can view, and the student in it
+func (m *learnerReportModel) checkStudent(ctx context.Context, op *entity.Operator, classID, studentID string) error {
+	if err := m.checkPermission(ctx, op, external.ReportStudentProgressReportView); err != nil {
+		return err
+	}
+
+	classIDs, err := GetReportModel().GetClassIDsCanViewReports(ctx, op, external.TeacherViewPermissionParams{
+		ViewOrgReports:    external.ReportStudentProgressReportOrganization,
+		ViewSchoolReports: external.ReportStudentProgressReportSchool,
+		ViewMyReports:     external.ReportStudentProgressReportTeacher,
+	})
+	if err != nil {
+		log.Error(ctx, "get classes can view reports failed", log.Err(err), log.Any("operator", op))
+		return err
+	}
+	if !utils.ContainsString(classIDs, classID) {
+		log.Warn(ctx, "user can not view the class", log.Any("operator", op), log.String("class_id", classID))
+		return constant.ErrForbidden
+	}
+
+	students, err := external.GetStudentServiceProvider().GetByClassID(ctx, op, classID)
+	if err != nil {
+		log.Error(ctx, "get students of class failed", log.Err(err), log.String("class_id", classID))
+		return err
+	}
+	for _, student := range students {
+		if student.ID == studentID {
+			return nil
+		}
+	}
+
+	log.Warn(ctx, "student is not in the class", log.Any("operator", op), log.String("class_id", classID), log.String("student_id", studentID))
+	return constant.ErrForbidden
+}
+
+func (m *learnerReportModel) Get(ctx context.Context, op *entity.Operator, req *entity.LearnerReportRequest) (*entity.LearnerReport, error) {
+	if !req.Valid() {
+		log.Warn(ctx, "invalid learner report request", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkStudent(ctx, op, req.ClassID, req.StudentID); err != nil {
+		return nil, err
+	}
+
+	return m.report(ctx, op, req)
+}
+
+func (m *learnerReportModel) report(ctx context.Context, op *entity.Operator, req *entity.LearnerReportRequest) (*entity.LearnerReport, error) {
+	condition := &entity.LearnerReportCondition{
+		OrgID:     op.OrgID,
+		ClassID:   req.ClassID,
+		StudentID: req.StudentID,
+		StartAt:   req.StartAt,
+		EndAt:     req.EndAt,
+	}
+	counts, err := da.GetReportDA().GetLearnerReportCounts(ctx, condition)
+	if err != nil {
+		return nil, err
+	}
+	report := entity.NewLearnerReport(req, counts)
+
+	condition.Limit = constant.LearnerReportMaxAchievedOutcomes
+	report.AchievedOutcomes, err = da.GetReportDA().GetLearnerReportAchievedOutcomes(ctx, condition)
+	if err != nil {
+		return nil, err
+	}
+
+	condition.Limit = constant.LearnerReportMaxComments
+	report.Comments, err = da.GetReportDA().GetLearnerReportComments(ctx, condition)
+	if err != nil {
+		return nil, err
+	}
+
+	classNames, err := external.GetClassServiceProvider().BatchGetNameMap(ctx, op, []string{req.ClassID})
+	if err != nil {
+		log.Error(ctx, "get class names failed", log.Err(err), log.String("class_id", req.ClassID))
+		return nil, err
+	}
+	report.ClassName = classNames[req.ClassID]
+
+	userIDs := []string{req.StudentID}
+	for _, comment := range report.Comments {
+		userIDs = append(userIDs, comment.TeacherID)
+	}
+	userNames, err := external.GetUserServiceProvider().BatchGetNameMap(ctx, op, utils.SliceDeduplicationExcludeEmpty(userIDs))
+	if err != nil {
+		log.Error(ctx, "get user names failed", log.Err(err), log.Strings("user_ids", userIDs))
+		return nil, err
+	}
+	report.StudentName = userNames[req.StudentID]
+	for _, comment := range report.Comments {
+		comment.TeacherName = userNames[comment.TeacherID]
+	}
+
+	return report, nil
+}
+
+func (m *learnerReportModel) Pdf(ctx context.Context, report *entity.LearnerReport) (*entity.JobFile, error) {
+	fileName := fmt.Sprintf("learner_report_%s_%d", report.StudentID, report.StartAt)
+	return GetReportExportModel().Render(ctx, entity.ReportExportFormatPdf, fileName, report.Table(config.Get().ReportRollup.TimeZoneOffset))
+}
+
+func (m *learnerReportModel) AddShareLink(ctx context.Context, op *entity.Operator, req *entity.LearnerReportShareLinkAddReq) (*entity.LearnerReportShareLinkView, error) {
+	if !req.Valid() {
+		log.Warn(ctx, "invalid learner report share link request", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	conf := config.Get().LearnerReport
+	if conf.LinkSecret == "" || conf.LinkBaseURL == "" {
+		log.Error(ctx, "learner report share links are not configured")
+		return nil, constant.ErrInternalServer
+	}
+
+	if err := m.checkPermission(ctx, op, external.ShareLearnerReports10907); err != nil {
+		return nil, err
+	}
+	if err := m.checkStudent(ctx, op, req.ClassID, req.StudentID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	link := &entity.LearnerReportShareLink{
+		ID:        utils.NewID(),
+		OrgID:     op.OrgID,
+		ClassID:   req.ClassID,
+		StudentID: req.StudentID,
+		StartAt:   req.StartAt,
+		EndAt:     req.EndAt,
+		ExpireAt:  now + int64(req.ExpireHours)*int64(time.Hour/time.Second),
+		CreatorID: op.UserID,
+		CreateAt:  now,
+		UpdateAt:  now,
+	}
+	if _, err := da.GetLearnerReportShareLinkDA().Insert(ctx, link); err != nil {
+		log.Error(ctx, "insert learner report share link failed", log.Err(err), log.Any("link", link))
+		return nil, err
+	}
+
+	return m.view(link, now), nil
+}
+
+func (m *learnerReportModel) QueryShareLinks(ctx context.Context, op *entity.Operator, req *entity.LearnerReportShareLinkQueryReq) (*entity.LearnerReportShareLinkPageReply, error) {
+	if req.ClassID == "" || req.StudentID == "" {
+		log.Warn(ctx, "invalid learner report share link query", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op, external.ShareLearnerReports10907); err != nil {
+		return nil, err
+	}
+	if err := m.checkStudent(ctx, op, req.ClassID, req.StudentID); err != nil {
+		return nil, err
+	}
+
+	condition := &da.LearnerReportShareLinkCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		ClassID: sql.NullString{
+			String: req.ClassID,
+			Valid:  true,
+		},
+		StudentID: sql.NullString{
+			String: req.StudentID,
+			Valid:  true,
+		},
+		Pager: dbo.Pager{
+			Page:     req.PageIndex,
+			PageSize: req.PageSize,
+		},
+	}
+
+	var links []*entity.LearnerReportShareLink
+	total, err := da.GetLearnerReportShareLinkDA().Page(ctx, condition, &links)
+	if err != nil {
+		log.Error(ctx, "page learner report share links failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	result := &entity.LearnerReportShareLinkPageReply{
+		Total: total,
+		Data:  make([]*entity.LearnerReportShareLinkView, 0, len(links)),
+	}
+	for _, link := range links {
+		result.Data = append(result.Data, m.view(link, now))
+	}
+	return result, nil
+}
+
+// RevokeShareLink by any user who can share the report of the student
+func (m *learnerReportModel) RevokeShareLink(ctx context.Context, op *entity.Operator, id string) error {
+	link, err := m.getShareLink(ctx, id)
+	if err != nil {
+		return err
+	}
+	if link.OrgID != op.OrgID {
+		log.Warn(ctx, "learner report share link is not in the organization", log.Any("operator", op), log.String("id", id))
+		return constant.ErrRecordNotFound
+	}
+
+	if err := m.checkPermission(ctx, op, external.ShareLearnerReports10907); err != nil {
+		return err
+	}
+	if err := m.checkStudent(ctx, op, link.ClassID, link.StudentID); err != nil {
+		return err
+	}
+
+	if link.RevokeAt > 0 {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	link.RevokeAt = now
+	link.UpdateAt = now
+	if _, err := da.GetLearnerReportShareLinkDA().Update(ctx, link); err != nil {
+		log.Error(ctx, "revoke learner report share link failed", log.Err(err), log.String("id", id))
+		return err
+	}
+	return nil
+}
+
+func (m *learnerReportModel) ViewSharedReport(ctx context.Context, id string, expireAt int64, signature string, visit *entity.LearnerReportShareVisit) (report *entity.LearnerReport, err error) {
+	// requests over the limit of the client are not audited, so that forged requests can't flood the audit log
+	if err = m.limit(ctx, "learner_report:client:"+visit.IP, config.Get().LearnerReport.ClientViewLimit); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	var orgID string
+	defer func() {
+		m.audit(ctx, id, orgID, visit, err, time.Since(start))
+	}()
+
+	// forged links are rejected before they count, they can't use up the views of a real link
+	secret := config.Get().LearnerReport.LinkSecret
+	if secret == "" ||
+		!hmac.Equal([]byte(signature), []byte(entity.LearnerReportShareLinkSignature(secret, id, expireAt))) {
+		log.Warn(ctx, "learner report share link invalid", log.String("id", id), log.Int64("expire_at", expireAt))
+		return nil, constant.ErrRecordNotFound
+	}
+
+	if err = m.limit(ctx, "learner_report:link:"+id, config.Get().LearnerReport.LinkViewLimit); err != nil {
+		return nil, err
+	}
+
+	link, err := m.getShareLink(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	orgID = link.OrgID
+
+	now := time.Now().Unix()
+	if link.ExpireAt != expireAt {
+		log.Warn(ctx, "learner report share link expiry mismatched", log.String("id", id), log.Int64("expire_at", expireAt))
+		return nil, constant.ErrRecordNotFound
+	}
+	if status := link.Status(now); status != entity.LearnerReportShareLinkStatusActive {
+		log.Info(ctx, "learner report share link is not active", log.String("id", id), log.Any("status", status))
+		return nil, constant.ErrOutOfDate
+	}
+
+	// the creator's permissions are checked again on every view, ams is called with the authorized key
+	op := &entity.Operator{
+		UserID: link.CreatorID,
+		OrgID:  link.OrgID,
+	}
+	if err = m.checkStudent(ctx, op, link.ClassID, link.StudentID); err != nil {
+		return nil, err
+	}
+
+	report, err = m.report(ctx, op, link.Request())
+	if err != nil {
+		return nil, err
+	}
+
+	// a missed count does not fail the view, the visit is in the audit log anyway
+	if err := da.GetLearnerReportShareLinkDA().IncreaseViewCount(ctx, id, now); err != nil {
+		log.Warn(ctx, "count learner report share link view failed", log.Err(err), log.String("id", id))
+	}
+
+	return report, nil
+}
+
+// limit views of a link or views from an ip, redis errors do not block the parents
+func (m *learnerReportModel) limit(ctx context.Context, key string, limit int) error {
+	allowed, err := da.GetRateLimitRedisDA().Allow(ctx, key, limit, config.Get().LearnerReport.RateWindow)
+	if err != nil {
+		return nil
+	}
+	if !allowed {
+		log.Warn(ctx, "learner report share link views exceeded limit", log.String("key", key), log.Int("limit", limit))
+		return constant.ErrExceededLimit
+	}
+	return nil
+}
+
+func (m *learnerReportModel) audit(ctx context.Context, id, orgID string, visit *entity.LearnerReportShareVisit, err error, duration time.Duration) {
+	statusCode := http.StatusOK
+	switch err {
+	case nil:
+	case constant.ErrRecordNotFound, constant.ErrForbidden:
+		statusCode = http.StatusNotFound
+	case constant.ErrOutOfDate:
+		statusCode = http.StatusGone
+	case constant.ErrExceededLimit:
+		statusCode = http.StatusTooManyRequests
+	default:
+		statusCode = http.StatusInternalServerError
+	}
+
+	// anonymous visit, the actor is empty
+	_ = GetAuditModel().Record(ctx, &entity.AuditLog{
+		OrgID:      orgID,
+		Action:     "viewLearnerReportShareLink",
+		TargetType: constant.TableNameLearnerReportShareLink,
+		TargetID:   id,
+		Method:     visit.Method,
+		Path:       visit.Path,
+		StatusCode: statusCode,
+		IP:         visit.IP,
+		UserAgent:  visit.UserAgent,
+		Duration:   duration.Milliseconds(),
+	})
+}
+
+func (m *learnerReportModel) getShareLink(ctx context.Context, id string) (*entity.LearnerReportShareLink, error) {
+	var links []*entity.LearnerReportShareLink
+	condition := &da.LearnerReportShareLinkCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{id},
+			Valid:   true,
+		},
+		Pager: dbo.NoPager,
+	}
+	if err := da.GetLearnerReportShareLinkDA().Query(ctx, condition, &links); err != nil {
+		log.Error(ctx, "query learner report share link failed", log.Err(err), log.String("id", id))
+		return nil, err
+	}
+	if len(links) == 0 {
+		return nil, constant.ErrRecordNotFound
+	}
+	return links[0], nil
+}
+
+func (m *learnerReportModel) view(link *entity.LearnerReportShareLink, now int64) *entity.LearnerReportShareLinkView {
+	conf := config.Get().LearnerReport
+	query := url.Values{}
+	query.Set("expire_at", strconv.FormatInt(link.ExpireAt, 10))
+	query.Set("sig", entity.LearnerReportShareLinkSignature(conf.LinkSecret, link.ID, link.ExpireAt))
+
+	return &entity.LearnerReportShareLinkView{
+		ID:         link.ID,
+		ClassID:    link.ClassID,
+		StudentID:  link.StudentID,
+		StartAt:    link.StartAt,
+		EndAt:      link.EndAt,
+		URL:        fmt.Sprintf("%s/v1/learner_report_links/%s?%s", conf.LinkBaseURL, url.PathEscape(link.ID), query.Encode()),
+		ExpireAt:   link.ExpireAt,
+		Status:     link.Status(now),
+		ViewCount:  link.ViewCount,
+		LastViewAt: link.LastViewAt,
+		CreatorID:  link.CreatorID,
+		CreateAt:   link.CreateAt,
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/KL-Engineering/kidsloop-cms-service/constant"
 	"github.com/KL-Engineering/kidsloop-cms-service/da"
 	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
 	"github.com/KL-Engineering/kidsloop-cms-service/external"
 	"github.com/KL-Engineering/kidsloop-cms-service/utils"
 )
@@ -147,6 +148,17 @@ func (l *learningSummaryReportModel) getYearsWeeksData(nowWithZone time.Time) (r
 }
 
 func (l *learningSummaryReportModel) QueryLiveClassesSummaryV2(ctx context.Context, tx *dbo.DBContext, operator *entity.Operator, filter *entity.LearningSummaryFilter) (res *entity.QueryLiveClassesSummaryResultV2, err error) {
+	// the items are details of schedules which only the live tables have,
+	// the attendance comes from the rollups when they are fresh and a week without classes isn't queried
+	summary, fresh, err := l.summarizeLiveClassesFromRollups(ctx, operator, filter)
+	if err != nil {
+		return
+	}
+	if fresh && summary.Lessons == 0 {
+		res = &entity.QueryLiveClassesSummaryResultV2{Items: []*entity.LiveClassSummaryItemV2{}}
+		return
+	}
+
 	items, err := da.GetReportDA().QueryLiveClassesSummaryV2(ctx, tx, operator, filter)
 	if err != nil {
 		return
@@ -177,7 +189,9 @@ func (l *learningSummaryReportModel) QueryLiveClassesSummaryV2(ctx context.Conte
 		Attend: 0,
 		Items:  items,
 	}
-	if len(items) > 0 {
+	if fresh {
+		res.Attend = float64(summary.Attended) / float64(summary.Lessons)
+	} else if len(items) > 0 {
 		absentCount := 0
 		for _, item := range items {
 			if item.Absent {
@@ -190,6 +204,51 @@ func (l *learningSummaryReportModel) QueryLiveClassesSummaryV2(ctx context.Conte
 	return
 }
 
+// summarizeLiveClassesFromRollups online classes of the student in the week, false if the filter can't be answered
+// by the rollups or they aren't fresh. Rollups have no teacher dimension, and a class of several schools is in a row of each.
+func (l *learningSummaryReportModel) summarizeLiveClassesFromRollups(ctx context.Context, operator *entity.Operator, filter *entity.LearningSummaryFilter) (*entity.ReportRollupSummary, bool, error) {
+	summary := &entity.ReportRollupSummary{}
+	if filter.StudentID == "" || filter.TeacherID != "" || filter.WeekStart <= 0 || filter.WeekEnd <= 0 ||
+		len(filter.SchoolIDs) > 1 || filter.ClassID == constant.LearningSummaryFilterOptionNoneID ||
+		(len(filter.SchoolIDs) == 1 && filter.SchoolIDs[0] == constant.LearningSummaryFilterOptionNoneID) {
+		return summary, false, nil
+	}
+
+	tr := entity.TimeRange(fmt.Sprintf("%d-%d", filter.WeekStart, filter.WeekEnd))
+	period, startAt, endAt, ok := GetReportRollupModel().Fresh(ctx, operator.OrgID, tr)
+	if !ok {
+		return summary, false, nil
+	}
+
+	condition := &entity.ReportRollupCondition{
+		Period:          period,
+		OrgID:           operator.OrgID,
+		StartAt:         startAt,
+		EndAt:           endAt,
+		UserIDs:         entity.NullStrings{Strings: []string{filter.StudentID}, Valid: true},
+		UserType:        string(v2.AssessmentUserTypeStudent),
+		AssessmentTypes: []string{string(v2.AssessmentTypeOnlineClass)},
+	}
+	if len(filter.SchoolIDs) == 1 {
+		condition.SchoolIDs = entity.NullStrings{Strings: filter.SchoolIDs, Valid: true}
+	}
+	if filter.ClassID != "" {
+		condition.ClassIDs = entity.NullStrings{Strings: []string{filter.ClassID}, Valid: true}
+	}
+	if filter.SubjectID != "" {
+		condition.SubjectIDs = entity.NullStrings{Strings: []string{filter.SubjectID}, Valid: true}
+	}
+	summaries, err := da.GetReportRollupDA().Summarize(ctx, condition)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, item := range summaries {
+		summary.Lessons += item.Lessons
+		summary.Attended += item.Attended
+	}
+	return summary, true, nil
+}
+
 func (l *learningSummaryReportModel) QueryLiveClassesSummary(ctx context.Context, tx *dbo.DBContext, operator *entity.Operator, filter *entity.LearningSummaryFilter) (*entity.QueryLiveClassesSummaryResult, error) {
 	// find related schedules and make by schedule id
 	schedules, err := l.findRelatedSchedules(ctx, tx, operator, entity.LearningSummaryTypeLiveClass, filter)
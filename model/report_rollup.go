@@ -0,0 +1,261 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/mutex"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+const reportRollupRefreshBatchSize = 100
+
+type IReportRollupModel interface {
+	// MarkDirtyTx the days of the schedules are refreshed by the worker after tx committed
+	MarkDirtyTx(ctx context.Context, tx *dbo.DBContext, scheduleIDs ...string) error
+	// MarkRepeatDirtyTx the days of the repeated schedules from startAt on are refreshed by the worker after tx committed
+	MarkRepeatDirtyTx(ctx context.Context, tx *dbo.DBContext, repeatID string, startAt int64) error
+
+	// Refresh dirty days, returns the number of days refreshed
+	Refresh(ctx context.Context) (int, error)
+	// Rebuild rollups of the organization from startAt on, every organization with schedules since then if orgID is empty.
+	// Reports read the rollups of an organization once it has been rebuilt.
+	Rebuild(ctx context.Context, orgID string, startAt int64) (*entity.ReportRollupRebuildResult, error)
+	// Fresh the period to read the rollups of the time range with, false if the live tables have to be queried
+	Fresh(ctx context.Context, orgID string, tr entity.TimeRange) (period entity.ReportRollupPeriod, startAt int64, endAt int64, ok bool)
+}
+
+type reportRollupModel struct{}
+
+var (
+	_reportRollupModel     IReportRollupModel
+	_reportRollupModelOnce sync.Once
+)
+
+func GetReportRollupModel() IReportRollupModel {
+	_reportRollupModelOnce.Do(func() {
+		_reportRollupModel = &reportRollupModel{}
+	})
+	return _reportRollupModel
+}
+
+// StartReportRollupWorker refresh rollups of dirty days, one instance at a time
+func StartReportRollupWorker(ctx context.Context) {
+	ticker := time.NewTicker(config.Get().ReportRollup.RefreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		refreshReportRollups(utils.CloneContextWithTrace(ctx))
+	}
+}
+
+// markReportRollupsOfScheduleStatus a closed schedule counts in the summaries
+func markReportRollupsOfScheduleStatus(ctx context.Context, op *entity.Operator, event *entity.ScheduleStatusChangedEvent) error {
+	if event.Status != entity.ScheduleStatusClosed && event.PreviousStatus != entity.ScheduleStatusClosed {
+		return nil
+	}
+	return GetReportRollupModel().MarkDirtyTx(ctx, dbo.MustGetDB(ctx), event.ScheduleID)
+}
+
+func refreshReportRollups(ctx context.Context) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(ctx, "refresh report rollups panic", log.Any("recover error", err))
+		}
+	}()
+
+	locker, err := mutex.NewLock(ctx, da.RedisKeyPrefixReportRollupRefreshLock)
+	if err != nil {
+		log.Error(ctx, "refresh report rollups: create lock failed", log.Err(err))
+		return
+	}
+	locker.Lock()
+	defer locker.Unlock()
+
+	count, err := GetReportRollupModel().Refresh(ctx)
+	if err != nil {
+		log.Error(ctx, "refresh report rollups failed", log.Err(err))
+		return
+	}
+
+	if count > 0 {
+		log.Info(ctx, "refresh report rollups finished", log.Int("count", count))
+	}
+}
+
+func (m *reportRollupModel) MarkDirtyTx(ctx context.Context, tx *dbo.DBContext, scheduleIDs ...string) error {
+	return da.GetReportRollupDA().MarkDirtyTx(ctx, tx, scheduleIDs, config.Get().ReportRollup.TimeZoneOffset, time.Now().Unix())
+}
+
+func (m *reportRollupModel) MarkRepeatDirtyTx(ctx context.Context, tx *dbo.DBContext, repeatID string, startAt int64) error {
+	return da.GetReportRollupDA().MarkRepeatDirtyTx(ctx, tx, repeatID, startAt, config.Get().ReportRollup.TimeZoneOffset, time.Now().Unix())
+}
+
+// refreshDailyTx aggregate the facts of the day again
+func (m *reportRollupModel) refreshDailyTx(ctx context.Context, tx *dbo.DBContext, orgID string, dayStart int64) error {
+	facts, err := da.GetReportRollupDA().QueryFactsTx(ctx, tx, orgID, dayStart, dayStart+entity.ReportRollupPeriodDaily.Seconds())
+	if err != nil {
+		return err
+	}
+
+	scheduleIDs := make([]string, 0, len(facts))
+	seen := make(map[string]bool)
+	for _, fact := range facts {
+		if !seen[fact.ScheduleID] {
+			seen[fact.ScheduleID] = true
+			scheduleIDs = append(scheduleIDs, fact.ScheduleID)
+		}
+	}
+	dimensions, err := da.GetReportRollupDA().QueryDimensionsTx(ctx, tx, scheduleIDs)
+	if err != nil {
+		return err
+	}
+
+	rollups := entity.AggregateReportRollups(orgID, dayStart, facts, dimensions, time.Now().Unix())
+	return da.GetReportRollupDA().ReplaceDailyTx(ctx, tx, orgID, dayStart, rollups)
+}
+
+func (m *reportRollupModel) Refresh(ctx context.Context) (int, error) {
+	dirties, err := da.GetReportRollupDA().GetDirty(ctx, reportRollupRefreshBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	offset := config.Get().ReportRollup.TimeZoneOffset
+	count := 0
+	for _, dirty := range dirties {
+		err := dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+			if err := m.refreshDailyTx(ctx, tx, dirty.OrgID, dirty.PeriodStart); err != nil {
+				return err
+			}
+			now := time.Now().Unix()
+			weekStart := entity.ReportRollupPeriodWeekly.Start(dirty.PeriodStart, offset)
+			if err := da.GetReportRollupDA().RefreshWeeklyTx(ctx, tx, dirty.OrgID, weekStart, now); err != nil {
+				return err
+			}
+			if err := da.GetReportRollupDA().DeleteDirtyTx(ctx, tx, dirty); err != nil {
+				return err
+			}
+			return da.GetReportRollupDA().SaveRefreshTx(ctx, tx, dirty.OrgID, now)
+		})
+		if err != nil {
+			// the day stays dirty and is retried next time
+			log.Error(ctx, "refresh report rollup failed", log.Err(err), log.Any("dirty", dirty))
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (m *reportRollupModel) Rebuild(ctx context.Context, orgID string, startAt int64) (*entity.ReportRollupRebuildResult, error) {
+	offset := config.Get().ReportRollup.TimeZoneOffset
+	startAt = entity.ReportRollupPeriodWeekly.Start(startAt, offset)
+
+	orgIDs := []string{orgID}
+	if orgID == "" {
+		var err error
+		orgIDs, err = da.GetReportRollupDA().QueryOrgIDs(ctx, startAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &entity.ReportRollupRebuildResult{OrgIDs: orgIDs}
+	for _, orgID := range orgIDs {
+		days, err := m.rebuildOrganization(ctx, orgID, startAt, offset)
+		if err != nil {
+			log.Error(ctx, "rebuild report rollups failed", log.Err(err), log.String("org_id", orgID), log.Int64("start_at", startAt))
+			return nil, err
+		}
+		result.Days += days
+		log.Info(ctx, "report rollups rebuilt", log.String("org_id", orgID), log.Int64("start_at", startAt), log.Int("days", days))
+	}
+	return result, nil
+}
+
+func (m *reportRollupModel) rebuildOrganization(ctx context.Context, orgID string, startAt int64, offset int) (int, error) {
+	days, err := da.GetReportRollupDA().QueryDays(ctx, orgID, startAt, offset)
+	if err != nil {
+		return 0, err
+	}
+
+	// days left by their schedules have no rollups after the rebuild
+	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		return da.GetReportRollupDA().DeleteFromTx(ctx, tx, orgID, startAt)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	weeks := make(map[int64]bool)
+	for _, day := range days {
+		err := dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+			return m.refreshDailyTx(ctx, tx, orgID, day)
+		})
+		if err != nil {
+			return 0, err
+		}
+		weeks[entity.ReportRollupPeriodWeekly.Start(day, offset)] = true
+	}
+
+	for week := range weeks {
+		err := dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+			return da.GetReportRollupDA().RefreshWeeklyTx(ctx, tx, orgID, week, time.Now().Unix())
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := da.GetReportRollupDA().SaveRebuild(ctx, orgID, startAt, time.Now().Unix()); err != nil {
+		return 0, err
+	}
+	return len(days), nil
+}
+
+func (m *reportRollupModel) Fresh(ctx context.Context, orgID string, tr entity.TimeRange) (entity.ReportRollupPeriod, int64, int64, bool) {
+	startAt, endAt, err := tr.Value(ctx)
+	if err != nil {
+		return "", 0, 0, false
+	}
+
+	period, ok := entity.ReportRollupPeriodOf(startAt, endAt, config.Get().ReportRollup.TimeZoneOffset)
+	if !ok {
+		return "", 0, 0, false
+	}
+
+	state, err := da.GetReportRollupDA().GetState(ctx, orgID)
+	if err != nil {
+		log.Warn(ctx, "get report rollup state failed, query the live tables", log.Err(err), log.String("org_id", orgID))
+		return "", 0, 0, false
+	}
+	if state == nil || startAt < state.CoverFrom {
+		return "", 0, 0, false
+	}
+
+	dirty, err := da.GetReportRollupDA().HasDirty(ctx, orgID, startAt, endAt)
+	if err != nil {
+		log.Warn(ctx, "check dirty report rollups failed, query the live tables", log.Err(err), log.String("org_id", orgID))
+		return "", 0, 0, false
+	}
+	if dirty {
+		return "", 0, 0, false
+	}
+	return period, startAt, endAt, true
+}
+
+func reportRollupScheduleIDs(schedules []*entity.Schedule) []string {
+	ids := make([]string, len(schedules))
+	for i := range schedules {
+		ids[i] = schedules[i].ID
+	}
+	return ids
+}
@@ -98,58 +98,94 @@ func (m *reportModel) GetStudentUsageMaterial(ctx context.Context, op *entity.Op
 }
 
 func (m *reportModel) AddStudentUsageRecordTx(ctx context.Context, tx *dbo.DBContext, op *entity.Operator, record *entity.StudentUsageRecord) (err error) {
-	sche, err := GetScheduleModel().GetPlainByID(ctx, record.RoomID)
+	room, err := getStudentUsageRoom(ctx, op, record.RoomID)
 	if err != nil {
-		log.Error(ctx, "can not find schedule by id", log.Any("schedule_id", record.RoomID))
+		return
+	}
+	if room == nil {
+		return
+	}
+	room.fill(ctx, record)
+
+	var models []entity.StudentUsageRecord
+	for _, student := range record.Students {
+		usageRecord := *record
+		usageRecord.ID = utils.NewID()
+		usageRecord.StudentUserID = student.UserID
+		usageRecord.StudentName = student.Name
+		usageRecord.StudentEmail = student.Email
+		models = append(models, usageRecord)
+	}
+	_, err = da.GetStudentUsageDA().InsertTx(ctx, tx, models)
+	if err != nil {
+		return
+	}
+
+	records := make([]*entity.StudentUsageRecord, len(models))
+	for i := range models {
+		records[i] = &models[i]
+	}
+	recordXAPIMaterialViews(ctx, room.orgID, records)
+	return
+}
+
+// studentUsageRoom the schedule, class and materials of a room, shared by the usage records of it
+type studentUsageRoom struct {
+	orgID           string
+	lessonPlanID    string
+	scheduleStartAt int64
+	classID         string
+	materials       LiveMaterialSlice
+}
+
+// getStudentUsageRoom returns nil if the schedule has no lesson plan, its usage records are not kept
+func getStudentUsageRoom(ctx context.Context, op *entity.Operator, roomID string) (room *studentUsageRoom, err error) {
+	sche, err := GetScheduleModel().GetPlainByID(ctx, roomID)
+	if err != nil {
+		log.Error(ctx, "can not find schedule by id", log.Any("schedule_id", roomID))
 		err = constant.ErrInvalidArgs
 		return
 	}
 	if sche.LessonPlanID == "" {
 		return
 	}
-	record.LessonPlanID = sche.LessonPlanID
+	room = &studentUsageRoom{
+		orgID:           sche.OrgID,
+		lessonPlanID:    sche.LessonPlanID,
+		scheduleStartAt: sche.CreatedAt,
+	}
 	if sche.StartAt > 0 {
-		record.ScheduleStartAt = sche.StartAt
-	} else {
-		record.ScheduleStartAt = sche.CreatedAt
+		room.scheduleStartAt = sche.StartAt
 	}
 
-	classID, err := GetScheduleRelationModel().GetClassRosterID(ctx, op, record.RoomID)
+	room.classID, err = GetScheduleRelationModel().GetClassRosterID(ctx, op, roomID)
 	if err != nil {
-		return
+		return nil, err
 	}
-	record.ClassID = classID
 
-	materials, err := m.getMaterials(ctx, op, &entity.MaterialInput{
+	room.materials, err = GetLiveTokenModel().GetMaterials(ctx, op, &entity.MaterialInput{
 		ContentID:  sche.LessonPlanID,
 		ScheduleID: sche.ID,
 		TokenType:  entity.LiveTokenTypeLive,
-	})
+	}, true)
 	if err != nil {
-		return
+		return nil, err
 	}
-	material, found := materials.FindByUrl(ctx, record.LessonMaterialUrl)
+	return
+}
+
+func (r *studentUsageRoom) fill(ctx context.Context, record *entity.StudentUsageRecord) {
+	record.LessonPlanID = r.lessonPlanID
+	record.ScheduleStartAt = r.scheduleStartAt
+	record.ClassID = r.classID
+
+	material, found := r.materials.FindByUrl(ctx, record.LessonMaterialUrl)
 	if found {
 		record.LessonMaterialID = material.ID
 		if mData, ok := material.ContentData.(*MaterialData); ok {
 			record.ContentType = mData.FileType.String()
 		}
 	}
-
-	var models []entity.StudentUsageRecord
-	for _, student := range record.Students {
-		usageRecord := *record
-		usageRecord.ID = utils.NewID()
-		usageRecord.StudentUserID = student.UserID
-		usageRecord.StudentName = student.Name
-		usageRecord.StudentEmail = student.Email
-		models = append(models, usageRecord)
-	}
-	_, err = da.GetStudentUsageDA().InsertTx(ctx, tx, models)
-	if err != nil {
-		return
-	}
-	return
 }
 
 type LiveMaterialSlice []*entity.LiveMaterial
@@ -174,7 +210,3 @@ func (lms LiveMaterialSlice) FindByUrl(ctx context.Context, url string) (materia
 	}
 	return
 }
-
-func (m *reportModel) getMaterials(ctx context.Context, op *entity.Operator, input *entity.MaterialInput) (materials LiveMaterialSlice, err error) {
-	return GetLiveTokenModel().GetMaterials(ctx, op, input, true)
-}
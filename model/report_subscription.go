@@ -0,0 +1,817 @@
+package model
+
+import (
+	"context"
+	"crypto/hmac"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/mutex"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+const reportSubscriptionDeliveryBatchSize = 20
+
+type IReportSubscriptionModel interface {
+	Add(ctx context.Context, op *entity.Operator, req *entity.ReportSubscriptionAddReq) (*entity.ReportSubscriptionView, error)
+	// Query subscriptions created by the operator
+	Query(ctx context.Context, op *entity.Operator) ([]*entity.ReportSubscriptionView, error)
+	GetByID(ctx context.Context, op *entity.Operator, id string) (*entity.ReportSubscriptionView, error)
+	Update(ctx context.Context, op *entity.Operator, req *entity.ReportSubscriptionUpdateReq) error
+	Delete(ctx context.Context, op *entity.Operator, id string) error
+	Pause(ctx context.Context, op *entity.Operator, id string) error
+	// Resume the next run is scheduled from now, missed runs are not delivered
+	Resume(ctx context.Context, op *entity.Operator, id string) error
+	QueryDeliveries(ctx context.Context, op *entity.Operator, req *entity.ReportSubscriptionDeliveryQueryReq) (*entity.ReportSubscriptionDeliveryPageReply, error)
+
+	// HandleLink pause or unsubscribe by a signed link of a mail, no login is required
+	HandleLink(ctx context.Context, id, recipient string, action entity.ReportSubscriptionLinkAction, signature string) error
+
+	// Deliver run due subscriptions and mail the reports, returns the number of runs
+	Deliver(ctx context.Context) (int, error)
+}
+
+// reportSubscriptionRunner runs a report type as the creator of the subscription
+type reportSubscriptionRunner struct {
+	// permissions the creator needs any of them
+	permissions []external.PermissionName
+	// validate the params of the subscription
+	validate func(params []byte) error
+	// run the report of the period
+	run func(ctx context.Context, op *entity.Operator, params []byte, start, end int64) (interface{}, error)
+}
+
+type reportSubscriptionModel struct {
+	runners map[entity.ReportSubscriptionReportType]*reportSubscriptionRunner
+}
+
+var (
+	_reportSubscriptionModelOnce sync.Once
+	_reportSubscriptionModel     IReportSubscriptionModel
+)
+
+func GetReportSubscriptionModel() IReportSubscriptionModel {
+	_reportSubscriptionModelOnce.Do(func() {
+		_reportSubscriptionModel = &reportSubscriptionModel{
+			runners: newReportSubscriptionRunners(),
+		}
+	})
+	return _reportSubscriptionModel
+}
+
+// StartReportSubscriptionWorker deliver due subscriptions, one instance at a time
+func StartReportSubscriptionWorker(ctx context.Context) {
+	GetReportSubscriptionModel()
+
+	ticker := time.NewTicker(config.Get().ReportSubscription.DeliveryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deliverReportSubscriptions(utils.CloneContextWithTrace(ctx))
+	}
+}
+
+func deliverReportSubscriptions(ctx context.Context) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(ctx, "deliver report subscriptions panic", log.Any("recover error", err))
+		}
+	}()
+
+	locker, err := mutex.NewLock(ctx, da.RedisKeyPrefixReportSubscriptionDeliveryLock)
+	if err != nil {
+		log.Error(ctx, "deliver report subscriptions: create lock failed", log.Err(err))
+		return
+	}
+	locker.Lock()
+	defer locker.Unlock()
+
+	count, err := GetReportSubscriptionModel().Deliver(ctx)
+	if err != nil {
+		log.Error(ctx, "deliver report subscriptions failed", log.Err(err))
+		return
+	}
+
+	if count > 0 {
+		log.Info(ctx, "deliver report subscriptions finished", log.Int("count", count))
+	}
+}
+
+func newReportSubscriptionRunners() map[entity.ReportSubscriptionReportType]*reportSubscriptionRunner {
+	teachingLoadPermissions := external.TeacherViewPermissionParams{
+		ViewOrgReports:    external.ReportOrganizationTeachingLoad617,
+		ViewSchoolReports: external.ReportSchoolTeachingLoad618,
+		ViewMyReports:     external.ReportMyTeachingLoad619,
+	}
+	learningSummaryPermissions := []external.PermissionName{
+		external.LearningSummaryReport,
+		external.ReportLearningSummaryStudent,
+		external.ReportLearningSummarySchool,
+		external.ReportLearningSummaryTeacher,
+		external.ReportLearningSummmaryOrg,
+	}
+	noParams := func(params []byte) error {
+		return nil
+	}
+	timeRange := func(start, end int64) entity.TimeRange {
+		return entity.TimeRange(fmt.Sprintf("%d-%d", start, end))
+	}
+
+	// teacherLoadLessonArgs the teachers and classes of the params which the creator can view now
+	teacherLoadLessonArgs := func(ctx context.Context, op *entity.Operator, params []byte, start, end int64) (*entity.TeacherLoadLessonArgs, error) {
+		args := new(entity.TeacherLoadLessonArgs)
+		if err := json.Unmarshal(params, args); err != nil {
+			return nil, err
+		}
+
+		teacherIDs, err := GetReportModel().GetTeacherIDsCanViewReports(ctx, op, teachingLoadPermissions)
+		if err != nil {
+			return nil, err
+		}
+		classIDs, err := GetReportModel().GetClassIDsCanViewReports(ctx, op, teachingLoadPermissions)
+		if err != nil {
+			return nil, err
+		}
+
+		args.TeacherIDs = utils.IntersectAndDeduplicateStrSlice(args.TeacherIDs, teacherIDs)
+		args.ClassIDs = utils.IntersectAndDeduplicateStrSlice(args.ClassIDs, classIDs)
+		if len(args.TeacherIDs) == 0 || len(args.ClassIDs) == 0 {
+			log.Warn(ctx, "report subscription: no teacher or class can be viewed", log.Any("operator", op), log.String("params", string(params)))
+			return nil, constant.ErrForbidden
+		}
+		args.Duration = timeRange(start, end)
+		return args, nil
+	}
+	validateTeacherLoadLessonArgs := func(params []byte) error {
+		args := new(entity.TeacherLoadLessonArgs)
+		if err := json.Unmarshal(params, args); err != nil {
+			return err
+		}
+		if len(args.TeacherIDs) == 0 || len(args.ClassIDs) == 0 {
+			return constant.ErrInvalidArgs
+		}
+		return nil
+	}
+
+	learningSummaryFilter := func(params []byte, start, end int64) (*entity.LearningSummaryFilter, error) {
+		filter := new(entity.LearningSummaryFilter)
+		if err := json.Unmarshal(params, filter); err != nil {
+			return nil, err
+		}
+		filter.Year = time.Unix(start, 0).UTC().Year()
+		filter.WeekStart = start
+		filter.WeekEnd = end
+		return filter, nil
+	}
+	validateLearningSummaryFilter := func(params []byte) error {
+		filter := new(entity.LearningSummaryFilter)
+		if err := json.Unmarshal(params, filter); err != nil {
+			return err
+		}
+		if filter.StudentID == "" {
+			return constant.ErrInvalidArgs
+		}
+		return nil
+	}
+
+	return map[entity.ReportSubscriptionReportType]*reportSubscriptionRunner{
+		entity.ReportSubscriptionLearnerWeeklyOverview: {
+			permissions: []external.PermissionName{
+				external.ReportLearningSummmaryOrg,
+				external.ReportLearningSummarySchool,
+				external.ReportLearningSummaryTeacher,
+				external.ReportLearningSummaryStudent,
+			},
+			validate: noParams,
+			run: func(ctx context.Context, op *entity.Operator, params []byte, start, end int64) (interface{}, error) {
+				return GetReportModel().GetLearnerReportOverview(ctx, op, &entity.LearnerReportOverviewCondition{
+					TimeRange:   timeRange(start, end),
+					PermOrg:     external.ReportLearningSummmaryOrg.String(),
+					PermSchool:  external.ReportLearningSummarySchool.String(),
+					PermTeacher: external.ReportLearningSummaryTeacher.String(),
+					PermStudent: external.ReportLearningSummaryStudent.String(),
+				})
+			},
+		},
+		entity.ReportSubscriptionTeacherLoadOverview: {
+			permissions: []external.PermissionName{
+				teachingLoadPermissions.ViewOrgReports,
+				teachingLoadPermissions.ViewSchoolReports,
+				teachingLoadPermissions.ViewMyReports,
+			},
+			validate: noParams,
+			run: func(ctx context.Context, op *entity.Operator, params []byte, start, end int64) (interface{}, error) {
+				teacherIDs, err := GetReportModel().GetTeacherIDsCanViewReports(ctx, op, teachingLoadPermissions)
+				if err != nil {
+					return nil, err
+				}
+				classIDs, err := GetReportModel().GetClassIDsCanViewReports(ctx, op, teachingLoadPermissions)
+				if err != nil {
+					return nil, err
+				}
+				return GetReportTeachingLoadModel().GetTeacherLoadOverview(ctx, op, timeRange(start, end), teacherIDs, classIDs)
+			},
+		},
+		entity.ReportSubscriptionTeacherLoadLessons: {
+			permissions: []external.PermissionName{
+				teachingLoadPermissions.ViewOrgReports,
+				teachingLoadPermissions.ViewSchoolReports,
+				teachingLoadPermissions.ViewMyReports,
+			},
+			validate: validateTeacherLoadLessonArgs,
+			run: func(ctx context.Context, op *entity.Operator, params []byte, start, end int64) (interface{}, error) {
+				args, err := teacherLoadLessonArgs(ctx, op, params, start, end)
+				if err != nil {
+					return nil, err
+				}
+				return GetReportModel().ListTeacherLoadLessons(ctx, op, args)
+			},
+		},
+		entity.ReportSubscriptionTeacherLoadSummary: {
+			permissions: []external.PermissionName{
+				teachingLoadPermissions.ViewOrgReports,
+				teachingLoadPermissions.ViewSchoolReports,
+				teachingLoadPermissions.ViewMyReports,
+			},
+			validate: validateTeacherLoadLessonArgs,
+			run: func(ctx context.Context, op *entity.Operator, params []byte, start, end int64) (interface{}, error) {
+				args, err := teacherLoadLessonArgs(ctx, op, params, start, end)
+				if err != nil {
+					return nil, err
+				}
+				return GetReportModel().SummaryTeacherLoadLessons(ctx, op, args)
+			},
+		},
+		entity.ReportSubscriptionLiveClassesSummary: {
+			permissions: learningSummaryPermissions,
+			validate:    validateLearningSummaryFilter,
+			run: func(ctx context.Context, op *entity.Operator, params []byte, start, end int64) (interface{}, error) {
+				filter, err := learningSummaryFilter(params, start, end)
+				if err != nil {
+					return nil, err
+				}
+				return GetLearningSummaryReportModel().QueryLiveClassesSummary(ctx, dbo.MustGetDB(ctx), op, filter)
+			},
+		},
+		entity.ReportSubscriptionAssignmentsSummary: {
+			permissions: learningSummaryPermissions,
+			validate:    validateLearningSummaryFilter,
+			run: func(ctx context.Context, op *entity.Operator, params []byte, start, end int64) (interface{}, error) {
+				filter, err := learningSummaryFilter(params, start, end)
+				if err != nil {
+					return nil, err
+				}
+				return GetLearningSummaryReportModel().QueryAssignmentsSummary(ctx, dbo.MustGetDB(ctx), op, filter)
+			},
+		},
+	}
+}
+
+// checkPermission the operator holds any permission of the report type
+func (m *reportSubscriptionModel) checkPermission(ctx context.Context, op *entity.Operator, runner *reportSubscriptionRunner) error {
+	permissions, err := external.GetPermissionServiceProvider().HasOrganizationPermissions(ctx, op, runner.permissions)
+	if err != nil {
+		log.Error(ctx, "check report subscription permissions failed", log.Err(err), log.Any("operator", op), log.Any("permissions", runner.permissions))
+		return err
+	}
+	for _, allowed := range permissions {
+		if allowed {
+			return nil
+		}
+	}
+
+	log.Warn(ctx, "user has no permission to view the report", log.Any("operator", op), log.Any("permissions", runner.permissions))
+	return constant.ErrForbidden
+}
+
+// checkRequest validate the request and the operator's permission of the report type
+func (m *reportSubscriptionModel) checkRequest(ctx context.Context, op *entity.Operator, req *entity.ReportSubscriptionAddReq) error {
+	if !req.Valid() {
+		log.Warn(ctx, "report subscription request invalid", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	if len(req.Params) == 0 || string(req.Params) == "null" {
+		req.Params = json.RawMessage("{}")
+	}
+	runner := m.runners[req.ReportType]
+	if err := runner.validate(req.Params); err != nil {
+		log.Warn(ctx, "report subscription params invalid", log.Err(err), log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	return m.checkPermission(ctx, op, runner)
+}
+
+func (m *reportSubscriptionModel) Add(ctx context.Context, op *entity.Operator, req *entity.ReportSubscriptionAddReq) (*entity.ReportSubscriptionView, error) {
+	if err := m.checkRequest(ctx, op, req); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	subscription := &entity.ReportSubscription{
+		ID:        utils.NewID(),
+		OrgID:     op.OrgID,
+		Status:    entity.ReportSubscriptionStatusActive,
+		CreatorID: op.UserID,
+		CreateAt:  now,
+	}
+	m.apply(subscription, req, now)
+	if _, err := da.GetReportSubscriptionDA().Insert(ctx, subscription); err != nil {
+		log.Error(ctx, "insert report subscription failed", log.Err(err), log.Any("subscription", subscription))
+		return nil, err
+	}
+
+	return m.convertView(subscription), nil
+}
+
+// apply the request to the subscription, the next run is scheduled again
+func (m *reportSubscriptionModel) apply(subscription *entity.ReportSubscription, req *entity.ReportSubscriptionAddReq, now int64) {
+	subscription.Name = req.Name
+	subscription.ReportType = req.ReportType
+	subscription.Params = string(req.Params)
+	subscription.Format = req.Format
+	subscription.SetRecipients(req.Recipients)
+	subscription.Cadence = req.Cadence
+	subscription.Weekday = req.Weekday
+	subscription.MonthDay = req.MonthDay
+	subscription.Hour = req.Hour
+	subscription.TimeZone = req.TimeZone
+	subscription.NextRunAt = subscription.NextRun(now)
+	subscription.UpdateAt = now
+}
+
+func (m *reportSubscriptionModel) Query(ctx context.Context, op *entity.Operator) ([]*entity.ReportSubscriptionView, error) {
+	var subscriptions []*entity.ReportSubscription
+	err := da.GetReportSubscriptionDA().Query(ctx, &da.ReportSubscriptionCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		CreatorID: sql.NullString{
+			String: op.UserID,
+			Valid:  true,
+		},
+		Pager: dbo.NoPager,
+	}, &subscriptions)
+	if err != nil {
+		log.Error(ctx, "query report subscriptions failed", log.Err(err), log.Any("operator", op))
+		return nil, err
+	}
+
+	result := make([]*entity.ReportSubscriptionView, 0, len(subscriptions))
+	for _, item := range subscriptions {
+		result = append(result, m.convertView(item))
+	}
+	return result, nil
+}
+
+func (m *reportSubscriptionModel) GetByID(ctx context.Context, op *entity.Operator, id string) (*entity.ReportSubscriptionView, error) {
+	subscription, err := m.getSubscription(ctx, op, id)
+	if err != nil {
+		return nil, err
+	}
+	return m.convertView(subscription), nil
+}
+
+func (m *reportSubscriptionModel) Update(ctx context.Context, op *entity.Operator, req *entity.ReportSubscriptionUpdateReq) error {
+	if err := m.checkRequest(ctx, op, &req.ReportSubscriptionAddReq); err != nil {
+		return err
+	}
+
+	subscription, err := m.getSubscription(ctx, op, req.ID)
+	if err != nil {
+		return err
+	}
+
+	m.apply(subscription, &req.ReportSubscriptionAddReq, time.Now().Unix())
+	if _, err := da.GetReportSubscriptionDA().Update(ctx, subscription); err != nil {
+		log.Error(ctx, "update report subscription failed", log.Err(err), log.Any("subscription", subscription))
+		return err
+	}
+
+	return nil
+}
+
+func (m *reportSubscriptionModel) Delete(ctx context.Context, op *entity.Operator, id string) error {
+	subscription, err := m.getSubscription(ctx, op, id)
+	if err != nil {
+		return err
+	}
+
+	subscription.DeleteAt = time.Now().Unix()
+	if _, err := da.GetReportSubscriptionDA().Update(ctx, subscription); err != nil {
+		log.Error(ctx, "delete report subscription failed", log.Err(err), log.String("id", id))
+		return err
+	}
+
+	return nil
+}
+
+func (m *reportSubscriptionModel) Pause(ctx context.Context, op *entity.Operator, id string) error {
+	subscription, err := m.getSubscription(ctx, op, id)
+	if err != nil {
+		return err
+	}
+
+	return m.setStatus(ctx, subscription, entity.ReportSubscriptionStatusPaused)
+}
+
+func (m *reportSubscriptionModel) Resume(ctx context.Context, op *entity.Operator, id string) error {
+	subscription, err := m.getSubscription(ctx, op, id)
+	if err != nil {
+		return err
+	}
+
+	if len(subscription.GetRecipients()) == 0 {
+		log.Warn(ctx, "report subscription has no recipient", log.Any("subscription", subscription))
+		return constant.ErrInvalidArgs
+	}
+
+	return m.setStatus(ctx, subscription, entity.ReportSubscriptionStatusActive)
+}
+
+func (m *reportSubscriptionModel) setStatus(ctx context.Context, subscription *entity.ReportSubscription, status entity.ReportSubscriptionStatus) error {
+	now := time.Now().Unix()
+	if status == entity.ReportSubscriptionStatusActive && subscription.Status != status {
+		subscription.NextRunAt = subscription.NextRun(now)
+	}
+	subscription.Status = status
+	subscription.UpdateAt = now
+	if _, err := da.GetReportSubscriptionDA().Update(ctx, subscription); err != nil {
+		log.Error(ctx, "update report subscription status failed", log.Err(err), log.Any("subscription", subscription))
+		return err
+	}
+
+	return nil
+}
+
+func (m *reportSubscriptionModel) QueryDeliveries(ctx context.Context, op *entity.Operator, req *entity.ReportSubscriptionDeliveryQueryReq) (*entity.ReportSubscriptionDeliveryPageReply, error) {
+	if _, err := m.getSubscription(ctx, op, req.SubscriptionID); err != nil {
+		return nil, err
+	}
+
+	condition := &da.ReportSubscriptionDeliveryCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		SubscriptionID: sql.NullString{
+			String: req.SubscriptionID,
+			Valid:  true,
+		},
+		Pager: dbo.Pager{
+			Page:     req.PageIndex,
+			PageSize: req.PageSize,
+		},
+	}
+
+	var deliveries []*entity.ReportSubscriptionDelivery
+	total, err := da.GetReportSubscriptionDeliveryDA().Page(ctx, condition, &deliveries)
+	if err != nil {
+		log.Error(ctx, "page report subscription deliveries failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	result := &entity.ReportSubscriptionDeliveryPageReply{
+		Total: total,
+		Data:  make([]*entity.ReportSubscriptionDeliveryView, 0, len(deliveries)),
+	}
+	for _, item := range deliveries {
+		recipients := []string{}
+		if item.Recipients != "" {
+			recipients = strings.Split(item.Recipients, constant.StringArraySeparator)
+		}
+		result.Data = append(result.Data, &entity.ReportSubscriptionDeliveryView{
+			ID:          item.ID,
+			Status:      item.Status,
+			PeriodStart: item.PeriodStart,
+			PeriodEnd:   item.PeriodEnd,
+			Recipients:  recipients,
+			Rows:        item.Rows,
+			FileName:    item.FileName,
+			Error:       item.Error,
+			CreateAt:    item.CreateAt,
+		})
+	}
+
+	return result, nil
+}
+
+func (m *reportSubscriptionModel) HandleLink(ctx context.Context, id, recipient string, action entity.ReportSubscriptionLinkAction, signature string) error {
+	secret := config.Get().ReportSubscription.LinkSecret
+	if secret == "" || !action.Valid() ||
+		!hmac.Equal([]byte(signature), []byte(entity.ReportSubscriptionLinkSignature(secret, id, recipient, action))) {
+		log.Warn(ctx, "report subscription link invalid", log.String("id", id), log.String("recipient", recipient), log.Any("action", action))
+		return constant.ErrRecordNotFound
+	}
+
+	subscription, err := m.querySubscription(ctx, &da.ReportSubscriptionCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{id},
+			Valid:   true,
+		},
+		Pager: dbo.NoPager,
+	})
+	if err != nil {
+		return err
+	}
+
+	if action == entity.ReportSubscriptionLinkPause {
+		if subscription.Status == entity.ReportSubscriptionStatusPaused {
+			return nil
+		}
+		return m.setStatus(ctx, subscription, entity.ReportSubscriptionStatusPaused)
+	}
+
+	recipients := subscription.GetRecipients()
+	remaining := make([]string, 0, len(recipients))
+	for _, item := range recipients {
+		if !strings.EqualFold(item, recipient) {
+			remaining = append(remaining, item)
+		}
+	}
+	if len(remaining) == len(recipients) {
+		return nil
+	}
+
+	subscription.SetRecipients(remaining)
+	if len(remaining) == 0 {
+		// nobody is left to mail
+		return m.setStatus(ctx, subscription, entity.ReportSubscriptionStatusPaused)
+	}
+	subscription.UpdateAt = time.Now().Unix()
+	if _, err := da.GetReportSubscriptionDA().Update(ctx, subscription); err != nil {
+		log.Error(ctx, "unsubscribe report subscription failed", log.Err(err), log.Any("subscription", subscription))
+		return err
+	}
+
+	return nil
+}
+
+func (m *reportSubscriptionModel) Deliver(ctx context.Context) (int, error) {
+	now := time.Now().Unix()
+	var subscriptions []*entity.ReportSubscription
+	err := da.GetReportSubscriptionDA().Query(ctx, &da.ReportSubscriptionCondition{
+		Due: sql.NullInt64{
+			Int64: now,
+			Valid: true,
+		},
+		OrderBy: "next_run_at",
+		Pager: dbo.Pager{
+			Page:     1,
+			PageSize: reportSubscriptionDeliveryBatchSize,
+		},
+	}, &subscriptions)
+	if err != nil {
+		log.Error(ctx, "query due report subscriptions failed", log.Err(err))
+		return 0, err
+	}
+
+	for _, subscription := range subscriptions {
+		m.deliver(ctx, subscription)
+	}
+
+	return len(subscriptions), nil
+}
+
+// deliver run the subscription once, a failed run is recorded and not retried until the next run
+func (m *reportSubscriptionModel) deliver(ctx context.Context, subscription *entity.ReportSubscription) {
+	start, end := subscription.Period(subscription.NextRunAt)
+
+	// the run is claimed before sending, so that it is sent once even if the delivery lock expires,
+	// runs missed while the worker was down are skipped
+	now := time.Now().Unix()
+	claimed, err := da.GetReportSubscriptionDA().Claim(ctx, subscription.ID, subscription.NextRunAt, subscription.NextRun(now), now)
+	if err != nil {
+		return
+	}
+	if !claimed {
+		log.Info(ctx, "report subscription run is claimed or changed", log.String("id", subscription.ID), log.Int64("next_run_at", subscription.NextRunAt))
+		return
+	}
+
+	delivery := &entity.ReportSubscriptionDelivery{
+		ID:             utils.NewID(),
+		SubscriptionID: subscription.ID,
+		OrgID:          subscription.OrgID,
+		Status:         entity.ReportSubscriptionDeliverySucceeded,
+		PeriodStart:    start,
+		PeriodEnd:      end,
+	}
+
+	recipients, rows, fileName, err := m.send(ctx, subscription, start, end)
+	delivery.Recipients = strings.Join(recipients, constant.StringArraySeparator)
+	delivery.Rows = rows
+	delivery.FileName = fileName
+	if err != nil {
+		log.Warn(ctx, "deliver report subscription failed", log.Err(err), log.Any("subscription", subscription))
+		delivery.Status = entity.ReportSubscriptionDeliveryFailed
+		delivery.Error = err.Error()
+	}
+
+	delivery.CreateAt = time.Now().Unix()
+	if _, err := da.GetReportSubscriptionDeliveryDA().Insert(ctx, delivery); err != nil {
+		log.Error(ctx, "insert report subscription delivery failed", log.Err(err), log.Any("delivery", delivery))
+	}
+}
+
+// send run the report as the creator and mail it to every recipient, returns the recipients mailed
+func (m *reportSubscriptionModel) send(ctx context.Context, subscription *entity.ReportSubscription, start, end int64) ([]string, int, string, error) {
+	conf := config.Get().ReportSubscription
+	if conf.LinkSecret == "" || conf.LinkBaseURL == "" {
+		return nil, 0, "", fmt.Errorf("report subscription links are not configured")
+	}
+
+	runner, ok := m.runners[subscription.ReportType]
+	if !ok {
+		return nil, 0, "", fmt.Errorf("unknown report type %s", subscription.ReportType)
+	}
+
+	// the creator's permissions are checked again on every run, ams is called with the authorized key
+	op := &entity.Operator{
+		UserID: subscription.CreatorID,
+		OrgID:  subscription.OrgID,
+	}
+	if err := m.checkPermission(ctx, op, runner); err != nil {
+		return nil, 0, "", err
+	}
+
+	result, err := runner.run(ctx, op, []byte(subscription.Params), start, end)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	loc, err := time.LoadLocation(subscription.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	period := fmt.Sprintf("%s - %s",
+		time.Unix(start, 0).In(loc).Format("2006-01-02"),
+		time.Unix(end-1, 0).In(loc).Format("2006-01-02"))
+
+	table, err := entity.NewReportTable(fmt.Sprintf("%s (%s)", subscription.Name, period), data)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	fileName := fmt.Sprintf("report_%s_%s", subscription.ReportType, time.Unix(start, 0).In(loc).Format("20060102"))
+	file, err := GetReportExportModel().Render(ctx, subscription.Format, fileName, table)
+	if err != nil {
+		return nil, len(table.Rows), "", err
+	}
+
+	attachment := &EmailAttachment{
+		FileName:    file.FileName,
+		ContentType: file.ContentType,
+		Data:        file.Data,
+	}
+	var sent []string
+	var failed []string
+	for _, recipient := range subscription.GetRecipients() {
+		pauseURL := m.linkURL(conf.LinkBaseURL, conf.LinkSecret, subscription.ID, recipient, entity.ReportSubscriptionLinkPause)
+		unsubscribeURL := m.linkURL(conf.LinkBaseURL, conf.LinkSecret, subscription.ID, recipient, entity.ReportSubscriptionLinkUnsubscribe)
+		htmlBody, plainBody := m.mailBody(table, pauseURL, unsubscribeURL)
+		err := GetEmailModel().SendEmailWithAttachments(ctx, recipient, table.Title, htmlBody, plainBody, attachment)
+		if err != nil {
+			log.Warn(ctx, "mail report subscription failed", log.Err(err), log.String("id", subscription.ID), log.String("recipient", recipient))
+			failed = append(failed, recipient)
+			continue
+		}
+		sent = append(sent, recipient)
+	}
+
+	if len(failed) > 0 {
+		return sent, len(table.Rows), file.FileName, fmt.Errorf("mail to %s failed", strings.Join(failed, constant.StringArraySeparator))
+	}
+	return sent, len(table.Rows), file.FileName, nil
+}
+
+func (m *reportSubscriptionModel) linkURL(baseURL, secret, id, recipient string, action entity.ReportSubscriptionLinkAction) string {
+	query := url.Values{}
+	query.Set("recipient", recipient)
+	query.Set("sig", entity.ReportSubscriptionLinkSignature(secret, id, recipient, action))
+	return fmt.Sprintf("%s/v1/report_subscriptions_links/%s/%s?%s", baseURL, url.PathEscape(id), action, query.Encode())
+}
+
+// mailBody the summary and the first rows of the report, the attachment has all of them
+func (m *reportSubscriptionModel) mailBody(table *entity.ReportTable, pauseURL, unsubscribeURL string) (string, string) {
+	rows := table.Rows
+	if len(rows) > constant.ReportSubscriptionSummaryRows {
+		rows = rows[:constant.ReportSubscriptionSummaryRows]
+	}
+
+	htmlBody := new(strings.Builder)
+	plainBody := new(strings.Builder)
+	fmt.Fprintf(htmlBody, "<h3>%s</h3>", html.EscapeString(table.Title))
+	fmt.Fprintf(plainBody, "%s\n\n", table.Title)
+
+	if len(table.Summary) > 0 {
+		htmlBody.WriteString("<table>")
+		for _, item := range table.Summary {
+			fmt.Fprintf(htmlBody, "<tr><th align=\"left\">%s</th><td>%s</td></tr>", html.EscapeString(item[0]), html.EscapeString(item[1]))
+			fmt.Fprintf(plainBody, "%s: %s\n", item[0], item[1])
+		}
+		htmlBody.WriteString("</table><br/>")
+		plainBody.WriteString("\n")
+	}
+
+	if len(table.Columns) > 0 && len(rows) > 0 {
+		htmlBody.WriteString("<table border=\"1\" cellspacing=\"0\" cellpadding=\"4\"><tr>")
+		for _, column := range table.Columns {
+			fmt.Fprintf(htmlBody, "<th>%s</th>", html.EscapeString(column))
+		}
+		htmlBody.WriteString("</tr>")
+		plainBody.WriteString(strings.Join(table.Columns, "\t") + "\n")
+		for _, row := range rows {
+			cells := formatReportRow(row)
+			htmlBody.WriteString("<tr>")
+			for _, cell := range cells {
+				fmt.Fprintf(htmlBody, "<td>%s</td>", html.EscapeString(cell))
+			}
+			htmlBody.WriteString("</tr>")
+			plainBody.WriteString(strings.Join(cells, "\t") + "\n")
+		}
+		htmlBody.WriteString("</table>")
+	}
+	if len(rows) < len(table.Rows) {
+		fmt.Fprintf(htmlBody, "<p>%d of %d rows, see the attachment for all of them.</p>", len(rows), len(table.Rows))
+		fmt.Fprintf(plainBody, "\n%d of %d rows, see the attachment for all of them.\n", len(rows), len(table.Rows))
+	}
+
+	fmt.Fprintf(htmlBody, "<p><a href=\"%s\">Pause this report</a> | <a href=\"%s\">Unsubscribe</a></p>",
+		html.EscapeString(pauseURL), html.EscapeString(unsubscribeURL))
+	fmt.Fprintf(plainBody, "\nPause this report: %s\nUnsubscribe: %s\n", pauseURL, unsubscribeURL)
+
+	return htmlBody.String(), plainBody.String()
+}
+
+// getSubscription subscriptions are private to their creators
+func (m *reportSubscriptionModel) getSubscription(ctx context.Context, op *entity.Operator, id string) (*entity.ReportSubscription, error) {
+	return m.querySubscription(ctx, &da.ReportSubscriptionCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{id},
+			Valid:   true,
+		},
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		CreatorID: sql.NullString{
+			String: op.UserID,
+			Valid:  true,
+		},
+		Pager: dbo.NoPager,
+	})
+}
+
+func (m *reportSubscriptionModel) querySubscription(ctx context.Context, condition *da.ReportSubscriptionCondition) (*entity.ReportSubscription, error) {
+	var subscriptions []*entity.ReportSubscription
+	if err := da.GetReportSubscriptionDA().Query(ctx, condition, &subscriptions); err != nil {
+		log.Error(ctx, "query report subscription failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+	if len(subscriptions) <= 0 {
+		return nil, constant.ErrRecordNotFound
+	}
+
+	return subscriptions[0], nil
+}
+
+func (m *reportSubscriptionModel) convertView(subscription *entity.ReportSubscription) *entity.ReportSubscriptionView {
+	return &entity.ReportSubscriptionView{
+		ID:         subscription.ID,
+		Name:       subscription.Name,
+		ReportType: subscription.ReportType,
+		Params:     json.RawMessage(subscription.Params),
+		Format:     subscription.Format,
+		Recipients: subscription.GetRecipients(),
+		Cadence:    subscription.Cadence,
+		Weekday:    subscription.Weekday,
+		MonthDay:   subscription.MonthDay,
+		Hour:       subscription.Hour,
+		TimeZone:   subscription.TimeZone,
+		Status:     subscription.Status,
+		NextRunAt:  subscription.NextRunAt,
+		LastRunAt:  subscription.LastRunAt,
+		CreateAt:   subscription.CreateAt,
+		UpdateAt:   subscription.UpdateAt,
+	}
+}
@@ -152,7 +152,12 @@ func (m *reportTeachingLoadModel) ListTeachingLoadReport(ctx context.Context, tx
 
 func (m *reportTeachingLoadModel) GetTeacherLoadOverview(ctx context.Context, op *entity.Operator, tr entity.TimeRange, teacherIDs []string, classIDs []string) (res *entity.TeacherLoadOverview, err error) {
 	res = &entity.TeacherLoadOverview{}
-	items, err := da.GetReportDA().GetTeacherLoadItems(ctx, op, tr, teacherIDs, classIDs)
+	var items []*entity.TeacherLoadItem
+	if period, startAt, endAt, ok := GetReportRollupModel().Fresh(ctx, op.OrgID, tr); ok {
+		items, err = da.GetReportRollupDA().GetTeacherLoadItems(ctx, period, op, startAt, endAt, teacherIDs, classIDs)
+	} else {
+		items, err = da.GetReportDA().GetTeacherLoadItems(ctx, op, tr, teacherIDs, classIDs)
+	}
 	if err != nil {
 		return
 	}
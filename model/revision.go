@@ -0,0 +1,48 @@
+package model
+
+import (
+	"context"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+)
+
+type expectedRevisionKey struct{}
+
+type expectedRevision struct {
+	table    string
+	id       string
+	revision int64
+}
+
+// WithExpectedRevision the write of the resource in ctx only succeeds while it still has the revision,
+// it is set for requests with an If-Match header
+func WithExpectedRevision(ctx context.Context, table, id string, revision int64) context.Context {
+	return context.WithValue(ctx, expectedRevisionKey{}, &expectedRevision{table: table, id: id, revision: revision})
+}
+
+// increaseRevisionTx call it first in the transaction writing a resource the api serves with an ETag.
+// The row is locked until the transaction ends, so concurrent writes expecting the same revision
+// can't both succeed, the late one gets constant.ErrPreconditionFailed.
+func increaseRevisionTx(ctx context.Context, tx *dbo.DBContext, table, id string) error {
+	expected := int64(-1)
+	if e, ok := ctx.Value(expectedRevisionKey{}).(*expectedRevision); ok && e.table == table && e.id == id {
+		expected = e.revision
+	}
+
+	increased, err := da.GetRevisionDA().IncreaseTx(ctx, tx, table, id, expected)
+	if err != nil {
+		return err
+	}
+	if !increased && expected >= 0 {
+		log.Info(ctx, "revision has changed",
+			log.String("table", table),
+			log.String("id", id),
+			log.Int64("expected", expected))
+		return constant.ErrPreconditionFailed
+	}
+	return nil
+}
@@ -270,6 +270,13 @@ func (s *scheduleModel) Add(ctx context.Context, op *entity.Operator, viewData *
 			log.Debug(ctx, "end add assessment", log.Any("result", result))
 		}
 
+		GetWebhookModel().EmitTx(ctx, tx, op, entity.WebhookEventScheduleCreated, newWebhookScheduleDataList(scheduleList, "", "")...)
+
+		err = GetReportRollupModel().MarkDirtyTx(ctx, tx, reportRollupScheduleIDs(scheduleList)...)
+		if err != nil {
+			return nil, err
+		}
+
 		return result, nil
 	})
 	if err != nil {
@@ -1041,6 +1048,7 @@ func (s *scheduleModel) Update(ctx context.Context, operator *entity.Operator, v
 		)
 		return nil, err
 	}
+	AuditBefore(ctx, schedule.ID, schedule)
 
 	if schedule.IsReview || viewData.IsReview {
 		log.Error(ctx, "schedule review not support edit",
@@ -1138,7 +1146,10 @@ func (s *scheduleModel) Update(ctx context.Context, operator *entity.Operator, v
 
 	var result []*entity.Schedule
 	if err := dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
-		var err error
+		err := increaseRevisionTx(ctx, tx, constant.TableNameSchedule, schedule.ID)
+		if err != nil {
+			return err
+		}
 		// delete schedule
 		if err = s.deleteScheduleTx(ctx, tx, operator, schedule, viewData.EditType); err != nil {
 			log.Error(ctx, "update schedule: delete failed",
@@ -1181,6 +1192,13 @@ func (s *scheduleModel) Update(ctx context.Context, operator *entity.Operator, v
 			log.Debug(ctx, "end add assessment", log.Any("result", result))
 		}
 
+		GetWebhookModel().EmitTx(ctx, tx, operator, entity.WebhookEventScheduleUpdated, newWebhookScheduleDataList(result, viewData.ID, viewData.EditType)...)
+
+		err = GetReportRollupModel().MarkDirtyTx(ctx, tx, reportRollupScheduleIDs(result)...)
+		if err != nil {
+			return err
+		}
+
 		return nil
 	}); err != nil {
 		log.Error(ctx, "update schedule: tx failed", log.Err(err))
@@ -1213,6 +1231,7 @@ func (s *scheduleModel) Delete(ctx context.Context, op *entity.Operator, id stri
 		)
 		return err
 	}
+	AuditBefore(ctx, schedule.ID, schedule)
 
 	// if review schedule is pending
 	if schedule.IsReview && schedule.ReviewStatus == entity.ScheduleReviewStatusPending {
@@ -1234,8 +1253,12 @@ func (s *scheduleModel) Delete(ctx context.Context, op *entity.Operator, id stri
 	}
 
 	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		err := increaseRevisionTx(ctx, tx, constant.TableNameSchedule, schedule.ID)
+		if err != nil {
+			return err
+		}
 		// delete schedule
-		err := s.deleteScheduleTx(ctx, tx, op, schedule, editType)
+		err = s.deleteScheduleTx(ctx, tx, op, schedule, editType)
 		if err != nil {
 			log.Error(ctx, "delete schedule error",
 				log.Err(err),
@@ -1266,6 +1289,8 @@ func (s *scheduleModel) Delete(ctx context.Context, op *entity.Operator, id stri
 			}
 		}
 
+		GetWebhookModel().EmitTx(ctx, tx, op, entity.WebhookEventScheduleCancelled, newWebhookScheduleData(schedule, "", editType))
+
 		return nil
 	})
 	if err != nil {
@@ -1348,6 +1373,15 @@ func (s *scheduleModel) deleteScheduleRelationTx(ctx context.Context, tx *dbo.DB
 }
 
 func (s *scheduleModel) deleteScheduleTx(ctx context.Context, tx *dbo.DBContext, op *entity.Operator, schedule *entity.Schedule, editType entity.ScheduleEditType) error {
+	// marked before the following schedules are deleted from the table
+	if editType == entity.ScheduleEditWithFollowing && schedule.RepeatID != "" {
+		if err := GetReportRollupModel().MarkRepeatDirtyTx(ctx, tx, schedule.RepeatID, schedule.StartAt); err != nil {
+			return err
+		}
+	} else if err := GetReportRollupModel().MarkDirtyTx(ctx, tx, schedule.ID); err != nil {
+		return err
+	}
+
 	switch editType {
 	case entity.ScheduleEditOnlyCurrent:
 		if err := da.GetScheduleDA().SoftDelete(ctx, tx, schedule.ID, op); err != nil {
@@ -2204,6 +2238,7 @@ func (s *scheduleModel) UpdateScheduleStatus(ctx context.Context, tx *dbo.DBCont
 		return constant.ErrRecordNotFound
 	}
 
+	previousStatus := schedule.Status
 	schedule.Status = status
 	_, err = da.GetScheduleDA().UpdateTx(ctx, tx, schedule)
 	if err != nil {
@@ -2214,6 +2249,23 @@ func (s *scheduleModel) UpdateScheduleStatus(ctx context.Context, tx *dbo.DBCont
 		)
 		return err
 	}
+	if previousStatus != status {
+		err = GetScheduleEventBusModel().PubStatusChangedTx(ctx, tx, operator, &entity.ScheduleStatusChangedEvent{
+			ScheduleID:     schedule.ID,
+			OrgID:          schedule.OrgID,
+			Status:         status,
+			PreviousStatus: previousStatus,
+			ChangedAt:      time.Now().Unix(),
+		})
+		if err != nil {
+			log.Error(ctx, "UpdateScheduleStatus: publish status changed event error",
+				log.String("id", id),
+				log.Any("schedule", schedule),
+				log.Err(err),
+			)
+			return err
+		}
+	}
 	//err = da.GetScheduleRedisDA().Clean(ctx, operator, []string{id})
 	//if err != nil {
 	//	log.Info(ctx, "UpdateScheduleStatus:GetScheduleRedisDA.Clean error", log.Err(err))
@@ -4697,7 +4749,7 @@ func (s *scheduleModel) transformToScheduleTimeView(ctx context.Context, operato
 				switch assessment.Status {
 				case v2.AssessmentStatusComplete:
 					assessmentStatusMap[assessment.ScheduleID] = entity.AssessmentStatusComplete
-				case v2.AssessmentStatusInDraft, v2.AssessmentStatusStarted:
+				case v2.AssessmentStatusInDraft, v2.AssessmentStatusStarted, v2.AssessmentStatusPendingModeration:
 					assessmentStatusMap[assessment.ScheduleID] = entity.AssessmentStatusInProgress
 				}
 			}
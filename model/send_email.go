@@ -2,14 +2,25 @@ package model
 
 import (
 	"context"
+	"io"
 	"sync"
 
 	"github.com/KL-Engineering/common-log/log"
 	"gopkg.in/gomail.v2"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
 )
 
 type IEmailModel interface {
 	SendEmail(ctx context.Context, recipient string, title string, html string, plain string) error
+	SendEmailWithAttachments(ctx context.Context, recipient string, title string, html string, plain string, attachments ...*EmailAttachment) error
+}
+
+// EmailAttachment file attached to a mail
+type EmailAttachment struct {
+	FileName    string
+	ContentType string
+	Data        []byte
 }
 
 // AwsSesModel ses model
@@ -23,9 +34,23 @@ type AwsSesModel struct {
 }
 
 func (ses AwsSesModel) SendEmail(ctx context.Context, recipient string, title string, html string, plain string) error {
+	return ses.SendEmailWithAttachments(ctx, recipient, title, html, plain)
+}
+
+func (ses AwsSesModel) SendEmailWithAttachments(ctx context.Context, recipient string, title string, html string, plain string, attachments ...*EmailAttachment) error {
 
 	m := gomail.NewMessage()
 
+	for _, attachment := range attachments {
+		data := attachment.Data
+		m.Attach(attachment.FileName,
+			gomail.SetHeader(map[string][]string{"Content-Type": {attachment.ContentType}}),
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			}))
+	}
+
 	m.SetBody("text/html", html)
 
 	m.AddAlternative("text/plain", plain)
@@ -47,7 +72,7 @@ func (ses AwsSesModel) SendEmail(ctx context.Context, recipient string, title st
 	// Display an error message if something goes wrong; otherwise,
 	// display a message confirming that the message was sent.
 	if err := d.DialAndSend(m); err != nil {
-		log.Error(ctx, "SendEmail: DialAndSend failed", log.String("recipient", recipient), log.Any("ses", ses), log.Err(err))
+		log.Error(ctx, "SendEmail: DialAndSend failed", log.String("recipient", recipient), log.String("host", ses.Host), log.Int("attachments", len(attachments)), log.Err(err))
 		return err
 	}
 	return nil
@@ -60,15 +85,15 @@ var (
 
 // GetEmailModel get user logic
 func GetEmailModel() IEmailModel {
-	// TODO
 	_emailOnce.Do(func() {
+		cfg := config.Get().Email
 		_emailModel = &AwsSesModel{
-			Host:          "", // config.Get().PrivateConfig.Social.Email.Host,
-			Port:          0,  // config.Get().PrivateConfig.Social.Email.Port,
-			User:          "", // config.Get().PrivateConfig.Social.Email.UserName,
-			Password:      "", // config.Get().PrivateConfig.Social.Email.Secret,
-			SenderAddress: "", // config.Get().PrivateConfig.Social.Email.Address,
-			SenderName:    "", //config.Get().PrivateConfig.Social.Email.SenderName,
+			Host:          cfg.Host,
+			Port:          cfg.Port,
+			User:          cfg.User,
+			Password:      cfg.Password,
+			SenderAddress: cfg.SenderAddress,
+			SenderName:    cfg.SenderName,
 		}
 	})
 	return _emailModel
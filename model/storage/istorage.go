@@ -29,6 +29,8 @@ const (
 	TeacherManualStoragePartition      StoragePartition = "teacher_manual"
 	ScheduleAttachmentStoragePartition StoragePartition = "schedule_attachment"
 	DrawingFeedbackStoragePartition    StoragePartition = "drawing_feedback"
+	// ReportExportStoragePartition written by report export jobs only, not open to the upload api
+	ReportExportStoragePartition StoragePartition = "report_export"
 )
 
 type StoragePartition string
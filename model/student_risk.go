@@ -0,0 +1,687 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/mutex"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+type IStudentRiskModel interface {
+	GetSetting(ctx context.Context, op *entity.Operator) (*entity.StudentRiskSettingView, error)
+	UpdateSetting(ctx context.Context, op *entity.Operator, req *entity.StudentRiskSettingUpdateReq) error
+
+	// QueryClass the latest evaluation of students of a class, the highest score first
+	QueryClass(ctx context.Context, op *entity.Operator, req *entity.StudentRiskQueryReq) (*entity.StudentRiskClassReply, error)
+	// QueryHistory flag changes of a class, latest first
+	QueryHistory(ctx context.Context, op *entity.Operator, req *entity.StudentRiskHistoryQueryReq) (*entity.StudentRiskHistoryPageReply, error)
+
+	// Evaluate students of a batch of the classes due, which have schedules in the window of enabled organizations
+	// and are not evaluated in the interval, returns the number of classes
+	Evaluate(ctx context.Context) (int, error)
+}
+
+type studentRiskModel struct{}
+
+var (
+	_studentRiskModelOnce sync.Once
+	_studentRiskModel     IStudentRiskModel
+)
+
+func GetStudentRiskModel() IStudentRiskModel {
+	_studentRiskModelOnce.Do(func() {
+		_studentRiskModel = &studentRiskModel{}
+	})
+	return _studentRiskModel
+}
+
+// StartStudentRiskWorker evaluate at-risk students regularly, one instance at a time
+func StartStudentRiskWorker(ctx context.Context) {
+	// a tick evaluates a batch only, the classes are evaluated once in the interval over the ticks
+	tick := constant.StudentRiskEvaluateTick
+	if interval := config.Get().StudentRisk.EvaluateInterval; interval < tick {
+		tick = interval
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		evaluateStudentRisks(utils.CloneContextWithTrace(ctx))
+	}
+}
+
+func evaluateStudentRisks(ctx context.Context) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(ctx, "evaluate student risks panic", log.Any("recover error", err))
+		}
+	}()
+
+	locker, err := mutex.NewLock(ctx, da.RedisKeyPrefixStudentRiskEvaluateLock)
+	if err != nil {
+		log.Error(ctx, "evaluate student risks: create lock failed", log.Err(err))
+		return
+	}
+	locker.Lock()
+	defer locker.Unlock()
+
+	count, err := GetStudentRiskModel().Evaluate(ctx)
+	if err != nil {
+		log.Error(ctx, "evaluate student risks failed", log.Err(err))
+		return
+	}
+
+	if count > 0 {
+		log.Info(ctx, "evaluate student risks finished", log.Int("classes", count))
+	}
+}
+
+// checkPermission the operator needs any of the permissions
+func (m *studentRiskModel) checkPermission(ctx context.Context, op *entity.Operator, permissions ...external.PermissionName) error {
+	perms, err := external.GetPermissionServiceProvider().HasOrganizationPermissions(ctx, op, permissions)
+	if err != nil {
+		log.Error(ctx, "check student risk permission failed", log.Err(err), log.Any("operator", op), log.Any("permissions", permissions))
+		return err
+	}
+
+	for _, permission := range permissions {
+		if perms[permission] {
+			return nil
+		}
+	}
+
+	log.Warn(ctx, "user has no student risk permission", log.Any("operator", op), log.Any("permissions", permissions))
+	return constant.ErrForbidden
+}
+
+// checkClass the class must be one of the student progress reports the operator can view
+func (m *studentRiskModel) checkClass(ctx context.Context, op *entity.Operator, classID string) error {
+	if err := m.checkPermission(ctx, op, external.ReportStudentProgressReportView); err != nil {
+		return err
+	}
+
+	classIDs, err := GetReportModel().GetClassIDsCanViewReports(ctx, op, external.TeacherViewPermissionParams{
+		ViewOrgReports:    external.ReportStudentProgressReportOrganization,
+		ViewSchoolReports: external.ReportStudentProgressReportSchool,
+		ViewMyReports:     external.ReportStudentProgressReportTeacher,
+	})
+	if err != nil {
+		log.Error(ctx, "get classes can view reports failed", log.Err(err), log.Any("operator", op))
+		return err
+	}
+
+	if !utils.ContainsString(classIDs, classID) {
+		log.Warn(ctx, "user can not view the class", log.Any("operator", op), log.String("class_id", classID))
+		return constant.ErrForbidden
+	}
+
+	return nil
+}
+
+func (m *studentRiskModel) getSetting(ctx context.Context, orgID string) (*entity.StudentRiskSetting, error) {
+	setting := new(entity.StudentRiskSetting)
+	err := da.GetStudentRiskSettingDA().Get(ctx, orgID, setting)
+	if err == dbo.ErrRecordNotFound {
+		return nil, constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get student risk setting failed", log.Err(err), log.String("org_id", orgID))
+		return nil, err
+	}
+
+	return setting, nil
+}
+
+func (m *studentRiskModel) GetSetting(ctx context.Context, op *entity.Operator) (*entity.StudentRiskSettingView, error) {
+	if err := m.checkPermission(ctx, op, external.ManageStudentRiskSettings10904, external.ReportStudentProgressReportView); err != nil {
+		return nil, err
+	}
+
+	setting, err := m.getSetting(ctx, op.OrgID)
+	if err == constant.ErrRecordNotFound {
+		setting = entity.NewDefaultStudentRiskSetting(op.OrgID)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &entity.StudentRiskSettingView{
+		Enabled:             setting.Enabled,
+		WindowDays:          setting.WindowDays,
+		AttendanceWeight:    setting.AttendanceWeight,
+		AssignmentWeight:    setting.AssignmentWeight,
+		OutcomeWeight:       setting.OutcomeWeight,
+		AttendanceThreshold: setting.AttendanceThreshold,
+		AssignmentThreshold: setting.AssignmentThreshold,
+		OutcomeThreshold:    setting.OutcomeThreshold,
+		RiskThreshold:       setting.RiskThreshold,
+		MinSamples:          setting.MinSamples,
+		NotifyRecipients:    setting.GetNotifyRecipients(),
+		UpdateAt:            setting.UpdateAt,
+	}, nil
+}
+
+func (m *studentRiskModel) UpdateSetting(ctx context.Context, op *entity.Operator, req *entity.StudentRiskSettingUpdateReq) error {
+	if !req.Valid() {
+		log.Warn(ctx, "student risk setting update request invalid", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op, external.ManageStudentRiskSettings10904); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	setting, err := m.getSetting(ctx, op.OrgID)
+	exists := err == nil
+	if err == constant.ErrRecordNotFound {
+		setting = &entity.StudentRiskSetting{
+			OrgID:    op.OrgID,
+			CreateAt: now,
+		}
+	} else if err != nil {
+		return err
+	}
+
+	setting.Enabled = req.Enabled
+	setting.WindowDays = req.WindowDays
+	setting.AttendanceWeight = req.AttendanceWeight
+	setting.AssignmentWeight = req.AssignmentWeight
+	setting.OutcomeWeight = req.OutcomeWeight
+	setting.AttendanceThreshold = req.AttendanceThreshold
+	setting.AssignmentThreshold = req.AssignmentThreshold
+	setting.OutcomeThreshold = req.OutcomeThreshold
+	setting.RiskThreshold = req.RiskThreshold
+	setting.MinSamples = req.MinSamples
+	setting.NotifyRecipients = strings.Join(req.NotifyRecipients, constant.StringArraySeparator)
+	setting.UpdaterID = op.UserID
+	setting.UpdateAt = now
+
+	if !exists {
+		if _, err := da.GetStudentRiskSettingDA().Insert(ctx, setting); err != nil {
+			log.Error(ctx, "insert student risk setting failed", log.Err(err), log.Any("setting", setting))
+			return err
+		}
+		return nil
+	}
+
+	if _, err := da.GetStudentRiskSettingDA().Update(ctx, setting); err != nil {
+		log.Error(ctx, "update student risk setting failed", log.Err(err), log.Any("setting", setting))
+		return err
+	}
+
+	return nil
+}
+
+func (m *studentRiskModel) QueryClass(ctx context.Context, op *entity.Operator, req *entity.StudentRiskQueryReq) (*entity.StudentRiskClassReply, error) {
+	if req.ClassID == "" {
+		log.Warn(ctx, "student risk query request invalid", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkClass(ctx, op, req.ClassID); err != nil {
+		return nil, err
+	}
+
+	condition := &da.StudentRiskFlagCondition{
+		OrgID:    sql.NullString{String: op.OrgID, Valid: true},
+		ClassIDs: entity.NullStrings{Strings: []string{req.ClassID}, Valid: true},
+		AtRisk:   sql.NullBool{Bool: true, Valid: req.AtRiskOnly},
+	}
+	var flags []*entity.StudentRiskFlag
+	if err := da.GetStudentRiskFlagDA().Query(ctx, condition, &flags); err != nil {
+		log.Error(ctx, "query student risk flags failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	names := m.studentNames(ctx, op, req.ClassID)
+	result := &entity.StudentRiskClassReply{
+		ClassID:  req.ClassID,
+		Students: make([]*entity.StudentRiskView, 0, len(flags)),
+	}
+	for _, flag := range flags {
+		result.Students = append(result.Students, &entity.StudentRiskView{
+			StudentID:   flag.StudentID,
+			StudentName: names[flag.StudentID],
+			AtRisk:      flag.AtRisk,
+			Score:       flag.Score,
+			Signals:     m.unmarshalSignals(ctx, flag.Signals),
+			Reasons:     m.unmarshalReasons(ctx, flag.Reasons),
+			WindowStart: flag.WindowStart,
+			WindowEnd:   flag.WindowEnd,
+			ChangedAt:   flag.ChangedAt,
+			EvaluatedAt: flag.UpdateAt,
+		})
+	}
+
+	return result, nil
+}
+
+func (m *studentRiskModel) QueryHistory(ctx context.Context, op *entity.Operator, req *entity.StudentRiskHistoryQueryReq) (*entity.StudentRiskHistoryPageReply, error) {
+	if req.ClassID == "" {
+		log.Warn(ctx, "student risk history query request invalid", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkClass(ctx, op, req.ClassID); err != nil {
+		return nil, err
+	}
+
+	condition := &da.StudentRiskFlagHistoryCondition{
+		OrgID:     sql.NullString{String: op.OrgID, Valid: true},
+		ClassID:   sql.NullString{String: req.ClassID, Valid: true},
+		StudentID: sql.NullString{String: req.StudentID, Valid: req.StudentID != ""},
+		Pager: dbo.Pager{
+			Page:     req.PageIndex,
+			PageSize: req.PageSize,
+		},
+	}
+	var histories []*entity.StudentRiskFlagHistory
+	total, err := da.GetStudentRiskFlagHistoryDA().Page(ctx, condition, &histories)
+	if err != nil {
+		log.Error(ctx, "page student risk histories failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	result := &entity.StudentRiskHistoryPageReply{
+		Total: total,
+		Data:  make([]*entity.StudentRiskHistoryView, 0, len(histories)),
+	}
+	for _, history := range histories {
+		result.Data = append(result.Data, &entity.StudentRiskHistoryView{
+			ID:          history.ID,
+			StudentID:   history.StudentID,
+			AtRisk:      history.AtRisk,
+			Score:       history.Score,
+			Signals:     m.unmarshalSignals(ctx, history.Signals),
+			Reasons:     m.unmarshalReasons(ctx, history.Reasons),
+			WindowStart: history.WindowStart,
+			WindowEnd:   history.WindowEnd,
+			CreateAt:    history.CreateAt,
+		})
+	}
+
+	return result, nil
+}
+
+func (m *studentRiskModel) Evaluate(ctx context.Context) (int, error) {
+	var settings []*entity.StudentRiskSetting
+	condition := &da.StudentRiskSettingCondition{
+		Enabled: sql.NullBool{Bool: true, Valid: true},
+	}
+	if err := da.GetStudentRiskSettingDA().Query(ctx, condition, &settings); err != nil {
+		log.Error(ctx, "query student risk settings failed", log.Err(err))
+		return 0, err
+	}
+
+	now := time.Now().Unix()
+	dueBefore := now - int64(config.Get().StudentRisk.EvaluateInterval/time.Second)
+	_ = da.GetStudentRiskRedisDA().Forget(ctx, dueBefore)
+
+	// the classes left are evaluated at the next ticks
+	deadline := time.Now().Add(constant.StudentRiskEvaluateTimeout)
+	attempts, count := 0, 0
+	full := func() bool {
+		return attempts >= constant.StudentRiskEvaluateBatchSize || time.Now().After(deadline)
+	}
+	for _, setting := range settings {
+		if full() {
+			break
+		}
+
+		windowStart := now - int64(setting.WindowDays)*24*60*60
+		classIDs, err := da.GetReportDA().GetStudentRiskClassIDs(ctx, setting.OrgID, windowStart, now)
+		if err != nil {
+			// other organizations are still evaluated
+			log.Error(ctx, "query classes to evaluate student risks failed", log.Err(err), log.String("org_id", setting.OrgID))
+			continue
+		}
+		// flags of classes without schedules in the window any more are cleared by their evaluation
+		flaggedClassIDs, err := da.GetStudentRiskFlagDA().GetAtRiskClassIDs(ctx, setting.OrgID)
+		if err != nil {
+			log.Error(ctx, "query classes with students at risk failed", log.Err(err), log.String("org_id", setting.OrgID))
+			continue
+		}
+		classIDs = utils.SliceDeduplicationExcludeEmpty(append(classIDs, flaggedClassIDs...))
+
+		// every class is due if redis fails, the batch is bounded anyway
+		evaluatedAt, _ := da.GetStudentRiskRedisDA().GetEvaluatedAt(ctx, classIDs)
+		for _, classID := range classIDs {
+			if evaluatedAt[classID] > dueBefore {
+				continue
+			}
+			if full() {
+				break
+			}
+
+			attempts++
+			err := m.evaluateClass(ctx, setting, classID, windowStart, now)
+			// a failed class waits for the interval too, so that it can't take every batch
+			_ = da.GetStudentRiskRedisDA().SetEvaluatedAt(ctx, classID, now)
+			if err != nil {
+				log.Error(ctx, "evaluate student risks of class failed", log.Err(err), log.String("org_id", setting.OrgID), log.String("class_id", classID))
+				continue
+			}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// evaluateClass save the evaluation of students of the class, changes are kept in the history and notified
+func (m *studentRiskModel) evaluateClass(ctx context.Context, setting *entity.StudentRiskSetting, classID string, windowStart, windowEnd int64) error {
+	signals, err := m.querySignals(ctx, classID, windowStart, windowEnd)
+	if err != nil {
+		return err
+	}
+
+	var flags []*entity.StudentRiskFlag
+	condition := &da.StudentRiskFlagCondition{
+		OrgID:    sql.NullString{String: setting.OrgID, Valid: true},
+		ClassIDs: entity.NullStrings{Strings: []string{classID}, Valid: true},
+	}
+	if err := da.GetStudentRiskFlagDA().Query(ctx, condition, &flags); err != nil {
+		log.Error(ctx, "query student risk flags failed", log.Err(err), log.Any("condition", condition))
+		return err
+	}
+	existing := make(map[string]*entity.StudentRiskFlag, len(flags))
+	for _, flag := range flags {
+		existing[flag.StudentID] = flag
+	}
+
+	// students flagged before without anything in the window are cleared
+	for studentID := range existing {
+		if _, ok := signals[studentID]; !ok {
+			signals[studentID] = &entity.StudentRiskSignals{}
+		}
+	}
+
+	var changed []*entity.StudentRiskFlag
+	var changedReasons [][]*entity.StudentRiskReason
+	op := &entity.Operator{OrgID: setting.OrgID}
+	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		changed, changedReasons = nil, nil
+		for studentID, studentSignals := range signals {
+			evaluation := setting.Evaluate(studentSignals)
+			signalsJSON, err := json.Marshal(studentSignals)
+			if err != nil {
+				return err
+			}
+			reasonsJSON, err := json.Marshal(evaluation.Reasons)
+			if err != nil {
+				return err
+			}
+
+			flag, ok := existing[studentID]
+			if !ok {
+				flag = &entity.StudentRiskFlag{
+					ID:        utils.NewID(),
+					OrgID:     setting.OrgID,
+					ClassID:   classID,
+					StudentID: studentID,
+					CreateAt:  windowEnd,
+				}
+			}
+			isChanged := flag.AtRisk != evaluation.AtRisk
+			if isChanged || !ok {
+				flag.ChangedAt = windowEnd
+			}
+			flag.AtRisk = evaluation.AtRisk
+			flag.Score = evaluation.Score
+			flag.Signals = string(signalsJSON)
+			flag.Reasons = string(reasonsJSON)
+			flag.WindowStart = windowStart
+			flag.WindowEnd = windowEnd
+			flag.UpdateAt = windowEnd
+
+			// the flag may be saved by an evaluation meanwhile, it is updated then
+			if err := da.GetStudentRiskFlagDA().UpsertTx(ctx, tx, flag); err != nil {
+				return err
+			}
+
+			// a new student not at risk is not a change
+			if !isChanged {
+				continue
+			}
+
+			history := &entity.StudentRiskFlagHistory{
+				ID:          utils.NewID(),
+				OrgID:       flag.OrgID,
+				ClassID:     flag.ClassID,
+				StudentID:   flag.StudentID,
+				AtRisk:      flag.AtRisk,
+				Score:       flag.Score,
+				Signals:     flag.Signals,
+				Reasons:     flag.Reasons,
+				WindowStart: flag.WindowStart,
+				WindowEnd:   flag.WindowEnd,
+				CreateAt:    windowEnd,
+			}
+			if _, err := da.GetStudentRiskFlagHistoryDA().InsertTx(ctx, tx, history); err != nil {
+				log.Error(ctx, "insert student risk history failed", log.Err(err), log.Any("history", history))
+				return err
+			}
+
+			GetWebhookModel().EmitTx(ctx, tx, op, entity.WebhookEventStudentRiskChanged, &entity.WebhookStudentRiskData{
+				ClassID:   flag.ClassID,
+				StudentID: flag.StudentID,
+				AtRisk:    flag.AtRisk,
+				Score:     flag.Score,
+				Reasons:   evaluation.Reasons,
+			})
+
+			changed = append(changed, flag)
+			changedReasons = append(changedReasons, evaluation.Reasons)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(changed) > 0 {
+		m.notify(ctx, op, setting, classID, changed, changedReasons)
+	}
+
+	return nil
+}
+
+// querySignals signals of students of the class in the window, same as the student progress reports
+func (m *studentRiskModel) querySignals(ctx context.Context, classID string, windowStart, windowEnd int64) (map[string]*entity.StudentRiskSignals, error) {
+	subjectIDs, err := da.GetReportDA().GetStudentRiskSubjectIDs(ctx, classID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*entity.StudentRiskSignals)
+	get := func(studentID string) *entity.StudentRiskSignals {
+		if _, ok := result[studentID]; !ok {
+			result[studentID] = &entity.StudentRiskSignals{}
+		}
+		return result[studentID]
+	}
+	if len(subjectIDs) == 0 {
+		return result, nil
+	}
+
+	duration := entity.TimeRange(fmt.Sprintf("%d-%d", windowStart, windowEnd))
+
+	attendances, err := da.GetReportDA().GetClassAttendance(ctx, &entity.ClassAttendanceQueryParameters{
+		ClassID:    classID,
+		SubjectIDS: subjectIDs,
+		Duration:   duration,
+	})
+	if err != nil {
+		log.Error(ctx, "get class attendance failed", log.Err(err), log.String("class_id", classID))
+		return nil, err
+	}
+	attended := make(map[string]int64)
+	for _, item := range attendances {
+		signals := get(item.StudentID)
+		if signals.Attendance == nil {
+			signals.Attendance = &entity.StudentRiskSignal{}
+		}
+		signals.Attendance.Samples++
+		if item.IsAttendance {
+			attended[item.StudentID]++
+		}
+	}
+
+	assignments, err := da.GetReportDA().ListAssignments(ctx, &entity.Operator{}, &entity.AssignmentRequest{
+		ClassID:               classID,
+		SelectedSubjectIDList: subjectIDs,
+		Durations:             []entity.TimeRange{duration},
+	})
+	if err != nil {
+		return nil, err
+	}
+	finished := make(map[string]int64)
+	for _, item := range assignments {
+		signals := get(item.StudentID)
+		if signals.Assignment == nil {
+			signals.Assignment = &entity.StudentRiskSignal{}
+		}
+		signals.Assignment.Samples += item.Total
+		finished[item.StudentID] += item.Finish
+	}
+
+	outcomes, err := da.GetReportDA().GetStudentProgressLearnOutcomeCountByStudentAndSubject(ctx, &entity.LearnOutcomeAchievementRequest{
+		ClassID:               classID,
+		SelectedSubjectIDList: subjectIDs,
+		Durations:             []entity.TimeRange{duration},
+	})
+	if err != nil {
+		log.Error(ctx, "get learn outcome counts failed", log.Err(err), log.String("class_id", classID))
+		return nil, err
+	}
+	achieved := make(map[string]int64)
+	for _, item := range outcomes {
+		signals := get(item.StudentID)
+		if signals.Outcome == nil {
+			signals.Outcome = &entity.StudentRiskSignal{}
+		}
+		signals.Outcome.Samples += item.CompletedCount
+		achieved[item.StudentID] += item.AchievedCount
+	}
+
+	rate := func(count, total int64) float64 {
+		if total <= 0 {
+			return 0
+		}
+		return math.Round(float64(count)/float64(total)*1000) / 1000
+	}
+	for studentID, signals := range result {
+		if signals.Attendance != nil {
+			signals.Attendance.Rate = rate(attended[studentID], signals.Attendance.Samples)
+		}
+		if signals.Assignment != nil {
+			signals.Assignment.Rate = rate(finished[studentID], signals.Assignment.Samples)
+		}
+		if signals.Outcome != nil {
+			signals.Outcome.Rate = rate(achieved[studentID], signals.Outcome.Samples)
+		}
+	}
+
+	return result, nil
+}
+
+// notify mail the changes of the class to recipients of the setting, failures are logged only
+func (m *studentRiskModel) notify(ctx context.Context, op *entity.Operator, setting *entity.StudentRiskSetting, classID string, flags []*entity.StudentRiskFlag, reasons [][]*entity.StudentRiskReason) {
+	recipients := setting.GetNotifyRecipients()
+	if len(recipients) == 0 {
+		return
+	}
+
+	names := m.studentNames(ctx, op, classID)
+	className := classID
+	classes, err := external.GetClassServiceProvider().BatchGetNameMap(ctx, op, []string{classID})
+	if err == nil && classes[classID] != "" {
+		className = classes[classID]
+	}
+
+	title := fmt.Sprintf("Student risk changes of %s", className)
+	var plain, body strings.Builder
+	plain.WriteString(title + "\n\n")
+	body.WriteString("<h3>" + html.EscapeString(title) + "</h3><ul>")
+	for i, flag := range flags {
+		name := names[flag.StudentID]
+		if name == "" {
+			name = flag.StudentID
+		}
+		status := "is no longer at risk"
+		if flag.AtRisk {
+			status = fmt.Sprintf("is at risk, score %.2f", flag.Score)
+		}
+		messages := make([]string, 0, len(reasons[i]))
+		for _, reason := range reasons[i] {
+			messages = append(messages, reason.Message)
+		}
+
+		line := name + " " + status
+		if len(messages) > 0 {
+			line += ": " + strings.Join(messages, "; ")
+		}
+		plain.WriteString("- " + line + "\n")
+		body.WriteString("<li>" + html.EscapeString(line) + "</li>")
+	}
+	body.WriteString("</ul>")
+
+	for _, recipient := range recipients {
+		if err := GetEmailModel().SendEmail(ctx, recipient, title, body.String(), plain.String()); err != nil {
+			log.Warn(ctx, "send student risk email failed", log.Err(err), log.String("class_id", classID), log.String("recipient", recipient))
+		}
+	}
+}
+
+// studentNames names of students of the class, ids are shown when the lookup fails
+func (m *studentRiskModel) studentNames(ctx context.Context, op *entity.Operator, classID string) map[string]string {
+	names := make(map[string]string)
+	students, err := external.GetStudentServiceProvider().GetByClassID(ctx, op, classID)
+	if err != nil {
+		log.Warn(ctx, "get students of class failed", log.Err(err), log.String("class_id", classID))
+		return names
+	}
+
+	for _, student := range students {
+		names[student.ID] = student.Name()
+	}
+	return names
+}
+
+func (m *studentRiskModel) unmarshalSignals(ctx context.Context, data string) *entity.StudentRiskSignals {
+	signals := new(entity.StudentRiskSignals)
+	if data == "" {
+		return signals
+	}
+	if err := json.Unmarshal([]byte(data), signals); err != nil {
+		log.Warn(ctx, "unmarshal student risk signals failed", log.Err(err), log.String("signals", data))
+	}
+	return signals
+}
+
+func (m *studentRiskModel) unmarshalReasons(ctx context.Context, data string) []*entity.StudentRiskReason {
+	reasons := []*entity.StudentRiskReason{}
+	if data == "" {
+		return reasons
+	}
+	if err := json.Unmarshal([]byte(data), &reasons); err != nil {
+		log.Warn(ctx, "unmarshal student risk reasons failed", log.Err(err), log.String("reasons", data))
+	}
+	return reasons
+}
@@ -0,0 +1,395 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+type IStudentUsageIngestModel interface {
+	// Submit validates the events and queues a record for each student of them,
+	// nil events are skipped, ErrExceededLimit if the queue can not take all the records
+	Submit(ctx context.Context, op *entity.Operator, events []*entity.StudentUsageRecord) (*entity.StudentUsageIngestResult, error)
+	Stats() *entity.StudentUsageIngestStats
+}
+
+// studentUsageIngestItem a queued record, the rooms are resolved with the operator who submitted it
+type studentUsageIngestItem struct {
+	op     *entity.Operator
+	record *entity.StudentUsageRecord
+}
+
+type studentUsageIngestCounters struct {
+	received   int64
+	accepted   int64
+	rejected   int64
+	duplicated int64
+	throttled  int64
+	skipped    int64
+	written    int64
+	failed     int64
+	// deadLettered failed records kept for the replay tool
+	deadLettered int64
+	batches      int64
+
+	lastFlushAt      int64
+	lastFlushMillis  int64
+	lastFlushRecords int64
+}
+
+type studentUsageIngestModel struct {
+	queue chan *studentUsageIngestItem
+	// enqueueMutex a batch is queued as a whole or refused
+	enqueueMutex sync.Mutex
+	// stopped nothing is queued once the workers are draining the queue for the shutdown
+	stopped  bool
+	counters studentUsageIngestCounters
+}
+
+var (
+	_studentUsageIngestModelOnce sync.Once
+	_studentUsageIngestModel     *studentUsageIngestModel
+)
+
+func GetStudentUsageIngestModel() IStudentUsageIngestModel {
+	return getStudentUsageIngestModel()
+}
+
+func getStudentUsageIngestModel() *studentUsageIngestModel {
+	_studentUsageIngestModelOnce.Do(func() {
+		_studentUsageIngestModel = &studentUsageIngestModel{
+			queue: make(chan *studentUsageIngestItem, config.Get().StudentUsageIngest.QueueSize),
+		}
+	})
+	return _studentUsageIngestModel
+}
+
+// StartStudentUsageIngestWorker writes the queued records in batches until ctx is done,
+// it returns after the records accepted before have been written
+func StartStudentUsageIngestWorker(ctx context.Context) {
+	m := getStudentUsageIngestModel()
+	conf := config.Get().StudentUsageIngest
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < conf.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.consume(ctx, conf.BatchSize, conf.FlushInterval)
+		}()
+	}
+	wg.Wait()
+}
+
+func (m *studentUsageIngestModel) Submit(ctx context.Context, op *entity.Operator, events []*entity.StudentUsageRecord) (*entity.StudentUsageIngestResult, error) {
+	result := &entity.StudentUsageIngestResult{
+		Rejected: []*entity.StudentUsageIngestRejection{},
+	}
+
+	keys := make(map[string]bool)
+	var items []*studentUsageIngestItem
+	for i, event := range events {
+		if event == nil {
+			continue
+		}
+		atomic.AddInt64(&m.counters.received, 1)
+
+		if reason := event.IngestInvalidReason(); reason != "" {
+			result.Rejected = append(result.Rejected, &entity.StudentUsageIngestRejection{Index: i, Reason: reason})
+			continue
+		}
+
+		for _, record := range event.SplitByStudent() {
+			key := record.IngestKey()
+			if keys[key] {
+				result.Duplicated++
+				continue
+			}
+			keys[key] = true
+			items = append(items, &studentUsageIngestItem{op: op, record: record})
+		}
+	}
+	atomic.AddInt64(&m.counters.rejected, int64(len(result.Rejected)))
+	atomic.AddInt64(&m.counters.duplicated, int64(result.Duplicated))
+
+	m.enqueueMutex.Lock()
+	defer m.enqueueMutex.Unlock()
+
+	if m.stopped {
+		atomic.AddInt64(&m.counters.throttled, int64(len(items)))
+		log.Warn(ctx, "student usage ingest is stopping", log.Int("records", len(items)))
+		return nil, constant.ErrExceededLimit
+	}
+
+	// only the consumers take records out meanwhile, so the sends below never block
+	if len(m.queue)+len(items) > cap(m.queue) {
+		atomic.AddInt64(&m.counters.throttled, int64(len(items)))
+		log.Warn(ctx, "student usage ingest queue is full",
+			log.Int("records", len(items)),
+			log.Int("queue_length", len(m.queue)),
+			log.Int("queue_capacity", cap(m.queue)))
+		return nil, constant.ErrExceededLimit
+	}
+	for _, item := range items {
+		m.queue <- item
+	}
+
+	result.Accepted = len(items)
+	atomic.AddInt64(&m.counters.accepted, int64(len(items)))
+	return result, nil
+}
+
+func (m *studentUsageIngestModel) Stats() *entity.StudentUsageIngestStats {
+	return &entity.StudentUsageIngestStats{
+		Received:         atomic.LoadInt64(&m.counters.received),
+		Accepted:         atomic.LoadInt64(&m.counters.accepted),
+		Rejected:         atomic.LoadInt64(&m.counters.rejected),
+		Duplicated:       atomic.LoadInt64(&m.counters.duplicated),
+		Throttled:        atomic.LoadInt64(&m.counters.throttled),
+		Skipped:          atomic.LoadInt64(&m.counters.skipped),
+		Written:          atomic.LoadInt64(&m.counters.written),
+		Failed:           atomic.LoadInt64(&m.counters.failed),
+		DeadLettered:     atomic.LoadInt64(&m.counters.deadLettered),
+		Batches:          atomic.LoadInt64(&m.counters.batches),
+		QueueLength:      len(m.queue),
+		QueueCapacity:    cap(m.queue),
+		LastFlushAt:      atomic.LoadInt64(&m.counters.lastFlushAt),
+		LastFlushMillis:  atomic.LoadInt64(&m.counters.lastFlushMillis),
+		LastFlushRecords: atomic.LoadInt64(&m.counters.lastFlushRecords),
+	}
+}
+
+// consume flushes a batch when it is full or the interval passes
+func (m *studentUsageIngestModel) consume(ctx context.Context, batchSize int, flushInterval time.Duration) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*studentUsageIngestItem, 0, batchSize)
+	for {
+		select {
+		case item := <-m.queue:
+			batch = append(batch, item)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-ctx.Done():
+			m.drain(utils.CloneContextWithTrace(ctx), batch, batchSize)
+			return
+		}
+
+		m.flush(utils.CloneContextWithTrace(ctx), batch)
+		batch = make([]*studentUsageIngestItem, 0, batchSize)
+	}
+}
+
+// drain stops queueing and writes the batch and the records left in the queue, ctx must not be the cancelled one
+func (m *studentUsageIngestModel) drain(ctx context.Context, batch []*studentUsageIngestItem, batchSize int) {
+	m.enqueueMutex.Lock()
+	m.stopped = true
+	m.enqueueMutex.Unlock()
+
+	for {
+		select {
+		case item := <-m.queue:
+			batch = append(batch, item)
+			if len(batch) < batchSize {
+				continue
+			}
+			m.flush(ctx, batch)
+			batch = make([]*studentUsageIngestItem, 0, batchSize)
+		default:
+			if len(batch) > 0 {
+				m.flush(ctx, batch)
+			}
+			log.Info(ctx, "student usage ingest queue drained")
+			return
+		}
+	}
+}
+
+func (m *studentUsageIngestModel) flush(ctx context.Context, items []*studentUsageIngestItem) {
+	start := time.Now()
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(ctx, "flush student usage records panic", log.Any("recover error", err))
+			// the records written before the panic are still claimed, so the replay skips them
+			records := make([]*entity.StudentUsageRecord, len(items))
+			for i, item := range items {
+				records[i] = item.record
+			}
+			m.fail(ctx, records)
+		}
+	}()
+
+	// the records of a room share its schedule, class and materials
+	rooms := make(map[string]*studentUsageRoom)
+	roomErrors := make(map[string]error)
+	keys := make(map[string]bool)
+	var records []*entity.StudentUsageRecord
+	var failed []*entity.StudentUsageRecord
+	for _, item := range items {
+		roomID := item.record.RoomID
+		room, resolved := rooms[roomID]
+		if !resolved {
+			err := retryStudentUsageIngest(ctx, "resolve student usage room", func() error {
+				var err error
+				room, err = getStudentUsageRoom(ctx, item.op, roomID)
+				return err
+			})
+			if err != nil {
+				log.Error(ctx, "resolve student usage room failed", log.Err(err), log.String("room_id", roomID))
+				roomErrors[roomID] = err
+			}
+			rooms[roomID] = room
+		}
+
+		if roomErrors[roomID] != nil {
+			failed = append(failed, item.record)
+			continue
+		}
+		if room == nil {
+			atomic.AddInt64(&m.counters.skipped, 1)
+			continue
+		}
+
+		// the same record may be queued by two requests
+		key := item.record.IngestKey()
+		if keys[key] {
+			atomic.AddInt64(&m.counters.duplicated, 1)
+			continue
+		}
+		keys[key] = true
+
+		room.fill(ctx, item.record)
+		item.record.ID = utils.NewID()
+		records = append(records, item.record)
+	}
+
+	records = m.claim(ctx, records)
+	if len(records) > 0 {
+		if err := m.write(ctx, records); err != nil {
+			failed = append(failed, records...)
+		} else {
+			orgRecords := make(map[string][]*entity.StudentUsageRecord)
+			for _, record := range records {
+				orgID := rooms[record.RoomID].orgID
+				orgRecords[orgID] = append(orgRecords[orgID], record)
+			}
+			for orgID, items := range orgRecords {
+				recordXAPIMaterialViews(ctx, orgID, items)
+			}
+		}
+	}
+	m.fail(ctx, failed)
+
+	atomic.AddInt64(&m.counters.batches, 1)
+	atomic.StoreInt64(&m.counters.lastFlushAt, start.Unix())
+	atomic.StoreInt64(&m.counters.lastFlushMillis, time.Since(start).Milliseconds())
+	atomic.StoreInt64(&m.counters.lastFlushRecords, int64(len(items)))
+}
+
+// claim drops the records written before, every record is kept if redis fails
+func (m *studentUsageIngestModel) claim(ctx context.Context, records []*entity.StudentUsageRecord) []*entity.StudentUsageRecord {
+	keys := make([]string, len(records))
+	for i, record := range records {
+		keys[i] = record.IngestKey()
+	}
+
+	claimed, err := da.GetStudentUsageIngestRedisDA().Claim(ctx, keys, config.Get().StudentUsageIngest.DedupeTTL)
+	if err != nil {
+		log.Warn(ctx, "dedupe student usage records failed, write them all", log.Err(err), log.Int("records", len(records)))
+		return records
+	}
+
+	result := make([]*entity.StudentUsageRecord, 0, len(records))
+	for i, record := range records {
+		if !claimed[i] {
+			atomic.AddInt64(&m.counters.duplicated, 1)
+			continue
+		}
+		result = append(result, record)
+	}
+	return result
+}
+
+func (m *studentUsageIngestModel) write(ctx context.Context, records []*entity.StudentUsageRecord) error {
+	models := make([]entity.BatchInsertModeler, len(records))
+	for i, record := range records {
+		models[i] = record
+	}
+
+	err := retryStudentUsageIngest(ctx, "write student usage records", func() error {
+		return da.GetStudentUsageDA().BatchInsert(ctx, models...)
+	})
+	if err != nil {
+		log.Error(ctx, "write student usage records failed", log.Err(err), log.Int("records", len(records)))
+
+		// the records can be replayed
+		keys := make([]string, len(records))
+		for i, record := range records {
+			keys[i] = record.IngestKey()
+		}
+		_ = da.GetStudentUsageIngestRedisDA().Release(ctx, keys)
+		return err
+	}
+
+	atomic.AddInt64(&m.counters.written, int64(len(records)))
+	return nil
+}
+
+// fail dead letters the records in lines of cmd/student_usage_replay,
+// the lines are logged if redis fails too, so that they can still be replayed from the logs
+func (m *studentUsageIngestModel) fail(ctx context.Context, records []*entity.StudentUsageRecord) {
+	if len(records) == 0 {
+		return
+	}
+	atomic.AddInt64(&m.counters.failed, int64(len(records)))
+
+	lines := make([]string, 0, len(records))
+	for _, record := range records {
+		line, err := record.ReplayLine()
+		if err != nil {
+			log.Error(ctx, "marshal student usage record failed", log.Err(err), log.Any("record", record))
+			continue
+		}
+		lines = append(lines, string(line))
+	}
+
+	if err := da.GetStudentUsageIngestRedisDA().DeadLetter(ctx, lines); err != nil {
+		for _, line := range lines {
+			log.Error(ctx, "student usage record lost", log.String("line", line))
+		}
+		return
+	}
+	atomic.AddInt64(&m.counters.deadLettered, int64(len(lines)))
+}
+
+// retryStudentUsageIngest calls fn until it succeeds or the attempts run out, the wait doubles after each failure
+func retryStudentUsageIngest(ctx context.Context, name string, fn func() error) error {
+	backoff := constant.StudentUsageIngestRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= constant.StudentUsageIngestAttempts {
+			return err
+		}
+		log.Warn(ctx, name+" failed, retry",
+			log.Err(err),
+			log.Int("attempt", attempt),
+			log.Duration("backoff", backoff))
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
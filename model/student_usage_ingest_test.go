@@ -0,0 +1,34 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+)
+
+func TestRetryStudentUsageIngest(t *testing.T) {
+	ctx := context.Background()
+
+	calls := 0
+	err := retryStudentUsageIngest(ctx, "test", func() error {
+		calls++
+		if calls < constant.StudentUsageIngestAttempts {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if err != nil || calls != constant.StudentUsageIngestAttempts {
+		t.Errorf("transient failures should be retried, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = retryStudentUsageIngest(ctx, "test", func() error {
+		calls++
+		return errors.New("down")
+	})
+	if err == nil || calls != constant.StudentUsageIngestAttempts {
+		t.Errorf("failures should be returned after %d attempts, got %v after %d calls", constant.StudentUsageIngestAttempts, err, calls)
+	}
+}
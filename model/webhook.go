@@ -0,0 +1,747 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/mutex"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+const BusTopicWebhook utils.BusTopic = "Webhook"
+
+const (
+	webhookSecretBytes          = 32
+	webhookDeliveryBatchSize    = 100
+	webhookDeliveryConcurrency  = 8
+	webhookResponseBodyMaxBytes = 1024
+	webhookUserAgent            = "KidsLoop-Webhook/1.0"
+)
+
+type IWebhookModel interface {
+	Add(ctx context.Context, op *entity.Operator, req *entity.WebhookAddReq) (*entity.WebhookCreatedReply, error)
+	Query(ctx context.Context, op *entity.Operator) ([]*entity.WebhookReply, error)
+	Update(ctx context.Context, op *entity.Operator, req *entity.WebhookUpdateReq) error
+	Delete(ctx context.Context, op *entity.Operator, id string) error
+
+	QueryDeliveries(ctx context.Context, op *entity.Operator, req *entity.WebhookDeliveryQueryReq) (*entity.WebhookDeliveryPageReply, error)
+	// Redeliver send a delivery again whatever its status, attempts start over
+	Redeliver(ctx context.Context, op *entity.Operator, id string) error
+
+	// Emit notify webhooks of the operator's organization, one event per data,
+	// nothing is written if no webhook subscribed the event type
+	Emit(ctx context.Context, op *entity.Operator, eventType entity.WebhookEventType, data ...interface{}) error
+	// EmitTx same as Emit, the events are only sent after tx committed.
+	// Failures are logged, they never roll back the business data in tx
+	EmitTx(ctx context.Context, tx *dbo.DBContext, op *entity.Operator, eventType entity.WebhookEventType, data ...interface{})
+
+	// Deliver post due deliveries, returns the number of attempts
+	Deliver(ctx context.Context) (int, error)
+}
+
+type webhookModel struct {
+	bus    IDomainEventBus
+	client *http.Client
+}
+
+var (
+	_webhookModelOnce sync.Once
+	_webhookModel     IWebhookModel
+)
+
+func GetWebhookModel() IWebhookModel {
+	_webhookModelOnce.Do(func() {
+		m := &webhookModel{
+			bus: GetDomainEventBus(),
+			client: &http.Client{
+				Timeout: config.Get().Webhook.Timeout,
+				// no proxy, the address checked by the dialer must be the receiver's
+				Transport: &http.Transport{
+					DialContext: (&net.Dialer{
+						Timeout: config.Get().Webhook.Timeout,
+						Control: webhookDialControl,
+					}).DialContext,
+					TLSHandshakeTimeout: config.Get().Webhook.Timeout,
+					MaxIdleConnsPerHost: webhookDeliveryConcurrency,
+				},
+				// a redirect is reported as a failure, the receiver should register the final url
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			},
+		}
+		m.bus.Subscribe(BusTopicWebhook, "webhook.FanOut", m.fanOut)
+		_webhookModel = m
+	})
+	return _webhookModel
+}
+
+// webhookDialControl the host is resolved again at delivery time, so the address is checked on the connection itself
+func webhookDialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !entity.IsWebhookPublicIP(ip) {
+		return fmt.Errorf("webhook address %s is not public", address)
+	}
+	return nil
+}
+
+// StartWebhookWorker post due deliveries, one instance at a time
+func StartWebhookWorker(ctx context.Context) {
+	GetWebhookModel()
+
+	ticker := time.NewTicker(config.Get().Webhook.DeliveryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deliverWebhooks(utils.CloneContextWithTrace(ctx))
+	}
+}
+
+func deliverWebhooks(ctx context.Context) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(ctx, "deliver webhooks panic", log.Any("recover error", err))
+		}
+	}()
+
+	locker, err := mutex.NewLock(ctx, da.RedisKeyPrefixWebhookDeliveryLock)
+	if err != nil {
+		log.Error(ctx, "deliver webhooks: create lock failed", log.Err(err))
+		return
+	}
+	locker.Lock()
+	defer locker.Unlock()
+
+	count, err := GetWebhookModel().Deliver(ctx)
+	if err != nil {
+		log.Error(ctx, "deliver webhooks failed", log.Err(err))
+		return
+	}
+
+	if count > 0 {
+		log.Info(ctx, "deliver webhooks finished", log.Int("count", count))
+	}
+}
+
+func (m *webhookModel) checkPermission(ctx context.Context, op *entity.Operator) error {
+	isAllow, err := external.GetPermissionServiceProvider().HasOrganizationPermission(ctx, op, external.ManageWebhooks10901)
+	if err != nil {
+		log.Error(ctx, "check permission 10901 failed", log.Err(err), log.Any("operator", op))
+		return err
+	}
+	if !isAllow {
+		log.Warn(ctx, "user has no permission to manage webhooks", log.Any("operator", op))
+		return constant.ErrForbidden
+	}
+
+	return nil
+}
+
+func (m *webhookModel) Add(ctx context.Context, op *entity.Operator, req *entity.WebhookAddReq) (*entity.WebhookCreatedReply, error) {
+	if !req.Valid() {
+		log.Warn(ctx, "webhook add request invalid", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op); err != nil {
+		return nil, err
+	}
+
+	if err := m.verifyHost(ctx, req.URL); err != nil {
+		return nil, err
+	}
+
+	secret, err := m.generateSecret()
+	if err != nil {
+		log.Error(ctx, "generate webhook secret failed", log.Err(err))
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	webhook := &entity.Webhook{
+		ID:        utils.NewID(),
+		OrgID:     op.OrgID,
+		Name:      req.Name,
+		URL:       req.URL,
+		Secret:    secret,
+		Enabled:   true,
+		CreatorID: op.UserID,
+		CreateAt:  now,
+		UpdateAt:  now,
+	}
+	webhook.SetEventTypes(req.EventTypes)
+	if _, err := da.GetWebhookDA().Insert(ctx, webhook); err != nil {
+		log.Error(ctx, "insert webhook failed", log.Err(err), log.Any("webhook", webhook))
+		return nil, err
+	}
+
+	return &entity.WebhookCreatedReply{
+		WebhookReply: m.convertReply(webhook),
+		Secret:       secret,
+	}, nil
+}
+
+func (m *webhookModel) Query(ctx context.Context, op *entity.Operator) ([]*entity.WebhookReply, error) {
+	if err := m.checkPermission(ctx, op); err != nil {
+		return nil, err
+	}
+
+	webhooks, err := m.queryWebhooks(ctx, op.OrgID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*entity.WebhookReply, 0, len(webhooks))
+	for _, item := range webhooks {
+		reply := m.convertReply(item)
+		result = append(result, &reply)
+	}
+
+	return result, nil
+}
+
+func (m *webhookModel) Update(ctx context.Context, op *entity.Operator, req *entity.WebhookUpdateReq) error {
+	if !req.Valid() {
+		log.Warn(ctx, "webhook update request invalid", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op); err != nil {
+		return err
+	}
+
+	if err := m.verifyHost(ctx, req.URL); err != nil {
+		return err
+	}
+
+	webhook, err := m.getWebhook(ctx, op, req.ID)
+	if err != nil {
+		return err
+	}
+
+	webhook.Name = req.Name
+	webhook.URL = req.URL
+	webhook.Enabled = req.Enabled
+	webhook.SetEventTypes(req.EventTypes)
+	webhook.UpdateAt = time.Now().Unix()
+	if _, err := da.GetWebhookDA().Update(ctx, webhook); err != nil {
+		log.Error(ctx, "update webhook failed", log.Err(err), log.Any("webhook", webhook))
+		return err
+	}
+
+	return nil
+}
+
+// Delete pending deliveries of the webhook are marked dead by the worker
+func (m *webhookModel) Delete(ctx context.Context, op *entity.Operator, id string) error {
+	if err := m.checkPermission(ctx, op); err != nil {
+		return err
+	}
+
+	webhook, err := m.getWebhook(ctx, op, id)
+	if err != nil {
+		return err
+	}
+
+	webhook.DeleteAt = time.Now().Unix()
+	if _, err := da.GetWebhookDA().Update(ctx, webhook); err != nil {
+		log.Error(ctx, "delete webhook failed", log.Err(err), log.String("id", id))
+		return err
+	}
+
+	return nil
+}
+
+func (m *webhookModel) QueryDeliveries(ctx context.Context, op *entity.Operator, req *entity.WebhookDeliveryQueryReq) (*entity.WebhookDeliveryPageReply, error) {
+	if (req.Status != "" && !req.Status.Valid()) || (req.EventType != "" && !req.EventType.Valid()) {
+		log.Warn(ctx, "webhook delivery query request invalid", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op); err != nil {
+		return nil, err
+	}
+
+	condition := &da.WebhookDeliveryCondition{
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+		WebhookID: sql.NullString{
+			String: req.WebhookID,
+			Valid:  req.WebhookID != "",
+		},
+		EventType: sql.NullString{
+			String: string(req.EventType),
+			Valid:  req.EventType != "",
+		},
+		Status: sql.NullString{
+			String: string(req.Status),
+			Valid:  req.Status != "",
+		},
+		Pager: dbo.Pager{
+			Page:     req.PageIndex,
+			PageSize: req.PageSize,
+		},
+	}
+
+	var deliveries []*entity.WebhookDelivery
+	total, err := da.GetWebhookDeliveryDA().Page(ctx, condition, &deliveries)
+	if err != nil {
+		log.Error(ctx, "page webhook deliveries failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	result := &entity.WebhookDeliveryPageReply{
+		Total: total,
+		Data:  make([]*entity.WebhookDeliveryView, 0, len(deliveries)),
+	}
+	for _, item := range deliveries {
+		result.Data = append(result.Data, &entity.WebhookDeliveryView{
+			ID:             item.ID,
+			WebhookID:      item.WebhookID,
+			EventID:        item.EventID,
+			EventType:      item.EventType,
+			Payload:        item.Payload,
+			Status:         item.Status,
+			Attempts:       item.Attempts,
+			ResponseStatus: item.ResponseStatus,
+			ResponseBody:   item.ResponseBody,
+			LastError:      item.LastError,
+			NextAttemptAt:  item.NextAttemptAt,
+			DeliveredAt:    item.DeliveredAt,
+			CreateAt:       item.CreateAt,
+		})
+	}
+
+	return result, nil
+}
+
+func (m *webhookModel) Redeliver(ctx context.Context, op *entity.Operator, id string) error {
+	if err := m.checkPermission(ctx, op); err != nil {
+		return err
+	}
+
+	delivery := new(entity.WebhookDelivery)
+	err := da.GetWebhookDeliveryDA().Get(ctx, id, delivery)
+	if err == dbo.ErrRecordNotFound || (err == nil && (delivery.OrgID != op.OrgID || delivery.DeleteAt > 0)) {
+		log.Warn(ctx, "webhook delivery not found", log.String("id", id), log.Any("operator", op))
+		return constant.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Error(ctx, "get webhook delivery failed", log.Err(err), log.String("id", id))
+		return err
+	}
+
+	now := time.Now().Unix()
+	delivery.Status = entity.WebhookDeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.LastError = ""
+	delivery.NextAttemptAt = now
+	delivery.UpdateAt = now
+	if _, err := da.GetWebhookDeliveryDA().Update(ctx, delivery); err != nil {
+		log.Error(ctx, "reset webhook delivery failed", log.Err(err), log.Any("delivery", delivery))
+		return err
+	}
+
+	return nil
+}
+
+func (m *webhookModel) Emit(ctx context.Context, op *entity.Operator, eventType entity.WebhookEventType, data ...interface{}) error {
+	events, err := m.newEvents(ctx, op, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range events {
+		if err := m.bus.Publish(ctx, op, BusTopicWebhook, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *webhookModel) EmitTx(ctx context.Context, tx *dbo.DBContext, op *entity.Operator, eventType entity.WebhookEventType, data ...interface{}) {
+	events, err := m.newEvents(ctx, op, eventType, data)
+	if err != nil {
+		log.Error(ctx, "emit webhook events failed", log.Err(err), log.String("eventType", string(eventType)), log.Any("op", op))
+		return
+	}
+
+	for _, item := range events {
+		if err := m.bus.PublishTx(ctx, tx, op, BusTopicWebhook, item); err != nil {
+			log.Error(ctx, "emit webhook event failed", log.Err(err), log.Any("event", item))
+		}
+	}
+}
+
+// newEvents returns nothing if no webhook subscribed the event type, most organizations have none
+func (m *webhookModel) newEvents(ctx context.Context, op *entity.Operator, eventType entity.WebhookEventType, data []interface{}) ([]*entity.WebhookEvent, error) {
+	if len(data) <= 0 {
+		return nil, nil
+	}
+
+	webhooks, err := m.queryWebhooks(ctx, op.OrgID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribed := false
+	for _, item := range webhooks {
+		if item.Subscribed(eventType) {
+			subscribed = true
+			break
+		}
+	}
+	if !subscribed {
+		return nil, nil
+	}
+
+	now := time.Now().Unix()
+	events := make([]*entity.WebhookEvent, len(data))
+	for i := range data {
+		events[i] = &entity.WebhookEvent{
+			ID:         utils.NewID(),
+			Type:       eventType,
+			OrgID:      op.OrgID,
+			OccurredAt: now,
+			Data:       data[i],
+		}
+	}
+
+	return events, nil
+}
+
+// fanOut create a delivery for every webhook subscribed the event, the payload is posted as it is
+func (m *webhookModel) fanOut(ctx context.Context, op *entity.Operator, payload []byte) error {
+	event := new(entity.WebhookEvent)
+	if err := json.Unmarshal(payload, event); err != nil {
+		log.Warn(ctx, "unmarshal webhook event failed", log.Err(err), log.String("payload", string(payload)))
+		return constant.ErrInvalidArgs
+	}
+
+	webhooks, err := m.queryWebhooks(ctx, event.OrgID, true)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, item := range webhooks {
+		if !item.Subscribed(event.Type) {
+			continue
+		}
+
+		delivery := &entity.WebhookDelivery{
+			ID:            utils.NewID(),
+			WebhookID:     item.ID,
+			OrgID:         event.OrgID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       string(payload),
+			Status:        entity.WebhookDeliveryStatusPending,
+			NextAttemptAt: now,
+			CreateAt:      now,
+			UpdateAt:      now,
+		}
+		// the event is retried as a whole, deliveries created by the last attempt are kept
+		_, err := da.GetWebhookDeliveryDA().Insert(ctx, delivery)
+		if err != nil && err != dbo.ErrDuplicateRecord {
+			log.Error(ctx, "insert webhook delivery failed", log.Err(err), log.Any("delivery", delivery))
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *webhookModel) Deliver(ctx context.Context) (int, error) {
+	condition := &da.WebhookDeliveryCondition{
+		Due: sql.NullInt64{
+			Int64: time.Now().Unix(),
+			Valid: true,
+		},
+		OrderBy: "next_attempt_at",
+		Pager: dbo.Pager{
+			Page:     1,
+			PageSize: webhookDeliveryBatchSize,
+		},
+	}
+
+	var deliveries []*entity.WebhookDelivery
+	if err := da.GetWebhookDeliveryDA().Query(ctx, condition, &deliveries); err != nil {
+		log.Error(ctx, "query due webhook deliveries failed", log.Err(err), log.Any("condition", condition))
+		return 0, err
+	}
+	if len(deliveries) <= 0 {
+		return 0, nil
+	}
+
+	webhookIDs := make([]string, 0, len(deliveries))
+	for _, item := range deliveries {
+		webhookIDs = append(webhookIDs, item.WebhookID)
+	}
+
+	var webhooks []*entity.Webhook
+	err := da.GetWebhookDA().Query(ctx, &da.WebhookCondition{
+		IDs: entity.NullStrings{
+			Strings: utils.SliceDeduplicationExcludeEmpty(webhookIDs),
+			Valid:   true,
+		},
+	}, &webhooks)
+	if err != nil {
+		log.Error(ctx, "query webhooks failed", log.Err(err), log.Strings("webhookIDs", webhookIDs))
+		return 0, err
+	}
+
+	webhookMap := make(map[string]*entity.Webhook, len(webhooks))
+	for _, item := range webhooks {
+		webhookMap[item.ID] = item
+	}
+
+	// a slow receiver should not hold up the others
+	semaphore := make(chan struct{}, webhookDeliveryConcurrency)
+	wg := sync.WaitGroup{}
+	for _, item := range deliveries {
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func(delivery *entity.WebhookDelivery) {
+			defer func() {
+				if err := recover(); err != nil {
+					log.Error(ctx, "deliver webhook panic", log.Any("recover error", err), log.String("id", delivery.ID))
+				}
+				<-semaphore
+				wg.Done()
+			}()
+
+			m.deliver(ctx, webhookMap[delivery.WebhookID], delivery)
+		}(item)
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+func (m *webhookModel) deliver(ctx context.Context, webhook *entity.Webhook, delivery *entity.WebhookDelivery) {
+	conf := config.Get().Webhook
+	now := time.Now().Unix()
+
+	switch {
+	case webhook == nil:
+		delivery.Fail(0, "", "webhook has been deleted", 0, 0, now)
+	case !webhook.Enabled:
+		delivery.Fail(0, "", "webhook has been disabled", 0, 0, now)
+	default:
+		statusCode, body, err := m.post(ctx, webhook, delivery)
+		now = time.Now().Unix()
+		switch {
+		case err != nil:
+			log.Warn(ctx, "post webhook failed", log.Err(err), log.String("id", delivery.ID), log.String("url", webhook.URL))
+			delivery.Fail(statusCode, body, err.Error(), conf.MaxAttempts, conf.RetryBackoff, now)
+		case statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices:
+			log.Warn(ctx, "webhook responded failure", log.Int("status", statusCode), log.String("id", delivery.ID), log.String("url", webhook.URL))
+			delivery.Fail(statusCode, body, fmt.Sprintf("unexpected status %d", statusCode), conf.MaxAttempts, conf.RetryBackoff, now)
+		default:
+			delivery.Succeed(statusCode, body, now)
+		}
+	}
+
+	if _, err := da.GetWebhookDeliveryDA().Update(ctx, delivery); err != nil {
+		log.Error(ctx, "update webhook delivery failed", log.Err(err), log.Any("delivery", delivery))
+	}
+}
+
+func (m *webhookModel) post(ctx context.Context, webhook *entity.Webhook, delivery *entity.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", webhookUserAgent)
+	request.Header.Set(entity.WebhookHeaderEvent, string(delivery.EventType))
+	request.Header.Set(entity.WebhookHeaderDelivery, delivery.ID)
+	request.Header.Set(entity.WebhookHeaderSignature, entity.WebhookSignature(webhook.Secret, time.Now().Unix(), body))
+
+	response, err := m.client.Do(request)
+	if err != nil {
+		return 0, "", err
+	}
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(io.LimitReader(response.Body, webhookResponseBodyMaxBytes))
+	if err != nil {
+		return response.StatusCode, "", err
+	}
+
+	return response.StatusCode, string(responseBody), nil
+}
+
+// verifyHost every address of the host must be public
+func (m *webhookModel) verifyHost(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		log.Warn(ctx, "parse webhook url failed", log.Err(err), log.String("url", rawURL))
+		return constant.ErrInvalidArgs
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addresses) <= 0 {
+		log.Warn(ctx, "resolve webhook host failed", log.Err(err), log.String("url", rawURL))
+		return constant.ErrInvalidArgs
+	}
+	for _, item := range addresses {
+		if !entity.IsWebhookPublicIP(item.IP) {
+			log.Warn(ctx, "webhook host is not public", log.String("url", rawURL), log.String("ip", item.IP.String()))
+			return constant.ErrInvalidArgs
+		}
+	}
+
+	return nil
+}
+
+func (m *webhookModel) queryWebhooks(ctx context.Context, orgID string, enabledOnly bool) ([]*entity.Webhook, error) {
+	var webhooks []*entity.Webhook
+	err := da.GetWebhookDA().Query(ctx, &da.WebhookCondition{
+		OrgID: sql.NullString{
+			String: orgID,
+			Valid:  true,
+		},
+		Enabled: sql.NullBool{
+			Bool:  true,
+			Valid: enabledOnly,
+		},
+	}, &webhooks)
+	if err != nil {
+		log.Error(ctx, "query webhooks failed", log.Err(err), log.String("orgID", orgID))
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (m *webhookModel) getWebhook(ctx context.Context, op *entity.Operator, id string) (*entity.Webhook, error) {
+	var webhooks []*entity.Webhook
+	err := da.GetWebhookDA().Query(ctx, &da.WebhookCondition{
+		IDs: entity.NullStrings{
+			Strings: []string{id},
+			Valid:   true,
+		},
+		OrgID: sql.NullString{
+			String: op.OrgID,
+			Valid:  true,
+		},
+	}, &webhooks)
+	if err != nil {
+		log.Error(ctx, "query webhook failed", log.Err(err), log.String("id", id))
+		return nil, err
+	}
+	if len(webhooks) <= 0 {
+		return nil, constant.ErrRecordNotFound
+	}
+
+	return webhooks[0], nil
+}
+
+func (m *webhookModel) generateSecret() (string, error) {
+	buf := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (m *webhookModel) convertReply(webhook *entity.Webhook) entity.WebhookReply {
+	return entity.WebhookReply{
+		ID:         webhook.ID,
+		Name:       webhook.Name,
+		URL:        webhook.URL,
+		EventTypes: webhook.GetEventTypes(),
+		Enabled:    webhook.Enabled,
+		CreatorID:  webhook.CreatorID,
+		CreateAt:   webhook.CreateAt,
+		UpdateAt:   webhook.UpdateAt,
+	}
+}
+
+// newWebhookContentData publish status tells deleted contents from archived ones
+func newWebhookContentData(content *entity.Content) *entity.WebhookContentData {
+	return &entity.WebhookContentData{
+		ID:            content.ID,
+		Name:          content.Name,
+		ContentType:   int(content.ContentType),
+		PublishStatus: string(content.PublishStatus),
+		AuthorID:      content.Author,
+	}
+}
+
+func newWebhookScheduleData(schedule *entity.Schedule, previousID string, editType entity.ScheduleEditType) *entity.WebhookScheduleData {
+	return &entity.WebhookScheduleData{
+		ID:         schedule.ID,
+		PreviousID: previousID,
+		RepeatID:   schedule.RepeatID,
+		EditType:   string(editType),
+		Title:      schedule.Title,
+		ClassType:  string(schedule.ClassType),
+		ClassID:    schedule.ClassID,
+		StartAt:    schedule.StartAt,
+		EndAt:      schedule.EndAt,
+		DueAt:      schedule.DueAt,
+		Status:     string(schedule.Status),
+	}
+}
+
+func newWebhookScheduleDataList(schedules []*entity.Schedule, previousID string, editType entity.ScheduleEditType) []interface{} {
+	result := make([]interface{}, len(schedules))
+	for i := range schedules {
+		result[i] = newWebhookScheduleData(schedules[i], previousID, editType)
+	}
+	return result
+}
+
+func newWebhookOutcomeData(outcome *entity.Outcome) *entity.WebhookOutcomeData {
+	return &entity.WebhookOutcomeData{
+		ID:        outcome.ID,
+		Name:      outcome.Name,
+		Shortcode: outcome.Shortcode,
+	}
+}
+
+// emitAssessmentCompletedTx called in the transaction completing the assessment
+func emitAssessmentCompletedTx(ctx context.Context, tx *dbo.DBContext, op *entity.Operator, assessment *v2.Assessment) {
+	GetWebhookModel().EmitTx(ctx, tx, op, entity.WebhookEventAssessmentCompleted, &entity.WebhookAssessmentData{
+		ID:             assessment.ID,
+		ScheduleID:     assessment.ScheduleID,
+		AssessmentType: string(assessment.AssessmentType),
+		Title:          assessment.Title,
+		CompleteAt:     assessment.CompleteAt,
+		CompleteBy:     string(assessment.CompleteBy),
+	})
+}
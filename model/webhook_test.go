@@ -0,0 +1,116 @@
+package model
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+)
+
+func TestWebhookPost(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(strings.Repeat("x", webhookResponseBodyMaxBytes+10)))
+	}))
+	defer server.Close()
+
+	// the receiver is on loopback, so the client of the test has no dial control
+	m := &webhookModel{client: server.Client()}
+	webhook := &entity.Webhook{URL: server.URL, Secret: "secret"}
+	delivery := &entity.WebhookDelivery{ID: "delivery", EventType: entity.WebhookEventContentPublished, Payload: `{"id":"1"}`}
+
+	statusCode, responseBody, err := m.post(context.Background(), webhook, delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusCode != http.StatusAccepted || len(responseBody) != webhookResponseBodyMaxBytes {
+		t.Errorf("unexpected response %d of %d bytes", statusCode, len(responseBody))
+	}
+
+	if string(body) != delivery.Payload {
+		t.Errorf("unexpected body %s", body)
+	}
+	if received.Header.Get(entity.WebhookHeaderEvent) != string(delivery.EventType) ||
+		received.Header.Get(entity.WebhookHeaderDelivery) != delivery.ID ||
+		received.Header.Get("User-Agent") != webhookUserAgent {
+		t.Errorf("unexpected headers %v", received.Header)
+	}
+
+	// the receiver verifies the signature with the timestamp in it
+	signature := received.Header.Get(entity.WebhookHeaderSignature)
+	var timestamp int64
+	for _, field := range strings.Split(signature, ",") {
+		if strings.HasPrefix(field, "t=") {
+			timestamp, _ = strconv.ParseInt(strings.TrimPrefix(field, "t="), 10, 64)
+		}
+	}
+	if signature != entity.WebhookSignature("secret", timestamp, body) {
+		t.Errorf("signature %s does not match the body", signature)
+	}
+}
+
+func TestWebhookDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:443", false},
+		{"10.0.0.8:443", false},
+		{"169.254.169.254:80", false},
+		{"100.64.0.1:443", false},
+		{"[::1]:443", false},
+		{"[fd00::1]:443", false},
+		{"[::ffff:127.0.0.1]:443", false},
+		{"localhost:443", false},
+	}
+	for _, tt := range tests {
+		err := webhookDialControl("tcp", tt.address, nil)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: allowed %v, got %v", tt.address, tt.allowed, err)
+		}
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a private receiver should not be reached")
+	}))
+	defer server.Close()
+
+	// a public host resolved to a private address at delivery time is refused by the dialer
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: (&net.Dialer{Timeout: time.Second, Control: webhookDialControl}).DialContext,
+	}}
+	m := &webhookModel{client: client}
+	webhook := &entity.Webhook{URL: server.URL, Secret: "secret"}
+	delivery := &entity.WebhookDelivery{ID: "delivery", Payload: `{}`}
+	if _, _, err := m.post(context.Background(), webhook, delivery); err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Errorf("expected the address to be refused, got %v", err)
+	}
+}
+
+func TestWebhookVerifyHost(t *testing.T) {
+	m := &webhookModel{}
+	for _, rawURL := range []string{"https://127.0.0.1/hook", "https://localhost/hook", "https://[fd00::1]/hook", "://bad"} {
+		if err := m.verifyHost(context.Background(), rawURL); err != constant.ErrInvalidArgs {
+			t.Errorf("%s: expected ErrInvalidArgs, got %v", rawURL, err)
+		}
+	}
+	if err := m.verifyHost(context.Background(), "https://93.184.216.34/hook"); err != nil {
+		t.Errorf("public address should be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,684 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+	"github.com/KL-Engineering/dbo"
+
+	"github.com/KL-Engineering/kidsloop-cms-service/config"
+	"github.com/KL-Engineering/kidsloop-cms-service/constant"
+	"github.com/KL-Engineering/kidsloop-cms-service/da"
+	"github.com/KL-Engineering/kidsloop-cms-service/da/assessmentV2"
+	"github.com/KL-Engineering/kidsloop-cms-service/entity"
+	v2 "github.com/KL-Engineering/kidsloop-cms-service/entity/v2"
+	"github.com/KL-Engineering/kidsloop-cms-service/external"
+	"github.com/KL-Engineering/kidsloop-cms-service/mutex"
+	"github.com/KL-Engineering/kidsloop-cms-service/utils"
+)
+
+const BusTopicXAPIAssessmentCompleted utils.BusTopic = "XAPIAssessmentCompleted"
+
+const (
+	xapiInsertBatchSize = 500
+	xapiExportPageSize  = 1000
+	// xapiForwardMaxBatches batches forwarded in a run, the rest wait for the next run
+	xapiForwardMaxBatches    = 50
+	xapiResponseBodyMaxBytes = 1024
+	xapiUserAgent            = "KidsLoop-xAPI/1.0"
+)
+
+type IXAPIModel interface {
+	// Store statements posted by a client, a statement or an array of them, returns their ids
+	Store(ctx context.Context, op *entity.Operator, body []byte) ([]string, error)
+	Query(ctx context.Context, op *entity.Operator, req *entity.XAPIStatementQueryReq) (*entity.XAPIStatementResult, error)
+	// Export call write with pages of statements, earliest stored first, until the export limit
+	Export(ctx context.Context, op *entity.Operator, req *entity.XAPIStatementQueryReq, write func(statements []json.RawMessage) error) error
+
+	// Record store statements generated by cms, nothing is stored if xapi is disabled
+	Record(ctx context.Context, orgID string, statements []*entity.XAPIStatement) error
+	// PublishAssessmentCompletedTx the statements of the students of a completed assessment, written to the outbox in tx
+	// and the outcomes they achieved are recorded by the domain event worker
+	PublishAssessmentCompletedTx(ctx context.Context, tx *dbo.DBContext, op *entity.Operator, assessmentID string) error
+
+	// Forward post due statements to the lrs in batches, returns the number of statements attempted
+	Forward(ctx context.Context) (int, error)
+}
+
+type xapiAssessmentCompletedEvent struct {
+	AssessmentID string `json:"assessment_id"`
+}
+
+type xapiModel struct {
+	bus    IDomainEventBus
+	client *http.Client
+}
+
+var (
+	_xapiModelOnce sync.Once
+	_xapiModel     IXAPIModel
+)
+
+func GetXAPIModel() IXAPIModel {
+	_xapiModelOnce.Do(func() {
+		m := &xapiModel{
+			bus: GetDomainEventBus(),
+			client: &http.Client{
+				Timeout: config.Get().XAPI.Timeout,
+			},
+		}
+		m.bus.Subscribe(BusTopicXAPIAssessmentCompleted, "xapi.RecordAssessment", m.recordAssessment)
+		_xapiModel = m
+	})
+	return _xapiModel
+}
+
+// StartXAPIForwardWorker post due statements to the lrs, one instance at a time
+func StartXAPIForwardWorker(ctx context.Context) {
+	conf := config.Get().XAPI
+	if conf.LRSEndpoint == "" {
+		log.Info(ctx, "xapi lrs endpoint is not configured, statements are kept in cms only")
+		return
+	}
+	GetXAPIModel()
+
+	ticker := time.NewTicker(conf.ForwardInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		forwardXAPIStatements(utils.CloneContextWithTrace(ctx))
+	}
+}
+
+func forwardXAPIStatements(ctx context.Context) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(ctx, "forward xapi statements panic", log.Any("recover error", err))
+		}
+	}()
+
+	locker, err := mutex.NewLock(ctx, da.RedisKeyPrefixXAPIForwardLock)
+	if err != nil {
+		log.Error(ctx, "forward xapi statements: create lock failed", log.Err(err))
+		return
+	}
+	locker.Lock()
+	defer locker.Unlock()
+
+	count, err := GetXAPIModel().Forward(ctx)
+	if err != nil {
+		log.Error(ctx, "forward xapi statements failed", log.Err(err))
+		return
+	}
+
+	if count > 0 {
+		log.Info(ctx, "forward xapi statements finished", log.Int("count", count))
+	}
+}
+
+func newXAPIStatementBuilder() entity.XAPIStatementBuilder {
+	return entity.XAPIStatementBuilder{
+		HomePage: config.Get().XAPI.HomePage,
+		Secret:   config.Get().XAPI.IDSecret,
+	}
+}
+
+func (m *xapiModel) checkPermission(ctx context.Context, op *entity.Operator) error {
+	isAllow, err := external.GetPermissionServiceProvider().HasOrganizationPermission(ctx, op, external.ManageXAPIStatements10908)
+	if err != nil {
+		log.Error(ctx, "check permission 10908 failed", log.Err(err), log.Any("operator", op))
+		return err
+	}
+	if !isAllow {
+		log.Warn(ctx, "user has no permission to manage xapi statements", log.Any("operator", op))
+		return constant.ErrForbidden
+	}
+
+	return nil
+}
+
+func (m *xapiModel) Store(ctx context.Context, op *entity.Operator, body []byte) ([]string, error) {
+	statements, err := entity.ParseXAPIStatements(body)
+	if err != nil || len(statements) > constant.XAPIQueryMaxLimit {
+		log.Warn(ctx, "xapi statements body invalid", log.Err(err), log.Int("count", len(statements)))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op); err != nil {
+		return nil, err
+	}
+
+	forward := config.Get().XAPI.LRSEndpoint != ""
+	now := time.Now()
+	ids := make([]string, 0, len(statements))
+	idMap := make(map[string]bool, len(statements))
+	records := make([]*entity.XAPIStatementRecord, 0, len(statements))
+	for i, statement := range statements {
+		record, err := entity.NewXAPIStatementRecord(op.OrgID, statement, forward, now)
+		if err != nil {
+			log.Warn(ctx, "xapi statement invalid", log.Err(err), log.Int("index", i))
+			return nil, constant.ErrInvalidArgs
+		}
+		if idMap[record.ID] {
+			log.Warn(ctx, "xapi statement id repeated", log.String("id", record.ID), log.Int("index", i))
+			return nil, constant.ErrInvalidArgs
+		}
+		idMap[record.ID] = true
+
+		ids = append(ids, record.ID)
+		records = append(records, record)
+	}
+
+	err = dbo.GetTrans(ctx, func(ctx context.Context, tx *dbo.DBContext) error {
+		return m.storeTx(ctx, tx, op.OrgID, ids, records)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// storeTx statements stored before with the same ids are kept, ErrConflict if any of them is a different statement
+func (m *xapiModel) storeTx(ctx context.Context, tx *dbo.DBContext, orgID string, ids []string, records []*entity.XAPIStatementRecord) error {
+	if err := da.GetXAPIStatementDA().InsertIgnoreTx(ctx, tx, records); err != nil {
+		return err
+	}
+
+	condition := &da.XAPIStatementCondition{
+		OrgID: sql.NullString{String: orgID, Valid: true},
+		IDs:   entity.NullStrings{Strings: ids, Valid: true},
+	}
+	var stored []*entity.XAPIStatementRecord
+	if err := da.GetXAPIStatementDA().QueryTx(ctx, tx, condition, &stored); err != nil {
+		log.Error(ctx, "query stored xapi statements failed", log.Err(err), log.Any("condition", condition))
+		return err
+	}
+
+	storedMap := make(map[string]*entity.XAPIStatementRecord, len(stored))
+	for _, record := range stored {
+		storedMap[record.ID] = record
+	}
+	for _, record := range records {
+		if storedRecord, ok := storedMap[record.ID]; ok && !storedRecord.SameStatement(record) {
+			log.Warn(ctx, "xapi statement id conflicts", log.String("org_id", orgID), log.String("id", record.ID))
+			return constant.ErrConflict
+		}
+	}
+
+	return nil
+}
+
+func (m *xapiModel) Query(ctx context.Context, op *entity.Operator, req *entity.XAPIStatementQueryReq) (*entity.XAPIStatementResult, error) {
+	if !req.Valid() {
+		log.Warn(ctx, "xapi statement query request invalid", log.Any("req", req))
+		return nil, constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op); err != nil {
+		return nil, err
+	}
+
+	condition := m.buildCondition(op, req)
+	condition.Pager = dbo.Pager{
+		Page:     req.Page,
+		PageSize: req.Limit,
+	}
+
+	var records []*entity.XAPIStatementRecord
+	if err := da.GetXAPIStatementDA().Query(ctx, condition, &records); err != nil {
+		log.Error(ctx, "query xapi statements failed", log.Err(err), log.Any("condition", condition))
+		return nil, err
+	}
+
+	result := &entity.XAPIStatementResult{
+		Statements: make([]json.RawMessage, len(records)),
+		HasMore:    len(records) == req.Limit,
+	}
+	for i, record := range records {
+		result.Statements[i] = json.RawMessage(record.Statement)
+	}
+	return result, nil
+}
+
+func (m *xapiModel) Export(ctx context.Context, op *entity.Operator, req *entity.XAPIStatementQueryReq, write func(statements []json.RawMessage) error) error {
+	if !req.Valid() {
+		log.Warn(ctx, "xapi statement export request invalid", log.Any("req", req))
+		return constant.ErrInvalidArgs
+	}
+
+	if err := m.checkPermission(ctx, op); err != nil {
+		return err
+	}
+
+	req.Ascending = true
+	condition := m.buildCondition(op, req)
+	// statements stored while exporting shift pages, the upper bound keeps them out
+	if !condition.CreateAtLe.Valid {
+		condition.CreateAtLe = sql.NullInt64{Int64: time.Now().Unix(), Valid: true}
+	}
+
+	for page, exported := 1, 0; exported < constant.XAPIExportLimit; page++ {
+		condition.Pager = dbo.Pager{
+			Page:     page,
+			PageSize: xapiExportPageSize,
+		}
+
+		var records []*entity.XAPIStatementRecord
+		if err := da.GetXAPIStatementDA().Query(ctx, condition, &records); err != nil {
+			log.Error(ctx, "query xapi statements failed", log.Err(err), log.Any("condition", condition))
+			return err
+		}
+		if exported+len(records) > constant.XAPIExportLimit {
+			records = records[:constant.XAPIExportLimit-exported]
+		}
+		if len(records) == 0 {
+			break
+		}
+
+		statements := make([]json.RawMessage, len(records))
+		for i, record := range records {
+			statements[i] = json.RawMessage(record.Statement)
+		}
+		if err := write(statements); err != nil {
+			log.Warn(ctx, "write xapi statements failed", log.Err(err))
+			return err
+		}
+
+		exported += len(records)
+		if len(records) < xapiExportPageSize {
+			break
+		}
+	}
+
+	return nil
+}
+
+// buildCondition the request is valid, since and until are compared in seconds and both inclusive
+func (m *xapiModel) buildCondition(op *entity.Operator, req *entity.XAPIStatementQueryReq) *da.XAPIStatementCondition {
+	condition := &da.XAPIStatementCondition{
+		OrgID:    sql.NullString{String: op.OrgID, Valid: true},
+		ID:       sql.NullString{String: req.StatementID, Valid: req.StatementID != ""},
+		VerbID:   sql.NullString{String: req.Verb, Valid: req.Verb != ""},
+		ObjectID: sql.NullString{String: req.Activity, Valid: req.Activity != ""},
+		OrderBy:  "create_at desc, id desc",
+	}
+	if req.Ascending {
+		condition.OrderBy = "create_at, id"
+	}
+
+	if req.Agent != "" {
+		key, _ := entity.ParseXAPIAgentKey(req.Agent)
+		condition.ActorKey = sql.NullString{String: key, Valid: true}
+	}
+	if req.Since != "" {
+		since, _ := entity.ParseXAPITime(req.Since)
+		condition.CreateAtGe = sql.NullInt64{Int64: since.Unix(), Valid: true}
+	}
+	if req.Until != "" {
+		until, _ := entity.ParseXAPITime(req.Until)
+		condition.CreateAtLe = sql.NullInt64{Int64: until.Unix(), Valid: true}
+	}
+
+	return condition
+}
+
+func (m *xapiModel) Record(ctx context.Context, orgID string, statements []*entity.XAPIStatement) error {
+	if !config.Get().XAPI.Enabled || len(statements) == 0 {
+		return nil
+	}
+
+	forward := config.Get().XAPI.LRSEndpoint != ""
+	now := time.Now()
+	records := make([]*entity.XAPIStatementRecord, 0, len(statements))
+	for _, statement := range statements {
+		data, err := json.Marshal(statement)
+		if err != nil {
+			log.Error(ctx, "marshal xapi statement failed", log.Err(err), log.Any("statement", statement))
+			return err
+		}
+
+		record, err := entity.NewXAPIStatementRecord(orgID, data, forward, now)
+		if err != nil {
+			// e.g. a usage record without the student
+			log.Warn(ctx, "generated xapi statement invalid", log.Err(err), log.Any("statement", statement))
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return m.insert(ctx, records)
+}
+
+func (m *xapiModel) insert(ctx context.Context, records []*entity.XAPIStatementRecord) error {
+	for start := 0; start < len(records); start += xapiInsertBatchSize {
+		end := start + xapiInsertBatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		if err := da.GetXAPIStatementDA().InsertIgnore(ctx, records[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *xapiModel) PublishAssessmentCompletedTx(ctx context.Context, tx *dbo.DBContext, op *entity.Operator, assessmentID string) error {
+	if !config.Get().XAPI.Enabled {
+		return nil
+	}
+	return m.bus.PublishTx(ctx, tx, op, BusTopicXAPIAssessmentCompleted, &xapiAssessmentCompletedEvent{AssessmentID: assessmentID})
+}
+
+// recordAssessment completions of the participating students and the outcomes they achieved
+func (m *xapiModel) recordAssessment(ctx context.Context, op *entity.Operator, payload []byte) error {
+	if !config.Get().XAPI.Enabled {
+		return nil
+	}
+
+	event := new(xapiAssessmentCompletedEvent)
+	if err := json.Unmarshal(payload, event); err != nil {
+		return err
+	}
+
+	assessment := new(v2.Assessment)
+	err := assessmentV2.GetAssessmentDA().Get(ctx, event.AssessmentID, assessment)
+	if err == dbo.ErrRecordNotFound {
+		log.Warn(ctx, "completed assessment not found", log.String("assessmentID", event.AssessmentID))
+		return nil
+	}
+	if err != nil {
+		log.Error(ctx, "get assessment failed", log.Err(err), log.String("assessmentID", event.AssessmentID))
+		return err
+	}
+
+	var users []*v2.AssessmentUser
+	userCondition := &assessmentV2.AssessmentUserCondition{
+		AssessmentID: sql.NullString{String: assessment.ID, Valid: true},
+		UserType:     sql.NullString{String: v2.AssessmentUserTypeStudent.String(), Valid: true},
+		StatusByUser: sql.NullString{String: v2.AssessmentUserStatusParticipate.String(), Valid: true},
+	}
+	if err := assessmentV2.GetAssessmentUserDA().Query(ctx, userCondition, &users); err != nil {
+		log.Error(ctx, "query assessment users failed", log.Err(err), log.Any("condition", userCondition))
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	builder := newXAPIStatementBuilder()
+	statements := make([]*entity.XAPIStatement, 0, len(users))
+	userIDs := make(map[string]string, len(users))
+	assessmentUserIDs := make([]string, 0, len(users))
+	for _, user := range users {
+		userIDs[user.ID] = user.UserID
+		assessmentUserIDs = append(assessmentUserIDs, user.ID)
+		statements = append(statements, builder.AssessmentCompleted(&entity.XAPIAssessmentCompletion{
+			OrgID:          assessment.OrgID,
+			AssessmentID:   assessment.ID,
+			Title:          assessment.Title,
+			AssessmentType: assessment.AssessmentType.String(),
+			ScheduleID:     assessment.ScheduleID,
+			UserID:         user.UserID,
+			CompleteAt:     assessment.CompleteAt,
+		}))
+	}
+
+	var userOutcomes []*v2.AssessmentUserOutcome
+	outcomeCondition := &assessmentV2.AssessmentUserOutcomeCondition{
+		AssessmentUserIDs: entity.NullStrings{Strings: assessmentUserIDs, Valid: true},
+		Status:            sql.NullString{String: v2.AssessmentUserOutcomeStatusAchieved.String(), Valid: true},
+	}
+	if err := assessmentV2.GetAssessmentUserOutcomeDA().Query(ctx, outcomeCondition, &userOutcomes); err != nil {
+		log.Error(ctx, "query assessment user outcomes failed", log.Err(err), log.Any("condition", outcomeCondition))
+		return err
+	}
+
+	if len(userOutcomes) > 0 {
+		outcomeIDs := make([]string, 0, len(userOutcomes))
+		for _, item := range userOutcomes {
+			outcomeIDs = append(outcomeIDs, item.OutcomeID)
+		}
+		_, outcomes, err := da.GetOutcomeDA().SearchOutcome(ctx, op, dbo.MustGetDB(ctx), &da.OutcomeCondition{
+			IDs:            dbo.NullStrings{Strings: utils.SliceDeduplicationExcludeEmpty(outcomeIDs), Valid: true},
+			IncludeDeleted: true,
+		})
+		if err != nil {
+			log.Error(ctx, "search outcomes failed", log.Err(err), log.Strings("outcomeIDs", outcomeIDs))
+			return err
+		}
+		outcomeMap := make(map[string]*entity.Outcome, len(outcomes))
+		for _, item := range outcomes {
+			outcomeMap[item.ID] = item
+		}
+
+		// an outcome may be achieved in several contents of the assessment
+		achieved := make(map[string]bool, len(userOutcomes))
+		for _, item := range userOutcomes {
+			key := item.AssessmentUserID + "|" + item.OutcomeID
+			if achieved[key] {
+				continue
+			}
+			achieved[key] = true
+
+			achievement := &entity.XAPIOutcomeAchievement{
+				OrgID:        assessment.OrgID,
+				OutcomeID:    item.OutcomeID,
+				AssessmentID: assessment.ID,
+				UserID:       userIDs[item.AssessmentUserID],
+				AchievedAt:   assessment.CompleteAt,
+			}
+			if outcome, ok := outcomeMap[item.OutcomeID]; ok {
+				achievement.OutcomeName = outcome.Name
+				achievement.Shortcode = outcome.Shortcode
+			}
+			statements = append(statements, builder.OutcomeAchieved(achievement))
+		}
+	}
+
+	return m.Record(ctx, assessment.OrgID, statements)
+}
+
+func (m *xapiModel) Forward(ctx context.Context) (int, error) {
+	conf := config.Get().XAPI
+	if conf.LRSEndpoint == "" {
+		return 0, nil
+	}
+
+	count := 0
+	for i := 0; i < xapiForwardMaxBatches; i++ {
+		condition := &da.XAPIStatementCondition{
+			Due: sql.NullInt64{
+				Int64: time.Now().Unix(),
+				Valid: true,
+			},
+			OrderBy: "next_attempt_at",
+			Pager: dbo.Pager{
+				Page:     1,
+				PageSize: conf.BatchSize,
+			},
+		}
+
+		var records []*entity.XAPIStatementRecord
+		if err := da.GetXAPIStatementDA().Query(ctx, condition, &records); err != nil {
+			log.Error(ctx, "query due xapi statements failed", log.Err(err), log.Any("condition", condition))
+			return count, err
+		}
+		if len(records) == 0 {
+			break
+		}
+
+		m.forward(ctx, records)
+		count += len(records)
+
+		if len(records) < conf.BatchSize {
+			break
+		}
+	}
+
+	return count, nil
+}
+
+func (m *xapiModel) forward(ctx context.Context, records []*entity.XAPIStatementRecord) {
+	conf := config.Get().XAPI
+
+	statusCode, body, err := m.post(ctx, records)
+	now := time.Now().Unix()
+	// the lrs refuses the request when any statement is invalid or conflicts
+	rejected := err == nil && (statusCode == http.StatusBadRequest || statusCode == http.StatusConflict)
+
+	switch {
+	case err == nil && statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices:
+		orgIDs := make(map[string][]string)
+		for _, record := range records {
+			orgIDs[record.OrgID] = append(orgIDs[record.OrgID], record.ID)
+		}
+		for orgID, ids := range orgIDs {
+			if err := da.GetXAPIStatementDA().MarkForwarded(ctx, orgID, ids, now); err != nil {
+				log.Error(ctx, "mark xapi statements forwarded failed", log.Err(err), log.String("org_id", orgID), log.Int("count", len(ids)))
+			}
+		}
+		return
+	case rejected && len(records) > 1:
+		// the others should not wait for the rejected ones
+		for _, record := range records {
+			m.forward(ctx, []*entity.XAPIStatementRecord{record})
+		}
+		return
+	}
+
+	reason := fmt.Sprintf("unexpected status %d: %s", statusCode, body)
+	if err != nil {
+		reason = err.Error()
+	}
+	log.Warn(ctx, "forward xapi statements failed",
+		log.String("reason", reason),
+		log.Int("count", len(records)),
+		log.String("endpoint", conf.LRSEndpoint))
+
+	maxAttempts := conf.MaxAttempts
+	if rejected {
+		maxAttempts = 0
+	}
+	for _, record := range records {
+		record.Fail(reason, maxAttempts, conf.RetryBackoff, now)
+		if _, err := da.GetXAPIStatementDA().Update(ctx, record); err != nil {
+			log.Error(ctx, "update xapi statement failed", log.Err(err), log.String("id", record.ID))
+		}
+	}
+}
+
+func (m *xapiModel) post(ctx context.Context, records []*entity.XAPIStatementRecord) (int, string, error) {
+	conf := config.Get().XAPI
+
+	body := new(bytes.Buffer)
+	body.WriteByte('[')
+	for i, record := range records {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.WriteString(record.Statement)
+	}
+	body.WriteByte(']')
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, conf.LRSEndpoint+"/statements", body)
+	if err != nil {
+		return 0, "", err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", xapiUserAgent)
+	request.Header.Set(entity.XAPIHeaderVersion, entity.XAPIVersion)
+	if conf.LRSUsername != "" {
+		request.SetBasicAuth(conf.LRSUsername, conf.LRSPassword)
+	}
+
+	response, err := m.client.Do(request)
+	if err != nil {
+		return 0, "", err
+	}
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(io.LimitReader(response.Body, xapiResponseBodyMaxBytes))
+	if err != nil {
+		return response.StatusCode, "", err
+	}
+
+	return response.StatusCode, string(responseBody), nil
+}
+
+// recordXAPIMaterialViews a lost statement does not fail the usage records
+func recordXAPIMaterialViews(ctx context.Context, orgID string, records []*entity.StudentUsageRecord) {
+	if !config.Get().XAPI.Enabled || len(records) == 0 {
+		return
+	}
+
+	builder := newXAPIStatementBuilder()
+	statements := make([]*entity.XAPIStatement, len(records))
+	for i, record := range records {
+		statements[i] = builder.MaterialViewed(orgID, record)
+	}
+
+	if err := GetXAPIModel().Record(ctx, orgID, statements); err != nil {
+		log.Warn(ctx, "record xapi statements of material views failed", log.Err(err), log.Int("count", len(records)))
+	}
+}
+
+// recordXAPIAttendance statements of the users attending the live room
+func recordXAPIAttendance(ctx context.Context, op *entity.Operator, event *v2.ScheduleEndClassCallBackReq) error {
+	attendanceIDs := utils.SliceDeduplicationExcludeEmpty(event.AttendanceIDs)
+	if !config.Get().XAPI.Enabled || len(attendanceIDs) == 0 {
+		return nil
+	}
+
+	schedule, err := GetScheduleModel().GetPlainByID(ctx, event.ScheduleID)
+	if err == constant.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	classID, err := GetScheduleRelationModel().GetClassRosterID(ctx, op, schedule.ID)
+	if err != nil {
+		return err
+	}
+
+	classEndAt := event.ClassEndAt
+	if classEndAt <= 0 {
+		classEndAt = time.Now().Unix()
+	}
+
+	builder := newXAPIStatementBuilder()
+	statements := make([]*entity.XAPIStatement, len(attendanceIDs))
+	for i, userID := range attendanceIDs {
+		statements[i] = builder.ClassAttended(&entity.XAPIClassAttendance{
+			OrgID:       schedule.OrgID,
+			ScheduleID:  schedule.ID,
+			Title:       schedule.Title,
+			ClassType:   schedule.ClassType.String(),
+			ClassID:     classID,
+			UserID:      userID,
+			ClassLength: event.ClassLength,
+			ClassEndAt:  classEndAt,
+		})
+	}
+
+	return GetXAPIModel().Record(ctx, schedule.OrgID, statements)
+}
+
+// emitXAPIAssessmentCompletedTx like emitAssessmentCompletedTx, an event which can't be written does not fail the completion
+func emitXAPIAssessmentCompletedTx(ctx context.Context, tx *dbo.DBContext, op *entity.Operator, assessment *v2.Assessment) {
+	if err := GetXAPIModel().PublishAssessmentCompletedTx(ctx, tx, op, assessment.ID); err != nil {
+		log.Warn(ctx, "publish xapi assessment completed failed", log.Err(err), log.String("assessmentID", assessment.ID))
+	}
+}
@@ -0,0 +1,82 @@
+CREATE TABLE IF NOT EXISTS `assessments_rubrics_v2` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `name` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `description` text COLLATE utf8mb4_unicode_ci default NULL COMMENT 'description',
+    `creator_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'creator id',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `assessments_rubrics_org_id` (`org_id`),
+    KEY `assessments_rubrics_delete_at` (`delete_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='assessments_rubrics_v2';
+
+CREATE TABLE IF NOT EXISTS `assessments_rubrics_criteria_v2` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `rubric_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'rubric id',
+    `name` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `description` text COLLATE utf8mb4_unicode_ci default NULL COMMENT 'description',
+    `outcome_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'linked outcome id',
+    `sort_index` int(11) NOT NULL DEFAULT '0' COMMENT 'sort index',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `rubric_id` (`rubric_id`),
+    KEY `delete_at` (`delete_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='assessments_rubrics_criteria_v2';
+
+CREATE TABLE IF NOT EXISTS `assessments_rubrics_levels_v2` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `rubric_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'rubric id',
+    `name` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `description` text COLLATE utf8mb4_unicode_ci default NULL COMMENT 'description',
+    `points` int(11) NOT NULL DEFAULT '0' COMMENT 'point value',
+    `sort_index` int(11) NOT NULL DEFAULT '0' COMMENT 'sort index',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `rubric_id` (`rubric_id`),
+    KEY `delete_at` (`delete_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='assessments_rubrics_levels_v2';
+
+CREATE TABLE IF NOT EXISTS `assessments_rubrics_relations_v2` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `rubric_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'rubric id',
+    `relation_type` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'relation type: LessonPlan, Schedule',
+    `relation_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'lesson plan id or schedule id',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `rubric_id` (`rubric_id`),
+    KEY `delete_at` (`delete_at`),
+
+    UNIQUE `assessments_rubrics_relations_unique` (`org_id`,`relation_type`,`relation_id`,`delete_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='assessments_rubrics_relations_v2';
+
+CREATE TABLE IF NOT EXISTS `assessments_users_rubric_scores_v2` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `assessment_user_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'assessment user id',
+    `rubric_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'rubric id',
+    `criterion_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'criterion id',
+    `level_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'performance level id',
+    `points` int(11) NOT NULL DEFAULT '0' COMMENT 'points of the selected level',
+    `reviewer_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'reviewer id',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `assessment_user_id` (`assessment_user_id`),
+    KEY `delete_at` (`delete_at`),
+
+    UNIQUE `assessments_users_rubric_scores_unique` (`assessment_user_id`,`criterion_id`,`delete_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='assessments_users_rubric_scores_v2';
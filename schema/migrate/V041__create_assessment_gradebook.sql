@@ -0,0 +1,65 @@
+CREATE TABLE IF NOT EXISTS `assessments_gradebook_line_items_v2` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'assessment id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `class_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'class id',
+    `school_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'school id',
+    `schedule_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'schedule id',
+    `assessment_type` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'assessment type',
+    `title` varchar(1024) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'title',
+    `max_score` double NOT NULL DEFAULT '0' COMMENT 'max score',
+    `assign_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'assign time (unix seconds)',
+    `due_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'due time (unix seconds)',
+    `complete_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'assessment complete time (unix seconds)',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `gradebook_line_items_org_class` (`org_id`, `class_id`),
+    KEY `gradebook_line_items_update_at` (`update_at`),
+    KEY `gradebook_line_items_delete_at` (`delete_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='assessments_gradebook_line_items_v2';
+
+CREATE TABLE IF NOT EXISTS `assessments_gradebook_results_v2` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'assessment user id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `line_item_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'line item id (assessment id)',
+    `class_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'class id',
+    `student_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'student id',
+    `score_status` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'score status',
+    `score` double NOT NULL DEFAULT '0' COMMENT 'score',
+    `max_score` double NOT NULL DEFAULT '0' COMMENT 'max score',
+    `comment` text COLLATE utf8mb4_unicode_ci default NULL COMMENT 'reviewer comment',
+    `outcomes_achieved` int(11) NOT NULL DEFAULT '0' COMMENT 'number of achieved outcomes',
+    `outcomes_not_achieved` int(11) NOT NULL DEFAULT '0' COMMENT 'number of not achieved outcomes',
+    `outcomes_total` int(11) NOT NULL DEFAULT '0' COMMENT 'number of outcomes',
+    `score_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'score time (unix seconds)',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `gradebook_results_line_item_id` (`line_item_id`),
+    KEY `gradebook_results_org_class` (`org_id`, `class_id`),
+    KEY `gradebook_results_org_student` (`org_id`, `student_id`),
+    KEY `gradebook_results_update_at` (`update_at`),
+    KEY `gradebook_results_delete_at` (`delete_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='assessments_gradebook_results_v2';
+
+CREATE TABLE IF NOT EXISTS `organizations_api_keys` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `name` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `key_prefix` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'first characters of the key, for display',
+    `key_hash` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'sha256 of the key',
+    `creator_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'creator id',
+    `last_used_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'last used time (unix seconds)',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `organizations_api_keys_key_hash` (`key_hash`),
+    KEY `organizations_api_keys_org_id` (`org_id`),
+    KEY `organizations_api_keys_delete_at` (`delete_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='organizations_api_keys';
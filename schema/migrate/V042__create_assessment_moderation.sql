@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS `assessments_moderation_policies_v2` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `program_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'program id',
+    `sample_percent` int(11) NOT NULL DEFAULT '0' COMMENT 'percentage of completed assessments sampled for moderation',
+    `fail_threshold` double NOT NULL DEFAULT '0' COMMENT 'score ratio below which moderation is mandatory, 0 disables',
+    `creator_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'creator id',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `moderation_policies_org_program` (`org_id`, `program_id`),
+    KEY `moderation_policies_delete_at` (`delete_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='assessments_moderation_policies_v2';
+
+CREATE TABLE IF NOT EXISTS `assessments_moderations_v2` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `assessment_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'assessment id',
+    `program_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'program id',
+    `reason` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'Sampled, FailingScore',
+    `status` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'Pending, Reconciled',
+    `marker_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'teacher who submitted the original result',
+    `moderator_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'second marker',
+    `original` mediumtext COLLATE utf8mb4_unicode_ci COMMENT 'original scores and outcome decisions (json)',
+    `moderated` mediumtext COLLATE utf8mb4_unicode_ci COMMENT 'moderated scores and outcome decisions (json)',
+    `final` mediumtext COLLATE utf8mb4_unicode_ci COMMENT 'reconciled final result (json)',
+    `reconciled_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'reconciled time (unix seconds)',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `moderations_org_status` (`org_id`, `status`),
+    KEY `moderations_assessment_id` (`assessment_id`),
+    KEY `moderations_delete_at` (`delete_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='assessments_moderations_v2';
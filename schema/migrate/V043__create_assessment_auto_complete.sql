@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS `assessments_auto_complete_rules_v2` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `assessment_type` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'OnlineClass, OnlineStudy',
+    `trigger_type` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'DaysAfterClassEnd, DaysAfterDue, AllStudentsAttempted, CompletionRate',
+    `days` int(11) NOT NULL DEFAULT '0' COMMENT 'days after class end or due',
+    `completion_rate` double NOT NULL DEFAULT '0' COMMENT 'completion rate, 0-1',
+    `creator_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'creator id',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `auto_complete_rules_org_id` (`org_id`),
+    KEY `auto_complete_rules_delete_at` (`delete_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='assessments_auto_complete_rules_v2';
+
+ALTER TABLE `assessments_v2` ADD COLUMN `complete_by` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'Teacher, System';
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS `domain_events` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `topic` varchar(128) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'topic',
+    `payload` mediumtext COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'event json',
+    `operator` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'operator json, without token',
+    `status` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'Pending, Dispatched, Succeeded, Dead',
+    `attempts` int(11) NOT NULL DEFAULT '0' COMMENT 'failed attempts',
+    `last_error` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'error of the last failed attempt',
+    `next_attempt_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'dispatch not before (unix seconds)',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `domain_events_status_next_attempt_at` (`status`, `next_attempt_at`),
+    KEY `domain_events_topic` (`topic`),
+    KEY `domain_events_create_at` (`create_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='domain_events';
+
+CREATE TABLE IF NOT EXISTS `domain_events_consumptions` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `event_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'domain event id',
+    `handler` varchar(128) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'handler name',
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `domain_events_consumptions_event_handler` (`event_id`, `handler`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='domain_events_consumptions';
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS `webhooks` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `name` varchar(128) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `url` varchar(1024) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'https url',
+    `secret` varchar(128) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'hmac secret',
+    `event_types` varchar(1024) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'subscribed event types, comma separated',
+    `enabled` tinyint(1) NOT NULL DEFAULT '1' COMMENT 'enabled',
+    `creator_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'creator id',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `webhooks_org_id` (`org_id`),
+    KEY `webhooks_delete_at` (`delete_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='webhooks';
+
+CREATE TABLE IF NOT EXISTS `webhooks_deliveries` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `webhook_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'webhook id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `event_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'event id',
+    `event_type` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'event type',
+    `payload` mediumtext COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'request body',
+    `status` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'Pending, Succeeded, Dead',
+    `attempts` int(11) NOT NULL DEFAULT '0' COMMENT 'attempts',
+    `response_status` int(11) NOT NULL DEFAULT '0' COMMENT 'http status of the last attempt',
+    `response_body` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'truncated response body of the last attempt',
+    `last_error` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'error of the last failed attempt',
+    `next_attempt_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'deliver not before (unix seconds)',
+    `delivered_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'delivered time (unix seconds)',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `webhooks_deliveries_webhook_event` (`webhook_id`, `event_id`),
+    KEY `webhooks_deliveries_org_id` (`org_id`),
+    KEY `webhooks_deliveries_status_next_attempt_at` (`status`, `next_attempt_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='webhooks_deliveries';
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS `jobs` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `type` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'job type',
+    `params` mediumtext COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'params json',
+    `status` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'Pending, Running, Succeeded, Failed, Cancelled',
+    `progress` int(11) NOT NULL DEFAULT '0' COMMENT 'progress percentage',
+    `attempts` int(11) NOT NULL DEFAULT '0' COMMENT 'attempts',
+    `last_error` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'error of the last failed attempt',
+    `result` mediumtext COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'result json',
+    `has_file` tinyint(1) NOT NULL DEFAULT '0' COMMENT 'has a downloadable file',
+    `next_attempt_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'enqueue not before (unix seconds)',
+    `start_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'start time of the last attempt (unix seconds)',
+    `finish_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'finish time (unix seconds)',
+    `creator_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'creator id',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `jobs_org_id_creator_id` (`org_id`, `creator_id`),
+    KEY `jobs_status_next_attempt_at` (`status`, `next_attempt_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='jobs';
+
+CREATE TABLE IF NOT EXISTS `jobs_files` (
+    `job_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'job id',
+    `file_name` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'file name',
+    `content_type` varchar(128) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'content type',
+    `data` mediumblob NOT NULL COMMENT 'file data',
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    PRIMARY KEY (`job_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='jobs_files';
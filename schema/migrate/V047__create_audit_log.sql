@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS `audit_logs` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `actor_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'user id of the operator',
+    `action` varchar(128) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'api handler name',
+    `target_type` varchar(128) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'resource of the route',
+    `target_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'target id',
+    `before_snapshot` mediumtext COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'snapshot before the change',
+    `after_snapshot` mediumtext COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'request body or snapshot after the change',
+    `method` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'http method',
+    `path` varchar(1024) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'request path',
+    `status_code` int(11) NOT NULL DEFAULT '0' COMMENT 'response status code',
+    `ip` varchar(256) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'client ip',
+    `user_agent` varchar(512) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'user agent',
+    `duration` bigint(20) NOT NULL DEFAULT '0' COMMENT 'duration (milliseconds)',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `audit_logs_org_id_create_at` (`org_id`, `create_at`),
+    KEY `audit_logs_org_id_target` (`org_id`, `target_type`, `target_id`),
+    KEY `audit_logs_org_id_actor_id` (`org_id`, `actor_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='audit_logs';
+
+CREATE TABLE IF NOT EXISTS `audit_settings` (
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `retention_days` int(11) NOT NULL COMMENT 'logs older than it are purged',
+    `updater_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'updater id',
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`org_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='audit_settings';
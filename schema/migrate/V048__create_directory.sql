@@ -0,0 +1,123 @@
+CREATE TABLE IF NOT EXISTS `directory_organizations` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `name` varchar(256) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `status` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'active' COMMENT 'active or inactive',
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_organizations';
+
+CREATE TABLE IF NOT EXISTS `directory_users` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `given_name` varchar(256) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'given name',
+    `family_name` varchar(256) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'family name',
+    `email` varchar(256) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'email',
+    `avatar` varchar(1024) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'avatar',
+    `status` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'active' COMMENT 'active or inactive',
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `directory_users_email` (`email`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_users';
+
+CREATE TABLE IF NOT EXISTS `directory_schools` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `name` varchar(256) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `status` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'active' COMMENT 'active or inactive',
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `directory_schools_org_id` (`org_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_schools';
+
+CREATE TABLE IF NOT EXISTS `directory_classes` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `name` varchar(256) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `status` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'active' COMMENT 'active or inactive',
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `directory_classes_org_id` (`org_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_classes';
+
+CREATE TABLE IF NOT EXISTS `directory_roles` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'org id, empty for system roles',
+    `name` varchar(256) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `status` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'active' COMMENT 'active or inactive',
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `directory_roles_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_roles';
+
+CREATE TABLE IF NOT EXISTS `directory_programs` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'org id, empty for system programs',
+    `name` varchar(256) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `group_name` varchar(256) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'group name',
+    `status` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'active' COMMENT 'active or inactive',
+    `system` tinyint(1) NOT NULL DEFAULT '0' COMMENT 'system or not',
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `directory_programs_org_id` (`org_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_programs';
+
+CREATE TABLE IF NOT EXISTS `directory_subjects` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'org id, empty for system subjects',
+    `name` varchar(256) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `status` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'active' COMMENT 'active or inactive',
+    `system` tinyint(1) NOT NULL DEFAULT '0' COMMENT 'system or not',
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `directory_subjects_org_id` (`org_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_subjects';
+
+CREATE TABLE IF NOT EXISTS `directory_memberships` (
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `school_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'school id, empty for the organization',
+    `user_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'user id',
+    PRIMARY KEY (`org_id`, `school_id`, `user_id`),
+    KEY `directory_memberships_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_memberships';
+
+CREATE TABLE IF NOT EXISTS `directory_membership_roles` (
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `school_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'school id, empty for the organization',
+    `user_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'user id',
+    `role_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'role id',
+    PRIMARY KEY (`org_id`, `school_id`, `user_id`, `role_id`),
+    KEY `directory_membership_roles_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_membership_roles';
+
+CREATE TABLE IF NOT EXISTS `directory_role_permissions` (
+    `role_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'role id',
+    `permission_name` varchar(128) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'permission name',
+    PRIMARY KEY (`role_id`, `permission_name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_role_permissions';
+
+CREATE TABLE IF NOT EXISTS `directory_class_schools` (
+    `class_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'class id',
+    `school_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'school id',
+    PRIMARY KEY (`class_id`, `school_id`),
+    KEY `directory_class_schools_school_id` (`school_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_class_schools';
+
+CREATE TABLE IF NOT EXISTS `directory_class_members` (
+    `class_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'class id',
+    `user_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'user id',
+    `join_type` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'teaching or studying',
+    PRIMARY KEY (`class_id`, `user_id`, `join_type`),
+    KEY `directory_class_members_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_class_members';
+
+CREATE TABLE IF NOT EXISTS `directory_program_subjects` (
+    `program_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'program id',
+    `subject_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'subject id',
+    PRIMARY KEY (`program_id`, `subject_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='directory_program_subjects';
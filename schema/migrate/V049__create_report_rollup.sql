@@ -0,0 +1,53 @@
+CREATE TABLE IF NOT EXISTS `report_rollups_daily` (
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `period_start` bigint(20) NOT NULL COMMENT 'start of the day (unix seconds)',
+    `school_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'school id, empty for all schools',
+    `class_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'class roster class id',
+    `subject_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'subject id, empty for all subjects',
+    `user_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'user id',
+    `user_type` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'Teacher or Student',
+    `assessment_type` varchar(32) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'assessment type',
+    `lessons` int(11) NOT NULL DEFAULT '0' COMMENT 'assessments of the user',
+    `attended` int(11) NOT NULL DEFAULT '0' COMMENT 'lessons the user attended or started',
+    `completed` int(11) NOT NULL DEFAULT '0' COMMENT 'lessons with a completed assessment',
+    `duration` bigint(20) NOT NULL DEFAULT '0' COMMENT 'scheduled seconds of the lessons',
+    `update_at` bigint(20) NOT NULL COMMENT 'refresh time (unix seconds)',
+    PRIMARY KEY (`org_id`, `period_start`, `school_id`, `class_id`, `subject_id`, `user_id`, `user_type`, `assessment_type`),
+    KEY `report_rollups_daily_org_id_user_id` (`org_id`, `user_id`, `period_start`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='report_rollups_daily';
+
+CREATE TABLE IF NOT EXISTS `report_rollups_weekly` (
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `period_start` bigint(20) NOT NULL COMMENT 'start of the week (unix seconds)',
+    `school_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'school id, empty for all schools',
+    `class_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'class roster class id',
+    `subject_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'subject id, empty for all subjects',
+    `user_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'user id',
+    `user_type` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'Teacher or Student',
+    `assessment_type` varchar(32) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'assessment type',
+    `lessons` int(11) NOT NULL DEFAULT '0' COMMENT 'assessments of the user',
+    `attended` int(11) NOT NULL DEFAULT '0' COMMENT 'lessons the user attended or started',
+    `completed` int(11) NOT NULL DEFAULT '0' COMMENT 'lessons with a completed assessment',
+    `duration` bigint(20) NOT NULL DEFAULT '0' COMMENT 'scheduled seconds of the lessons',
+    `update_at` bigint(20) NOT NULL COMMENT 'refresh time (unix seconds)',
+    PRIMARY KEY (`org_id`, `period_start`, `school_id`, `class_id`, `subject_id`, `user_id`, `user_type`, `assessment_type`),
+    KEY `report_rollups_weekly_org_id_user_id` (`org_id`, `user_id`, `period_start`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='report_rollups_weekly';
+
+CREATE TABLE IF NOT EXISTS `report_rollups_dirty` (
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `period_start` bigint(20) NOT NULL COMMENT 'start of the day to refresh (unix seconds)',
+    `mark_at` bigint(20) NOT NULL COMMENT 'last change time (unix seconds)',
+    PRIMARY KEY (`org_id`, `period_start`),
+    KEY `report_rollups_dirty_mark_at` (`mark_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='report_rollups_dirty';
+
+CREATE TABLE IF NOT EXISTS `report_rollups_states` (
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `cover_from` bigint(20) NOT NULL COMMENT 'rollups are complete from it on (unix seconds)',
+    `rebuild_at` bigint(20) NOT NULL COMMENT 'last full rebuild time (unix seconds)',
+    `refresh_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'last incremental refresh time (unix seconds)',
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`org_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='report_rollups_states';
@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS `report_subscriptions` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `name` varchar(128) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `report_type` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'report type',
+    `params` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'report filter, json',
+    `format` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'csv, xlsx, pdf',
+    `recipients` varchar(2048) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'email addresses, comma separated',
+    `cadence` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'daily, weekly, monthly',
+    `weekday` int(11) NOT NULL DEFAULT '0' COMMENT 'weekday of weekly subscriptions, 0 is sunday',
+    `month_day` int(11) NOT NULL DEFAULT '0' COMMENT 'day of monthly subscriptions',
+    `hour` int(11) NOT NULL DEFAULT '0' COMMENT 'hour of the run',
+    `time_zone` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'iana time zone',
+    `status` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'Active, Paused',
+    `next_run_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'next run (unix seconds)',
+    `last_run_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'last run (unix seconds)',
+    `creator_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'creator id, the report runs as the creator',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `report_subscriptions_org_id_creator_id` (`org_id`, `creator_id`),
+    KEY `report_subscriptions_status_next_run_at` (`status`, `next_run_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='report_subscriptions';
+
+CREATE TABLE IF NOT EXISTS `report_subscriptions_deliveries` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `subscription_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'subscription id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `status` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'Succeeded, Failed',
+    `period_start` bigint(20) NOT NULL COMMENT 'reported from (unix seconds)',
+    `period_end` bigint(20) NOT NULL COMMENT 'reported until (unix seconds)',
+    `recipients` varchar(2048) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'mailed email addresses, comma separated',
+    `rows` int(11) NOT NULL DEFAULT '0' COMMENT 'rows of the report',
+    `file_name` varchar(256) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'attachment',
+    `error` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'error of a failed delivery',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `report_subscriptions_deliveries_subscription_id` (`subscription_id`, `create_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='report_subscriptions_deliveries';
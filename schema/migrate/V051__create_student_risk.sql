@@ -0,0 +1,57 @@
+CREATE TABLE IF NOT EXISTS `student_risk_settings` (
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `enabled` tinyint(1) NOT NULL DEFAULT '0' COMMENT 'evaluate students of the organization',
+    `window_days` int(11) NOT NULL COMMENT 'days of the rolling window',
+    `attendance_weight` double NOT NULL COMMENT 'weight of attendance',
+    `assignment_weight` double NOT NULL COMMENT 'weight of assignment completion',
+    `outcome_weight` double NOT NULL COMMENT 'weight of outcome achievement',
+    `attendance_threshold` double NOT NULL COMMENT 'attendance rates below are reasons',
+    `assignment_threshold` double NOT NULL COMMENT 'assignment completion rates below are reasons',
+    `outcome_threshold` double NOT NULL COMMENT 'outcome achievement rates below are reasons',
+    `risk_threshold` double NOT NULL COMMENT 'students scoring at least the threshold are at risk',
+    `min_samples` int(11) NOT NULL COMMENT 'signals of fewer samples are ignored',
+    `notify_recipients` varchar(2048) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'email addresses, comma separated',
+    `updater_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'updater id',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`org_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='student_risk_settings';
+
+CREATE TABLE IF NOT EXISTS `student_risk_flags` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `class_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'class id',
+    `student_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'student id',
+    `at_risk` tinyint(1) NOT NULL DEFAULT '0' COMMENT 'at risk',
+    `score` double NOT NULL COMMENT 'risk score from 0 to 1',
+    `signals` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'signals, json',
+    `reasons` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'reasons, json',
+    `window_start` bigint(20) NOT NULL COMMENT 'window from (unix seconds)',
+    `window_end` bigint(20) NOT NULL COMMENT 'window until (unix seconds)',
+    `changed_at` bigint(20) NOT NULL COMMENT 'flag change time (unix seconds)',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'evaluate time (unix seconds)',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `student_risk_flags_class_id_student_id` (`class_id`, `student_id`),
+    KEY `student_risk_flags_org_id` (`org_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='student_risk_flags';
+
+CREATE TABLE IF NOT EXISTS `student_risk_flags_histories` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `class_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'class id',
+    `student_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'student id',
+    `at_risk` tinyint(1) NOT NULL DEFAULT '0' COMMENT 'at risk after the change',
+    `score` double NOT NULL COMMENT 'risk score from 0 to 1',
+    `signals` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'signals, json',
+    `reasons` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'reasons, json',
+    `window_start` bigint(20) NOT NULL COMMENT 'window from (unix seconds)',
+    `window_end` bigint(20) NOT NULL COMMENT 'window until (unix seconds)',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'change time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `student_risk_flags_histories_class_id_student_id` (`class_id`, `student_id`, `create_at`),
+    KEY `student_risk_flags_histories_org_id` (`org_id`, `create_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='student_risk_flags_histories';
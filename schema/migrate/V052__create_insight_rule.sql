@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS `insight_rule_sets` (
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `rules` mediumtext COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'rules, json, empty for the default rules',
+    `updater_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'updater id',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`org_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='insight_rule_sets';
+
+CREATE TABLE IF NOT EXISTS `insight_rule_logs` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `class_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'class id',
+    `student_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'student id',
+    `category` varchar(32) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'learning_outcome, attendance, assignment',
+    `rule_key` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'key of the fired rule',
+    `label_id` varchar(128) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'label of the fired rule',
+    `params` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'params, json',
+    `preview` tinyint(1) NOT NULL DEFAULT '0' COMMENT 'fired by a preview',
+    `operator_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'user requesting the insight',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `insight_rule_logs_org_id_create_at` (`org_id`, `create_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='insight_rule_logs';
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `report_definitions` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `name` varchar(128) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'name',
+    `description` varchar(1024) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' COMMENT 'description',
+    `query` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'report builder query, json',
+    `shared` tinyint(1) NOT NULL DEFAULT '0' COMMENT 'visible to the whole organization',
+    `creator_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'creator id',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    `delete_at` bigint(20) NOT NULL COMMENT 'delete time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `report_definitions_org_id_creator_id` (`org_id`, `creator_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='report_definitions';
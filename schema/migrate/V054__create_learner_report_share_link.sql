@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS `learner_report_share_links` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'id',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `class_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'class id',
+    `student_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'student id',
+    `start_at` bigint(20) NOT NULL COMMENT 'report start time (unix seconds)',
+    `end_at` bigint(20) NOT NULL COMMENT 'report end time (unix seconds)',
+    `expire_at` bigint(20) NOT NULL COMMENT 'expire time (unix seconds)',
+    `revoke_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'revoke time (unix seconds)',
+    `view_count` int(11) NOT NULL DEFAULT '0' COMMENT 'views of the link',
+    `last_view_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'last view time (unix seconds)',
+    `creator_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'creator id',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'create time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `learner_report_share_links_org_id_class_id_student_id` (`org_id`, `class_id`, `student_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='learner_report_share_links';
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS `xapi_statements` (
+    `id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'statement id (uuid)',
+    `org_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'org id',
+    `actor_key` varchar(512) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'identifier of the actor',
+    `verb_id` varchar(512) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'verb iri',
+    `object_id` varchar(1024) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'activity iri or statement id',
+    `timestamp` bigint(20) NOT NULL COMMENT 'time of the experience (unix seconds)',
+    `statement` mediumtext COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'statement json',
+    `forward_status` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'None, Pending, Forwarded, Dead',
+    `attempts` int(11) NOT NULL DEFAULT '0' COMMENT 'forward attempts',
+    `last_error` text COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'error of the last failed attempt',
+    `next_attempt_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'forward not before (unix seconds)',
+    `forwarded_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'forwarded time (unix seconds)',
+
+    `create_at` bigint(20) NOT NULL COMMENT 'stored time (unix seconds)',
+    `update_at` bigint(20) NOT NULL COMMENT 'update time (unix seconds)',
+    PRIMARY KEY (`id`),
+    KEY `xapi_statements_org_id_create_at` (`org_id`, `create_at`),
+    KEY `xapi_statements_org_id_actor_key` (`org_id`, `actor_key`(191)),
+    KEY `xapi_statements_forward_status_next_attempt_at` (`forward_status`, `next_attempt_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='xapi_statements';
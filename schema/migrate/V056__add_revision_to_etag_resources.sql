@@ -0,0 +1,5 @@
+ALTER TABLE `schedules` ADD COLUMN `revision` bigint(20) NOT NULL DEFAULT '0' COMMENT 'increased by every write with an etag';
+ALTER TABLE `cms_contents` ADD COLUMN `revision` bigint(20) NOT NULL DEFAULT '0' COMMENT 'increased by every write with an etag';
+ALTER TABLE `learning_outcomes` ADD COLUMN `revision` bigint(20) NOT NULL DEFAULT '0' COMMENT 'increased by every write with an etag';
+ALTER TABLE `milestones` ADD COLUMN `revision` bigint(20) NOT NULL DEFAULT '0' COMMENT 'increased by every write with an etag';
+ALTER TABLE `cms_folder_items` ADD COLUMN `revision` bigint(20) NOT NULL DEFAULT '0' COMMENT 'increased by every write with an etag';
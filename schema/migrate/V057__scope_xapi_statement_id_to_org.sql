@@ -0,0 +1 @@
+ALTER TABLE `xapi_statements` DROP PRIMARY KEY, ADD PRIMARY KEY (`org_id`, `id`);
@@ -0,0 +1,3 @@
+ALTER TABLE `assessments_v2` ADD COLUMN `reopened_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'reopened after completed by system (unix seconds)';
+ALTER TABLE `assessments_v2` ADD COLUMN `auto_complete_checked_at` bigint(20) NOT NULL DEFAULT '0' COMMENT 'last evaluated by auto complete rules (unix seconds)';
+ALTER TABLE `assessments_v2` ADD INDEX `assessments_v2_org_id_auto_complete_checked_at` (`org_id`, `auto_complete_checked_at`);
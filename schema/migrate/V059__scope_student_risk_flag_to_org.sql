@@ -0,0 +1 @@
+ALTER TABLE `student_risk_flags` DROP INDEX `student_risk_flags_class_id_student_id`, ADD UNIQUE KEY `student_risk_flags_org_id_class_id_student_id` (`org_id`, `class_id`, `student_id`);
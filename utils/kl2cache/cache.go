@@ -49,6 +49,8 @@ type Provider interface {
 	//
 	// note: val must be a pointer of slice, and it's item must has the same type of KeyValue.Val
 	BatchGet(ctx context.Context, keys []Key, val interface{}, fGetData FuncBatchGet) (err error)
+	// Invalidate delete keys from all tiers of all instances, call it after the data of keys changed
+	Invalidate(ctx context.Context, keys ...Key) (err error)
 }
 
 var DefaultProvider Provider
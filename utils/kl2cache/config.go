@@ -28,6 +28,11 @@ type config struct {
 		Port     int
 		Password string
 	}
+	// Local the local tier is off when capacity is zero
+	Local struct {
+		Capacity   int
+		Expiration time.Duration
+	}
 
 	ExpireStrategy ExpireStrategy
 }
@@ -58,5 +63,8 @@ func Init(ctx context.Context, opts ...Option) (err error) {
 	if err != nil {
 		return
 	}
+	if conf.Enable && conf.Local.Capacity > 0 && conf.Local.Expiration > 0 {
+		initTiered(ctx, conf, DefaultProvider.(*redisProvider))
+	}
 	return
 }
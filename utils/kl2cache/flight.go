@@ -0,0 +1,49 @@
+package kl2cache
+
+import "sync"
+
+type flightCall struct {
+	done   chan struct{}
+	result interface{}
+	err    error
+	// panicked the panic is raised again in the caller which ran fn, waiters get an error
+	panicked bool
+}
+
+// flightGroup concurrent calls of the same key run fn once and share the result
+type flightGroup struct {
+	lock  sync.Mutex
+	calls map[string]*flightCall
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: make(map[string]*flightCall)}
+}
+
+// Do shared is true for callers which waited for another caller
+func (g *flightGroup) Do(key string, fn func() (interface{}, error)) (result interface{}, shared bool, err error) {
+	g.lock.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.lock.Unlock()
+		<-call.done
+		if call.panicked {
+			return nil, true, errFlightPanicked
+		}
+		return call.result, true, call.err
+	}
+
+	call := &flightCall{done: make(chan struct{}), panicked: true}
+	g.calls[key] = call
+	g.lock.Unlock()
+
+	defer func() {
+		g.lock.Lock()
+		delete(g.calls, key)
+		g.lock.Unlock()
+		close(call.done)
+	}()
+
+	call.result, call.err = fn()
+	call.panicked = false
+	return call.result, false, call.err
+}
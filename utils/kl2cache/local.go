@@ -0,0 +1,107 @@
+package kl2cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type localEntry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+// localCache in-process lru, every entry has its own expiration.
+// Values are json, callers unmarshal them and never share the cached object.
+type localCache struct {
+	lock     sync.Mutex
+	capacity int
+	entries  *list.List
+	index    map[string]*list.Element
+	// epoch increases on every invalidation, values fetched before it must not be set
+	epoch uint64
+}
+
+func newLocalCache(capacity int) *localCache {
+	return &localCache{
+		capacity: capacity,
+		entries:  list.New(),
+		index:    make(map[string]*list.Element, capacity),
+	}
+}
+
+func (c *localCache) Get(key string, now time.Time) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, ok := c.index[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*localEntry)
+	if !now.Before(entry.expireAt) {
+		c.remove(element)
+		return nil, false
+	}
+
+	c.entries.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *localCache) Epoch() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.epoch
+}
+
+// Set values fetched before an invalidation of epoch are dropped
+func (c *localCache) Set(key string, value []byte, expireAt time.Time, epoch uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if epoch != c.epoch {
+		return
+	}
+
+	if element, ok := c.index[key]; ok {
+		entry := element.Value.(*localEntry)
+		entry.value = value
+		entry.expireAt = expireAt
+		c.entries.MoveToFront(element)
+		return
+	}
+
+	c.index[key] = c.entries.PushFront(&localEntry{
+		key:      key,
+		value:    value,
+		expireAt: expireAt,
+	})
+	for c.entries.Len() > c.capacity {
+		c.remove(c.entries.Back())
+		stats.addLocalEvictions(1)
+	}
+}
+
+func (c *localCache) Delete(keys ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.epoch++
+	for _, key := range keys {
+		if element, ok := c.index[key]; ok {
+			c.remove(element)
+		}
+	}
+}
+
+func (c *localCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.entries.Len()
+}
+
+func (c *localCache) remove(element *list.Element) {
+	c.entries.Remove(element)
+	delete(c.index, element.Value.(*localEntry).key)
+}
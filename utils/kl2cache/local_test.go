@@ -0,0 +1,128 @@
+package kl2cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLocalCacheEvictLeastRecentlyUsed(t *testing.T) {
+	now := time.Now()
+	c := newLocalCache(2)
+	c.Set("a", []byte("1"), now.Add(time.Minute), c.Epoch())
+	c.Set("b", []byte("2"), now.Add(time.Minute), c.Epoch())
+	if _, ok := c.Get("a", now); !ok {
+		t.Fatal("a should be cached")
+	}
+
+	c.Set("c", []byte("3"), now.Add(time.Minute), c.Epoch())
+	if _, ok := c.Get("b", now); ok {
+		t.Error("b is the least recently used, it should be evicted")
+	}
+	if _, ok := c.Get("a", now); !ok {
+		t.Error("a should be kept")
+	}
+	if c.Len() != 2 {
+		t.Errorf("len should be 2, got %d", c.Len())
+	}
+}
+
+func TestLocalCacheExpiration(t *testing.T) {
+	now := time.Now()
+	c := newLocalCache(10)
+	c.Set("short", []byte("1"), now.Add(time.Second), c.Epoch())
+	c.Set("long", []byte("2"), now.Add(time.Minute), c.Epoch())
+
+	later := now.Add(2 * time.Second)
+	if _, ok := c.Get("short", later); ok {
+		t.Error("short should be expired")
+	}
+	if value, ok := c.Get("long", later); !ok || string(value) != "2" {
+		t.Errorf("long should be cached, got %q", value)
+	}
+}
+
+func TestLocalCacheInvalidation(t *testing.T) {
+	now := time.Now()
+	c := newLocalCache(10)
+	c.Set("a", []byte("1"), now.Add(time.Minute), c.Epoch())
+
+	// a value fetched before the invalidation must not be set after it
+	epoch := c.Epoch()
+	c.Delete("a")
+	c.Set("a", []byte("stale"), now.Add(time.Minute), epoch)
+	if _, ok := c.Get("a", now); ok {
+		t.Error("a should be invalidated")
+	}
+
+	c.Set("a", []byte("2"), now.Add(time.Minute), c.Epoch())
+	if value, ok := c.Get("a", now); !ok || string(value) != "2" {
+		t.Errorf("a should be cached again, got %q", value)
+	}
+}
+
+func TestFlightGroupCoalesce(t *testing.T) {
+	g := newFlightGroup()
+	release := make(chan struct{})
+	var calls, sharedCalls int32
+
+	leader := make(chan struct{})
+	go func() {
+		g.Do("key", func() (interface{}, error) {
+			close(leader)
+			<-release
+			atomic.AddInt32(&calls, 1)
+			return "value", nil
+		})
+	}()
+	<-leader
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, shared, err := g.Do("key", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				return "value", nil
+			})
+			if err != nil || result != "value" {
+				t.Errorf("unexpected result %v, %v", result, err)
+			}
+			if shared {
+				atomic.AddInt32(&sharedCalls, 1)
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// every waiter either shared the leader's call or ran its own after the leader finished
+	if n, shared := atomic.LoadInt32(&calls), atomic.LoadInt32(&sharedCalls); shared == 0 || n-1+shared != 5 {
+		t.Errorf("unexpected calls %d, shared %d", n, shared)
+	}
+}
+
+func TestFlightGroupPanic(t *testing.T) {
+	g := newFlightGroup()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic should be raised in the caller running fn")
+			}
+		}()
+		g.Do("key", func() (interface{}, error) {
+			panic("boom")
+		})
+	}()
+
+	result, shared, err := g.Do("key", func() (interface{}, error) {
+		return "value", nil
+	})
+	if err != nil || shared || result != "value" {
+		t.Errorf("the key should be released after a panic: %v, %v, %v", result, shared, err)
+	}
+}
@@ -121,11 +121,32 @@ func (r *redisProvider) getWithOutCache(ctx context.Context, key Key, val interf
 }
 
 func (r *redisProvider) getWithCache(ctx context.Context, key Key, val interface{}, fGetData innerFuncGet) (innerPanic bool, err error) {
-	start := time.Now()
 	var buf []byte
+	innerPanic, buf, err = r.getRaw(ctx, key, fGetData)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(buf, val)
+	if err != nil {
+		log.Error(ctx,
+			"json.Unmarshal failed",
+			log.Err(err),
+			log.Any("from", string(buf)),
+			log.Any("to", val),
+		)
+		return
+	}
+	return
+}
+
+// getRaw get the json of key from redis, fetch and set it by fGetData if missed
+func (r *redisProvider) getRaw(ctx context.Context, key Key, fGetData innerFuncGet) (innerPanic bool, buf []byte, err error) {
+	start := time.Now()
 	s, err := r.Client.Get(ctx, key.Key()).Result()
 	switch err {
 	case nil:
+		stats.addRedisHits(1)
 		log.Info(ctx,
 			"got data from redis",
 			log.Any("key", key.Key()),
@@ -134,6 +155,7 @@ func (r *redisProvider) getWithCache(ctx context.Context, key Key, val interface
 		)
 		buf = []byte(s)
 	case redis.Nil:
+		stats.addRedisMisses(1)
 		log.Info(ctx,
 			"miss cache from redis,call fGetData",
 			log.Any("key", key.Key()),
@@ -164,17 +186,6 @@ func (r *redisProvider) getWithCache(ctx context.Context, key Key, val interface
 			log.Duration("duration", time.Since(start)))
 		return
 	}
-
-	err = json.Unmarshal(buf, val)
-	if err != nil {
-		log.Error(ctx,
-			"json.Unmarshal failed",
-			log.Err(err),
-			log.Any("from", string(buf)),
-			log.Any("to", val),
-		)
-		return
-	}
 	return
 }
 
@@ -255,6 +266,37 @@ func (r *redisProvider) batchGetWithOutCache(ctx context.Context, keys []Key, va
 }
 
 func (r *redisProvider) batchGetWithCache(ctx context.Context, keys []Key, val interface{}, fGetData innerFuncBatchGet) (innerPanic bool, err error) {
+	var values map[string]string
+	innerPanic, values, err = r.batchGetRaw(ctx, keys, fGetData)
+	if err != nil {
+		return
+	}
+
+	valStrArr := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if s, ok := values[key.Key()]; ok {
+			valStrArr = append(valStrArr, s)
+		}
+	}
+
+	valStr := "[" + strings.Join(valStrArr, ",") + "]"
+	err = json.Unmarshal([]byte(valStr), val)
+	if err != nil {
+		log.Error(ctx,
+			"json.Unmarshal failed",
+			log.Err(err),
+			log.Any("from", valStr),
+			log.Any("to", val),
+		)
+		return
+	}
+	return
+
+}
+
+// batchGetRaw get the json of keys from redis, fetch and set missed ones by fGetData.
+// Keys fGetData has no value for are not in values.
+func (r *redisProvider) batchGetRaw(ctx context.Context, keys []Key, fGetData innerFuncBatchGet) (innerPanic bool, values map[string]string, err error) {
 	var keyStrArr []string
 	for _, key := range keys {
 		keyStrArr = append(keyStrArr, key.Key())
@@ -281,18 +323,20 @@ func (r *redisProvider) batchGetWithCache(ctx context.Context, keys []Key, val i
 		log.Any("keys", keyStrArr),
 		log.Duration("duration", time.Since(start)))
 
-	valStrArr := make([]string, 0, len(keys))
+	values = make(map[string]string, len(keys))
 	missed := make([]Key, 0, len(keys))
 	mMissed := map[string]bool{}
 	for i := 0; i < len(keys); i++ {
 		s, ok := rsCached[i].(string)
 		if ok {
-			valStrArr = append(valStrArr, s)
+			values[keys[i].Key()] = s
 		} else {
 			missed = append(missed, keys[i])
 			mMissed[keys[i].Key()] = true
 		}
 	}
+	stats.addRedisHits(int64(len(keys) - len(missed)))
+	stats.addRedisMisses(int64(len(missed)))
 	log.Info(ctx, "missed keys", log.Any("keys", keyStrArr), log.Any("missed", missed))
 	if len(missed) > 0 {
 		var rsGot []*KeyValue
@@ -324,7 +368,7 @@ func (r *redisProvider) batchGetWithCache(ctx context.Context, keys []Key, val i
 				)
 				return
 			}
-			valStrArr = append(valStrArr, s)
+			values[kv.Key.Key()] = s
 		}
 
 		start := time.Now()
@@ -344,18 +388,22 @@ func (r *redisProvider) batchGetWithCache(ctx context.Context, keys []Key, val i
 			log.Any("rsGot", rsGot),
 			log.Duration("duration", time.Since(start)))
 	}
+	return
+}
 
-	valStr := "[" + strings.Join(valStrArr, ",") + "]"
-	err = json.Unmarshal([]byte(valStr), val)
+func (r *redisProvider) Invalidate(ctx context.Context, keys ...Key) (err error) {
+	if !r.Enable || len(keys) == 0 {
+		return
+	}
+
+	keyStrArr := make([]string, 0, len(keys))
+	for _, key := range keys {
+		keyStrArr = append(keyStrArr, key.Key())
+	}
+	err = r.Client.Del(ctx, keyStrArr...).Err()
 	if err != nil {
-		log.Error(ctx,
-			"json.Unmarshal failed",
-			log.Err(err),
-			log.Any("from", valStr),
-			log.Any("to", val),
-		)
+		log.Error(ctx, "redis del failed", log.Err(err), log.Strings("keys", keyStrArr))
 		return
 	}
 	return
-
 }
@@ -0,0 +1,54 @@
+package kl2cache
+
+import "sync/atomic"
+
+// Stats counters since the instance started
+type Stats struct {
+	LocalHits   int64 `json:"local_hits"`
+	LocalMisses int64 `json:"local_misses"`
+	// LocalEvictions entries dropped for the capacity, expired and invalidated ones are not counted
+	LocalEvictions int64 `json:"local_evictions"`
+	LocalEntries   int64 `json:"local_entries"`
+	RedisHits      int64 `json:"redis_hits"`
+	RedisMisses    int64 `json:"redis_misses"`
+	// Coalesced misses served by a concurrent fetch of the same keys
+	Coalesced     int64 `json:"coalesced"`
+	Invalidations int64 `json:"invalidations"`
+}
+
+type counters struct {
+	localHits      int64
+	localMisses    int64
+	localEvictions int64
+	redisHits      int64
+	redisMisses    int64
+	coalesced      int64
+	invalidations  int64
+}
+
+var stats = new(counters)
+
+func (c *counters) addLocalHits(n int64)      { atomic.AddInt64(&c.localHits, n) }
+func (c *counters) addLocalMisses(n int64)    { atomic.AddInt64(&c.localMisses, n) }
+func (c *counters) addLocalEvictions(n int64) { atomic.AddInt64(&c.localEvictions, n) }
+func (c *counters) addRedisHits(n int64)      { atomic.AddInt64(&c.redisHits, n) }
+func (c *counters) addRedisMisses(n int64)    { atomic.AddInt64(&c.redisMisses, n) }
+func (c *counters) addCoalesced(n int64)      { atomic.AddInt64(&c.coalesced, n) }
+func (c *counters) addInvalidations(n int64)  { atomic.AddInt64(&c.invalidations, n) }
+
+// GetStats hit and miss counters of this instance
+func GetStats() Stats {
+	result := Stats{
+		LocalHits:      atomic.LoadInt64(&stats.localHits),
+		LocalMisses:    atomic.LoadInt64(&stats.localMisses),
+		LocalEvictions: atomic.LoadInt64(&stats.localEvictions),
+		RedisHits:      atomic.LoadInt64(&stats.redisHits),
+		RedisMisses:    atomic.LoadInt64(&stats.redisMisses),
+		Coalesced:      atomic.LoadInt64(&stats.coalesced),
+		Invalidations:  atomic.LoadInt64(&stats.invalidations),
+	}
+	if p, ok := DefaultProvider.(*tieredProvider); ok {
+		result.LocalEntries = int64(p.local.Len())
+	}
+	return result
+}
@@ -0,0 +1,228 @@
+package kl2cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KL-Engineering/common-log/log"
+)
+
+// invalidationChannel every instance drops the keys published on it from its local tier
+const invalidationChannel = "kl2cache:invalidation"
+
+var errFlightPanicked = errors.New("kl2cache: concurrent fetch panicked")
+
+func OptLocal(capacity int, expiration time.Duration) Option {
+	return func(c *config) {
+		c.Local.Capacity = capacity
+		c.Local.Expiration = expiration
+	}
+}
+
+// tieredProvider the local tier in front of redis. Concurrent misses of the same keys are fetched once.
+// Invalidations are broadcast over redis pub/sub, a lost message is bounded by the local expiration.
+type tieredProvider struct {
+	redis           *redisProvider
+	local           *localCache
+	localExpiration time.Duration
+	flights         *flightGroup
+}
+
+func initTiered(ctx context.Context, conf *config, rProvider *redisProvider) {
+	provider := &tieredProvider{
+		redis:           rProvider,
+		local:           newLocalCache(conf.Local.Capacity),
+		localExpiration: conf.Local.Expiration,
+		flights:         newFlightGroup(),
+	}
+	go provider.subscribeInvalidation(context.Background())
+	DefaultProvider = provider
+}
+
+func (t *tieredProvider) subscribeInvalidation(ctx context.Context) {
+	pubsub := t.redis.Client.Subscribe(ctx, invalidationChannel)
+	defer pubsub.Close()
+
+	for message := range pubsub.Channel() {
+		var keys []string
+		if err := json.Unmarshal([]byte(message.Payload), &keys); err != nil {
+			log.Warn(ctx, "invalid kl2cache invalidation message", log.Err(err), log.String("payload", message.Payload))
+			continue
+		}
+		t.local.Delete(keys...)
+		stats.addInvalidations(1)
+	}
+}
+
+func (t *tieredProvider) WithExpireStrategy(ctx context.Context, strategy ExpireStrategy) (provider Provider) {
+	cloned := *t
+	cloned.redis = t.redis.WithExpireStrategy(ctx, strategy).(*redisProvider)
+	provider = &cloned
+	return
+}
+
+// expireAt a local entry never outlives the redis one
+func (t *tieredProvider) expireAt(now time.Time) time.Time {
+	expiration := t.localExpiration
+	if ex := t.redis.CalcExpired(nil); ex > 0 && ex < expiration {
+		expiration = ex
+	}
+	return now.Add(expiration)
+}
+
+func (t *tieredProvider) Get(ctx context.Context, key Key, val interface{}, fGetData FuncGet) (err error) {
+	innerPanic := false
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			return
+		}
+		if innerPanic {
+			panic(rec)
+		}
+		err = fmt.Errorf("kl2cache(tieredProvider).Get panic: %+v", rec)
+		log.Error(ctx, "Get panic", log.Err(err))
+		return
+	}()
+
+	keyStr := key.Key()
+	if buf, ok := t.local.Get(keyStr, time.Now()); ok {
+		stats.addLocalHits(1)
+		return t.unmarshal(ctx, buf, val)
+	}
+	stats.addLocalMisses(1)
+
+	epoch := t.local.Epoch()
+	innerFnGetData := fGetData.wrapPanic()
+	result, shared, err := t.flights.Do(keyStr, func() (interface{}, error) {
+		var buf []byte
+		var err error
+		innerPanic, buf, err = t.redis.getRaw(ctx, key, innerFnGetData)
+		if err != nil {
+			return nil, err
+		}
+		t.local.Set(keyStr, buf, t.expireAt(time.Now()), epoch)
+		return buf, nil
+	})
+	if shared {
+		stats.addCoalesced(1)
+	}
+	if err != nil {
+		return
+	}
+
+	return t.unmarshal(ctx, result.([]byte), val)
+}
+
+func (t *tieredProvider) BatchGet(ctx context.Context, keys []Key, val interface{}, fGetData FuncBatchGet) (err error) {
+	innerPanic := false
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			return
+		}
+		if innerPanic {
+			panic(rec)
+		}
+		err = fmt.Errorf("kl2cache(tieredProvider).BatchGet panic: %+v", rec)
+		log.Error(ctx, "BatchGet panic", log.Err(err))
+		return
+	}()
+
+	now := time.Now()
+	values := make(map[string][]byte, len(keys))
+	missed := make([]Key, 0, len(keys))
+	missedKeyStrArr := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if buf, ok := t.local.Get(key.Key(), now); ok {
+			values[key.Key()] = buf
+			continue
+		}
+		missed = append(missed, key)
+		missedKeyStrArr = append(missedKeyStrArr, key.Key())
+	}
+	stats.addLocalHits(int64(len(keys) - len(missed)))
+	stats.addLocalMisses(int64(len(missed)))
+
+	if len(missed) > 0 {
+		epoch := t.local.Epoch()
+		innerFnGetData := fGetData.wrapPanic()
+		result, shared, err := t.flights.Do(strings.Join(missedKeyStrArr, "\n"), func() (interface{}, error) {
+			var fetched map[string]string
+			var err error
+			innerPanic, fetched, err = t.redis.batchGetRaw(ctx, missed, innerFnGetData)
+			if err != nil {
+				return nil, err
+			}
+			expireAt := t.expireAt(time.Now())
+			for key, s := range fetched {
+				t.local.Set(key, []byte(s), expireAt, epoch)
+			}
+			return fetched, nil
+		})
+		if shared {
+			stats.addCoalesced(int64(len(missed)))
+		}
+		if err != nil {
+			return err
+		}
+		for key, s := range result.(map[string]string) {
+			values[key] = []byte(s)
+		}
+	}
+
+	valStrArr := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if buf, ok := values[key.Key()]; ok {
+			valStrArr = append(valStrArr, string(buf))
+		}
+	}
+	return t.unmarshal(ctx, []byte("["+strings.Join(valStrArr, ",")+"]"), val)
+}
+
+func (t *tieredProvider) Invalidate(ctx context.Context, keys ...Key) (err error) {
+	if len(keys) == 0 {
+		return
+	}
+
+	keyStrArr := make([]string, 0, len(keys))
+	for _, key := range keys {
+		keyStrArr = append(keyStrArr, key.Key())
+	}
+	t.local.Delete(keyStrArr...)
+
+	err = t.redis.Invalidate(ctx, keys...)
+	if err != nil {
+		return
+	}
+
+	payload, err := json.Marshal(keyStrArr)
+	if err != nil {
+		log.Error(ctx, "marshal kl2cache invalidation failed", log.Err(err), log.Strings("keys", keyStrArr))
+		return
+	}
+	err = t.redis.Client.Publish(ctx, invalidationChannel, string(payload)).Err()
+	if err != nil {
+		log.Error(ctx, "publish kl2cache invalidation failed", log.Err(err), log.Strings("keys", keyStrArr))
+		return
+	}
+	return
+}
+
+func (t *tieredProvider) unmarshal(ctx context.Context, buf []byte, val interface{}) (err error) {
+	err = json.Unmarshal(buf, val)
+	if err != nil {
+		log.Error(ctx,
+			"json.Unmarshal failed",
+			log.Err(err),
+			log.Any("from", string(buf)),
+			log.Any("to", val),
+		)
+		return
+	}
+	return
+}
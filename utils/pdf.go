@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// a4 landscape in points
+const (
+	pdfPageWidth    = 842.0
+	pdfPageHeight   = 595.0
+	pdfMargin       = 36.0
+	pdfFontSize     = 8.0
+	pdfRowHeight    = 13.0
+	pdfMinColWidth  = 40.0
+	pdfChartBars    = 20
+	pdfChartBarSize = 10.0
+)
+
+// PdfBarChart values drawn as horizontal bars, at most 20 of them
+type PdfBarChart struct {
+	Title  string
+	Labels []string
+	Values []float64
+}
+
+// PdfReport printable report of a title, key value summary, optional chart and a table repeating its header on every page.
+// Text is written with the standard Helvetica font, characters out of latin-1 are printed as '?'.
+type PdfReport struct {
+	Title   string
+	Summary [][2]string
+	Chart   *PdfBarChart
+	Columns []string
+	Rows    [][]string
+}
+
+type pdfWriter struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+}
+
+// WritePdf write the report as a pdf document
+func WritePdf(w io.Writer, report *PdfReport) error {
+	p := &pdfWriter{}
+	p.newPage()
+
+	p.text("F2", 14, pdfMargin, p.y-14, report.Title)
+	p.y -= 28
+
+	for _, item := range report.Summary {
+		p.ensure(pdfRowHeight)
+		p.text("F2", pdfFontSize, pdfMargin, p.y-pdfFontSize, item[0])
+		p.text("F1", pdfFontSize, pdfMargin+160, p.y-pdfFontSize, item[1])
+		p.y -= pdfRowHeight
+	}
+	if len(report.Summary) > 0 {
+		p.y -= pdfRowHeight
+	}
+
+	if report.Chart != nil && len(report.Chart.Values) > 0 {
+		p.chart(report.Chart)
+	}
+
+	if len(report.Columns) > 0 {
+		p.table(report.Columns, report.Rows)
+	}
+
+	return p.writeTo(w)
+}
+
+func (p *pdfWriter) newPage() {
+	p.page = new(bytes.Buffer)
+	p.pages = append(p.pages, p.page)
+	p.y = pdfPageHeight - pdfMargin
+}
+
+// ensure start a new page unless the height fits in the current one
+func (p *pdfWriter) ensure(height float64) bool {
+	if p.y-height >= pdfMargin {
+		return false
+	}
+	p.newPage()
+	return true
+}
+
+func (p *pdfWriter) text(font string, size, x, y float64, s string) {
+	fmt.Fprintf(p.page, "BT /%s %s Tf %s %s Td (", font, pdfNum(size), pdfNum(x), pdfNum(y))
+	p.page.Write(pdfEscape(s))
+	p.page.WriteString(") Tj ET\n")
+}
+
+func (p *pdfWriter) rect(r, g, b, x, y, width, height float64) {
+	fmt.Fprintf(p.page, "%s %s %s rg %s %s %s %s re f 0 g\n",
+		pdfNum(r), pdfNum(g), pdfNum(b), pdfNum(x), pdfNum(y), pdfNum(width), pdfNum(height))
+}
+
+func (p *pdfWriter) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(p.page, "0.8 G 0.5 w %s %s m %s %s l S 0 G\n", pdfNum(x1), pdfNum(y1), pdfNum(x2), pdfNum(y2))
+}
+
+func (p *pdfWriter) chart(chart *PdfBarChart) {
+	count := len(chart.Values)
+	if count > pdfChartBars {
+		count = pdfChartBars
+	}
+	top := 0.0
+	for _, value := range chart.Values[:count] {
+		if value > top {
+			top = value
+		}
+	}
+
+	p.ensure(pdfRowHeight*2 + float64(count)*(pdfChartBarSize+3))
+	p.text("F2", 10, pdfMargin, p.y-10, chart.Title)
+	p.y -= pdfRowHeight * 1.5
+
+	labelWidth := 160.0
+	barWidth := pdfPageWidth - pdfMargin*2 - labelWidth - 60
+	for i, value := range chart.Values[:count] {
+		label := ""
+		if i < len(chart.Labels) {
+			label = chart.Labels[i]
+		}
+		p.text("F1", pdfFontSize, pdfMargin, p.y-pdfFontSize, pdfFit(label, labelWidth-4, pdfFontSize))
+		width := 0.0
+		if top > 0 && value > 0 {
+			width = barWidth * value / top
+		}
+		p.rect(0.25, 0.5, 0.8, pdfMargin+labelWidth, p.y-pdfChartBarSize, width, pdfChartBarSize)
+		p.text("F1", pdfFontSize, pdfMargin+labelWidth+width+4, p.y-pdfFontSize, strconv.FormatFloat(value, 'f', -1, 64))
+		p.y -= pdfChartBarSize + 3
+	}
+	p.y -= pdfRowHeight
+}
+
+func (p *pdfWriter) table(columns []string, rows [][]string) {
+	width := (pdfPageWidth - pdfMargin*2) / float64(len(columns))
+	if width < pdfMinColWidth {
+		width = pdfMinColWidth
+	}
+	// columns out of the page are not printed
+	visible := int((pdfPageWidth - pdfMargin*2) / width)
+	if visible > len(columns) {
+		visible = len(columns)
+	}
+
+	header := func() {
+		p.rect(0.9, 0.9, 0.9, pdfMargin, p.y-pdfRowHeight, width*float64(visible), pdfRowHeight)
+		for i := 0; i < visible; i++ {
+			p.text("F2", pdfFontSize, pdfMargin+float64(i)*width+2, p.y-pdfRowHeight+3, pdfFit(columns[i], width-4, pdfFontSize))
+		}
+		p.y -= pdfRowHeight
+	}
+
+	p.ensure(pdfRowHeight * 2)
+	header()
+	for _, row := range rows {
+		if p.ensure(pdfRowHeight) {
+			header()
+		}
+		for i := 0; i < visible && i < len(row); i++ {
+			p.text("F1", pdfFontSize, pdfMargin+float64(i)*width+2, p.y-pdfRowHeight+3, pdfFit(row[i], width-4, pdfFontSize))
+		}
+		p.y -= pdfRowHeight
+		p.line(pdfMargin, p.y, pdfMargin+width*float64(visible), p.y)
+	}
+}
+
+func (p *pdfWriter) writeTo(w io.Writer) error {
+	buf := new(bytes.Buffer)
+	var offsets []int
+	object := func(format string, args ...interface{}) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(buf, format, args...)
+		buf.WriteString("\nendobj\n")
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1 catalog, 2 pages, 3 and 4 fonts, then every page followed by its content
+	kids := new(bytes.Buffer)
+	for i := range p.pages {
+		fmt.Fprintf(kids, "%d 0 R ", 5+i*2)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [%s] /Count %d >>", bytes.TrimSpace(kids.Bytes()), len(p.pages))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range p.pages {
+		object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfNum(pdfPageWidth), pdfNum(pdfPageHeight), 6+i*2)
+		object("<< /Length %d >>\nstream\n%s\nendstream", page.Len(), page.Bytes())
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func pdfNum(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// pdfEscape latin-1 bytes of the string with the delimiters of pdf strings escaped
+func pdfEscape(s string) []byte {
+	buf := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			buf = append(buf, '\\', byte(r))
+		case r < 0x20:
+			buf = append(buf, ' ')
+		case r < 0x100:
+			buf = append(buf, byte(r))
+		default:
+			buf = append(buf, '?')
+		}
+	}
+	return buf
+}
+
+// pdfFit cut the text to the width by the average width of helvetica characters
+func pdfFit(s string, width, size float64) string {
+	limit := int(width / (size * 0.5))
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	if limit <= 3 {
+		return string(runes[:limit])
+	}
+	return string(runes[:limit-3]) + "..."
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWritePdf(t *testing.T) {
+	rows := make([][]string, 100)
+	for i := range rows {
+		rows[i] = []string{fmt.Sprintf("student %d", i), strconv.Itoa(i)}
+	}
+
+	buf := new(bytes.Buffer)
+	err := WritePdf(buf, &PdfReport{
+		Title:   "Attendance (weekly)",
+		Summary: [][2]string{{"total", "100"}},
+		Chart:   &PdfBarChart{Title: "score", Labels: []string{"a", "b"}, Values: []float64{1, 2}},
+		Columns: []string{"name", "score"},
+		Rows:    rows,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.String()
+	if !strings.HasPrefix(data, "%PDF-1.4") || !strings.HasSuffix(data, "%%EOF\n") {
+		t.Fatal("not a pdf document")
+	}
+	if !strings.Contains(data, `(Attendance \(weekly\)) Tj`) {
+		t.Error("title is not escaped")
+	}
+	if !strings.Contains(data, "/Count 3 ") {
+		t.Error("want 3 pages")
+	}
+
+	// every xref entry points to its object
+	xref := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllStringSubmatch(data, -1)
+	if len(xref) != 4+3*2 {
+		t.Fatalf("got %d objects", len(xref))
+	}
+	for i, entry := range xref {
+		offset, _ := strconv.Atoi(entry[1])
+		if !strings.HasPrefix(data[offset:], fmt.Sprintf("%d 0 obj", i+1)) {
+			t.Errorf("xref of object %d is wrong", i+1)
+		}
+	}
+	start := regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(data)
+	if offset, _ := strconv.Atoi(start[1]); !strings.HasPrefix(data[offset:], "xref") {
+		t.Error("startxref is wrong")
+	}
+}
+
+func TestPdfEscapeAndFit(t *testing.T) {
+	if got := string(pdfEscape("a(b)\\c\n수학é")); got != "a\\(b\\)\\\\c ??\xe9" {
+		t.Errorf("pdfEscape got %q", got)
+	}
+	if got := pdfFit("abcdefghij", 20, 8); got != "ab..." {
+		t.Errorf("pdfFit got %q", got)
+	}
+	if got := pdfFit("abc", 20, 8); got != "abc" {
+		t.Errorf("pdfFit got %q", got)
+	}
+}
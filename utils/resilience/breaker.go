@@ -0,0 +1,178 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("resilience: circuit open")
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half_open"
+)
+
+// Value metric value of the state, 0 closed, 1 half open, 2 open
+func (s State) Value() float64 {
+	switch s {
+	case StateOpen:
+		return 2
+	case StateHalfOpen:
+		return 1
+	default:
+		return 0
+	}
+}
+
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+	// outcomeIgnored permanent errors and cancelled callers tell nothing about the upstream
+	outcomeIgnored
+)
+
+// Status snapshot of a breaker and its counters since the instance started
+type Status struct {
+	Name                string    `json:"name"`
+	State               State     `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	OpenedAt            time.Time `json:"opened_at,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+	Successes           int64     `json:"successes"`
+	Failures            int64     `json:"failures"`
+	Rejections          int64     `json:"rejections"`
+	Retries             int64     `json:"retries"`
+	Hedges              int64     `json:"hedges"`
+	StaleServed         int64     `json:"stale_served"`
+}
+
+// Breaker opens after FailureThreshold consecutive failures and rejects calls for OpenTimeout,
+// then lets one probe through, the probe closes or opens it again.
+type Breaker struct {
+	lock     sync.Mutex
+	policy   Policy
+	now      func() time.Time
+	onChange func(name string, from, to State)
+
+	status  Status
+	probing bool
+}
+
+func newBreaker(name string, policy Policy, onChange func(name string, from, to State)) *Breaker {
+	return &Breaker{
+		policy:   policy,
+		now:      time.Now,
+		onChange: onChange,
+		status:   Status{Name: name, State: StateClosed},
+	}
+}
+
+func (b *Breaker) Name() string {
+	return b.status.Name
+}
+
+// Allow returns ErrCircuitOpen when the call must not reach the upstream
+func (b *Breaker) Allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.status.State {
+	case StateOpen:
+		if b.now().Sub(b.status.OpenedAt) < b.policy.OpenTimeout {
+			b.status.Rejections++
+			return ErrCircuitOpen
+		}
+		b.transit(StateHalfOpen)
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			b.status.Rejections++
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (b *Breaker) report(result outcome, err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	halfOpen := b.status.State == StateHalfOpen
+	if halfOpen {
+		b.probing = false
+	}
+
+	switch result {
+	case outcomeSuccess:
+		b.status.Successes++
+		b.status.ConsecutiveFailures = 0
+		if halfOpen {
+			b.transit(StateClosed)
+		}
+	case outcomeFailure:
+		b.status.Failures++
+		b.status.ConsecutiveFailures++
+		if err != nil {
+			b.status.LastError = err.Error()
+		}
+		if halfOpen || (b.status.State == StateClosed && b.status.ConsecutiveFailures >= b.policy.FailureThreshold) {
+			b.status.OpenedAt = b.now()
+			b.transit(StateOpen)
+		}
+	}
+}
+
+func (b *Breaker) transit(to State) {
+	from := b.status.State
+	if from == to {
+		return
+	}
+	b.status.State = to
+	if to == StateClosed {
+		b.status.OpenedAt = time.Time{}
+	}
+	if b.onChange != nil {
+		b.onChange(b.status.Name, from, to)
+	}
+}
+
+func (b *Breaker) addRetries(n int64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.status.Retries += n
+}
+
+func (b *Breaker) addHedges(n int64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.status.Hedges += n
+}
+
+// AddStaleServed counts responses served from the last known good data
+func (b *Breaker) AddStaleServed(n int64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.status.StaleServed += n
+}
+
+func (b *Breaker) State() State {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.status.State
+}
+
+func (b *Breaker) Status() Status {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.status
+}
@@ -0,0 +1,220 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Policy struct {
+	// MaxAttempts attempts of an idempotent call, non idempotent calls run once
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// HedgeDelay a second attempt of an idempotent call starts when the first one is slower, zero is off
+	HedgeDelay       time.Duration
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// backoff full jitter, a random duration up to BaseBackoff * 2^retry capped by MaxBackoff
+func (p Policy) backoff(retry int, random func(n int64) int64) time.Duration {
+	ceiling := p.BaseBackoff
+	for i := 0; i < retry && ceiling < p.MaxBackoff; i++ {
+		ceiling *= 2
+	}
+	if ceiling > p.MaxBackoff {
+		ceiling = p.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(random(int64(ceiling) + 1))
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent the error is returned at once, it is neither retried nor counted as a failure of the upstream
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Func runs one attempt. Attempts of an idempotent call may run concurrently when hedged,
+// so they must not share mutable state, return the result instead of filling a shared value.
+type Func func(ctx context.Context) (interface{}, error)
+
+// Registry breakers by name, they share the policy
+type Registry struct {
+	policy   Policy
+	lock     sync.Mutex
+	breakers map[string]*Breaker
+	onChange func(name string, from, to State)
+
+	randLock sync.Mutex
+	rand     *rand.Rand
+}
+
+func NewRegistry(policy Policy) *Registry {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.FailureThreshold < 1 {
+		policy.FailureThreshold = 1
+	}
+	return &Registry{
+		policy:   policy,
+		breakers: make(map[string]*Breaker),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// OnStateChange fn runs under the lock of the breaker, it must not call the breaker
+func (r *Registry) OnStateChange(fn func(name string, from, to State)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.onChange = fn
+}
+
+func (r *Registry) Breaker(name string) *Breaker {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	breaker, ok := r.breakers[name]
+	if !ok {
+		breaker = newBreaker(name, r.policy, r.onChange)
+		r.breakers[name] = breaker
+	}
+	return breaker
+}
+
+// Statuses ordered by name
+func (r *Registry) Statuses() []Status {
+	r.lock.Lock()
+	breakers := make([]*Breaker, 0, len(r.breakers))
+	for _, breaker := range r.breakers {
+		breakers = append(breakers, breaker)
+	}
+	r.lock.Unlock()
+
+	statuses := make([]Status, 0, len(breakers))
+	for _, breaker := range breakers {
+		statuses = append(statuses, breaker.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func (r *Registry) random(n int64) int64 {
+	r.randLock.Lock()
+	defer r.randLock.Unlock()
+	return r.rand.Int63n(n)
+}
+
+// Do runs fn through the breaker of name. Idempotent calls are retried with jitter and hedged.
+func (r *Registry) Do(ctx context.Context, name string, idempotent bool, fn Func) (interface{}, error) {
+	breaker := r.Breaker(name)
+	attempts := 1
+	if idempotent {
+		attempts = r.policy.MaxAttempts
+	}
+
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			breaker.addRetries(1)
+			timer := time.NewTimer(r.policy.backoff(i-1, r.random))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, lastErr
+			case <-timer.C:
+			}
+		}
+
+		if err := breaker.Allow(); err != nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, err
+		}
+
+		var result interface{}
+		var err error
+		if idempotent && r.policy.HedgeDelay > 0 {
+			result, err = r.hedge(ctx, breaker, fn)
+		} else {
+			result, err = fn(ctx)
+		}
+
+		var permanent *permanentError
+		switch {
+		case err == nil:
+			breaker.report(outcomeSuccess, nil)
+			return result, nil
+		case errors.As(err, &permanent):
+			breaker.report(outcomeIgnored, nil)
+			return result, permanent.err
+		case ctx.Err() != nil:
+			breaker.report(outcomeIgnored, nil)
+			return result, err
+		}
+		breaker.report(outcomeFailure, err)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+type attemptResult struct {
+	result interface{}
+	err    error
+}
+
+// hedge starts a second attempt when the first one is slower than HedgeDelay, the first success wins
+func (r *Registry) hedge(ctx context.Context, breaker *Breaker, fn Func) (interface{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan attemptResult, 2)
+	run := func() {
+		result, err := fn(ctx)
+		results <- attemptResult{result: result, err: err}
+	}
+	go run()
+
+	timer := time.NewTimer(r.policy.HedgeDelay)
+	defer timer.Stop()
+
+	running := 1
+	var lastErr error
+	for {
+		select {
+		case <-timer.C:
+			if running == 1 && lastErr == nil {
+				breaker.addHedges(1)
+				running++
+				go run()
+			}
+		case res := <-results:
+			running--
+			if res.err == nil {
+				return res.result, nil
+			}
+			lastErr = res.err
+			var permanent *permanentError
+			if running == 0 || errors.As(res.err, &permanent) {
+				return res.result, res.err
+			}
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errUpstream = errors.New("upstream failed")
+
+func testPolicy() Policy {
+	return Policy{
+		MaxAttempts:      3,
+		BaseBackoff:      time.Millisecond,
+		MaxBackoff:       5 * time.Millisecond,
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+	}
+}
+
+func TestBreakerOpenAndProbe(t *testing.T) {
+	now := time.Now()
+	registry := NewRegistry(testPolicy())
+	breaker := registry.Breaker("ams")
+	breaker.now = func() time.Time { return now }
+
+	breaker.report(outcomeFailure, errUpstream)
+	if breaker.State() != StateClosed {
+		t.Fatal("one failure should not open the breaker")
+	}
+	breaker.report(outcomeFailure, errUpstream)
+	if breaker.State() != StateOpen {
+		t.Fatal("breaker should be open after the threshold")
+	}
+	if err := breaker.Allow(); err != ErrCircuitOpen {
+		t.Fatalf("open breaker should reject, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("one probe should be allowed after the open timeout, got %v", err)
+	}
+	if err := breaker.Allow(); err != ErrCircuitOpen {
+		t.Fatalf("only one probe should be allowed, got %v", err)
+	}
+	breaker.report(outcomeSuccess, nil)
+	if breaker.State() != StateClosed {
+		t.Fatal("successful probe should close the breaker")
+	}
+
+	status := breaker.Status()
+	if status.Failures != 2 || status.Successes != 1 || status.Rejections != 2 || status.LastError != errUpstream.Error() {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestBreakerFailedProbe(t *testing.T) {
+	now := time.Now()
+	registry := NewRegistry(testPolicy())
+	var transitions []State
+	registry.OnStateChange(func(name string, from, to State) {
+		transitions = append(transitions, to)
+	})
+	breaker := registry.Breaker("ams")
+	breaker.now = func() time.Time { return now }
+
+	breaker.report(outcomeFailure, errUpstream)
+	breaker.report(outcomeFailure, errUpstream)
+	now = now.Add(2 * time.Minute)
+	if err := breaker.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	breaker.report(outcomeFailure, errUpstream)
+	if breaker.State() != StateOpen || !breaker.Status().OpenedAt.Equal(now) {
+		t.Errorf("failed probe should open the breaker again, got %+v", breaker.Status())
+	}
+	if len(transitions) != 3 || transitions[0] != StateOpen || transitions[1] != StateHalfOpen || transitions[2] != StateOpen {
+		t.Errorf("unexpected transitions %v", transitions)
+	}
+}
+
+func TestDoRetryIdempotent(t *testing.T) {
+	registry := NewRegistry(testPolicy())
+	var calls int32
+	result, err := registry.Do(context.Background(), "ams", true, func(ctx context.Context) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) < 2 {
+			return nil, errUpstream
+		}
+		return "ok", nil
+	})
+	if err != nil || result != "ok" || calls != 2 {
+		t.Errorf("unexpected result %v, %v, calls %d", result, err, calls)
+	}
+	if status := registry.Breaker("ams").Status(); status.Retries != 1 || status.ConsecutiveFailures != 0 {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestDoNotRetryNonIdempotent(t *testing.T) {
+	registry := NewRegistry(testPolicy())
+	var calls int32
+	_, err := registry.Do(context.Background(), "ams", false, func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errUpstream
+	})
+	if err != errUpstream || calls != 1 {
+		t.Errorf("unexpected error %v, calls %d", err, calls)
+	}
+}
+
+func TestDoPermanentError(t *testing.T) {
+	registry := NewRegistry(testPolicy())
+	var calls int32
+	for i := 0; i < 3; i++ {
+		_, err := registry.Do(context.Background(), "ams", true, func(ctx context.Context) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, Permanent(errUpstream)
+		})
+		if err != errUpstream {
+			t.Errorf("permanent error should be unwrapped, got %v", err)
+		}
+	}
+	if calls != 3 || registry.Breaker("ams").State() != StateClosed {
+		t.Errorf("permanent errors should neither be retried nor open the breaker, calls %d", calls)
+	}
+}
+
+func TestDoStopsWhenOpen(t *testing.T) {
+	registry := NewRegistry(testPolicy())
+	var calls int32
+	_, err := registry.Do(context.Background(), "ams", true, func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errUpstream
+	})
+	if err != errUpstream || calls != 2 {
+		t.Errorf("retries should stop once the breaker opens, error %v, calls %d", err, calls)
+	}
+
+	_, err = registry.Do(context.Background(), "ams", true, func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "ok", nil
+	})
+	if err != ErrCircuitOpen || calls != 2 {
+		t.Errorf("open breaker should reject the call, error %v, calls %d", err, calls)
+	}
+}
+
+func TestDoHedge(t *testing.T) {
+	policy := testPolicy()
+	policy.HedgeDelay = 10 * time.Millisecond
+	registry := NewRegistry(policy)
+
+	var calls int32
+	start := time.Now()
+	result, err := registry.Do(context.Background(), "h5p", true, func(ctx context.Context) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return "hedged", nil
+	})
+	if err != nil || result != "hedged" {
+		t.Fatalf("unexpected result %v, %v", result, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("the slow attempt should not be awaited")
+	}
+	if status := registry.Breaker("h5p").Status(); status.Hedges != 1 || status.Failures != 0 {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestPolicyBackoff(t *testing.T) {
+	policy := Policy{BaseBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	max := func(n int64) int64 { return n - 1 }
+	cases := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	for retry, want := range cases {
+		if got := policy.backoff(retry, max); got != want {
+			t.Errorf("retry %d: want %v, got %v", retry, want, got)
+		}
+	}
+	if got := policy.backoff(3, func(n int64) int64 { return 0 }); got != 0 {
+		t.Errorf("jitter should allow no wait, got %v", got)
+	}
+}
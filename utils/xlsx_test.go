@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestXlsxColumnName(t *testing.T) {
+	tests := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"}
+	for index, want := range tests {
+		if got := XlsxColumnName(index); got != want {
+			t.Errorf("XlsxColumnName(%d) want %s, got %s", index, want, got)
+		}
+	}
+}
+
+func TestWriteXlsx(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := WriteXlsx(buf, "Grades", [][]interface{}{
+		{"Student", "Score"},
+		{"Tom & Jerry", 8.5},
+		{"<b>", nil, 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sheet string
+	names := make(map[string]bool)
+	for _, file := range zr.File {
+		names[file.Name] = true
+		if file.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sheet = string(data)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if !names[name] {
+			t.Errorf("missing part %s", name)
+		}
+	}
+
+	for _, want := range []string{
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">Tom &amp; Jerry</t></is></c>`,
+		`<c r="B2"><v>8.5</v></c>`,
+		`<t xml:space="preserve">&lt;b&gt;</t>`,
+		`<c r="C3"><v>3</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet should contain %s, got %s", want, sheet)
+		}
+	}
+	if strings.Contains(sheet, `r="B3"`) {
+		t.Errorf("nil cell should be skipped")
+	}
+}